@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ericmhalvorsen/witness/pkg/audit"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// handleAgent is run on the remote machine by witness remote. It records
+// the screen and writes PNG frames to stdout until stdin closes.
+func handleAgent(args []string) {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	regionStr := fs.String("r", "", "Capture region (x,y,w,h)")
+	regionName := fs.String("region", "", "Use a saved region by name")
+	fpsStr := fs.String("f", "15", "Frames per second (e.g. 15, 29.97, 30000/1001)")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: witness agent [options]")
+		fmt.Fprintln(os.Stderr, "\nRecord the screen as a PNG stream on stdout for witness remote; stops when stdin closes")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// stdout carries the frames
+	status = os.Stderr
+
+	fps, err := capture.ParseFPS(*fpsStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	region, err := resolveRegion(*regionStr, *regionName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	recConfig := recorder.DefaultConfig(capture.Config{Region: region, FPS: fps, IncludeCursor: true})
+	recConfig.PauseWhen, recConfig.StopWhen, err = recordingConditions(false, 0, true, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	enc := encoder.NewPNGEncoder(os.Stdout)
+	rec := recorder.NewRecorder(recConfig, enc)
+	recording, err := audit.StartRecording(region, "witness remote")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	stdinClosed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, os.Stdin)
+		close(stdinClosed)
+	}()
+
+	err = rec.Start()
+	if err == nil {
+		fmt.Fprintln(status, "● Recording... stop with Ctrl+C on the local machine")
+		select {
+		case <-interrupt:
+		case <-stdinClosed:
+		case <-rec.Done():
+		}
+		err = rec.Stop()
+		fmt.Fprintf(status, "Capture: %s\n", rec.Stats())
+	}
+	if auditErr := recording.Stop(err); auditErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", auditErr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
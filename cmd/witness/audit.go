@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ericmhalvorsen/witness/pkg/audit"
+)
+
+func handleAudit(args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	last := fs.Int("n", 0, "Show only the last n entries (0 shows all)")
+	userName := fs.String("user", "", "Show only entries for this user")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness audit [options]")
+		fmt.Println("\nShow the log of recording activity")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness audit                      # Show all entries")
+		fmt.Println("  witness audit -n 20                # Show the last 20 entries")
+		fmt.Println("  witness audit -user alice          # Show entries for 'alice'")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	entries, err := audit.Read()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *userName != "" {
+		filtered := entries[:0]
+		for _, e := range entries {
+			if e.User == *userName {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+
+	if *last > 0 && len(entries) > *last {
+		entries = entries[len(entries)-*last:]
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No recording activity")
+		return
+	}
+
+	for _, e := range entries {
+		fmt.Println(e)
+	}
+}
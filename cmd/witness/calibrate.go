@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"image/png"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/calibrate"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func handleCalibrate(args []string) {
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	regionStr := fs.String("r", "", "Capture region around the chart (x,y,w,h)")
+	regionName := fs.String("region", "", "Use a saved region by name")
+	display := fs.String("display", "", "Check the display with this ID, UUID, or name (see witness displays; default the main display)")
+	listen := fs.String("listen", calibrate.DefaultAddr, "Address to serve the chart on")
+	savePath := fs.String("save", "", "Also save the captured frame as a PNG")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness calibrate [options]")
+		fmt.Println("\nServe a chart of 24 known color patches, capture it from the screen, and")
+		fmt.Println("report how far each captured color is from the one drawn, as CIE76 ΔE. Open")
+		fmt.Println("the chart in a browser and click it to fill the screen before capturing.")
+		fmt.Println("Exits with status 1 when a patch is off by more than ΔE", calibrate.MaxDeltaE)
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness calibrate")
+		fmt.Println("  witness calibrate -display \"DELL U2720Q\"")
+		fmt.Println("  witness calibrate -r 100,100,900,600 -save chart.png")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	region, err := resolveRegion(*regionStr, *regionName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	go http.Serve(ln, calibrate.NewServer())
+
+	fmt.Printf("Open http://%s/ in a browser on the display being checked, and click the\n", *listen)
+	fmt.Println("chart to fill the screen. Turn off Night Shift and True Tone for the check.")
+	fmt.Print("Press Enter once the chart is showing... ")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+
+	frame, err := captureStill(capture.Config{Region: region, Display: *display, FPS: capture.IntFPS(10)})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *savePath != "" {
+		f, err := os.Create(*savePath)
+		if err == nil {
+			err = png.Encode(f, frame.Image)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save the capture: %v\n", err)
+		} else {
+			fmt.Fprintf(status, "✓ Saved capture %s\n", displayName(*savePath))
+		}
+	}
+
+	report, err := calibrate.Measure(frame.Image)
+	if errors.Is(err, calibrate.ErrChartNotFound) {
+		err = fmt.Errorf("%w; make the chart fill the screen, or use -r to capture just the area around it", err)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n%-15s %-8s %-8s    ΔE\n", "Patch", "Drawn", "Captured")
+	for _, r := range report.Results {
+		mark := "✓"
+		switch {
+		case r.DeltaE > calibrate.MaxDeltaE:
+			mark = "✗"
+		case r.DeltaE > calibrate.NoticeableDeltaE:
+			mark = "!"
+		}
+		fmt.Printf("%-15s %-8s %-8s %5.1f %s\n", r.Patch.Name, hexColor(r.Patch.Color), hexColor(r.Captured), r.DeltaE, mark)
+	}
+
+	worst := report.Worst()
+	fmt.Println()
+	if !report.Passed() {
+		fmt.Printf("✗ Colors drift: mean ΔE %.1f, worst %.1f (%s)\n", report.Mean(), worst.DeltaE, worst.Patch.Name)
+		fmt.Println("  Check the display's color profile, HDR, Night Shift, and True Tone settings")
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Colors are accurate: mean ΔE %.1f, worst %.1f (%s)\n", report.Mean(), worst.DeltaE, worst.Patch.Name)
+}
+
+// captureStill captures a single frame, without the pointer
+func captureStill(config capture.Config) (*capture.Frame, error) {
+	capturer, err := capture.NewCapturer(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := capturer.Start(); err != nil {
+		if errors.Is(err, capture.ErrPermissionDenied) {
+			err = fmt.Errorf("%w (run 'witness doctor' for help)", err)
+		}
+		return nil, err
+	}
+	defer capturer.Stop()
+
+	select {
+	case frame, ok := <-capturer.Frames():
+		if !ok || frame == nil {
+			return nil, fmt.Errorf("capture ended before a frame arrived")
+		}
+		if err := frame.Load(); err != nil {
+			return nil, err
+		}
+		if frame.Image == nil {
+			return nil, fmt.Errorf("capture ended before a frame arrived")
+		}
+		return frame, nil
+	case err := <-capturer.Errors():
+		return nil, err
+	case <-time.After(5 * time.Second):
+		return nil, fmt.Errorf("no frame was captured within 5s")
+	}
+}
+
+// hexColor formats c as #rrggbb
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/remote"
+)
+
+func handleCtl(args []string) {
+	fs := flag.NewFlagSet("ctl", flag.ExitOnError)
+	addr := fs.String("addr", remote.DefaultAddr, "witness serve address (host:port)")
+	token := fs.String("token", "", "Shared secret the daemon expects (default $WITNESS_TOKEN)")
+	output := fs.String("o", "", "Output file name (default: witness-<date>-<time>.gif)")
+	duration := fs.Duration("d", 0, "Stop after this long (default: record until stopped)")
+	fpsStr := fs.String("f", "15", "Frames per second (e.g. 15, 29.97, 30000/1001)")
+	quality := fs.String("q", "medium", "Quality level (low, medium, high)")
+	regionStr := fs.String("r", "", "Capture region (x,y,w,h)")
+	regionName := fs.String("region", "", "Use a saved region")
+	jsonOut := fs.Bool("json", false, "Print the daemon's status as JSON")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness ctl [options] start|stop|toggle|status")
+		fmt.Println("\nControl the recording of a witness serve daemon, for Shortcuts, AppleScript,")
+		fmt.Println("Stream Deck buttons, and other automation. Prints the daemon's state:")
+		fmt.Println("idle, scheduled, or recording.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness ctl start                            # Record until stopped")
+		fmt.Println("  witness ctl -region demo -d 30s start")
+		fmt.Println("  witness ctl toggle                           # One button to start and stop")
+		fmt.Println("  witness ctl -json status")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	envDefault(fs, "token", "WITNESS_TOKEN")
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	client := remote.NewClient(*addr, *token)
+	var st remote.Status
+	var err error
+	switch action := fs.Arg(0); action {
+	case "start", "toggle":
+		if *duration < 0 {
+			fmt.Fprintf(os.Stderr, "Error: -d must not be negative\n")
+			os.Exit(1)
+		}
+		if _, err := capture.ParseFPS(*fpsStr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := encoder.ParseQuality(*quality); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		region, err := resolveRegion(*regionStr, *regionName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		req := remote.Request{
+			Duration: *duration,
+			Output:   *output,
+			FPS:      *fpsStr,
+			Quality:  *quality,
+			Region:   region,
+		}
+		if action == "start" {
+			st, err = client.Start(req)
+		} else {
+			st, err = client.Toggle(req)
+		}
+	case "stop":
+		st, err = client.Stop()
+	case "status":
+		st, err = client.Status()
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown action %q\n\n", action)
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(st); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Println(formatDaemonStatus(st))
+}
+
+// formatDaemonStatus describes a daemon's status on one line, starting
+// with its state so scripts can match on the first word
+func formatDaemonStatus(st remote.Status) string {
+	switch st.State {
+	case remote.StateRecording:
+		return fmt.Sprintf("recording %s since %s", st.Output, st.StartedAt.Format("15:04:05"))
+	case remote.StateScheduled:
+		return fmt.Sprintf("scheduled %s at %s", st.Output, st.StartAt.Format("15:04:05"))
+	}
+	if st.LastError != "" {
+		return fmt.Sprintf("%s (last recording failed: %s)", st.State, st.LastError)
+	}
+	return string(st.State)
+}
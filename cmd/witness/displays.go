@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func handleDisplays(args []string) {
+	fs := flag.NewFlagSet("displays", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness displays")
+		fmt.Println("\nList connected displays. Pass an ID, UUID, or name to -display to record that")
+		fmt.Println("display. IDs can change when displays are plugged in or removed; UUIDs and")
+		fmt.Println("names do not. Bounds are in points, the units of -r and saved regions.")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	displays, err := capture.ListDisplays()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Displays:")
+	for _, d := range displays {
+		fmt.Printf("  %s\n", d)
+		if d.UUID != "" {
+			fmt.Printf("     UUID %s\n", d.UUID)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/ocr"
+)
+
+func handleDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	open := fs.Bool("open", false, "Ask for screen recording permission and open its settings (macOS)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness doctor [options]")
+		fmt.Println("\nCheck that Witness can record this screen")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *open {
+		if err := capture.RequestPermission(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Grant Screen Recording permission to your terminal, then restart it and run witness doctor again.")
+		return
+	}
+
+	fmt.Printf("Witness %s on %s/%s\n\n", version, runtime.GOOS, runtime.GOARCH)
+
+	ok := true
+	if err := capture.CheckPermission(); err != nil {
+		ok = false
+		fmt.Printf("✗ Screen recording: %v\n", err)
+		if errors.Is(err, capture.ErrPermissionDenied) {
+			fmt.Println("  Open the settings with: witness doctor -open")
+		}
+	} else {
+		fmt.Println("✓ Screen recording permitted")
+	}
+
+	// ffmpeg is only needed by some features, so its absence is a warning
+	if path, err := encoder.FindFFmpeg(); err != nil {
+		if encoder.NativeVideo() {
+			fmt.Println("! ffmpeg not found: needed for -webcam, and for witness video with -roi, -o -, webm, or av1")
+		} else {
+			fmt.Println("! ffmpeg not found: needed for witness video and -webcam")
+		}
+	} else {
+		fmt.Printf("✓ ffmpeg: %s\n", path)
+	}
+	if path, err := exec.LookPath(ocr.TesseractBinary); err != nil {
+		fmt.Println("! tesseract not found: needed for witness ocr and search")
+	} else {
+		fmt.Printf("✓ tesseract: %s\n", path)
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+)
+
+func handleEdit(args []string) {
+	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+	input := fs.String("i", "", "Input GIF file path")
+	output := fs.String("o", "", "Output file path (- for stdout)")
+	reverse := fs.Bool("reverse", false, "Reverse the frame order (delays are preserved)")
+	trim := fs.String("trim", "", "Keep only frames first:last, counting from 0, e.g. 12:87 (see witness preview)")
+	autoLoop := fs.Bool("auto-loop", false, "Keep only the frames that loop seamlessly, when there are some")
+	autoCrop := fs.Bool("auto-crop", false, "Trim static, uniform borders from every frame")
+	autoRegion := fs.Bool("auto-region", false, "Crop mostly static recordings to the area that changes")
+	timeline := fs.String("timeline", "", "Export a JSON timeline for annotating with 'witness render'")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists instead of picking a new name")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness edit [options]")
+		fmt.Println("\nEdit an existing GIF recording")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness edit -i demo.gif -o undo.gif -reverse")
+		fmt.Println("  witness edit -i demo.gif -o trimmed.gif -trim 12:87")
+		fmt.Println("  witness edit -i spinner.gif -o looped.gif -auto-loop")
+		fmt.Println("  witness edit -i demo.gif -o window.gif -auto-crop")
+		fmt.Println("  witness edit -i demo.gif -o active.gif -auto-region")
+		fmt.Println("  witness edit -i demo.gif -timeline demo.json")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if writesToStdout(*output) {
+		status = os.Stderr
+	}
+
+	if *input == "" || (*output == "" && *timeline == "") {
+		fmt.Fprintf(os.Stderr, "Error: -i and one of -o or -timeline are required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	clip, err := editor.LoadGIF(*input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *timeline != "" {
+		if err := clip.Timeline(*input).Save(*timeline); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(status, "✓ Wrote timeline for %d frames to %s\n", clip.Len(), *timeline)
+		fmt.Fprintf(status, "\nAdd entries to \"annotations\", then run:\n")
+		fmt.Fprintf(status, "  witness render -t %s -o annotated.gif\n", *timeline)
+		if *output == "" {
+			return
+		}
+	}
+
+	*output, err = protectOutput(*output, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Frames are numbered as in the input, before -trim
+	offset := 0
+	if *trim != "" {
+		first, last, err := editor.ParseTrim(*trim, clip.Len())
+		if err == nil {
+			err = clip.Trim(first, last)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		offset = first
+	}
+
+	if loop, ok := clip.Loop(); !ok {
+		if *autoLoop {
+			fmt.Fprintf(os.Stderr, "Warning: no frames loop seamlessly; kept all %d\n", clip.Len())
+		}
+	} else if loop.Len() < clip.Len() {
+		if *autoLoop {
+			clip.Trim(loop.First, loop.Last)
+		}
+		loop.First, loop.Last = loop.First+offset, loop.Last+offset
+		if *autoLoop {
+			fmt.Fprintf(status, "✓ Kept frames %d-%d, which loop seamlessly\n", loop.First, loop.Last)
+		} else {
+			warnLoop(loop, fmt.Sprintf("-trim %d:%d", loop.First, loop.Last))
+		}
+	}
+
+	if *reverse {
+		clip.Reverse()
+	}
+
+	if *autoCrop {
+		crop := clip.AutoCrop()
+		fmt.Fprintf(status, "✓ Cropped to %dx%d at %d,%d\n", crop.Dx(), crop.Dy(), crop.Min.X, crop.Min.Y)
+	}
+
+	if activity := clip.Activity(); activity.MostlyStatic() {
+		region := activity.Suggest(clip.Frames[0].Bounds(), analyze.DefaultPadding)
+		if *autoRegion {
+			clip.Crop(region)
+			fmt.Fprintf(status, "✓ Cropped to active region %s\n", formatRect(region))
+		} else {
+			warnMostlyStatic(activity, region)
+		}
+	}
+
+	if err := writeClip(clip, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(status, "✓ Wrote %d frames to %s\n", clip.Len(), displayName(*output))
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+	"github.com/ericmhalvorsen/witness/pkg/replay"
+	"github.com/ericmhalvorsen/witness/pkg/source"
+)
+
+func handleEncode(args []string) {
+	fs := flag.NewFlagSet("encode", flag.ExitOnError)
+	output := fs.String("o", "", "Output file path (- for stdout)")
+	format := fs.String("format", "gif", "Output format (gif, y4m, rawvideo, mjpeg, png)")
+	input := fs.String("input", "rgba", "Frame format on stdin (rgba, png, y4m)")
+	size := fs.String("size", "", "Frame size for raw input, e.g. 800x600")
+	fpsStr := fs.String("f", "15", "Frames per second (e.g. 15, 29.97, 30000/1001)")
+	fs.StringVar(fpsStr, "fps", "15", "Alias for -f")
+	quality := fs.String("q", "medium", "Quality level (low, medium, high; see -list-qualities)")
+	listQualities := fs.Bool("list-qualities", false, "Describe the quality levels and exit")
+	palettePath := fs.String("palette", "", "Use the colors from a palette file (.gpl or .hex) instead of the quality preset")
+	colors := fs.Int("colors", 0, "Use an adaptive palette with this many colors (1-256) instead of the quality preset")
+	transparent := fs.String("transparent", "", "Make this color transparent, e.g. #00ff00 or green (chroma key)")
+	transparentTol := fs.Uint("transparent-tolerance", analyze.DefaultTolerance, "Per-channel tolerance for -transparent (0-255)")
+	alpha := fs.Bool("alpha", false, "Make pixels that are mostly transparent in the capture transparent in the GIF")
+	interlace := fs.Bool("interlace", false, "Write interlaced frames so large GIFs render progressively while loading")
+	lossy := fs.Int("lossy", 0, "Allow lossy compression with this color tolerance for smaller files (e.g. 20 subtle, 80 strong)")
+	dither := fs.Bool("dither", true, "Dither colors; -dither=false keeps flat UI and text clean")
+	disposalStr := fs.String("disposal", "auto", "Frame disposal method (auto, none, background, previous)")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists instead of picking a new name")
+	holdFirst := fs.Duration("hold-first", 0, "Extra time to show the first frame (e.g. 1s)")
+	holdLast := fs.Duration("hold-last", 0, "Extra time to show the last frame (e.g. 2s)")
+	reverse := fs.Bool("reverse", false, "Write frames in reverse order")
+	autoCrop := fs.Bool("auto-crop", false, "Trim static, uniform borders from the output")
+	autoRegion := fs.Bool("auto-region", false, "Crop mostly static animations to the area that changes")
+	denoiseOn := fs.Bool("denoise", false, "Suppress pixel flicker between frames for cleaner, smaller output")
+	denoiseTol := fs.Uint("denoise-tolerance", analyze.DefaultTolerance, "Largest per-channel change -denoise treats as noise (0-255)")
+	scaleFactor := fs.Float64("scale", 1, "Resize frames by this factor, e.g. 0.5 to halve Retina captures")
+	scaleMode := fs.String("scale-mode", "smooth", "Resampling for -scale (smooth, text for terminals and code, nearest for whole ratios)")
+	idleSkip := fs.Duration("idle-skip", 0, "Drop frames once the screen has been unchanged this long (e.g. 1s)")
+	presetName := fs.String("preset", "", "Apply a preset (terminal, browser-demo, full-tutorial, or one from config); other flags override it")
+	noSort := fs.Bool("no-sort", false, "Keep images in command-line order instead of sorting frame2 before frame10")
+	deterministic := fs.Bool("deterministic", false, "Give frames fixed timestamps so identical input always produces byte-identical output (for CI caching and diffs)")
+	var annotations annotationFlags
+	fs.Var(&annotations, "annotate", "Overlay an annotation, e.g. text:20,40,text=Step 1 (repeatable)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness encode [options] [images...] < frames")
+		fmt.Println("\nEncode an image sequence, or frames read from stdin, without capturing the screen")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness encode ./frames/*.png -o out.gif -fps 12")
+		fmt.Println("  witness encode ./frames -o out.gif -annotate 'text:20,40,text=Step 1'")
+		fmt.Println("  some-capture-tool | witness encode -size 800x600 -fps 15 -o out.gif")
+		fmt.Println("  cat frames/*.png | witness encode -input png -o out.gif")
+		fmt.Println("  ffmpeg -i in.mp4 -f yuv4mpegpipe - | witness encode -input y4m -o out.gif")
+		fmt.Println("  witness encode -input png -format y4m -o - < frames | ffmpeg -i - out.mp4")
+		fmt.Println("  witness encode capture.wrec -format png -o frames/   # frame_000000.png... and timings.json")
+		fmt.Println("  witness encode session.wrec -o out.gif")
+		fmt.Println("  witness encode session.wrec -deterministic -o docs/demo.gif")
+	}
+
+	images, err := parseInterspersed(fs, args)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	if *listQualities {
+		printQualities()
+		return
+	}
+
+	if err := applyPreset(fs, *presetName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if writesToStdout(*output) {
+		status = os.Stderr
+	}
+
+	if *output == "" {
+		fmt.Fprintf(os.Stderr, "Error: -o is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	switch *format {
+	case "gif", "y4m", "rawvideo", "mjpeg", "png":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unsupported format %q (want gif, y4m, rawvideo, mjpeg, or png)\n", *format)
+		os.Exit(1)
+	}
+
+	fps, err := capture.ParseFPS(*fpsStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	q, err := encoder.ParseQuality(*quality)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	pal, numColors, err := resolvePalette(*palettePath, *colors, *quality)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	chromaKey, err := parseChromaKey(*transparent, *transparentTol)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *lossy < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -lossy must be 0 or more, got %d\n", *lossy)
+		os.Exit(1)
+	}
+
+	if *denoiseTol > 255 {
+		fmt.Fprintf(os.Stderr, "Error: -denoise-tolerance must be between 0 and 255, got %d\n", *denoiseTol)
+		os.Exit(1)
+	}
+
+	scaling, err := parseScale(*scaleFactor, *scaleMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *idleSkip < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -idle-skip must not be negative, got %v\n", *idleSkip)
+		os.Exit(1)
+	}
+
+	disposal, err := encoder.ParseDisposal(*disposalStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var frames source.Reader
+	switch {
+	case len(images) == 1 && strings.HasSuffix(images[0], replay.Extension):
+		// The frame rate comes from the capture file
+		saved, err := replay.Open(images[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer saved.Close()
+		fps = saved.FPS()
+		frames = saved
+	case len(images) > 0:
+		paths, err := source.ExpandSequence(images)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !*noSort {
+			capture.SortNatural(paths)
+		}
+		frames = source.NewSequenceReader(paths, fps)
+	case *input == "rgba":
+		if *size == "" {
+			fmt.Fprintf(os.Stderr, "Error: -size is required for rgba input\n")
+			os.Exit(1)
+		}
+		width, height, err := source.ParseSize(*size)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		frames = source.NewRawReader(bufio.NewReader(os.Stdin), width, height, fps)
+	case *input == "png":
+		frames = source.NewPNGReader(os.Stdin, fps)
+	case *input == "y4m":
+		// The frame size and rate come from the stream header
+		y4m, err := source.NewY4MReader(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fps = y4m.FPS()
+		frames = y4m
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unsupported input %q (want rgba, png, or y4m)\n", *input)
+		os.Exit(1)
+	}
+
+	// Timestamps otherwise depend on when the frames were read, or for a
+	// capture file when it was recorded
+	if *deterministic {
+		frames = source.Rebase(frames, capture.Epoch)
+	}
+
+	*output, err = protectOutput(*output, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *format != "gif" {
+		n, err := streamFrames(*format, *output, fps, frames, func(sink recorder.FrameSink) recorder.FrameSink {
+			return skipIdle(denoise(annotate(rescale(sink, scaling), annotations), *denoiseOn, *denoiseTol), *idleSkip)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(status, "✓ Wrote %d frames to %s\n", n, displayName(*output))
+		return
+	}
+
+	enc := encoder.NewGIFEncoderFPS(*output, fps, q)
+	enc.SetHoldFirst(*holdFirst)
+	enc.SetHoldLast(*holdLast)
+	enc.SetReverse(*reverse)
+	enc.SetAutoCrop(*autoCrop)
+	enc.SetAutoRegion(*autoRegion)
+	enc.SetPalette(pal)
+	enc.SetColors(numColors)
+	if chromaKey != nil {
+		enc.SetChromaKey(*chromaKey, uint8(*transparentTol))
+	}
+	enc.SetAlphaTransparency(*alpha)
+	enc.SetInterlace(*interlace)
+	enc.SetLossy(*lossy)
+	enc.SetDither(*dither)
+	enc.SetDisposal(disposal)
+
+	n, err := source.Copy(skipIdle(denoise(annotate(rescale(enc, scaling), annotations), *denoiseOn, *denoiseTol), *idleSkip), frames)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if n == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no frames to encode\n")
+		os.Exit(1)
+	}
+
+	if writesToStdout(*output) {
+		err = enc.EncodeTo(os.Stdout)
+	} else {
+		err = enc.Encode()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(status, "✓ Encoded %d frames to %s\n", enc.FrameCount(), displayName(*output))
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
+	"github.com/ericmhalvorsen/witness/pkg/audit"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+	"github.com/ericmhalvorsen/witness/pkg/schedule"
+)
+
+func handleGif(args []string) {
+	fs := flag.NewFlagSet("gif", flag.ExitOnError)
+	output := fs.String("o", "", "Output file path (- for stdout)")
+	outDir := fs.String("out-dir", "", "Directory for bare -o file names, e.g. ~/Recordings/{year}/{month} (default: output_dir from config)")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists instead of picking a new name")
+	regionStr := fs.String("r", "", "Capture region (x,y,w,h)")
+	regionName := fs.String("region", "", "Use a saved region by name")
+	display := fs.String("display", "", "Record the display with this ID, UUID, or name, e.g. \"DELL U2720Q\" (see witness displays; default the main display)")
+	cursor := fs.Bool("cursor", true, "Draw the mouse pointer into frames")
+	hidpi := fs.String("hidpi", "physical", "Frame pixels per point on Retina displays: physical, logical, or a factor such as 1.5")
+	showClicks := fs.Bool("clicks", false, "Draw a ripple wherever the mouse is clicked (macOS)")
+	followSize := fs.String("follow", "", "Record a WxH-point view that pans to follow the mouse pointer, e.g. 800x600 (macOS)")
+	stabilize := fs.Float64("stabilize", 0.85, "How steadily -follow pans, from 0 (tracks every movement) to just under 1 (glides slowly)")
+	saveCapturePath := fs.String("save-capture", "", "Also save the captured frames losslessly to a .wrec file, to replay with witness encode")
+	heatmapPath := fs.String("heatmap", "", "Also save a PNG heatmap of where the screen changed (and was clicked, with -clicks)")
+	webcamOn := fs.Bool("webcam", false, "Overlay the webcam in a corner of the recording (requires ffmpeg)")
+	webcamDevice := fs.String("webcam-device", "", "Camera for -webcam: an index on macOS, a /dev/video path on Linux, or a name on Windows (default: first camera)")
+	webcamCorner := fs.String("webcam-corner", "bottom-right", "Corner for -webcam: bottom-right, bottom-left, top-right, or top-left")
+	webcamSize := fs.Float64("webcam-size", 0.25, "Width of the -webcam overlay as a fraction of the frame width")
+	windowStr := fs.String("window", "", "Record one window by title, app name, or ID, following it as it moves (macOS)")
+	app := fs.String("app", "", "Record only the windows of the application whose name contains this (macOS 12.3+)")
+	var exclude windowTargetFlags
+	fs.Var(&exclude, "exclude", "Leave windows out of the recording by title, app name, or ID (repeatable; macOS 12.3+)")
+	vncAddr := fs.String("vnc", "", "Record a VNC server instead of this screen, e.g. localhost:5900 for a container")
+	vncPassword := fs.String("vnc-password", "", "Password for -vnc (default $WITNESS_VNC_PASSWORD)")
+	rdpAddr := fs.String("rdp", "", "Record a Windows desktop over Remote Desktop instead of this screen, e.g. winserver or winserver:3389")
+	rdpUser := fs.String("rdp-user", "", "User for -rdp: name, DOMAIN\\name, or name@domain (default $WITNESS_RDP_USER)")
+	rdpPassword := fs.String("rdp-password", "", "Password for -rdp (default $WITNESS_RDP_PASSWORD)")
+	rdpSize := fs.String("rdp-size", "1920x1080", "Desktop size to ask the -rdp server for")
+	rdpCert := fs.String("rdp-cert", "", "SHA-256 fingerprint of the -rdp server's certificate (default: trust the first certificate seen and refuse a different one)")
+	rdpAllowTLSOnly := fs.Bool("rdp-allow-tls-only", false, "Sign in to -rdp servers without Network Level Authentication, which sends the password protected only by TLS")
+	fpsStr := fs.String("f", "15", "Frames per second (e.g. 15, 29.97, 30000/1001)")
+	fs.StringVar(fpsStr, "output-fps", "15", "Alias for -f")
+	captureFPSStr := fs.String("capture-fps", "", "Capture faster than -f and keep the sharpest frame of each group (e.g. 60)")
+	captureScale := fs.Float64("capture-scale", 1, "Shrink frames by this factor as they are captured, e.g. 0.5 for 4K displays (0-1)")
+	quality := fs.String("q", "medium", "Quality level (low, medium, high; see -list-qualities)")
+	listQualities := fs.Bool("list-qualities", false, "Describe the quality levels and exit")
+	palettePath := fs.String("palette", "", "Use the colors from a palette file (.gpl or .hex) instead of the quality preset")
+	colors := fs.Int("colors", 0, "Use an adaptive palette with this many colors (1-256) instead of the quality preset")
+	transparent := fs.String("transparent", "", "Make this color transparent, e.g. #00ff00 or green (chroma key)")
+	transparentTol := fs.Uint("transparent-tolerance", analyze.DefaultTolerance, "Per-channel tolerance for -transparent (0-255)")
+	alpha := fs.Bool("alpha", false, "Make pixels that are mostly transparent in the capture transparent in the GIF")
+	interlace := fs.Bool("interlace", false, "Write interlaced frames so large GIFs render progressively while loading")
+	lossy := fs.Int("lossy", 0, "Allow lossy compression with this color tolerance for smaller files (e.g. 20 subtle, 80 strong)")
+	dither := fs.Bool("dither", true, "Dither colors; -dither=false keeps flat UI and text clean")
+	disposalStr := fs.String("disposal", "auto", "Frame disposal method (auto, none, background, previous)")
+	holdFirst := fs.Duration("hold-first", 0, "Extra time to show the first frame (e.g. 1s)")
+	holdLast := fs.Duration("hold-last", 0, "Extra time to show the last frame (e.g. 2s)")
+	reverse := fs.Bool("reverse", false, "Write frames in reverse order")
+	autoCrop := fs.Bool("auto-crop", false, "Trim static, uniform borders from the output")
+	autoRegion := fs.Bool("auto-region", false, "Crop mostly static recordings to the area that changes")
+	autoLoop := fs.Bool("auto-loop", false, "Keep only the frames that loop seamlessly, when there are some")
+	denoiseOn := fs.Bool("denoise", false, "Suppress pixel flicker between frames for cleaner, smaller output")
+	denoiseTol := fs.Uint("denoise-tolerance", analyze.DefaultTolerance, "Largest per-channel change -denoise treats as noise (0-255)")
+	scaleFactor := fs.Float64("scale", 1, "Resize frames by this factor, e.g. 0.5 to halve Retina captures")
+	scaleMode := fs.String("scale-mode", "smooth", "Resampling for -scale (smooth, text for terminals and code, nearest for whole ratios)")
+	idleSkip := fs.Duration("idle-skip", 0, "Drop frames once the screen has been unchanged this long (e.g. 1s)")
+	presetName := fs.String("preset", "", "Apply a preset (terminal, browser-demo, full-tutorial, or one from config); other flags override it")
+	pinSpace := fs.Bool("pin-space", false, "Pause while a different Space (virtual desktop) is active")
+	pauseWindow := fs.Uint("pause-window", 0, "Pause while the window with this ID is minimized or covered")
+	stopOnLock := fs.Bool("stop-on-lock", true, "Stop and save when the screen locks, the system sleeps, or the user switches")
+	stopFile := fs.String("stop-file", "", "Stop and save once this file exists, removing it (for scripts)")
+	maxDuration := fs.Duration("d", 0, "Stop and save after recording this long (e.g. 30s)")
+	maxFrames := fs.Int("max-frames", 0, "Stop and save after capturing this many frames")
+	startAt := fs.String("at", "", "Start recording at this time of day, e.g. 14:30 or 2:30pm")
+	startAfter := fs.Duration("after", 0, "Start recording after waiting this long (e.g. 10m)")
+	countdown := fs.Duration("countdown", 0, "Announce a scheduled recording this long before it starts, with a notification on macOS (e.g. 10s)")
+	requireConsent := fs.Bool("consent", false, "Show a recording banner that must be clicked through before capture starts")
+	consentMessage := fs.String("consent-message", "", "Custom text for the -consent banner")
+	var annotations annotationFlags
+	fs.Var(&annotations, "annotate", "Overlay an annotation, e.g. box:10,10,200,80 (repeatable)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness gif [options]")
+		fmt.Println("\nRecord screen and save as GIF")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness gif -o demo.gif")
+		fmt.Println("  witness gif -o demo.gif -f 10 -q low")
+		fmt.Println("  witness gif -o demo.gif -d 30s")
+		fmt.Println("  witness gif -o overview.gif -capture-scale 0.5")
+		fmt.Println("  witness gif -o session.gif -clicks -heatmap session-heatmap.png")
+		fmt.Println("  witness gif -o walkthrough.gif -follow 800x600")
+		fmt.Println("  witness gif -o standup.gif -at 9:30 -d 5m -countdown 10s")
+		fmt.Println("  witness gif -region demo -o capture.gif")
+		fmt.Println("  witness gif -r 0,0,800,600 -o capture.gif")
+		fmt.Println("  witness gif -window Safari -o browser.gif")
+		fmt.Println("  witness gif -o demo.gif -exclude 1Password -exclude Slack")
+		fmt.Println("  witness gif -app Figma -o figma.gif")
+		fmt.Println("  witness gif -display 2 -o second-screen.gif")
+		fmt.Println("  witness gif -display \"DELL U2720Q\" -o external.gif")
+		fmt.Println("  witness gif -vnc localhost:5900 -o container.gif")
+		fmt.Println("  witness gif -rdp winserver -rdp-user 'CORP\\alice' -o server.gif")
+		fmt.Println("  witness gif -o demo.gif -hold-first 1s -hold-last 2s")
+		fmt.Println("  witness gif -o demo.gif -palette dracula.gpl")
+		fmt.Println("  witness gif -o demo.gif -colors 32")
+		fmt.Println("  witness gif -o panel.gif -transparent '#00ff00'")
+		fmt.Println("  witness gif -o demo.gif -auto-crop")
+		fmt.Println("  witness gif -o demo.gif -auto-region")
+		fmt.Println("  witness gif -o spinner.gif -auto-loop")
+		fmt.Println("  witness gif -o terminal.gif -scale 0.5 -scale-mode text")
+		fmt.Println("  witness gif -region demo -o demo.gif -hidpi logical  # Same size on any display")
+		fmt.Println("  witness gif -o terminal.gif -preset terminal")
+		fmt.Println("  witness gif -o scroll.gif -capture-fps 60 -output-fps 15")
+		fmt.Println("  witness gif -o demo.gif -annotate 'blur:0,0,300,40' -annotate 'text:20,60,text=Step 1'")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	envDefault(fs, "vnc-password", "WITNESS_VNC_PASSWORD")
+	envDefault(fs, "rdp-user", "WITNESS_RDP_USER")
+	envDefault(fs, "rdp-password", "WITNESS_RDP_PASSWORD")
+
+	if *listQualities {
+		printQualities()
+		return
+	}
+
+	if err := applyPreset(fs, *presetName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if writesToStdout(*output) {
+		status = os.Stderr
+	}
+
+	if *output == "" {
+		fmt.Fprintf(os.Stderr, "Error: -o is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if err := checkConsent(*requireConsent); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fps, err := capture.ParseFPS(*fpsStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	captureFPS, err := parseCaptureFPS(*captureFPSStr, fps)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	q, err := encoder.ParseQuality(*quality)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	region, err := resolveRegion(*regionStr, *regionName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	window, err := resolveWindow(*windowStr, region)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	remote, err := resolveRemoteDesktop(*vncAddr, *vncPassword, *rdpAddr, *rdpSize, capture.RDPOptions{
+		User:         *rdpUser,
+		Password:     *rdpPassword,
+		Fingerprint:  *rdpCert,
+		AllowTLSOnly: *rdpAllowTLSOnly,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if remote.addr != "" && window != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s cannot be combined with -window\n", remote.flag)
+		os.Exit(1)
+	}
+	if (len(exclude) > 0 || *app != "") && (window != nil || remote.addr != "") {
+		fmt.Fprintf(os.Stderr, "Error: -exclude and -app cannot be combined with -window, -vnc, or -rdp\n")
+		os.Exit(1)
+	}
+
+	pal, numColors, err := resolvePalette(*palettePath, *colors, *quality)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	chromaKey, err := parseChromaKey(*transparent, *transparentTol)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *lossy < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -lossy must be 0 or more, got %d\n", *lossy)
+		os.Exit(1)
+	}
+
+	if *denoiseTol > 255 {
+		fmt.Fprintf(os.Stderr, "Error: -denoise-tolerance must be between 0 and 255, got %d\n", *denoiseTol)
+		os.Exit(1)
+	}
+
+	scaling, err := parseScale(*scaleFactor, *scaleMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	hidpiMode, err := capture.ParseScaleMode(*hidpi)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *maxDuration < 0 || *maxFrames < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -d and -max-frames must not be negative\n")
+		os.Exit(1)
+	}
+
+	if !(*captureScale > 0 && *captureScale <= 1) {
+		fmt.Fprintf(os.Stderr, "Error: -capture-scale must be above 0 and at most 1, got %g\n", *captureScale)
+		os.Exit(1)
+	}
+
+	if *heatmapPath != "" && !strings.EqualFold(filepath.Ext(*heatmapPath), ".png") {
+		fmt.Fprintf(os.Stderr, "Error: -heatmap must be a .png file, got %q\n", *heatmapPath)
+		os.Exit(1)
+	}
+
+	start, err := schedule.Start(*startAt, *startAfter, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *idleSkip < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -idle-skip must not be negative, got %v\n", *idleSkip)
+		os.Exit(1)
+	}
+
+	disposal, err := encoder.ParseDisposal(*disposalStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	*output, releaseOutput, err = resolveOutput(*output, *outDir, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	if *requireConsent {
+		confirmConsent(*consentMessage, region, *output)
+	}
+
+	recConfig := recorder.DefaultConfig(capture.Config{Region: region, Window: window, Exclude: exclude, App: *app, FPS: captureFPS, Display: *display, IncludeCursor: *cursor, ScaleMode: hidpiMode, Scale: *captureScale, MaxDuration: *maxDuration, MaxFrames: *maxFrames})
+	recConfig.PauseWhen, recConfig.StopWhen, err = recordingConditions(*pinSpace, *pauseWindow, *stopOnLock && remote.addr == "", *stopFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	waitForStart(start, *countdown)
+
+	clicks, err := watchClicks(*showClicks, region, *display, window, remote.addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	follow, err := followPointer(*followSize, *stabilize, region, *display, window, remote.addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	webcam, err := startWebcam(*webcamOn, *webcamDevice, *webcamCorner, *webcamSize, captureFPS)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	heatmap, err := startHeatmap(*heatmapPath, *force, &clicks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	enc := encoder.NewGIFEncoderFPS(*output, fps, q)
+	enc.SetHoldFirst(*holdFirst)
+	enc.SetHoldLast(*holdLast)
+	enc.SetReverse(*reverse)
+	enc.SetAutoCrop(*autoCrop)
+	enc.SetAutoRegion(*autoRegion)
+	enc.SetAutoLoop(*autoLoop)
+	enc.SetPalette(pal)
+	enc.SetColors(numColors)
+	if chromaKey != nil {
+		enc.SetChromaKey(*chromaKey, uint8(*transparentTol))
+	}
+	enc.SetAlphaTransparency(*alpha)
+	enc.SetInterlace(*interlace)
+	enc.SetLossy(*lossy)
+	enc.SetDither(*dither)
+	enc.SetDisposal(disposal)
+
+	frames, flush := downsample(clicks.wrap(follow.wrap(webcam.wrap(skipIdle(denoise(annotate(rescale(enc, scaling), annotations), *denoiseOn, *denoiseTol), *idleSkip)))), captureFPS, fps)
+	frames, closeCapture, err := saveCapture(*saveCapturePath, captureFPS, frames, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	rec := newRecorder(recConfig, frames, remote)
+	heatmap.watch(rec)
+	recording, err := audit.StartRecording(region, *output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	err = record(rec)
+	clicks.stop()
+	webcam.stop()
+	if flushErr := flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := closeCapture(); err == nil {
+		err = closeErr
+	}
+	if err == nil && enc.FrameCount() == 0 {
+		err = fmt.Errorf("no frames were captured")
+	}
+	if err == nil {
+		fmt.Fprintf(status, "Encoding %d frames...\n", enc.FrameCount())
+		if writesToStdout(*output) {
+			err = enc.EncodeTo(os.Stdout)
+		} else {
+			err = enc.Encode()
+		}
+	}
+	if auditErr := recording.Stop(err); auditErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", auditErr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	if !*autoRegion {
+		if activity := enc.Activity(); activity.MostlyStatic() {
+			suggested := activity.Suggest(enc.Bounds(), analyze.DefaultPadding)
+			if region != nil {
+				suggested = suggested.Add(image.Pt(region.X, region.Y))
+			}
+			warnMostlyStatic(activity, suggested)
+		}
+	}
+	kept := enc.FrameCount()
+	if loop, ok := enc.Loop(); !ok {
+		if *autoLoop {
+			fmt.Fprintf(os.Stderr, "Warning: no frames loop seamlessly; kept all %d\n", kept)
+		}
+	} else if loop.Len() < kept {
+		if *autoLoop {
+			fmt.Fprintf(status, "✓ Kept frames %d-%d, which loop seamlessly\n", loop.First, loop.Last)
+			kept = loop.Len()
+		} else if !writesToStdout(*output) {
+			// Frame numbers are counted in the order they are written
+			if *reverse {
+				loop.First, loop.Last = kept-1-loop.Last, kept-1-loop.First
+			}
+			warnLoop(loop, fmt.Sprintf("witness edit -i %s -o looped.gif -trim %d:%d", *output, loop.First, loop.Last))
+		}
+	}
+	heatmap.save()
+
+	summary := fmt.Sprintf("%d frames, %s", kept, fps.FrameTime(kept).Round(100*time.Millisecond))
+	if info, err := os.Stat(*output); err == nil {
+		summary += ", " + formatBytes(info.Size())
+	}
+	fmt.Fprintf(status, "✓ Saved %s (%s)\n", displayName(*output), summary)
+}
@@ -1,16 +1,17 @@
 package main
 
 import (
-	"flag"
 	"fmt"
+	"io"
 	"os"
-
-	"github.com/ericmhalvorsen/witness/pkg/capture"
-	"github.com/ericmhalvorsen/witness/pkg/selector"
 )
 
 const version = "0.1.0-dev"
 
+// status receives progress messages. It is switched to stderr when the
+// recording itself is written to stdout with -o -.
+var status io.Writer = os.Stdout
+
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
@@ -24,10 +25,50 @@ func main() {
 		handleSelect(os.Args[2:])
 	case "regions":
 		handleRegions(os.Args[2:])
+	case "displays":
+		handleDisplays(os.Args[2:])
 	case "gif":
 		handleGif(os.Args[2:])
 	case "video":
 		handleVideo(os.Args[2:])
+	case "edit":
+		handleEdit(os.Args[2:])
+	case "render":
+		handleRender(os.Args[2:])
+	case "encode":
+		handleEncode(os.Args[2:])
+	case "preview":
+		handlePreview(os.Args[2:])
+	case "ocr":
+		handleOCR(os.Args[2:])
+	case "search":
+		handleSearch(os.Args[2:])
+	case "audit":
+		handleAudit(os.Args[2:])
+	case "prune":
+		handlePrune(os.Args[2:])
+	case "serve":
+		handleServe(os.Args[2:])
+	case "schedule":
+		handleSchedule(os.Args[2:])
+	case "watch":
+		handleWatch(os.Args[2:])
+	case "sync":
+		handleSync(os.Args[2:])
+	case "ctl":
+		handleCtl(os.Args[2:])
+	case "remote":
+		handleRemote(os.Args[2:])
+	case "agent":
+		handleAgent(os.Args[2:])
+	case "calibrate":
+		handleCalibrate(os.Args[2:])
+	case "doctor":
+		handleDoctor(os.Args[2:])
+	case "quick":
+		handleQuick(os.Args[2:])
+	case "run":
+		handleRun(os.Args[2:])
 	case "help", "--help", "-h":
 		printUsage()
 	case "version", "--version", "-v":
@@ -38,193 +79,6 @@ func main() {
 		os.Exit(1)
 	}
 }
-
-func handleSelect(args []string) {
-	fs := flag.NewFlagSet("select", flag.ExitOnError)
-	name := fs.String("name", "", "Save the selected region with a name")
-	setDefault := fs.Bool("default", false, "Set this region as the default")
-
-	fs.Usage = func() {
-		fmt.Println("Usage: witness select [options]")
-		fmt.Println("\nLaunch an interactive region selector")
-		fmt.Println("\nOptions:")
-		fs.PrintDefaults()
-		fmt.Println("\nExamples:")
-		fmt.Println("  witness select                    # Select a region")
-		fmt.Println("  witness select -name demo         # Select and save as 'demo'")
-		fmt.Println("  witness select -name demo -default # Select, save, and set as default")
-	}
-
-	if err := fs.Parse(args); err != nil {
-		os.Exit(1)
-	}
-
-	// Create selector
-	sel, err := selector.NewSelector()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Select region
-	var region *capture.Region
-	if *name != "" {
-		region, err = sel.SelectWithName(*name)
-	} else {
-		region, err = sel.Select()
-	}
-
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Set as default if requested
-	if *setDefault && *name != "" {
-		if err := selector.SetDefaultRegion(*name); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to set default region: %v\n", err)
-		} else {
-			fmt.Printf("✓ Set '%s' as default region\n", *name)
-		}
-	}
-
-	// Print region info
-	if *name == "" {
-		fmt.Println("\nTo use this region in capture:")
-		fmt.Printf("  witness gif -r %s\n", selector.FormatRegionString(region))
-		fmt.Println("\nOr save it for later use:")
-		fmt.Printf("  witness select -name myregion\n")
-	}
-}
-
-func handleRegions(args []string) {
-	fs := flag.NewFlagSet("regions", flag.ExitOnError)
-	delete := fs.String("delete", "", "Delete a saved region")
-	setDefault := fs.String("default", "", "Set a region as default")
-
-	fs.Usage = func() {
-		fmt.Println("Usage: witness regions [options]")
-		fmt.Println("\nManage saved screen regions")
-		fmt.Println("\nOptions:")
-		fs.PrintDefaults()
-		fmt.Println("\nExamples:")
-		fmt.Println("  witness regions                    # List all saved regions")
-		fmt.Println("  witness regions -delete demo       # Delete 'demo' region")
-		fmt.Println("  witness regions -default demo      # Set 'demo' as default")
-	}
-
-	if err := fs.Parse(args); err != nil {
-		os.Exit(1)
-	}
-
-	// Handle delete
-	if *delete != "" {
-		if err := selector.DeleteRegion(*delete); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("✓ Deleted region '%s'\n", *delete)
-		return
-	}
-
-	// Handle set default
-	if *setDefault != "" {
-		if err := selector.SetDefaultRegion(*setDefault); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("✓ Set '%s' as default region\n", *setDefault)
-		return
-	}
-
-	// Handle list (default action)
-	names, err := selector.ListRegions()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
-	if len(names) == 0 {
-		fmt.Println("No saved regions")
-		fmt.Println("\nCreate one with: witness select -name myregion")
-		return
-	}
-
-	fmt.Println("Saved regions:")
-	for _, name := range names {
-		info, err := selector.GetRegionInfo(name)
-		if err != nil {
-			continue
-		}
-		fmt.Printf("  %s\n", info)
-	}
-}
-
-func handleGif(args []string) {
-	fs := flag.NewFlagSet("gif", flag.ExitOnError)
-	output := fs.String("o", "", "Output file path")
-	regionStr := fs.String("r", "", "Capture region (x,y,w,h)")
-	regionName := fs.String("region", "", "Use a saved region by name")
-	fps := fs.Int("f", 15, "Frames per second")
-	quality := fs.String("q", "medium", "Quality level (low, medium, high)")
-
-	fs.Usage = func() {
-		fmt.Println("Usage: witness gif [options]")
-		fmt.Println("\nRecord screen and save as GIF")
-		fmt.Println("\nOptions:")
-		fs.PrintDefaults()
-		fmt.Println("\nExamples:")
-		fmt.Println("  witness gif -o demo.gif")
-		fmt.Println("  witness gif -o demo.gif -f 10 -q low")
-		fmt.Println("  witness gif -region demo -o capture.gif")
-		fmt.Println("  witness gif -r 0,0,800,600 -o capture.gif")
-	}
-
-	if err := fs.Parse(args); err != nil {
-		os.Exit(1)
-	}
-
-	// TODO: Implement GIF recording
-	fmt.Println("GIF recording not yet implemented")
-	fmt.Printf("Output: %s\n", *output)
-	fmt.Printf("Region: %s\n", *regionStr)
-	fmt.Printf("Region name: %s\n", *regionName)
-	fmt.Printf("FPS: %d\n", *fps)
-	fmt.Printf("Quality: %s\n", *quality)
-}
-
-func handleVideo(args []string) {
-	fs := flag.NewFlagSet("video", flag.ExitOnError)
-	output := fs.String("o", "", "Output file path")
-	regionStr := fs.String("r", "", "Capture region (x,y,w,h)")
-	regionName := fs.String("region", "", "Use a saved region by name")
-	fps := fs.Int("f", 30, "Frames per second")
-	quality := fs.String("q", "medium", "Quality level (low, medium, high)")
-
-	fs.Usage = func() {
-		fmt.Println("Usage: witness video [options]")
-		fmt.Println("\nRecord screen and save as MP4")
-		fmt.Println("\nOptions:")
-		fs.PrintDefaults()
-		fmt.Println("\nExamples:")
-		fmt.Println("  witness video -o tutorial.mp4")
-		fmt.Println("  witness video -o tutorial.mp4 -f 30 -q high")
-		fmt.Println("  witness video -region demo -o capture.mp4")
-	}
-
-	if err := fs.Parse(args); err != nil {
-		os.Exit(1)
-	}
-
-	// TODO: Implement video recording
-	fmt.Println("Video recording not yet implemented")
-	fmt.Printf("Output: %s\n", *output)
-	fmt.Printf("Region: %s\n", *regionStr)
-	fmt.Printf("Region name: %s\n", *regionName)
-	fmt.Printf("FPS: %d\n", *fps)
-	fmt.Printf("Quality: %s\n", *quality)
-}
-
 func printUsage() {
 	usage := `Witness - Screen Capture Tool
 Version: ` + version + `
@@ -234,8 +88,27 @@ Usage: witness <command> [options]
 Commands:
   select     Launch interactive region selector
   regions    Manage saved regions
+  displays   List connected displays and their IDs
   gif        Record and save as GIF
   video      Record and save as MP4 (coming soon)
+  edit       Edit an existing GIF recording
+  render     Re-encode a recording with timeline annotations
+  preview    Step through a GIF frame by frame to pick trim points
+  encode     Encode frames from stdin without capturing
+  ocr        Extract the text shown in a recording
+  search     Find when text appeared in recordings
+  audit      Show the log of recording activity
+  prune      Delete old recordings under the retention policy
+  serve      Run a daemon that records on request from witness sync
+  schedule   Record at a set time, once or on a recurring schedule
+  watch      Record whenever an application is frontmost
+  sync       Start recording on several machines at the same moment
+  ctl        Start, stop, or toggle a witness serve recording
+  remote     Record another machine's screen over SSH
+  quick      Start recording with no flags, or stop when run again
+  run        Record scenario files one after another
+  doctor     Check permissions and tools needed for recording
+  calibrate  Check that recordings keep colors accurate on this display
   help       Show this help message
   version    Show version information
 
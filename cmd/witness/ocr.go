@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+	"github.com/ericmhalvorsen/witness/pkg/ocr"
+	"github.com/ericmhalvorsen/witness/pkg/replay"
+)
+
+func handleOCR(args []string) {
+	fs := flag.NewFlagSet("ocr", flag.ExitOnError)
+	output := fs.String("o", "-", "Transcript file (- for stdout)")
+	every := fs.Duration("every", time.Second, "Read the screen once per this much recording time (0 reads every frame)")
+	language := fs.String("lang", "eng", "Tesseract language, e.g. eng or eng+deu")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists instead of picking a new name")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness ocr [options] <recording.gif|recording.wrec>")
+		fmt.Println("\nRead the text on screen at intervals through a recording, giving a searchable")
+		fmt.Println("log of what was shown. Each time the text changes, it is written with its time")
+		fmt.Println("in the recording. Requires tesseract.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness ocr demo.gif -every 1s -o transcript.txt")
+		fmt.Println("  witness ocr incident.wrec -every 5s | grep -i error")
+	}
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		os.Exit(1)
+	}
+	if len(paths) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *every < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -every must not be negative, got %v\n", *every)
+		os.Exit(1)
+	}
+	if writesToStdout(*output) {
+		status = os.Stderr
+	}
+
+	engine, err := ocr.NewTesseract(*language)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(status, "Reading text from %s...\n", paths[0])
+	entries, err := transcribe(paths[0], *every, engine)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if writesToStdout(*output) {
+		err = ocr.WriteTranscript(os.Stdout, entries)
+	} else {
+		*output, err = protectOutput(*output, *force)
+		if err == nil {
+			var f *os.File
+			if f, err = os.Create(*output); err == nil {
+				err = ocr.WriteTranscript(f, entries)
+				if closeErr := f.Close(); err == nil {
+					err = closeErr
+				}
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(status, "✓ Wrote %d text changes to %s\n", len(entries), displayName(*output))
+}
+
+// transcribe reads the text on screen through a GIF or .wrec recording
+func transcribe(path string, every time.Duration, engine ocr.Recognizer) ([]ocr.Entry, error) {
+	if strings.HasSuffix(path, replay.Extension) {
+		saved, err := replay.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer saved.Close()
+		return ocr.Transcribe(saved, every, engine)
+	}
+
+	clip, err := editor.LoadGIF(path)
+	if err != nil {
+		return nil, err
+	}
+	return ocr.Transcribe(clip.Reader(), every, engine)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/config"
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// printQualities describes what each -q level trades off
+func printQualities() {
+	fmt.Println("Quality levels (-q):")
+	for _, info := range encoder.Qualities() {
+		fmt.Printf("  %-8s GIF: %s\n", info.Name, info.GIF)
+		fmt.Printf("  %-8s MP4: %s\n", "", info.Video)
+	}
+	fmt.Println("\nThe palettes entry in ~/.config/witness/config.json can change a level's GIF palette.")
+}
+
+// envDefault sets the flag name from the environment variable env when
+// it was not given on the command line. Secrets are read this way instead
+// of being flag defaults, which -h would print.
+func envDefault(fs *flag.FlagSet, name, env string) {
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	if value, ok := os.LookupEnv(env); ok && !set {
+		fs.Set(name, value)
+	}
+}
+
+// parseCaptureFPS parses -capture-fps, which defaults to the output rate
+// and may not be lower than it
+func parseCaptureFPS(s string, output capture.FPS) (capture.FPS, error) {
+	if s == "" {
+		return output, nil
+	}
+	fps, err := capture.ParseFPS(s)
+	if err != nil {
+		return capture.FPS{}, fmt.Errorf("invalid -capture-fps: %w", err)
+	}
+	if fps.Num*output.Den < output.Num*fps.Den {
+		return capture.FPS{}, fmt.Errorf("-capture-fps %s is lower than the output frame rate %s", fps, output)
+	}
+	return fps, nil
+}
+
+// downsample wraps sink so frames captured at captureFPS are reduced to
+// outputFPS, keeping the sharpest of each group. The returned flush
+// delivers the last group and must be called once recording stops.
+func downsample(sink recorder.FrameSink, captureFPS, outputFPS capture.FPS) (recorder.FrameSink, func() error) {
+	if captureFPS == outputFPS {
+		return sink, func() error { return nil }
+	}
+	d := editor.NewDownsample(sink, outputFPS)
+	return d, d.Flush
+}
+
+// skipIdle wraps sink with an idle frame filter when maxIdle is set
+func skipIdle(sink recorder.FrameSink, maxIdle time.Duration) recorder.FrameSink {
+	if maxIdle == 0 {
+		return sink
+	}
+	return editor.NewIdleSkip(sink, maxIdle)
+}
+
+// applyPreset fills in flags from the named preset. Flags given on the
+// command line take precedence, and preset settings the command has no
+// flag for are ignored.
+func applyPreset(fs *flag.FlagSet, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	settings, err := config.Load()
+	if err != nil {
+		return err
+	}
+	p, err := settings.Preset(name)
+	if err != nil {
+		return err
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	// -fps and -output-fps are aliases for -f, and -palette and -colors
+	// exclude each other
+	set["f"] = set["f"] || set["fps"] || set["output-fps"]
+	if set["palette"] || set["colors"] {
+		set["palette"], set["colors"] = true, true
+	}
+
+	values := map[string]string{
+		"f":          p.FPS,
+		"q":          p.Quality,
+		"palette":    p.Palette,
+		"scale-mode": p.ScaleMode,
+		"hidpi":      p.HiDPI,
+		"idle-skip":  p.IdleSkip,
+	}
+	if p.Colors != 0 {
+		values["colors"] = strconv.Itoa(p.Colors)
+	}
+	if p.Dither != nil {
+		values["dither"] = strconv.FormatBool(*p.Dither)
+	}
+	if p.Scale != 0 {
+		values["scale"] = strconv.FormatFloat(p.Scale, 'g', -1, 64)
+	}
+
+	for flagName, value := range values {
+		if value == "" || set[flagName] || fs.Lookup(flagName) == nil {
+			continue
+		}
+		if err := fs.Set(flagName, value); err != nil {
+			return fmt.Errorf("preset %s: invalid %s: %w", name, flagName, err)
+		}
+	}
+	return nil
+}
+
+// parseScale validates the -scale and -scale-mode flags
+func parseScale(factor float64, mode string) (editor.Scale, error) {
+	m, err := editor.ParseScaleMode(mode)
+	if err != nil {
+		return editor.Scale{}, err
+	}
+	scale := editor.Scale{Factor: factor, Mode: m}
+	if err := scale.Validate(); err != nil {
+		return editor.Scale{}, err
+	}
+	return scale, nil
+}
+
+// rescale wraps sink with a scaler unless frames keep their size
+func rescale(sink recorder.FrameSink, scale editor.Scale) recorder.FrameSink {
+	if scale.Factor == 1 {
+		return sink
+	}
+	return editor.NewScaler(sink, scale)
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments, as in "witness encode frames/*.png -o out.gif", and returns
+// the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// annotationFlags collects repeated -annotate flags
+type annotationFlags []editor.Annotation
+
+func (a *annotationFlags) String() string {
+	return fmt.Sprintf("%d annotations", len(*a))
+}
+
+func (a *annotationFlags) Set(spec string) error {
+	annotation, err := editor.ParseAnnotation(spec)
+	if err != nil {
+		return err
+	}
+	*a = append(*a, annotation)
+	return nil
+}
+
+// windowTargetFlags collects repeated -exclude flags
+type windowTargetFlags []capture.WindowTarget
+
+func (w *windowTargetFlags) String() string {
+	return fmt.Sprintf("%d windows", len(*w))
+}
+
+func (w *windowTargetFlags) Set(s string) error {
+	target, err := capture.ParseWindowTarget(s)
+	if err != nil {
+		return err
+	}
+	*w = append(*w, *target)
+	return nil
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+	"github.com/ericmhalvorsen/witness/pkg/source"
+)
+
+// clickRipples watches mouse clicks for -clicks and draws them onto frames
+// showing area, in global points. The zero value does nothing.
+type clickRipples struct {
+	watcher capture.ClickWatcher
+	area    capture.Region
+	clicks  <-chan capture.Click // The ripples' copy of the clicks once teed
+}
+
+// watchClicks starts watching mouse clicks when enabled. Frames show region,
+// or the whole display when region is nil.
+func watchClicks(enabled bool, region *capture.Region, display string, window *capture.WindowTarget, remoteAddr string) (clickRipples, error) {
+	if !enabled {
+		return clickRipples{}, nil
+	}
+	if window != nil || remoteAddr != "" {
+		return clickRipples{}, fmt.Errorf("-clicks cannot be combined with -window, -vnc, or -rdp")
+	}
+
+	area, err := displayArea(region, display)
+	if err != nil {
+		return clickRipples{}, err
+	}
+	watcher, err := capture.WatchClicks()
+	if err != nil {
+		return clickRipples{}, err
+	}
+	return clickRipples{watcher: watcher, area: area}, nil
+}
+
+// pointerFollow crops frames showing area to a view that follows the mouse
+// pointer for -follow. The zero value does nothing.
+type pointerFollow struct {
+	size      image.Point
+	area      capture.Region
+	smoothing float64
+}
+
+// followPointer prepares -follow when size is set. Frames show region, or
+// the whole display when region is nil.
+func followPointer(size string, smoothing float64, region *capture.Region, display string, window *capture.WindowTarget, remoteAddr string) (pointerFollow, error) {
+	if size == "" {
+		return pointerFollow{}, nil
+	}
+	if window != nil || remoteAddr != "" {
+		return pointerFollow{}, fmt.Errorf("-follow cannot be combined with -window, -vnc, or -rdp")
+	}
+	if smoothing < 0 || smoothing >= 1 {
+		return pointerFollow{}, fmt.Errorf("-stabilize must be at least 0 and below 1, got %g", smoothing)
+	}
+
+	w, h, err := source.ParseSize(size)
+	if err != nil {
+		return pointerFollow{}, fmt.Errorf("invalid -follow: %w", err)
+	}
+	area, err := displayArea(region, display)
+	if err != nil {
+		return pointerFollow{}, err
+	}
+	// Fail now rather than record a view stuck in the middle
+	if _, err := capture.PointerPosition(); err != nil {
+		return pointerFollow{}, err
+	}
+	return pointerFollow{size: image.Pt(w, h), area: area, smoothing: smoothing}, nil
+}
+
+// wrap wraps sink with the following view when -follow is set
+func (f pointerFollow) wrap(sink recorder.FrameSink) recorder.FrameSink {
+	if f.size == (image.Point{}) {
+		return sink
+	}
+	follow := editor.NewFollow(sink, capture.PointerPosition, f.area, f.size)
+	follow.Smoothing = f.smoothing
+	return follow
+}
+
+// displayArea returns region, or the bounds of the display being recorded
+func displayArea(region *capture.Region, display string) (capture.Region, error) {
+	if region != nil {
+		return *region, nil
+	}
+
+	displays, err := capture.ListDisplays()
+	if err != nil {
+		return capture.Region{}, err
+	}
+	d, ok := capture.MatchDisplay(displays, display)
+	if !ok {
+		return capture.Region{}, fmt.Errorf("display %q not found (see witness displays)", display)
+	}
+	return d.Bounds, nil
+}
+
+// wrap wraps sink with click ripples when clicks are being watched
+func (c clickRipples) wrap(sink recorder.FrameSink) recorder.FrameSink {
+	if c.watcher == nil {
+		return sink
+	}
+	clicks := c.clicks
+	if clicks == nil {
+		clicks = c.watcher.Clicks()
+	}
+	return editor.NewClickRipples(sink, clicks, c.area)
+}
+
+// tee returns a copy of the clicks for another consumer, or nil when clicks
+// aren't being watched. Call it before wrap. Clicks are dropped rather than
+// block either consumer.
+func (c *clickRipples) tee() <-chan capture.Click {
+	if c.watcher == nil {
+		return nil
+	}
+	ripples := make(chan capture.Click, 64)
+	other := make(chan capture.Click, 64)
+	go func() {
+		defer close(ripples)
+		defer close(other)
+		for click := range c.watcher.Clicks() {
+			for _, ch := range []chan capture.Click{ripples, other} {
+				select {
+				case ch <- click:
+				default:
+				}
+			}
+		}
+	}()
+	c.clicks = ripples
+	return other
+}
+
+// stop stops watching clicks once recording has ended
+func (c clickRipples) stop() {
+	if c.watcher != nil {
+		c.watcher.Stop()
+	}
+}
+
+// heatmapSampleInterval is how often -heatmap compares frames. Sampling
+// keeps the comparison off the recording path at high frame rates.
+const heatmapSampleInterval = 200 * time.Millisecond
+
+// activityHeatmap builds the -heatmap image from frames sampled while
+// recording, and from clicks with -clicks. The zero value does nothing.
+type activityHeatmap struct {
+	path   string
+	heat   *analyze.Heatmap
+	clicks <-chan capture.Click // Copied from -clicks; nil without it
+	area   capture.Region       // What the frames show, in global points
+	done   chan struct{}        // Closed once the recording's samples are used up
+}
+
+// startHeatmap prepares a heatmap when path is set, copying the clicks
+// being watched. Call it before clicks.wrap, and watch once the recorder
+// exists.
+func startHeatmap(path string, force bool, clicks *clickRipples) (activityHeatmap, error) {
+	if path == "" {
+		return activityHeatmap{}, nil
+	}
+	path, err := protectOutput(path, force)
+	if err != nil {
+		return activityHeatmap{}, err
+	}
+	return activityHeatmap{
+		path:   path,
+		heat:   analyze.NewHeatmap(),
+		clicks: clicks.tee(),
+		area:   clicks.area,
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// watch adds frames sampled from rec, and the clicks, to the heatmap until
+// the recording ends. Call it before rec starts.
+func (h activityHeatmap) watch(rec *recorder.Recorder) {
+	if h.heat == nil {
+		return
+	}
+	samples := rec.Sample(heatmapSampleInterval)
+	clicks := h.clicks
+	go func() {
+		defer close(h.done)
+		for {
+			select {
+			case frame, ok := <-samples:
+				if !ok {
+					return
+				}
+				h.heat.AddFrame(frame.Image)
+			case click, ok := <-clicks:
+				if !ok {
+					clicks = nil
+					continue
+				}
+				// Clicks are in global points; frames may be in pixels
+				b := h.heat.Bounds()
+				if h.area.Width <= 0 || h.area.Height <= 0 {
+					continue
+				}
+				h.heat.AddClick(image.Pt(
+					(click.X-h.area.X)*b.Dx()/h.area.Width,
+					(click.Y-h.area.Y)*b.Dy()/h.area.Height,
+				))
+			}
+		}
+	}()
+}
+
+// save writes the heatmap once the recording has finished, warning rather
+// than failing since the recording itself was saved
+func (h activityHeatmap) save() {
+	if h.heat == nil {
+		return
+	}
+	<-h.done
+
+	img := h.heat.Image()
+	if img == nil {
+		fmt.Fprintln(os.Stderr, "Warning: no frames for the heatmap")
+		return
+	}
+	f, err := os.Create(h.path)
+	if err == nil {
+		err = png.Encode(f, img)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save heatmap: %v\n", err)
+		return
+	}
+	fmt.Fprintf(status, "✓ Saved heatmap %s\n", displayName(h.path))
+}
+
+// webcamOverlay runs the camera for -webcam and draws it in a corner of
+// frames. The zero value does nothing.
+type webcamOverlay struct {
+	camera *capture.WebcamCapturer
+	corner editor.Corner
+	size   float64
+}
+
+// startWebcam starts the camera when enabled
+func startWebcam(enabled bool, device, corner string, size float64, fps capture.FPS) (webcamOverlay, error) {
+	if !enabled {
+		return webcamOverlay{}, nil
+	}
+
+	c, err := editor.ParseCorner(corner)
+	if err != nil {
+		return webcamOverlay{}, err
+	}
+	if size <= 0 || size > 1 {
+		return webcamOverlay{}, fmt.Errorf("-webcam-size must be between 0 and 1, got %g", size)
+	}
+
+	camera, err := capture.NewWebcamCapturer(capture.WebcamConfig{Device: device, Width: 640, Height: 480, FPS: fps})
+	if err != nil {
+		return webcamOverlay{}, err
+	}
+	if err := camera.Start(); err != nil {
+		return webcamOverlay{}, err
+	}
+	return webcamOverlay{camera: camera, corner: c, size: size}, nil
+}
+
+// wrap wraps sink with the webcam overlay when the camera is running
+func (w webcamOverlay) wrap(sink recorder.FrameSink) recorder.FrameSink {
+	if w.camera == nil {
+		return sink
+	}
+	pip := editor.NewPictureInPicture(sink, w.camera.Frames(), w.corner)
+	pip.Size = w.size
+	return pip
+}
+
+// stop stops the camera once recording has ended, warning if it failed
+// part way through
+func (w webcamOverlay) stop() {
+	if w.camera == nil {
+		return
+	}
+	select {
+	case err, ok := <-w.camera.Errors():
+		if ok {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	default:
+	}
+	w.camera.Stop()
+}
+
+// annotate wraps sink in an overlay when there are annotations to draw
+func annotate(sink recorder.FrameSink, annotations []editor.Annotation) recorder.FrameSink {
+	if len(annotations) == 0 {
+		return sink
+	}
+	return editor.NewOverlay(sink, annotations)
+}
+
+// denoise wraps sink with a temporal denoise filter when enabled
+func denoise(sink recorder.FrameSink, enabled bool, tolerance uint) recorder.FrameSink {
+	if !enabled {
+		return sink
+	}
+	return editor.NewDenoise(sink, uint8(tolerance))
+}
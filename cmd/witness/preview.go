@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+	"github.com/ericmhalvorsen/witness/pkg/preview"
+)
+
+func handlePreview(args []string) {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	listen := fs.String("listen", preview.DefaultAddr, "Address to serve the preview on")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness preview [options] <recording.gif>")
+		fmt.Println("\nServe a local page for stepping through a GIF frame by frame. Pick the first")
+		fmt.Println("and last frames to keep, and the page shows the witness edit -trim command that")
+		fmt.Println("cuts the recording to them. Press Ctrl+C to stop.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness preview demo.gif")
+		fmt.Println("  witness preview demo.gif -listen 127.0.0.1:8080")
+	}
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		os.Exit(1)
+	}
+	if len(paths) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	clip, err := editor.LoadGIF(paths[0])
+	if err == nil && clip.Len() == 0 {
+		err = fmt.Errorf("%s has no frames", paths[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(status, "Previewing %d frames of %s at http://%s/\n", clip.Len(), paths[0], *listen)
+	if err := http.ListenAndServe(*listen, preview.NewServer(clip, paths[0])); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
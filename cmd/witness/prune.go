@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/config"
+	"github.com/ericmhalvorsen/witness/pkg/output"
+)
+
+func handlePrune(args []string) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "List the recordings that would be deleted without deleting them")
+	maxSize := fs.String("max-size", "", "Keep at most this much, e.g. 10GB (default: retention max_size from config)")
+	maxAge := fs.String("max-age", "", "Delete recordings older than this, e.g. 30d or 2w (default: retention max_age from config)")
+	outDir := fs.String("out-dir", "", "Recordings directory (default: output_dir from config)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness prune [options]")
+		fmt.Println("\nDelete the oldest recordings in the recordings directory until it is within the")
+		fmt.Println("retention policy. Only recordings witness wrote there are deleted; other files,")
+		fmt.Println("and recordings saved elsewhere with -o, are left alone. Set \"auto\": true in the")
+		fmt.Println("retention policy to also prune whenever witness writes a new recording there.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness prune -dry-run                  # Preview what the configured policy deletes")
+		fmt.Println("  witness prune -max-age 30d -max-size 10GB")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	settings, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	retention := config.Retention{MaxSize: *maxSize, MaxAge: *maxAge}
+	if settings.Retention != nil {
+		if retention.MaxSize == "" {
+			retention.MaxSize = settings.Retention.MaxSize
+		}
+		if retention.MaxAge == "" {
+			retention.MaxAge = settings.Retention.MaxAge
+		}
+	}
+	if *outDir == "" {
+		*outDir = settings.OutputDir
+	}
+
+	policy, root, err := retentionPolicy(&retention, *outDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !policy.Limited() {
+		fmt.Fprintln(os.Stderr, "Error: no retention policy; set retention in the config file or pass -max-size or -max-age")
+		os.Exit(1)
+	}
+
+	recordings, err := output.ListRecordings(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	now := time.Now()
+	expired := policy.Expired(recordings, now)
+	if len(expired) == 0 {
+		fmt.Printf("Nothing to prune: %d recordings (%s) in %s are within the policy\n", len(recordings), formatBytes(totalSize(recordings)), root)
+		return
+	}
+
+	action := "Deleted"
+	if *dryRun {
+		action = "Would delete"
+	} else if expired, err = policy.Prune(root, now); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	for _, rec := range expired {
+		name, relErr := filepath.Rel(root, rec.Path)
+		if relErr != nil {
+			name = rec.Path
+		}
+		fmt.Printf("  %s %s (%s, %s old)\n", action, name, formatBytes(rec.Size), formatAge(now.Sub(rec.ModTime)))
+	}
+
+	kept := len(recordings) - len(expired)
+	fmt.Printf("%s %d recordings (%s) in %s; %d kept (%s)\n", action, len(expired), formatBytes(totalSize(expired)), root,
+		kept, formatBytes(totalSize(recordings)-totalSize(expired)))
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// formatAge describes how old a recording is, in days, hours, or minutes
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/config"
+	"github.com/ericmhalvorsen/witness/pkg/output"
+	"github.com/ericmhalvorsen/witness/pkg/quick"
+	"github.com/ericmhalvorsen/witness/pkg/selector"
+)
+
+// Timeouts for witness quick
+const (
+	// quickStartTimeout is how long to watch a new recording for early failure
+	quickStartTimeout = 3 * time.Second
+
+	// quickAckTimeout is how long a running recording has to acknowledge a
+	// stop request before it is assumed to have exited
+	quickAckTimeout = 3 * time.Second
+
+	// quickSaveTimeout is how long to wait for a stopped recording to be saved
+	quickSaveTimeout = time.Minute
+)
+
+func handleQuick(args []string) {
+	fs := flag.NewFlagSet("quick", flag.ExitOnError)
+	run := fs.String("run", "", "Record to this file in the foreground (used internally)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness quick")
+		fmt.Println("\nStart recording a GIF in the background with no flags, or stop and save the")
+		fmt.Println("running one. Records the default region (see witness select -default), or the")
+		fmt.Println("whole screen without one, using quick_preset from the config file. Bind it to")
+		fmt.Println("a Raycast script command, an Alfred workflow, or a keyboard shortcut.")
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness quick    # Start recording")
+		fmt.Println("  witness quick    # Stop and save")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	dir, err := config.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *run != "" {
+		os.Exit(runQuick(dir, *run))
+	}
+
+	state, err := quick.Load(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if state != nil {
+		err := quick.RequestStop(dir, quickAckTimeout)
+		if err == nil {
+			fmt.Printf("■ Stopping %s...\n", state.Output)
+			if !quick.WaitCleared(dir, quickSaveTimeout) {
+				fmt.Printf("Still saving %s; see %s\n", state.Output, quick.LogPath(dir))
+				return
+			}
+			fmt.Printf("✓ Saved %s\n", state.Output)
+			return
+		}
+		if !errors.Is(err, quick.ErrNoResponse) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: the last quick recording, %s, is no longer running; starting a new one\n", state.Output)
+		if err := quick.Clear(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// The output is resolved here, so the path printed is the one saved
+	name := "witness-" + time.Now().Format("2006-01-02-150405") + ".gif"
+	path, release, err := resolveOutput(name, "", false)
+	if err == nil {
+		path, err = filepath.Abs(path)
+	}
+	if err == nil {
+		err = startQuick(dir, path)
+	}
+	if err != nil {
+		if release != nil {
+			release()
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("● Recording %s; run witness quick again to stop\n", path)
+}
+
+// startQuick runs witness quick -run in the background, so launchers get
+// control back at once, and waits briefly in case it fails to start. The
+// state is saved first, so a witness quick run meanwhile stops this
+// recording rather than starting another.
+func startQuick(dir, path string) (err error) {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the witness binary: %w", err)
+	}
+	if err := quick.Save(dir, quick.State{Output: path, Started: time.Now()}); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			quick.Clear(dir)
+		}
+	}()
+
+	logFile, err := os.Create(quick.LogPath(dir))
+	if err != nil {
+		return fmt.Errorf("failed to create log: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(self, "quick", "-run", path)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachQuick(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start recording: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case <-exited:
+		msg, _ := os.ReadFile(quick.LogPath(dir))
+		return fmt.Errorf("recording stopped right away:\n%s", strings.TrimSpace(string(msg)))
+	case <-time.After(quickStartTimeout):
+		return nil
+	}
+}
+
+// runQuick records to path, which startQuick reserved, with witness gif
+// until a stop is requested, clearing the quick state however the
+// recording ends, and returns its exit code
+func runQuick(dir, path string) int {
+	defer quick.Clear(dir)
+	defer output.Release(path)
+
+	args := []string{"gif", "-o", path, "-force", "-stop-file", quick.StopPath(dir)}
+
+	region, err := selector.GetDefaultRegion()
+	switch {
+	case err == nil:
+		args = append(args, "-r", fmt.Sprintf("%d,%d,%d,%d", region.X, region.Y, region.Width, region.Height))
+	case !errors.Is(err, selector.ErrNoDefault):
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	settings, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if settings.QuickPreset != "" {
+		args = append(args, "-preset", settings.QuickPreset)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to find the witness binary: %v\n", err)
+		return 1
+	}
+
+	cmd := exec.Command(self, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
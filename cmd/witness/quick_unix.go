@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachQuick starts cmd in its own session, so the recording outlives the
+// terminal or launcher that ran witness quick and doesn't get its hangup
+func detachQuick(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
+	"github.com/ericmhalvorsen/witness/pkg/audit"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/config"
+	"github.com/ericmhalvorsen/witness/pkg/consent"
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/output"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+	"github.com/ericmhalvorsen/witness/pkg/schedule"
+	"github.com/ericmhalvorsen/witness/pkg/selector"
+	"github.com/ericmhalvorsen/witness/pkg/source"
+)
+
+func resolveRegion(regionStr, regionName string) (*capture.Region, error) {
+	switch {
+	case regionStr != "":
+		return selector.ParseRegionString(regionStr)
+	case regionName != "":
+		return selector.LoadRegion(regionName)
+	default:
+		return nil, nil
+	}
+}
+
+// resolveWindow parses a -window target, which replaces the capture region
+func resolveWindow(windowStr string, region *capture.Region) (*capture.WindowTarget, error) {
+	if windowStr == "" {
+		return nil, nil
+	}
+	if region != nil {
+		return nil, fmt.Errorf("-window cannot be combined with -r or -region")
+	}
+	return capture.ParseWindowTarget(windowStr)
+}
+
+// resolveOutput places a bare output file name in outDir, or in the
+// configured output_dir when outDir is empty, and protects existing files
+// unless force is set. The name is reserved so that recordings started at
+// the same time don't write the same file; release removes the reservation
+// if the recording fails before writing it.
+func resolveOutput(name, outDir string, force bool) (path string, release func(), err error) {
+	if name == "" {
+		return "", func() {}, nil
+	}
+
+	if outDir == "" {
+		settings, err := config.Load()
+		if err != nil {
+			return "", nil, err
+		}
+		outDir = settings.OutputDir
+	}
+
+	path, err = output.Resolve(name, outDir, time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	inOutDir := path != name
+	if inOutDir {
+		autoPrune(outDir)
+	}
+
+	release = func() {}
+	if !force {
+		reserved, err := output.Reserve(path)
+		if err != nil {
+			return "", nil, err
+		}
+		noteRenamed(path, reserved)
+		path = reserved
+		release = func() { output.Release(reserved) }
+	}
+	if inOutDir {
+		trackRecording(outDir, path)
+	}
+	return path, release, nil
+}
+
+// releaseOutput releases the output a recording command reserved with
+// resolveOutput
+var releaseOutput func()
+
+// exit ends a recording command with code, first releasing its output if
+// the recording never wrote it
+func exit(code int) {
+	if releaseOutput != nil {
+		releaseOutput()
+	}
+	os.Exit(code)
+}
+
+// trackRecording adds a recording witness writes in the recordings
+// directory to its manifest, the only recordings retention deletes. A
+// directory too broad to prune is not tracked.
+func trackRecording(outDir, path string) {
+	root, err := output.Root(outDir)
+	if err != nil {
+		return
+	}
+	if err := output.Track(root, path); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// autoPrune applies the retention policy from the config file to the
+// recordings directory before a new recording is written there, when the
+// policy opts in with auto. Problems are only warnings, so they never stop
+// a recording.
+func autoPrune(outDir string) {
+	settings, err := config.Load()
+	if err != nil || settings.Retention == nil || !settings.Retention.Auto {
+		return
+	}
+	policy, root, err := retentionPolicy(settings.Retention, outDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: retention policy not applied: %v\n", err)
+		return
+	}
+
+	deleted, err := policy.Prune(root, time.Now())
+	if len(deleted) > 0 {
+		fmt.Fprintf(status, "Pruned %d old recordings (%s) under the retention policy\n", len(deleted), formatBytes(totalSize(deleted)))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// retentionPolicy parses a configured retention policy for the recordings
+// directory template outDir, returning the directory it applies to
+func retentionPolicy(retention *config.Retention, outDir string) (output.Retention, string, error) {
+	policy, err := output.ParseRetention(retention.MaxSize, retention.MaxAge)
+	if err != nil {
+		return output.Retention{}, "", err
+	}
+	if outDir == "" {
+		return output.Retention{}, "", fmt.Errorf("retention needs output_dir set in the config file")
+	}
+	root, err := output.Root(outDir)
+	if err != nil {
+		return output.Retention{}, "", err
+	}
+	return policy, root, nil
+}
+
+// totalSize returns the combined size of recordings
+func totalSize(recordings []output.Recording) int64 {
+	var n int64
+	for _, rec := range recordings {
+		n += rec.Size
+	}
+	return n
+}
+
+// recordingConditions builds the pause and stop conditions selected by the
+// -pin-space, -pause-window, -stop-on-lock, and -stop-file flags
+func recordingConditions(pinSpace bool, pauseWindow uint, stopOnLock bool, stopFile string) ([]recorder.PauseCondition, []recorder.StopCondition, error) {
+	var pauseWhen []recorder.PauseCondition
+	var stopWhen []recorder.StopCondition
+
+	if pinSpace {
+		space, err := recorder.NewSpaceCondition()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get the active Space: %w", err)
+		}
+		pauseWhen = append(pauseWhen, space)
+	}
+	if pauseWindow != 0 {
+		pauseWhen = append(pauseWhen, recorder.NewWindowCondition(uint32(pauseWindow)))
+	}
+	if stopOnLock {
+		stopWhen = append(stopWhen, recorder.NewSessionCondition())
+	}
+	if stopFile != "" {
+		stopWhen = append(stopWhen, &recorder.FileCondition{Path: stopFile})
+	}
+
+	return pauseWhen, stopWhen, nil
+}
+
+// startPollInterval is how often waitForStart checks the clock. Polling the
+// wall clock, rather than sleeping until the start time, keeps the schedule
+// after the system sleeps.
+const startPollInterval = 200 * time.Millisecond
+
+// waitForStart waits for a recording scheduled with -at or -after to start,
+// announcing it countdown beforehand. Ctrl+C cancels it and exits.
+func waitForStart(start time.Time, countdown time.Duration) {
+	if !time.Now().Before(start) {
+		return
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	fmt.Fprintf(status, "⏱ Recording starts at %s (in %s)... press Ctrl+C to cancel\n",
+		start.Format("15:04:05"), time.Until(start).Round(time.Second))
+
+	ticker := time.NewTicker(startPollInterval)
+	defer ticker.Stop()
+	announced := countdown <= 0
+	for {
+		remaining := start.Sub(time.Now().Round(0))
+		if remaining <= 0 {
+			return
+		}
+		if !announced && remaining <= countdown {
+			announced = true
+			fmt.Fprintf(status, "Recording starts in %s\n", remaining.Round(time.Second))
+			if runtime.GOOS == "darwin" {
+				if err := schedule.NewNotifier().Countdown(remaining); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				}
+			}
+		}
+
+		select {
+		case <-interrupt:
+			fmt.Fprintln(status, "Scheduled recording cancelled")
+			exit(1)
+		case <-ticker.C:
+		}
+	}
+}
+
+// remoteDesktop is the -vnc or -rdp server to record instead of this
+// machine's screen. The zero value records this machine's screen.
+type remoteDesktop struct {
+	flag     string // -vnc or -rdp, for error messages
+	addr     string
+	password string // VNC password
+	rdp      capture.RDPOptions
+}
+
+// resolveRemoteDesktop picks the server to record from the -vnc and -rdp
+// flags. rdp holds the -rdp account and certificate flags; without
+// -rdp-cert, certificates are trusted on first use and recorded in
+// known_rdp_hosts in the config directory.
+func resolveRemoteDesktop(vncAddr, vncPassword, rdpAddr, rdpSize string, rdp capture.RDPOptions) (remoteDesktop, error) {
+	switch {
+	case vncAddr != "" && rdpAddr != "":
+		return remoteDesktop{}, errors.New("-vnc cannot be combined with -rdp")
+	case vncAddr != "":
+		return remoteDesktop{flag: "-vnc", addr: vncAddr, password: vncPassword}, nil
+	case rdpAddr == "":
+		return remoteDesktop{}, nil
+	}
+
+	if rdp.User == "" {
+		return remoteDesktop{}, errors.New("-rdp needs a user name: set -rdp-user or $WITNESS_RDP_USER")
+	}
+	width, height, err := source.ParseSize(rdpSize)
+	if err != nil {
+		return remoteDesktop{}, fmt.Errorf("invalid -rdp-size: %w", err)
+	}
+	if width < 200 || height < 200 || width > 8192 || height > 8192 {
+		return remoteDesktop{}, fmt.Errorf("-rdp-size must be between 200x200 and 8192x8192, got %s", rdpSize)
+	}
+	rdp.Width, rdp.Height = width, height
+
+	if rdp.Fingerprint != "" {
+		if rdp.Fingerprint, err = capture.ParseRDPFingerprint(rdp.Fingerprint); err != nil {
+			return remoteDesktop{}, fmt.Errorf("invalid -rdp-cert: %w", err)
+		}
+	} else {
+		dir, err := config.Dir()
+		if err != nil {
+			return remoteDesktop{}, err
+		}
+		rdp.KnownHosts = filepath.Join(dir, "known_rdp_hosts")
+		rdp.Trusted = func(addr, fingerprint string) {
+			fmt.Fprintf(os.Stderr, "Trusting %s on first use. Its certificate fingerprint is\n  %s\n", addr, fingerprint)
+			fmt.Fprintf(os.Stderr, "Witness will refuse a different certificate from it (see %s).\n", rdp.KnownHosts)
+		}
+	}
+	return remoteDesktop{flag: "-rdp", addr: rdpAddr, rdp: rdp}, nil
+}
+
+// newRecorder records this machine's screen, or the remote desktop when
+// one is given
+func newRecorder(config recorder.Config, sink recorder.FrameSink, remote remoteDesktop) *recorder.Recorder {
+	switch remote.flag {
+	case "-vnc":
+		return recorder.NewRecorderWithFactory(config, sink, func(c capture.Config) (capture.Capturer, error) {
+			return capture.NewVNCCapturer(remote.addr, remote.password, c), nil
+		})
+	case "-rdp":
+		return recorder.NewRecorderWithFactory(config, sink, func(c capture.Config) (capture.Capturer, error) {
+			return capture.NewRDPCapturer(remote.addr, remote.rdp, c), nil
+		})
+	}
+	return recorder.NewRecorder(config, sink)
+}
+
+// record runs rec until Ctrl+C, a stop condition, or an unrecoverable
+// capture error, and reports what happened during the recording. When run
+// from a terminal, Enter pauses and resumes the recording.
+func record(rec *recorder.Recorder) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	if err := rec.Start(); err != nil {
+		if errors.Is(err, capture.ErrPermissionDenied) {
+			err = fmt.Errorf("%w (run 'witness doctor' for help)", err)
+		}
+		if errors.Is(err, capture.ErrRDPWithoutNLA) {
+			err = fmt.Errorf("%w (pass -rdp-allow-tls-only to sign in with the password protected only by TLS)", err)
+		}
+		return err
+	}
+	fmt.Fprintln(status, "● Recording... press Ctrl+C to stop")
+
+	pauseKey := watchPauseKey()
+	if pauseKey != nil {
+		fmt.Fprintln(status, "  Press Enter to pause or resume")
+	}
+
+	paused := false
+wait:
+	for {
+		select {
+		case <-interrupt:
+			// A second Ctrl+C while saving exits immediately
+			signal.Stop(interrupt)
+			break wait
+		case <-rec.Done():
+			break wait
+		case <-pauseKey:
+			toggle := rec.Pause
+			if paused {
+				toggle = rec.Resume
+			}
+			if err := toggle(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			paused = !paused
+			if paused {
+				fmt.Fprintln(status, "❚❚ Paused... press Enter to resume")
+			} else {
+				fmt.Fprintln(status, "● Recording...")
+			}
+		}
+	}
+
+	err := rec.Stop()
+	fmt.Fprintf(status, "Capture: %s\n", rec.Stats())
+	if reason := rec.StopReason(); reason != "" {
+		fmt.Fprintf(status, "Stopped: %s\n", reason)
+	}
+	for _, gap := range rec.Gaps() {
+		fmt.Fprintf(status, "Warning: %s gap in recording after %v\n", gap.Duration().Round(time.Millisecond), gap.Err)
+	}
+	for _, pause := range rec.Pauses() {
+		fmt.Fprintf(status, "Paused for %s: %s\n", pause.Duration().Round(time.Millisecond), pause.Reason)
+	}
+
+	return err
+}
+
+// watchPauseKey reports each press of Enter, or returns nil when stdin is
+// not a terminal, such as when witness runs from a script
+func watchPauseKey() <-chan struct{} {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+
+	presses := make(chan struct{})
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			if _, err := reader.ReadString('\n'); err != nil {
+				return
+			}
+			presses <- struct{}{}
+		}
+	}()
+	return presses
+}
+
+// formatBytes formats a file size for display
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// resolvePalette picks the GIF palette from -palette or -colors, falling
+// back to the palettes config entry for the quality level. It returns a
+// palette or an adaptive color count, or neither to use the quality preset.
+func resolvePalette(path string, colors int, quality string) (color.Palette, int, error) {
+	if path != "" && colors != 0 {
+		return nil, 0, fmt.Errorf("-palette and -colors cannot be used together")
+	}
+	if colors < 0 || colors > encoder.MaxColors {
+		return nil, 0, fmt.Errorf("-colors must be between 1 and %d, got %d", encoder.MaxColors, colors)
+	}
+
+	if path == "" && colors == 0 {
+		settings, err := config.Load()
+		if err != nil {
+			return nil, 0, err
+		}
+		path, colors, err = settings.PaletteFor(quality)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if path == "" {
+		return nil, min(colors, encoder.MaxColors), nil
+	}
+
+	p, err := encoder.LoadPalette(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return p, 0, nil
+}
+
+// parseChromaKey parses the -transparent color, returning nil if it is not set
+func parseChromaKey(s string, tolerance uint) (*color.RGBA, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if tolerance > 255 {
+		return nil, fmt.Errorf("-transparent-tolerance must be between 0 and 255, got %d", tolerance)
+	}
+
+	key, err := editor.ParseColor(s)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// protectOutput returns path, or a numbered variant of it if a file already
+// exists there and force is not set
+func protectOutput(path string, force bool) (string, error) {
+	if force || path == "" {
+		return path, nil
+	}
+
+	unique, err := output.Unique(path)
+	if err != nil {
+		return "", err
+	}
+	noteRenamed(path, unique)
+
+	return unique, nil
+}
+
+// noteRenamed tells the user when an output is written under another name
+// to keep an existing file
+func noteRenamed(path, unique string) {
+	if unique != path {
+		fmt.Fprintf(os.Stderr, "Note: %s exists, writing %s instead (use -force to overwrite)\n", path, unique)
+	}
+}
+
+// checkConsent rejects -consent where the recording banner cannot be shown
+func checkConsent(required bool) error {
+	if required && runtime.GOOS != "darwin" {
+		return errors.New("-consent is only supported on macOS")
+	}
+	return nil
+}
+
+// confirmConsent shows the recording banner and records the answer in the
+// audit log. It exits if the user declines or the banner cannot be shown.
+func confirmConsent(message string, region *capture.Region, output string) {
+	banner := consent.NewBanner(message)
+	entry := audit.Entry{Event: audit.EventConsentGiven, Region: region, Output: output}
+
+	err := banner.Confirm()
+	if errors.Is(err, consent.ErrDeclined) {
+		entry.Event = audit.EventConsentDeclined
+	}
+	// Without an answer there is nothing to record
+	if err == nil || errors.Is(err, consent.ErrDeclined) {
+		if auditErr := audit.Append(entry); auditErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", auditErr)
+			exit(1)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	if err := banner.Notify(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// warnMostlyStatic suggests a tighter region when most of a recording never
+// changes
+func warnMostlyStatic(activity analyze.Activity, region image.Rectangle) {
+	fmt.Fprintf(os.Stderr, "Note: %.0f%% of the frame never changes. A tighter region would make a smaller file:\n",
+		activity.StaticFraction()*100)
+	fmt.Fprintf(os.Stderr, "  -r %s (or pass -auto-region to apply it)\n", formatRect(region))
+}
+
+// warnLoop offers to trim a recording to the frames that loop seamlessly,
+// with fix being the command or flags that would do it
+func warnLoop(loop analyze.Loop, fix string) {
+	fmt.Fprintf(os.Stderr, "Note: frames %d-%d loop seamlessly. Keeping only them removes the jump back to the start:\n",
+		loop.First, loop.Last)
+	fmt.Fprintf(os.Stderr, "  %s (or pass -auto-loop to apply it)\n", fix)
+}
+
+// formatRect formats a rectangle as x,y,w,h for the -r flag
+func formatRect(r image.Rectangle) string {
+	return selector.FormatRegionString(&capture.Region{
+		X:      r.Min.X,
+		Y:      r.Min.Y,
+		Width:  r.Dx(),
+		Height: r.Dy(),
+	})
+}
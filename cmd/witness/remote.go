@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/remote"
+	"github.com/ericmhalvorsen/witness/pkg/source"
+)
+
+func handleRemote(args []string) {
+	fs := flag.NewFlagSet("remote", flag.ExitOnError)
+	output := fs.String("o", "", "Output file path, written on this machine")
+	outDir := fs.String("out-dir", "", "Directory for bare -o file names (default: output_dir from config)")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists instead of picking a new name")
+	regionStr := fs.String("r", "", "Capture region on the remote machine (x,y,w,h)")
+	regionName := fs.String("region", "", "Use a region saved on the remote machine")
+	fpsStr := fs.String("f", "15", "Frames per second (e.g. 15, 29.97, 30000/1001)")
+	quality := fs.String("q", "medium", "Quality level (low, medium, high; see -list-qualities)")
+	sshPath := fs.String("ssh", "ssh", "ssh binary to connect with")
+	witnessPath := fs.String("witness", "witness", "Path to witness on the remote machine")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness remote [options] user@host")
+		fmt.Println("\nRecord another machine's screen over SSH and save the GIF here. The remote")
+		fmt.Println("machine needs witness installed and key-based SSH access.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness remote alice@build-vm -o vm.gif")
+		fmt.Println("  witness remote alice@build-vm -region demo -o vm.gif -f 10")
+		fmt.Println("  witness remote alice@build-vm -r 0,0,1280,720 -witness /opt/witness/witness -o vm.gif")
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		os.Exit(1)
+	}
+	if len(positional) != 1 || *output == "" {
+		fmt.Fprintf(os.Stderr, "Error: a destination (user@host) and -o are required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if writesToStdout(*output) {
+		status = os.Stderr
+	}
+
+	fps, err := capture.ParseFPS(*fpsStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	q, err := encoder.ParseQuality(*quality)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	*output, releaseOutput, err = resolveOutput(*output, *outDir, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	agentArgs := []string{"-f", *fpsStr}
+	if *regionStr != "" {
+		agentArgs = append(agentArgs, "-r", *regionStr)
+	}
+	if *regionName != "" {
+		agentArgs = append(agentArgs, "-region", *regionName)
+	}
+	agent := remote.NewAgent(positional[0], agentArgs)
+	agent.SSH = *sshPath
+	agent.Witness = *witnessPath
+	agent.Stderr = os.Stderr
+
+	stream, err := agent.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	// Ctrl+C asks the agent to stop; frames already sent are still saved
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+		agent.Stop()
+	}()
+
+	enc := encoder.NewGIFEncoderFPS(*output, fps, q)
+	_, err = source.Copy(enc, source.NewPNGReader(stream, fps))
+	if err != nil {
+		agent.Stop()
+	}
+	if waitErr := agent.Wait(); err == nil {
+		err = waitErr
+	}
+	if err == nil && enc.FrameCount() == 0 {
+		err = fmt.Errorf("no frames were captured")
+	}
+	if err == nil {
+		fmt.Fprintf(status, "Encoding %d frames...\n", enc.FrameCount())
+		if writesToStdout(*output) {
+			err = enc.EncodeTo(os.Stdout)
+		} else {
+			err = enc.Encode()
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	fmt.Fprintf(status, "✓ Saved %s (%d frames from %s)\n", displayName(*output), enc.FrameCount(), positional[0])
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+)
+
+func handleRender(args []string) {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	timelinePath := fs.String("t", "", "Timeline JSON file with annotations")
+	input := fs.String("i", "", "Input GIF file path (default: the timeline's source)")
+	output := fs.String("o", "", "Output file path (- for stdout)")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists instead of picking a new name")
+	var annotations annotationFlags
+	fs.Var(&annotations, "annotate", "Add an annotation, e.g. arrow:40,200,180,120,at=1s-3s (repeatable)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness render [options]")
+		fmt.Println("\nRe-encode a recording with the annotations from a timeline")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness edit -i demo.gif -timeline demo.json")
+		fmt.Println("  witness render -t demo.json -o annotated.gif")
+		fmt.Println("  witness render -i demo.gif -o boxed.gif -annotate box:10,10,200,80,color=yellow")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if writesToStdout(*output) {
+		status = os.Stderr
+	}
+
+	if (*timelinePath == "" && *input == "") || *output == "" {
+		fmt.Fprintf(os.Stderr, "Error: -o and one of -t or -i are required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	timeline := &editor.Timeline{}
+	if *timelinePath != "" {
+		var err error
+		timeline, err = editor.LoadTimeline(*timelinePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	timeline.Annotations = append(timeline.Annotations, annotations...)
+
+	source := *input
+	if source == "" {
+		source = timeline.Source
+	}
+	if source == "" {
+		fmt.Fprintf(os.Stderr, "Error: timeline has no source; pass -i\n")
+		os.Exit(1)
+	}
+
+	clip, err := editor.LoadGIF(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	*output, err = protectOutput(*output, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	clip.Annotate(timeline.Annotations)
+
+	if err := writeClip(clip, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(status, "✓ Rendered %d annotations over %d frames to %s\n",
+		len(timeline.Annotations), clip.Len(), displayName(*output))
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/scenario"
+)
+
+func handleRun(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	failFast := fs.Bool("fail-fast", false, "Stop at the first scenario that fails")
+	rewrite := fs.Bool("rewrite", false, "Replace outputs even when their frames haven't changed")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness run [options] <scenario.yaml>...")
+		fmt.Println("\nRecord each scenario in turn, then print a summary. A scenario is a YAML")
+		fmt.Println("file of key: value pairs describing one recording:")
+		fmt.Println("\n  output: login.gif          # Relative to the scenario file (default: <name>.gif)")
+		fmt.Println("  region: demo               # Or rect: x,y,w,h, or window: <title>")
+		fmt.Println("  preset: terminal           # Also fps, quality, and flags: \"-lossy 40\"")
+		fmt.Println("  setup: ./reset-app.sh      # Run before recording starts")
+		fmt.Println("  run: ./log-in.sh           # Run while recording; recording stops when it exits")
+		fmt.Println("  duration: 20s              # Or max_frames: 300; needed without run")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness run docs/login.yaml")
+		fmt.Println("  witness run docs/scenarios/*.yaml")
+		fmt.Println("  witness run -rewrite docs/scenarios/*.yaml")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	// Load every scenario first, so a typo is found before anything records
+	var queue []*scenario.Scenario
+	for _, path := range fs.Args() {
+		s, err := scenario.Load(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		queue = append(queue, s)
+	}
+
+	// Ctrl+C reaches the recording too, which stops and saves; the rest of
+	// the queue is skipped
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	results := make([]scenarioResult, len(queue))
+	stopped := false
+	for i, s := range queue {
+		results[i].Scenario = s
+		if stopped {
+			results[i].Err = errSkipped
+			continue
+		}
+
+		fmt.Printf("▶ %s (%d of %d)\n", s.Name, i+1, len(queue))
+		results[i] = runScenario(s, *rewrite)
+
+		select {
+		case <-interrupt:
+			stopped = true
+		default:
+		}
+		if results[i].Err != nil && *failFast {
+			stopped = true
+		}
+	}
+
+	if !printRunSummary(results) {
+		os.Exit(1)
+	}
+}
+
+// errSkipped marks scenarios not recorded because the queue was stopped
+var errSkipped = errors.New("skipped")
+
+// scenarioResult is the outcome of recording one scenario
+type scenarioResult struct {
+	Scenario  *scenario.Scenario
+	Elapsed   time.Duration
+	Size      int64
+	Unchanged bool
+	Err       error
+}
+
+// runScenario runs the scenario's setup, records it with witness gif or
+// video while its run command drives the app, and stops the recording when
+// that command exits. Unless rewrite is set, an existing output showing the
+// same frames is left untouched, so its modification time and any upload
+// or commit keyed on it stay as they were.
+func runScenario(s *scenario.Scenario, rewrite bool) scenarioResult {
+	result := scenarioResult{Scenario: s}
+	started := time.Now()
+	defer func() { result.Elapsed = time.Since(started) }()
+
+	if s.Setup != "" {
+		if err := shellCommand(s.Setup, s.Dir).Run(); err != nil {
+			result.Err = fmt.Errorf("setup failed: %w", err)
+			return result
+		}
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		result.Err = fmt.Errorf("failed to find the witness binary: %w", err)
+		return result
+	}
+	tmp, err := os.MkdirTemp("", "witness-run-")
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer os.RemoveAll(tmp)
+	stopFile := filepath.Join(tmp, "stop")
+
+	// Record next to the output, keeping its extension, so it can be renamed
+	// into place once compared with the previous recording
+	out := s.OutputPath()
+	next := filepath.Join(filepath.Dir(out), ".witness-"+filepath.Base(out))
+	defer os.Remove(next)
+
+	cmd := exec.Command(self, s.Args(next, stopFile)...)
+	cmd.Dir = s.Dir
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if err := cmd.Start(); err != nil {
+		result.Err = fmt.Errorf("failed to start recording: %w", err)
+		return result
+	}
+
+	// Pass the recording's progress through, watching for capture to start
+	ready := make(chan struct{})
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		recording := false
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line := scanner.Text()
+			fmt.Println(line)
+			if !recording && strings.HasPrefix(line, "● Recording") {
+				recording = true
+				close(ready)
+			}
+		}
+	}()
+	exited := make(chan error, 1)
+	go func() {
+		<-copied
+		exited <- cmd.Wait()
+	}()
+
+	var runErr error
+	select {
+	case <-ready:
+		if s.Run != "" {
+			runErr = shellCommand(s.Run, s.Dir).Run()
+			if err := os.WriteFile(stopFile, nil, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to stop the recording: %v\n", err)
+			}
+		}
+		err = <-exited
+	case err = <-exited:
+	}
+
+	switch {
+	case err != nil:
+		result.Err = fmt.Errorf("recording failed: %w", err)
+	case runErr != nil:
+		result.Err = fmt.Errorf("run failed: %w", runErr)
+	}
+	if result.Err != nil {
+		return result
+	}
+
+	if !rewrite {
+		unchanged, err := scenario.Unchanged(out, next)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to compare with the previous recording: %v\n", err)
+		}
+		result.Unchanged = unchanged
+	}
+	if !result.Unchanged {
+		if err := os.Rename(next, out); err != nil {
+			result.Err = fmt.Errorf("failed to save recording: %w", err)
+			return result
+		}
+	}
+	if info, err := os.Stat(out); err == nil {
+		result.Size = info.Size()
+	}
+	return result
+}
+
+// shellCommand runs script with the system shell in dir
+func shellCommand(script, dir string) *exec.Cmd {
+	cmd := exec.Command("sh", "-c", script)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", script)
+	}
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// printRunSummary reports each scenario's outcome and whether all of them
+// were recorded
+func printRunSummary(results []scenarioResult) bool {
+	cwd, _ := os.Getwd()
+	fmt.Println("\nSummary:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	recorded, unchanged := 0, 0
+	for _, r := range results {
+		out := r.Scenario.OutputPath()
+		if rel, err := filepath.Rel(cwd, out); err == nil && !strings.HasPrefix(rel, "..") {
+			out = rel
+		}
+		if r.Err != nil {
+			fmt.Fprintf(w, "  ✗ %s\t%s\t%v\n", r.Scenario.Name, out, r.Err)
+			continue
+		}
+		recorded++
+		if r.Unchanged {
+			unchanged++
+			fmt.Fprintf(w, "  = %s\t%s\t%s, unchanged\n", r.Scenario.Name, out,
+				r.Elapsed.Round(100*time.Millisecond))
+			continue
+		}
+		fmt.Fprintf(w, "  ✓ %s\t%s\t%s, %s\n", r.Scenario.Name, out,
+			r.Elapsed.Round(100*time.Millisecond), formatBytes(r.Size))
+	}
+	w.Flush()
+	fmt.Printf("%d of %d scenarios recorded", recorded, len(results))
+	if unchanged > 0 {
+		fmt.Printf(", %d unchanged", unchanged)
+	}
+	fmt.Println()
+
+	return recorded == len(results)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/config"
+	"github.com/ericmhalvorsen/witness/pkg/schedule"
+)
+
+func handleSchedule(args []string) {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	at := fs.String("at", "", "Record once at this time of day, e.g. 14:00 or 2:30pm")
+	cronExpr := fs.String("cron", "", "Add a recording witness serve repeats on this schedule, e.g. \"0 14 * * mon-fri\"")
+	name := fs.String("name", "", "Name of the recurring recording (default: the output name)")
+	duration := fs.Duration("duration", 0, "How long to record (e.g. 10m)")
+	fs.DurationVar(duration, "d", 0, "Shorthand for -duration")
+	output := fs.String("o", "", "Output file; .gif records a GIF, .webm a WebM (once only), anything else an MP4")
+	regionName := fs.String("region", "", "Use a saved region by name")
+	regionStr := fs.String("r", "", "Capture region (x,y,w,h)")
+	fpsStr := fs.String("f", "", "Frames per second (default: 15 for GIF, 30 for video)")
+	quality := fs.String("q", "medium", "Quality level (low, medium, high)")
+	list := fs.Bool("list", false, "List recurring recordings and when they next run")
+	remove := fs.String("remove", "", "Delete the recurring recording with this name")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness schedule [options]")
+		fmt.Println("\nRecord unattended, once at a time of day with -at, or repeatedly with")
+		fmt.Println("-cron. Recurring recordings are run by witness serve and written to its")
+		fmt.Println("output directory; placeholders such as {date} keep them apart.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness schedule -at 14:00 -duration 10m -region dashboard -o daily.mp4")
+		fmt.Println("  witness schedule -cron \"0 14 * * mon-fri\" -d 10m -region dashboard -o dashboard-{date}.mp4")
+		fmt.Println("  witness schedule -list")
+		fmt.Println("  witness schedule -remove dashboard")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	modes := 0
+	for _, set := range []bool{*at != "", *cronExpr != "", *list, *remove != ""} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		fmt.Fprintln(os.Stderr, "Error: use one of -at, -cron, -list, or -remove")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *regionName != "" && *regionStr != "" {
+		fmt.Fprintln(os.Stderr, "Error: use either -region or -r, not both")
+		os.Exit(1)
+	}
+
+	switch {
+	case *list:
+		if err := listSchedules(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case *remove != "":
+		if err := removeSchedule(*remove); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Removed schedule %q\n", *remove)
+		return
+	}
+
+	if *output == "" {
+		fmt.Fprintln(os.Stderr, "Error: -o is required")
+		os.Exit(1)
+	}
+	// Unattended recordings need an end
+	if *duration <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: -duration is required, e.g. -duration 10m")
+		os.Exit(1)
+	}
+
+	if *cronExpr != "" {
+		entry := config.Schedule{
+			Name:     *name,
+			Cron:     *cronExpr,
+			Duration: duration.String(),
+			Output:   *output,
+			Region:   *regionName + *regionStr,
+			FPS:      *fpsStr,
+			Quality:  *quality,
+		}
+		if entry.Name == "" {
+			entry.Name = scheduleName(*output)
+		}
+		job, err := addSchedule(entry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Saved schedule %q: next at %s\n", job.Name, formatNextRun(job.Cron.Next(time.Now())))
+		fmt.Println("  Recordings run while witness serve is running")
+		return
+	}
+
+	// A one-off recording is an ordinary one that waits for its start time
+	recordArgs := []string{"-at", *at, "-d", duration.String(), "-o", *output, "-q", *quality}
+	if *regionName != "" {
+		recordArgs = append(recordArgs, "-region", *regionName)
+	}
+	if *regionStr != "" {
+		recordArgs = append(recordArgs, "-r", *regionStr)
+	}
+	if *fpsStr != "" {
+		recordArgs = append(recordArgs, "-f", *fpsStr)
+	}
+	switch strings.ToLower(filepath.Ext(*output)) {
+	case ".gif":
+		handleGif(recordArgs)
+	case ".webm":
+		handleVideo(append([]string{"-format", "webm"}, recordArgs...))
+	default:
+		handleVideo(recordArgs)
+	}
+}
+
+// scheduleName names a recurring recording after its output, without the
+// extension or placeholders: dashboard-{date}.mp4 is called dashboard
+func scheduleName(output string) string {
+	name := strings.TrimSuffix(output, filepath.Ext(output))
+	for {
+		start := strings.IndexByte(name, '{')
+		end := strings.IndexByte(name, '}')
+		if start < 0 || end < start {
+			break
+		}
+		name = name[:start] + name[end+1:]
+	}
+	return strings.Trim(name, "-_. ")
+}
+
+// addSchedule checks a recurring recording and saves it to the config
+// file, replacing any with the same name
+func addSchedule(entry config.Schedule) (schedule.Job, error) {
+	job, err := schedule.NewJob(entry)
+	if err != nil {
+		return schedule.Job{}, err
+	}
+	if _, err := job.ResolveRegion(); err != nil {
+		return schedule.Job{}, err
+	}
+
+	settings, err := config.Load()
+	if err != nil {
+		return schedule.Job{}, err
+	}
+	replaced := false
+	for i, s := range settings.Schedules {
+		if s.Name == entry.Name {
+			settings.Schedules[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		settings.Schedules = append(settings.Schedules, entry)
+	}
+	return job, config.Save(settings)
+}
+
+// removeSchedule deletes the recurring recording called name
+func removeSchedule(name string) error {
+	settings, err := config.Load()
+	if err != nil {
+		return err
+	}
+	for i, s := range settings.Schedules {
+		if s.Name == name {
+			settings.Schedules = append(settings.Schedules[:i], settings.Schedules[i+1:]...)
+			return config.Save(settings)
+		}
+	}
+	return fmt.Errorf("no schedule named %q", name)
+}
+
+// listSchedules prints the recurring recordings and when each runs next
+func listSchedules() error {
+	settings, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if len(settings.Schedules) == 0 {
+		fmt.Println("No scheduled recordings. Add one with: witness schedule -cron \"0 14 * * *\" -d 10m -o daily-{date}.mp4")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSCHEDULE\tDURATION\tOUTPUT\tREGION\tNEXT RUN")
+	now := time.Now()
+	for _, s := range settings.Schedules {
+		next := "invalid"
+		if job, err := schedule.NewJob(s); err == nil {
+			next = formatNextRun(job.Cron.Next(now))
+		}
+		region := s.Region
+		if region == "" {
+			region = "full screen"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Cron, s.Duration, s.Output, region, next)
+	}
+	return w.Flush()
+}
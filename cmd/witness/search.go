@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/ocr"
+	"github.com/ericmhalvorsen/witness/pkg/replay"
+)
+
+func handleSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	every := fs.Duration("every", time.Second, "Read the screen once per this much recording time when indexing")
+	language := fs.String("lang", "eng", "Tesseract language, e.g. eng or eng+deu")
+	reindex := fs.Bool("reindex", false, "Read every recording again instead of using saved indexes")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness search [options] <text> <recording or directory>...")
+		fmt.Println("\nFind when text appeared on screen in GIF and .wrec recordings, ignoring case.")
+		fmt.Println("Directories are searched recursively. Each recording's text is read once with")
+		fmt.Println("tesseract and saved beside it in <recording>" + ocr.IndexExtension + "; the index is")
+		fmt.Println("rebuilt when the recording or the OCR options change.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness search NullPointerException ./recordings")
+		fmt.Println("  witness search \"build failed\" demo.gif incident.wrec")
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		os.Exit(1)
+	}
+	if len(positional) < 2 || positional[0] == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *every < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -every must not be negative, got %v\n", *every)
+		os.Exit(1)
+	}
+	query := positional[0]
+	status = os.Stderr
+
+	recordings, err := findRecordings(positional[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(recordings) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no GIF or "+replay.Extension+" recordings found")
+		os.Exit(1)
+	}
+
+	// tesseract is only needed for recordings without a current index
+	var engine *ocr.Tesseract
+	found, matched := 0, 0
+	for _, path := range recordings {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		index, err := ocr.LoadIndex(ocr.IndexPath(path))
+		if *reindex || err != nil || !index.Current(info, *every, *language) {
+			if engine == nil {
+				if engine, err = ocr.NewTesseract(*language); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			fmt.Fprintf(status, "Indexing %s...\n", path)
+			entries, err := transcribe(path, *every, engine)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+				continue
+			}
+			index = ocr.NewIndex(info, *every, *language, entries)
+			if err := index.Save(ocr.IndexPath(path)); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+
+		matches := index.Search(query)
+		for _, m := range matches {
+			fmt.Printf("%s [%s] %s\n", path, ocr.FormatOffset(m.Offset), m.Line)
+		}
+		if len(matches) > 0 {
+			found += len(matches)
+			matched++
+		}
+	}
+
+	if found == 0 {
+		fmt.Fprintf(status, "No matches for %q in %d recordings\n", query, len(recordings))
+		return
+	}
+	fmt.Fprintf(status, "✓ %d matches in %d of %d recordings\n", found, matched, len(recordings))
+}
+
+// findRecordings expands paths into the GIF and .wrec recordings they
+// name, searching directories recursively
+func findRecordings(paths []string) ([]string, error) {
+	var recordings []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			recordings = append(recordings, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if !d.IsDir() && (ext == ".gif" || ext == replay.Extension) {
+				recordings = append(recordings, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return recordings, nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/selector"
+)
+
+func handleSelect(args []string) {
+	fs := flag.NewFlagSet("select", flag.ExitOnError)
+	name := fs.String("name", "", "Save the selected region with a name")
+	setDefault := fs.Bool("default", false, "Set this region as the default")
+	regionStr := fs.String("region", "", "Use this region (x,y,w,h) instead of selecting one")
+	fs.StringVar(regionStr, "r", "", "Use this region (shorthand)")
+	nonInteractive := fs.Bool("non-interactive", false, "Never open the selector: use -region, $"+selector.EnvRegion+", or the default region")
+	timeout := fs.Duration("timeout", 0, "Give up if no region is selected in this time (e.g. 2m; 0 waits forever)")
+	count := fs.Int("count", 1, "Select and save this many regions in a row (0 keeps going until you cancel)")
+	adjust := fs.String("adjust", "", "Adjust a saved region instead of selecting a new one")
+	methodStr := fs.String("selection-method", "auto", "How macOS reads the dragged region: tap (event tap, needs Input Monitoring), prefs (screencapture preferences), or auto to try the tap first")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness select [options]")
+		fmt.Println("\nLaunch an interactive region selector")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness select                    # Select a region")
+		fmt.Println("  witness select -name demo         # Select and save as 'demo'")
+		fmt.Println("  witness select -name demo -default # Select, save, and set as default")
+		fmt.Println("  witness select -name demo -r 0,0,800,600 # Save a region without selecting")
+		fmt.Println("  witness select -timeout 1m        # Give up after a minute")
+		fmt.Println("  witness select -count 3           # Select and name three regions")
+		fmt.Println("  witness select -count 0 -name app # Save app-1, app-2, ... until ESC")
+		fmt.Println("  witness select -adjust demo       # Fine-tune the edges of 'demo'")
+		fmt.Println("  witness select -selection-method tap # Don't read the screencapture preferences")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	method, err := selector.ParseSelectionMethod(*methodStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	config := selector.DefaultConfig()
+	config.Timeout = *timeout
+	config.Method = method
+
+	if *adjust != "" {
+		if *regionStr != "" || *nonInteractive || *count != 1 {
+			fmt.Fprintln(os.Stderr, "Error: -adjust cannot be used with -region, -non-interactive, or -count")
+			os.Exit(1)
+		}
+		adjustRegion(*adjust, *name, *setDefault, config)
+		return
+	}
+
+	if *count != 1 {
+		if *count < 0 {
+			fmt.Fprintf(os.Stderr, "Error: -count must be 0 or more, got %d\n", *count)
+			os.Exit(1)
+		}
+		if *regionStr != "" || *nonInteractive {
+			fmt.Fprintln(os.Stderr, "Error: -count cannot be used with -region or -non-interactive")
+			os.Exit(1)
+		}
+		selectMultiple(*count, *name, *setDefault, config)
+		return
+	}
+
+	var region *capture.Region
+	if *regionStr != "" || *nonInteractive {
+		region, err = selector.NonInteractive(*regionStr)
+		if err == nil && *name != "" {
+			if err = selector.SaveRegion(*name, region); err == nil {
+				fmt.Printf("✓ Saved region '%s'\n", *name)
+			}
+		}
+	} else {
+		var sel selector.Selector
+		sel, err = selector.NewSelectorWithConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if *name != "" {
+			region, err = sel.SelectWithName(*name)
+		} else {
+			region, err = sel.Select()
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Set as default if requested
+	if *setDefault && *name != "" {
+		if err := selector.SetDefaultRegion(*name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to set default region: %v\n", err)
+		} else {
+			fmt.Printf("✓ Set '%s' as default region\n", *name)
+		}
+	}
+
+	// Print region info
+	if *name == "" {
+		fmt.Println("\nTo use this region in capture:")
+		fmt.Printf("  witness gif -r %s\n", selector.FormatRegionString(region))
+		fmt.Println("\nOr save it for later use:")
+		fmt.Printf("  witness select -name myregion\n")
+	}
+}
+
+// selectMultiple runs several selections in one session and saves each
+// region, naming them prefix-1, prefix-2, ... or asking for each name
+func selectMultiple(count int, prefix string, setDefault bool, config selector.Config) {
+	sel, err := selector.NewSelectorWithConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if count == 0 {
+		fmt.Println("Select regions one after another; press ESC when done")
+	}
+	names, err := selector.SelectMultiple(sel, count, regionNamer(prefix))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if len(names) == 0 {
+			os.Exit(1)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	if setDefault {
+		if err := selector.SetDefaultRegion(names[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to set default region: %v\n", err)
+		} else {
+			fmt.Printf("✓ Set '%s' as default region\n", names[0])
+		}
+	}
+
+	fmt.Printf("\nSaved %d region(s): %s\n", len(names), strings.Join(names, ", "))
+	fmt.Printf("Record one with:\n  witness gif -region %s\n", names[0])
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// adjustRegion lets the user correct the saved region named from and saves
+// the result as name, or back under from if name is empty. Platforms whose
+// selector cannot show an existing region take typed adjustments instead.
+func adjustRegion(from, name string, setDefault bool, config selector.Config) {
+	region, err := selector.LoadRegion(from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if name == "" {
+		name = from
+	}
+
+	sel, err := selector.NewSelectorWithConfig(config)
+	if adjuster, ok := sel.(selector.Adjuster); err == nil && ok {
+		region, err = adjuster.Adjust(region)
+	} else {
+		region, err = nudgeRegion(region)
+	}
+	if err == nil {
+		err = selector.SaveRegion(name, region)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Saved region '%s' (%s)\n", name, selector.FormatRegionString(region))
+
+	if setDefault {
+		if err := selector.SetDefaultRegion(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to set default region: %v\n", err)
+		} else {
+			fmt.Printf("✓ Set '%s' as default region\n", name)
+		}
+	}
+}
+
+// nudgeRegion reads adjustments such as "left-5 w+10" from stdin and
+// applies them to region until an empty line or the end of input. Typing q
+// cancels.
+func nudgeRegion(region *capture.Region) (*capture.Region, error) {
+	fmt.Println("Adjust the region's edges, e.g. left-5 right+10 top+2, or move it with x+10 y-4.")
+	fmt.Println("Press Enter on an empty line to save, or type q to cancel.")
+
+	input := bufio.NewReader(os.Stdin)
+	current := *region
+	for {
+		fmt.Printf("%dx%d at (%d,%d)> ", current.Width, current.Height, current.X, current.Y)
+		line, err := input.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		spec := strings.TrimSpace(line)
+		if spec == "q" {
+			return nil, selector.ErrCanceled
+		}
+		if spec != "" {
+			next, nudgeErr := selector.Nudge(current, spec)
+			if nudgeErr != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", nudgeErr)
+			} else {
+				current = next
+			}
+		}
+		if err == io.EOF {
+			fmt.Println()
+			return &current, nil
+		}
+		if spec == "" {
+			return &current, nil
+		}
+	}
+}
+
+// regionNamer names the regions of a multi-region selection: prefix-N when
+// a prefix is given, otherwise the name typed in for each, defaulting to
+// region-N
+func regionNamer(prefix string) func(n int, region *capture.Region) (string, error) {
+	if prefix != "" {
+		return func(n int, _ *capture.Region) (string, error) {
+			return fmt.Sprintf("%s-%d", prefix, n), nil
+		}
+	}
+
+	input := bufio.NewReader(os.Stdin)
+	return func(n int, _ *capture.Region) (string, error) {
+		fallback := fmt.Sprintf("region-%d", n)
+		fmt.Printf("Name for region %d [%s]: ", n, fallback)
+		line, err := input.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if err == io.EOF {
+			fmt.Println()
+		}
+		if name := strings.TrimSpace(line); name != "" {
+			return name, nil
+		}
+		return fallback, nil
+	}
+}
+
+func handleRegions(args []string) {
+	fs := flag.NewFlagSet("regions", flag.ExitOnError)
+	delete := fs.String("delete", "", "Delete a saved region")
+	setDefault := fs.String("default", "", "Set a region as default")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness regions [options]")
+		fmt.Println("\nManage saved screen regions")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness regions                    # List all saved regions")
+		fmt.Println("  witness regions -delete demo       # Delete 'demo' region")
+		fmt.Println("  witness regions -default demo      # Set 'demo' as default")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// Handle delete
+	if *delete != "" {
+		if err := selector.DeleteRegion(*delete); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Deleted region '%s'\n", *delete)
+		return
+	}
+
+	// Handle set default
+	if *setDefault != "" {
+		if err := selector.SetDefaultRegion(*setDefault); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Set '%s' as default region\n", *setDefault)
+		return
+	}
+
+	// Handle list (default action)
+	names, err := selector.ListRegions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(names) == 0 {
+		fmt.Println("No saved regions")
+		fmt.Println("\nCreate one with: witness select -name myregion")
+		return
+	}
+
+	fmt.Println("Saved regions:")
+	for _, name := range names {
+		info, err := selector.GetRegionInfo(name)
+		if err != nil {
+			continue
+		}
+		fmt.Printf("  %s\n", info)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/audit"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/config"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+	"github.com/ericmhalvorsen/witness/pkg/remote"
+	"github.com/ericmhalvorsen/witness/pkg/schedule"
+)
+
+func handleServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", remote.DefaultAddr, "Address to listen on; use 0.0.0.0:7420 to accept other machines")
+	token := fs.String("token", "", "Require this shared secret from witness sync (default $WITNESS_TOKEN)")
+	outDir := fs.String("out-dir", "", "Directory for recordings (default: output_dir from config)")
+	insecure := fs.Bool("insecure", false, "Allow listening on a non-loopback address without -token")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness serve [options]")
+		fmt.Println("\nRun a daemon that records when witness sync asks it to, on the recurring")
+		fmt.Println("schedules added with witness schedule -cron, and while the applications")
+		fmt.Println("added with witness watch are frontmost")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness serve                                # Accept local requests only")
+		fmt.Println("  witness serve -listen 0.0.0.0:7420 -token s3cret")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	envDefault(fs, "token", "WITNESS_TOKEN")
+
+	if _, _, err := net.SplitHostPort(*listen); err == nil && *token == "" && !remote.LoopbackOnly(*listen) {
+		if !*insecure {
+			fmt.Fprintf(os.Stderr, "Error: listening on %s without -token would let anyone who can reach it record this screen; set -token, or pass -insecure to allow it\n", *listen)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: listening on %s without -token; anyone who can reach it can record this screen\n", *listen)
+	}
+
+	server := remote.NewServer(func(req remote.Request, started func(), stop <-chan struct{}) error {
+		err := recordRemote(req, *outDir, started, stop)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return err
+	}, *token)
+
+	settings, err := config.Load()
+	if err == nil {
+		err = startSchedules(server, settings.Schedules)
+	}
+	if err == nil {
+		err = startWatches(server, settings.Watches)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(status, "Listening on %s\n", *listen)
+	if err := http.ListenAndServe(*listen, server); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// startSchedules runs the recurring recordings from the config file on
+// server. A run is skipped with a warning if another recording is still
+// going when it comes round.
+func startSchedules(server *remote.Server, schedules []config.Schedule) error {
+	jobs, err := schedule.NewJobs(schedules)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	scheduler := schedule.NewScheduler(jobs, func(job schedule.Job, at time.Time) {
+		if err := startScheduled(server, job, at); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipped scheduled recording %q: %v\n", job.Name, err)
+		}
+	})
+	for _, job := range jobs {
+		fmt.Fprintf(status, "Scheduled %s (%s): next at %s\n", job.Name, job.Cron, formatNextRun(job.Cron.Next(time.Now())))
+	}
+	go scheduler.Run(nil)
+	return nil
+}
+
+// startScheduled asks server to record job's run starting at
+func startScheduled(server *remote.Server, job schedule.Job, at time.Time) error {
+	region, err := job.ResolveRegion()
+	if err != nil {
+		return err
+	}
+	name, err := job.OutputAt(at)
+	if err != nil {
+		return err
+	}
+	return server.Schedule(remote.Request{
+		StartAt:  at,
+		Duration: job.Duration,
+		Output:   name,
+		FPS:      job.FPS,
+		Quality:  job.Quality,
+		Region:   region,
+	})
+}
+
+// startWatches records on server while each watched application is
+// frontmost, a new file for each time it comes to the front
+func startWatches(server *remote.Server, watches []config.Watch) error {
+	rules, err := schedule.NewWatchRules(watches)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	if _, err := capture.FrontmostApplication(); err != nil {
+		return fmt.Errorf("watch rules need the frontmost application, which is unavailable here: %w", err)
+	}
+
+	watcher := schedule.NewWatcher(rules, capture.FrontmostApplication, func(rule schedule.WatchRule, at time.Time) (func(), error) {
+		name, err := startWatched(server, rule, at)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: not recording %s: %v\n", rule.App, err)
+			return nil, err
+		}
+		return func() {
+			// Leave recordings started some other way alone
+			if st := server.Status(); st.State != remote.StateIdle && st.Output == name && st.StartAt.Equal(at) {
+				server.Stop()
+			}
+		}, nil
+	})
+	for _, rule := range rules {
+		fmt.Fprintf(status, "Watching %s: recording while it is frontmost\n", rule.App)
+	}
+	go watcher.Run(nil)
+	return nil
+}
+
+// startWatched asks server to record rule's session from at until it is
+// stopped, and returns the session's file name
+func startWatched(server *remote.Server, rule schedule.WatchRule, at time.Time) (string, error) {
+	region, err := rule.ResolveRegion()
+	if err != nil {
+		return "", err
+	}
+	name, err := rule.OutputAt(at)
+	if err != nil {
+		return "", err
+	}
+	return name, server.Schedule(remote.Request{
+		StartAt: at,
+		Output:  name,
+		FPS:     rule.FPS,
+		Quality: rule.Quality,
+		Region:  region,
+	})
+}
+
+// formatNextRun describes when a schedule runs next
+func formatNextRun(next time.Time) string {
+	if next.IsZero() {
+		return "never"
+	}
+	return next.Format("Mon Jan 2 15:04")
+}
+
+// recordRemote records a GIF, or an MP4 if the output ends in .mp4, for a
+// witness sync, witness ctl, or scheduled request, until req.Duration
+// passes, if it is positive, or stop is closed
+func recordRemote(req remote.Request, outDir string, started func(), stop <-chan struct{}) (err error) {
+	// Only bare names are accepted so a remote caller cannot write
+	// elsewhere on this machine
+	if filepath.Base(req.Output) != req.Output || req.Output == "." || req.Output == ".." {
+		return fmt.Errorf("output must be a file name, got %q", req.Output)
+	}
+
+	fpsStr := req.FPS
+	if fpsStr == "" {
+		fpsStr = "15"
+	}
+	fps, err := capture.ParseFPS(fpsStr)
+	if err != nil {
+		return err
+	}
+
+	quality := req.Quality
+	if quality == "" {
+		quality = "medium"
+	}
+	q, err := encoder.ParseQuality(quality)
+	if err != nil {
+		return err
+	}
+
+	path, release, err := resolveOutput(req.Output, outDir, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	recConfig := recorder.DefaultConfig(capture.Config{Region: req.Region, FPS: fps, IncludeCursor: true})
+	recConfig.PauseWhen, recConfig.StopWhen, err = recordingConditions(false, 0, true, "")
+	if err != nil {
+		return err
+	}
+
+	var enc videoSink = gifSink{encoder.NewGIFEncoderFPS(path, fps, q)}
+	if strings.EqualFold(filepath.Ext(path), ".mp4") {
+		if enc, err = encoder.NewVideoEncoder(path, fps, q); err != nil {
+			return err
+		}
+	}
+	rec := recorder.NewRecorder(recConfig, enc)
+	recording, err := audit.StartRecording(req.Region, path)
+	if err != nil {
+		releaseSink(enc)
+		return err
+	}
+
+	err = rec.Start()
+	if err == nil {
+		started()
+		var timeout <-chan time.Time
+		if req.Duration > 0 {
+			fmt.Fprintf(status, "● Recording %s for %v\n", displayName(path), req.Duration)
+			timer := time.NewTimer(req.Duration)
+			defer timer.Stop()
+			timeout = timer.C
+		} else {
+			fmt.Fprintf(status, "● Recording %s until stopped\n", displayName(path))
+		}
+
+		select {
+		case <-timeout:
+		case <-stop:
+		case <-rec.Done():
+		}
+		err = rec.Stop()
+		fmt.Fprintf(status, "Capture: %s\n", rec.Stats())
+	}
+	if err == nil && enc.FrameCount() == 0 {
+		err = fmt.Errorf("no frames were captured")
+	}
+	if err == nil {
+		err = enc.Close()
+	} else {
+		releaseSink(enc)
+	}
+	if auditErr := recording.Stop(err); auditErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", auditErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(status, "✓ Saved %s (%d frames)\n", displayName(path), enc.FrameCount())
+	return nil
+}
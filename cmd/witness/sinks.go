@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/output"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+	"github.com/ericmhalvorsen/witness/pkg/replay"
+	"github.com/ericmhalvorsen/witness/pkg/source"
+)
+
+// writesToStdout reports whether an -o value selects stdout
+func writesToStdout(name string) bool {
+	return name == output.Stdout
+}
+
+// displayName describes an -o value in progress messages
+func displayName(path string) string {
+	if writesToStdout(path) {
+		return "stdout"
+	}
+	return path
+}
+
+// writeClip saves the clip to path, or streams it to stdout for -o -
+func writeClip(clip *editor.Clip, path string) error {
+	if writesToStdout(path) {
+		return clip.WriteGIF(os.Stdout)
+	}
+	return clip.SaveGIF(path)
+}
+
+// resolveRegion returns the region given with -r or -region, or nil to
+// capture the full screen
+// streamFrames copies frames to path (or stdout) as a y4m or rawvideo stream.
+// Unlike GIF these formats are written as frames arrive. process wraps the
+// stream with any frame processors.
+func streamFrames(format, path string, fps capture.FPS, frames source.Reader, process func(recorder.FrameSink) recorder.FrameSink) (int, error) {
+	sink, err := newStreamSink(format, path, fps)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := source.Copy(process(sink), frames)
+	if err != nil {
+		sink.Close()
+		return n, err
+	}
+	if n == 0 {
+		sink.Close()
+		return 0, fmt.Errorf("no frames to encode")
+	}
+	return n, sink.Close()
+}
+
+// videoSink receives recorded video frames and is finished with Close
+type videoSink interface {
+	recorder.FrameSink
+	FrameCount() int
+	Close() error
+}
+
+// gifSink is a GIF encoder finished with Close, which encodes the frames
+type gifSink struct {
+	*encoder.GIFEncoder
+}
+
+func (s gifSink) Close() error {
+	return s.Encode()
+}
+
+// releaseSink stops a video encoder's ffmpeg process after a recording
+// fails. A GIF encoder holds nothing to release, and closing it would write
+// the frames so far.
+func releaseSink(enc videoSink) {
+	if v, ok := enc.(*encoder.VideoEncoder); ok {
+		v.Close()
+	}
+}
+
+// streamSink writes a y4m, rawvideo, or mjpeg stream to a file or stdout
+type streamSink struct {
+	frames interface {
+		recorder.FrameSink
+		FrameCount() int
+	}
+	buf  *bufio.Writer
+	file *os.File
+}
+
+// newStreamSink creates a y4m, rawvideo, or mjpeg writer for path, or
+// stdout for -o -, or for png a frame sequence in the directory path
+func newStreamSink(format, path string, fps capture.FPS) (videoSink, error) {
+	if format == "png" {
+		if writesToStdout(path) {
+			return nil, fmt.Errorf("-format png writes a directory of frames; use -o <dir> instead of -o -")
+		}
+		return encoder.NewPNGSequenceEncoder(path, fps), nil
+	}
+
+	s := &streamSink{}
+
+	var w io.Writer = os.Stdout
+	if !writesToStdout(path) {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+		s.file = f
+		w = f
+	}
+
+	s.buf = bufio.NewWriter(w)
+	switch format {
+	case "y4m":
+		s.frames = encoder.NewY4MEncoder(s.buf, fps)
+	case "mjpeg":
+		// Bare JPEGs, flushed as each is written, as ffmpeg -f mjpeg reads them
+		mjpeg := encoder.NewMJPEGEncoder(s.buf)
+		mjpeg.SetBoundary("")
+		s.frames = mjpeg
+	default:
+		s.frames = encoder.NewRawEncoder(s.buf)
+	}
+	return s, nil
+}
+
+func (s *streamSink) AddFrame(frame *capture.Frame) error {
+	return s.frames.AddFrame(frame)
+}
+
+func (s *streamSink) FrameCount() int {
+	return s.frames.FrameCount()
+}
+
+// Close flushes buffered frames and closes the output file
+func (s *streamSink) Close() error {
+	err := s.buf.Flush()
+	if s.file != nil {
+		if closeErr := s.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// saveCapture tees frames to a .wrec file for -save-capture before any
+// processing. The returned function finishes the file.
+func saveCapture(path string, fps capture.FPS, sink recorder.FrameSink, force bool) (recorder.FrameSink, func() error, error) {
+	if path == "" {
+		return sink, func() error { return nil }, nil
+	}
+	if !strings.HasSuffix(path, replay.Extension) {
+		return nil, nil, fmt.Errorf("-save-capture must be a %s file, got %q", replay.Extension, path)
+	}
+
+	path, err := protectOutput(path, force)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, err := replay.Create(path, fps)
+	if err != nil {
+		return nil, nil, err
+	}
+	return replay.Tee(w, sink), w.Close, nil
+}
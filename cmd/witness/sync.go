@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/remote"
+)
+
+func handleSync(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	hosts := fs.String("hosts", "", "Comma-separated witness serve addresses (host:port)")
+	output := fs.String("o", "", "Output file name, written on each machine")
+	duration := fs.Duration("d", 0, "How long to record (e.g. 30s)")
+	lead := fs.Duration("lead", 2*time.Second, "How far ahead to schedule the shared start")
+	fpsStr := fs.String("f", "15", "Frames per second (e.g. 15, 29.97, 30000/1001)")
+	quality := fs.String("q", "medium", "Quality level (low, medium, high)")
+	regionStr := fs.String("r", "", "Capture region on every machine (x,y,w,h)")
+	token := fs.String("token", "", "Shared secret the daemons expect (default $WITNESS_TOKEN)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness sync [options]")
+		fmt.Println("\nStart recording on several machines running witness serve at the same")
+		fmt.Println("moment. Clock offsets are measured so every recording begins at one shared")
+		fmt.Println("instant, keeping frame timestamps aligned across machines.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness sync -hosts 10.0.0.5:7420,10.0.0.6:7420 -d 30s -o cluster.gif")
+		fmt.Println("  witness sync -hosts a:7420,b:7420 -d 10s -o demo.gif -f 10 -lead 5s")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	envDefault(fs, "token", "WITNESS_TOKEN")
+
+	var addrs []string
+	for _, h := range strings.Split(*hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			addrs = append(addrs, h)
+		}
+	}
+	if len(addrs) == 0 || *output == "" || *duration <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: -hosts, -o, and -d are required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if _, err := capture.ParseFPS(*fpsStr); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := encoder.ParseQuality(*quality); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	region, err := resolveRegion(*regionStr, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	req := remote.Request{
+		Duration: *duration,
+		Output:   *output,
+		FPS:      *fpsStr,
+		Quality:  *quality,
+		Region:   region,
+	}
+	coordinator := remote.NewCoordinator(addrs, *token)
+	results, err := coordinator.Start(req, *lead)
+
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", r.Host, r.Err)
+			continue
+		}
+		fmt.Printf("  %s: clock offset %v (±%v)\n", r.Host, r.Offset.Offset.Round(time.Millisecond), (r.Offset.RTT / 2).Round(time.Millisecond))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	start := results[0].StartAt.Add(-results[0].Offset.Offset)
+	fmt.Printf("✓ Recording starts on %d machines at %s for %v\n", len(results), start.Format("15:04:05.000"), *duration)
+}
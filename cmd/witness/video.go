@@ -0,0 +1,399 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
+	"github.com/ericmhalvorsen/witness/pkg/audit"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+	"github.com/ericmhalvorsen/witness/pkg/schedule"
+)
+
+func handleVideo(args []string) {
+	fs := flag.NewFlagSet("video", flag.ExitOnError)
+	output := fs.String("o", "", "Output file path (- for stdout)")
+	outDir := fs.String("out-dir", "", "Directory for bare -o file names, e.g. ~/Recordings/{year}/{month} (default: output_dir from config)")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists instead of picking a new name")
+	regionStr := fs.String("r", "", "Capture region (x,y,w,h)")
+	regionName := fs.String("region", "", "Use a saved region by name")
+	display := fs.String("display", "", "Record the display with this ID, UUID, or name, e.g. \"DELL U2720Q\" (see witness displays; default the main display)")
+	cursor := fs.Bool("cursor", true, "Draw the mouse pointer into frames")
+	hidpi := fs.String("hidpi", "physical", "Frame pixels per point on Retina displays: physical, logical, or a factor such as 1.5")
+	showClicks := fs.Bool("clicks", false, "Draw a ripple wherever the mouse is clicked (macOS)")
+	followSize := fs.String("follow", "", "Record a WxH-point view that pans to follow the mouse pointer, e.g. 800x600 (macOS)")
+	stabilize := fs.Float64("stabilize", 0.85, "How steadily -follow pans, from 0 (tracks every movement) to just under 1 (glides slowly)")
+	saveCapturePath := fs.String("save-capture", "", "Also save the captured frames losslessly to a .wrec file, to replay with witness encode")
+	heatmapPath := fs.String("heatmap", "", "Also save a PNG heatmap of where the screen changed (and was clicked, with -clicks)")
+	webcamOn := fs.Bool("webcam", false, "Overlay the webcam in a corner of the recording (requires ffmpeg)")
+	webcamDevice := fs.String("webcam-device", "", "Camera for -webcam: an index on macOS, a /dev/video path on Linux, or a name on Windows (default: first camera)")
+	webcamCorner := fs.String("webcam-corner", "bottom-right", "Corner for -webcam: bottom-right, bottom-left, top-right, or top-left")
+	webcamSize := fs.Float64("webcam-size", 0.25, "Width of the -webcam overlay as a fraction of the frame width")
+	windowStr := fs.String("window", "", "Record one window by title, app name, or ID, following it as it moves (macOS)")
+	app := fs.String("app", "", "Record only the windows of the application whose name contains this (macOS 12.3+)")
+	var exclude windowTargetFlags
+	fs.Var(&exclude, "exclude", "Leave windows out of the recording by title, app name, or ID (repeatable; macOS 12.3+)")
+	vncAddr := fs.String("vnc", "", "Record a VNC server instead of this screen, e.g. localhost:5900 for a container")
+	vncPassword := fs.String("vnc-password", "", "Password for -vnc (default $WITNESS_VNC_PASSWORD)")
+	rdpAddr := fs.String("rdp", "", "Record a Windows desktop over Remote Desktop instead of this screen, e.g. winserver or winserver:3389")
+	rdpUser := fs.String("rdp-user", "", "User for -rdp: name, DOMAIN\\name, or name@domain (default $WITNESS_RDP_USER)")
+	rdpPassword := fs.String("rdp-password", "", "Password for -rdp (default $WITNESS_RDP_PASSWORD)")
+	rdpSize := fs.String("rdp-size", "1920x1080", "Desktop size to ask the -rdp server for")
+	rdpCert := fs.String("rdp-cert", "", "SHA-256 fingerprint of the -rdp server's certificate (default: trust the first certificate seen and refuse a different one)")
+	rdpAllowTLSOnly := fs.Bool("rdp-allow-tls-only", false, "Sign in to -rdp servers without Network Level Authentication, which sends the password protected only by TLS")
+	fpsStr := fs.String("f", "30", "Frames per second (e.g. 30, 29.97, 30000/1001)")
+	fs.StringVar(fpsStr, "output-fps", "30", "Alias for -f")
+	captureFPSStr := fs.String("capture-fps", "", "Capture faster than -f and keep the sharpest frame of each group (e.g. 60)")
+	captureScale := fs.Float64("capture-scale", 1, "Shrink frames by this factor as they are captured, e.g. 0.5 for 4K displays (0-1)")
+	quality := fs.String("q", "medium", "Quality level (low, medium, high; see -list-qualities)")
+	listQualities := fs.Bool("list-qualities", false, "Describe the quality levels and exit")
+	format := fs.String("format", "mp4", "Output format (mp4, webm, av1, y4m, rawvideo, mjpeg, png)")
+	ffmpegArgs := fs.String("ffmpeg-args", "", "Encode with these ffmpeg output arguments instead; {fps}, {width}, {height}, {crf}, and {output} are filled in")
+	bitrateStr := fs.String("bitrate", "", "Target MP4 bitrate in bits per second, e.g. 4M or 800k (default: set by -q)")
+	keyframeInterval := fs.Int("keyframe-interval", 0, "Most frames between MP4 keyframes; fewer seek faster but make larger files (default: chosen by the encoder)")
+	profileStr := fs.String("profile", "high", "H.264 profile: high, main, or baseline for the oldest players")
+	roi := fs.Bool("roi", false, "Keep the cursor and active areas sharp and compress static areas harder")
+	denoiseOn := fs.Bool("denoise", false, "Suppress pixel flicker between frames for cleaner, smaller output")
+	denoiseTol := fs.Uint("denoise-tolerance", analyze.DefaultTolerance, "Largest per-channel change -denoise treats as noise (0-255)")
+	scaleFactor := fs.Float64("scale", 1, "Resize frames by this factor, e.g. 0.5 to halve Retina captures")
+	scaleMode := fs.String("scale-mode", "smooth", "Resampling for -scale (smooth, text for terminals and code, nearest for whole ratios)")
+	idleSkip := fs.Duration("idle-skip", 0, "Drop frames once the screen has been unchanged this long (e.g. 1s)")
+	presetName := fs.String("preset", "", "Apply a preset (terminal, browser-demo, full-tutorial, or one from config); other flags override it")
+	pinSpace := fs.Bool("pin-space", false, "Pause while a different Space (virtual desktop) is active")
+	pauseWindow := fs.Uint("pause-window", 0, "Pause while the window with this ID is minimized or covered")
+	stopOnLock := fs.Bool("stop-on-lock", true, "Stop and save when the screen locks, the system sleeps, or the user switches")
+	stopFile := fs.String("stop-file", "", "Stop and save once this file exists, removing it (for scripts)")
+	maxDuration := fs.Duration("d", 0, "Stop and save after recording this long (e.g. 30s)")
+	maxFrames := fs.Int("max-frames", 0, "Stop and save after capturing this many frames")
+	startAt := fs.String("at", "", "Start recording at this time of day, e.g. 14:30 or 2:30pm")
+	startAfter := fs.Duration("after", 0, "Start recording after waiting this long (e.g. 10m)")
+	countdown := fs.Duration("countdown", 0, "Announce a scheduled recording this long before it starts, with a notification on macOS (e.g. 10s)")
+	requireConsent := fs.Bool("consent", false, "Show a recording banner that must be clicked through before capture starts")
+	consentMessage := fs.String("consent-message", "", "Custom text for the -consent banner")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness video [options]")
+		fmt.Println("\nRecord screen and save as MP4")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness video -o tutorial.mp4")
+		fmt.Println("  witness video -o tutorial.mp4 -f 30 -q high")
+		fmt.Println("  witness video -o tutorial.mp4 -d 2m")
+		fmt.Println("  witness video -o overnight.mp4 -after 10m -d 1h")
+		fmt.Println("  witness video -o session.mp4 -heatmap session-heatmap.png")
+		fmt.Println("  witness video -o walkthrough.mp4 -follow 1280x720 -stabilize 0.9")
+		fmt.Println("  witness video -region demo -o capture.mp4")
+		fmt.Println("  witness video -window Safari -o browser.mp4")
+		fmt.Println("  witness video -o demo.mp4 -exclude 1Password")
+		fmt.Println("  witness video -app Xcode -o xcode-demo.mp4")
+		fmt.Println("  witness video -vnc localhost:5900 -o container.mp4")
+		fmt.Println("  witness video -rdp winserver -rdp-user 'CORP\\alice' -o server.mp4")
+		fmt.Println("  witness video -o tutorial.mp4 -roi")
+		fmt.Println("  witness video -o tutorial.mp4 -bitrate 4M -keyframe-interval 60 -profile main")
+		fmt.Println("  witness video -o tutorial.mp4 -scale 0.5 -scale-mode nearest")
+		fmt.Println("  witness video -o tutorial.mp4 -preset full-tutorial")
+		fmt.Println("  witness video -format webm -o tutorial.webm")
+		fmt.Println("  witness video -o tutorial.mp4 -ffmpeg-args '-c:v libx265 -crf {crf} -tag:v hvc1 {output}'")
+		fmt.Println("  witness video -format y4m -o - | ffmpeg -i - -c:v libx265 out.mp4")
+		fmt.Println("  witness video -format mjpeg -o - | ffplay -f mjpeg -")
+		fmt.Println("  witness video -region demo -format png -o frames/   # One PNG per frame, with timings.json")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	envDefault(fs, "vnc-password", "WITNESS_VNC_PASSWORD")
+	envDefault(fs, "rdp-user", "WITNESS_RDP_USER")
+	envDefault(fs, "rdp-password", "WITNESS_RDP_PASSWORD")
+
+	if *listQualities {
+		printQualities()
+		return
+	}
+
+	if err := applyPreset(fs, *presetName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if writesToStdout(*output) {
+		status = os.Stderr
+	}
+
+	if *output == "" {
+		fmt.Fprintf(os.Stderr, "Error: -o is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if err := checkConsent(*requireConsent); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fps, err := capture.ParseFPS(*fpsStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	captureFPS, err := parseCaptureFPS(*captureFPSStr, fps)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	q, err := encoder.ParseQuality(*quality)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var bitrate int
+	if *bitrateStr != "" {
+		if bitrate, err = encoder.ParseBitrate(*bitrateStr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *keyframeInterval < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -keyframe-interval must not be negative\n")
+		os.Exit(1)
+	}
+	profile, err := encoder.ParseH264Profile(*profileStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch *format {
+	case "mp4", "webm", "av1", "y4m", "rawvideo", "mjpeg", "png":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unsupported format %q (want mp4, webm, av1, y4m, rawvideo, mjpeg, or png)\n", *format)
+		os.Exit(1)
+	}
+
+	// webm, av1, and custom arguments are encoded by an ffmpeg child process
+	useFFmpeg := *format == "webm" || *format == "av1" || (*format == "mp4" && *ffmpegArgs != "")
+	if *ffmpegArgs != "" && !useFFmpeg {
+		fmt.Fprintf(os.Stderr, "Error: -ffmpeg-args needs -format mp4, webm, or av1\n")
+		os.Exit(1)
+	}
+	if useFFmpeg && (*roi || writesToStdout(*output)) {
+		fmt.Fprintf(os.Stderr, "Error: -roi and -o - are only supported for mp4 without -ffmpeg-args\n")
+		os.Exit(1)
+	}
+
+	if *denoiseTol > 255 {
+		fmt.Fprintf(os.Stderr, "Error: -denoise-tolerance must be between 0 and 255, got %d\n", *denoiseTol)
+		os.Exit(1)
+	}
+
+	scaling, err := parseScale(*scaleFactor, *scaleMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	hidpiMode, err := capture.ParseScaleMode(*hidpi)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *maxDuration < 0 || *maxFrames < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -d and -max-frames must not be negative\n")
+		os.Exit(1)
+	}
+
+	if !(*captureScale > 0 && *captureScale <= 1) {
+		fmt.Fprintf(os.Stderr, "Error: -capture-scale must be above 0 and at most 1, got %g\n", *captureScale)
+		os.Exit(1)
+	}
+
+	if *heatmapPath != "" && !strings.EqualFold(filepath.Ext(*heatmapPath), ".png") {
+		fmt.Fprintf(os.Stderr, "Error: -heatmap must be a .png file, got %q\n", *heatmapPath)
+		os.Exit(1)
+	}
+
+	start, err := schedule.Start(*startAt, *startAfter, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *idleSkip < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -idle-skip must not be negative, got %v\n", *idleSkip)
+		os.Exit(1)
+	}
+
+	region, err := resolveRegion(*regionStr, *regionName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	window, err := resolveWindow(*windowStr, region)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	remote, err := resolveRemoteDesktop(*vncAddr, *vncPassword, *rdpAddr, *rdpSize, capture.RDPOptions{
+		User:         *rdpUser,
+		Password:     *rdpPassword,
+		Fingerprint:  *rdpCert,
+		AllowTLSOnly: *rdpAllowTLSOnly,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if remote.addr != "" && window != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s cannot be combined with -window\n", remote.flag)
+		os.Exit(1)
+	}
+	if (len(exclude) > 0 || *app != "") && (window != nil || remote.addr != "") {
+		fmt.Fprintf(os.Stderr, "Error: -exclude and -app cannot be combined with -window, -vnc, or -rdp\n")
+		os.Exit(1)
+	}
+
+	*output, releaseOutput, err = resolveOutput(*output, *outDir, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	if *requireConsent {
+		confirmConsent(*consentMessage, region, *output)
+	}
+
+	recConfig := recorder.DefaultConfig(capture.Config{Region: region, Window: window, Exclude: exclude, App: *app, FPS: captureFPS, Display: *display, IncludeCursor: *cursor, ScaleMode: hidpiMode, Scale: *captureScale, MaxDuration: *maxDuration, MaxFrames: *maxFrames})
+	recConfig.PauseWhen, recConfig.StopWhen, err = recordingConditions(*pinSpace, *pauseWindow, *stopOnLock && remote.addr == "", *stopFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	waitForStart(start, *countdown)
+
+	clicks, err := watchClicks(*showClicks, region, *display, window, remote.addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	follow, err := followPointer(*followSize, *stabilize, region, *display, window, remote.addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	webcam, err := startWebcam(*webcamOn, *webcamDevice, *webcamCorner, *webcamSize, captureFPS)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	heatmap, err := startHeatmap(*heatmapPath, *force, &clicks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	var sink videoSink
+	if useFFmpeg {
+		ff, _ := encoder.ParseFFmpegFormat(*format)
+		enc, err := encoder.NewFFmpegEncoder(*output, fps, q, ff)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		if *ffmpegArgs != "" {
+			enc.SetArgs(strings.Fields(*ffmpegArgs))
+		}
+		sink = enc
+	} else if *format == "mp4" {
+		enc, err := encoder.NewVideoEncoder(*output, fps, q)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		if writesToStdout(*output) {
+			enc.SetOutput(os.Stdout)
+		}
+		if *roi {
+			enc.SetROI(encoder.DefaultROIConfig())
+			// The sharp area follows the pointer where its position maps
+			// onto the frames
+			if window == nil && remote.addr == "" && *followSize == "" {
+				if _, err := capture.PointerPosition(); err == nil {
+					if area, err := displayArea(region, *display); err == nil {
+						enc.SetROICursor(capture.PointerPosition, area)
+					}
+				}
+			}
+		}
+		enc.SetBitrate(bitrate)
+		enc.SetKeyframeInterval(*keyframeInterval)
+		enc.SetProfile(profile)
+		sink = enc
+	} else {
+		sink, err = newStreamSink(*format, *output, fps)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+	}
+
+	frames, flush := downsample(clicks.wrap(follow.wrap(webcam.wrap(skipIdle(denoise(rescale(sink, scaling), *denoiseOn, *denoiseTol), *idleSkip)))), captureFPS, fps)
+	frames, closeCapture, err := saveCapture(*saveCapturePath, captureFPS, frames, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	rec := newRecorder(recConfig, frames, remote)
+	heatmap.watch(rec)
+	recording, err := audit.StartRecording(region, *output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	err = record(rec)
+	clicks.stop()
+	webcam.stop()
+	if flushErr := flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := closeCapture(); err == nil {
+		err = closeErr
+	}
+	if sink.FrameCount() > 0 {
+		fmt.Fprintf(status, "Finishing %d frames...\n", sink.FrameCount())
+	}
+	if closeErr := sink.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil && sink.FrameCount() == 0 && !writesToStdout(*output) {
+		// Don't leave an empty stream file behind
+		os.Remove(*output)
+	}
+	if auditErr := recording.Stop(err); auditErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", auditErr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	heatmap.save()
+
+	summary := fmt.Sprintf("%d frames, %s", sink.FrameCount(), fps.FrameTime(sink.FrameCount()).Round(100*time.Millisecond))
+	if info, err := os.Stat(*output); err == nil {
+		summary += ", " + formatBytes(info.Size())
+	}
+	fmt.Fprintf(status, "✓ Saved %s (%s)\n", displayName(*output), summary)
+}
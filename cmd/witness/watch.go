@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ericmhalvorsen/witness/pkg/config"
+	"github.com/ericmhalvorsen/witness/pkg/schedule"
+)
+
+func handleWatch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	app := fs.String("app", "", "Record while this application is frontmost (name or part of it, ignoring case)")
+	output := fs.String("o", "", "Output file name for each session, .gif or .mp4 (default: <app>-{date}-{time}.mp4)")
+	regionName := fs.String("region", "", "Use a saved region by name")
+	regionStr := fs.String("r", "", "Capture region (x,y,w,h)")
+	fpsStr := fs.String("f", "", "Frames per second (default 15)")
+	quality := fs.String("q", "medium", "Quality level (low, medium, high)")
+	list := fs.Bool("list", false, "List watched applications")
+	remove := fs.String("remove", "", "Stop watching this application")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: witness watch [options]")
+		fmt.Println("\nRecord whenever an application is frontmost. While witness serve is running,")
+		fmt.Println("a recording starts each time the application comes to the front and is saved")
+		fmt.Println("when it loses focus, one file per session in the daemon's output directory.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+		fmt.Println("\nExamples:")
+		fmt.Println("  witness watch -app Figma")
+		fmt.Println("  witness watch -app Sketch -region canvas -o sketch-{date}-{time}.gif")
+		fmt.Println("  witness watch -list")
+		fmt.Println("  witness watch -remove Figma")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	modes := 0
+	for _, set := range []bool{*app != "", *list, *remove != ""} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		fmt.Fprintln(os.Stderr, "Error: use one of -app, -list, or -remove")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *regionName != "" && *regionStr != "" {
+		fmt.Fprintln(os.Stderr, "Error: use either -region or -r, not both")
+		os.Exit(1)
+	}
+
+	var err error
+	switch {
+	case *list:
+		err = listWatches()
+	case *remove != "":
+		if err = removeWatch(*remove); err == nil {
+			fmt.Printf("✓ No longer watching %s\n", *remove)
+		}
+	default:
+		entry := config.Watch{
+			App:     *app,
+			Output:  *output,
+			Region:  *regionName + *regionStr,
+			FPS:     *fpsStr,
+			Quality: *quality,
+		}
+		if entry.Output == "" {
+			entry.Output = watchOutputName(*app)
+		}
+		if err = addWatch(entry); err == nil {
+			fmt.Printf("✓ Watching %s: each session is saved as %s\n", entry.App, entry.Output)
+			fmt.Println("  Recordings run while witness serve is running")
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// watchOutputName is the default session file name for an application,
+// e.g. figma-{date}-{time}.mp4 for Figma
+func watchOutputName(app string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, strings.TrimSpace(app))
+	if name = strings.Trim(name, "-"); name == "" {
+		name = "watch"
+	}
+	return name + "-{date}-{time}.mp4"
+}
+
+// addWatch checks a watch rule and saves it to the config file, replacing
+// any for the same application
+func addWatch(entry config.Watch) error {
+	rule, err := schedule.NewWatchRule(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := rule.ResolveRegion(); err != nil {
+		return err
+	}
+
+	settings, err := config.Load()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i, w := range settings.Watches {
+		if strings.EqualFold(w.App, entry.App) {
+			settings.Watches[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		settings.Watches = append(settings.Watches, entry)
+	}
+	return config.Save(settings)
+}
+
+// removeWatch deletes the watch rule for app
+func removeWatch(app string) error {
+	settings, err := config.Load()
+	if err != nil {
+		return err
+	}
+	for i, w := range settings.Watches {
+		if strings.EqualFold(w.App, app) {
+			settings.Watches = append(settings.Watches[:i], settings.Watches[i+1:]...)
+			return config.Save(settings)
+		}
+	}
+	return fmt.Errorf("%s is not being watched", app)
+}
+
+// listWatches prints the watched applications
+func listWatches() error {
+	settings, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if len(settings.Watches) == 0 {
+		fmt.Println("No applications are watched. Add one with: witness watch -app Figma")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APP\tOUTPUT\tREGION")
+	for _, watch := range settings.Watches {
+		region := watch.Region
+		if region == "" {
+			region = "full screen"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", watch.App, watch.Output, region)
+	}
+	return w.Flush()
+}
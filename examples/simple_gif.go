@@ -1,3 +1,4 @@
+//go:build darwin
 // +build darwin
 
 // This example demonstrates basic screen capture and GIF encoding
@@ -26,9 +27,9 @@ func main() {
 
 	// Configure capture
 	config := capture.Config{
-		FPS:       15,      // 15 FPS for smaller file size
-		DisplayID: 0,       // Main display
-		Region:    nil,     // Full screen
+		FPS:       capture.FPS15, // 15 FPS for smaller file size
+		DisplayID: 0,             // Main display
+		Region:    nil,           // Full screen
 	}
 
 	// Create capturer
@@ -39,7 +40,7 @@ func main() {
 
 	// Create GIF encoder
 	outputPath := "output.gif"
-	gifEncoder := encoder.NewGIFEncoder(outputPath, config.FPS, encoder.QualityMedium)
+	gifEncoder := encoder.NewGIFEncoderFPS(outputPath, config.FPS, encoder.QualityMedium)
 
 	// Start capture
 	fmt.Println("Starting capture...")
@@ -57,6 +58,10 @@ func main() {
 
 	go func() {
 		for frame := range capturer.Frames() {
+			if err := frame.Load(); err != nil {
+				log.Printf("Failed to load frame: %v", err)
+				continue
+			}
 			if err := gifEncoder.AddFrame(frame); err != nil {
 				log.Printf("Failed to add frame: %v", err)
 				continue
@@ -65,7 +70,7 @@ func main() {
 			frameCount++
 			if frameCount%15 == 0 {
 				fmt.Printf("Captured %d frames (%.1f seconds)\n",
-					frameCount, float64(frameCount)/float64(config.FPS))
+					frameCount, float64(frameCount)/config.FPS.Float64())
 			}
 
 			if frameCount >= maxFrames {
@@ -0,0 +1,27 @@
+#ifndef WITNESS_CLICK_TAP_H
+#define WITNESS_CLICK_TAP_H
+
+#include <stdint.h>
+
+typedef struct ClickTap ClickTap;
+
+// createClickTap creates a listen-only event tap for mouse button presses,
+// and left button releases too if releases is nonzero. Each event is passed
+// to the Go clickTapEvent callback along with handle. It returns NULL when
+// the process lacks the Input Monitoring permission.
+ClickTap *createClickTap(uintptr_t handle, int releases);
+
+// runClickTap delivers clicks on the calling thread until stopClickTap
+void runClickTap(ClickTap *tap);
+
+// stopClickTap makes runClickTap return within a quarter of a second
+void stopClickTap(ClickTap *tap);
+
+// freeClickTap releases a tap once runClickTap has returned
+void freeClickTap(ClickTap *tap);
+
+// pointerLocation stores the pointer position in global points. It returns
+// 0 when the position cannot be read.
+int pointerLocation(double *x, double *y);
+
+#endif
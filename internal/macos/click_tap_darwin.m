@@ -0,0 +1,98 @@
+#include "click_tap.h"
+
+#include <ApplicationServices/ApplicationServices.h>
+#include <stdatomic.h>
+#include <stdlib.h>
+
+#include "_cgo_export.h"
+
+struct ClickTap {
+	uintptr_t handle;
+	CFMachPortRef port;
+	CFRunLoopSourceRef source;
+	atomic_int stopped;
+};
+
+static CGEventRef clickTapCallback(CGEventTapProxy proxy, CGEventType type, CGEventRef event, void *info) {
+	ClickTap *tap = info;
+	switch (type) {
+	case kCGEventTapDisabledByTimeout:
+	case kCGEventTapDisabledByUserInput:
+		CGEventTapEnable(tap->port, true);
+		break;
+	case kCGEventLeftMouseDown:
+	case kCGEventRightMouseDown:
+	case kCGEventOtherMouseDown: {
+		CGPoint location = CGEventGetLocation(event);
+		clickTapEvent(tap->handle, location.x, location.y, 1);
+		break;
+	}
+	case kCGEventLeftMouseUp: {
+		CGPoint location = CGEventGetLocation(event);
+		clickTapEvent(tap->handle, location.x, location.y, 0);
+		break;
+	}
+	default:
+		break;
+	}
+	return event;
+}
+
+ClickTap *createClickTap(uintptr_t handle, int releases) {
+	ClickTap *tap = calloc(1, sizeof(ClickTap));
+	tap->handle = handle;
+
+	CGEventMask mask = CGEventMaskBit(kCGEventLeftMouseDown) |
+		CGEventMaskBit(kCGEventRightMouseDown) |
+		CGEventMaskBit(kCGEventOtherMouseDown);
+	if (releases) {
+		mask |= CGEventMaskBit(kCGEventLeftMouseUp);
+	}
+	tap->port = CGEventTapCreate(kCGSessionEventTap, kCGHeadInsertEventTap,
+		kCGEventTapOptionListenOnly, mask, clickTapCallback, tap);
+	if (tap->port == NULL) {
+		free(tap);
+		return NULL;
+	}
+	tap->source = CFMachPortCreateRunLoopSource(NULL, tap->port, 0);
+	return tap;
+}
+
+void runClickTap(ClickTap *tap) {
+	CFRunLoopRef loop = CFRunLoopGetCurrent();
+	CFRunLoopAddSource(loop, tap->source, kCFRunLoopDefaultMode);
+	CGEventTapEnable(tap->port, true);
+
+	// Polling the flag avoids racing CFRunLoopStop against the loop starting
+	while (!atomic_load(&tap->stopped)) {
+		CFRunLoopRunInMode(kCFRunLoopDefaultMode, 0.25, false);
+	}
+
+	CGEventTapEnable(tap->port, false);
+	CFRunLoopRemoveSource(loop, tap->source, kCFRunLoopDefaultMode);
+}
+
+void stopClickTap(ClickTap *tap) {
+	atomic_store(&tap->stopped, 1);
+}
+
+void freeClickTap(ClickTap *tap) {
+	CFRelease(tap->source);
+	CFMachPortInvalidate(tap->port);
+	CFRelease(tap->port);
+	free(tap);
+}
+
+int pointerLocation(double *x, double *y) {
+	// A fresh event carries the current pointer position and, unlike an
+	// event tap, needs no permission
+	CGEventRef event = CGEventCreate(NULL);
+	if (event == NULL) {
+		return 0;
+	}
+	CGPoint location = CGEventGetLocation(event);
+	CFRelease(event);
+	*x = location.x;
+	*y = location.y;
+	return 1;
+}
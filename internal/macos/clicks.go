@@ -0,0 +1,149 @@
+//go:build darwin
+// +build darwin
+
+package macos
+
+/*
+#cgo LDFLAGS: -framework ApplicationServices -framework CoreFoundation
+
+#include "click_tap.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"runtime"
+	"runtime/cgo"
+	"sync"
+	"time"
+)
+
+// Click is a mouse button press or release
+type Click struct {
+	// X and Y are the pointer position in global points
+	X, Y int
+
+	// Time is when the event happened
+	Time time.Time
+}
+
+// ClickTap watches mouse clicks with a listen-only CGEventTap, which sees
+// clicks in every app without changing them
+type ClickTap struct {
+	tap      *C.ClickTap
+	handle   cgo.Handle
+	clicks   chan Click
+	releases chan Click    // nil unless watching drags
+	done     chan struct{} // Closed when the tap's run loop returns
+	stopped  bool
+	mu       sync.Mutex
+}
+
+// WatchClicks starts an event tap on its own thread
+func WatchClicks() (*ClickTap, error) {
+	return watchClicks(false)
+}
+
+// WatchDrags is like WatchClicks, and also reports where the left button is
+// released, so a drag can be followed from press to release without
+// reading any other app's state
+func WatchDrags() (*ClickTap, error) {
+	return watchClicks(true)
+}
+
+// watchClicks starts an event tap, reporting left button releases too if
+// releases is set
+func watchClicks(releases bool) (*ClickTap, error) {
+	t := &ClickTap{
+		clicks: make(chan Click, 16),
+		done:   make(chan struct{}),
+	}
+	flag := 0
+	if releases {
+		t.releases = make(chan Click, 16)
+		flag = 1
+	}
+	t.handle = cgo.NewHandle(t)
+	t.tap = C.createClickTap(C.uintptr_t(t.handle), C.int(flag))
+	if t.tap == nil {
+		t.handle.Delete()
+		return nil, errors.New("failed to watch mouse clicks; allow Witness under System Settings > Privacy & Security > Input Monitoring")
+	}
+
+	go func() {
+		// The tap's run loop belongs to this thread
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(t.done)
+		C.runClickTap(t.tap)
+	}()
+
+	return t, nil
+}
+
+// Clicks returns the channel clicks arrive on
+func (t *ClickTap) Clicks() <-chan Click {
+	return t.clicks
+}
+
+// Releases returns the channel left button releases arrive on, which is nil
+// unless the tap was started by WatchDrags
+func (t *ClickTap) Releases() <-chan Click {
+	return t.releases
+}
+
+// Stop removes the event tap
+func (t *ClickTap) Stop() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopped {
+		return ErrStopped
+	}
+	t.stopped = true
+
+	C.stopClickTap(t.tap)
+	<-t.done
+	C.freeClickTap(t.tap)
+	t.handle.Delete()
+	close(t.clicks)
+	if t.releases != nil {
+		close(t.releases)
+	}
+
+	return nil
+}
+
+// PointerPosition returns the pointer position in global points
+func PointerPosition() (image.Point, error) {
+	var x, y C.double
+	if C.pointerLocation(&x, &y) == 0 {
+		return image.Point{}, fmt.Errorf("failed to read the pointer position")
+	}
+	return image.Pt(int(math.Round(float64(x))), int(math.Round(float64(y)))), nil
+}
+
+// clickTapEvent is called on the tap's thread for each button press, and
+// each left button release when watching drags, with the pointer position
+// in global points. Events are dropped rather than stalling input when
+// nobody is reading them.
+//
+//export clickTapEvent
+func clickTapEvent(handle C.uintptr_t, x, y C.double, pressed C.int) {
+	t := cgo.Handle(handle).Value().(*ClickTap)
+	click := Click{
+		X:    int(math.Round(float64(x))),
+		Y:    int(math.Round(float64(y))),
+		Time: time.Now(),
+	}
+	events := t.clicks
+	if pressed == 0 {
+		events = t.releases
+	}
+	select {
+	case events <- click:
+	default:
+	}
+}
@@ -1,217 +1,141 @@
+//go:build darwin
 // +build darwin
 
 package macos
 
 /*
 #cgo CFLAGS: -x objective-c
-#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation -framework CoreVideo
+#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation -framework CoreVideo -framework IOSurface -framework CoreMedia -framework Foundation
 
 #include <CoreGraphics/CoreGraphics.h>
 #include <CoreFoundation/CoreFoundation.h>
+#include <IOSurface/IOSurface.h>
 #include <stdlib.h>
 
-// Forward declarations
-void frameAvailableCallback(void *userInfo, void *frameData);
-
-// Helper function to create a display stream
-// We'll implement this to capture frames from the display
-static CGDisplayStreamRef createDisplayStream(CGDirectDisplayID displayID, size_t width, size_t height, void *userInfo) {
-	// Dictionary for output properties
-	CFDictionaryRef properties = NULL;
-
-	// Create the display stream
-	// Using kCVPixelFormatType_32BGRA for RGBA format
-	CGDisplayStreamRef stream = CGDisplayStreamCreate(
-		displayID,
-		width,
-		height,
-		'BGRA',  // kCVPixelFormatType_32BGRA
-		properties,
-		NULL  // We'll set up the callback handler in Go
-	);
-
-	return stream;
-}
-
+#include "display_stream.h"
+#include "filtered_stream.h"
 */
 import "C"
 import (
-	"fmt"
-	"image"
+	"errors"
+	"runtime/cgo"
 	"time"
 	"unsafe"
-
-	"github.com/ericmhalvorsen/witness/pkg/capture"
 )
 
-// DisplayCapturer captures frames from macOS displays using CGDisplayStream
-type DisplayCapturer struct {
-	config      capture.Config
-	stream      C.CGDisplayStreamRef
-	frames      chan *capture.Frame
-	errors      chan error
-	stopChan    chan struct{}
-	isRunning   bool
-	displayID   C.CGDirectDisplayID
-	displayBounds C.CGRect
-}
-
-// NewDisplayCapturer creates a new macOS display capturer
-func NewDisplayCapturer(config capture.Config) (*DisplayCapturer, error) {
-	// Get the display ID (0 = main display)
-	displayID := C.CGDirectDisplayID(config.DisplayID)
-	if displayID == 0 {
-		displayID = C.CGMainDisplayID()
-	}
-
-	// Get display bounds
-	bounds := C.CGDisplayBounds(displayID)
-
-	capturer := &DisplayCapturer{
-		config:        config,
-		displayID:     displayID,
-		displayBounds: bounds,
-		frames:        make(chan *capture.Frame, 30), // Buffer 30 frames
-		errors:        make(chan error, 10),
-		stopChan:      make(chan struct{}),
-		isRunning:     false,
-	}
-
-	return capturer, nil
-}
+// StreamOptions configures a display stream
+type StreamOptions struct {
+	// Width and Height are the frame size in pixels; the stream scales the
+	// display to fit
+	Width, Height int
 
-// Start begins the capture process
-func (d *DisplayCapturer) Start() error {
-	if d.isRunning {
-		return fmt.Errorf("capturer already running")
-	}
+	// MinFrameTime limits how often frames are delivered
+	MinFrameTime time.Duration
 
-	// Determine capture dimensions
-	width := C.size_t(d.displayBounds.size.width)
-	height := C.size_t(d.displayBounds.size.height)
+	// ShowCursor draws the mouse pointer into frames
+	ShowCursor bool
 
-	if d.config.Region != nil {
-		width = C.size_t(d.config.Region.Width)
-		height = C.size_t(d.config.Region.Height)
-	}
-
-	// Create the display stream
-	// TODO: Implement the actual callback mechanism
-	// For now, we'll create a basic stream
-	d.stream = C.createDisplayStream(d.displayID, width, height, nil)
-	if d.stream == nil {
-		return fmt.Errorf("failed to create display stream")
-	}
+	// Exclude leaves the windows with these IDs out of the frames, showing
+	// what is behind them instead
+	Exclude []uint32
 
-	d.isRunning = true
-
-	// Start capture loop
-	go d.captureLoop()
+	// App limits the frames to the windows of applications whose name
+	// contains App, ignoring case; everything else is black
+	App string
+}
 
-	return nil
+// DisplayStream delivers a display's frames as they change, using
+// CGDisplayStream. When windows are excluded or the frames limited to one
+// application, it uses ScreenCaptureKit instead, from macOS 12.3.
+type DisplayStream struct {
+	stream   C.CGDisplayStreamRef
+	filtered *C.FilteredStream
+	handle   cgo.Handle
+	frame    func(*Surface)
+	done     chan struct{} // Closed when the stream reports it stopped
 }
 
-// Stop ends the capture process
-func (d *DisplayCapturer) Stop() error {
-	if !d.isRunning {
-		return fmt.Errorf("capturer not running")
+// StartDisplayStream starts streaming a display. frame is called on the
+// stream's dispatch queue with each changed frame, holding one reference
+// to it that it must release.
+func StartDisplayStream(display uint32, o StreamOptions, frame func(*Surface)) (*DisplayStream, error) {
+	s := &DisplayStream{frame: frame, done: make(chan struct{})}
+	s.handle = cgo.NewHandle(s)
+
+	id := C.CGDirectDisplayID(display)
+	width, height := C.size_t(o.Width), C.size_t(o.Height)
+	minFrameTime := C.double(o.MinFrameTime.Seconds())
+	showCursor := C.int(0)
+	if o.ShowCursor {
+		showCursor = 1
 	}
 
-	// Signal stop
-	close(d.stopChan)
-
-	// Stop the display stream
-	if d.stream != nil {
-		C.CGDisplayStreamStop(d.stream)
-		d.stream = nil
+	if len(o.Exclude) > 0 || o.App != "" {
+		var excluded *C.uint32_t
+		if len(o.Exclude) > 0 {
+			excluded = (*C.uint32_t)(unsafe.Pointer(&o.Exclude[0]))
+		}
+		var app *C.char
+		if o.App != "" {
+			app = C.CString(o.App)
+			defer C.free(unsafe.Pointer(app))
+		}
+		s.filtered = C.createFilteredStream(id, width, height, minFrameTime, showCursor,
+			excluded, C.int(len(o.Exclude)), app, C.uintptr_t(s.handle))
+		if s.filtered == nil {
+			s.handle.Delete()
+			return nil, errors.New("failed to create filtered display stream (needs macOS 12.3 or later)")
+		}
+		return s, nil
 	}
 
-	d.isRunning = false
-	close(d.frames)
-	close(d.errors)
-
-	return nil
-}
-
-// Frames returns the channel for captured frames
-func (d *DisplayCapturer) Frames() <-chan *capture.Frame {
-	return d.frames
-}
-
-// Errors returns the channel for errors
-func (d *DisplayCapturer) Errors() <-chan error {
-	return d.errors
+	s.stream = C.createDisplayStream(id, width, height, minFrameTime, showCursor, C.uintptr_t(s.handle))
+	if s.stream == 0 {
+		s.handle.Delete()
+		return nil, errors.New("failed to create display stream")
+	}
+	return s, nil
 }
 
-// captureLoop is the main capture loop
-// This is a placeholder - we'll implement the actual CGDisplayStream callback mechanism
-func (d *DisplayCapturer) captureLoop() {
-	ticker := time.NewTicker(time.Second / time.Duration(d.config.FPS))
-	defer ticker.Stop()
-
-	for {
+// Stop stops the stream. The handle stays valid until the stream confirms
+// it has stopped, since a late callback would otherwise use a deleted
+// handle.
+func (s *DisplayStream) Stop() {
+	if s.stream != 0 {
+		C.CGDisplayStreamStop(s.stream)
 		select {
-		case <-d.stopChan:
-			return
-		case <-ticker.C:
-			// TODO: Implement actual frame capture
-			// For now, this is a placeholder that would capture via CGDisplayCreateImage
-			frame := d.captureFrame()
-			if frame != nil {
-				d.frames <- frame
-			}
+		case <-s.done:
+			s.handle.Delete()
+		case <-time.After(time.Second):
 		}
+		C.CFRelease(C.CFTypeRef(s.stream))
+		s.stream = 0
 	}
-}
-
-// captureFrame captures a single frame using CGDisplayCreateImage
-// This is a simpler approach than CGDisplayStream but less efficient
-// We'll upgrade this to use CGDisplayStream's callback mechanism later
-func (d *DisplayCapturer) captureFrame() *capture.Frame {
-	// Capture the display
-	imageRef := C.CGDisplayCreateImage(d.displayID)
-	if imageRef == 0 {
-		d.errors <- fmt.Errorf("failed to capture display image")
-		return nil
-	}
-	defer C.CGImageRelease(imageRef)
-
-	// Get image dimensions
-	width := int(C.CGImageGetWidth(imageRef))
-	height := int(C.CGImageGetHeight(imageRef))
-
-	// Create RGBA image
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-
-	// TODO: Copy pixel data from CGImage to image.RGBA
-	// This requires creating a bitmap context and drawing the image
-	// For now, we'll implement a basic version
-
-	// Create a bitmap context
-	colorSpace := C.CGColorSpaceCreateDeviceRGB()
-	defer C.CGColorSpaceRelease(colorSpace)
-
-	context := C.CGBitmapContextCreate(
-		unsafe.Pointer(&img.Pix[0]),
-		C.size_t(width),
-		C.size_t(height),
-		8, // bits per component
-		C.size_t(img.Stride),
-		colorSpace,
-		C.kCGImageAlphaPremultipliedLast,
-	)
-	if context == 0 {
-		d.errors <- fmt.Errorf("failed to create bitmap context")
-		return nil
+	if s.filtered != nil {
+		C.stopFilteredStream(s.filtered)
+		select {
+		case <-s.done:
+			s.handle.Delete()
+		case <-time.After(time.Second):
+		}
+		s.filtered = nil
 	}
-	defer C.CGContextRelease(context)
+}
 
-	// Draw the image into the context
-	rect := C.CGRectMake(0, 0, C.CGFloat(width), C.CGFloat(height))
-	C.CGContextDrawImage(context, rect, imageRef)
+// displayStreamFrame is called on the stream's dispatch queue with the
+// retained surface of each changed frame
+//
+//export displayStreamFrame
+func displayStreamFrame(handle C.uintptr_t, ref C.IOSurfaceRef) {
+	s := cgo.Handle(handle).Value().(*DisplayStream)
+	s.frame(newSurface(ref))
+}
 
-	return &capture.Frame{
-		Image:     img,
-		Timestamp: time.Now(),
-	}
+// displayStreamStopped is called once the stream has stopped delivering
+// frames
+//
+//export displayStreamStopped
+func displayStreamStopped(handle C.uintptr_t) {
+	s := cgo.Handle(handle).Value().(*DisplayStream)
+	close(s.done)
 }
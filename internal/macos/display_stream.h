@@ -0,0 +1,15 @@
+#ifndef WITNESS_DISPLAY_STREAM_H
+#define WITNESS_DISPLAY_STREAM_H
+
+#include <CoreGraphics/CoreGraphics.h>
+#include <stdint.h>
+
+// createDisplayStream starts a stream of BGRA frames from a display. Each
+// changed frame's IOSurface is retained and passed to the Go
+// displayStreamFrame callback along with handle, which must release it.
+// minFrameTime limits how often frames are delivered, in seconds, and
+// showCursor draws the mouse pointer into frames.
+CGDisplayStreamRef createDisplayStream(CGDirectDisplayID displayID, size_t width, size_t height,
+	double minFrameTime, int showCursor, uintptr_t handle);
+
+#endif
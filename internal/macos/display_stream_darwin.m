@@ -0,0 +1,52 @@
+#include "display_stream.h"
+
+#include <IOSurface/IOSurface.h>
+#include <dispatch/dispatch.h>
+
+#include "_cgo_export.h"
+
+CGDisplayStreamRef createDisplayStream(CGDirectDisplayID displayID, size_t width, size_t height,
+	double minFrameTime, int showCursor, uintptr_t handle) {
+	CFNumberRef frameTime = CFNumberCreate(NULL, kCFNumberDoubleType, &minFrameTime);
+	const void *keys[] = {kCGDisplayStreamMinimumFrameTime, kCGDisplayStreamShowCursor};
+	const void *values[] = {frameTime, showCursor ? kCFBooleanTrue : kCFBooleanFalse};
+	CFDictionaryRef properties = CFDictionaryCreate(NULL, keys, values, 2,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFRelease(frameTime);
+
+	dispatch_queue_t queue = dispatch_queue_create("witness.capture", DISPATCH_QUEUE_SERIAL);
+
+	CGDisplayStreamRef stream = CGDisplayStreamCreateWithDispatchQueue(
+		displayID,
+		width,
+		height,
+		'BGRA', // kCVPixelFormatType_32BGRA
+		properties,
+		queue,
+		^(CGDisplayStreamFrameStatus status, uint64_t displayTime,
+		  IOSurfaceRef surface, CGDisplayStreamUpdateRef update) {
+			if (status == kCGDisplayStreamFrameStatusStopped) {
+				displayStreamStopped(handle);
+				return;
+			}
+			// Idle and blank statuses mean nothing changed on screen
+			if (status != kCGDisplayStreamFrameStatusFrameComplete || surface == NULL) {
+				return;
+			}
+
+			// Hold the surface rather than copy it; Go releases it once a
+			// newer frame replaces it
+			CFRetain(surface);
+			IOSurfaceIncrementUseCount(surface);
+			displayStreamFrame(handle, surface);
+		});
+
+	CFRelease(properties);
+	dispatch_release(queue);
+
+	if (stream != NULL && CGDisplayStreamStart(stream) != kCGErrorSuccess) {
+		CFRelease(stream);
+		return NULL;
+	}
+	return stream;
+}
@@ -0,0 +1,157 @@
+//go:build darwin
+// +build darwin
+
+package macos
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework CoreGraphics -framework AppKit -framework ApplicationServices
+
+#include <ApplicationServices/ApplicationServices.h>
+#include <CoreGraphics/CoreGraphics.h>
+#include <AppKit/AppKit.h>
+
+#define MAX_DISPLAYS 32
+#define MAX_DISPLAY_NAME 128
+#define MAX_DISPLAY_UUID 64
+
+// activeDisplays fills ids with the active displays and returns how many
+// there are, or -1 on failure
+static int activeDisplays(CGDirectDisplayID *ids) {
+	uint32_t count = 0;
+	if (CGGetActiveDisplayList(MAX_DISPLAYS, ids, &count) != kCGErrorSuccess) {
+		return -1;
+	}
+	return (int)count;
+}
+
+// displayName copies the name macOS shows for a display, or an empty string
+static void displayName(CGDirectDisplayID id, char *out) {
+	out[0] = 0;
+	@autoreleasepool {
+		for (NSScreen *screen in [NSScreen screens]) {
+			NSNumber *number = screen.deviceDescription[@"NSScreenNumber"];
+			if (number.unsignedIntValue != id) {
+				continue;
+			}
+			if (@available(macOS 10.15, *)) {
+				[screen.localizedName getCString:out maxLength:MAX_DISPLAY_NAME encoding:NSUTF8StringEncoding];
+			}
+			return;
+		}
+	}
+}
+
+// displayUUID copies the display's persistent UUID, or an empty string
+static void displayUUID(CGDirectDisplayID id, char *out) {
+	out[0] = 0;
+	CFUUIDRef uuid = CGDisplayCreateUUIDFromDisplayID(id);
+	if (uuid == NULL) {
+		return;
+	}
+	CFStringRef s = CFUUIDCreateString(NULL, uuid);
+	if (s != NULL) {
+		CFStringGetCString(s, out, MAX_DISPLAY_UUID, kCFStringEncodingUTF8);
+		CFRelease(s);
+	}
+	CFRelease(uuid);
+}
+*/
+import "C"
+import "fmt"
+
+// DisplayInfo describes an active display
+type DisplayInfo struct {
+	// ID is the Core Graphics display ID
+	ID uint32
+
+	// Bounds is the display rectangle
+	Bounds Rect
+
+	// ScaleFactor is the number of physical pixels per point
+	ScaleFactor float64
+
+	// Name is the name System Settings shows for the display
+	Name string
+
+	// UUID is the display's persistent identifier
+	UUID string
+
+	// Main is true for the display with the menu bar
+	Main bool
+}
+
+// MainDisplay returns the ID of the display with the menu bar
+func MainDisplay() uint32 {
+	return uint32(C.CGMainDisplayID())
+}
+
+// LookupDisplay returns the geometry of a display, leaving its name and
+// UUID empty, or false if it is not connected
+func LookupDisplay(id uint32) (DisplayInfo, bool) {
+	cgID := C.CGDirectDisplayID(id)
+	if C.CGDisplayIsOnline(cgID) == 0 {
+		return DisplayInfo{}, false
+	}
+	return displayGeometry(cgID), true
+}
+
+// ListDisplays returns the active displays, main display first
+func ListDisplays() ([]DisplayInfo, error) {
+	ids := make([]C.CGDirectDisplayID, C.MAX_DISPLAYS)
+	n := int(C.activeDisplays(&ids[0]))
+	if n < 0 {
+		return nil, fmt.Errorf("failed to list displays")
+	}
+
+	main := C.CGMainDisplayID()
+	var name [C.MAX_DISPLAY_NAME]C.char
+	var uuid [C.MAX_DISPLAY_UUID]C.char
+
+	displays := make([]DisplayInfo, 0, n)
+	for _, id := range ids[:n] {
+		info := displayGeometry(id)
+		info.Main = id == main
+
+		C.displayName(id, &name[0])
+		info.Name = C.GoString(&name[0])
+		if info.Name == "" {
+			info.Name = fmt.Sprintf("Display %d", id)
+			if C.CGDisplayIsBuiltin(id) != 0 {
+				info.Name = "Built-in Display"
+			}
+		}
+
+		C.displayUUID(id, &uuid[0])
+		info.UUID = C.GoString(&uuid[0])
+
+		if info.Main {
+			displays = append([]DisplayInfo{info}, displays...)
+		} else {
+			displays = append(displays, info)
+		}
+	}
+
+	return displays, nil
+}
+
+// displayGeometry describes a display from its bounds in global points
+func displayGeometry(id C.CGDirectDisplayID) DisplayInfo {
+	bounds := rectFromCG(C.CGDisplayBounds(id))
+
+	// The ratio of physical pixels to points is the backing scale factor
+	scale := 1.0
+	if bounds.Width > 0 {
+		scale = float64(C.CGDisplayPixelsWide(id)) / float64(bounds.Width)
+		if mode := C.CGDisplayCopyDisplayMode(id); mode != 0 {
+			scale = float64(C.CGDisplayModeGetPixelWidth(mode)) / float64(bounds.Width)
+			C.CGDisplayModeRelease(mode)
+		}
+	}
+
+	return DisplayInfo{
+		ID:          uint32(id),
+		Bounds:      bounds,
+		ScaleFactor: scale,
+	}
+}
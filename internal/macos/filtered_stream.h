@@ -0,0 +1,24 @@
+#ifndef WITNESS_FILTERED_STREAM_H
+#define WITNESS_FILTERED_STREAM_H
+
+#include <CoreGraphics/CoreGraphics.h>
+#include <stdint.h>
+
+typedef struct FilteredStream FilteredStream;
+
+// createFilteredStream starts a ScreenCaptureKit stream of BGRA frames from
+// a display, leaving out the windows whose IDs are in excluded. When app is
+// not NULL, only the windows of applications whose name contains app,
+// ignoring case, are shown. Frames are passed to displayStreamFrame and the
+// end of the stream to displayStreamStopped, as for createDisplayStream. It
+// returns NULL if the stream could not start, including before macOS 12.3,
+// where ScreenCaptureKit is not available.
+FilteredStream *createFilteredStream(CGDirectDisplayID displayID, size_t width, size_t height,
+	double minFrameTime, int showCursor, const uint32_t *excluded, int excludedCount, const char *app,
+	uintptr_t handle);
+
+// stopFilteredStream stops the stream, waiting up to a second for it to
+// finish, and frees it
+void stopFilteredStream(FilteredStream *stream);
+
+#endif
@@ -0,0 +1,216 @@
+#include "filtered_stream.h"
+
+#import <CoreMedia/CoreMedia.h>
+#import <Foundation/Foundation.h>
+#import <ScreenCaptureKit/ScreenCaptureKit.h>
+#include <IOSurface/IOSurface.h>
+#include <dispatch/dispatch.h>
+#include <dlfcn.h>
+#include <stdatomic.h>
+#include <stdlib.h>
+
+#include "_cgo_export.h"
+
+// ScreenCaptureKit is loaded when a filtered stream is first needed rather
+// than linked, so Witness still runs on macOS versions without it
+#define SCREEN_CAPTURE_KIT "/System/Library/Frameworks/ScreenCaptureKit.framework/ScreenCaptureKit"
+
+// waitFor waits up to seconds for done, releasing it unless the wait timed
+// out, since the completion handler may still signal it later
+static BOOL waitFor(dispatch_semaphore_t done, double seconds) {
+	if (dispatch_semaphore_wait(done, dispatch_time(DISPATCH_TIME_NOW, (int64_t)(seconds * NSEC_PER_SEC))) != 0) {
+		return NO;
+	}
+	dispatch_release(done);
+	return YES;
+}
+
+API_AVAILABLE(macos(12.3))
+@interface WitnessStreamOutput : NSObject <SCStreamOutput, SCStreamDelegate> {
+@public
+	uintptr_t handle;
+	NSString *statusKey;
+	atomic_int stopped;
+}
+- (void)finish;
+@end
+
+@implementation WitnessStreamOutput
+
+- (void)stream:(SCStream *)stream didOutputSampleBuffer:(CMSampleBufferRef)sampleBuffer ofType:(SCStreamOutputType)type {
+	if (type != SCStreamOutputTypeScreen) {
+		return;
+	}
+
+	// Idle and blank statuses mean nothing changed on screen
+	CFArrayRef attachments = CMSampleBufferGetSampleAttachmentsArray(sampleBuffer, false);
+	if (attachments == NULL || CFArrayGetCount(attachments) == 0) {
+		return;
+	}
+	NSDictionary *info = (NSDictionary *)CFArrayGetValueAtIndex(attachments, 0);
+	NSNumber *status = info[statusKey];
+	if (status == nil || status.integerValue != SCFrameStatusComplete) {
+		return;
+	}
+
+	CVImageBufferRef pixels = CMSampleBufferGetImageBuffer(sampleBuffer);
+	IOSurfaceRef surface = pixels != NULL ? CVPixelBufferGetIOSurface(pixels) : NULL;
+	if (surface == NULL) {
+		return;
+	}
+
+	// Held like a display stream's surface; Go releases it
+	CFRetain(surface);
+	IOSurfaceIncrementUseCount(surface);
+	displayStreamFrame(handle, surface);
+}
+
+- (void)stream:(SCStream *)stream didStopWithError:(NSError *)error {
+	[self finish];
+}
+
+// finish reports the end of the stream once, whether it failed or was
+// stopped
+- (void)finish {
+	int running = 0;
+	if (atomic_compare_exchange_strong(&stopped, &running, 1)) {
+		displayStreamStopped(handle);
+	}
+}
+
+@end
+
+struct FilteredStream {
+	id stream;
+	id output;
+	dispatch_queue_t queue;
+};
+
+FilteredStream *createFilteredStream(CGDirectDisplayID displayID, size_t width, size_t height,
+	double minFrameTime, int showCursor, const uint32_t *excluded, int excludedCount, const char *app,
+	uintptr_t handle) {
+	if (@available(macOS 12.3, *)) {
+		if (dlopen(SCREEN_CAPTURE_KIT, RTLD_LAZY) == NULL) {
+			return NULL;
+		}
+		NSString **statusKey = dlsym(RTLD_DEFAULT, "SCStreamFrameInfoStatus");
+		Class contentClass = NSClassFromString(@"SCShareableContent");
+		Class filterClass = NSClassFromString(@"SCContentFilter");
+		Class configClass = NSClassFromString(@"SCStreamConfiguration");
+		Class streamClass = NSClassFromString(@"SCStream");
+		if (statusKey == NULL || contentClass == nil || filterClass == nil || configClass == nil || streamClass == nil) {
+			return NULL;
+		}
+
+		// The displays and windows that can be captured are delivered
+		// asynchronously
+		__block SCShareableContent *content = nil;
+		dispatch_semaphore_t listed = dispatch_semaphore_create(0);
+		[contentClass getShareableContentWithCompletionHandler:^(SCShareableContent *shareable, NSError *error) {
+			content = [shareable retain];
+			dispatch_semaphore_signal(listed);
+		}];
+		if (!waitFor(listed, 5) || content == nil) {
+			return NULL;
+		}
+
+		SCDisplay *display = nil;
+		for (SCDisplay *d in content.displays) {
+			if (d.displayID == displayID) {
+				display = d;
+			}
+		}
+		NSMutableArray<SCWindow *> *windows = [NSMutableArray array];
+		for (SCWindow *w in content.windows) {
+			for (int i = 0; i < excludedCount; i++) {
+				if (w.windowID == excluded[i]) {
+					[windows addObject:w];
+				}
+			}
+		}
+		NSMutableArray<SCRunningApplication *> *apps = [NSMutableArray array];
+		if (app != NULL) {
+			NSString *name = [NSString stringWithUTF8String:app];
+			for (SCRunningApplication *a in content.applications) {
+				if ([a.applicationName localizedCaseInsensitiveContainsString:name]) {
+					[apps addObject:a];
+				}
+			}
+		}
+		if (display == nil || (app != NULL && apps.count == 0)) {
+			[content release];
+			return NULL;
+		}
+
+		SCContentFilter *filter;
+		if (app != NULL) {
+			filter = [[filterClass alloc] initWithDisplay:display includingApplications:apps exceptingWindows:windows];
+		} else {
+			filter = [[filterClass alloc] initWithDisplay:display excludingWindows:windows];
+		}
+		[content release];
+
+		SCStreamConfiguration *config = [[configClass alloc] init];
+		config.width = width;
+		config.height = height;
+		config.minimumFrameInterval = CMTimeMakeWithSeconds(minFrameTime, 600);
+		config.pixelFormat = 'BGRA'; // kCVPixelFormatType_32BGRA
+		config.showsCursor = showCursor != 0;
+
+		WitnessStreamOutput *output = [[WitnessStreamOutput alloc] init];
+		output->handle = handle;
+		output->statusKey = *statusKey;
+
+		SCStream *stream = [[streamClass alloc] initWithFilter:filter configuration:config delegate:output];
+		[filter release];
+		[config release];
+
+		dispatch_queue_t queue = dispatch_queue_create("witness.capture", DISPATCH_QUEUE_SERIAL);
+		NSError *error = nil;
+		if (![stream addStreamOutput:output type:SCStreamOutputTypeScreen sampleHandlerQueue:queue error:&error]) {
+			[stream release];
+			[output release];
+			dispatch_release(queue);
+			return NULL;
+		}
+
+		__block BOOL started = NO;
+		dispatch_semaphore_t starting = dispatch_semaphore_create(0);
+		[stream startCaptureWithCompletionHandler:^(NSError *error) {
+			started = error == nil;
+			dispatch_semaphore_signal(starting);
+		}];
+		if (!waitFor(starting, 5) || !started) {
+			// The stream may still be starting, so it is not released
+			return NULL;
+		}
+
+		FilteredStream *s = calloc(1, sizeof(FilteredStream));
+		s->stream = stream;
+		s->output = output;
+		s->queue = queue;
+		return s;
+	}
+	return NULL;
+}
+
+void stopFilteredStream(FilteredStream *s) {
+	if (@available(macOS 12.3, *)) {
+		SCStream *stream = s->stream;
+		WitnessStreamOutput *output = s->output;
+
+		dispatch_semaphore_t stopping = dispatch_semaphore_create(0);
+		[stream stopCaptureWithCompletionHandler:^(NSError *error) {
+			[output finish];
+			dispatch_semaphore_signal(stopping);
+		}];
+		// A stream that has not finished stopping may still call back, so
+		// it is only released once it has
+		if (waitFor(stopping, 1)) {
+			[stream release];
+			[output release];
+			dispatch_release(s->queue);
+		}
+	}
+	free(s);
+}
@@ -0,0 +1,161 @@
+//go:build darwin
+// +build darwin
+
+package macos
+
+/*
+#cgo LDFLAGS: -framework VideoToolbox -framework CoreMedia -framework CoreVideo -framework CoreFoundation
+
+#include "h264_session.h"
+*/
+import "C"
+import (
+	"fmt"
+	"image"
+	"runtime/cgo"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// H264 profiles, from best compression to widest compatibility
+const (
+	H264ProfileHigh     = C.H264ProfileHigh
+	H264ProfileMain     = C.H264ProfileMain
+	H264ProfileBaseline = C.H264ProfileBaseline
+)
+
+// H264Options configures an H264Encoder
+type H264Options struct {
+	Width, Height int
+	FPS           float64
+
+	// Bitrate is the average bits per second; 0 lets VideoToolbox choose
+	Bitrate int
+
+	// KeyframeInterval is the most frames between keyframes; 0 lets
+	// VideoToolbox choose
+	KeyframeInterval int
+
+	Profile int
+}
+
+// H264Sample is one encoded frame: NAL units with 4-byte length prefixes
+type H264Sample struct {
+	Data     []byte
+	PTS      time.Duration
+	Keyframe bool
+}
+
+// H264Encoder encodes frames with a VideoToolbox compression session, on
+// the hardware encoder when the Mac has one. Frames are encoded
+// asynchronously; Samples collects those that are done.
+type H264Encoder struct {
+	session       *C.H264Session
+	handle        cgo.Handle
+	width, height int
+
+	mu       sync.Mutex
+	samples  []H264Sample
+	sps, pps []byte
+	err      error
+}
+
+// NewH264Encoder creates a compression session. Odd sizes are rounded up to
+// even, as 4:2:0 H.264 requires.
+func NewH264Encoder(o H264Options) (*H264Encoder, error) {
+	e := &H264Encoder{width: o.Width + o.Width%2, height: o.Height + o.Height%2}
+	e.handle = cgo.NewHandle(e)
+
+	var status C.int32_t
+	e.session = C.createH264Session(C.uintptr_t(e.handle), C.int(e.width), C.int(e.height), C.double(o.FPS),
+		C.int(o.Bitrate), C.int(o.KeyframeInterval), C.int(o.Profile), &status)
+	if e.session == nil {
+		e.handle.Delete()
+		return nil, fmt.Errorf("failed to create H.264 encoder (OSStatus %d)", int32(status))
+	}
+	return e, nil
+}
+
+// Encode queues img for encoding, presented at pts
+func (e *H264Encoder) Encode(img *image.RGBA, pts time.Duration) error {
+	if err := e.Err(); err != nil {
+		return err
+	}
+	b := img.Bounds()
+	if b.Empty() {
+		return fmt.Errorf("empty frame")
+	}
+	pix := img.Pix[img.PixOffset(b.Min.X, b.Min.Y):]
+	status := C.encodeH264Frame(e.session, (*C.uint8_t)(unsafe.Pointer(&pix[0])), C.int(img.Stride),
+		C.int(b.Dx()), C.int(b.Dy()), C.int64_t(pts.Microseconds()))
+	if status != 0 {
+		return fmt.Errorf("failed to encode frame (OSStatus %d)", int32(status))
+	}
+	return nil
+}
+
+// Flush waits for every queued frame to be encoded
+func (e *H264Encoder) Flush() error {
+	if status := C.flushH264Session(e.session); status != 0 {
+		return fmt.Errorf("failed to finish encoding (OSStatus %d)", int32(status))
+	}
+	return e.Err()
+}
+
+// Samples returns the frames encoded since the last call
+func (e *H264Encoder) Samples() []H264Sample {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	samples := e.samples
+	e.samples = nil
+	return samples
+}
+
+// ParameterSets returns the sequence and picture parameter sets of the
+// stream, once a keyframe has been encoded
+func (e *H264Encoder) ParameterSets() (sps, pps []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.sps, e.pps
+}
+
+// Err returns the first error reported while encoding
+func (e *H264Encoder) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// Close releases the session, dropping frames not yet encoded
+func (e *H264Encoder) Close() {
+	C.freeH264Session(e.session)
+	e.handle.Delete()
+}
+
+// h264SessionOutput is called on a VideoToolbox thread for each encoded
+// frame, or with a nonzero status when encoding a frame failed
+//
+//export h264SessionOutput
+func h264SessionOutput(handle C.uintptr_t, status C.int32_t, data unsafe.Pointer, size C.size_t,
+	ptsMicros C.int64_t, keyframe C.int, sps unsafe.Pointer, spsSize C.size_t, pps unsafe.Pointer, ppsSize C.size_t) {
+	e := cgo.Handle(handle).Value().(*H264Encoder)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if status != 0 {
+		if e.err == nil {
+			e.err = fmt.Errorf("failed to encode frame (OSStatus %d)", int32(status))
+		}
+		return
+	}
+	if sps != nil && pps != nil {
+		e.sps = C.GoBytes(sps, C.int(spsSize))
+		e.pps = C.GoBytes(pps, C.int(ppsSize))
+	}
+	e.samples = append(e.samples, H264Sample{
+		Data:     C.GoBytes(data, C.int(size)),
+		PTS:      time.Duration(ptsMicros) * time.Microsecond,
+		Keyframe: keyframe != 0,
+	})
+}
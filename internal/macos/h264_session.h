@@ -0,0 +1,35 @@
+#ifndef WITNESS_H264_SESSION_H
+#define WITNESS_H264_SESSION_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+typedef struct H264Session H264Session;
+
+// Profiles for createH264Session
+enum {
+	H264ProfileHigh = 0,
+	H264ProfileMain = 1,
+	H264ProfileBaseline = 2,
+};
+
+// createH264Session creates a VideoToolbox H.264 compression session for
+// frames of the given even size, preferring the hardware encoder. Encoded
+// frames are passed to the Go h264SessionOutput callback along with handle,
+// in presentation order. A bitrate or keyframeInterval of 0 leaves the
+// choice to VideoToolbox. It returns NULL and sets *status on failure.
+H264Session *createH264Session(uintptr_t handle, int width, int height, double fps,
+	int bitrate, int keyframeInterval, int profile, int32_t *status);
+
+// encodeH264Frame queues an RGBA frame presented at ptsMicros. Frames
+// smaller than the session are padded by repeating their last row and
+// column. It returns a nonzero OSStatus on failure.
+int32_t encodeH264Frame(H264Session *s, const uint8_t *rgba, int stride, int width, int height, int64_t ptsMicros);
+
+// flushH264Session waits until every queued frame has been output
+int32_t flushH264Session(H264Session *s);
+
+// freeH264Session invalidates and releases a session
+void freeH264Session(H264Session *s);
+
+#endif
@@ -0,0 +1,181 @@
+#include "h264_session.h"
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <CoreMedia/CoreMedia.h>
+#include <CoreVideo/CoreVideo.h>
+#include <VideoToolbox/VideoToolbox.h>
+#include <stdlib.h>
+
+#include "_cgo_export.h"
+
+struct H264Session {
+	uintptr_t handle;
+	int width;
+	int height;
+	VTCompressionSessionRef session;
+};
+
+static void setInt(VTCompressionSessionRef session, CFStringRef key, int value) {
+	CFNumberRef number = CFNumberCreate(NULL, kCFNumberIntType, &value);
+	VTSessionSetProperty(session, key, number);
+	CFRelease(number);
+}
+
+static void outputCallback(void *refcon, void *sourceRefcon, OSStatus status,
+	VTEncodeInfoFlags flags, CMSampleBufferRef sample) {
+	H264Session *s = refcon;
+	if (status != noErr || sample == NULL) {
+		h264SessionOutput(s->handle, status, NULL, 0, 0, 0, NULL, 0, NULL, 0);
+		return;
+	}
+	if (flags & kVTEncodeInfo_FrameDropped) {
+		return;
+	}
+
+	// Samples are sync samples unless marked otherwise
+	int keyframe = 1;
+	CFArrayRef attachments = CMSampleBufferGetSampleAttachmentsArray(sample, false);
+	if (attachments != NULL && CFArrayGetCount(attachments) > 0) {
+		CFDictionaryRef attachment = CFArrayGetValueAtIndex(attachments, 0);
+		CFBooleanRef notSync = CFDictionaryGetValue(attachment, kCMSampleAttachmentKey_NotSync);
+		keyframe = notSync == NULL || !CFBooleanGetValue(notSync);
+	}
+
+	const uint8_t *sps = NULL, *pps = NULL;
+	size_t spsSize = 0, ppsSize = 0;
+	if (keyframe) {
+		CMFormatDescriptionRef format = CMSampleBufferGetFormatDescription(sample);
+		CMVideoFormatDescriptionGetH264ParameterSetAtIndex(format, 0, &sps, &spsSize, NULL, NULL);
+		CMVideoFormatDescriptionGetH264ParameterSetAtIndex(format, 1, &pps, &ppsSize, NULL, NULL);
+	}
+
+	// The block buffer may not be contiguous, so copy it out
+	CMBlockBufferRef block = CMSampleBufferGetDataBuffer(sample);
+	size_t size = CMBlockBufferGetDataLength(block);
+	void *data = malloc(size);
+	if (CMBlockBufferCopyDataBytes(block, 0, size, data) != kCMBlockBufferNoErr) {
+		free(data);
+		h264SessionOutput(s->handle, -1, NULL, 0, 0, 0, NULL, 0, NULL, 0);
+		return;
+	}
+
+	CMTime pts = CMTimeConvertScale(CMSampleBufferGetPresentationTimeStamp(sample),
+		1000000, kCMTimeRoundingMethod_RoundHalfAwayFromZero);
+	h264SessionOutput(s->handle, noErr, data, size, pts.value, keyframe,
+		(void *)sps, spsSize, (void *)pps, ppsSize);
+	free(data);
+}
+
+H264Session *createH264Session(uintptr_t handle, int width, int height, double fps,
+	int bitrate, int keyframeInterval, int profile, int32_t *status) {
+	H264Session *s = calloc(1, sizeof(H264Session));
+	s->handle = handle;
+	s->width = width;
+	s->height = height;
+
+	// Use the hardware encoder when there is one, else fall back to software
+	const void *specKeys[] = {kVTVideoEncoderSpecification_EnableHardwareAcceleratedVideoEncoder};
+	const void *specValues[] = {kCFBooleanTrue};
+	CFDictionaryRef spec = CFDictionaryCreate(NULL, specKeys, specValues, 1,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+
+	int format = kCVPixelFormatType_32BGRA;
+	CFNumberRef formatNumber = CFNumberCreate(NULL, kCFNumberIntType, &format);
+	CFDictionaryRef surface = CFDictionaryCreate(NULL, NULL, NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	const void *sourceKeys[] = {kCVPixelBufferPixelFormatTypeKey, kCVPixelBufferIOSurfacePropertiesKey};
+	const void *sourceValues[] = {formatNumber, surface};
+	CFDictionaryRef source = CFDictionaryCreate(NULL, sourceKeys, sourceValues, 2,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+
+	OSStatus err = VTCompressionSessionCreate(NULL, width, height, kCMVideoCodecType_H264,
+		spec, source, NULL, outputCallback, s, &s->session);
+	CFRelease(spec);
+	CFRelease(source);
+	CFRelease(surface);
+	CFRelease(formatNumber);
+	if (err != noErr) {
+		*status = err;
+		free(s);
+		return NULL;
+	}
+
+	CFStringRef level = kVTProfileLevel_H264_High_AutoLevel;
+	if (profile == H264ProfileMain) {
+		level = kVTProfileLevel_H264_Main_AutoLevel;
+	} else if (profile == H264ProfileBaseline) {
+		level = kVTProfileLevel_H264_Baseline_AutoLevel;
+	}
+	VTSessionSetProperty(s->session, kVTCompressionPropertyKey_ProfileLevel, level);
+	VTSessionSetProperty(s->session, kVTCompressionPropertyKey_RealTime, kCFBooleanFalse);
+
+	// Without B-frames samples come out in presentation order, which is what
+	// the MP4 writer expects
+	VTSessionSetProperty(s->session, kVTCompressionPropertyKey_AllowFrameReordering, kCFBooleanFalse);
+
+	// Tag the stream as BT.709 so players show the captured colors
+	VTSessionSetProperty(s->session, kVTCompressionPropertyKey_ColorPrimaries, kCVImageBufferColorPrimaries_ITU_R_709_2);
+	VTSessionSetProperty(s->session, kVTCompressionPropertyKey_TransferFunction, kCVImageBufferTransferFunction_ITU_R_709_2);
+	VTSessionSetProperty(s->session, kVTCompressionPropertyKey_YCbCrMatrix, kCVImageBufferYCbCrMatrix_ITU_R_709_2);
+
+	CFNumberRef rate = CFNumberCreate(NULL, kCFNumberDoubleType, &fps);
+	VTSessionSetProperty(s->session, kVTCompressionPropertyKey_ExpectedFrameRate, rate);
+	CFRelease(rate);
+	if (bitrate > 0) {
+		setInt(s->session, kVTCompressionPropertyKey_AverageBitRate, bitrate);
+	}
+	if (keyframeInterval > 0) {
+		setInt(s->session, kVTCompressionPropertyKey_MaxKeyFrameInterval, keyframeInterval);
+	}
+
+	err = VTCompressionSessionPrepareToEncodeFrames(s->session);
+	if (err != noErr) {
+		*status = err;
+		freeH264Session(s);
+		return NULL;
+	}
+	return s;
+}
+
+int32_t encodeH264Frame(H264Session *s, const uint8_t *rgba, int stride, int width, int height, int64_t ptsMicros) {
+	CVPixelBufferPoolRef pool = VTCompressionSessionGetPixelBufferPool(s->session);
+	if (pool == NULL) {
+		return kVTInvalidSessionErr;
+	}
+	CVPixelBufferRef pixels = NULL;
+	CVReturn cvErr = CVPixelBufferPoolCreatePixelBuffer(NULL, pool, &pixels);
+	if (cvErr != kCVReturnSuccess) {
+		return cvErr;
+	}
+
+	CVPixelBufferLockBaseAddress(pixels, 0);
+	uint8_t *base = CVPixelBufferGetBaseAddress(pixels);
+	size_t bytesPerRow = CVPixelBufferGetBytesPerRow(pixels);
+	for (int y = 0; y < s->height; y++) {
+		const uint8_t *src = rgba + (size_t)(y < height ? y : height - 1) * stride;
+		uint8_t *dst = base + (size_t)y * bytesPerRow;
+		for (int x = 0; x < s->width; x++) {
+			const uint8_t *p = src + (x < width ? x : width - 1) * 4;
+			dst[x * 4 + 0] = p[2];
+			dst[x * 4 + 1] = p[1];
+			dst[x * 4 + 2] = p[0];
+			dst[x * 4 + 3] = 255;
+		}
+	}
+	CVPixelBufferUnlockBaseAddress(pixels, 0);
+
+	OSStatus err = VTCompressionSessionEncodeFrame(s->session, pixels,
+		CMTimeMake(ptsMicros, 1000000), kCMTimeInvalid, NULL, NULL, NULL);
+	CVPixelBufferRelease(pixels);
+	return err;
+}
+
+int32_t flushH264Session(H264Session *s) {
+	return VTCompressionSessionCompleteFrames(s->session, kCMTimeInvalid);
+}
+
+void freeH264Session(H264Session *s) {
+	VTCompressionSessionInvalidate(s->session);
+	CFRelease(s->session);
+	free(s);
+}
@@ -0,0 +1,32 @@
+//go:build darwin
+// +build darwin
+
+// Package macos wraps the Core Graphics, ScreenCaptureKit, and VideoToolbox
+// calls witness makes on macOS. It returns its own types, which pkg/capture
+// converts, since pkg/capture imports this package to build its capturers.
+package macos
+
+import "errors"
+
+// Errors returned by this package. Callers compare with errors.Is, since
+// they are wrapped with context.
+var (
+	// ErrWindowNotFound means no window has the requested ID
+	ErrWindowNotFound = errors.New("window not found")
+
+	// ErrBitmapLayout means a bitmap context is laid out differently from
+	// the buffer it draws into, which would skew every row
+	ErrBitmapLayout = errors.New("bitmap context does not match its buffer")
+
+	// ErrStopped is returned when stopping something already stopped
+	ErrStopped = errors.New("already stopped")
+)
+
+// Rect is a rectangle in global points: the origin is the top-left corner
+// of the main display and Y grows downwards
+type Rect struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
@@ -0,0 +1,26 @@
+//go:build darwin
+// +build darwin
+
+package macos
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics
+
+#include <CoreGraphics/CoreGraphics.h>
+*/
+import "C"
+
+// ScreenCaptureAllowed reports whether the process has Screen Recording
+// permission. Without it, macOS does not fail capture: frames show only the
+// wallpaper and the process's own windows, so recordings come out blank.
+func ScreenCaptureAllowed() bool {
+	return bool(C.CGPreflightScreenCaptureAccess())
+}
+
+// RequestScreenCaptureAccess asks for Screen Recording permission. The
+// first request shows the system prompt and lists the app responsible for
+// the process (usually the terminal) in System Settings; later requests
+// only report whether permission has been granted.
+func RequestScreenCaptureAccess() bool {
+	return bool(C.CGRequestScreenCaptureAccess())
+}
@@ -0,0 +1,62 @@
+//go:build darwin
+// +build darwin
+
+package macos
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation
+
+#include <CoreGraphics/CoreGraphics.h>
+#include <CoreFoundation/CoreFoundation.h>
+
+// sessionBool reads a boolean from the current session dictionary, or
+// returns fallback if the key is missing
+static int sessionBool(CFDictionaryRef session, CFStringRef key, int fallback) {
+	CFBooleanRef value = CFDictionaryGetValue(session, key);
+	if (value == NULL || CFGetTypeID(value) != CFBooleanGetTypeID()) {
+		return fallback;
+	}
+	return CFBooleanGetValue(value);
+}
+
+// querySession reports whether the screen is locked and whether this
+// session owns the console. It returns 0 if there is no window server session.
+static int querySession(int *locked, int *onConsole) {
+	CFDictionaryRef session = CGSessionCopyCurrentDictionary();
+	if (session == NULL) {
+		return 0;
+	}
+
+	// CGSSessionScreenIsLocked is undocumented but set by loginwindow
+	*locked = sessionBool(session, CFSTR("CGSSessionScreenIsLocked"), 0);
+	*onConsole = sessionBool(session, kCGSessionOnConsoleKey, 1);
+
+	CFRelease(session);
+	return 1;
+}
+*/
+import "C"
+import "errors"
+
+// Session describes the login session the process runs in
+type Session struct {
+	// Locked is true while the screen is locked
+	Locked bool
+
+	// OnConsole is false when another user has taken over the display
+	// through fast user switching
+	OnConsole bool
+}
+
+// CurrentSession returns the lock and console state of the login session
+func CurrentSession() (Session, error) {
+	var locked, onConsole C.int
+	if C.querySession(&locked, &onConsole) == 0 {
+		return Session{}, errors.New("no window server session")
+	}
+
+	return Session{
+		Locked:    locked != 0,
+		OnConsole: onConsole != 0,
+	}, nil
+}
@@ -0,0 +1,35 @@
+//go:build darwin
+// +build darwin
+
+package macos
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics
+
+#include <stdint.h>
+
+// Private CoreGraphics (SkyLight) symbols. There is no public API for
+// Spaces, but these have been stable since Mac OS X 10.6.
+typedef int CGSConnectionID;
+typedef uint64_t CGSSpaceID;
+extern CGSConnectionID CGSMainConnectionID(void);
+extern CGSSpaceID CGSGetActiveSpace(CGSConnectionID cid);
+*/
+import "C"
+import "errors"
+
+// ActiveSpace returns the ID of the Space that is currently active on the
+// display with keyboard focus
+func ActiveSpace() (uint64, error) {
+	conn := C.CGSMainConnectionID()
+	if conn == 0 {
+		return 0, errors.New("failed to connect to window server")
+	}
+
+	space := C.CGSGetActiveSpace(conn)
+	if space == 0 {
+		return 0, errors.New("failed to query active Space")
+	}
+
+	return uint64(space), nil
+}
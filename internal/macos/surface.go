@@ -0,0 +1,99 @@
+//go:build darwin
+// +build darwin
+
+package macos
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework CoreFoundation -framework IOSurface
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <IOSurface/IOSurface.h>
+
+static void releaseSurface(IOSurfaceRef surface) {
+	IOSurfaceDecrementUseCount(surface);
+	CFRelease(surface);
+}
+
+static void lockSurface(IOSurfaceRef surface) {
+	IOSurfaceLock(surface, kIOSurfaceLockReadOnly, NULL);
+}
+
+static void unlockSurface(IOSurfaceRef surface) {
+	IOSurfaceUnlock(surface, kIOSurfaceLockReadOnly, NULL);
+}
+*/
+import "C"
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// PixelFormatBGRA is the IOSurface pixel format display streams are asked for
+const PixelFormatBGRA = 'B'<<24 | 'G'<<16 | 'R'<<8 | 'A'
+
+// Surface is a frame from a display stream, held in the IOSurface the
+// stream drew it into. The stream does not reuse the surface until every
+// reference to it is released, so its pixels can be read in place.
+type Surface struct {
+	ref  C.IOSurfaceRef
+	refs atomic.Int32
+}
+
+// Pixels is the memory of a locked surface
+type Pixels struct {
+	// Data holds every row of the surface
+	Data []byte
+
+	// Width and Height are the surface size in pixels
+	Width, Height int
+
+	// Stride is the distance between rows in bytes
+	Stride int
+
+	// BytesPerPixel is the size of each pixel
+	BytesPerPixel int
+
+	// Format is the pixel format, such as PixelFormatBGRA
+	Format uint32
+}
+
+// newSurface wraps a surface already retained by the stream callback,
+// holding the one reference
+func newSurface(ref C.IOSurfaceRef) *Surface {
+	s := &Surface{ref: ref}
+	s.refs.Store(1)
+	return s
+}
+
+// Retain adds a reference, which must be released with Release
+func (s *Surface) Retain() {
+	s.refs.Add(1)
+}
+
+// Release drops a reference, handing the surface back to the stream once
+// the last one is gone
+func (s *Surface) Release() {
+	if s.refs.Add(-1) == 0 {
+		C.releaseSurface(s.ref)
+	}
+}
+
+// Lock locks the surface for reading and returns its pixels, which may only
+// be read until Unlock
+func (s *Surface) Lock() Pixels {
+	C.lockSurface(s.ref)
+	return Pixels{
+		Data:          unsafe.Slice((*byte)(C.IOSurfaceGetBaseAddress(s.ref)), int(C.IOSurfaceGetAllocSize(s.ref))),
+		Width:         int(C.IOSurfaceGetWidth(s.ref)),
+		Height:        int(C.IOSurfaceGetHeight(s.ref)),
+		Stride:        int(C.IOSurfaceGetBytesPerRow(s.ref)),
+		BytesPerPixel: int(C.IOSurfaceGetBytesPerElement(s.ref)),
+		Format:        uint32(C.IOSurfaceGetPixelFormat(s.ref)),
+	}
+}
+
+// Unlock ends reading the pixels returned by Lock
+func (s *Surface) Unlock() {
+	C.unlockSurface(s.ref)
+}
@@ -0,0 +1,196 @@
+//go:build darwin
+// +build darwin
+
+package macos
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation
+
+#include <CoreGraphics/CoreGraphics.h>
+#include <CoreFoundation/CoreFoundation.h>
+
+#define MAX_ABOVE 64
+
+typedef struct {
+	int found;
+	int onscreen;
+	CGRect bounds;
+	int aboveCount;
+	CGRect above[MAX_ABOVE];
+} windowState;
+
+// windowBounds reads kCGWindowBounds from a window description
+static int windowBounds(CFDictionaryRef info, CGRect *rect) {
+	CFDictionaryRef dict = CFDictionaryGetValue(info, kCGWindowBounds);
+	return dict != NULL && CGRectMakeWithDictionaryRepresentation(dict, rect);
+}
+
+// windowLayer reads kCGWindowLayer from a window description
+static int windowLayer(CFDictionaryRef info) {
+	int layer = 0;
+	CFNumberRef num = CFDictionaryGetValue(info, kCGWindowLayer);
+	if (num != NULL) {
+		CFNumberGetValue(num, kCFNumberIntType, &layer);
+	}
+	return layer;
+}
+
+// queryWindow describes a window and the normal windows stacked above it
+static windowState queryWindow(CGWindowID id) {
+	windowState state = {0};
+
+	CFArrayRef self = CGWindowListCopyWindowInfo(kCGWindowListOptionIncludingWindow, id);
+	if (self == NULL) {
+		return state;
+	}
+	if (CFArrayGetCount(self) > 0) {
+		CFDictionaryRef info = CFArrayGetValueAtIndex(self, 0);
+		state.found = windowBounds(info, &state.bounds);
+		CFBooleanRef onscreen = CFDictionaryGetValue(info, kCGWindowIsOnscreen);
+		state.onscreen = onscreen != NULL && CFBooleanGetValue(onscreen);
+	}
+	CFRelease(self);
+
+	if (!state.found || !state.onscreen) {
+		return state;
+	}
+
+	CFArrayRef above = CGWindowListCopyWindowInfo(
+		kCGWindowListOptionOnScreenAboveWindow | kCGWindowListExcludeDesktopElements, id);
+	if (above == NULL) {
+		return state;
+	}
+	for (CFIndex i = 0; i < CFArrayGetCount(above) && state.aboveCount < MAX_ABOVE; i++) {
+		CFDictionaryRef info = CFArrayGetValueAtIndex(above, i);
+		// Skip the menu bar, Dock, and other overlays above normal windows
+		if (windowLayer(info) != 0) {
+			continue;
+		}
+		if (windowBounds(info, &state.above[state.aboveCount])) {
+			state.aboveCount++;
+		}
+	}
+	CFRelease(above);
+
+	return state;
+}
+
+#define MAX_WINDOWS 256
+#define MAX_NAME 256
+
+typedef struct {
+	CGWindowID id;
+	CGRect bounds;
+	char title[MAX_NAME];
+	char owner[MAX_NAME];
+} windowInfo;
+
+// copyString copies a CFString value from a window description as UTF-8
+static void copyString(CFDictionaryRef info, CFStringRef key, char *out) {
+	out[0] = 0;
+	CFStringRef str = CFDictionaryGetValue(info, key);
+	if (str != NULL) {
+		CFStringGetCString(str, out, MAX_NAME, kCFStringEncodingUTF8);
+	}
+}
+
+// listWindows fills out with the on-screen normal windows, frontmost first,
+// and returns how many were found
+static int listWindows(windowInfo *out) {
+	CFArrayRef list = CGWindowListCopyWindowInfo(
+		kCGWindowListOptionOnScreenOnly | kCGWindowListExcludeDesktopElements, kCGNullWindowID);
+	if (list == NULL) {
+		return 0;
+	}
+
+	int n = 0;
+	for (CFIndex i = 0; i < CFArrayGetCount(list) && n < MAX_WINDOWS; i++) {
+		CFDictionaryRef info = CFArrayGetValueAtIndex(list, i);
+		if (windowLayer(info) != 0 || !windowBounds(info, &out[n].bounds)) {
+			continue;
+		}
+		CFNumberRef num = CFDictionaryGetValue(info, kCGWindowNumber);
+		if (num == NULL || !CFNumberGetValue(num, kCGWindowIDCFNumberType, &out[n].id)) {
+			continue;
+		}
+		copyString(info, kCGWindowName, out[n].title);
+		copyString(info, kCGWindowOwnerName, out[n].owner);
+		n++;
+	}
+	CFRelease(list);
+
+	return n;
+}
+*/
+import "C"
+import "fmt"
+
+// Window describes an on-screen window
+type Window struct {
+	// ID is the Core Graphics window number
+	ID uint32
+
+	// Bounds is the window rectangle
+	Bounds Rect
+
+	// OnScreen is false when the window is minimized, hidden, or on another Space
+	OnScreen bool
+
+	// Above holds the bounds of normal windows stacked in front of this one
+	Above []Rect
+
+	// Title is the window's title, empty without screen recording permission
+	Title string
+
+	// Owner is the name of the application that owns the window
+	Owner string
+}
+
+// LookupWindow returns the bounds and visibility of a window
+func LookupWindow(id uint32) (Window, error) {
+	state := C.queryWindow(C.CGWindowID(id))
+	if state.found == 0 {
+		return Window{}, fmt.Errorf("window %d: %w", id, ErrWindowNotFound)
+	}
+
+	w := Window{
+		ID:       id,
+		Bounds:   rectFromCG(state.bounds),
+		OnScreen: state.onscreen != 0,
+	}
+	for i := 0; i < int(state.aboveCount); i++ {
+		w.Above = append(w.Above, rectFromCG(state.above[i]))
+	}
+
+	return w, nil
+}
+
+// ListWindows returns the on-screen application windows, frontmost first.
+// Titles are empty unless the process has screen recording permission.
+func ListWindows() ([]Window, error) {
+	infos := make([]C.windowInfo, C.MAX_WINDOWS)
+	n := int(C.listWindows(&infos[0]))
+
+	windows := make([]Window, 0, n)
+	for _, info := range infos[:n] {
+		windows = append(windows, Window{
+			ID:       uint32(info.id),
+			Bounds:   rectFromCG(info.bounds),
+			OnScreen: true,
+			Title:    C.GoString(&info.title[0]),
+			Owner:    C.GoString(&info.owner[0]),
+		})
+	}
+	return windows, nil
+}
+
+// rectFromCG converts a CGRect in global points to a Rect
+func rectFromCG(r C.CGRect) Rect {
+	return Rect{
+		X:      int(r.origin.x),
+		Y:      int(r.origin.y),
+		Width:  int(r.size.width),
+		Height: int(r.size.height),
+	}
+}
@@ -0,0 +1,142 @@
+//go:build darwin
+// +build darwin
+
+package macos
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation -framework AppKit
+
+#include <CoreGraphics/CoreGraphics.h>
+#include <AppKit/AppKit.h>
+
+// windowImage captures a single window, without its shadow, at the
+// resolution of the display it is on
+static CGImageRef windowImage(CGWindowID id) {
+	return CGWindowListCreateImage(CGRectNull, kCGWindowListOptionIncludingWindow, id,
+		kCGWindowImageBoundsIgnoreFraming | kCGWindowImageBestResolution);
+}
+
+// drawImage draws img, scaled to drawWidth x drawHeight, into the top-left
+// corner of an RGBA buffer, cropping or leaving the rest clear when the
+// sizes differ. It returns 0 when the bitmap context cannot be created and
+// -1 when the context is not laid out like the buffer, which would draw
+// skewed rows.
+static int drawImage(CGImageRef img, CGFloat drawWidth, CGFloat drawHeight,
+	void *pix, size_t width, size_t height, size_t stride) {
+	CGColorSpaceRef colorSpace = CGColorSpaceCreateDeviceRGB();
+	CGContextRef context = CGBitmapContextCreate(pix, width, height, 8, stride, colorSpace,
+		kCGImageAlphaPremultipliedLast | kCGBitmapByteOrder32Big);
+	CGColorSpaceRelease(colorSpace);
+	if (context == NULL) {
+		return 0;
+	}
+	if (CGBitmapContextGetBytesPerRow(context) != stride ||
+		CGBitmapContextGetBitsPerPixel(context) != 32 ||
+		CGBitmapContextGetWidth(context) != width ||
+		CGBitmapContextGetHeight(context) != height) {
+		CGContextRelease(context);
+		return -1;
+	}
+
+	// Core Graphics puts the origin at the bottom left
+	CGContextSetInterpolationQuality(context, kCGInterpolationHigh);
+	CGContextDrawImage(context, CGRectMake(0, (CGFloat)height - drawHeight, drawWidth, drawHeight), img);
+	CGContextRelease(context);
+	return 1;
+}
+
+// drawCursor draws the mouse pointer into an RGBA buffer whose top-left
+// corner is at origin in global points, with scale pixels per point
+static void drawCursor(void *pix, size_t width, size_t height, size_t stride,
+	CGFloat originX, CGFloat originY, CGFloat scale) {
+	@autoreleasepool {
+		NSCursor *cursor = [NSCursor currentSystemCursor];
+		if (cursor == nil) {
+			return; // Hidden, e.g. while typing
+		}
+		CGImageRef img = [cursor.image CGImageForProposedRect:NULL context:nil hints:nil];
+		if (img == NULL) {
+			return;
+		}
+
+		CGEventRef event = CGEventCreate(NULL);
+		CGPoint location = CGEventGetLocation(event);
+		CFRelease(event);
+
+		CGColorSpaceRef colorSpace = CGColorSpaceCreateDeviceRGB();
+		CGContextRef context = CGBitmapContextCreate(pix, width, height, 8, stride, colorSpace,
+			kCGImageAlphaPremultipliedLast | kCGBitmapByteOrder32Big);
+		CGColorSpaceRelease(colorSpace);
+		if (context == NULL) {
+			return;
+		}
+
+		// The hot spot is measured from the image's top left
+		NSSize size = cursor.image.size;
+		CGFloat x = (location.x - cursor.hotSpot.x - originX) * scale;
+		CGFloat y = (location.y - cursor.hotSpot.y - originY) * scale;
+		CGFloat w = size.width * scale, h = size.height * scale;
+		CGContextDrawImage(context, CGRectMake(x, (CGFloat)height - y - h, w, h), img);
+		CGContextRelease(context);
+	}
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"image"
+	"unsafe"
+)
+
+// WindowImage is a capture of a single window, without its shadow, at the
+// resolution of the display it is on
+type WindowImage struct {
+	ref C.CGImageRef
+}
+
+// CaptureWindow captures a window with CGWindowListCreateImage, which sees
+// it even while it is covered. It returns nil when the window cannot be
+// captured, as when it is off screen or has closed.
+func CaptureWindow(id uint32) *WindowImage {
+	ref := C.windowImage(C.CGWindowID(id))
+	if ref == 0 {
+		return nil
+	}
+	return &WindowImage{ref: ref}
+}
+
+// Size returns the image size in pixels
+func (w *WindowImage) Size() image.Point {
+	return image.Pt(int(C.CGImageGetWidth(w.ref)), int(C.CGImageGetHeight(w.ref)))
+}
+
+// Draw draws the image, scaled to size pixels, into the top-left corner of
+// dst, cropping it or leaving the rest of dst untouched when the sizes
+// differ. It fails with ErrBitmapLayout when Core Graphics would lay out
+// the pixels differently from dst.
+func (w *WindowImage) Draw(dst *image.RGBA, size image.Point) error {
+	bounds := dst.Rect.Size()
+	switch C.drawImage(w.ref, C.CGFloat(size.X), C.CGFloat(size.Y),
+		unsafe.Pointer(&dst.Pix[0]), C.size_t(bounds.X), C.size_t(bounds.Y), C.size_t(dst.Stride)) {
+	case 0:
+		return errors.New("failed to create bitmap context")
+	case -1:
+		return fmt.Errorf("%dx%d RGBA buffer with a %d-byte stride: %w", bounds.X, bounds.Y, dst.Stride, ErrBitmapLayout)
+	}
+	return nil
+}
+
+// Close releases the image
+func (w *WindowImage) Close() {
+	C.CGImageRelease(w.ref)
+}
+
+// DrawCursor draws the mouse pointer into dst, whose top-left corner is at
+// origin in global points, with scale pixels per point
+func DrawCursor(dst *image.RGBA, origin image.Point, scale float64) {
+	bounds := dst.Rect.Size()
+	C.drawCursor(unsafe.Pointer(&dst.Pix[0]), C.size_t(bounds.X), C.size_t(bounds.Y), C.size_t(dst.Stride),
+		C.CGFloat(origin.X), C.CGFloat(origin.Y), C.CGFloat(scale))
+}
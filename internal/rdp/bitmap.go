@@ -0,0 +1,364 @@
+package rdp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Bitmap data flags
+const (
+	bitmapCompression      = 0x0001
+	noBitmapCompressionHdr = 0x0400
+)
+
+// updateTypeBitmap marks a bitmap update among slow-path updates
+const updateTypeBitmap = 1
+
+// Interleaved RLE order codes (MS-RDPBCGR 2.2.9.1.1.3.1.2.4)
+const (
+	regularBgRun      = 0x0
+	regularFgRun      = 0x1
+	regularFgBgImage  = 0x2
+	regularColorRun   = 0x3
+	regularColorImage = 0x4
+	liteSetFgFgRun    = 0xc
+	liteSetFgFgBg     = 0xd
+	liteDitheredRun   = 0xe
+	megaBgRun         = 0xf0
+	megaFgRun         = 0xf1
+	megaFgBgImage     = 0xf2
+	megaColorRun      = 0xf3
+	megaColorImage    = 0xf4
+	megaSetFgRun      = 0xf6
+	megaSetFgBgImage  = 0xf7
+	megaDitheredRun   = 0xf8
+	specialFgBg1      = 0xf9
+	specialFgBg2      = 0xfa
+	specialWhite      = 0xfd
+	specialBlack      = 0xfe
+)
+
+var errRLE = errors.New("malformed RLE bitmap")
+
+// bitmapUpdate applies a TS_UPDATE_BITMAP_DATA structure
+func (c *Client) bitmapUpdate(data []byte) error {
+	if len(data) < 4 || binary.LittleEndian.Uint16(data) != updateTypeBitmap {
+		return nil // Orders, palettes, and synchronize updates are not used
+	}
+	count := int(binary.LittleEndian.Uint16(data[2:]))
+	data = data[4:]
+
+	for i := 0; i < count; i++ {
+		if len(data) < 18 {
+			return errShortPDU
+		}
+		var f [9]int // Left, top, right, bottom, width, height, bpp, flags, length
+		for j := range f {
+			f[j] = int(binary.LittleEndian.Uint16(data[2*j:]))
+		}
+		if 18+f[8] > len(data) {
+			return errShortPDU
+		}
+		body := data[18 : 18+f[8]]
+		data = data[18+f[8]:]
+
+		width, height, bpp, flags := f[4], f[5], f[6], f[7]
+		compressed := flags&bitmapCompression != 0
+		if compressed && flags&noBitmapCompressionHdr == 0 {
+			if len(body) < 8 {
+				return errShortPDU
+			}
+			if n := int(binary.LittleEndian.Uint16(body[2:])); 8+n <= len(body) {
+				body = body[8 : 8+n]
+			} else {
+				body = body[8:]
+			}
+		}
+		pixels, stride, err := decodeBitmap(body, width, height, bpp, compressed)
+		if err != nil {
+			return err
+		}
+		c.drawBitmap(image.Rect(f[0], f[1], f[2]+1, f[3]+1), pixels, stride, height, bpp)
+	}
+	return nil
+}
+
+// decodeBitmap returns a bitmap's pixels as bottom-up rows of stride bytes
+func decodeBitmap(data []byte, width, height, bpp int, compressed bool) ([]byte, int, error) {
+	switch bpp {
+	case 15, 16, 24:
+	case 32:
+		if compressed {
+			return nil, 0, errors.New("RDP server sent a 32 bpp planar bitmap, which is not supported")
+		}
+	default:
+		return nil, 0, fmt.Errorf("RDP server sent a %d bpp bitmap, which is not supported", bpp)
+	}
+	pixelSize := (bpp + 7) / 8
+
+	if compressed {
+		pixels, err := decompressRLE(data, width, height, bpp)
+		return pixels, width * pixelSize, err
+	}
+	stride := (width*pixelSize + 3) &^ 3
+	if len(data) < stride*height {
+		return nil, 0, errShortPDU
+	}
+	return data, stride, nil
+}
+
+// drawBitmap converts bottom-up pixel rows into the framebuffer at dst
+func (c *Client) drawBitmap(dst image.Rectangle, pixels []byte, stride, height, bpp int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pixelSize := (bpp + 7) / 8
+	clip := dst.Intersect(c.fb.Bounds())
+	for y := clip.Min.Y; y < clip.Max.Y; y++ {
+		row := height - 1 - (y - dst.Min.Y)
+		if row < 0 {
+			break
+		}
+		src := pixels[row*stride:]
+		out := c.fb.Pix[c.fb.PixOffset(clip.Min.X, y):]
+		for x := clip.Min.X; x < clip.Max.X; x++ {
+			at := (x - dst.Min.X) * pixelSize
+			if at+pixelSize > len(src) || at+pixelSize > stride {
+				break
+			}
+			var p uint32
+			for i := pixelSize - 1; i >= 0; i-- {
+				p = p<<8 | uint32(src[at+i])
+			}
+			o := 4 * (x - clip.Min.X)
+			out[o], out[o+1], out[o+2], out[o+3] = pixelRGB(p, bpp)
+		}
+	}
+	c.synced = true
+}
+
+// pixelRGB converts a little-endian pixel to RGBA
+func pixelRGB(p uint32, bpp int) (r, g, b, a byte) {
+	switch bpp {
+	case 15:
+		r, g, b = byte(p>>10&0x1f), byte(p>>5&0x1f), byte(p&0x1f)
+		return r<<3 | r>>2, g<<3 | g>>2, b<<3 | b>>2, 255
+	case 16:
+		r, g, b = byte(p>>11&0x1f), byte(p>>5&0x3f), byte(p&0x1f)
+		return r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 255
+	}
+	return byte(p >> 16), byte(p >> 8), byte(p), 255
+}
+
+// rle decodes interleaved RLE, in which runs and images of pixels may be
+// XORed with the row above
+type rle struct {
+	src       []byte
+	dst       []byte
+	d         int // Write offset in dst
+	pixelSize int
+	rowDelta  int
+	err       error
+}
+
+// decompressRLE decodes an interleaved RLE bitmap (MS-RDPBCGR 3.1.9) into
+// bottom-up rows of width pixels
+func decompressRLE(src []byte, width, height, bpp int) ([]byte, error) {
+	pixelSize := (bpp + 7) / 8
+	r := &rle{
+		src:       src,
+		dst:       make([]byte, width*height*pixelSize),
+		pixelSize: pixelSize,
+		rowDelta:  width * pixelSize,
+	}
+	white := uint32(1)<<bpp - 1
+	fg := white
+	insertFg := false
+	firstLine := true
+
+	for len(r.src) > 0 && r.err == nil {
+		if firstLine && r.d >= r.rowDelta {
+			firstLine = false
+			insertFg = false
+		}
+		header := r.src[0]
+		code := orderCode(header)
+
+		if code == regularBgRun || code == megaBgRun {
+			n := r.runLength(code, header)
+			if insertFg && n > 0 {
+				r.put(r.above(firstLine) ^ fg)
+				n--
+			}
+			for ; n > 0; n-- {
+				r.put(r.above(firstLine))
+			}
+			insertFg = true
+			continue
+		}
+		insertFg = false
+
+		switch code {
+		case regularFgRun, megaFgRun, liteSetFgFgRun, megaSetFgRun:
+			n := r.runLength(code, header)
+			if code == liteSetFgFgRun || code == megaSetFgRun {
+				fg = r.pixel()
+			}
+			for ; n > 0; n-- {
+				r.put(r.above(firstLine) ^ fg)
+			}
+		case liteDitheredRun, megaDitheredRun:
+			n := r.runLength(code, header)
+			a, b := r.pixel(), r.pixel()
+			for ; n > 0; n-- {
+				r.put(a)
+				r.put(b)
+			}
+		case regularColorRun, megaColorRun:
+			n := r.runLength(code, header)
+			p := r.pixel()
+			for ; n > 0; n-- {
+				r.put(p)
+			}
+		case regularFgBgImage, megaFgBgImage, liteSetFgFgBg, megaSetFgBgImage:
+			n := r.runLength(code, header)
+			if code == liteSetFgFgBg || code == megaSetFgBgImage {
+				fg = r.pixel()
+			}
+			for ; n > 0 && r.err == nil; n -= 8 {
+				r.fgbg(r.byte(), fg, min(n, 8), firstLine)
+			}
+		case regularColorImage, megaColorImage:
+			n := r.runLength(code, header)
+			for ; n > 0; n-- {
+				r.put(r.pixel())
+			}
+		case specialFgBg1, specialFgBg2:
+			r.src = r.src[1:]
+			mask := byte(0x03)
+			if code == specialFgBg2 {
+				mask = 0x05
+			}
+			r.fgbg(mask, fg, 8, firstLine)
+		case specialWhite:
+			r.src = r.src[1:]
+			r.put(white)
+		case specialBlack:
+			r.src = r.src[1:]
+			r.put(0)
+		default:
+			return nil, fmt.Errorf("%w: unknown order %#x", errRLE, header)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.dst, nil
+}
+
+// orderCode extracts the order code from an order header
+func orderCode(header byte) int {
+	switch {
+	case header&0xc0 != 0xc0:
+		return int(header >> 5) // Regular
+	case header&0xf0 == 0xf0:
+		return int(header) // Mega and special
+	}
+	return int(header >> 4) // Lite
+}
+
+// runLength consumes an order header and returns its run length
+func (r *rle) runLength(code int, header byte) int {
+	r.src = r.src[1:]
+	switch code {
+	case regularFgBgImage:
+		if n := int(header & 0x1f); n != 0 {
+			return n * 8
+		}
+		return int(r.byte()) + 1
+	case liteSetFgFgBg:
+		if n := int(header & 0x0f); n != 0 {
+			return n * 8
+		}
+		return int(r.byte()) + 1
+	case regularBgRun, regularFgRun, regularColorRun, regularColorImage:
+		if n := int(header & 0x1f); n != 0 {
+			return n
+		}
+		return int(r.byte()) + 32
+	case liteSetFgFgRun, liteDitheredRun:
+		if n := int(header & 0x0f); n != 0 {
+			return n
+		}
+		return int(r.byte()) + 16
+	}
+	// Mega orders give the length in the next two bytes
+	lo := int(r.byte())
+	return int(r.byte())<<8 | lo
+}
+
+// byte reads one byte of the source
+func (r *rle) byte() byte {
+	if len(r.src) < 1 {
+		r.err = errRLE
+		return 0
+	}
+	b := r.src[0]
+	r.src = r.src[1:]
+	return b
+}
+
+// pixel reads one pixel of the source
+func (r *rle) pixel() uint32 {
+	if len(r.src) < r.pixelSize {
+		r.err = errRLE
+		r.src = nil
+		return 0
+	}
+	var p uint32
+	for i := r.pixelSize - 1; i >= 0; i-- {
+		p = p<<8 | uint32(r.src[i])
+	}
+	r.src = r.src[r.pixelSize:]
+	return p
+}
+
+// above returns the pixel in the row above the next one written, or black
+// on the first row
+func (r *rle) above(firstLine bool) uint32 {
+	if firstLine || r.d < r.rowDelta {
+		return 0
+	}
+	var p uint32
+	for i := r.pixelSize - 1; i >= 0; i-- {
+		p = p<<8 | uint32(r.dst[r.d-r.rowDelta+i])
+	}
+	return p
+}
+
+// put writes one pixel
+func (r *rle) put(p uint32) {
+	if r.d+r.pixelSize > len(r.dst) {
+		r.err = fmt.Errorf("%w: more pixels than the bitmap holds", errRLE)
+		r.src = nil
+		return
+	}
+	for i := 0; i < r.pixelSize; i++ {
+		r.dst[r.d+i] = byte(p >> (8 * i))
+	}
+	r.d += r.pixelSize
+}
+
+// fgbg writes bits pixels, XORing the foreground color into those whose
+// bit in mask is set, least significant bit first
+func (r *rle) fgbg(mask byte, fg uint32, bits int, firstLine bool) {
+	for i := 0; i < bits; i++ {
+		p := r.above(firstLine)
+		if mask&(1<<i) != 0 {
+			p ^= fg
+		}
+		r.put(p)
+	}
+}
@@ -0,0 +1,161 @@
+package rdp
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// Helper function to repeat a 24 bpp pixel, given as 0xRRGGBB
+func bgr(p uint32, n int) []byte {
+	return bytes.Repeat([]byte{byte(p), byte(p >> 8), byte(p >> 16)}, n)
+}
+
+func TestDecompressRLE(t *testing.T) {
+	const red, white = 0xff0000, 0xffffff
+
+	tests := []struct {
+		name   string
+		width  int
+		height int
+		src    []byte
+		want   []byte // Bottom-up rows
+	}{
+		{
+			name:  "color run then background run copying the row above",
+			width: 4, height: 2,
+			src:  append(append([]byte{0x64}, bgr(red, 1)...), 0x04),
+			want: bgr(red, 8),
+		},
+		{
+			name:  "foreground on the first line and an inserted foreground pixel",
+			width: 4, height: 2,
+			// Background 2, foreground 2, then background 1 and 3 on the
+			// second row, the second of which starts with the foreground
+			// XORed into the row above
+			src:  []byte{0x02, 0x22, 0x01, 0x03},
+			want: append(append(bgr(0, 2), bgr(white, 2)...), append(bgr(0, 1), bgr(white, 3)...)...),
+		},
+		{
+			name:  "set foreground and a foreground/background image",
+			width: 8, height: 1,
+			src:  append(append([]byte{0xd1}, bgr(red, 1)...), 0xa5),
+			want: bytes.Join([][]byte{bgr(red, 1), bgr(0, 1), bgr(red, 1), bgr(0, 2), bgr(red, 1), bgr(0, 1), bgr(red, 1)}, nil),
+		},
+		{
+			name:  "mega color image, white, black, and a dithered run",
+			width: 6, height: 1,
+			src: bytes.Join([][]byte{
+				{0xf4, 2, 0}, bgr(0x123456, 1), bgr(0x654321, 1),
+				{0xfd, 0xfe},
+				{0xe1}, bgr(red, 1), bgr(0x00ff00, 1),
+			}, nil),
+			want: bytes.Join([][]byte{bgr(0x123456, 1), bgr(0x654321, 1), bgr(white, 1), bgr(0, 1), bgr(red, 1), bgr(0x00ff00, 1)}, nil),
+		},
+		{
+			name:  "extended run length",
+			width: 40, height: 1,
+			src:  append([]byte{0x60, 8}, bgr(red, 1)...), // 32 + 8
+			want: bgr(red, 40),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decompressRLE(tt.src, tt.width, tt.height, 24)
+			if err != nil {
+				t.Fatalf("decompressRLE failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decompressRLE =\n%x\nwant\n%x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecompressRLE16(t *testing.T) {
+	// A 16 bpp color run, and white is 0xffff
+	got, err := decompressRLE([]byte{0x62, 0x1f, 0x00, 0xfd, 0xfe}, 4, 1, 16)
+	if err != nil {
+		t.Fatalf("decompressRLE failed: %v", err)
+	}
+	if want := []byte{0x1f, 0, 0x1f, 0, 0xff, 0xff, 0, 0}; !bytes.Equal(got, want) {
+		t.Errorf("decompressRLE = %x, want %x", got, want)
+	}
+}
+
+func TestDecompressRLEMalformed(t *testing.T) {
+	tests := map[string][]byte{
+		"truncated pixel":  {0x64, 0xff},
+		"too many pixels":  append([]byte{0x65}, bgr(0, 1)...),
+		"unknown order":    {0xa1},
+		"missing mask":     {0x41},
+		"truncated length": {0xf3, 1},
+	}
+	for name, src := range tests {
+		if _, err := decompressRLE(src, 4, 1, 24); !errors.Is(err, errRLE) {
+			t.Errorf("%s: error = %v, want errRLE", name, err)
+		}
+	}
+}
+
+func TestBitmapUpdate(t *testing.T) {
+	c := &Client{fb: image.NewRGBA(image.Rect(0, 0, 4, 4))}
+
+	// An uncompressed 16 bpp bitmap two pixels wide, padded to four bytes
+	// per row, with the bottom row first, clipped to one pixel wide
+	var data []byte
+	for _, v := range []int{1, 1, 1, 2, 2, 2, 16, 0, 8} {
+		data = append(data, byte(v), byte(v>>8))
+	}
+	data = append(data,
+		0x00, 0xf8, 0x00, 0xf8, // Red, red
+		0x1f, 0x00, 0x1f, 0x00) // Blue, blue
+	update := append([]byte{updateTypeBitmap, 0, 1, 0}, data...)
+	if err := c.bitmapUpdate(update); err != nil {
+		t.Fatalf("bitmapUpdate failed: %v", err)
+	}
+
+	img := c.Image(c.fb.Bounds())
+	if img == nil {
+		t.Fatal("Image is nil after a bitmap update")
+	}
+	if got := img.RGBAAt(1, 1); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("top pixel = %v, want blue", got)
+	}
+	if got := img.RGBAAt(1, 2); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("bottom pixel = %v, want red", got)
+	}
+	if got := img.RGBAAt(2, 1); got != (color.RGBA{}) {
+		t.Errorf("pixel outside the destination = %v, want untouched", got)
+	}
+}
+
+func TestDecodePointer(t *testing.T) {
+	// 24 bpp, 2x1: a blue pixel and one the AND mask makes transparent
+	xorMask := []byte{0xff, 0, 0, 0, 0, 0}
+	andMask := []byte{0x40, 0}
+	p := decodePointer(xorMask, andMask, 2, 1, 24)
+	if p == nil {
+		t.Fatal("decodePointer returned nil")
+	}
+	if got := p.img.NRGBAAt(0, 0); got != (color.NRGBA{0, 0, 255, 255}) {
+		t.Errorf("pixel 0 = %v, want opaque blue", got)
+	}
+	if got := p.img.NRGBAAt(1, 0); got.A != 0 {
+		t.Errorf("pixel 1 = %v, want transparent", got)
+	}
+
+	// 32 bpp with alpha keeps the alpha and ignores the AND mask
+	xorMask = []byte{0, 0, 0xff, 0x80, 0, 0, 0, 0}
+	p = decodePointer(xorMask, []byte{0xc0, 0}, 2, 1, 32)
+	if got := p.img.NRGBAAt(0, 0); got != (color.NRGBA{255, 0, 0, 0x80}) {
+		t.Errorf("32 bpp pixel = %v, want half transparent red", got)
+	}
+
+	if decodePointer(make([]byte, 4), nil, 2, 1, 8) != nil {
+		t.Error("decoded an 8 bpp pointer without a palette")
+	}
+}
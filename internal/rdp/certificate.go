@@ -0,0 +1,136 @@
+package rdp
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrCertificateMismatch means the server presented a certificate other
+// than the pinned or previously seen one, as a man in the middle would
+var ErrCertificateMismatch = errors.New("RDP server certificate does not match")
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate as
+// colon-separated hex
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return formatFingerprint(sum[:])
+}
+
+// ParseFingerprint reads a SHA-256 fingerprint given as hex, with or
+// without colons, and returns it as Fingerprint formats it
+func ParseFingerprint(s string) (string, error) {
+	b, err := hex.DecodeString(strings.NewReplacer(":", "", " ", "").Replace(s))
+	if err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("%q is not a SHA-256 fingerprint of 64 hex digits", s)
+	}
+	return formatFingerprint(b), nil
+}
+
+// formatFingerprint writes a digest as uppercase hex bytes separated by
+// colons, as OpenSSL prints fingerprints
+func formatFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// PinFingerprint returns a Config.Verify that accepts only the
+// certificate with the given SHA-256 fingerprint
+func PinFingerprint(fingerprint string) (func(*x509.Certificate) error, error) {
+	want, err := ParseFingerprint(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	return func(cert *x509.Certificate) error {
+		if got := Fingerprint(cert); got != want {
+			return fmt.Errorf("%w: got %s, want %s", ErrCertificateMismatch, got, want)
+		}
+		return nil
+	}, nil
+}
+
+// KnownHosts trusts each server's certificate the first time it is seen
+// and refuses a different one after that, as SSH does with host keys. The
+// file has one "host:port fingerprint" line per server.
+type KnownHosts struct {
+	Path string
+
+	// Added is called when a server is trusted for the first time, so the
+	// user can compare the fingerprint with the server's
+	Added func(addr, fingerprint string)
+}
+
+// Verify returns a Config.Verify for the server at addr
+func (k KnownHosts) Verify(addr string) func(*x509.Certificate) error {
+	addr = Addr(addr)
+	return func(cert *x509.Certificate) error {
+		got := Fingerprint(cert)
+		want, err := k.lookup(addr)
+		if err != nil {
+			return err
+		}
+		if want == "" {
+			if err := k.add(addr, got); err != nil {
+				return err
+			}
+			if k.Added != nil {
+				k.Added(addr, got)
+			}
+			return nil
+		}
+		if got != want {
+			return fmt.Errorf("%w: %s presented %s, but %s records %s; if the certificate was replaced, remove that line",
+				ErrCertificateMismatch, addr, got, k.Path, want)
+		}
+		return nil
+	}
+}
+
+// lookup returns the recorded fingerprint for addr, or "" if there is none
+func (k KnownHosts) lookup(addr string) (string, error) {
+	f, err := os.Open(k.Path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read known RDP hosts: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == addr {
+			return fields[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read known RDP hosts: %w", err)
+	}
+	return "", nil
+}
+
+// add records the fingerprint for addr
+func (k KnownHosts) add(addr, fingerprint string) error {
+	if err := os.MkdirAll(filepath.Dir(k.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	f, err := os.OpenFile(k.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to record known RDP host: %w", err)
+	}
+	if _, err := fmt.Fprintf(f, "%s %s\n", addr, fingerprint); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to record known RDP host: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,94 @@
+package rdp
+
+import (
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Helper function to generate a parsed self-signed certificate
+func testLeaf(t *testing.T) *x509.Certificate {
+	leaf, err := x509.ParseCertificate(testCertificate(t).Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf
+}
+
+func TestParseFingerprint(t *testing.T) {
+	want := strings.Repeat("AB:", 31) + "AB"
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{strings.Repeat("ab", 32), want, false},
+		{strings.Repeat("AB:", 31) + "AB", want, false},
+		{strings.Repeat("ab ", 32), want, false},
+		{strings.Repeat("ab", 20), "", true}, // SHA-1
+		{strings.Repeat("zz", 32), "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFingerprint(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFingerprint(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFingerprint(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPinFingerprint(t *testing.T) {
+	leaf := testLeaf(t)
+	verify, err := PinFingerprint(strings.ToLower(strings.ReplaceAll(Fingerprint(leaf), ":", "")))
+	if err != nil {
+		t.Fatalf("PinFingerprint() failed: %v", err)
+	}
+	if err := verify(leaf); err != nil {
+		t.Errorf("pinned certificate rejected: %v", err)
+	}
+	if err := verify(testLeaf(t)); !errors.Is(err, ErrCertificateMismatch) {
+		t.Errorf("other certificate error = %v, want ErrCertificateMismatch", err)
+	}
+	if _, err := PinFingerprint("not hex"); err == nil {
+		t.Error("PinFingerprint() accepted a malformed fingerprint")
+	}
+}
+
+func TestKnownHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "witness", "known_rdp_hosts")
+	var added []string
+	hosts := KnownHosts{Path: path, Added: func(addr, fingerprint string) { added = append(added, addr) }}
+	first, second := testLeaf(t), testLeaf(t)
+
+	if err := hosts.Verify("winserver")(first); err != nil {
+		t.Fatalf("first certificate rejected: %v", err)
+	}
+	if err := hosts.Verify("winserver:3389")(first); err != nil {
+		t.Errorf("same certificate rejected on the next connection: %v", err)
+	}
+	if err := hosts.Verify("winserver")(second); !errors.Is(err, ErrCertificateMismatch) {
+		t.Errorf("changed certificate error = %v, want ErrCertificateMismatch", err)
+	}
+	if err := hosts.Verify("other")(second); err != nil {
+		t.Errorf("another server's certificate rejected: %v", err)
+	}
+	if want := []string{"winserver:3389", "other:3389"}; strings.Join(added, " ") != strings.Join(want, " ") {
+		t.Errorf("Added called for %v, want %v", added, want)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("known hosts not written: %v", err)
+	}
+	if want := "winserver:3389 " + Fingerprint(first) + "\n"; !strings.HasPrefix(string(data), want) {
+		t.Errorf("known hosts = %q, want it to start with %q", data, want)
+	}
+}
@@ -0,0 +1,708 @@
+package rdp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// fakeServer scripts the server side of an RDP connection
+type fakeServer struct {
+	t        *testing.T
+	raw      net.Conn
+	conn     net.Conn // TLS once negotiated
+	cert     tls.Certificate
+	protocol uint32 // Security protocol to pick
+	failure  uint32 // Negotiation failure code to send instead, if set
+	password string // Checked with NLA
+	width    int
+	height   int
+
+	done      chan struct{} // Closed when the connection sequence is over
+	requested image.Point   // Desktop size the client asked for
+	user      string        // User name in the client info PDU
+}
+
+const testShareID = 0x000103ea
+
+// Helper function to start a client against a fake server. The server's
+// certificate is pinned unless config has its own check.
+func dialFake(t *testing.T, server *fakeServer, config Config) (*Client, error) {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close(); serverConn.Close() })
+	server.t = t
+	server.raw = serverConn
+	server.done = make(chan struct{})
+	server.cert = testCertificate(t)
+	if server.protocol == 0 {
+		// Most tests are about what follows signing in
+		server.protocol = protocolSSL
+		config.AllowTLSOnly = true
+	}
+	if config.Verify == nil {
+		config.Verify = pin(t, server.cert)
+	}
+	config.Size = image.Pt(1920, 1080)
+
+	go server.handshake()
+	return NewClient(clientConn, config)
+}
+
+// Helper function to pin a certificate
+func pin(t *testing.T, cert tls.Certificate) func(*x509.Certificate) error {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify, err := PinFingerprint(Fingerprint(leaf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verify
+}
+
+// Helper function to generate a self-signed certificate
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "witness-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (s *fakeServer) handshake() {
+	if _, err := readTPKT(s.raw); err != nil {
+		s.t.Errorf("server failed to read the connection request: %v", err)
+		return
+	}
+	cc := []byte{14, 0xd0, 0, 0, 0x12, 0x34, 0}
+	if s.failure != 0 {
+		cc = binary.LittleEndian.AppendUint32(append(cc, 3, 0, 8, 0), s.failure)
+		s.raw.Write(tpkt(cc))
+		return
+	}
+	cc = binary.LittleEndian.AppendUint32(append(cc, 2, 0, 8, 0), s.protocol)
+	s.raw.Write(tpkt(cc))
+
+	conn := tls.Server(s.raw, &tls.Config{Certificates: []tls.Certificate{s.cert}})
+	if err := conn.Handshake(); err != nil {
+		return // The client refused the certificate
+	}
+	s.conn = conn
+	if s.protocol == protocolHybrid && !s.nla(s.cert.Certificate[0]) {
+		return
+	}
+
+	initial := s.readX224()
+	if at := bytes.Index(initial, []byte{0x01, 0xc0, 216, 0}); at >= 0 && at+12 <= len(initial) {
+		s.requested = image.Pt(int(binary.LittleEndian.Uint16(initial[at+8:])), int(binary.LittleEndian.Uint16(initial[at+10:])))
+	}
+	s.writeX224(connectResponse(1003))
+	s.readX224() // Erect domain
+	s.readX224() // Attach user
+	s.writeX224([]byte{mcsAttachUserConfirm << 2, 0, 0, 6})
+	for i := 0; i < 2; i++ {
+		join := s.readX224()
+		s.writeX224(append(append([]byte{mcsChannelJoinConfirm << 2, 0}, join[1:5]...), join[3:5]...))
+	}
+
+	info := s.readData()
+	if len(info) < 22 || binary.LittleEndian.Uint16(info)&secInfoPacket == 0 {
+		s.t.Errorf("client info PDU = %x", info)
+		return
+	}
+	domainLen, userLen := int(binary.LittleEndian.Uint16(info[12:])), int(binary.LittleEndian.Uint16(info[14:]))
+	s.user = fromUTF16(info[22+domainLen+2:][:userLen])
+
+	// A valid client license error, as servers without RDS licensing send
+	license := []byte{secLicensePacket, 0, 0, 0, licenseErrorAlert, 3, 16, 0}
+	license = binary.LittleEndian.AppendUint32(license, licenseValidClient)
+	license = binary.LittleEndian.AppendUint32(license, 2)
+	s.writeData(append(license, 4, 0, 0, 0))
+
+	s.activate(s.width, s.height)
+	close(s.done)
+}
+
+// activate sends a demand active PDU and reads the client's confirm active
+// and finalization PDUs
+func (s *fakeServer) activate(width, height int) {
+	bitmap := []byte{2, 0, 28, 0, 24, 0, 1, 0, 1, 0, 1, 0}
+	bitmap = binary.LittleEndian.AppendUint16(bitmap, uint16(width))
+	bitmap = binary.LittleEndian.AppendUint16(bitmap, uint16(height))
+	bitmap = append(bitmap, make([]byte, 12)...)
+
+	body := binary.LittleEndian.AppendUint32(nil, testShareID)
+	body = binary.LittleEndian.AppendUint16(body, 4)
+	body = binary.LittleEndian.AppendUint16(body, uint16(4+len(bitmap)))
+	body = append(body, "RDP\x00"...)
+	body = append(body, 1, 0, 0, 0)
+	body = append(body, bitmap...)
+	s.writeData(shareControl(pduDemandActive, append(body, 0, 0, 0, 0)))
+
+	confirm := s.readData()
+	if len(confirm) < 10 || binary.LittleEndian.Uint16(confirm[2:])&0xf != pduConfirmActive {
+		s.t.Errorf("client sent %x, want a confirm active PDU", confirm)
+		return
+	}
+	if got := binary.LittleEndian.Uint32(confirm[6:]); got != testShareID {
+		s.t.Errorf("confirm active share ID = %#x, want %#x", got, testShareID)
+	}
+	for _, want := range []byte{pdu2Synchronize, pdu2Control, pdu2Control, pdu2FontList} {
+		if pdu := s.readData(); len(pdu) < 15 || pdu[14] != want {
+			s.t.Errorf("client sent %x, want share data PDU type %#x", pdu, want)
+		}
+	}
+}
+
+// nla authenticates the client with CredSSP, reporting whether it gave
+// the right password
+func (s *fakeServer) nla(der []byte) bool {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		s.t.Error(err)
+		return false
+	}
+	var spki struct {
+		Algorithm asn1.RawValue
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		s.t.Error(err)
+		return false
+	}
+	publicKey := spki.PublicKey.Bytes
+
+	if _, err := readTSRequest(s.conn); err != nil {
+		s.t.Errorf("server failed to read the NTLM negotiate message: %v", err)
+		return false
+	}
+	serverChallenge := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	targetInfo := []byte{2, 0, 2, 0, 'D', 0} // NetBIOS domain name
+	targetInfo = append(targetInfo, avTimestamp, 0, 8, 0)
+	targetInfo = binary.LittleEndian.AppendUint64(targetInfo, fileTime(time.Now()))
+	targetInfo = append(targetInfo, 0, 0, 0, 0)
+	writeTSRequest(s.conn, tsRequest{Version: credsspVersion, NegoTokens: []negoToken{{challengeMessage(serverChallenge, targetInfo)}}})
+
+	req, err := readTSRequest(s.conn)
+	if err != nil || len(req.NegoTokens) == 0 {
+		s.t.Errorf("server failed to read the NTLM authenticate message: %v", err)
+		return false
+	}
+	auth := req.NegoTokens[0].Token
+	nt, _ := messageField(auth, 20)
+	domain, _ := messageField(auth, 28)
+	user, _ := messageField(auth, 36)
+	encryptedKey, _ := messageField(auth, 52)
+	if len(nt) < 16 {
+		s.t.Errorf("NT response = %x", nt)
+		return false
+	}
+
+	key := ntowfv2(fromUTF16(user), fromUTF16(domain), s.password)
+	mac := hmac.New(md5.New, key)
+	mac.Write(serverChallenge)
+	mac.Write(nt[16:])
+	proof := mac.Sum(nil)
+	if !hmac.Equal(proof, nt[:16]) {
+		writeTSRequest(s.conn, tsRequest{Version: credsspVersion, ErrorCode: 0xc000006d})
+		return false
+	}
+	mac = hmac.New(md5.New, key)
+	mac.Write(proof)
+	exportedKey := rc4Encrypt(mac.Sum(nil), encryptedKey)
+
+	// The server's side of the NTLM keys
+	n := &ntlm{
+		clientSign: signingKey(exportedKey, "server-to-client"),
+		serverSign: signingKey(exportedKey, "client-to-server"),
+	}
+	n.clientSeal, _ = rc4.NewCipher(sealingKey(exportedKey, "server-to-client"))
+	n.serverSeal, _ = rc4.NewCipher(sealingKey(exportedKey, "client-to-server"))
+
+	hash, err := n.unseal(req.PubKeyAuth)
+	if err != nil || !bytes.Equal(hash, bindingHash(clientServerHashMagic, req.ClientNonce, publicKey)) {
+		s.t.Errorf("client public key hash = %x, %v", hash, err)
+		return false
+	}
+	writeTSRequest(s.conn, tsRequest{Version: credsspVersion, PubKeyAuth: n.seal(bindingHash(serverClientHashMagic, req.ClientNonce, publicKey))})
+
+	req, err = readTSRequest(s.conn)
+	if err != nil {
+		s.t.Errorf("server failed to read the credentials: %v", err)
+		return false
+	}
+	msg, err := n.unseal(req.AuthInfo)
+	if err != nil {
+		s.t.Errorf("failed to unseal the credentials: %v", err)
+		return false
+	}
+	var creds tsCredentials
+	var password tsPasswordCreds
+	if _, err := asn1.Unmarshal(msg, &creds); err != nil {
+		s.t.Error(err)
+		return false
+	}
+	if _, err := asn1.Unmarshal(creds.Credentials, &password); err != nil {
+		s.t.Error(err)
+		return false
+	}
+	if got := fromUTF16(password.Password); got != s.password {
+		s.t.Errorf("delegated password = %q, want %q", got, s.password)
+	}
+	return true
+}
+
+// Helper function to build an NTLM challenge message
+func challengeMessage(serverChallenge, targetInfo []byte) []byte {
+	b := append([]byte(nil), ntlmSignature...)
+	b = append(b, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	b = binary.LittleEndian.AppendUint32(b, ntlmClientFlags)
+	b = append(b, serverChallenge...)
+	b = append(b, make([]byte, 8)...)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(targetInfo)))
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(targetInfo)))
+	b = binary.LittleEndian.AppendUint32(b, 48)
+	return append(b, targetInfo...)
+}
+
+// Helper function to decode little-endian UTF-16
+func fromUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// Helper function to build an MCS connect response with no encryption
+// and the given I/O channel
+func connectResponse(ioChannel uint16) []byte {
+	blocks := []byte{0x01, 0x0c, 12, 0, 4, 0, 8, 0, 1, 0, 0, 0}                          // SC_CORE
+	blocks = append(blocks, 0x02, 0x0c, 12, 0, 0, 0, 0, 0, 0, 0, 0, 0)                   // SC_SECURITY
+	blocks = append(blocks, 0x03, 0x0c, 8, 0, byte(ioChannel), byte(ioChannel>>8), 0, 0) // SC_NET
+
+	gcc := []byte{0, 5, 0, 0x14, 0x7c, 0, 1, 0x2a, 0x14, 0x76, 0x0a, 1, 1, 0, 1, 0xc0, 0}
+	gcc = append(gcc, "McDn"...)
+	gcc = append(gcc, perLength(len(blocks))...)
+	gcc = append(gcc, blocks...)
+
+	var b []byte
+	b = berTLV(b, 0x0a, []byte{0})
+	b = berTLV(b, 0x02, []byte{0})
+	b = domainParameters(b, 34, 3, 0, 1, 0, 1, 65528, 2)
+	b = berTLV(b, 0x04, gcc)
+	return append([]byte{0x7f, 0x66}, append(berLength(len(b)), b...)...)
+}
+
+func (s *fakeServer) write(b []byte) {
+	if _, err := s.conn.Write(b); err != nil {
+		s.t.Errorf("server write failed: %v", err)
+	}
+}
+
+func (s *fakeServer) writeX224(data []byte) {
+	s.write(tpkt(append([]byte{2, 0xf0, 0x80}, data...)))
+}
+
+// writeData sends an MCS send data indication on the I/O channel
+func (s *fakeServer) writeData(data []byte) {
+	b := []byte{mcsSendDataIndication << 2, 0, 1, 0x03, 0xeb, 0x70}
+	b = append(b, perLength(len(data))...)
+	s.writeX224(append(b, data...))
+}
+
+func (s *fakeServer) readX224() []byte {
+	payload, err := readTPKT(s.conn)
+	if err != nil || len(payload) < 3 {
+		s.t.Errorf("server read failed: %v", err)
+		return nil
+	}
+	return payload[3:]
+}
+
+// readData reads the data of an MCS send data request
+func (s *fakeServer) readData() []byte {
+	data := s.readX224()
+	if len(data) < 7 {
+		s.t.Errorf("client sent %x, want an MCS send data request", data)
+		return nil
+	}
+	if data[6]&0x80 != 0 {
+		return data[8:]
+	}
+	return data[7:]
+}
+
+// Helper function to add a share control header
+func shareControl(kind uint16, body []byte) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, uint16(6+len(body)))
+	b = binary.LittleEndian.AppendUint16(b, kind|0x10)
+	b = binary.LittleEndian.AppendUint16(b, serverChannel)
+	return append(b, body...)
+}
+
+// Helper function to wrap data in share data and share control headers
+func shareData(kind byte, data []byte) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, testShareID)
+	b = append(b, 0, 1)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(data)))
+	b = append(b, kind, 0, 0, 0)
+	return shareControl(pduData, append(b, data...))
+}
+
+// Helper function to frame fast-path updates
+func fastPathMessage(updates ...[]byte) []byte {
+	body := bytes.Join(updates, nil)
+	n := 3 + len(body)
+	return append([]byte{0, 0x80 | byte(n>>8), byte(n)}, body...)
+}
+
+// Helper function to encode one fast-path update
+func fastPathEntry(code, fragment byte, data []byte) []byte {
+	b := binary.LittleEndian.AppendUint16([]byte{code | fragment<<4}, uint16(len(data)))
+	return append(b, data...)
+}
+
+// Helper function to encode an uncompressed 32 bpp bitmap update of one
+// color
+func bitmapData(r image.Rectangle, c color.RGBA) []byte {
+	b := []byte{updateTypeBitmap, 0, 1, 0}
+	for _, v := range []int{r.Min.X, r.Min.Y, r.Max.X - 1, r.Max.Y - 1, r.Dx(), r.Dy(), 32, 0, 4 * r.Dx() * r.Dy()} {
+		b = binary.LittleEndian.AppendUint16(b, uint16(v))
+	}
+	for i := 0; i < r.Dx()*r.Dy(); i++ {
+		b = append(b, c.B, c.G, c.R, 0)
+	}
+	return b
+}
+
+func TestClientConnect(t *testing.T) {
+	server := &fakeServer{width: 4, height: 3}
+	client, err := dialFake(t, server, Config{Login: ParseLogin(`CORP\alice`, "s3cret")})
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	<-server.done
+	if server.requested != image.Pt(1920, 1080) {
+		t.Errorf("requested size = %v, want 1920x1080", server.requested)
+	}
+	if server.user != "alice" {
+		t.Errorf("client info user = %q, want alice", server.user)
+	}
+	if w, h := client.Size(); w != 4 || h != 3 {
+		t.Errorf("Size() = %dx%d, want the server's 4x3", w, h)
+	}
+	if client.Image(image.Rect(0, 0, 4, 3)) != nil {
+		t.Error("Image() before the first update should be nil")
+	}
+}
+
+func TestClientNLA(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{"correct password", "s3cret", false},
+		{"wrong password", "guess", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeServer{protocol: protocolHybrid, password: "s3cret", width: 2, height: 2}
+			_, err := dialFake(t, server, Config{Login: Login{User: "alice", Domain: "CORP", Password: tt.password}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrAuthFailed) {
+				t.Errorf("NewClient() error = %v, want ErrAuthFailed", err)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "wrong user name or password") {
+				t.Errorf("NewClient() error = %v, want the NTSTATUS explained", err)
+			}
+		})
+	}
+}
+
+func TestClientTLSOnly(t *testing.T) {
+	server := &fakeServer{protocol: protocolSSL, width: 2, height: 2}
+	_, err := dialFake(t, server, Config{Login: Login{User: "alice", Password: "s3cret"}})
+	if !errors.Is(err, ErrTLSOnly) {
+		t.Errorf("NewClient() error = %v, want ErrTLSOnly", err)
+	}
+
+	server = &fakeServer{protocol: protocolSSL, width: 2, height: 2}
+	if _, err := dialFake(t, server, Config{Login: Login{User: "alice", Password: "s3cret"}, AllowTLSOnly: true}); err != nil {
+		t.Fatalf("NewClient() with AllowTLSOnly failed: %v", err)
+	}
+	<-server.done
+	if server.user != "alice" {
+		t.Errorf("client info user = %q, want alice", server.user)
+	}
+}
+
+func TestClientNegotiationFailure(t *testing.T) {
+	server := &fakeServer{failure: 5}
+	_, err := dialFake(t, server, Config{Login: Login{User: "alice"}})
+	if err == nil || !strings.Contains(err.Error(), "requires Network Level Authentication") {
+		t.Errorf("NewClient() error = %v, want the negotiation failure explained", err)
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	other, err := PinFingerprint(strings.Repeat("00", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeServer{width: 2, height: 2}
+	_, err = dialFake(t, server, Config{Login: Login{User: "alice", Password: "s3cret"}, Verify: other})
+	if !errors.Is(err, ErrCertificateMismatch) {
+		t.Errorf("NewClient() error = %v, want ErrCertificateMismatch", err)
+	}
+
+	if err := verifyCertificate(nil, []*x509.Certificate{{}}); err == nil {
+		t.Error("verifyCertificate() accepted a certificate with no check")
+	}
+}
+
+func TestClientFastPathBitmap(t *testing.T) {
+	server := &fakeServer{width: 4, height: 3}
+	client, err := dialFake(t, server, Config{Login: Login{User: "alice"}})
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	<-server.done
+
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	fragmented := bitmapData(image.Rect(0, 2, 4, 3), blue)
+	go func() {
+		server.write(fastPathMessage(fastPathEntry(fastPathBitmap, fragmentSingle, bitmapData(image.Rect(1, 1, 3, 2), red))))
+		server.write(fastPathMessage(
+			fastPathEntry(fastPathBitmap, fragmentFirst, fragmented[:10]),
+			fastPathEntry(fastPathBitmap, fragmentNext, fragmented[10:20])))
+		server.write(fastPathMessage(fastPathEntry(fastPathBitmap, fragmentLast, fragmented[20:])))
+	}()
+	for i := 0; i < 3; i++ {
+		if err := client.Update(); err != nil {
+			t.Fatalf("Update() failed: %v", err)
+		}
+	}
+
+	img := client.Image(image.Rect(0, 0, 4, 3))
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, color.RGBA{A: 255}},
+		{1, 1, red},
+		{2, 1, red},
+		{3, 1, color.RGBA{A: 255}},
+		{0, 2, blue},
+		{3, 2, blue},
+	}
+	for _, tt := range tests {
+		if got := img.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("pixel (%d,%d) = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	crop := client.Image(image.Rect(1, 1, 3, 2))
+	if crop.Bounds() != image.Rect(0, 0, 2, 1) || crop.RGBAAt(1, 0) != red {
+		t.Errorf("cropped image = %v with %v, want 2x1 red", crop.Bounds(), crop.RGBAAt(1, 0))
+	}
+}
+
+func TestClientSlowPathBitmap(t *testing.T) {
+	server := &fakeServer{width: 2, height: 2}
+	client, err := dialFake(t, server, Config{Login: Login{User: "alice"}})
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	<-server.done
+
+	green := color.RGBA{G: 255, A: 255}
+	go server.writeData(shareData(pdu2Update, bitmapData(image.Rect(0, 0, 2, 2), green)))
+	if err := client.Update(); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if got := client.Image(image.Rect(0, 0, 2, 2)).RGBAAt(1, 1); got != green {
+		t.Errorf("pixel = %v, want %v", got, green)
+	}
+}
+
+func TestClientPointer(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor bool
+	}{
+		{"pointer drawn", true},
+		{"pointer left out", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeServer{width: 4, height: 4}
+			client, err := dialFake(t, server, Config{Login: Login{User: "alice"}, Cursor: tt.cursor})
+			if err != nil {
+				t.Fatalf("NewClient() failed: %v", err)
+			}
+			<-server.done
+
+			// An opaque green 2x2 pointer with its hot spot at (1,1),
+			// moved with a fast-path update and then a slow-path one
+			shape := []byte{0, 0, 1, 0, 1, 0, 2, 0, 2, 0, 4, 0, 12, 0}
+			shape = append(shape, bytes.Repeat([]byte{0, 255, 0}, 4)...)
+			shape = append(shape, 0, 0, 0, 0)
+			go func() {
+				server.write(fastPathMessage(
+					fastPathEntry(fastPathBitmap, fragmentSingle, bitmapData(image.Rect(0, 0, 4, 4), color.RGBA{})),
+					fastPathEntry(fastPathPointerColor, fragmentSingle, shape),
+					fastPathEntry(fastPathPointerPosition, fragmentSingle, []byte{1, 0, 1, 0})))
+				server.writeData(shareData(pdu2Pointer, []byte{pointerPosition, 0, 0, 0, 2, 0, 2, 0}))
+			}()
+			for i := 0; i < 2; i++ {
+				if err := client.Update(); err != nil {
+					t.Fatalf("Update() failed: %v", err)
+				}
+			}
+
+			img := client.Image(image.Rect(0, 0, 4, 4))
+			black, green := color.RGBA{A: 255}, color.RGBA{G: 255, A: 255}
+			want := black
+			if tt.cursor {
+				want = green
+			}
+			if got := img.RGBAAt(1, 1); got != want {
+				t.Errorf("pixel under the pointer = %v, want %v", got, want)
+			}
+			if got := img.RGBAAt(2, 2); got != want {
+				t.Errorf("pixel under the pointer = %v, want %v", got, want)
+			}
+			if got := img.RGBAAt(0, 0); got != black {
+				t.Errorf("pixel beside the pointer = %v, want %v", got, black)
+			}
+
+			go server.write(fastPathMessage(fastPathEntry(fastPathPointerHidden, fragmentSingle, nil)))
+			if err := client.Update(); err != nil {
+				t.Fatalf("Update() failed: %v", err)
+			}
+			if got := client.Image(image.Rect(0, 0, 4, 4)).RGBAAt(1, 1); got != black {
+				t.Errorf("pixel after hiding the pointer = %v, want %v", got, black)
+			}
+		})
+	}
+}
+
+func TestClientDisconnect(t *testing.T) {
+	server := &fakeServer{width: 2, height: 2}
+	client, err := dialFake(t, server, Config{Login: Login{User: "alice"}})
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	<-server.done
+
+	go func() {
+		server.writeData(shareData(pdu2SetErrorInfo, binary.LittleEndian.AppendUint32(nil, 0x5)))
+		server.writeX224([]byte{mcsDisconnectUltimatum<<2 | 1, 0x80})
+	}()
+	if err := client.Update(); err != nil {
+		t.Fatalf("Update() failed on the error info: %v", err)
+	}
+	err = client.Update()
+	if err == nil || !strings.Contains(err.Error(), "another connection took over") {
+		t.Errorf("Update() error = %v, want the disconnect reason", err)
+	}
+}
+
+func TestClientReactivation(t *testing.T) {
+	server := &fakeServer{width: 4, height: 3}
+	client, err := dialFake(t, server, Config{Login: Login{User: "alice"}})
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	<-server.done
+
+	// The same size is accepted, as after the remote screen is locked
+	go server.activate(4, 3)
+	if err := client.Update(); err != nil {
+		t.Fatalf("Update() failed on reactivation: %v", err)
+	}
+
+	go server.writeData(shareControl(pduDemandActive, func() []byte {
+		body := binary.LittleEndian.AppendUint32(nil, testShareID)
+		body = append(body, 4, 0, 32, 0)
+		body = append(body, "RDP\x00"...)
+		body = append(body, 1, 0, 0, 0, 2, 0, 28, 0, 24, 0, 1, 0, 1, 0, 1, 0, 8, 0, 6, 0)
+		return append(body, make([]byte, 16)...)
+	}()))
+	err = client.Update()
+	if err == nil || !strings.Contains(err.Error(), "resized from 4x3 to 8x6") {
+		t.Errorf("Update() error = %v, want the resize reported", err)
+	}
+}
+
+func TestParseLogin(t *testing.T) {
+	tests := []struct {
+		user string
+		want Login
+	}{
+		{"alice", Login{User: "alice", Password: "pw"}},
+		{`CORP\alice`, Login{User: "alice", Domain: "CORP", Password: "pw"}},
+		{"alice@corp.example", Login{User: "alice@corp.example", Password: "pw"}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseLogin(tt.user, "pw"); got != tt.want {
+			t.Errorf("ParseLogin(%q) = %+v, want %+v", tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestAddr(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"winserver", "winserver:3389"},
+		{"winserver:3390", "winserver:3390"},
+		{"::1", "[::1]:3389"},
+		{"[::1]", "[::1]:3389"},
+	}
+
+	for _, tt := range tests {
+		if got := Addr(tt.in); got != tt.want {
+			t.Errorf("Addr(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,807 @@
+package rdp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"net"
+	"unicode/utf16"
+)
+
+// tpktVersion starts every slow-path packet
+const tpktVersion = 3
+
+// Security protocols negotiated in the X.224 connection request
+const (
+	protocolRDP    = 0 // Standard RDP Security, which is not supported
+	protocolSSL    = 1
+	protocolHybrid = 2 // TLS with CredSSP
+)
+
+// MCS domain PDUs, as the first byte's upper six bits
+const (
+	mcsDisconnectUltimatum = 8
+	mcsAttachUserConfirm   = 11
+	mcsChannelJoinConfirm  = 15
+	mcsSendDataIndication  = 26
+)
+
+// Security header flags
+const (
+	secInfoPacket    = 0x0040
+	secLicensePacket = 0x0080
+)
+
+// Licensing
+const (
+	licenseErrorAlert  = 0xff
+	licenseNewLicense  = 0x03
+	licenseUpgrade     = 0x04
+	licenseValidClient = 0x07
+)
+
+// serverChannel is the MCS channel the server sends from
+const serverChannel = 1002
+
+// mcsUserBase offsets MCS user IDs as they are encoded
+const mcsUserBase = 1001
+
+var errShortPDU = errors.New("truncated RDP message")
+
+// connect runs the connection sequence up to the first activation
+func (c *Client) connect(config Config) error {
+	protocol, err := c.negotiate(config.Login)
+	if err != nil {
+		return err
+	}
+	if protocol == protocolSSL && !config.AllowTLSOnly {
+		return ErrTLSOnly
+	}
+
+	tlsConn := tls.Client(c.conn, &tls.Config{
+		// The chain is not checked against certificate authorities; Verify
+		// checks the certificate itself before the handshake completes
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return verifyCertificate(config.Verify, state.PeerCertificates)
+		},
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("RDP TLS handshake failed: %w", err)
+	}
+	if protocol == protocolHybrid {
+		if err := authenticateNLA(tlsConn, tlsConn.ConnectionState().PeerCertificates[0], config.Login); err != nil {
+			return err
+		}
+	}
+	c.conn = tlsConn
+	c.r = bufio.NewReader(tlsConn)
+
+	if err := c.connectMCS(config.Size, protocol); err != nil {
+		return err
+	}
+	if err := c.sendInfo(config.Login); err != nil {
+		return err
+	}
+	data, err := c.license()
+	if err != nil {
+		return err
+	}
+	for c.fb == nil {
+		if data == nil {
+			if data, err = c.readMCS(); err != nil {
+				return err
+			}
+		}
+		if err := c.shareControl(data); err != nil {
+			return err
+		}
+		data = nil
+	}
+	return nil
+}
+
+// negotiate sends the X.224 connection request and returns the security
+// protocol the server picked
+func (c *Client) negotiate(login Login) (uint32, error) {
+	name := login.User
+	if len(name) > 9 {
+		name = name[:9]
+	}
+	cookie := "Cookie: mstshash=" + name + "\r\n"
+	req := []byte{byte(6 + len(cookie) + 8), 0xe0, 0, 0, 0, 0, 0}
+	req = append(req, cookie...)
+	req = append(req, 1, 0, 8, 0) // RDP_NEG_REQ
+	req = binary.LittleEndian.AppendUint32(req, protocolSSL|protocolHybrid)
+	if _, err := c.conn.Write(tpkt(req)); err != nil {
+		return 0, err
+	}
+
+	resp, err := readTPKT(c.conn)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read RDP connection confirm: %w", err)
+	}
+	if len(resp) < 7 || resp[1]&0xf0 != 0xd0 {
+		return 0, errors.New("not an RDP server")
+	}
+	neg := resp[7:]
+	if len(neg) < 8 {
+		return 0, errors.New("RDP server only offers Standard RDP Security, which is not supported; enable TLS on the server")
+	}
+	value := binary.LittleEndian.Uint32(neg[4:])
+	switch neg[0] {
+	case 2: // RDP_NEG_RSP
+		if value != protocolSSL && value != protocolHybrid {
+			return 0, fmt.Errorf("RDP server picked unsupported security protocol %d", value)
+		}
+		return value, nil
+	case 3: // RDP_NEG_FAILURE
+		return 0, negotiationFailure(value)
+	}
+	return 0, fmt.Errorf("unexpected RDP negotiation response type %d", neg[0])
+}
+
+// verifyCertificate checks the server's certificate with verify
+func verifyCertificate(verify func(*x509.Certificate) error, certs []*x509.Certificate) error {
+	if len(certs) == 0 {
+		return errors.New("RDP server sent no TLS certificate")
+	}
+	if verify == nil {
+		return errors.New("RDP server certificate cannot be checked without a pinned fingerprint")
+	}
+	return verify(certs[0])
+}
+
+// negotiationFailure explains why the server refused every protocol
+func negotiationFailure(code uint32) error {
+	switch code {
+	case 2:
+		return errors.New("RDP server only allows Standard RDP Security, which is not supported")
+	case 3:
+		return errors.New("RDP server has no TLS certificate")
+	case 5:
+		return errors.New("RDP server requires Network Level Authentication")
+	case 6:
+		return errors.New("RDP server requires TLS client certificates")
+	}
+	return fmt.Errorf("RDP server refused the connection (negotiation failure %d)", code)
+}
+
+// connectMCS sets up the MCS domain: the conference with the client's
+// settings, the client's user channel, and the I/O channel
+func (c *Client) connectMCS(size image.Point, protocol uint32) error {
+	blocks := clientCoreData(size, protocol)
+	blocks = append(blocks, clientSecurityData()...)
+	blocks = append(blocks, clientNetworkData()...)
+	if err := c.writeX224(connectInitial(conferenceCreateRequest(blocks))); err != nil {
+		return err
+	}
+
+	resp, err := c.readX224()
+	if err != nil {
+		return fmt.Errorf("failed to read MCS connect response: %w", err)
+	}
+	if err := c.readConnectResponse(resp); err != nil {
+		return err
+	}
+
+	if err := c.writeX224([]byte{0x04, 0x01, 0x00, 0x01, 0x00}); err != nil { // Erect domain
+		return err
+	}
+	if err := c.writeX224([]byte{0x28}); err != nil { // Attach user
+		return err
+	}
+	resp, err = c.readX224()
+	if err != nil {
+		return err
+	}
+	if len(resp) < 4 || resp[0]>>2 != mcsAttachUserConfirm || resp[1] != 0 {
+		return errors.New("RDP server refused to attach an MCS user")
+	}
+	c.userID = binary.BigEndian.Uint16(resp[2:]) + mcsUserBase
+
+	for _, channel := range []uint16{c.userID, c.ioChannel} {
+		req := []byte{0x38}
+		req = binary.BigEndian.AppendUint16(req, c.userID-mcsUserBase)
+		req = binary.BigEndian.AppendUint16(req, channel)
+		if err := c.writeX224(req); err != nil {
+			return err
+		}
+		resp, err := c.readX224()
+		if err != nil {
+			return err
+		}
+		if len(resp) < 2 || resp[0]>>2 != mcsChannelJoinConfirm || resp[1] != 0 {
+			return fmt.Errorf("RDP server refused to join MCS channel %d", channel)
+		}
+	}
+	return nil
+}
+
+// readConnectResponse reads the server's settings from the MCS connect
+// response
+func (c *Client) readConnectResponse(resp []byte) error {
+	if len(resp) < 2 || resp[0] != 0x7f || resp[1] != 0x66 {
+		return errors.New("unexpected MCS connect response")
+	}
+	body, _, err := berValue(resp[2:])
+	if err != nil {
+		return err
+	}
+	var fields [4][]byte // result, calledConnectId, domainParameters, userData
+	for i := range fields {
+		if len(body) < 1 {
+			return errShortPDU
+		}
+		if fields[i], body, err = berValue(body[1:]); err != nil {
+			return err
+		}
+	}
+	if len(fields[0]) != 1 || fields[0][0] != 0 {
+		return fmt.Errorf("RDP server refused the MCS connection (result %v)", fields[0])
+	}
+
+	// The server data blocks follow the H.221 key in the GCC response
+	userData := fields[3]
+	at := bytes.Index(userData, []byte("McDn"))
+	if at < 0 || at+5 > len(userData) {
+		return errors.New("malformed GCC conference create response")
+	}
+	blocks := userData[at+4:]
+	if blocks[0]&0x80 != 0 {
+		blocks = blocks[2:]
+	} else {
+		blocks = blocks[1:]
+	}
+
+	for len(blocks) >= 4 {
+		kind := binary.LittleEndian.Uint16(blocks)
+		n := int(binary.LittleEndian.Uint16(blocks[2:]))
+		if n < 4 || n > len(blocks) {
+			return errShortPDU
+		}
+		block := blocks[4:n]
+		blocks = blocks[n:]
+
+		switch kind {
+		case 0x0c02: // SC_SECURITY
+			if len(block) >= 4 && binary.LittleEndian.Uint32(block) != 0 {
+				return errors.New("RDP server asked for Standard RDP Security encryption, which is not supported")
+			}
+		case 0x0c03: // SC_NET
+			if len(block) < 2 {
+				return errShortPDU
+			}
+			c.ioChannel = binary.LittleEndian.Uint16(block)
+		}
+	}
+	if c.ioChannel == 0 {
+		return errors.New("RDP server did not assign an I/O channel")
+	}
+	return nil
+}
+
+// clientCoreData describes the client: its version, the desktop size it
+// wants, and its color depths
+func clientCoreData(size image.Point, protocol uint32) []byte {
+	b := []byte{0x01, 0xc0, 216, 0} // CS_CORE, length
+	b = binary.LittleEndian.AppendUint32(b, 0x00080004)
+	b = binary.LittleEndian.AppendUint16(b, uint16(size.X))
+	b = binary.LittleEndian.AppendUint16(b, uint16(size.Y))
+	b = binary.LittleEndian.AppendUint16(b, 0xca01) // 8 bpp, superseded below
+	b = binary.LittleEndian.AppendUint16(b, 0xaa03) // SAS sequence
+	b = binary.LittleEndian.AppendUint32(b, 0x409)  // US keyboard
+	b = binary.LittleEndian.AppendUint32(b, 2600)   // Client build
+	b = append(b, fixedUTF16("WITNESS", 32)...)
+	b = binary.LittleEndian.AppendUint32(b, 4)  // IBM enhanced keyboard
+	b = binary.LittleEndian.AppendUint32(b, 0)  // Subtype
+	b = binary.LittleEndian.AppendUint32(b, 12) // Function keys
+	b = append(b, make([]byte, 64)...)          // IME file name
+	b = binary.LittleEndian.AppendUint16(b, 0xca01)
+	b = binary.LittleEndian.AppendUint16(b, 1) // Product ID
+	b = binary.LittleEndian.AppendUint32(b, 0) // Serial number
+	b = binary.LittleEndian.AppendUint16(b, 24)
+	b = binary.LittleEndian.AppendUint16(b, 0x0007) // 24, 16, and 15 bpp
+	b = binary.LittleEndian.AppendUint16(b, 0x0001) // Supports the error info PDU
+	b = append(b, make([]byte, 64)...)              // Digital product ID
+	b = append(b, 0, 0)                             // Connection type, padding
+	return binary.LittleEndian.AppendUint32(b, protocol)
+}
+
+// clientSecurityData offers the Standard RDP Security encryption methods,
+// which servers ignore once TLS is in use
+func clientSecurityData() []byte {
+	b := []byte{0x02, 0xc0, 12, 0}
+	b = binary.LittleEndian.AppendUint32(b, 0x1b)
+	return binary.LittleEndian.AppendUint32(b, 0)
+}
+
+// clientNetworkData asks for no virtual channels
+func clientNetworkData() []byte {
+	b := []byte{0x03, 0xc0, 8, 0}
+	return binary.LittleEndian.AppendUint32(b, 0)
+}
+
+// conferenceCreateRequest wraps the client data blocks in a T.124 GCC
+// conference create request
+func conferenceCreateRequest(blocks []byte) []byte {
+	b := []byte{0, 5, 0, 0x14, 0x7c, 0, 1} // T.124 object identifier
+	b = append(b, perLength(len(blocks)+14)...)
+	b = append(b, 0, 0x08, 0, 0x10, 0, 1, 0xc0, 0)
+	b = append(b, "Duca"...)
+	b = append(b, perLength(len(blocks))...)
+	return append(b, blocks...)
+}
+
+// connectInitial wraps GCC user data in a T.125 MCS connect initial
+func connectInitial(userData []byte) []byte {
+	var b []byte
+	b = berTLV(b, 0x04, []byte{1}) // Calling domain selector
+	b = berTLV(b, 0x04, []byte{1}) // Called domain selector
+	b = berTLV(b, 0x01, []byte{0xff})
+	b = domainParameters(b, 34, 2, 0, 1, 0, 1, 65535, 2)
+	b = domainParameters(b, 1, 1, 1, 1, 0, 1, 1056, 2)
+	b = domainParameters(b, 65535, 64535, 65535, 1, 0, 1, 65535, 2)
+	b = berTLV(b, 0x04, userData)
+	return append([]byte{0x7f, 0x65}, append(berLength(len(b)), b...)...)
+}
+
+// domainParameters encodes MCS domain parameters
+func domainParameters(b []byte, values ...int) []byte {
+	var seq []byte
+	for _, v := range values {
+		var n []byte
+		switch {
+		case v <= 0xff:
+			n = []byte{byte(v)}
+		case v <= 0xffff:
+			n = binary.BigEndian.AppendUint16(nil, uint16(v))
+		default:
+			n = binary.BigEndian.AppendUint32(nil, uint32(v))
+		}
+		seq = berTLV(seq, 0x02, n)
+	}
+	return berTLV(b, 0x30, seq)
+}
+
+// berTLV appends a BER tag, length, and value
+func berTLV(b []byte, tag byte, value []byte) []byte {
+	b = append(b, tag)
+	b = append(b, berLength(len(value))...)
+	return append(b, value...)
+}
+
+// berLength encodes a BER length
+func berLength(n int) []byte {
+	switch {
+	case n < 0x80:
+		return []byte{byte(n)}
+	case n <= 0xff:
+		return []byte{0x81, byte(n)}
+	}
+	return []byte{0x82, byte(n >> 8), byte(n)}
+}
+
+// berValue reads a BER length and returns the value it covers and what
+// follows
+func berValue(b []byte) (value, rest []byte, err error) {
+	if len(b) < 1 {
+		return nil, nil, errShortPDU
+	}
+	n, size := int(b[0]), 1
+	if b[0]&0x80 != 0 {
+		size = 1 + int(b[0]&0x7f)
+		if size > 3 || len(b) < size {
+			return nil, nil, errShortPDU
+		}
+		n = 0
+		for _, x := range b[1:size] {
+			n = n<<8 | int(x)
+		}
+	}
+	if size+n > len(b) {
+		return nil, nil, errShortPDU
+	}
+	return b[size : size+n], b[size+n:], nil
+}
+
+// perLength encodes a PER length
+func perLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	return binary.BigEndian.AppendUint16(nil, uint16(n)|0x8000)
+}
+
+// sendInfo sends the client info PDU, which signs in when the server did
+// not authenticate with NLA
+func (c *Client) sendInfo(login Login) error {
+	const flags = 0x1 | 0x2 | 0x8 | 0x10 | 0x20 | 0x100 // Mouse, no Ctrl+Alt+Del, autologon, Unicode, maximize shell, Windows key
+	fields := [][]byte{utf16le(login.Domain), utf16le(login.User), utf16le(login.Password), nil, nil}
+
+	b := binary.LittleEndian.AppendUint16(nil, secInfoPacket)
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, 0) // Code page
+	b = binary.LittleEndian.AppendUint32(b, flags)
+	for _, f := range fields {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(f)))
+	}
+	for _, f := range fields {
+		b = append(append(b, f...), 0, 0)
+	}
+
+	// Extended info: client address and directory, time zone, session ID,
+	// performance flags, and auto-reconnect cookie
+	address := utf16le(clientAddress(c.conn))
+	b = binary.LittleEndian.AppendUint16(b, 2) // AF_INET
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(address)+2))
+	b = append(append(b, address...), 0, 0)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = append(b, 0, 0)
+	b = append(b, make([]byte, 172)...)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	return c.sendData(b)
+}
+
+// clientAddress returns the local IP address of the connection
+func clientAddress(conn net.Conn) string {
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		return addr.IP.String()
+	}
+	return ""
+}
+
+// license waits for the server to finish licensing. Servers without the
+// Remote Desktop Session Host role accept every client straight away. If
+// the server skips licensing, the PDU it sent instead is returned.
+func (c *Client) license() ([]byte, error) {
+	data, err := c.readMCS()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 || binary.LittleEndian.Uint16(data)&secLicensePacket == 0 {
+		return data, nil
+	}
+	data = data[4:]
+	if len(data) < 4 {
+		return nil, errShortPDU
+	}
+
+	switch data[0] {
+	case licenseErrorAlert:
+		if len(data) < 8 {
+			return nil, errShortPDU
+		}
+		if code := binary.LittleEndian.Uint32(data[4:]); code != licenseValidClient {
+			return nil, fmt.Errorf("RDP licensing failed (error %d)", code)
+		}
+		return nil, nil
+	case licenseNewLicense, licenseUpgrade:
+		return nil, nil
+	}
+	return nil, errors.New("RDP server requires a Remote Desktop Services client license, which is not supported")
+}
+
+// confirmActive answers a demand active PDU with the client's capabilities
+// and finishes the activation
+func (c *Client) confirmActive(pdu []byte) error {
+	if len(pdu) < 8 {
+		return errShortPDU
+	}
+	shareID := binary.LittleEndian.Uint32(pdu)
+	sourceLen := int(binary.LittleEndian.Uint16(pdu[4:]))
+	if 8+sourceLen+4 > len(pdu) {
+		return errShortPDU
+	}
+	count := int(binary.LittleEndian.Uint16(pdu[8+sourceLen:]))
+	caps := pdu[8+sourceLen+4:] // The session ID follows the capability sets
+
+	var size image.Point
+	for ; count > 0; count-- {
+		if len(caps) < 4 {
+			return errShortPDU
+		}
+		kind := binary.LittleEndian.Uint16(caps)
+		n := int(binary.LittleEndian.Uint16(caps[2:]))
+		if n < 4 || n > len(caps) {
+			return errShortPDU
+		}
+		if kind == 0x2 && n >= 16 { // Bitmap capability set
+			size.X = int(binary.LittleEndian.Uint16(caps[12:]))
+			size.Y = int(binary.LittleEndian.Uint16(caps[14:]))
+		}
+		caps = caps[n:]
+	}
+	if size.X <= 0 || size.Y <= 0 {
+		return errors.New("RDP server did not report the desktop size")
+	}
+
+	c.mu.Lock()
+	if c.fb == nil {
+		c.fb = image.NewRGBA(image.Rectangle{Max: size})
+		draw.Draw(c.fb, c.fb.Bounds(), image.Black, image.Point{}, draw.Src)
+	} else if c.fb.Bounds().Size() != size {
+		old := c.fb.Bounds().Size()
+		c.mu.Unlock()
+		return fmt.Errorf("RDP desktop was resized from %dx%d to %dx%d", old.X, old.Y, size.X, size.Y)
+	}
+	c.mu.Unlock()
+	c.shareID = shareID
+
+	count, capabilities := clientCapabilities(size)
+	body := binary.LittleEndian.AppendUint32(nil, shareID)
+	body = binary.LittleEndian.AppendUint16(body, serverChannel) // Originator
+	body = binary.LittleEndian.AppendUint16(body, 6)
+	body = binary.LittleEndian.AppendUint16(body, uint16(4+len(capabilities)))
+	body = append(body, "MSTSC\x00"...)
+	body = binary.LittleEndian.AppendUint16(body, uint16(count))
+	body = append(body, 0, 0)
+	body = append(body, capabilities...)
+	if err := c.sendData(c.shareControlPDU(pduConfirmActive, body)); err != nil {
+		return err
+	}
+
+	// Synchronize, cooperate, request control, and an empty font list
+	finalize := []struct {
+		kind byte
+		data []byte
+	}{
+		{pdu2Synchronize, []byte{1, 0, byte(serverChannel & 0xff), byte(serverChannel >> 8)}},
+		{pdu2Control, []byte{4, 0, 0, 0, 0, 0, 0, 0}},
+		{pdu2Control, []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+		{pdu2FontList, []byte{0, 0, 0, 0, 3, 0, 0x32, 0}},
+	}
+	for _, f := range finalize {
+		if err := c.sendData(c.shareDataPDU(f.kind, f.data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clientCapabilities returns the client's capability sets and how many
+// there are. No drawing orders, caches, or codecs are offered, so the
+// server sends every change as a bitmap.
+func clientCapabilities(size image.Point) (int, []byte) {
+	sets := [][]byte{
+		// General: Windows NT, fast-path output, long credentials, and no
+		// compression headers on bitmaps
+		{1, 0, 3, 0, 0, 2, 0, 0, 0, 0, 0x05, 0x04, 0, 0, 0, 0, 0, 0, 1, 1},
+		// Bitmap: 24 bpp at the desktop size, with compression and
+		// multiple rectangles per update
+		binary.LittleEndian.AppendUint16(binary.LittleEndian.AppendUint16(
+			[]byte{24, 0, 1, 0, 1, 0, 1, 0}, uint16(size.X)), uint16(size.Y)),
+		// Order: orders negotiated, none supported
+		orderCapability(),
+		// Bitmap cache with no cells
+		make([]byte, 36),
+		// Pointer: color pointers, with a cache for them and new pointers
+		{1, 0, pointerCacheSize, 0, pointerCacheSize, 0},
+		// Input: scancodes and a US keyboard
+		append([]byte{1, 0, 0, 0, 0x09, 0x04, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0, 12, 0, 0, 0}, make([]byte, 64)...),
+		// Brush, glyph cache, offscreen cache, virtual channels, sound
+		make([]byte, 4),
+		make([]byte, 48),
+		make([]byte, 8),
+		make([]byte, 4),
+		make([]byte, 4),
+		// Control, activation, share, and color cache
+		{0, 0, 0, 0, 2, 0, 2, 0},
+		make([]byte, 8),
+		make([]byte, 4),
+		{6, 0, 0, 0},
+		// Multifragment update: a full screen of 32 bpp pixels
+		binary.LittleEndian.AppendUint32(nil, uint32(4*size.X*size.Y)),
+	}
+	types := []uint16{0x01, 0x02, 0x03, 0x04, 0x08, 0x0d, 0x0f, 0x10, 0x11, 0x14, 0x0c, 0x05, 0x07, 0x09, 0x0a, 0x1a}
+
+	var b []byte
+	for i, set := range sets {
+		if types[i] == 0x02 {
+			// Padding, resizing, compression, color flags, drawing flags,
+			// multiple rectangles, and padding
+			set = append(set, 0, 0, 1, 0, 1, 0, 0, 0, 1, 0, 0, 0)
+		}
+		b = binary.LittleEndian.AppendUint16(b, types[i])
+		b = binary.LittleEndian.AppendUint16(b, uint16(4+len(set)))
+		b = append(b, set...)
+	}
+	return len(sets), b
+}
+
+// orderCapability negotiates orders without supporting any
+func orderCapability() []byte {
+	b := make([]byte, 20)                       // Terminal descriptor and padding
+	b = binary.LittleEndian.AppendUint16(b, 1)  // Desktop save X granularity
+	b = binary.LittleEndian.AppendUint16(b, 20) // Desktop save Y granularity
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	b = binary.LittleEndian.AppendUint16(b, 1)      // Maximum order level
+	b = binary.LittleEndian.AppendUint16(b, 0)      // Number of fonts
+	b = binary.LittleEndian.AppendUint16(b, 0x0022) // Negotiate order support, color indexes
+	b = append(b, make([]byte, 32)...)              // No orders
+	b = binary.LittleEndian.AppendUint16(b, 0)      // Text flags
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, 480*480) // Desktop save size
+	return append(b, make([]byte, 8)...)
+}
+
+// tpkt frames an X.224 message
+func tpkt(payload []byte) []byte {
+	b := []byte{tpktVersion, 0}
+	b = binary.BigEndian.AppendUint16(b, uint16(4+len(payload)))
+	return append(b, payload...)
+}
+
+// readTPKT reads one TPKT packet and returns its payload
+func readTPKT(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != tpktVersion {
+		return nil, fmt.Errorf("unexpected RDP packet version %d", header[0])
+	}
+	n := int(binary.BigEndian.Uint16(header[2:]))
+	if n < 4 {
+		return nil, fmt.Errorf("RDP packet length %d is too short", n)
+	}
+	payload := make([]byte, n-4)
+	_, err := io.ReadFull(r, payload)
+	return payload, err
+}
+
+// writeX224 sends an X.224 data TPDU
+func (c *Client) writeX224(data []byte) error {
+	_, err := c.conn.Write(tpkt(append([]byte{2, 0xf0, 0x80}, data...)))
+	return err
+}
+
+// readX224 reads an X.224 data TPDU
+func (c *Client) readX224() ([]byte, error) {
+	payload, err := readTPKT(c.r)
+	if err != nil {
+		return nil, err
+	}
+	return x224Data(payload)
+}
+
+// x224Data strips the X.224 data TPDU header
+func x224Data(payload []byte) ([]byte, error) {
+	if len(payload) < 3 || payload[1] != 0xf0 {
+		return nil, errors.New("unexpected X.224 message")
+	}
+	return payload[3:], nil
+}
+
+// sendData sends data to the server on the I/O channel
+func (c *Client) sendData(data []byte) error {
+	b := []byte{0x64}
+	b = binary.BigEndian.AppendUint16(b, c.userID-mcsUserBase)
+	b = binary.BigEndian.AppendUint16(b, c.ioChannel)
+	b = append(b, 0x70)
+	b = append(b, perLength(len(data))...)
+	return c.writeX224(append(b, data...))
+}
+
+// readMCS reads the next slow-path message's data, applying fast-path
+// updates that arrive first
+func (c *Client) readMCS() ([]byte, error) {
+	for {
+		header, data, err := c.readPDU()
+		if err != nil {
+			return nil, err
+		}
+		if header != tpktVersion {
+			if err := c.fastPath(header, data); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if data, err = c.mcsData(data); err != nil {
+			return nil, err
+		}
+		if data != nil {
+			return data, nil
+		}
+	}
+}
+
+// mcsData returns the data of an MCS send data indication in a TPKT
+// payload, or nil for other MCS messages
+func (c *Client) mcsData(payload []byte) ([]byte, error) {
+	data, err := x224Data(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1 {
+		return nil, errShortPDU
+	}
+	switch data[0] >> 2 {
+	case mcsDisconnectUltimatum:
+		return nil, c.disconnected()
+	case mcsSendDataIndication:
+	default:
+		return nil, nil
+	}
+
+	if len(data) < 7 {
+		return nil, errShortPDU
+	}
+	n, data := int(data[6]), data[7:]
+	if n&0x80 != 0 {
+		if len(data) < 1 {
+			return nil, errShortPDU
+		}
+		n, data = (n&0x7f)<<8|int(data[0]), data[1:]
+	}
+	if n > len(data) {
+		return nil, errShortPDU
+	}
+	return data[:n], nil
+}
+
+// shareControlPDU adds a share control header
+func (c *Client) shareControlPDU(kind uint16, body []byte) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, uint16(6+len(body)))
+	b = binary.LittleEndian.AppendUint16(b, kind|0x10)
+	b = binary.LittleEndian.AppendUint16(b, c.userID)
+	return append(b, body...)
+}
+
+// shareDataPDU wraps data in share data and share control headers
+func (c *Client) shareDataPDU(kind byte, data []byte) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, c.shareID)
+	b = append(b, 0, 1) // Padding, low priority stream
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(data)))
+	b = append(b, kind, 0, 0, 0) // Type, not compressed
+	return c.shareControlPDU(pduData, append(b, data...))
+}
+
+// disconnected explains why the server hung up
+func (c *Client) disconnected() error {
+	reasons := map[uint32]string{
+		0x1: "an administrator disconnected the session",
+		0x2: "an administrator signed the session out",
+		0x3: "the session was idle for too long",
+		0x4: "signing in took too long",
+		0x5: "another connection took over the session",
+		0x6: "the server ran out of memory",
+		0x7: "the server denied the connection",
+		0x9: "the user may not sign in remotely",
+		0xb: "the session was disconnected from inside",
+		0xc: "the session signed out",
+	}
+	if reason, ok := reasons[c.errorInfo]; ok {
+		return fmt.Errorf("RDP server disconnected: %s", reason)
+	}
+	if c.errorInfo != 0 {
+		return fmt.Errorf("RDP server disconnected (error info %#x)", c.errorInfo)
+	}
+	return errors.New("RDP server disconnected")
+}
+
+// utf16le encodes s as little-endian UTF-16
+func utf16le(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// fixedUTF16 encodes s as UTF-16 in a zero-padded field of n bytes
+func fixedUTF16(s string, n int) []byte {
+	b := make([]byte, n)
+	copy(b[:n-2], utf16le(s))
+	return b
+}
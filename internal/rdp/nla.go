@@ -0,0 +1,499 @@
+package rdp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Network Level Authentication logs in before the session starts: CredSSP
+// (MS-CSSP) carries an NTLMv2 exchange (MS-NLMP) over TLS, binds it to the
+// server's public key, and then sends the password sealed with the NTLM
+// session key.
+
+// NTLM negotiate flags
+const (
+	ntlmNegotiateUnicode         = 0x00000001
+	ntlmRequestTarget            = 0x00000004
+	ntlmNegotiateSign            = 0x00000010
+	ntlmNegotiateSeal            = 0x00000020
+	ntlmNegotiateNTLM            = 0x00000200
+	ntlmNegotiateAlwaysSign      = 0x00008000
+	ntlmNegotiateExtendedSession = 0x00080000
+	ntlmNegotiateVersion         = 0x02000000
+	ntlmNegotiate128             = 0x20000000
+	ntlmNegotiateKeyExchange     = 0x40000000
+	ntlmNegotiate56              = 0x80000000
+
+	ntlmClientFlags = ntlmNegotiate56 | ntlmNegotiateKeyExchange | ntlmNegotiate128 |
+		ntlmNegotiateVersion | ntlmNegotiateExtendedSession | ntlmNegotiateAlwaysSign |
+		ntlmNegotiateNTLM | ntlmNegotiateSeal | ntlmNegotiateSign | ntlmRequestTarget |
+		ntlmNegotiateUnicode
+)
+
+// NTLM target info (AV_PAIR) IDs
+const (
+	avEOL       = 0
+	avFlags     = 6
+	avTimestamp = 7
+
+	avFlagMIC = 0x00000002 // The AUTHENTICATE message carries a MIC
+)
+
+// ntlmSignature starts every NTLM message
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmVersion claims Windows 7 SP1 and NTLM revision 15
+var ntlmVersion = []byte{6, 1, 0xb1, 0x1d, 0, 0, 0, 0x0f}
+
+// CredSSP binds the NTLM exchange to the TLS key with these hashes
+// from version 5 on
+var (
+	clientServerHashMagic = []byte("CredSSP Client-To-Server Binding Hash\x00")
+	serverClientHashMagic = []byte("CredSSP Server-To-Client Binding Hash\x00")
+)
+
+// credsspVersion is the CredSSP version this client speaks
+const credsspVersion = 6
+
+// ntlm is one NTLMv2 authentication and the keys it produces for sealing
+// CredSSP messages
+type ntlm struct {
+	user     string
+	domain   string
+	password string
+
+	negotiate []byte // The messages sent and received, for the MIC
+	challenge []byte
+
+	clientSign []byte
+	serverSign []byte
+	clientSeal *rc4.Cipher
+	serverSeal *rc4.Cipher
+	clientSeq  uint32
+	serverSeq  uint32
+}
+
+// negotiateMessage starts the exchange
+func (n *ntlm) negotiateMessage() []byte {
+	msg := append([]byte(nil), ntlmSignature...)
+	msg = binary.LittleEndian.AppendUint32(msg, 1)
+	msg = binary.LittleEndian.AppendUint32(msg, ntlmClientFlags)
+	msg = append(msg, make([]byte, 16)...) // No domain or workstation
+	msg = append(msg, ntlmVersion...)
+	n.negotiate = msg
+	return msg
+}
+
+// authenticateMessage answers the server's challenge and derives the
+// session keys
+func (n *ntlm) authenticateMessage(challenge []byte) ([]byte, error) {
+	if len(challenge) < 48 || !bytes.Equal(challenge[:8], ntlmSignature) || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, errors.New("malformed NTLM challenge")
+	}
+	n.challenge = challenge
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	if flags&ntlmNegotiateExtendedSession == 0 || flags&ntlmNegotiate128 == 0 {
+		return nil, fmt.Errorf("NTLM server does not support extended session security (flags %#x)", flags)
+	}
+	serverChallenge := challenge[24:32]
+	targetInfo, err := messageField(challenge, 40)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := parseAVPairs(targetInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := pairs[avTimestamp]
+	if timestamp == nil {
+		timestamp = binary.LittleEndian.AppendUint64(nil, fileTime(time.Now()))
+	}
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+
+	key := ntowfv2(n.user, n.domain, n.password)
+	ntResponse, sessionBaseKey := ntlmv2Response(key, serverChallenge, clientChallenge, timestamp, withMICFlag(targetInfo))
+	lmResponse := make([]byte, 24)
+	if pairs[avTimestamp] == nil {
+		mac := hmac.New(md5.New, key)
+		mac.Write(serverChallenge)
+		mac.Write(clientChallenge)
+		lmResponse = append(mac.Sum(nil), clientChallenge...)
+	}
+
+	exportedKey := sessionBaseKey
+	var encryptedKey []byte
+	if flags&ntlmNegotiateKeyExchange != 0 {
+		exportedKey = make([]byte, 16)
+		if _, err := rand.Read(exportedKey); err != nil {
+			return nil, err
+		}
+		encryptedKey = rc4Encrypt(sessionBaseKey, exportedKey)
+	}
+
+	const headerLen = 88
+	var header, payload []byte
+	header = append(header, ntlmSignature...)
+	header = binary.LittleEndian.AppendUint32(header, 3)
+	for _, field := range [][]byte{lmResponse, ntResponse, utf16le(n.domain), utf16le(n.user), nil, encryptedKey} {
+		header = binary.LittleEndian.AppendUint16(header, uint16(len(field)))
+		header = binary.LittleEndian.AppendUint16(header, uint16(len(field)))
+		header = binary.LittleEndian.AppendUint32(header, uint32(headerLen+len(payload)))
+		payload = append(payload, field...)
+	}
+	header = binary.LittleEndian.AppendUint32(header, flags)
+	header = append(header, ntlmVersion...)
+	header = append(header, make([]byte, 16)...) // MIC, filled in below
+	msg := append(header, payload...)
+
+	mic := hmac.New(md5.New, exportedKey)
+	mic.Write(n.negotiate)
+	mic.Write(n.challenge)
+	mic.Write(msg)
+	copy(msg[72:], mic.Sum(nil))
+
+	n.clientSign = signingKey(exportedKey, "client-to-server")
+	n.serverSign = signingKey(exportedKey, "server-to-client")
+	n.clientSeal, _ = rc4.NewCipher(sealingKey(exportedKey, "client-to-server")) // A 16 byte key cannot fail
+	n.serverSeal, _ = rc4.NewCipher(sealingKey(exportedKey, "server-to-client"))
+	return msg, nil
+}
+
+// seal encrypts and signs a message to the server
+func (n *ntlm) seal(msg []byte) []byte {
+	seq := binary.LittleEndian.AppendUint32(nil, n.clientSeq)
+	n.clientSeq++
+
+	sealed := make([]byte, 16+len(msg))
+	n.clientSeal.XORKeyStream(sealed[16:], msg)
+	mac := hmac.New(md5.New, n.clientSign)
+	mac.Write(seq)
+	mac.Write(msg)
+	binary.LittleEndian.PutUint32(sealed, 1)
+	n.clientSeal.XORKeyStream(sealed[4:12], mac.Sum(nil)[:8])
+	copy(sealed[12:], seq)
+	return sealed
+}
+
+// unseal decrypts a message from the server and checks its signature
+func (n *ntlm) unseal(sealed []byte) ([]byte, error) {
+	if len(sealed) < 16 {
+		return nil, errors.New("sealed NTLM message is too short")
+	}
+	seq := binary.LittleEndian.AppendUint32(nil, n.serverSeq)
+	n.serverSeq++
+
+	msg := make([]byte, len(sealed)-16)
+	n.serverSeal.XORKeyStream(msg, sealed[16:])
+	checksum := make([]byte, 8)
+	n.serverSeal.XORKeyStream(checksum, sealed[4:12])
+	mac := hmac.New(md5.New, n.serverSign)
+	mac.Write(seq)
+	mac.Write(msg)
+	if !hmac.Equal(checksum, mac.Sum(nil)[:8]) || !bytes.Equal(sealed[12:16], seq) {
+		return nil, errors.New("NTLM message signature does not match")
+	}
+	return msg, nil
+}
+
+// ntowfv2 hashes the password with the user and domain names
+func ntowfv2(user, domain, password string) []byte {
+	hash := md4(utf16le(password))
+	mac := hmac.New(md5.New, hash[:])
+	mac.Write(utf16le(strings.ToUpper(user) + domain))
+	return mac.Sum(nil)
+}
+
+// ntlmv2Response computes the NTLMv2 response to the server's challenge and
+// the session base key
+func ntlmv2Response(key, serverChallenge, clientChallenge, timestamp, targetInfo []byte) (response, sessionBaseKey []byte) {
+	var blob []byte
+	blob = append(blob, 1, 1, 0, 0, 0, 0, 0, 0)
+	blob = append(blob, timestamp...)
+	blob = append(blob, clientChallenge...)
+	blob = append(blob, 0, 0, 0, 0)
+	blob = append(blob, targetInfo...)
+	blob = append(blob, 0, 0, 0, 0)
+
+	mac := hmac.New(md5.New, key)
+	mac.Write(serverChallenge)
+	mac.Write(blob)
+	proof := mac.Sum(nil)
+
+	mac = hmac.New(md5.New, key)
+	mac.Write(proof)
+	return append(proof, blob...), mac.Sum(nil)
+}
+
+// signingKey derives the key that signs messages in one direction
+func signingKey(exportedKey []byte, direction string) []byte {
+	sum := md5.Sum(append(append([]byte(nil), exportedKey...), "session key to "+direction+" signing key magic constant\x00"...))
+	return sum[:]
+}
+
+// sealingKey derives the key that encrypts messages in one direction
+func sealingKey(exportedKey []byte, direction string) []byte {
+	sum := md5.Sum(append(append([]byte(nil), exportedKey...), "session key to "+direction+" sealing key magic constant\x00"...))
+	return sum[:]
+}
+
+// rc4Encrypt encrypts data with a fresh RC4 key stream
+func rc4Encrypt(key, data []byte) []byte {
+	cipher, _ := rc4.NewCipher(key) // A 16 byte key cannot fail
+	out := make([]byte, len(data))
+	cipher.XORKeyStream(out, data)
+	return out
+}
+
+// messageField returns the payload an NTLM length/offset field points to
+func messageField(msg []byte, at int) ([]byte, error) {
+	n := int(binary.LittleEndian.Uint16(msg[at:]))
+	offset := int(binary.LittleEndian.Uint32(msg[at+4:]))
+	if offset+n > len(msg) {
+		return nil, errors.New("NTLM message field is out of bounds")
+	}
+	return msg[offset : offset+n], nil
+}
+
+// parseAVPairs indexes target info by AV ID
+func parseAVPairs(info []byte) (map[uint16][]byte, error) {
+	pairs := make(map[uint16][]byte)
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		n := int(binary.LittleEndian.Uint16(info[2:]))
+		if id == avEOL {
+			return pairs, nil
+		}
+		if 4+n > len(info) {
+			break
+		}
+		pairs[id] = info[4 : 4+n]
+		info = info[4+n:]
+	}
+	return nil, errors.New("malformed NTLM target info")
+}
+
+// withMICFlag returns the target info with the flag saying the
+// AUTHENTICATE message carries a MIC
+func withMICFlag(info []byte) []byte {
+	var out []byte
+	flags := uint32(avFlagMIC)
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		n := int(binary.LittleEndian.Uint16(info[2:]))
+		if id == avEOL || 4+n > len(info) {
+			break
+		}
+		if id == avFlags && n == 4 {
+			flags |= binary.LittleEndian.Uint32(info[4:])
+		} else {
+			out = append(out, info[:4+n]...)
+		}
+		info = info[4+n:]
+	}
+	out = binary.LittleEndian.AppendUint16(out, avFlags)
+	out = binary.LittleEndian.AppendUint16(out, 4)
+	out = binary.LittleEndian.AppendUint32(out, flags)
+	return append(out, 0, 0, 0, 0) // EOL
+}
+
+// fileTime converts t to 100ns intervals since 1601, as Windows counts
+func fileTime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + 116444736000000000
+}
+
+// tsRequest is the CredSSP message
+type tsRequest struct {
+	Version     int         `asn1:"explicit,tag:0"`
+	NegoTokens  []negoToken `asn1:"optional,explicit,tag:1"`
+	AuthInfo    []byte      `asn1:"optional,explicit,tag:2"`
+	PubKeyAuth  []byte      `asn1:"optional,explicit,tag:3"`
+	ErrorCode   int64       `asn1:"optional,explicit,tag:4"`
+	ClientNonce []byte      `asn1:"optional,explicit,tag:5"`
+}
+
+type negoToken struct {
+	Token []byte `asn1:"explicit,tag:0"`
+}
+
+type tsCredentials struct {
+	CredType    int    `asn1:"explicit,tag:0"`
+	Credentials []byte `asn1:"explicit,tag:1"`
+}
+
+type tsPasswordCreds struct {
+	Domain   []byte `asn1:"explicit,tag:0"`
+	User     []byte `asn1:"explicit,tag:1"`
+	Password []byte `asn1:"explicit,tag:2"`
+}
+
+// authenticateNLA runs CredSSP over the TLS connection rw, whose server
+// certificate is cert
+func authenticateNLA(rw io.ReadWriter, cert *x509.Certificate, login Login) error {
+	var spki struct {
+		Algorithm asn1.RawValue
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return fmt.Errorf("failed to read the server's public key: %w", err)
+	}
+	publicKey := spki.PublicKey.Bytes
+
+	n := &ntlm{user: login.User, domain: login.Domain, password: login.Password}
+	if err := writeTSRequest(rw, tsRequest{Version: credsspVersion, NegoTokens: []negoToken{{n.negotiateMessage()}}}); err != nil {
+		return err
+	}
+	resp, err := readTSRequest(rw)
+	if err != nil {
+		return err
+	}
+	if len(resp.NegoTokens) == 0 {
+		return errors.New("CredSSP server sent no NTLM challenge")
+	}
+	auth, err := n.authenticateMessage(resp.NegoTokens[0].Token)
+	if err != nil {
+		return err
+	}
+
+	serverVersion := resp.Version
+	req := tsRequest{Version: credsspVersion, NegoTokens: []negoToken{{auth}}}
+	var nonce []byte
+	if serverVersion >= 5 {
+		nonce = make([]byte, 32)
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		req.ClientNonce = nonce
+		req.PubKeyAuth = n.seal(bindingHash(clientServerHashMagic, nonce, publicKey))
+	} else {
+		req.PubKeyAuth = n.seal(publicKey)
+	}
+	if err := writeTSRequest(rw, req); err != nil {
+		return err
+	}
+	resp, err = readTSRequest(rw)
+	if err != nil {
+		// Servers before CredSSP 3 hang up instead of sending an error code
+		return fmt.Errorf("%w: %v", ErrAuthFailed, err)
+	}
+	if resp.ErrorCode != 0 {
+		return fmt.Errorf("%w: %s", ErrAuthFailed, ntStatus(uint32(resp.ErrorCode)))
+	}
+
+	echo, err := n.unseal(resp.PubKeyAuth)
+	if err != nil {
+		return err
+	}
+	want := append([]byte(nil), publicKey...)
+	if serverVersion >= 5 {
+		want = bindingHash(serverClientHashMagic, nonce, publicKey)
+	} else if len(want) > 0 {
+		want[0]++
+	}
+	if !bytes.Equal(echo, want) {
+		return errors.New("CredSSP server did not prove it holds the TLS key")
+	}
+
+	creds, err := asn1.Marshal(tsPasswordCreds{
+		Domain:   utf16le(login.Domain),
+		User:     utf16le(login.User),
+		Password: utf16le(login.Password),
+	})
+	if err != nil {
+		return err
+	}
+	creds, err = asn1.Marshal(tsCredentials{CredType: 1, Credentials: creds})
+	if err != nil {
+		return err
+	}
+	return writeTSRequest(rw, tsRequest{Version: credsspVersion, AuthInfo: n.seal(creds)})
+}
+
+// bindingHash ties the NTLM exchange to the TLS public key
+func bindingHash(magic, nonce, publicKey []byte) []byte {
+	h := sha256.New()
+	h.Write(magic)
+	h.Write(nonce)
+	h.Write(publicKey)
+	return h.Sum(nil)
+}
+
+// ntStatus names the NTSTATUS codes a failed logon returns
+func ntStatus(code uint32) string {
+	switch code {
+	case 0xc000006d:
+		return "wrong user name or password"
+	case 0xc000006e:
+		return "account restriction"
+	case 0xc0000071:
+		return "password expired"
+	case 0xc0000072:
+		return "account disabled"
+	case 0xc0000234:
+		return "account locked out"
+	case 0xc0000224:
+		return "password must be changed"
+	}
+	return fmt.Sprintf("NTSTATUS %#08x", code)
+}
+
+// writeTSRequest sends a CredSSP message
+func writeTSRequest(w io.Writer, req tsRequest) error {
+	b, err := asn1.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// readTSRequest reads one DER-encoded CredSSP message
+func readTSRequest(r io.Reader) (tsRequest, error) {
+	var req tsRequest
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return req, err
+	}
+	if header[0] != 0x30 {
+		return req, fmt.Errorf("unexpected CredSSP message tag %#x", header[0])
+	}
+	n := int(header[1])
+	if n&0x80 != 0 {
+		size := make([]byte, n&0x7f)
+		if len(size) == 0 || len(size) > 3 {
+			return req, errors.New("unsupported CredSSP message length")
+		}
+		if _, err := io.ReadFull(r, size); err != nil {
+			return req, err
+		}
+		header = append(header, size...)
+		n = 0
+		for _, b := range size {
+			n = n<<8 | int(b)
+		}
+	}
+	msg := make([]byte, len(header)+n)
+	copy(msg, header)
+	if _, err := io.ReadFull(r, msg[len(header):]); err != nil {
+		return req, err
+	}
+	if _, err := asn1.Unmarshal(msg, &req); err != nil {
+		return req, fmt.Errorf("malformed CredSSP message: %w", err)
+	}
+	return req, nil
+}
@@ -0,0 +1,131 @@
+package rdp
+
+import (
+	"bytes"
+	"crypto/rc4"
+	"encoding/hex"
+	"testing"
+)
+
+// Helper function to decode hex test vectors
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestMD4(t *testing.T) {
+	// RFC 1320 test suite
+	tests := map[string]string{
+		"":    "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"abc": "a448017aaf21d8525fc10ae87aa6729d",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	}
+	for input, want := range tests {
+		sum := md4([]byte(input))
+		if got := hex.EncodeToString(sum[:]); got != want {
+			t.Errorf("md4(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
+
+// The NTLMv2 example in MS-NLMP section 4.2.4
+func TestNTLMv2Response(t *testing.T) {
+	key := ntowfv2("User", "Domain", "Password")
+	if want := unhex(t, "0c868a403bfd7a93a3001ef22ef02e3f"); !bytes.Equal(key, want) {
+		t.Errorf("ResponseKeyNT = %x, want %x", key, want)
+	}
+
+	targetInfo := unhex(t, "02000c0044006f006d00610069006e0001000c00530065007200760065007200"+"00000000")
+	response, sessionBaseKey := ntlmv2Response(key, unhex(t, "0123456789abcdef"), unhex(t, "aaaaaaaaaaaaaaaa"), make([]byte, 8), targetInfo)
+	if want := unhex(t, "68cd0ab851e51c96aabc927bebef6a1c"); !bytes.Equal(response[:16], want) {
+		t.Errorf("NTProofStr = %x, want %x", response[:16], want)
+	}
+	if want := unhex(t, "8de40ccadbc14a82f15cb0ad0de95ca3"); !bytes.Equal(sessionBaseKey, want) {
+		t.Errorf("SessionBaseKey = %x, want %x", sessionBaseKey, want)
+	}
+}
+
+func TestNTLMSeal(t *testing.T) {
+	exportedKey := bytes.Repeat([]byte{0x55}, 16)
+	client := &ntlm{clientSign: signingKey(exportedKey, "client-to-server")}
+	client.clientSeal, _ = rc4.NewCipher(sealingKey(exportedKey, "client-to-server"))
+
+	// MS-NLMP section 4.2.4.4
+	sealed := client.seal(utf16le("Plaintext"))
+	if want := unhex(t, "010000007fb38ec5c55d497600000000"); !bytes.Equal(sealed[:16], want) {
+		t.Errorf("signature = %x, want %x", sealed[:16], want)
+	}
+	if want := unhex(t, "54e50165bf1936dc996020c1811b0f06fb5f"); !bytes.Equal(sealed[16:], want) {
+		t.Errorf("sealed data = %x, want %x", sealed[16:], want)
+	}
+
+	// The server's side of the same keys unseals it
+	server := &ntlm{serverSign: client.clientSign}
+	server.serverSeal, _ = rc4.NewCipher(sealingKey(exportedKey, "client-to-server"))
+	msg, err := server.unseal(sealed)
+	if err != nil {
+		t.Fatalf("unseal failed: %v", err)
+	}
+	if !bytes.Equal(msg, utf16le("Plaintext")) {
+		t.Errorf("unsealed %x", msg)
+	}
+
+	sealed = client.seal([]byte("second"))
+	sealed[20] ^= 1
+	if _, err := server.unseal(sealed); err == nil {
+		t.Error("unseal accepted a tampered message")
+	}
+}
+
+func TestNTLMAuthenticateMessage(t *testing.T) {
+	n := &ntlm{user: "User", domain: "Domain", password: "Password"}
+	n.negotiateMessage()
+
+	targetInfo := unhex(t, "02000c0044006f006d00610069006e000700080000000000000000000000"+"0000")
+	msg, err := n.authenticateMessage(challengeMessage(unhex(t, "0123456789abcdef"), targetInfo))
+	if err != nil {
+		t.Fatalf("authenticateMessage failed: %v", err)
+	}
+	if !bytes.Equal(msg[:8], ntlmSignature) || msg[8] != 3 {
+		t.Fatalf("not an AUTHENTICATE message: %x", msg[:12])
+	}
+
+	// With a timestamp, the LM response is zeros and the MIC is set
+	lm, err := messageField(msg, 12)
+	if err != nil || !bytes.Equal(lm, make([]byte, 24)) {
+		t.Errorf("LM response = %x, %v; want zeros", lm, err)
+	}
+	if bytes.Equal(msg[72:88], make([]byte, 16)) {
+		t.Error("MIC was not filled in")
+	}
+	user, err := messageField(msg, 36)
+	if err != nil || !bytes.Equal(user, utf16le("User")) {
+		t.Errorf("user = %x, %v", user, err)
+	}
+	nt, err := messageField(msg, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs, err := parseAVPairs(nt[44:])
+	if err != nil {
+		t.Fatalf("response target info: %v", err)
+	}
+	if flags := pairs[avFlags]; len(flags) != 4 || flags[0]&avFlagMIC == 0 {
+		t.Errorf("response target info flags = %x, want the MIC flag", flags)
+	}
+	if n.clientSeal == nil || n.serverSeal == nil {
+		t.Error("sealing keys were not derived")
+	}
+}
+
+func TestNTLMRejectsMalformedChallenge(t *testing.T) {
+	n := &ntlm{user: "User"}
+	n.negotiateMessage()
+	if _, err := n.authenticateMessage([]byte("NTLMSSP\x00\x01\x00\x00\x00")); err == nil {
+		t.Error("accepted a truncated challenge")
+	}
+}
@@ -0,0 +1,177 @@
+package rdp
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// pointerCacheSize is how many pointer shapes the server may cache
+const pointerCacheSize = 25
+
+// Slow-path pointer message types
+const (
+	pointerSystem   = 0x1
+	pointerPosition = 0x3
+	pointerColor    = 0x6
+	pointerCached   = 0x7
+	pointerNew      = 0x8
+)
+
+// pointer is a pointer shape
+type pointer struct {
+	img     *image.NRGBA
+	hotspot image.Point
+}
+
+// setPointer changes the pointer shape. nil hides it, and also stands for
+// the system default, whose shape the server does not send.
+func (c *Client) setPointer(p *pointer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pointer = p
+}
+
+// movePointer applies a pointer position update
+func (c *Client) movePointer(data []byte) error {
+	if len(data) < 4 {
+		return errShortPDU
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pointAt = image.Pt(int(binary.LittleEndian.Uint16(data)), int(binary.LittleEndian.Uint16(data[2:])))
+	c.pointSet = true
+	return nil
+}
+
+// cachedPointer switches to a cached pointer shape
+func (c *Client) cachedPointer(data []byte) error {
+	if len(data) < 2 {
+		return errShortPDU
+	}
+	if i := int(binary.LittleEndian.Uint16(data)); i < pointerCacheSize {
+		c.mu.Lock()
+		c.pointer = c.pointers[i]
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// colorPointer applies a TS_COLORPOINTERATTRIBUTE with xorBpp bits per
+// pixel, caching the shape and switching to it
+func (c *Client) colorPointer(data []byte, xorBpp int) error {
+	if len(data) < 14 {
+		return errShortPDU
+	}
+	var f [7]int // Cache index, hotspot x and y, width, height, AND and XOR mask lengths
+	for i := range f {
+		f[i] = int(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	index, width, height := f[0], f[3], f[4]
+	if 14+f[5]+f[6] > len(data) {
+		return errShortPDU
+	}
+	xorMask := data[14 : 14+f[6]]
+	andMask := data[14+f[6] : 14+f[6]+f[5]]
+
+	p := decodePointer(xorMask, andMask, width, height, xorBpp)
+	if p != nil {
+		p.hotspot = image.Pt(f[1], f[2])
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if index < pointerCacheSize {
+		c.pointers[index] = p
+	}
+	c.pointer = p
+	return nil
+}
+
+// decodePointer combines the XOR and AND masks of a pointer shape, or
+// returns nil for shapes it cannot decode. Both masks are bottom-up with
+// rows padded to two bytes. Pixels that would invert the screen are drawn
+// black.
+func decodePointer(xorMask, andMask []byte, width, height, xorBpp int) *pointer {
+	switch xorBpp {
+	case 1, 16, 24, 32:
+	default:
+		return nil // 8 bpp shapes need the palette
+	}
+	xorStride := (width*xorBpp + 15) / 16 * 2
+	andStride := (width + 15) / 16 * 2
+	if width == 0 || height == 0 || len(xorMask) < xorStride*height {
+		return nil
+	}
+	hasAnd := len(andMask) >= andStride*height
+
+	// 32 bpp shapes with alpha ignore the AND mask
+	alpha := false
+	if xorBpp == 32 {
+		for i := 3; i < len(xorMask); i += 4 {
+			if xorMask[i] != 0 {
+				alpha = true
+				break
+			}
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := height - 1 - y
+		xorRow := xorMask[row*xorStride:]
+		for x := 0; x < width; x++ {
+			transparent := hasAnd && andMask[row*andStride+x/8]&(0x80>>(x%8)) != 0
+
+			var c color.NRGBA
+			switch xorBpp {
+			case 1:
+				if xorRow[x/8]&(0x80>>(x%8)) != 0 {
+					c = color.NRGBA{255, 255, 255, 255}
+				}
+			case 16:
+				r, g, b, _ := pixelRGB(uint32(binary.LittleEndian.Uint16(xorRow[2*x:])), 16)
+				c = color.NRGBA{r, g, b, 0}
+			case 24:
+				c = color.NRGBA{xorRow[3*x+2], xorRow[3*x+1], xorRow[3*x], 0}
+			case 32:
+				c = color.NRGBA{xorRow[4*x+2], xorRow[4*x+1], xorRow[4*x], xorRow[4*x+3]}
+			}
+
+			switch {
+			case alpha:
+			case !transparent:
+				c.A = 255
+			case c.R|c.G|c.B != 0:
+				c = color.NRGBA{0, 0, 0, 255} // Inverts the screen
+			default:
+				c = color.NRGBA{}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return &pointer{img: img}
+}
+
+// slowPathPointer applies a slow-path pointer update
+func (c *Client) slowPathPointer(data []byte) error {
+	if len(data) < 4 {
+		return errShortPDU
+	}
+	kind, data := binary.LittleEndian.Uint16(data), data[4:]
+	switch kind {
+	case pointerSystem:
+		c.setPointer(nil)
+	case pointerPosition:
+		return c.movePointer(data)
+	case pointerColor:
+		return c.colorPointer(data, 24)
+	case pointerCached:
+		return c.cachedPointer(data)
+	case pointerNew:
+		if len(data) < 2 {
+			return errShortPDU
+		}
+		return c.colorPointer(data[2:], int(binary.LittleEndian.Uint16(data)))
+	}
+	return nil
+}
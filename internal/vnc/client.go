@@ -0,0 +1,481 @@
+// Package vnc is a minimal VNC (RFB) client for recording remote desktops,
+// such as a containerized browser that exposes a VNC server. It supports
+// the Raw, CopyRect, and Hextile encodings and no authentication or VNC
+// password authentication.
+package vnc
+
+import (
+	"bufio"
+	"crypto/des"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPort is the port of VNC display :0
+const DefaultPort = 5900
+
+// ErrAuthFailed means the server rejected the password
+var ErrAuthFailed = errors.New("VNC authentication failed")
+
+// Security types
+const (
+	securityInvalid = 0
+	securityNone    = 1
+	securityVNCAuth = 2
+)
+
+// Message types
+const (
+	msgSetPixelFormat           = 0
+	msgSetEncodings             = 2
+	msgFramebufferUpdateRequest = 3
+
+	msgFramebufferUpdate   = 0
+	msgSetColourMapEntries = 1
+	msgBell                = 2
+	msgServerCutText       = 3
+)
+
+// Encodings
+const (
+	encodingRaw      = 0
+	encodingCopyRect = 1
+	encodingHextile  = 5
+
+	// encodingCursor is a pseudo-encoding that sends the pointer's shape
+	// separately, so the server leaves it out of the framebuffer
+	encodingCursor = -239
+)
+
+// Client is a connection to a VNC server. Frames arrive as framebuffer
+// updates, which are applied to a local copy of the remote screen.
+type Client struct {
+	// Name is the desktop name the server reports
+	Name string
+
+	conn   net.Conn
+	r      *bufio.Reader
+	minor  int  // Negotiated protocol version 3.minor
+	cursor bool // Whether the server draws the pointer into the framebuffer
+
+	mu     sync.Mutex
+	fb     *image.RGBA
+	buf    []byte
+	synced bool // Whether a full update has been received
+}
+
+// Addr adds the default port to a host, or maps a display number
+// (host:1) to its port, leaving explicit ports (host:5901) alone
+func Addr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return net.JoinHostPort(addr, strconv.Itoa(DefaultPort))
+	}
+	if n, err := strconv.Atoi(port); err == nil && n < 100 {
+		port = strconv.Itoa(DefaultPort + n)
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Dial connects to a VNC server and completes the handshake. cursor keeps
+// the mouse pointer in the framebuffer; otherwise servers that support the
+// Cursor pseudo-encoding leave it out.
+func Dial(addr, password string, cursor bool, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", Addr(addr), timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	c, err := NewClient(conn, password, cursor)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// NewClient performs the handshake over an established connection
+func NewClient(conn net.Conn, password string, cursor bool) (*Client, error) {
+	c := &Client{conn: conn, r: bufio.NewReader(conn), cursor: cursor}
+	if err := c.handshake(password); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Size returns the framebuffer dimensions
+func (c *Client) Size() (width, height int) {
+	b := c.fb.Bounds()
+	return b.Dx(), b.Dy()
+}
+
+// handshake negotiates the version, security, and pixel format
+func (c *Client) handshake(password string) error {
+	var version [12]byte
+	if _, err := io.ReadFull(c.r, version[:]); err != nil {
+		return fmt.Errorf("failed to read VNC version: %w", err)
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version[:]), "RFB %03d.%03d\n", &major, &minor); err != nil || major != 3 {
+		return fmt.Errorf("not a VNC server: %q", version)
+	}
+	// 3.8 is the newest version; servers reporting 3.4-3.6 speak 3.3
+	switch {
+	case minor >= 8:
+		minor = 8
+	case minor < 7:
+		minor = 3
+	}
+	c.minor = minor
+	if _, err := fmt.Fprintf(c.conn, "RFB 003.%03d\n", minor); err != nil {
+		return err
+	}
+
+	security, err := c.negotiateSecurity()
+	if err != nil {
+		return err
+	}
+	if security == securityVNCAuth {
+		if err := c.authenticate(password); err != nil {
+			return err
+		}
+	}
+	if security == securityVNCAuth || c.minor >= 8 {
+		var result uint32
+		if err := binary.Read(c.r, binary.BigEndian, &result); err != nil {
+			return err
+		}
+		if result != 0 {
+			if c.minor >= 8 {
+				if reason, err := c.readString(); err == nil && reason != "" {
+					return fmt.Errorf("%w: %s", ErrAuthFailed, reason)
+				}
+			}
+			return ErrAuthFailed
+		}
+	}
+
+	// ClientInit: share the desktop with other viewers
+	if _, err := c.conn.Write([]byte{1}); err != nil {
+		return err
+	}
+
+	var init struct {
+		Width, Height uint16
+		PixelFormat   [16]byte
+	}
+	if err := binary.Read(c.r, binary.BigEndian, &init); err != nil {
+		return fmt.Errorf("failed to read server init: %w", err)
+	}
+	if c.Name, err = c.readString(); err != nil {
+		return err
+	}
+	c.fb = image.NewRGBA(image.Rect(0, 0, int(init.Width), int(init.Height)))
+
+	return c.setFormat()
+}
+
+// negotiateSecurity picks the security type
+func (c *Client) negotiateSecurity() (byte, error) {
+	if c.minor == 3 {
+		// The server decides
+		var security uint32
+		if err := binary.Read(c.r, binary.BigEndian, &security); err != nil {
+			return 0, err
+		}
+		if security == securityInvalid {
+			return 0, c.failure()
+		}
+		if security != securityNone && security != securityVNCAuth {
+			return 0, fmt.Errorf("unsupported VNC security type %d", security)
+		}
+		return byte(security), nil
+	}
+
+	count, err := c.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, c.failure()
+	}
+	types := make([]byte, count)
+	if _, err := io.ReadFull(c.r, types); err != nil {
+		return 0, err
+	}
+
+	chosen := byte(securityInvalid)
+	for _, t := range types {
+		if t == securityNone {
+			chosen = t
+			break
+		}
+		if t == securityVNCAuth {
+			chosen = t
+		}
+	}
+	if chosen == securityInvalid {
+		return 0, fmt.Errorf("no supported VNC security type among %v", types)
+	}
+	_, err = c.conn.Write([]byte{chosen})
+	return chosen, err
+}
+
+// failure reads the reason a server refused the connection
+func (c *Client) failure() error {
+	reason, err := c.readString()
+	if err != nil {
+		return fmt.Errorf("VNC server refused the connection")
+	}
+	return fmt.Errorf("VNC server refused the connection: %s", reason)
+}
+
+// authenticate answers the VNC authentication challenge
+func (c *Client) authenticate(password string) error {
+	var challenge [16]byte
+	if _, err := io.ReadFull(c.r, challenge[:]); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(encryptChallenge(challenge, password))
+	return err
+}
+
+// encryptChallenge encrypts the challenge with DES keyed by the first eight
+// bytes of the password, each with its bits reversed as VNC requires
+func encryptChallenge(challenge [16]byte, password string) []byte {
+	var key [8]byte
+	copy(key[:], password)
+	for i, b := range key {
+		var r byte
+		for bit := 0; bit < 8; bit++ {
+			r = r<<1 | (b>>bit)&1
+		}
+		key[i] = r
+	}
+
+	block, _ := des.NewCipher(key[:]) // An 8 byte key cannot fail
+	response := make([]byte, 16)
+	block.Encrypt(response[:8], challenge[:8])
+	block.Encrypt(response[8:], challenge[8:])
+	return response
+}
+
+// setFormat asks for 32-bit true color laid out as RGBX in memory, and the
+// encodings this client decodes
+func (c *Client) setFormat() error {
+	msg := []byte{
+		msgSetPixelFormat, 0, 0, 0,
+		32, 24, 0, 1, // 32 bpp, depth 24, little endian, true color
+		0, 255, 0, 255, 0, 255, // Max red, green, blue
+		0, 8, 16, // Red, green, blue shifts
+		0, 0, 0, // Padding
+	}
+	encodings := []int32{encodingCopyRect, encodingHextile, encodingRaw}
+	if !c.cursor {
+		encodings = append(encodings, encodingCursor)
+	}
+	msg = append(msg, msgSetEncodings, 0)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(encodings)))
+	for _, e := range encodings {
+		msg = binary.BigEndian.AppendUint32(msg, uint32(e))
+	}
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+// Update requests a framebuffer update and applies it. An incremental
+// request only returns once something on the remote screen changes.
+func (c *Client) Update(incremental bool) error {
+	w, h := c.Size()
+	req := []byte{msgFramebufferUpdateRequest, 0, 0, 0, 0, 0}
+	if incremental {
+		req[1] = 1
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(w))
+	req = binary.BigEndian.AppendUint16(req, uint16(h))
+	if _, err := c.conn.Write(req); err != nil {
+		return err
+	}
+
+	for {
+		msgType, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case msgFramebufferUpdate:
+			return c.readUpdate()
+		case msgSetColourMapEntries:
+			var header struct {
+				Padding    byte
+				First, Num uint16
+			}
+			if err := binary.Read(c.r, binary.BigEndian, &header); err != nil {
+				return err
+			}
+			if _, err := c.r.Discard(6 * int(header.Num)); err != nil {
+				return err
+			}
+		case msgBell:
+		case msgServerCutText:
+			if _, err := c.r.Discard(3); err != nil {
+				return err
+			}
+			if _, err := c.readString(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected VNC message type %d", msgType)
+		}
+	}
+}
+
+// readUpdate applies the rectangles of a framebuffer update
+func (c *Client) readUpdate() error {
+	var header struct {
+		Padding byte
+		Count   uint16
+	}
+	if err := binary.Read(c.r, binary.BigEndian, &header); err != nil {
+		return err
+	}
+
+	bounds := c.fb.Bounds()
+	for i := 0; i < int(header.Count); i++ {
+		var rect struct {
+			X, Y, Width, Height uint16
+			Encoding            int32
+		}
+		if err := binary.Read(c.r, binary.BigEndian, &rect); err != nil {
+			return err
+		}
+		if rect.Encoding == encodingCursor {
+			// The pointer's pixels and mask, which are not drawn
+			mask := (int(rect.Width) + 7) / 8 * int(rect.Height)
+			if _, err := c.r.Discard(4*int(rect.Width)*int(rect.Height) + mask); err != nil {
+				return err
+			}
+			continue
+		}
+
+		r := image.Rect(int(rect.X), int(rect.Y), int(rect.X)+int(rect.Width), int(rect.Y)+int(rect.Height))
+		if !r.In(bounds) {
+			return fmt.Errorf("VNC update %v is outside the %dx%d screen", r, bounds.Dx(), bounds.Dy())
+		}
+
+		switch rect.Encoding {
+		case encodingRaw:
+			if err := c.readRaw(r); err != nil {
+				return err
+			}
+		case encodingCopyRect:
+			var src struct{ X, Y uint16 }
+			if err := binary.Read(c.r, binary.BigEndian, &src); err != nil {
+				return err
+			}
+			from := image.Pt(int(src.X), int(src.Y))
+			if !r.Sub(r.Min).Add(from).In(bounds) {
+				return fmt.Errorf("VNC copy source %v is outside the screen", from)
+			}
+			c.copyRect(r, from)
+		case encodingHextile:
+			if err := c.readHextile(r); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported VNC encoding %d", rect.Encoding)
+		}
+	}
+
+	c.mu.Lock()
+	c.synced = true
+	c.mu.Unlock()
+	return nil
+}
+
+// readRaw reads RGBX pixels into r
+func (c *Client) readRaw(r image.Rectangle) error {
+	n := 4 * r.Dx() * r.Dy()
+	if cap(c.buf) < n {
+		c.buf = make([]byte, n)
+	}
+	data := c.buf[:n]
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rowLen := 4 * r.Dx()
+	for y := 0; y < r.Dy(); y++ {
+		dst := c.fb.Pix[c.fb.PixOffset(r.Min.X, r.Min.Y+y):][:rowLen]
+		copy(dst, data[y*rowLen:])
+		for x := 3; x < rowLen; x += 4 {
+			dst[x] = 255
+		}
+	}
+	return nil
+}
+
+// copyRect copies the pixels at from to r, which may overlap
+func (c *Client) copyRect(r image.Rectangle, from image.Point) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rowLen := 4 * r.Dx()
+	rows := make([]byte, rowLen*r.Dy())
+	for y := 0; y < r.Dy(); y++ {
+		copy(rows[y*rowLen:], c.fb.Pix[c.fb.PixOffset(from.X, from.Y+y):][:rowLen])
+	}
+	for y := 0; y < r.Dy(); y++ {
+		copy(c.fb.Pix[c.fb.PixOffset(r.Min.X, r.Min.Y+y):][:rowLen], rows[y*rowLen:])
+	}
+}
+
+// Image returns a copy of the area of the screen, or nil until the first
+// update has been received
+func (c *Client) Image(area image.Rectangle) *image.RGBA {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.synced {
+		return nil
+	}
+	area = area.Intersect(c.fb.Bounds())
+	img := image.NewRGBA(image.Rect(0, 0, area.Dx(), area.Dy()))
+	rowLen := 4 * area.Dx()
+	for y := 0; y < area.Dy(); y++ {
+		copy(img.Pix[y*img.Stride:], c.fb.Pix[c.fb.PixOffset(area.Min.X, area.Min.Y+y):][:rowLen])
+	}
+	return img
+}
+
+// readString reads a length-prefixed string
+func (c *Client) readString() (string, error) {
+	var n uint32
+	if err := binary.Read(c.r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+	if n > 1<<20 {
+		return "", fmt.Errorf("VNC string of %d bytes is too long", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,376 @@
+package vnc
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"net"
+	"testing"
+)
+
+// fakeServer scripts the server side of an RFB connection
+type fakeServer struct {
+	t        *testing.T
+	conn     net.Conn
+	version  string
+	password string // Use VNC authentication when set
+	width    int
+	height   int
+
+	encodings chan []int32 // Receives the client's SetEncodings list
+}
+
+// Helper function to start a client against a fake server
+func dialFake(t *testing.T, server *fakeServer, password string) (*Client, error) {
+	return dialFakeCursor(t, server, password, true)
+}
+
+// Helper function to start a client against a fake server, choosing
+// whether the pointer stays in the framebuffer
+func dialFakeCursor(t *testing.T, server *fakeServer, password string, cursor bool) (*Client, error) {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close(); serverConn.Close() })
+	server.t = t
+	server.conn = serverConn
+	server.encodings = make(chan []int32, 1)
+
+	go server.handshake()
+	return NewClient(clientConn, password, cursor)
+}
+
+func (s *fakeServer) write(b []byte) {
+	if _, err := s.conn.Write(b); err != nil {
+		s.t.Errorf("server write failed: %v", err)
+	}
+}
+
+func (s *fakeServer) read(n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.conn, b); err != nil {
+		s.t.Errorf("server read failed: %v", err)
+	}
+	return b
+}
+
+func (s *fakeServer) handshake() {
+	s.write([]byte(s.version))
+	reply := string(s.read(12))
+
+	security := byte(securityNone)
+	if s.password != "" {
+		security = securityVNCAuth
+	}
+	if reply == "RFB 003.003\n" {
+		s.write(binary.BigEndian.AppendUint32(nil, uint32(security)))
+	} else {
+		s.write([]byte{2, securityVNCAuth, security})
+		if got := s.read(1)[0]; got != security {
+			s.t.Errorf("client chose security %d, want %d", got, security)
+		}
+	}
+
+	if security == securityVNCAuth {
+		var challenge [16]byte
+		for i := range challenge {
+			challenge[i] = byte(i * 7)
+		}
+		s.write(challenge[:])
+		want := encryptChallenge(challenge, s.password)
+		if string(s.read(16)) != string(want) {
+			msg := binary.BigEndian.AppendUint32(nil, 1)
+			reason := "bad password"
+			msg = binary.BigEndian.AppendUint32(msg, uint32(len(reason)))
+			s.write(append(msg, reason...))
+			return
+		}
+	}
+	if security == securityVNCAuth || reply == "RFB 003.008\n" {
+		s.write(binary.BigEndian.AppendUint32(nil, 0))
+	}
+
+	s.read(1) // ClientInit
+	init := binary.BigEndian.AppendUint16(nil, uint16(s.width))
+	init = binary.BigEndian.AppendUint16(init, uint16(s.height))
+	init = append(init, make([]byte, 16)...)
+	init = binary.BigEndian.AppendUint32(init, 4)
+	s.write(append(init, "test"...))
+
+	s.read(20) // SetPixelFormat
+	header := s.read(4)
+	var encodings []int32
+	for i := 0; i < int(binary.BigEndian.Uint16(header[2:])); i++ {
+		encodings = append(encodings, int32(binary.BigEndian.Uint32(s.read(4))))
+	}
+	s.encodings <- encodings
+}
+
+// update answers one update request with the given rectangles
+func (s *fakeServer) update(rects ...[]byte) {
+	s.read(10) // FramebufferUpdateRequest
+	msg := []byte{msgFramebufferUpdate, 0}
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(rects)))
+	for _, r := range rects {
+		msg = append(msg, r...)
+	}
+	s.write(msg)
+}
+
+// Helper function to encode a rectangle header
+func rectHeader(x, y, w, h int, encoding int32) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(x))
+	b = binary.BigEndian.AppendUint16(b, uint16(y))
+	b = binary.BigEndian.AppendUint16(b, uint16(w))
+	b = binary.BigEndian.AppendUint16(b, uint16(h))
+	return binary.BigEndian.AppendUint32(b, uint32(encoding))
+}
+
+// Helper function to encode a raw rectangle of one color
+func rawRect(x, y, w, h int, c color.RGBA) []byte {
+	b := rectHeader(x, y, w, h, encodingRaw)
+	for i := 0; i < w*h; i++ {
+		b = append(b, c.R, c.G, c.B, 0)
+	}
+	return b
+}
+
+func TestClientRawUpdate(t *testing.T) {
+	server := &fakeServer{version: "RFB 003.008\n", width: 4, height: 3}
+	client, err := dialFake(t, server, "")
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	if client.Name != "test" {
+		t.Errorf("Name = %q, want test", client.Name)
+	}
+	if w, h := client.Size(); w != 4 || h != 3 {
+		t.Errorf("Size() = %dx%d, want 4x3", w, h)
+	}
+	if client.Image(image.Rect(0, 0, 4, 3)) != nil {
+		t.Error("Image() before the first update should be nil")
+	}
+
+	red := color.RGBA{R: 255, A: 255}
+	go server.update(rawRect(1, 1, 2, 1, red))
+	if err := client.Update(false); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	img := client.Image(image.Rect(0, 0, 4, 3))
+	if got := img.RGBAAt(1, 1); got != red {
+		t.Errorf("updated pixel = %v, want %v", got, red)
+	}
+	if got := img.RGBAAt(0, 0); got != (color.RGBA{}) {
+		t.Errorf("untouched pixel = %v, want zero", got)
+	}
+
+	crop := client.Image(image.Rect(1, 1, 3, 2))
+	if crop.Bounds() != image.Rect(0, 0, 2, 1) || crop.RGBAAt(1, 0) != red {
+		t.Errorf("cropped image = %v with %v, want 2x1 red", crop.Bounds(), crop.RGBAAt(1, 0))
+	}
+}
+
+func TestClientCopyRect(t *testing.T) {
+	server := &fakeServer{version: "RFB 003.008\n", width: 4, height: 2}
+	client, err := dialFake(t, server, "")
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+
+	blue := color.RGBA{B: 255, A: 255}
+	go func() {
+		server.update(rawRect(0, 0, 1, 1, blue))
+		copyRect := append(rectHeader(3, 1, 1, 1, encodingCopyRect), 0, 0, 0, 0)
+		server.update(copyRect)
+	}()
+	for i := 0; i < 2; i++ {
+		if err := client.Update(i > 0); err != nil {
+			t.Fatalf("Update() failed: %v", err)
+		}
+	}
+
+	if got := client.Image(image.Rect(0, 0, 4, 2)).RGBAAt(3, 1); got != blue {
+		t.Errorf("copied pixel = %v, want %v", got, blue)
+	}
+}
+
+func TestClientHextile(t *testing.T) {
+	server := &fakeServer{version: "RFB 003.008\n", width: 20, height: 4}
+	client, err := dialFake(t, server, "")
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+
+	// A 20x4 rectangle is two tiles: 16x4 and 4x4
+	rect := rectHeader(0, 0, 20, 4, encodingHextile)
+	// First tile: white background with a red 2x3 subrect at (1,1)
+	rect = append(rect, hextileBackgroundSpecified|hextileForegroundSpecified|hextileAnySubrects)
+	rect = append(rect, 255, 255, 255, 0, 255, 0, 0, 0)
+	rect = append(rect, 1, 0x1<<4|0x1, 0x1<<4|0x2)
+	// Second tile: keeps the white background, one green subrect at (0,0)
+	rect = append(rect, hextileAnySubrects|hextileSubrectsColoured, 1)
+	rect = append(rect, 0, 255, 0, 0, 0x00, 0x00)
+
+	go server.update(rect)
+	if err := client.Update(false); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	img := client.Image(image.Rect(0, 0, 20, 4))
+	white := color.RGBA{255, 255, 255, 255}
+	red := color.RGBA{R: 255, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, white},
+		{1, 1, red},
+		{2, 3, red},
+		{3, 1, white},
+		{15, 3, white},
+		{16, 0, green},
+		{17, 0, white},
+		{19, 3, white},
+	}
+	for _, tt := range tests {
+		if got := img.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("pixel (%d,%d) = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestClientHextileRawTile(t *testing.T) {
+	server := &fakeServer{version: "RFB 003.008\n", width: 2, height: 1}
+	client, err := dialFake(t, server, "")
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+
+	rect := append(rectHeader(0, 0, 2, 1, encodingHextile), hextileRaw)
+	rect = append(rect, 0, 0, 255, 0, 10, 20, 30, 0)
+	go server.update(rect)
+	if err := client.Update(false); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	img := client.Image(image.Rect(0, 0, 2, 1))
+	if got, want := img.RGBAAt(1, 0), (color.RGBA{10, 20, 30, 255}); got != want {
+		t.Errorf("raw tile pixel = %v, want %v", got, want)
+	}
+}
+
+func TestClientCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor bool
+		want   bool // Whether the Cursor pseudo-encoding is requested
+	}{
+		{"server draws the pointer", true, false},
+		{"pointer left out", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeServer{version: "RFB 003.008\n", width: 2, height: 2}
+			if _, err := dialFakeCursor(t, server, "", tt.cursor); err != nil {
+				t.Fatalf("NewClient() failed: %v", err)
+			}
+			got := false
+			for _, e := range <-server.encodings {
+				got = got || e == encodingCursor
+			}
+			if got != tt.want {
+				t.Errorf("Cursor pseudo-encoding requested = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientDiscardsCursorShape(t *testing.T) {
+	server := &fakeServer{version: "RFB 003.008\n", width: 2, height: 1}
+	client, err := dialFakeCursor(t, server, "", false)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+
+	// A 9x2 pointer with its hot spot at (4,1): pixels then a 2x2 byte mask
+	shape := append(rectHeader(4, 1, 9, 2, encodingCursor), make([]byte, 4*9*2+2*2)...)
+	red := color.RGBA{R: 255, A: 255}
+	go server.update(shape, rawRect(0, 0, 2, 1, red))
+	if err := client.Update(false); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if got := client.Image(image.Rect(0, 0, 2, 1)).RGBAAt(1, 0); got != red {
+		t.Errorf("pixel after cursor shape = %v, want %v", got, red)
+	}
+}
+
+func TestClientSkipsServerMessages(t *testing.T) {
+	server := &fakeServer{version: "RFB 003.008\n", width: 1, height: 1}
+	client, err := dialFake(t, server, "")
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+
+	go func() {
+		server.read(10)
+		cut := append([]byte{msgServerCutText, 0, 0, 0}, binary.BigEndian.AppendUint32(nil, 2)...)
+		server.write(append(append([]byte{msgBell}, cut...), "hi"...))
+		msg := []byte{msgFramebufferUpdate, 0, 0, 1}
+		server.write(append(msg, rawRect(0, 0, 1, 1, color.RGBA{G: 255})...))
+	}()
+	if err := client.Update(false); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+}
+
+func TestClientAuthentication(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		password string
+		wantErr  bool
+	}{
+		{"3.8 correct password", "RFB 003.008\n", "s3cret", false},
+		{"3.8 wrong password", "RFB 003.008\n", "guess", true},
+		{"3.3 correct password", "RFB 003.003\n", "s3cret", false},
+		{"3.7 no password", "RFB 003.007\n", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeServer{version: tt.version, width: 2, height: 2}
+			if tt.password != "" {
+				server.password = "s3cret"
+			}
+			_, err := dialFake(t, server, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrAuthFailed) {
+				t.Errorf("NewClient() error = %v, want ErrAuthFailed", err)
+			}
+		})
+	}
+}
+
+func TestAddr(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"localhost", "localhost:5900"},
+		{"localhost:1", "localhost:5901"},
+		{"localhost:5905", "localhost:5905"},
+		{"[::1]:2", "[::1]:5902"},
+	}
+
+	for _, tt := range tests {
+		if got := Addr(tt.in); got != tt.want {
+			t.Errorf("Addr(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
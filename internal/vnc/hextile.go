@@ -0,0 +1,92 @@
+package vnc
+
+import (
+	"image"
+	"io"
+)
+
+// Hextile subencoding flags
+const (
+	hextileRaw                 = 1
+	hextileBackgroundSpecified = 2
+	hextileForegroundSpecified = 4
+	hextileAnySubrects         = 8
+	hextileSubrectsColoured    = 16
+)
+
+// hextileSize is the width and height of a full Hextile tile
+const hextileSize = 16
+
+// readHextile reads a Hextile rectangle into r. The rectangle is sent as
+// 16x16 tiles, left to right and top to bottom, each either raw or a
+// background color with solid subrectangles drawn over it. Background and
+// foreground colors carry over from one tile to the next.
+func (c *Client) readHextile(r image.Rectangle) error {
+	var bg, fg [4]byte
+	for y := r.Min.Y; y < r.Max.Y; y += hextileSize {
+		for x := r.Min.X; x < r.Max.X; x += hextileSize {
+			tile := image.Rect(x, y, x+hextileSize, y+hextileSize).Intersect(r)
+
+			flags, err := c.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if flags&hextileRaw != 0 {
+				if err := c.readRaw(tile); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if flags&hextileBackgroundSpecified != 0 {
+				if _, err := io.ReadFull(c.r, bg[:]); err != nil {
+					return err
+				}
+			}
+			if flags&hextileForegroundSpecified != 0 {
+				if _, err := io.ReadFull(c.r, fg[:]); err != nil {
+					return err
+				}
+			}
+			c.fill(tile, bg)
+
+			if flags&hextileAnySubrects == 0 {
+				continue
+			}
+			count, err := c.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			for i := 0; i < int(count); i++ {
+				color := fg
+				if flags&hextileSubrectsColoured != 0 {
+					if _, err := io.ReadFull(c.r, color[:]); err != nil {
+						return err
+					}
+				}
+				var geom [2]byte
+				if _, err := io.ReadFull(c.r, geom[:]); err != nil {
+					return err
+				}
+				at := tile.Min.Add(image.Pt(int(geom[0]>>4), int(geom[0]&15)))
+				size := image.Pt(int(geom[1]>>4)+1, int(geom[1]&15)+1)
+				c.fill(image.Rectangle{Min: at, Max: at.Add(size)}.Intersect(tile), color)
+			}
+		}
+	}
+	return nil
+}
+
+// fill sets every pixel in r to an RGBX color
+func (c *Client) fill(r image.Rectangle, color [4]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pixel := [4]byte{color[0], color[1], color[2], 255}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := c.fb.Pix[c.fb.PixOffset(r.Min.X, y):][:4*r.Dx()]
+		for x := 0; x < len(row); x += 4 {
+			copy(row[x:], pixel[:])
+		}
+	}
+}
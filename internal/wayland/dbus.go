@@ -0,0 +1,881 @@
+//go:build linux
+// +build linux
+
+package wayland
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// D-Bus message types
+const (
+	msgMethodCall   = 1
+	msgMethodReturn = 2
+	msgError        = 3
+	msgSignal       = 4
+)
+
+// D-Bus header field codes
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+	fieldUnixFDs     = 9
+)
+
+// objectPath is a D-Bus object path ("o")
+type objectPath string
+
+// signature is a D-Bus type signature ("g")
+type signature string
+
+// variant is a D-Bus value tagged with its type ("v")
+type variant struct {
+	sig   string
+	value any
+}
+
+// message is a decoded D-Bus message
+type message struct {
+	typ         byte
+	serial      uint32
+	replySerial uint32
+	path        objectPath
+	iface       string
+	member      string
+	errName     string
+	dest        string
+	sender      string
+	sig         string
+	body        []any
+	fds         []int
+}
+
+// conn is a minimal D-Bus session bus client. It supports just what the
+// screencast portal needs: method calls, signals, and receiving file
+// descriptors.
+type conn struct {
+	c       *net.UnixConn
+	name    string // Unique bus name assigned by the daemon
+	serial  uint32
+	buf     []byte
+	fds     []int
+	signals []*message
+}
+
+// dialSessionBus connects and authenticates to the user's session bus
+func dialSessionBus() (*conn, error) {
+	addr, err := sessionBusAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: addr, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+
+	return newConn(c)
+}
+
+// newConn authenticates over c and registers with the bus
+func newConn(c *net.UnixConn) (*conn, error) {
+	bus := &conn{c: c}
+	if err := bus.auth(); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	reply, err := bus.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", "")
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	var name string
+	if len(reply.body) > 0 {
+		name, _ = reply.body[0].(string)
+	}
+	if name == "" {
+		c.Close()
+		return nil, fmt.Errorf("unexpected reply to Hello: %v", reply.body)
+	}
+	bus.name = name
+
+	return bus, nil
+}
+
+// sessionBusAddress returns the socket named by DBUS_SESSION_BUS_ADDRESS,
+// falling back to the systemd default of $XDG_RUNTIME_DIR/bus
+func sessionBusAddress() (string, error) {
+	addrs := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if addrs == "" {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			return dir + "/bus", nil
+		}
+		return "", fmt.Errorf("no session bus: DBUS_SESSION_BUS_ADDRESS is not set")
+	}
+
+	for _, addr := range strings.Split(addrs, ";") {
+		transport, params, ok := strings.Cut(addr, ":")
+		if !ok || transport != "unix" {
+			continue
+		}
+		for _, param := range strings.Split(params, ",") {
+			key, value, _ := strings.Cut(param, "=")
+			switch key {
+			case "path":
+				return unescapeAddress(value), nil
+			case "abstract":
+				return "@" + unescapeAddress(value), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no supported transport in session bus address %q", addrs)
+}
+
+// unescapeAddress decodes the %xx escapes allowed in D-Bus addresses
+func unescapeAddress(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// auth performs SASL EXTERNAL authentication and enables fd passing
+func (c *conn) auth() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := c.c.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return fmt.Errorf("failed to authenticate with session bus: %w", err)
+	}
+	if line, err := c.readLine(); err != nil || !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("session bus rejected authentication: %q %v", line, err)
+	}
+
+	if _, err := c.c.Write([]byte("NEGOTIATE_UNIX_FD\r\n")); err != nil {
+		return fmt.Errorf("failed to authenticate with session bus: %w", err)
+	}
+	if line, err := c.readLine(); err != nil || line != "AGREE_UNIX_FD" {
+		return fmt.Errorf("session bus does not support file descriptor passing: %q %v", line, err)
+	}
+
+	if _, err := c.c.Write([]byte("BEGIN\r\n")); err != nil {
+		return fmt.Errorf("failed to authenticate with session bus: %w", err)
+	}
+	return nil
+}
+
+// readLine reads one CRLF-terminated authentication line
+func (c *conn) readLine() (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if _, err := c.c.Read(b); err != nil {
+			return string(line), err
+		}
+		line = append(line, b[0])
+	}
+	return string(line[:len(line)-2]), nil
+}
+
+// Close closes the connection and any received descriptors nobody claimed
+func (c *conn) Close() error {
+	for _, fd := range c.fds {
+		syscall.Close(fd)
+	}
+	c.fds = nil
+	return c.c.Close()
+}
+
+// call invokes a method and waits for its reply. Signals received in the
+// meantime are queued for waitSignal.
+func (c *conn) call(dest string, path objectPath, iface, member, sig string, args ...any) (*message, error) {
+	c.serial++
+	serial := c.serial
+	msg := &message{
+		typ:    msgMethodCall,
+		serial: serial,
+		path:   path,
+		iface:  iface,
+		member: member,
+		dest:   dest,
+		sig:    sig,
+		body:   args,
+	}
+
+	data, err := msg.marshal()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.c.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to call %s.%s: %w", iface, member, err)
+	}
+
+	for {
+		reply, err := c.read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to call %s.%s: %w", iface, member, err)
+		}
+
+		switch {
+		case reply.typ == msgSignal:
+			c.signals = append(c.signals, reply)
+		case reply.replySerial != serial:
+			closeAll(reply.fds)
+		case reply.typ == msgError:
+			detail := ""
+			if len(reply.body) > 0 {
+				detail = fmt.Sprintf(": %v", reply.body[0])
+			}
+			return nil, fmt.Errorf("%s.%s failed: %s%s", iface, member, reply.errName, detail)
+		default:
+			return reply, nil
+		}
+	}
+}
+
+// waitSignal returns the next signal emitted by path with the given member
+func (c *conn) waitSignal(path objectPath, iface, member string) (*message, error) {
+	for i, msg := range c.signals {
+		if msg.path == path && msg.iface == iface && msg.member == member {
+			c.signals = append(c.signals[:i], c.signals[i+1:]...)
+			return msg, nil
+		}
+	}
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return nil, fmt.Errorf("failed waiting for %s.%s: %w", iface, member, err)
+		}
+		if msg.typ == msgSignal && msg.path == path && msg.iface == iface && msg.member == member {
+			return msg, nil
+		}
+		if msg.typ == msgSignal {
+			c.signals = append(c.signals, msg)
+		} else {
+			closeAll(msg.fds)
+		}
+	}
+}
+
+// read returns the next message from the bus
+func (c *conn) read() (*message, error) {
+	buf := make([]byte, 4096)
+	oob := make([]byte, syscall.CmsgSpace(16*4))
+
+	for {
+		n := messageLength(c.buf)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid D-Bus message endianness %q", c.buf[0])
+		}
+		if n > 0 && len(c.buf) >= n {
+			msg, err := unmarshalMessage(c.buf[:n], c.fds)
+			c.buf = c.buf[n:]
+			if err != nil {
+				return nil, err
+			}
+			c.fds = c.fds[len(msg.fds):]
+			return msg, nil
+		}
+
+		n, oobn, _, _, err := c.c.ReadMsgUnix(buf, oob)
+		if err != nil {
+			return nil, err
+		}
+		c.buf = append(c.buf, buf[:n]...)
+
+		if oobn > 0 {
+			cmsgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse control message: %w", err)
+			}
+			for _, cmsg := range cmsgs {
+				fds, err := syscall.ParseUnixRights(&cmsg)
+				if err == nil {
+					c.fds = append(c.fds, fds...)
+				}
+			}
+		}
+	}
+}
+
+// closeAll closes file descriptors attached to a discarded message
+func closeAll(fds []int) {
+	for _, fd := range fds {
+		syscall.Close(fd)
+	}
+}
+
+// messageLength returns the total length of the message at the start of
+// data, or 0 if the fixed header has not arrived yet
+func messageLength(data []byte) int {
+	if len(data) < 16 {
+		return 0
+	}
+	order := byteOrder(data[0])
+	if order == nil {
+		return -1
+	}
+	fields := int(order.Uint32(data[12:]))
+	body := int(order.Uint32(data[4:]))
+	return align(16+fields, 8) + body
+}
+
+// byteOrder returns the byte order for a message endianness flag
+func byteOrder(flag byte) binary.ByteOrder {
+	switch flag {
+	case 'l':
+		return binary.LittleEndian
+	case 'B':
+		return binary.BigEndian
+	default:
+		return nil
+	}
+}
+
+// marshal encodes the message in little-endian wire format
+func (m *message) marshal() ([]byte, error) {
+	body := &encoder{}
+	if m.sig != "" {
+		sigs, err := splitSignature(m.sig)
+		if err != nil {
+			return nil, err
+		}
+		if len(sigs) != len(m.body) {
+			return nil, fmt.Errorf("signature %q needs %d arguments, got %d", m.sig, len(sigs), len(m.body))
+		}
+		for i, sig := range sigs {
+			if err := body.encode(sig, m.body[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var fields []any
+	add := func(code byte, sig string, value any) {
+		fields = append(fields, []any{code, variant{sig: sig, value: value}})
+	}
+	if m.path != "" {
+		add(fieldPath, "o", m.path)
+	}
+	if m.iface != "" {
+		add(fieldInterface, "s", m.iface)
+	}
+	if m.member != "" {
+		add(fieldMember, "s", m.member)
+	}
+	if m.errName != "" {
+		add(fieldErrorName, "s", m.errName)
+	}
+	if m.replySerial != 0 {
+		add(fieldReplySerial, "u", m.replySerial)
+	}
+	if m.dest != "" {
+		add(fieldDestination, "s", m.dest)
+	}
+	if m.sender != "" {
+		add(fieldSender, "s", m.sender)
+	}
+	if m.sig != "" {
+		add(fieldSignature, "g", signature(m.sig))
+	}
+	if len(m.fds) > 0 {
+		add(fieldUnixFDs, "u", uint32(len(m.fds)))
+	}
+
+	header := &encoder{}
+	header.buf = append(header.buf, 'l', m.typ, 0, 1)
+	header.encode("u", uint32(len(body.buf)))
+	header.encode("u", m.serial)
+	if err := header.encode("a(yv)", fields); err != nil {
+		return nil, err
+	}
+	header.align(8)
+
+	return append(header.buf, body.buf...), nil
+}
+
+// unmarshalMessage decodes one complete message, taking the descriptors it
+// carries from the front of fds
+func unmarshalMessage(data []byte, fds []int) (*message, error) {
+	order := byteOrder(data[0])
+	if order == nil {
+		return nil, fmt.Errorf("invalid D-Bus message endianness %q", data[0])
+	}
+
+	d := &decoder{buf: data, order: order, off: 8}
+	m := &message{typ: data[1]}
+	serial, _ := d.decode("u")
+	m.serial = serial.(uint32)
+
+	raw, err := d.decode("a(yv)")
+	if err != nil {
+		return nil, fmt.Errorf("invalid D-Bus header: %w", err)
+	}
+	for _, f := range raw.([]any) {
+		field := f.([]any)
+		v := field[1].(variant).value
+		switch field[0].(byte) {
+		case fieldPath:
+			m.path, _ = v.(objectPath)
+		case fieldInterface:
+			m.iface, _ = v.(string)
+		case fieldMember:
+			m.member, _ = v.(string)
+		case fieldErrorName:
+			m.errName, _ = v.(string)
+		case fieldReplySerial:
+			m.replySerial, _ = v.(uint32)
+		case fieldDestination:
+			m.dest, _ = v.(string)
+		case fieldSender:
+			m.sender, _ = v.(string)
+		case fieldSignature:
+			sig, _ := v.(signature)
+			m.sig = string(sig)
+		case fieldUnixFDs:
+			n, _ := v.(uint32)
+			if int(n) > len(fds) {
+				return nil, fmt.Errorf("message carries %d file descriptors but %d were received", n, len(fds))
+			}
+			m.fds = fds[:n:n]
+		}
+	}
+
+	d.align(8)
+	body := &decoder{buf: data[d.off:], order: order, fds: m.fds}
+	sigs, err := splitSignature(m.sig)
+	if err != nil {
+		return nil, err
+	}
+	for _, sig := range sigs {
+		v, err := body.decode(sig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s body: %w", m.member, err)
+		}
+		m.body = append(m.body, v)
+	}
+
+	return m, nil
+}
+
+// align rounds n up to a multiple of a
+func align(n, a int) int {
+	return (n + a - 1) / a * a
+}
+
+// alignment returns the wire alignment of a type
+func alignment(t byte) int {
+	switch t {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 'h', 's', 'o', 'a':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	default:
+		return 1
+	}
+}
+
+// splitSignature splits a signature into its complete types
+func splitSignature(sig string) ([]string, error) {
+	var types []string
+	for sig != "" {
+		n, err := typeLength(sig)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, sig[:n])
+		sig = sig[n:]
+	}
+	return types, nil
+}
+
+// typeLength returns the length of the first complete type in sig
+func typeLength(sig string) (int, error) {
+	if sig == "" {
+		return 0, fmt.Errorf("empty signature")
+	}
+
+	switch sig[0] {
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 's', 'o', 'g', 'v', 'h':
+		return 1, nil
+	case 'a':
+		n, err := typeLength(sig[1:])
+		return n + 1, err
+	case '(', '{':
+		end := byte(')')
+		if sig[0] == '{' {
+			end = '}'
+		}
+		i := 1
+		for i < len(sig) && sig[i] != end {
+			n, err := typeLength(sig[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+		if i >= len(sig) {
+			return 0, fmt.Errorf("unterminated signature %q", sig)
+		}
+		return i + 1, nil
+	default:
+		return 0, fmt.Errorf("unsupported type %q in signature", sig[0])
+	}
+}
+
+// encoder writes values in little-endian D-Bus wire format
+type encoder struct {
+	buf []byte
+}
+
+// align pads the buffer to a multiple of n
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// encode writes v as the single complete type sig
+func (e *encoder) encode(sig string, v any) error {
+	e.align(alignment(sig[0]))
+	le := binary.LittleEndian
+
+	switch sig[0] {
+	case 'y':
+		b, ok := v.(byte)
+		if !ok {
+			return typeError(sig, v)
+		}
+		e.buf = append(e.buf, b)
+	case 'b':
+		b, ok := v.(bool)
+		if !ok {
+			return typeError(sig, v)
+		}
+		var u uint32
+		if b {
+			u = 1
+		}
+		e.buf = le.AppendUint32(e.buf, u)
+	case 'i':
+		i, ok := v.(int32)
+		if !ok {
+			return typeError(sig, v)
+		}
+		e.buf = le.AppendUint32(e.buf, uint32(i))
+	case 'u', 'h':
+		// File descriptors are sent as an index into the message's fds
+		u, ok := v.(uint32)
+		if !ok {
+			return typeError(sig, v)
+		}
+		e.buf = le.AppendUint32(e.buf, u)
+	case 's', 'o':
+		var s string
+		switch x := v.(type) {
+		case string:
+			s = x
+		case objectPath:
+			s = string(x)
+		default:
+			return typeError(sig, v)
+		}
+		e.buf = le.AppendUint32(e.buf, uint32(len(s)))
+		e.buf = append(append(e.buf, s...), 0)
+	case 'g':
+		s, ok := v.(signature)
+		if !ok {
+			return typeError(sig, v)
+		}
+		e.buf = append(append(append(e.buf, byte(len(s))), s...), 0)
+	case 'v':
+		x, ok := v.(variant)
+		if !ok {
+			return typeError(sig, v)
+		}
+		if err := e.encode("g", signature(x.sig)); err != nil {
+			return err
+		}
+		return e.encode(x.sig, x.value)
+	case 'a':
+		return e.encodeArray(sig[1:], v)
+	case '(':
+		fields, ok := v.([]any)
+		if !ok {
+			return typeError(sig, v)
+		}
+		sigs, err := splitSignature(sig[1 : len(sig)-1])
+		if err != nil {
+			return err
+		}
+		if len(sigs) != len(fields) {
+			return fmt.Errorf("struct %s needs %d fields, got %d", sig, len(sigs), len(fields))
+		}
+		for i, s := range sigs {
+			if err := e.encode(s, fields[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode type %q", sig)
+	}
+
+	return nil
+}
+
+// encodeArray writes an array of elem. Dictionaries are only supported
+// with string keys and variant values (a{sv}), the only kind portals use.
+func (e *encoder) encodeArray(elem string, v any) error {
+	lenAt := len(e.buf)
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	e.align(alignment(elem[0]))
+	start := len(e.buf)
+
+	if elem == "{sv}" {
+		dict, ok := v.(map[string]variant)
+		if !ok {
+			return typeError("a"+elem, v)
+		}
+		keys := make([]string, 0, len(dict))
+		for k := range dict {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			e.align(8)
+			if err := e.encode("s", k); err != nil {
+				return err
+			}
+			if err := e.encode("v", dict[k]); err != nil {
+				return err
+			}
+		}
+	} else {
+		items, ok := v.([]any)
+		if !ok {
+			return typeError("a"+elem, v)
+		}
+		for _, item := range items {
+			if err := e.encode(elem, item); err != nil {
+				return err
+			}
+		}
+	}
+
+	binary.LittleEndian.PutUint32(e.buf[lenAt:], uint32(len(e.buf)-start))
+	return nil
+}
+
+// typeError reports a Go value that does not match its signature
+func typeError(sig string, v any) error {
+	return fmt.Errorf("cannot encode %T as D-Bus type %q", v, sig)
+}
+
+// decoder reads values in D-Bus wire format
+type decoder struct {
+	buf   []byte
+	off   int
+	order binary.ByteOrder
+	fds   []int
+}
+
+// align skips padding up to a multiple of n
+func (d *decoder) align(n int) {
+	d.off = align(d.off, n)
+}
+
+// need checks that n more bytes are available
+func (d *decoder) need(n int) error {
+	if d.off+n > len(d.buf) {
+		return fmt.Errorf("message truncated")
+	}
+	return nil
+}
+
+// decode reads the single complete type sig. Integers decode to their
+// sized Go types, arrays and structs to []any, and dictionaries to
+// map[string]any.
+func (d *decoder) decode(sig string) (any, error) {
+	d.align(alignment(sig[0]))
+
+	switch sig[0] {
+	case 'y':
+		if err := d.need(1); err != nil {
+			return nil, err
+		}
+		d.off++
+		return d.buf[d.off-1], nil
+	case 'n', 'q':
+		if err := d.need(2); err != nil {
+			return nil, err
+		}
+		u := d.order.Uint16(d.buf[d.off:])
+		d.off += 2
+		if sig[0] == 'n' {
+			return int16(u), nil
+		}
+		return u, nil
+	case 'b', 'i', 'u', 'h':
+		if err := d.need(4); err != nil {
+			return nil, err
+		}
+		u := d.order.Uint32(d.buf[d.off:])
+		d.off += 4
+		switch sig[0] {
+		case 'b':
+			return u != 0, nil
+		case 'i':
+			return int32(u), nil
+		case 'h':
+			if int(u) >= len(d.fds) {
+				return nil, fmt.Errorf("file descriptor index %d out of range", u)
+			}
+			return d.fds[u], nil
+		}
+		return u, nil
+	case 'x', 't', 'd':
+		if err := d.need(8); err != nil {
+			return nil, err
+		}
+		u := d.order.Uint64(d.buf[d.off:])
+		d.off += 8
+		switch sig[0] {
+		case 'x':
+			return int64(u), nil
+		case 'd':
+			return math.Float64frombits(u), nil
+		}
+		return u, nil
+	case 's', 'o':
+		if err := d.need(4); err != nil {
+			return nil, err
+		}
+		n := int(d.order.Uint32(d.buf[d.off:]))
+		d.off += 4
+		if err := d.need(n + 1); err != nil {
+			return nil, err
+		}
+		s := string(d.buf[d.off : d.off+n])
+		d.off += n + 1
+		if sig[0] == 'o' {
+			return objectPath(s), nil
+		}
+		return s, nil
+	case 'g':
+		if err := d.need(1); err != nil {
+			return nil, err
+		}
+		n := int(d.buf[d.off])
+		d.off++
+		if err := d.need(n + 1); err != nil {
+			return nil, err
+		}
+		s := signature(d.buf[d.off : d.off+n])
+		d.off += n + 1
+		return s, nil
+	case 'v':
+		s, err := d.decode("g")
+		if err != nil {
+			return nil, err
+		}
+		inner := string(s.(signature))
+		if n, err := typeLength(inner); err != nil || n != len(inner) {
+			return nil, fmt.Errorf("invalid variant signature %q", inner)
+		}
+		v, err := d.decode(inner)
+		if err != nil {
+			return nil, err
+		}
+		return variant{sig: inner, value: v}, nil
+	case 'a':
+		return d.decodeArray(sig[1:])
+	case '(':
+		sigs, err := splitSignature(sig[1 : len(sig)-1])
+		if err != nil {
+			return nil, err
+		}
+		fields := make([]any, 0, len(sigs))
+		for _, s := range sigs {
+			v, err := d.decode(s)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, v)
+		}
+		return fields, nil
+	default:
+		return nil, fmt.Errorf("cannot decode type %q", sig)
+	}
+}
+
+// decodeArray reads an array of elem
+func (d *decoder) decodeArray(elem string) (any, error) {
+	if err := d.need(4); err != nil {
+		return nil, err
+	}
+	n := int(d.order.Uint32(d.buf[d.off:]))
+	d.off += 4
+	d.align(alignment(elem[0]))
+	if err := d.need(n); err != nil {
+		return nil, err
+	}
+	end := d.off + n
+
+	if elem[0] == '{' {
+		sigs, err := splitSignature(elem[1 : len(elem)-1])
+		if err != nil || len(sigs) != 2 {
+			return nil, fmt.Errorf("invalid dictionary signature %q", elem)
+		}
+		dict := make(map[string]any)
+		for d.off < end {
+			d.align(8)
+			k, err := d.decode(sigs[0])
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.decode(sigs[1])
+			if err != nil {
+				return nil, err
+			}
+			dict[fmt.Sprint(k)] = v
+		}
+		return dict, nil
+	}
+
+	items := []any{}
+	for d.off < end {
+		v, err := d.decode(elem)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
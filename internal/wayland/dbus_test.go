@@ -0,0 +1,229 @@
+//go:build linux
+// +build linux
+
+package wayland
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		sig   string
+		value any
+		want  any
+	}{
+		{name: "byte", sig: "y", value: byte(7), want: byte(7)},
+		{name: "bool", sig: "b", value: true, want: true},
+		{name: "int32", sig: "i", value: int32(-5), want: int32(-5)},
+		{name: "uint32", sig: "u", value: uint32(42), want: uint32(42)},
+		{name: "string", sig: "s", value: "hello", want: "hello"},
+		{name: "object path", sig: "o", value: objectPath("/a/b"), want: objectPath("/a/b")},
+		{name: "signature", sig: "g", value: signature("a{sv}"), want: signature("a{sv}")},
+		{
+			name:  "variant",
+			sig:   "v",
+			value: variant{sig: "u", value: uint32(3)},
+			want:  variant{sig: "u", value: uint32(3)},
+		},
+		{
+			name:  "struct",
+			sig:   "(yv)",
+			value: []any{byte(1), variant{sig: "s", value: "x"}},
+			want:  []any{byte(1), variant{sig: "s", value: "x"}},
+		},
+		{
+			name:  "array",
+			sig:   "as",
+			value: []any{"a", "bc"},
+			want:  []any{"a", "bc"},
+		},
+		{
+			name:  "empty array",
+			sig:   "a(ii)",
+			value: []any{},
+			want:  []any{},
+		},
+		{
+			name: "dictionary",
+			sig:  "a{sv}",
+			value: map[string]variant{
+				"types":    {sig: "u", value: uint32(1)},
+				"multiple": {sig: "b", value: false},
+			},
+			want: map[string]any{
+				"types":    variant{sig: "u", value: uint32(1)},
+				"multiple": variant{sig: "b", value: false},
+			},
+		},
+		{
+			name: "streams",
+			sig:  "a(ua{sv})",
+			value: []any{[]any{uint32(9), map[string]variant{
+				"size": {sig: "(ii)", value: []any{int32(640), int32(480)}},
+			}}},
+			want: []any{[]any{uint32(9), map[string]any{
+				"size": variant{sig: "(ii)", value: []any{int32(640), int32(480)}},
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A leading byte checks that alignment padding is handled
+			e := &encoder{buf: []byte{0xff}}
+			if err := e.encode(tt.sig, tt.value); err != nil {
+				t.Fatalf("encode() failed: %v", err)
+			}
+
+			d := &decoder{buf: e.buf, off: 1, order: binary.LittleEndian}
+			got, err := d.decode(tt.sig)
+			if err != nil {
+				t.Fatalf("decode() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decode() = %#v, want %#v", got, tt.want)
+			}
+			if d.off != len(e.buf) {
+				t.Errorf("decode() consumed %d bytes, want %d", d.off, len(e.buf))
+			}
+		})
+	}
+}
+
+func TestEncodeTypeMismatch(t *testing.T) {
+	e := &encoder{}
+	if err := e.encode("u", "not a number"); err == nil {
+		t.Error("expected error encoding a string as uint32")
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	e := &encoder{}
+	e.encode("s", "hello")
+
+	d := &decoder{buf: e.buf[:6], order: binary.LittleEndian}
+	if _, err := d.decode("s"); err == nil {
+		t.Error("expected error decoding a truncated string")
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	msg := &message{
+		typ:    msgMethodCall,
+		serial: 7,
+		path:   "/org/freedesktop/portal/desktop",
+		iface:  screenCastIface,
+		member: "SelectSources",
+		dest:   portalBus,
+		sig:    "oa{sv}",
+		body: []any{
+			objectPath("/session/1"),
+			map[string]variant{"types": {sig: "u", value: uint32(1)}},
+		},
+	}
+
+	data, err := msg.marshal()
+	if err != nil {
+		t.Fatalf("marshal() failed: %v", err)
+	}
+	if n := messageLength(data); n != len(data) {
+		t.Fatalf("messageLength() = %d, want %d", n, len(data))
+	}
+	if n := messageLength(data[:10]); n != 0 {
+		t.Errorf("messageLength() of a partial header = %d, want 0", n)
+	}
+
+	got, err := unmarshalMessage(data, nil)
+	if err != nil {
+		t.Fatalf("unmarshalMessage() failed: %v", err)
+	}
+	if got.serial != msg.serial || got.path != msg.path || got.iface != msg.iface ||
+		got.member != msg.member || got.dest != msg.dest || got.sig != msg.sig {
+		t.Errorf("header = %+v, want %+v", got, msg)
+	}
+	if path := got.body[0]; path != objectPath("/session/1") {
+		t.Errorf("body[0] = %v, want /session/1", path)
+	}
+}
+
+func TestMessageFileDescriptors(t *testing.T) {
+	msg := &message{typ: msgMethodReturn, serial: 2, replySerial: 1, sig: "h", body: []any{uint32(0)}, fds: []int{-1}}
+	data, err := msg.marshal()
+	if err != nil {
+		t.Fatalf("marshal() failed: %v", err)
+	}
+
+	if _, err := unmarshalMessage(data, nil); err == nil {
+		t.Error("expected error when the descriptor was not received")
+	}
+
+	got, err := unmarshalMessage(data, []int{11, 12})
+	if err != nil {
+		t.Fatalf("unmarshalMessage() failed: %v", err)
+	}
+	if got.body[0] != 11 || len(got.fds) != 1 {
+		t.Errorf("body = %v, fds = %v, want descriptor 11", got.body, got.fds)
+	}
+}
+
+func TestSplitSignature(t *testing.T) {
+	tests := []struct {
+		sig     string
+		want    []string
+		wantErr bool
+	}{
+		{sig: "", want: nil},
+		{sig: "osa{sv}", want: []string{"o", "s", "a{sv}"}},
+		{sig: "ua(ua{sv})", want: []string{"u", "a(ua{sv})"}},
+		{sig: "(ii", wantErr: true},
+		{sig: "z", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := splitSignature(tt.sig)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitSignature(%q) error = %v, wantErr %v", tt.sig, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitSignature(%q) = %v, want %v", tt.sig, got, tt.want)
+		}
+	}
+}
+
+func TestSessionBusAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		runtime string
+		want    string
+		wantErr bool
+	}{
+		{name: "path", address: "unix:path=/run/user/1000/bus", want: "/run/user/1000/bus"},
+		{name: "abstract", address: "unix:abstract=/tmp/dbus-abc,guid=123", want: "@/tmp/dbus-abc"},
+		{name: "escaped", address: "unix:path=/tmp/my%20bus", want: "/tmp/my bus"},
+		{name: "fallback transport", address: "tcp:host=localhost;unix:path=/bus", want: "/bus"},
+		{name: "runtime dir", runtime: "/run/user/1000", want: "/run/user/1000/bus"},
+		{name: "unsupported", address: "tcp:host=localhost,port=1234", wantErr: true},
+		{name: "unset", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DBUS_SESSION_BUS_ADDRESS", tt.address)
+			t.Setenv("XDG_RUNTIME_DIR", tt.runtime)
+
+			got, err := sessionBusAddress()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sessionBusAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("sessionBusAddress() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
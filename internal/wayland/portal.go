@@ -0,0 +1,271 @@
+//go:build linux
+// +build linux
+
+// Package wayland captures the screen on Wayland compositors, which do not
+// let clients read the screen directly. The user picks a monitor through
+// the xdg-desktop-portal ScreenCast dialog and frames arrive over PipeWire.
+package wayland
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	portalBus       = "org.freedesktop.portal.Desktop"
+	portalPath      = objectPath("/org/freedesktop/portal/desktop")
+	screenCastIface = "org.freedesktop.portal.ScreenCast"
+	requestIface    = "org.freedesktop.portal.Request"
+	sessionIface    = "org.freedesktop.portal.Session"
+)
+
+// sourceMonitor selects whole monitors in SelectSources
+const sourceMonitor uint32 = 1
+
+// Cursor modes for SelectSources
+const (
+	cursorHidden   uint32 = 1
+	cursorEmbedded uint32 = 2
+)
+
+// ErrCancelled means the user dismissed the screen sharing dialog
+var ErrCancelled = errors.New("screen sharing was cancelled")
+
+// Stream is a PipeWire video stream shared through the portal
+type Stream struct {
+	// Node is the PipeWire node ID to read frames from
+	Node uint32
+
+	// X and Y are the monitor's position in the compositor's logical
+	// coordinate space, if the portal reports it
+	X, Y int
+
+	// Width and Height are the monitor's logical size
+	Width, Height int
+}
+
+// Session is an open screencast portal session
+type Session struct {
+	// Streams lists the monitors the user chose to share
+	Streams []Stream
+
+	bus    *conn
+	handle objectPath
+	remote *os.File // PipeWire connection restricted to Streams
+	tokens int
+}
+
+// OpenScreenCast asks the user to share a monitor. It blocks until the user
+// accepts or dismisses the portal dialog. cursor asks the compositor to draw
+// the pointer into frames; portals older than version 2 ignore it.
+func OpenScreenCast(cursor bool) (*Session, error) {
+	bus, err := dialSessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := openScreenCast(bus, cursor)
+	if err != nil {
+		bus.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// openScreenCast negotiates a session over an authenticated bus connection
+func openScreenCast(bus *conn, cursor bool) (*Session, error) {
+	s := &Session{bus: bus}
+
+	rule := "type='signal',interface='" + requestIface + "',member='Response'"
+	if _, err := bus.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", "s", rule); err != nil {
+		return nil, err
+	}
+
+	results, err := s.request("CreateSession", "a{sv}", map[string]variant{
+		"session_handle_token": {sig: "s", value: s.token()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	handle, ok := variantValue(results["session_handle"]).(string)
+	if !ok {
+		return nil, fmt.Errorf("screencast portal did not return a session handle")
+	}
+	s.handle = objectPath(handle)
+
+	sources := map[string]variant{
+		"types":    {sig: "u", value: sourceMonitor},
+		"multiple": {sig: "b", value: false},
+	}
+	if mode := s.cursorMode(cursor); mode != 0 {
+		sources["cursor_mode"] = variant{sig: "u", value: mode}
+	}
+	if _, err := s.request("SelectSources", "oa{sv}", s.handle, sources); err != nil {
+		s.closeSession()
+		return nil, err
+	}
+
+	results, err = s.request("Start", "osa{sv}", s.handle, "", map[string]variant{})
+	if err != nil {
+		s.closeSession()
+		return nil, err
+	}
+	s.Streams, err = parseStreams(variantValue(results["streams"]))
+	if err != nil {
+		s.closeSession()
+		return nil, err
+	}
+
+	reply, err := bus.call(portalBus, portalPath, screenCastIface, "OpenPipeWireRemote", "oa{sv}", s.handle, map[string]variant{})
+	if err != nil {
+		s.closeSession()
+		return nil, err
+	}
+	fd := -1
+	if len(reply.body) > 0 {
+		if v, ok := reply.body[0].(int); ok {
+			fd = v
+		}
+	}
+	if fd < 0 {
+		s.closeSession()
+		return nil, fmt.Errorf("screencast portal did not return a PipeWire connection")
+	}
+	s.remote = os.NewFile(uintptr(fd), "pipewire")
+
+	return s, nil
+}
+
+// cursorMode picks the SelectSources cursor mode, or 0 if the portal does
+// not offer the one wanted
+func (s *Session) cursorMode(cursor bool) uint32 {
+	reply, err := s.bus.call(portalBus, portalPath, "org.freedesktop.DBus.Properties", "Get", "ss",
+		screenCastIface, "AvailableCursorModes")
+	if err != nil || len(reply.body) == 0 {
+		return 0 // Version 1 portals have no cursor modes
+	}
+	available, _ := variantValue(reply.body[0]).(uint32)
+
+	mode := cursorHidden
+	if cursor {
+		mode = cursorEmbedded
+	}
+	if available&mode == 0 {
+		return 0
+	}
+	return mode
+}
+
+// Close ends the screencast session
+func (s *Session) Close() error {
+	s.closeSession()
+	if s.remote != nil {
+		s.remote.Close()
+	}
+	return s.bus.Close()
+}
+
+// closeSession asks the portal to stop sharing
+func (s *Session) closeSession() {
+	s.bus.call(portalBus, s.handle, sessionIface, "Close", "")
+}
+
+// token returns a new handle token, unique within this session
+func (s *Session) token() string {
+	s.tokens++
+	return "witness" + strconv.Itoa(s.tokens)
+}
+
+// request calls a portal method whose result arrives later through a
+// Request object's Response signal. The method's final argument must be
+// its options dictionary, which gains a handle_token.
+func (s *Session) request(method, sig string, args ...any) (map[string]any, error) {
+	options := args[len(args)-1].(map[string]variant)
+	token := s.token()
+	options["handle_token"] = variant{sig: "s", value: token}
+
+	reply, err := s.bus.call(portalBus, portalPath, screenCastIface, method, sig, args...)
+	if err != nil {
+		return nil, err
+	}
+	path := requestPath(s.bus.name, token)
+	if len(reply.body) > 0 {
+		if p, ok := reply.body[0].(objectPath); ok {
+			path = p
+		}
+	}
+
+	response, err := s.bus.waitSignal(path, requestIface, "Response")
+	if err != nil {
+		return nil, err
+	}
+	if len(response.body) != 2 {
+		return nil, fmt.Errorf("unexpected screencast portal response to %s", method)
+	}
+	code, _ := response.body[0].(uint32)
+	results, _ := response.body[1].(map[string]any)
+
+	switch code {
+	case 0:
+		return results, nil
+	case 1:
+		return nil, ErrCancelled
+	default:
+		return nil, fmt.Errorf("screencast portal %s failed", method)
+	}
+}
+
+// requestPath returns the object path the portal uses for a request with
+// the given handle token
+func requestPath(sender, token string) objectPath {
+	sender = strings.ReplaceAll(strings.TrimPrefix(sender, ":"), ".", "_")
+	return objectPath(string(portalPath) + "/request/" + sender + "/" + token)
+}
+
+// variantValue unwraps a variant, returning nil for anything else
+func variantValue(v any) any {
+	if x, ok := v.(variant); ok {
+		return x.value
+	}
+	return nil
+}
+
+// parseStreams decodes the a(ua{sv}) streams list from a Start response
+func parseStreams(v any) ([]Stream, error) {
+	items, _ := v.([]any)
+	var streams []Stream
+	for _, item := range items {
+		fields, ok := item.([]any)
+		if !ok || len(fields) != 2 {
+			continue
+		}
+		node, _ := fields[0].(uint32)
+		props, _ := fields[1].(map[string]any)
+
+		x, y, _ := intPair(props["position"])
+		width, height, sized := intPair(props["size"])
+		if !sized || width <= 0 || height <= 0 {
+			return nil, fmt.Errorf("screencast portal did not report the size of stream %d", node)
+		}
+		streams = append(streams, Stream{Node: node, X: x, Y: y, Width: width, Height: height})
+	}
+
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("screencast portal did not share any streams")
+	}
+	return streams, nil
+}
+
+// intPair decodes an (ii) variant
+func intPair(v any) (int, int, bool) {
+	fields, ok := variantValue(v).([]any)
+	if !ok || len(fields) != 2 {
+		return 0, 0, false
+	}
+	a, ok1 := fields[0].(int32)
+	b, ok2 := fields[1].(int32)
+	return int(a), int(b), ok1 && ok2
+}
@@ -0,0 +1,237 @@
+//go:build linux
+// +build linux
+
+package wayland
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// fakePortal answers screencast portal calls on the server end of a socket
+type fakePortal struct {
+	bus    *conn
+	cancel string // Method whose request the user cancels
+	mu     sync.Mutex
+	calls  []string
+	cursor any // cursor_mode passed to SelectSources
+}
+
+// Helper function to connect a client to a fake portal over a socketpair
+func dialFakePortal(t *testing.T, cancel string) (*conn, *fakePortal) {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("Socketpair() failed: %v", err)
+	}
+
+	unixConn := func(fd int) *net.UnixConn {
+		f := os.NewFile(uintptr(fd), "bus")
+		defer f.Close()
+		c, err := net.FileConn(f)
+		if err != nil {
+			t.Fatalf("FileConn() failed: %v", err)
+		}
+		return c.(*net.UnixConn)
+	}
+
+	portal := &fakePortal{bus: &conn{c: unixConn(fds[1])}, cancel: cancel}
+	go portal.serve()
+
+	client, err := newConn(unixConn(fds[0]))
+	if err != nil {
+		t.Fatalf("newConn() failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, portal
+}
+
+// serve authenticates the client and answers its calls until it hangs up
+func (p *fakePortal) serve() {
+	defer p.bus.Close()
+
+	for _, reply := range []string{"OK 0123456789abcdef", "AGREE_UNIX_FD", ""} {
+		if _, err := p.bus.readLine(); err != nil {
+			return
+		}
+		if reply != "" {
+			p.bus.c.Write([]byte(reply + "\r\n"))
+		}
+	}
+
+	for {
+		call, err := p.bus.read()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		p.calls = append(p.calls, call.member)
+		p.mu.Unlock()
+		p.answer(call)
+	}
+}
+
+// answer replies to one method call
+func (p *fakePortal) answer(call *message) {
+	reply := &message{typ: msgMethodReturn, serial: call.serial + 1000, replySerial: call.serial}
+
+	switch call.member {
+	case "Hello":
+		reply.sig, reply.body = "s", []any{":1.42"}
+	case "Get":
+		reply.sig, reply.body = "v", []any{variant{sig: "u", value: cursorHidden | cursorEmbedded}}
+	case "CreateSession", "SelectSources", "Start":
+		options := call.body[len(call.body)-1].(map[string]any)
+		token := variantValue(options["handle_token"]).(string)
+		if call.member == "SelectSources" {
+			p.mu.Lock()
+			p.cursor = variantValue(options["cursor_mode"])
+			p.mu.Unlock()
+		}
+		path := requestPath(":1.42", token)
+		reply.sig, reply.body = "o", []any{path}
+		p.send(reply, nil)
+
+		code, results := uint32(0), map[string]variant{}
+		switch {
+		case call.member == p.cancel:
+			code = 1
+		case call.member == "CreateSession":
+			results["session_handle"] = variant{sig: "s", value: "/org/freedesktop/portal/desktop/session/1_42/witness1"}
+		case call.member == "Start":
+			results["streams"] = variant{sig: "a(ua{sv})", value: []any{[]any{uint32(57), map[string]variant{
+				"position": {sig: "(ii)", value: []any{int32(1920), int32(0)}},
+				"size":     {sig: "(ii)", value: []any{int32(1280), int32(720)}},
+			}}}}
+		}
+		p.send(&message{
+			typ:    msgSignal,
+			serial: call.serial + 2000,
+			path:   path,
+			iface:  requestIface,
+			member: "Response",
+			sig:    "ua{sv}",
+			body:   []any{code, results},
+		}, nil)
+		return
+	case "OpenPipeWireRemote":
+		r, w, _ := os.Pipe()
+		defer r.Close()
+		defer w.Close()
+		reply.sig, reply.body, reply.fds = "h", []any{uint32(0)}, []int{int(r.Fd())}
+		p.send(reply, syscall.UnixRights(int(r.Fd())))
+		return
+	}
+
+	p.send(reply, nil)
+}
+
+// send writes a message with optional ancillary data
+func (p *fakePortal) send(msg *message, oob []byte) {
+	data, err := msg.marshal()
+	if err != nil {
+		panic(err)
+	}
+	p.bus.c.WriteMsgUnix(data, oob, nil)
+}
+
+func TestOpenScreenCast(t *testing.T) {
+	bus, portal := dialFakePortal(t, "")
+	if bus.name != ":1.42" {
+		t.Errorf("bus name = %q, want :1.42", bus.name)
+	}
+
+	session, err := openScreenCast(bus, true)
+	if err != nil {
+		t.Fatalf("openScreenCast() failed: %v", err)
+	}
+	defer session.remote.Close()
+
+	want := Stream{Node: 57, X: 1920, Y: 0, Width: 1280, Height: 720}
+	if len(session.Streams) != 1 || session.Streams[0] != want {
+		t.Errorf("Streams = %+v, want [%+v]", session.Streams, want)
+	}
+	if session.handle != "/org/freedesktop/portal/desktop/session/1_42/witness1" {
+		t.Errorf("handle = %q", session.handle)
+	}
+	if session.remote == nil {
+		t.Fatal("expected a PipeWire connection")
+	}
+
+	portal.mu.Lock()
+	defer portal.mu.Unlock()
+	if portal.cursor != cursorEmbedded {
+		t.Errorf("cursor_mode = %v, want %d", portal.cursor, cursorEmbedded)
+	}
+	wantCalls := []string{"Hello", "AddMatch", "CreateSession", "Get", "SelectSources", "Start", "OpenPipeWireRemote"}
+	if len(portal.calls) != len(wantCalls) {
+		t.Fatalf("calls = %v, want %v", portal.calls, wantCalls)
+	}
+	for i := range wantCalls {
+		if portal.calls[i] != wantCalls[i] {
+			t.Errorf("calls = %v, want %v", portal.calls, wantCalls)
+			break
+		}
+	}
+}
+
+func TestOpenScreenCastCancelled(t *testing.T) {
+	bus, _ := dialFakePortal(t, "SelectSources")
+
+	if _, err := openScreenCast(bus, false); !errors.Is(err, ErrCancelled) {
+		t.Errorf("openScreenCast() error = %v, want %v", err, ErrCancelled)
+	}
+}
+
+func TestRequestPath(t *testing.T) {
+	got := requestPath(":1.42", "witness3")
+	want := objectPath("/org/freedesktop/portal/desktop/request/1_42/witness3")
+	if got != want {
+		t.Errorf("requestPath() = %q, want %q", got, want)
+	}
+}
+
+func TestParseStreams(t *testing.T) {
+	size := func(w, h int32) variant {
+		return variant{sig: "(ii)", value: []any{w, h}}
+	}
+
+	tests := []struct {
+		name    string
+		streams any
+		want    []Stream
+		wantErr bool
+	}{
+		{
+			name:    "without position",
+			streams: []any{[]any{uint32(3), map[string]any{"size": size(800, 600)}}},
+			want:    []Stream{{Node: 3, Width: 800, Height: 600}},
+		},
+		{
+			name:    "missing size",
+			streams: []any{[]any{uint32(3), map[string]any{}}},
+			wantErr: true,
+		},
+		{
+			name:    "no streams",
+			streams: []any{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStreams(tt.streams)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStreams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (len(got) != 1 || got[0] != tt.want[0]) {
+				t.Errorf("parseStreams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
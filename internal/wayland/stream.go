@@ -0,0 +1,103 @@
+//go:build linux
+// +build linux
+
+package wayland
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// DefaultGStreamer is the GStreamer launcher used to read PipeWire streams
+const DefaultGStreamer = "gst-launch-1.0"
+
+// FrameReader reads RGBA frames from a shared stream
+type FrameReader struct {
+	Width  int
+	Height int
+
+	r      io.Reader
+	cmd    *exec.Cmd
+	stderr *strings.Builder
+	close  sync.Once
+}
+
+// Record starts reading frames from stream at num/den frames per second.
+// Frames are scaled to the stream's logical size, and repeated when the
+// screen is idle so they arrive at a steady rate.
+func (s *Session) Record(stream Stream, num, den int) (*FrameReader, error) {
+	gst, err := exec.LookPath(DefaultGStreamer)
+	if err != nil {
+		return nil, fmt.Errorf("GStreamer with the PipeWire plugin is required for Wayland capture (install gstreamer1.0-pipewire): %w", err)
+	}
+
+	cmd := exec.Command(gst, gstArgs(stream, num, den)...)
+	cmd.ExtraFiles = []*os.File{s.remote} // fd 3 in the child
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start GStreamer: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start GStreamer: %w", err)
+	}
+
+	return &FrameReader{
+		Width:  stream.Width,
+		Height: stream.Height,
+		r:      out,
+		cmd:    cmd,
+		stderr: &stderr,
+	}, nil
+}
+
+// gstArgs returns the pipeline that converts a PipeWire node to raw RGBA
+// frames on stdout
+func gstArgs(stream Stream, num, den int) []string {
+	caps := fmt.Sprintf("video/x-raw,format=RGBA,width=%d,height=%d,framerate=%d/%d",
+		stream.Width, stream.Height, num, den)
+	return []string{
+		"-q",
+		"pipewiresrc", "fd=3", fmt.Sprintf("path=%d", stream.Node), "always-copy=true",
+		"!", "videoconvert",
+		"!", "videoscale",
+		"!", "videorate",
+		"!", caps,
+		"!", "fdsink", "fd=1",
+	}
+}
+
+// ReadFrame reads the next frame as tightly packed RGBA rows
+func (r *FrameReader) ReadFrame() ([]byte, error) {
+	frame := make([]byte, 4*r.Width*r.Height)
+	if _, err := io.ReadFull(r.r, frame); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("stream ended mid-frame: %w", err)
+		}
+		r.Close()
+		if r.stderr != nil {
+			if msg := strings.TrimSpace(r.stderr.String()); msg != "" {
+				err = fmt.Errorf("%w: %s", err, msg)
+			}
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+// Close stops reading frames
+func (r *FrameReader) Close() error {
+	r.close.Do(func() {
+		if r.cmd != nil && r.cmd.Process != nil {
+			r.cmd.Process.Kill()
+			r.cmd.Wait()
+		}
+	})
+	return nil
+}
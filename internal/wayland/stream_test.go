@@ -0,0 +1,52 @@
+//go:build linux
+// +build linux
+
+package wayland
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestGstArgs(t *testing.T) {
+	args := strings.Join(gstArgs(Stream{Node: 57, Width: 1280, Height: 720}, 30000, 1001), " ")
+
+	for _, want := range []string{
+		"pipewiresrc fd=3 path=57",
+		"video/x-raw,format=RGBA,width=1280,height=720,framerate=30000/1001",
+		"fdsink fd=1",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args %q missing %q", args, want)
+		}
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	data := make([]byte, 2*4*3*2+5) // Two 3x2 frames and part of a third
+	for i := range data {
+		data[i] = byte(i)
+	}
+	r := &FrameReader{Width: 3, Height: 2, r: bytes.NewReader(data)}
+
+	for i := 0; i < 2; i++ {
+		frame, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame() %d failed: %v", i, err)
+		}
+		if !bytes.Equal(frame, data[i*24:(i+1)*24]) {
+			t.Errorf("frame %d = %v, want %v", i, frame, data[i*24:(i+1)*24])
+		}
+	}
+
+	if _, err := r.ReadFrame(); err == nil || !strings.Contains(err.Error(), "mid-frame") {
+		t.Errorf("ReadFrame() error = %v, want a mid-frame error", err)
+	}
+
+	r = &FrameReader{Width: 3, Height: 2, r: bytes.NewReader(nil)}
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("ReadFrame() at end of stream error = %v, want %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,160 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import (
+	"fmt"
+	"image"
+)
+
+// grabDistance is how close, in pixels, the mouse must be to an edge of the
+// selection to drag that edge
+const grabDistance = 6
+
+// nudgeStep and nudgeStepLarge are how far an arrow key moves the
+// selection, without and with Ctrl held
+const (
+	nudgeStep      = 1
+	nudgeStepLarge = 10
+)
+
+// edge is a set of selection edges moved together by a drag or key press
+type edge int
+
+const (
+	edgeLeft edge = 1 << iota
+	edgeTop
+	edgeRight
+	edgeBottom
+
+	// edgeAll moves the whole selection
+	edgeAll = edgeLeft | edgeTop | edgeRight | edgeBottom
+)
+
+// AdjustRegion shows the overlay with initial, a rectangle in screen
+// coordinates, already selected. Dragging an edge or corner moves it,
+// dragging inside moves the whole selection, and dragging outside draws a
+// new one. Arrow keys nudge the selection as described for SelectRegion.
+// Enter accepts the selection; Escape or a right-click returns
+// ErrCancelled.
+func AdjustRegion(initial image.Rectangle) (image.Rectangle, error) {
+	if initial.Empty() {
+		return image.Rectangle{}, fmt.Errorf("cannot adjust an empty region")
+	}
+	return show(initial)
+}
+
+// hitTest returns the edges of r a drag starting at p moves: the edges
+// within grabDistance, edgeAll inside r, and none outside it
+func hitTest(r rect, p point) edge {
+	if p.X < r.Left-grabDistance || p.X > r.Right+grabDistance ||
+		p.Y < r.Top-grabDistance || p.Y > r.Bottom+grabDistance {
+		return 0
+	}
+
+	var e edge
+	if abs(p.X-r.Left) <= grabDistance {
+		e |= edgeLeft
+	} else if abs(p.X-r.Right) <= grabDistance {
+		e |= edgeRight
+	}
+	if abs(p.Y-r.Top) <= grabDistance {
+		e |= edgeTop
+	} else if abs(p.Y-r.Bottom) <= grabDistance {
+		e |= edgeBottom
+	}
+	if e == 0 {
+		return edgeAll
+	}
+	return e
+}
+
+// moveEdges moves edges e of r by dx and dy
+func moveEdges(r rect, e edge, dx, dy int32) rect {
+	if e&edgeLeft != 0 {
+		r.Left += dx
+	}
+	if e&edgeRight != 0 {
+		r.Right += dx
+	}
+	if e&edgeTop != 0 {
+		r.Top += dy
+	}
+	if e&edgeBottom != 0 {
+		r.Bottom += dy
+	}
+	return r
+}
+
+// nudgeRect moves r step pixels in the direction of an arrow key, or with
+// resize, moves its right or bottom edge. The selection never shrinks below
+// a pixel.
+func nudgeRect(r rect, key uintptr, resize bool, step int32) rect {
+	var dx, dy int32
+	switch key {
+	case vkLeft:
+		dx = -step
+	case vkRight:
+		dx = step
+	case vkUp:
+		dy = -step
+	case vkDown:
+		dy = step
+	default:
+		return r
+	}
+
+	if !resize {
+		return moveEdges(r, edgeAll, dx, dy)
+	}
+	moved := moveEdges(r, edgeRight|edgeBottom, dx, dy)
+	if moved.Right <= moved.Left || moved.Bottom <= moved.Top {
+		return r
+	}
+	return moved
+}
+
+// nudge applies an arrow key press to the selection, reading Shift and
+// Ctrl to choose between moving and resizing and the step size
+func (o *overlay) nudge(key uintptr) {
+	step := int32(nudgeStep)
+	if keyDown(vkControl) {
+		step = nudgeStepLarge
+	}
+	o.setRect(nudgeRect(dragRect(o.start, o.end), key, keyDown(vkShift), step))
+}
+
+// keyDown reports whether key is held. The high bit of GetKeyState's
+// result is set while the key is down.
+func keyDown(key uintptr) bool {
+	state, _, _ := procGetKeyState.Call(key)
+	return state&0x8000 != 0
+}
+
+// setRect makes r the selection
+func (o *overlay) setRect(r rect) {
+	o.start = point{X: r.Left, Y: r.Top}
+	o.end = point{X: r.Right, Y: r.Bottom}
+}
+
+// startAdjustDrag picks what a drag starting at p changes while adjusting
+func (o *overlay) startAdjustDrag(p point) {
+	r := dragRect(o.start, o.end)
+	o.grab = hitTest(r, p)
+	if o.grab == 0 {
+		// Draw a new selection from p
+		r = rect{Left: p.X, Top: p.Y, Right: p.X, Bottom: p.Y}
+		o.grab = edgeRight | edgeBottom
+	}
+	o.grabAt = p
+	o.grabRect = r
+}
+
+// abs returns the absolute value of n
+func abs(n int32) int32 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
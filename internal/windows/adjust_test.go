@@ -0,0 +1,106 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import "testing"
+
+func TestHitTest(t *testing.T) {
+	r := rect{Left: 100, Top: 100, Right: 300, Bottom: 200}
+
+	tests := []struct {
+		name string
+		p    point
+		want edge
+	}{
+		{name: "left edge", p: point{X: 103, Y: 150}, want: edgeLeft},
+		{name: "right edge just outside", p: point{X: 305, Y: 150}, want: edgeRight},
+		{name: "top edge", p: point{X: 200, Y: 98}, want: edgeTop},
+		{name: "bottom edge", p: point{X: 200, Y: 200}, want: edgeBottom},
+		{name: "top left corner", p: point{X: 101, Y: 99}, want: edgeLeft | edgeTop},
+		{name: "bottom right corner", p: point{X: 302, Y: 204}, want: edgeRight | edgeBottom},
+		{name: "inside", p: point{X: 200, Y: 150}, want: edgeAll},
+		{name: "outside", p: point{X: 50, Y: 150}, want: 0},
+		{name: "beside an edge but past the corner", p: point{X: 103, Y: 250}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hitTest(r, tt.p); got != tt.want {
+				t.Errorf("hitTest(%+v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveEdges(t *testing.T) {
+	r := rect{Left: 100, Top: 100, Right: 300, Bottom: 200}
+
+	tests := []struct {
+		name   string
+		e      edge
+		dx, dy int32
+		want   rect
+	}{
+		{name: "left", e: edgeLeft, dx: -10, dy: 5, want: rect{Left: 90, Top: 100, Right: 300, Bottom: 200}},
+		{name: "corner", e: edgeRight | edgeBottom, dx: 4, dy: -3, want: rect{Left: 100, Top: 100, Right: 304, Bottom: 197}},
+		{name: "move", e: edgeAll, dx: -100, dy: 20, want: rect{Left: 0, Top: 120, Right: 200, Bottom: 220}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := moveEdges(r, tt.e, tt.dx, tt.dy); got != tt.want {
+				t.Errorf("moveEdges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNudgeRect(t *testing.T) {
+	r := rect{Left: 10, Top: 10, Right: 11, Bottom: 20}
+
+	tests := []struct {
+		name   string
+		key    uintptr
+		resize bool
+		step   int32
+		want   rect
+	}{
+		{name: "move left", key: vkLeft, step: 1, want: rect{Left: 9, Top: 10, Right: 10, Bottom: 20}},
+		{name: "move down", key: vkDown, step: 1, want: rect{Left: 10, Top: 11, Right: 11, Bottom: 21}},
+		{name: "move right by 10", key: vkRight, step: 10, want: rect{Left: 20, Top: 10, Right: 21, Bottom: 20}},
+		{name: "grow right", key: vkRight, resize: true, step: 1, want: rect{Left: 10, Top: 10, Right: 12, Bottom: 20}},
+		{name: "shrink up", key: vkUp, resize: true, step: 1, want: rect{Left: 10, Top: 10, Right: 11, Bottom: 19}},
+		{name: "grow down by 10", key: vkDown, resize: true, step: 10, want: rect{Left: 10, Top: 10, Right: 11, Bottom: 30}},
+		{name: "never below a pixel", key: vkLeft, resize: true, step: 1, want: r},
+		{name: "shrink by 10 past the edge", key: vkUp, resize: true, step: 10, want: r},
+		{name: "other key", key: vkReturn, step: 1, want: r},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nudgeRect(r, tt.key, tt.resize, tt.step); got != tt.want {
+				t.Errorf("nudgeRect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartAdjustDrag(t *testing.T) {
+	o := &overlay{adjusting: true}
+	o.setRect(rect{Left: 100, Top: 100, Right: 300, Bottom: 200})
+
+	// Dragging outside the selection starts a new one
+	o.startAdjustDrag(point{X: 500, Y: 400})
+	o.setRect(moveEdges(o.grabRect, o.grab, 20, 10))
+	if got, want := dragRect(o.start, o.end), (rect{Left: 500, Top: 400, Right: 520, Bottom: 410}); got != want {
+		t.Errorf("new selection = %+v, want %+v", got, want)
+	}
+
+	// Dragging the left edge past the right one flips the selection
+	o.startAdjustDrag(point{X: 500, Y: 405})
+	o.setRect(moveEdges(o.grabRect, o.grab, 30, 0))
+	if got, want := dragRect(o.start, o.end), (rect{Left: 520, Top: 400, Right: 530, Bottom: 410}); got != want {
+		t.Errorf("flipped selection = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// The loupe magnifies the pixels around the cursor so selection edges can
+// be lined up exactly with window borders
+const (
+	loupeRadius = 10 // Pixels shown on each side of the cursor
+	loupeZoom   = 6  // Screen pixels are drawn this many times larger
+	loupeOffset = 24 // Gap between the cursor and the loupe
+
+	loupeLabelHeight = 20
+	loupeSize        = (2*loupeRadius + 1) * loupeZoom
+	loupeWidth       = loupeSize
+	loupeHeight      = loupeSize + loupeLabelHeight
+)
+
+// loupePosition returns the loupe window's top-left corner for a cursor at
+// p in a window of the given size. The loupe sits below and to the right
+// of the cursor, flipping sides near the right and bottom edges.
+func loupePosition(p, size point) point {
+	pos := point{X: p.X + loupeOffset, Y: p.Y + loupeOffset}
+	if pos.X+loupeWidth > size.X {
+		pos.X = p.X - loupeOffset - loupeWidth
+	}
+	if pos.Y+loupeHeight > size.Y {
+		pos.Y = p.Y - loupeOffset - loupeHeight
+	}
+	return pos
+}
+
+// loupeSource returns the screen pixels shown in the loupe for a cursor
+// at p in screen coordinates
+func loupeSource(p point) rect {
+	return rect{
+		Left:   p.X - loupeRadius,
+		Top:    p.Y - loupeRadius,
+		Right:  p.X + loupeRadius + 1,
+		Bottom: p.Y + loupeRadius + 1,
+	}
+}
+
+// loupeLabel returns the loupe's caption: the cursor's screen coordinates,
+// and the selection's size while dragging or adjusting
+func (o *overlay) loupeLabel() string {
+	p := o.screenPoint(o.cursor)
+	if !o.dragging && !o.adjusting {
+		return fmt.Sprintf("%d, %d", p.X, p.Y)
+	}
+	r := dragRect(o.start, o.end)
+	return fmt.Sprintf("%d, %d  %dx%d", p.X, p.Y, r.Right-r.Left, r.Bottom-r.Top)
+}
+
+// screenPoint converts a point in window coordinates to screen coordinates
+func (o *overlay) screenPoint(p point) point {
+	return point{X: p.X + o.origin.X, Y: p.Y + o.origin.Y}
+}
+
+// moveLoupe shows the loupe next to the cursor and redraws it
+func (o *overlay) moveLoupe() {
+	if o.loupe == 0 {
+		return
+	}
+	pos := o.screenPoint(loupePosition(o.cursor, o.size))
+	procSetWindowPos.Call(o.loupe, hwndTopmost, uintptr(pos.X), uintptr(pos.Y), 0, 0,
+		swpNoSize|swpNoActivate|swpShowWindow)
+	procInvalidateRect.Call(o.loupe, 0, 0)
+}
+
+// paintLoupe draws the magnified pixels around the cursor, outlines the
+// pixel under it, and writes the coordinates beneath. It reads the screen
+// directly, which leaves out the layered overlay windows, so the loupe
+// shows the screen undimmed.
+func (o *overlay) paintLoupe(hwnd uintptr) {
+	var ps paintStruct
+	hdc, _, _ := procBeginPaint.Call(hwnd, ptr(&ps))
+	defer procEndPaint.Call(hwnd, ptr(&ps))
+
+	screen, _, _ := procGetDC.Call(0)
+	if screen != 0 {
+		src := loupeSource(o.screenPoint(o.cursor))
+		procSetStretchBltMode.Call(hdc, colorOnColor)
+		procStretchBlt.Call(hdc, 0, 0, loupeSize, loupeSize,
+			screen, uintptr(src.Left), uintptr(src.Top), uintptr(src.Right-src.Left), uintptr(src.Bottom-src.Top),
+			srcCopy)
+		procReleaseDC.Call(0, screen)
+	}
+
+	center := rect{
+		Left:   loupeRadius * loupeZoom,
+		Top:    loupeRadius * loupeZoom,
+		Right:  (loupeRadius + 1) * loupeZoom,
+		Bottom: (loupeRadius + 1) * loupeZoom,
+	}
+	procFrameRect.Call(hdc, ptr(&center), o.border)
+
+	label := rect{Top: loupeSize, Right: loupeWidth, Bottom: loupeHeight}
+	procFillRect.Call(hdc, ptr(&label), o.dim)
+	text, err := syscall.UTF16FromString(o.loupeLabel())
+	if err != nil {
+		return
+	}
+	procSetBkMode.Call(hdc, bkTransparent)
+	procSetTextColor.Call(hdc, borderColor)
+	procTextOutW.Call(hdc, 4, loupeSize+2, ptr(&text[0]), uintptr(len(text)-1))
+}
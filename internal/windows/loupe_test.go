@@ -0,0 +1,80 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import "testing"
+
+func TestLoupePosition(t *testing.T) {
+	size := point{X: 1920, Y: 1080}
+
+	tests := []struct {
+		name   string
+		cursor point
+		want   point
+	}{
+		{
+			name:   "below right",
+			cursor: point{X: 100, Y: 100},
+			want:   point{X: 100 + loupeOffset, Y: 100 + loupeOffset},
+		},
+		{
+			name:   "flips left near the right edge",
+			cursor: point{X: 1900, Y: 100},
+			want:   point{X: 1900 - loupeOffset - loupeWidth, Y: 100 + loupeOffset},
+		},
+		{
+			name:   "flips up near the bottom edge",
+			cursor: point{X: 100, Y: 1070},
+			want:   point{X: 100 + loupeOffset, Y: 1070 - loupeOffset - loupeHeight},
+		},
+		{
+			name:   "flips both in the corner",
+			cursor: point{X: 1919, Y: 1079},
+			want:   point{X: 1919 - loupeOffset - loupeWidth, Y: 1079 - loupeOffset - loupeHeight},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loupePosition(tt.cursor, size); got != tt.want {
+				t.Errorf("loupePosition(%+v) = %+v, want %+v", tt.cursor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoupeSource(t *testing.T) {
+	got := loupeSource(point{X: -1900, Y: 5})
+	want := rect{
+		Left:   -1900 - loupeRadius,
+		Top:    5 - loupeRadius,
+		Right:  -1900 + loupeRadius + 1,
+		Bottom: 5 + loupeRadius + 1,
+	}
+	if got != want {
+		t.Errorf("loupeSource() = %+v, want %+v", got, want)
+	}
+
+	// The source is magnified to exactly fill the loupe
+	if w := (got.Right - got.Left) * loupeZoom; w != loupeSize {
+		t.Errorf("magnified width = %d, want %d", w, loupeSize)
+	}
+}
+
+func TestLoupeLabel(t *testing.T) {
+	o := &overlay{
+		origin: point{X: -1920, Y: 0},
+		cursor: point{X: 500, Y: 400},
+	}
+	if got, want := o.loupeLabel(), "-1420, 400"; got != want {
+		t.Errorf("loupeLabel() = %q, want %q", got, want)
+	}
+
+	o.dragging = true
+	o.start = point{X: 100, Y: 100}
+	o.end = o.cursor
+	if got, want := o.loupeLabel(), "-1420, 400  400x300"; got != want {
+		t.Errorf("loupeLabel() while dragging = %q, want %q", got, want)
+	}
+}
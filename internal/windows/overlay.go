@@ -0,0 +1,318 @@
+//go:build windows
+// +build windows
+
+// Package windows provides a native region selection overlay for Windows,
+// which has no command-line tool for picking an area of the screen.
+package windows
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// overlayAlpha dims the screen outside the selection (0-255)
+const overlayAlpha = 110
+
+// ErrCancelled means the user dismissed the overlay without selecting
+var ErrCancelled = errors.New("selection was cancelled")
+
+// Colors used to paint the overlay. Pixels in holeColor are fully
+// transparent, so the selected area shows the screen undimmed.
+var (
+	dimColor    = rgb(0, 0, 0)
+	holeColor   = rgb(255, 0, 255)
+	borderColor = rgb(255, 255, 255)
+)
+
+// overlay tracks a drag across the selection window
+type overlay struct {
+	origin     point // Top-left corner of the virtual screen
+	size       point // Size of the virtual screen
+	start, end point // Drag corners in window coordinates
+	cursor     point // Mouse position in window coordinates
+	dragging   bool
+	done       bool
+
+	// While adjusting, the selection stays shown between drags and can be
+	// nudged with the arrow keys until Enter accepts it. A drag moves the
+	// grab edges of grabRect by the distance from grabAt.
+	adjusting bool
+	grab      edge
+	grabAt    point
+	grabRect  rect
+
+	loupe uintptr // Magnifier window; 0 if it could not be created
+
+	dim, hole, border uintptr // Brushes
+}
+
+var (
+	// active is the overlay receiving window messages. Only one overlay is
+	// shown at a time.
+	active *overlay
+
+	classOnce sync.Once
+	classErr  error
+	className *uint16
+)
+
+// SelectRegion covers every monitor with a dimmed window and returns the
+// screen rectangle the user drags out, in physical pixels. The selection
+// can then be corrected before Enter accepts it: dragging its edges as in
+// AdjustRegion, or with the arrow keys, which move it a pixel, or with
+// Shift move its right or bottom edge to resize it; holding Ctrl makes
+// each step 10 pixels. It returns ErrCancelled if the user presses Escape
+// or right-clicks.
+func SelectRegion() (image.Rectangle, error) {
+	return show(image.Rectangle{})
+}
+
+// show runs the overlay until a selection is made or cancelled. A
+// non-empty initial rectangle is shown selected for adjusting.
+func show(initial image.Rectangle) (image.Rectangle, error) {
+	// Window messages are delivered to the thread that created the window
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Report physical pixels, matching capture coordinates on scaled displays
+	procSetProcessDPIAware.Call()
+
+	classOnce.Do(registerClass)
+	if classErr != nil {
+		return image.Rectangle{}, classErr
+	}
+
+	o := &overlay{
+		origin: point{X: systemMetric(smXVirtualScreen), Y: systemMetric(smYVirtualScreen)},
+		size:   point{X: systemMetric(smCXVirtualScreen), Y: systemMetric(smCYVirtualScreen)},
+	}
+	if !initial.Empty() {
+		o.adjusting = true
+		o.setRect(rect{
+			Left:   int32(initial.Min.X) - o.origin.X,
+			Top:    int32(initial.Min.Y) - o.origin.Y,
+			Right:  int32(initial.Max.X) - o.origin.X,
+			Bottom: int32(initial.Max.Y) - o.origin.Y,
+		})
+	}
+	for _, b := range []struct {
+		brush *uintptr
+		color uintptr
+	}{{&o.dim, dimColor}, {&o.hole, holeColor}, {&o.border, borderColor}} {
+		*b.brush, _, _ = procCreateSolidBrush.Call(b.color)
+		defer procDeleteObject.Call(*b.brush)
+	}
+
+	active = o
+	defer func() { active = nil }()
+
+	hwnd, _, err := procCreateWindowExW.Call(
+		wsExTopmost|wsExToolWin|wsExLayered,
+		ptr(className),
+		0,
+		wsPopup|wsVisible,
+		uintptr(o.origin.X), uintptr(o.origin.Y), uintptr(o.size.X), uintptr(o.size.Y),
+		0, 0, 0, 0,
+	)
+	if hwnd == 0 {
+		return image.Rectangle{}, fmt.Errorf("failed to create selection window: %w", err)
+	}
+	procSetLayeredWindowAttributes.Call(hwnd, holeColor, overlayAlpha, lwaColorKey|lwaAlpha)
+	procSetForegroundWindow.Call(hwnd)
+
+	// The loupe is opaque but lets clicks through to the overlay. Selection
+	// still works without it.
+	o.loupe, _, _ = procCreateWindowExW.Call(
+		wsExTopmost|wsExToolWin|wsExLayered|wsExClickThru|wsExNoActive,
+		ptr(className),
+		0,
+		wsPopup,
+		0, 0, loupeWidth, loupeHeight,
+		0, 0, 0, 0,
+	)
+	if o.loupe != 0 {
+		procSetLayeredWindowAttributes.Call(o.loupe, 0, 255, lwaAlpha)
+		defer procDestroyWindow.Call(o.loupe)
+	}
+
+	var m msg
+	for {
+		r, _, err := procGetMessageW.Call(ptr(&m), 0, 0, 0)
+		if int32(r) == -1 {
+			return image.Rectangle{}, fmt.Errorf("failed to read window messages: %w", err)
+		}
+		if r == 0 {
+			break
+		}
+		procTranslateMessage.Call(ptr(&m))
+		procDispatchMessageW.Call(ptr(&m))
+	}
+
+	if !o.done {
+		return image.Rectangle{}, ErrCancelled
+	}
+	selection := o.selection()
+	if selection.Empty() {
+		return image.Rectangle{}, fmt.Errorf("no region selected")
+	}
+	return selection, nil
+}
+
+// registerClass registers the overlay's window class
+func registerClass() {
+	name, err := syscall.UTF16PtrFromString("WitnessRegionSelector")
+	if err != nil {
+		classErr = err
+		return
+	}
+	instance, _, _ := procGetModuleHandleW.Call(0)
+	cursor, _, _ := procLoadCursorW.Call(0, idcCross)
+
+	class := wndClassEx{
+		WndProc:   syscall.NewCallback(windowProc),
+		Instance:  instance,
+		Cursor:    cursor,
+		ClassName: name,
+	}
+	class.Size = uint32(unsafe.Sizeof(class))
+	if r, _, err := procRegisterClassExW.Call(ptr(&class)); r == 0 {
+		classErr = fmt.Errorf("failed to register selection window: %w", err)
+		return
+	}
+	className = name
+}
+
+// windowProc handles messages for the overlay window
+func windowProc(hwnd, message, wParam, lParam uintptr) uintptr {
+	o := active
+	if o == nil {
+		r, _, _ := procDefWindowProcW.Call(hwnd, message, wParam, lParam)
+		return r
+	}
+
+	if hwnd == o.loupe {
+		switch uint32(message) {
+		case wmEraseBkgnd:
+			return 1
+		case wmPaint:
+			o.paintLoupe(hwnd)
+			return 0
+		}
+		r, _, _ := procDefWindowProcW.Call(hwnd, message, wParam, lParam)
+		return r
+	}
+
+	switch uint32(message) {
+	case wmLButtonDown:
+		o.cursor = pointFromLParam(lParam)
+		if o.adjusting {
+			o.startAdjustDrag(o.cursor)
+		} else {
+			o.start = o.cursor
+			o.end = o.cursor
+		}
+		o.dragging = true
+		procSetCapture.Call(hwnd)
+		return 0
+	case wmMouseMove:
+		o.cursor = pointFromLParam(lParam)
+		if o.dragging {
+			if o.adjusting {
+				o.setRect(moveEdges(o.grabRect, o.grab, o.cursor.X-o.grabAt.X, o.cursor.Y-o.grabAt.Y))
+			} else {
+				o.end = o.cursor
+			}
+			procInvalidateRect.Call(hwnd, 0, 0)
+		}
+		o.moveLoupe()
+		return 0
+	case wmLButtonUp:
+		if o.dragging {
+			o.dragging = false
+			procReleaseCapture.Call()
+			if !o.adjusting {
+				// Keep the new selection pending so it can be corrected
+				// before Enter; a click without a drag selects nothing
+				o.end = pointFromLParam(lParam)
+				o.adjusting = !o.selection().Empty()
+				procInvalidateRect.Call(hwnd, 0, 0)
+			}
+		}
+		return 0
+	case wmRButtonDown:
+		procDestroyWindow.Call(hwnd)
+		return 0
+	case wmKeyDown:
+		switch {
+		case wParam == vkEscape:
+			procDestroyWindow.Call(hwnd)
+		case wParam == vkReturn && o.adjusting && !o.dragging:
+			o.done = true
+			procDestroyWindow.Call(hwnd)
+		case o.adjusting && !o.dragging:
+			o.nudge(wParam)
+			procInvalidateRect.Call(hwnd, 0, 0)
+			if o.loupe != 0 {
+				procInvalidateRect.Call(o.loupe, 0, 0)
+			}
+		}
+		return 0
+	case wmEraseBkgnd:
+		// Painting covers the whole window, so skip the erase to avoid flicker
+		return 1
+	case wmPaint:
+		o.paint(hwnd)
+		return 0
+	case wmDestroy:
+		procPostQuitMessage.Call(0)
+		return 0
+	}
+
+	r, _, _ := procDefWindowProcW.Call(hwnd, message, wParam, lParam)
+	return r
+}
+
+// paint dims the window and cuts out the current selection
+func (o *overlay) paint(hwnd uintptr) {
+	var ps paintStruct
+	hdc, _, _ := procBeginPaint.Call(hwnd, ptr(&ps))
+	defer procEndPaint.Call(hwnd, ptr(&ps))
+
+	procFillRect.Call(hdc, ptr(&ps.Paint), o.dim)
+	if o.dragging || o.adjusting {
+		r := dragRect(o.start, o.end)
+		procFillRect.Call(hdc, ptr(&r), o.hole)
+		procFrameRect.Call(hdc, ptr(&r), o.border)
+	}
+}
+
+// selection returns the dragged rectangle in screen coordinates
+func (o *overlay) selection() image.Rectangle {
+	r := dragRect(o.start, o.end)
+	return image.Rect(int(r.Left), int(r.Top), int(r.Right), int(r.Bottom)).
+		Add(image.Pt(int(o.origin.X), int(o.origin.Y)))
+}
+
+// dragRect returns the rectangle spanned by two drag corners
+func dragRect(a, b point) rect {
+	return rect{
+		Left:   min(a.X, b.X),
+		Top:    min(a.Y, b.Y),
+		Right:  max(a.X, b.X),
+		Bottom: max(a.Y, b.Y),
+	}
+}
+
+// pointFromLParam decodes the signed mouse coordinates packed in a mouse
+// message's lParam
+func pointFromLParam(lParam uintptr) point {
+	return point{
+		X: int32(int16(lParam & 0xffff)),
+		Y: int32(int16(lParam >> 16 & 0xffff)),
+	}
+}
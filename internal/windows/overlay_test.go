@@ -0,0 +1,44 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPointFromLParam(t *testing.T) {
+	tests := []struct {
+		name   string
+		lParam uintptr
+		want   point
+	}{
+		{name: "origin", lParam: 0, want: point{X: 0, Y: 0}},
+		{name: "positive", lParam: 600<<16 | 800, want: point{X: 800, Y: 600}},
+		{name: "negative", lParam: 0xfff6<<16 | 0xffec, want: point{X: -20, Y: -10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pointFromLParam(tt.lParam); got != tt.want {
+				t.Errorf("pointFromLParam(%#x) = %+v, want %+v", tt.lParam, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelection(t *testing.T) {
+	// Dragging up and to the left on a virtual screen that starts on a
+	// monitor left of the primary one
+	o := &overlay{
+		origin: point{X: -1920, Y: 0},
+		start:  point{X: 500, Y: 400},
+		end:    point{X: 100, Y: 100},
+	}
+
+	want := image.Rect(-1820, 100, -1420, 400)
+	if got := o.selection(); got != want {
+		t.Errorf("selection() = %v, want %v", got, want)
+	}
+}
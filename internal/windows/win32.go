@@ -0,0 +1,154 @@
+//go:build windows
+// +build windows
+
+package windows
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	gdi32    = syscall.NewLazyDLL("gdi32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procSetProcessDPIAware         = user32.NewProc("SetProcessDPIAware")
+	procGetSystemMetrics           = user32.NewProc("GetSystemMetrics")
+	procRegisterClassExW           = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW            = user32.NewProc("CreateWindowExW")
+	procDestroyWindow              = user32.NewProc("DestroyWindow")
+	procDefWindowProcW             = user32.NewProc("DefWindowProcW")
+	procSetLayeredWindowAttributes = user32.NewProc("SetLayeredWindowAttributes")
+	procSetForegroundWindow        = user32.NewProc("SetForegroundWindow")
+	procGetMessageW                = user32.NewProc("GetMessageW")
+	procTranslateMessage           = user32.NewProc("TranslateMessage")
+	procDispatchMessageW           = user32.NewProc("DispatchMessageW")
+	procPostQuitMessage            = user32.NewProc("PostQuitMessage")
+	procLoadCursorW                = user32.NewProc("LoadCursorW")
+	procSetCapture                 = user32.NewProc("SetCapture")
+	procGetKeyState                = user32.NewProc("GetKeyState")
+	procReleaseCapture             = user32.NewProc("ReleaseCapture")
+	procInvalidateRect             = user32.NewProc("InvalidateRect")
+	procBeginPaint                 = user32.NewProc("BeginPaint")
+	procEndPaint                   = user32.NewProc("EndPaint")
+	procFillRect                   = user32.NewProc("FillRect")
+	procFrameRect                  = user32.NewProc("FrameRect")
+	procSetWindowPos               = user32.NewProc("SetWindowPos")
+	procGetDC                      = user32.NewProc("GetDC")
+	procReleaseDC                  = user32.NewProc("ReleaseDC")
+	procCreateSolidBrush           = gdi32.NewProc("CreateSolidBrush")
+	procDeleteObject               = gdi32.NewProc("DeleteObject")
+	procStretchBlt                 = gdi32.NewProc("StretchBlt")
+	procSetStretchBltMode          = gdi32.NewProc("SetStretchBltMode")
+	procSetBkMode                  = gdi32.NewProc("SetBkMode")
+	procSetTextColor               = gdi32.NewProc("SetTextColor")
+	procTextOutW                   = gdi32.NewProc("TextOutW")
+	procGetModuleHandleW           = kernel32.NewProc("GetModuleHandleW")
+)
+
+const (
+	smXVirtualScreen  = 76
+	smYVirtualScreen  = 77
+	smCXVirtualScreen = 78
+	smCYVirtualScreen = 79
+
+	wsPopup       = 0x80000000
+	wsVisible     = 0x10000000
+	wsExTopmost   = 0x00000008
+	wsExClickThru = 0x00000020 // WS_EX_TRANSPARENT
+	wsExToolWin   = 0x00000080
+	wsExLayered   = 0x00080000
+	wsExNoActive  = 0x08000000
+	lwaColorKey   = 0x1
+	lwaAlpha      = 0x2
+	swpNoSize     = 0x0001
+	swpNoActivate = 0x0010
+	swpShowWindow = 0x0040
+	srcCopy       = 0x00CC0020
+	colorOnColor  = 3
+	bkTransparent = 1
+	idcCross      = 32515
+	vkReturn      = 0x0D
+	vkShift       = 0x10
+	vkControl     = 0x11
+	vkEscape      = 0x1B
+	vkLeft        = 0x25
+	vkUp          = 0x26
+	vkRight       = 0x27
+	vkDown        = 0x28
+	wmDestroy     = 0x0002
+	wmPaint       = 0x000F
+	wmEraseBkgnd  = 0x0014
+	wmKeyDown     = 0x0100
+	wmMouseMove   = 0x0200
+	wmLButtonDown = 0x0201
+	wmLButtonUp   = 0x0202
+	wmRButtonDown = 0x0204
+)
+
+// hwndTopmost places a window above all non-topmost windows
+const hwndTopmost = ^uintptr(0) // HWND_TOPMOST (-1)
+
+// point is a Win32 POINT
+type point struct {
+	X, Y int32
+}
+
+// rect is a Win32 RECT
+type rect struct {
+	Left, Top, Right, Bottom int32
+}
+
+// msg is a Win32 MSG
+type msg struct {
+	Hwnd    uintptr
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      point
+	Private uint32
+}
+
+// wndClassEx is a Win32 WNDCLASSEXW
+type wndClassEx struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   uintptr
+	Icon       uintptr
+	Cursor     uintptr
+	Background uintptr
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSm     uintptr
+}
+
+// paintStruct is a Win32 PAINTSTRUCT
+type paintStruct struct {
+	Hdc       uintptr
+	Erase     int32
+	Paint     rect
+	Restore   int32
+	IncUpdate int32
+	Reserved  [32]byte
+}
+
+// rgb returns a Win32 COLORREF
+func rgb(r, g, b uint8) uintptr {
+	return uintptr(r) | uintptr(g)<<8 | uintptr(b)<<16
+}
+
+// systemMetric returns a GetSystemMetrics value
+func systemMetric(index uintptr) int32 {
+	r, _, _ := procGetSystemMetrics.Call(index)
+	return int32(r)
+}
+
+// ptr returns the address of v as a call argument
+func ptr[T any](v *T) uintptr {
+	return uintptr(unsafe.Pointer(v))
+}
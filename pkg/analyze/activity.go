@@ -0,0 +1,77 @@
+package analyze
+
+import (
+	"image"
+)
+
+// StaticThreshold is the fraction of never-changing pixels above which a
+// recording is considered mostly static
+const StaticThreshold = 0.8
+
+// DefaultPadding is the margin kept around the active area when suggesting
+// a tighter region, so motion at its edges is not clipped
+const DefaultPadding = 16
+
+// Activity summarizes which pixels change over a sequence of frames
+type Activity struct {
+	// Bounds is the smallest rectangle containing every changed pixel.
+	// It is empty when nothing changed.
+	Bounds image.Rectangle
+
+	// Changed is the number of pixels that differ from the first frame in
+	// at least one later frame
+	Changed int
+
+	// Total is the number of pixels in a frame
+	Total int
+}
+
+// DetectActivity compares every frame against the first and reports which
+// pixels change. All frames must have the same bounds.
+func DetectActivity(frames []image.Image, tolerance uint8) Activity {
+	if len(frames) == 0 {
+		return Activity{}
+	}
+
+	b := frames[0].Bounds()
+	a := Activity{Total: b.Dx() * b.Dy()}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			ref := RGBAAt(frames[0], x, y)
+			for _, f := range frames[1:] {
+				if !Similar(RGBAAt(f, x, y), ref, tolerance) {
+					a.Changed++
+					a.Bounds = a.Bounds.Union(image.Rect(x, y, x+1, y+1))
+					break
+				}
+			}
+		}
+	}
+
+	return a
+}
+
+// StaticFraction returns the fraction of pixels that never change
+func (a Activity) StaticFraction() float64 {
+	if a.Total == 0 {
+		return 0
+	}
+	return 1 - float64(a.Changed)/float64(a.Total)
+}
+
+// MostlyStatic reports whether more than StaticThreshold of the frame never
+// changes while some smaller area does, so a tighter region would produce a
+// smaller recording
+func (a Activity) MostlyStatic() bool {
+	return !a.Bounds.Empty() && a.StaticFraction() > StaticThreshold
+}
+
+// Suggest returns the active area grown by padding pixels on every side and
+// clipped to bounds
+func (a Activity) Suggest(bounds image.Rectangle, padding int) image.Rectangle {
+	if a.Bounds.Empty() {
+		return bounds
+	}
+	return a.Bounds.Inset(-padding).Intersect(bounds)
+}
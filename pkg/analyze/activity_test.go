@@ -0,0 +1,66 @@
+package analyze
+
+import (
+	"image"
+	"testing"
+)
+
+func TestDetectActivity(t *testing.T) {
+	bounds := image.Rect(0, 0, 40, 30)
+	active := image.Rect(10, 5, 20, 15)
+
+	frames := []image.Image{
+		windowFrame(bounds, active, white),
+		windowFrame(bounds, active, black),
+	}
+
+	a := DetectActivity(frames, DefaultTolerance)
+	if a.Bounds != active {
+		t.Errorf("Bounds = %v, want %v", a.Bounds, active)
+	}
+	if a.Changed != 100 || a.Total != 1200 {
+		t.Errorf("Changed/Total = %d/%d, want 100/1200", a.Changed, a.Total)
+	}
+	if !a.MostlyStatic() {
+		t.Errorf("MostlyStatic() = false with static fraction %.2f", a.StaticFraction())
+	}
+}
+
+func TestDetectActivityBusy(t *testing.T) {
+	bounds := image.Rect(0, 0, 10, 10)
+	active := image.Rect(1, 1, 9, 9)
+
+	frames := []image.Image{
+		windowFrame(bounds, active, white),
+		windowFrame(bounds, active, black),
+	}
+
+	if a := DetectActivity(frames, DefaultTolerance); a.MostlyStatic() {
+		t.Errorf("MostlyStatic() = true with static fraction %.2f", a.StaticFraction())
+	}
+}
+
+func TestDetectActivityStill(t *testing.T) {
+	bounds := image.Rect(0, 0, 10, 10)
+	frame := windowFrame(bounds, image.Rect(2, 2, 5, 5), white)
+
+	a := DetectActivity([]image.Image{frame, frame}, DefaultTolerance)
+	if !a.Bounds.Empty() || a.Changed != 0 {
+		t.Errorf("still frames reported activity %+v", a)
+	}
+	if a.MostlyStatic() {
+		t.Error("MostlyStatic() should be false when nothing changes")
+	}
+	if got := a.Suggest(bounds, DefaultPadding); got != bounds {
+		t.Errorf("Suggest() = %v, want full bounds", got)
+	}
+}
+
+func TestActivitySuggest(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	a := Activity{Bounds: image.Rect(5, 40, 30, 60)}
+
+	if got, want := a.Suggest(bounds, 10), image.Rect(0, 30, 40, 70); got != want {
+		t.Errorf("Suggest() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package analyze
+
+import (
+	"image"
+	"image/color"
+)
+
+// DefaultTolerance is the per-channel difference below which two colors are
+// considered the same. It absorbs dithering and compression noise.
+const DefaultTolerance = 8
+
+// BorderCrop returns the smallest rectangle that excludes static, uniform
+// borders from a sequence of frames. A border row or column is trimmed when
+// it is the same single color (within tolerance) as the outermost line on
+// that side in every frame. This removes desktop background around a window
+// recording. All frames must have the same bounds. The full bounds are
+// returned when nothing can be trimmed or the frames have no content.
+func BorderCrop(frames []image.Image, tolerance uint8) image.Rectangle {
+	if len(frames) == 0 {
+		return image.Rectangle{}
+	}
+
+	b := frames[0].Bounds()
+	crop := b
+
+	top, topOK := lineColor(frames, tolerance, b.Min.X, b.Min.Y, 1, 0, b.Dx())
+	bottom, bottomOK := lineColor(frames, tolerance, b.Min.X, b.Max.Y-1, 1, 0, b.Dx())
+	left, leftOK := lineColor(frames, tolerance, b.Min.X, b.Min.Y, 0, 1, b.Dy())
+	right, rightOK := lineColor(frames, tolerance, b.Max.X-1, b.Min.Y, 0, 1, b.Dy())
+
+	rowIs := func(y int, c color.RGBA) bool {
+		got, ok := lineColor(frames, tolerance, crop.Min.X, y, 1, 0, crop.Dx())
+		return ok && Similar(got, c, tolerance)
+	}
+	colIs := func(x int, c color.RGBA) bool {
+		got, ok := lineColor(frames, tolerance, x, crop.Min.Y, 0, 1, crop.Dy())
+		return ok && Similar(got, c, tolerance)
+	}
+
+	// Trimming columns may expose more border rows, and vice versa
+	for changed := true; changed; {
+		changed = false
+		for topOK && crop.Dy() > 1 && rowIs(crop.Min.Y, top) {
+			crop.Min.Y++
+			changed = true
+		}
+		for bottomOK && crop.Dy() > 1 && rowIs(crop.Max.Y-1, bottom) {
+			crop.Max.Y--
+			changed = true
+		}
+		for leftOK && crop.Dx() > 1 && colIs(crop.Min.X, left) {
+			crop.Min.X++
+			changed = true
+		}
+		for rightOK && crop.Dx() > 1 && colIs(crop.Max.X-1, right) {
+			crop.Max.X--
+			changed = true
+		}
+	}
+
+	// A single remaining line means every frame was one flat color
+	if crop.Dx() <= 1 || crop.Dy() <= 1 {
+		return b
+	}
+
+	return crop
+}
+
+// lineColor returns the color shared by the n pixels starting at (x,y) and
+// stepping by (dx,dy) in every frame. ok is false when the line is not a
+// single static color.
+func lineColor(frames []image.Image, tolerance uint8, x, y, dx, dy, n int) (c color.RGBA, ok bool) {
+	ref := RGBAAt(frames[0], x, y)
+	for _, f := range frames {
+		for i := 0; i < n; i++ {
+			if !Similar(RGBAAt(f, x+i*dx, y+i*dy), ref, tolerance) {
+				return color.RGBA{}, false
+			}
+		}
+	}
+	return ref, true
+}
+
+// RGBAAt returns the color at (x,y) with fast paths for common image types
+func RGBAAt(img image.Image, x, y int) color.RGBA {
+	switch im := img.(type) {
+	case *image.RGBA:
+		return im.RGBAAt(x, y)
+	case *image.Paletted:
+		if !(image.Point{X: x, Y: y}.In(im.Rect)) {
+			return color.RGBA{}
+		}
+		idx := im.Pix[im.PixOffset(x, y)]
+		if int(idx) >= len(im.Palette) {
+			return color.RGBA{}
+		}
+		return color.RGBAModel.Convert(im.Palette[idx]).(color.RGBA)
+	default:
+		return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+	}
+}
+
+// Similar reports whether every channel of a and b differs by at most tolerance
+func Similar(a, b color.RGBA, tolerance uint8) bool {
+	return diff(a.R, b.R) <= tolerance && diff(a.G, b.G) <= tolerance &&
+		diff(a.B, b.B) <= tolerance && diff(a.A, b.A) <= tolerance
+}
+
+// diff returns the absolute difference of two channel values
+func diff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// CropPaletted copies the part of p inside r into a new image with its
+// origin at (0,0), sharing p's palette
+func CropPaletted(p *image.Paletted, r image.Rectangle) *image.Paletted {
+	r = r.Intersect(p.Bounds())
+	out := image.NewPaletted(image.Rect(0, 0, r.Dx(), r.Dy()), p.Palette)
+	for y := 0; y < r.Dy(); y++ {
+		src := p.PixOffset(r.Min.X, r.Min.Y+y)
+		copy(out.Pix[y*out.Stride:y*out.Stride+r.Dx()], p.Pix[src:src+r.Dx()])
+	}
+	return out
+}
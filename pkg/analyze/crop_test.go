@@ -0,0 +1,124 @@
+package analyze
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+var (
+	background = color.RGBA{R: 40, G: 90, B: 160, A: 255}
+	white      = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	black      = color.RGBA{A: 255}
+)
+
+// Helper function to create a frame with a window drawn over a background
+func windowFrame(bounds, window image.Rectangle, windowColor color.RGBA) *image.RGBA {
+	img := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := background
+			if (image.Point{X: x, Y: y}).In(window) {
+				c = windowColor
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestBorderCrop(t *testing.T) {
+	bounds := image.Rect(0, 0, 40, 30)
+	window := image.Rect(5, 4, 30, 20)
+
+	frames := []image.Image{
+		windowFrame(bounds, window, white),
+		windowFrame(bounds, window, black),
+	}
+
+	if got := BorderCrop(frames, DefaultTolerance); got != window {
+		t.Errorf("BorderCrop() = %v, want %v", got, window)
+	}
+}
+
+func TestBorderCropNonZeroOrigin(t *testing.T) {
+	bounds := image.Rect(100, 50, 140, 80)
+	window := image.Rect(110, 60, 130, 70)
+
+	frames := []image.Image{windowFrame(bounds, window, white)}
+
+	if got := BorderCrop(frames, DefaultTolerance); got != window {
+		t.Errorf("BorderCrop() = %v, want %v", got, window)
+	}
+}
+
+func TestBorderCropKeepsChangingBorder(t *testing.T) {
+	bounds := image.Rect(0, 0, 20, 20)
+	window := image.Rect(5, 5, 15, 15)
+
+	// The background changes color between frames, so it is content
+	second := windowFrame(bounds, window, white)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			if !(image.Point{X: x, Y: y}).In(window) {
+				second.SetRGBA(x, y, black)
+			}
+		}
+	}
+	frames := []image.Image{windowFrame(bounds, window, white), second}
+
+	if got := BorderCrop(frames, DefaultTolerance); got != bounds {
+		t.Errorf("BorderCrop() = %v, want full bounds %v", got, bounds)
+	}
+}
+
+func TestBorderCropTolerance(t *testing.T) {
+	bounds := image.Rect(0, 0, 20, 20)
+	window := image.Rect(4, 4, 16, 16)
+
+	img := windowFrame(bounds, window, white)
+	noisy := background
+	noisy.B += 3
+	img.SetRGBA(0, 10, noisy)
+
+	frames := []image.Image{img}
+
+	if got := BorderCrop(frames, DefaultTolerance); got != window {
+		t.Errorf("BorderCrop() with noise = %v, want %v", got, window)
+	}
+	if got := BorderCrop(frames, 0); got.Min.X != 0 {
+		t.Errorf("BorderCrop() with zero tolerance = %v, want left column kept", got)
+	}
+}
+
+func TestBorderCropUniform(t *testing.T) {
+	bounds := image.Rect(0, 0, 10, 10)
+	frames := []image.Image{windowFrame(bounds, image.Rectangle{}, white)}
+
+	if got := BorderCrop(frames, DefaultTolerance); got != bounds {
+		t.Errorf("BorderCrop() of a uniform frame = %v, want full bounds %v", got, bounds)
+	}
+}
+
+func TestBorderCropNoFrames(t *testing.T) {
+	if got := BorderCrop(nil, DefaultTolerance); !got.Empty() {
+		t.Errorf("BorderCrop(nil) = %v, want empty", got)
+	}
+}
+
+func TestCropPaletted(t *testing.T) {
+	p := image.NewPaletted(image.Rect(0, 0, 6, 4), color.Palette{black, white})
+	p.SetColorIndex(3, 2, 1)
+
+	out := CropPaletted(p, image.Rect(2, 1, 5, 3))
+
+	if out.Bounds() != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("bounds = %v, want (0,0)-(3,2)", out.Bounds())
+	}
+	if out.ColorIndexAt(1, 1) != 1 {
+		t.Error("pixel (3,2) should move to (1,1)")
+	}
+	if out.ColorIndexAt(0, 0) != 0 {
+		t.Error("other pixels should be unchanged")
+	}
+}
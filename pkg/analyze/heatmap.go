@@ -0,0 +1,156 @@
+package analyze
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// DefaultClickRadius is how far around a click, in pixels, a heatmap marks
+// it as active
+const DefaultClickRadius = 24
+
+// Heatmap accumulates where the screen changed and where the user clicked
+// during a recording, and renders it over the last frame so reviewers can
+// see which parts of the screen drew the most activity. Each frame is
+// compared with the one added before it, so a pixel that changes often
+// counts for more than one that changed once.
+type Heatmap struct {
+	// Tolerance is the largest per-channel difference not counted as a
+	// change, so compression noise doesn't register as activity
+	Tolerance uint8
+
+	// ClickRadius is how far around a click, in pixels, is marked active
+	ClickRadius int
+
+	bounds  image.Rectangle // Frame size, at the origin; empty before the first frame
+	last    image.Image
+	changes []uint32 // How many times each pixel changed
+	clicks  []uint32 // Click weight at each pixel
+}
+
+// NewHeatmap creates an empty heatmap
+func NewHeatmap() *Heatmap {
+	return &Heatmap{
+		Tolerance:   DefaultTolerance,
+		ClickRadius: DefaultClickRadius,
+	}
+}
+
+// Bounds returns the frame size the heatmap covers, or an empty rectangle
+// before the first frame
+func (h *Heatmap) Bounds() image.Rectangle {
+	return h.bounds
+}
+
+// AddFrame counts the pixels that differ from the previous frame. The
+// heatmap keeps img as the background for Image, so it must not be changed
+// afterwards. The first frame sets the heatmap's size; frames of another
+// size are not compared.
+func (h *Heatmap) AddFrame(img image.Image) {
+	b := img.Bounds()
+	if h.bounds.Empty() {
+		h.bounds = image.Rect(0, 0, b.Dx(), b.Dy())
+		h.changes = make([]uint32, b.Dx()*b.Dy())
+		h.clicks = make([]uint32, b.Dx()*b.Dy())
+	}
+	if b.Size() != h.bounds.Size() {
+		return
+	}
+
+	if h.last != nil {
+		lb := h.last.Bounds()
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				prev := RGBAAt(h.last, lb.Min.X+x, lb.Min.Y+y)
+				if !Similar(RGBAAt(img, b.Min.X+x, b.Min.Y+y), prev, h.Tolerance) {
+					h.changes[y*b.Dx()+x]++
+				}
+			}
+		}
+	}
+	h.last = img
+}
+
+// AddClick marks a click at p, in frame pixels, fading out to ClickRadius.
+// Clicks before the first frame, or outside it, are ignored.
+func (h *Heatmap) AddClick(p image.Point) {
+	if !p.In(h.bounds) {
+		return
+	}
+	r := max(1, h.ClickRadius)
+	area := image.Rect(p.X-r, p.Y-r, p.X+r+1, p.Y+r+1).Intersect(h.bounds)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			d := math.Hypot(float64(x-p.X), float64(y-p.Y))
+			if d < float64(r) {
+				h.clicks[y*h.bounds.Dx()+x] += uint32(256 * (1 - d/float64(r)))
+			}
+		}
+	}
+}
+
+// Image renders the heatmap over a dimmed grayscale copy of the last frame.
+// Changes are scaled logarithmically, so a blinking cursor doesn't drown
+// out areas that changed less often, and clicks are scaled linearly; each
+// pixel shows whichever is hotter, from blue through green and yellow to
+// red. It returns nil before the first frame.
+func (h *Heatmap) Image() *image.RGBA {
+	if h.last == nil {
+		return nil
+	}
+
+	var maxChanges, maxClicks uint32
+	for i := range h.changes {
+		maxChanges = max(maxChanges, h.changes[i])
+		maxClicks = max(maxClicks, h.clicks[i])
+	}
+
+	w := h.bounds.Dx()
+	lb := h.last.Bounds()
+	out := image.NewRGBA(h.bounds)
+	for y := 0; y < h.bounds.Dy(); y++ {
+		for x := 0; x < w; x++ {
+			c := RGBAAt(h.last, lb.Min.X+x, lb.Min.Y+y)
+			gray := uint8((299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 2000)
+			px := color.RGBA{gray, gray, gray, 255}
+
+			var heat float64
+			if n := h.changes[y*w+x]; n > 0 {
+				heat = math.Log1p(float64(n)) / math.Log1p(float64(maxChanges))
+			}
+			if n := h.clicks[y*w+x]; n > 0 {
+				heat = max(heat, float64(n)/float64(maxClicks))
+			}
+			if heat > 0 {
+				px = blend(px, heatColor(heat), 0.3+0.5*heat)
+			}
+			out.SetRGBA(x, y, px)
+		}
+	}
+	return out
+}
+
+// heatStops are the colors of the heat scale, from coolest to hottest
+var heatStops = []color.RGBA{
+	{0, 0, 255, 255},
+	{0, 255, 255, 255},
+	{0, 255, 0, 255},
+	{255, 255, 0, 255},
+	{255, 0, 0, 255},
+}
+
+// heatColor returns the color for heat between 0 and 1
+func heatColor(heat float64) color.RGBA {
+	pos := min(max(heat, 0), 1) * float64(len(heatStops)-1)
+	i := min(int(pos), len(heatStops)-2)
+	return blend(heatStops[i], heatStops[i+1], pos-float64(i))
+}
+
+// blend mixes a fraction t of b into a
+func blend(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x)*(1-t) + float64(y)*t + 0.5)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
@@ -0,0 +1,83 @@
+package analyze
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestHeatmap(t *testing.T) {
+	bounds := image.Rect(0, 0, 40, 30)
+	busy := image.Rect(2, 2, 6, 6)     // Changes on every frame
+	once := image.Rect(30, 20, 34, 24) // Changes once
+
+	h := NewHeatmap()
+	if h.Image() != nil {
+		t.Error("Image() should be nil before the first frame")
+	}
+	for i := 0; i < 10; i++ {
+		c := white
+		if i%2 == 1 {
+			c = black
+		}
+		frame := windowFrame(bounds, busy, c)
+		if i >= 5 {
+			draw.Draw(frame, once, image.NewUniform(white), image.Point{}, draw.Src)
+		}
+		h.AddFrame(frame)
+	}
+	if h.Bounds() != bounds {
+		t.Fatalf("Bounds() = %v, want %v", h.Bounds(), bounds)
+	}
+
+	img := h.Image()
+	hot, warm, still := img.RGBAAt(3, 3), img.RGBAAt(31, 21), img.RGBAAt(15, 15)
+	if hot.R <= hot.B {
+		t.Errorf("busy area %v should be red", hot)
+	}
+	if warm.B <= warm.R {
+		t.Errorf("area that changed once %v should be blue", warm)
+	}
+	if still.R != still.G || still.G != still.B {
+		t.Errorf("unchanged area %v should be gray", still)
+	}
+}
+
+func TestHeatmapClicks(t *testing.T) {
+	bounds := image.Rect(0, 0, 40, 30)
+	h := NewHeatmap()
+	h.ClickRadius = 5
+
+	h.AddClick(image.Pt(10, 10)) // Before the first frame
+	h.AddFrame(windowFrame(bounds, image.Rectangle{}, white))
+	h.AddClick(image.Pt(20, 15))
+	h.AddClick(image.Pt(50, 50)) // Outside the frame
+
+	img := h.Image()
+	if c := img.RGBAAt(20, 15); c.R <= c.B {
+		t.Errorf("clicked pixel %v should be red", c)
+	}
+	for _, p := range []image.Point{{10, 10}, {26, 15}, {39, 29}} {
+		if c := img.RGBAAt(p.X, p.Y); c.R != c.G || c.G != c.B {
+			t.Errorf("pixel %v = %v, want gray", p, c)
+		}
+	}
+}
+
+func TestHeatmapOffsetFrames(t *testing.T) {
+	h := NewHeatmap()
+	h.AddFrame(windowFrame(image.Rect(10, 10, 30, 30), image.Rect(10, 10, 12, 12), white))
+	h.AddFrame(windowFrame(image.Rect(0, 0, 20, 20), image.Rect(0, 0, 2, 2), black))
+	h.AddFrame(windowFrame(image.Rect(0, 0, 5, 5), image.Rectangle{}, black)) // Other size, ignored
+
+	if h.Bounds() != image.Rect(0, 0, 20, 20) {
+		t.Fatalf("Bounds() = %v, want 20x20 at the origin", h.Bounds())
+	}
+	img := h.Image()
+	if c := img.RGBAAt(0, 0); c.R == c.B {
+		t.Errorf("changed pixel %v should be colored", c)
+	}
+	if c := img.RGBAAt(5, 5); c.R != c.B {
+		t.Errorf("unchanged pixel %v should be gray", c)
+	}
+}
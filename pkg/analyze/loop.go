@@ -0,0 +1,120 @@
+package analyze
+
+import (
+	"image"
+	"image/color"
+)
+
+// SeamlessThreshold is the largest fraction of differing pixels at which
+// jumping from one frame back to another is not noticeable
+const SeamlessThreshold = 0.01
+
+// MinLoopFraction is the smallest fraction of a recording a loop may keep,
+// so a still stretch of a few identical frames is not mistaken for a loop
+const MinLoopFraction = 0.5
+
+// MaxLoopFrames is the most frames searched for a loop. Comparing every
+// pair of frames is slow, and long recordings rarely loop.
+const MaxLoopFrames = 600
+
+// loopSamples is the largest number of pixels compared along each side of a
+// frame when looking for a loop
+const loopSamples = 64
+
+// Loop is a range of frames that plays back seamlessly when repeated: the
+// frame after Last looks like First, so jumping from Last back to First
+// continues the motion. Both ends are counted from 0 and kept.
+type Loop struct {
+	First int
+	Last  int
+
+	// Difference is the fraction of pixels that change at the jump from
+	// Last back to First
+	Difference float64
+}
+
+// Len returns the number of frames in the loop
+func (l Loop) Len() int {
+	return l.Last - l.First + 1
+}
+
+// FindLoop finds the pair of frames most alike and returns the loop between
+// them, keeping at least MinLoopFraction of the frames. The whole recording
+// is preferred when its last frame already matches its first, and longer
+// loops are preferred over equally good shorter ones. Frames are compared
+// on a grid of sampled pixels, within tolerance per channel. All frames must
+// have the same bounds. It returns false when no loop jumps back with at
+// most SeamlessThreshold of the pixels changing, or with fewer than 3 or
+// more than MaxLoopFrames frames.
+func FindLoop(frames []image.Image, tolerance uint8) (Loop, bool) {
+	n := len(frames)
+	if n < 3 || n > MaxLoopFrames {
+		return Loop{}, false
+	}
+
+	samples := make([][]color.RGBA, n)
+	for i, f := range frames {
+		samples[i] = sampleGrid(f)
+	}
+	total := len(samples[0])
+	if total == 0 {
+		return Loop{}, false
+	}
+
+	// Comparisons stop once too many samples differ for a seamless jump
+	limit := int(float64(total)*SeamlessThreshold) + 1
+	best, bestDiff := Loop{}, limit
+
+	// Playing the whole recording jumps from the last frame to the first
+	if d := countDiff(samples[n-1], samples[0], tolerance, bestDiff); d < bestDiff {
+		best, bestDiff = Loop{First: 0, Last: n - 1}, d
+	}
+
+	// Otherwise frame i+length matches frame i, so the frames before it
+	// loop. Longer loops are tried first and only replaced by strictly
+	// better ones, so nothing can replace an exact match.
+	minLen := max(2, int(float64(n)*MinLoopFraction+0.5))
+	for length := n - 1; length >= minLen && bestDiff > 0; length-- {
+		for i := 0; i+length < n; i++ {
+			if d := countDiff(samples[i+length], samples[i], tolerance, bestDiff); d < bestDiff {
+				best, bestDiff = Loop{First: i, Last: i + length - 1}, d
+			}
+		}
+	}
+
+	if bestDiff == limit {
+		return Loop{}, false
+	}
+	best.Difference = float64(bestDiff) / float64(total)
+	return best, true
+}
+
+// sampleGrid reads up to loopSamples by loopSamples evenly spaced pixels
+func sampleGrid(img image.Image) []color.RGBA {
+	b := img.Bounds()
+	cols, rows := min(b.Dx(), loopSamples), min(b.Dy(), loopSamples)
+
+	out := make([]color.RGBA, 0, cols*rows)
+	for r := 0; r < rows; r++ {
+		y := b.Min.Y + r*b.Dy()/rows
+		for c := 0; c < cols; c++ {
+			out = append(out, RGBAAt(img, b.Min.X+c*b.Dx()/cols, y))
+		}
+	}
+	return out
+}
+
+// countDiff counts the samples that differ between a and b, stopping once
+// the count reaches limit
+func countDiff(a, b []color.RGBA, tolerance uint8, limit int) int {
+	n := 0
+	for i := range a {
+		if !Similar(a[i], b[i], tolerance) {
+			n++
+			if n >= limit {
+				return n
+			}
+		}
+	}
+	return n
+}
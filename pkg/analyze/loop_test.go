@@ -0,0 +1,87 @@
+package analyze
+
+import (
+	"image"
+	"testing"
+)
+
+// Helper function to create frames of a box moving along a strip, one frame
+// per box position
+func boxFrames(positions ...int) []image.Image {
+	bounds := image.Rect(0, 0, 160, 20)
+	frames := make([]image.Image, len(positions))
+	for i, p := range positions {
+		frames[i] = windowFrame(bounds, image.Rect(p*16, 4, p*16+12, 16), white)
+	}
+	return frames
+}
+
+func TestFindLoop(t *testing.T) {
+	tests := []struct {
+		name      string
+		positions []int
+		want      Loop
+	}{
+		{
+			name:      "cycle between an intro and an outro",
+			positions: []int{9, 8, 0, 1, 2, 3, 0, 1, 2, 3, 0, 7},
+			want:      Loop{First: 2, Last: 9},
+		},
+		{
+			name:      "already loops",
+			positions: []int{0, 1, 2, 3, 0},
+			want:      Loop{First: 0, Last: 4},
+		},
+		{
+			name:      "still recording keeps every frame",
+			positions: []int{5, 5, 5, 5, 5, 5},
+			want:      Loop{First: 0, Last: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FindLoop(boxFrames(tt.positions...), DefaultTolerance)
+			if !ok {
+				t.Fatal("FindLoop() found no loop")
+			}
+			if got.First != tt.want.First || got.Last != tt.want.Last {
+				t.Errorf("FindLoop() = frames %d-%d, want %d-%d", got.First, got.Last, tt.want.First, tt.want.Last)
+			}
+			if got.Difference != 0 {
+				t.Errorf("Difference = %.3f, want 0", got.Difference)
+			}
+		})
+	}
+}
+
+func TestFindLoopShortRepeat(t *testing.T) {
+	// Frames 0 and 2 match, but looping them would keep too little
+	if got, ok := FindLoop(boxFrames(0, 1, 0, 2, 3, 4, 5, 6, 7, 8), DefaultTolerance); ok {
+		t.Errorf("FindLoop() = frames %d-%d, want no loop", got.First, got.Last)
+	}
+}
+
+func TestFindLoopTolerance(t *testing.T) {
+	frames := boxFrames(0, 1, 2, 3, 0)
+	noisy := frames[4].(*image.RGBA)
+	for i := range noisy.Pix {
+		if i%4 != 3 && noisy.Pix[i] < 250 {
+			noisy.Pix[i] += 4
+		}
+	}
+
+	got, _ := FindLoop(frames, DefaultTolerance)
+	if got.Len() != 5 || got.Difference != 0 {
+		t.Errorf("FindLoop() = %+v, want all 5 frames with no difference", got)
+	}
+}
+
+func TestFindLoopFrameCount(t *testing.T) {
+	if _, ok := FindLoop(boxFrames(0, 1), DefaultTolerance); ok {
+		t.Error("FindLoop() should need at least 3 frames")
+	}
+	if _, ok := FindLoop(boxFrames(make([]int, MaxLoopFrames+1)...), DefaultTolerance); ok {
+		t.Errorf("FindLoop() should not search more than %d frames", MaxLoopFrames)
+	}
+}
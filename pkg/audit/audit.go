@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/config"
+)
+
+// Event identifies what an audit entry records
+type Event string
+
+const (
+	// EventConsentGiven records that the user acknowledged the recording banner
+	EventConsentGiven Event = "consent-given"
+	// EventConsentDeclined records that the user dismissed the recording banner
+	EventConsentDeclined Event = "consent-declined"
+	// EventStart records that a recording started
+	EventStart Event = "start"
+	// EventStop records that a recording ended
+	EventStop Event = "stop"
+)
+
+// Entry is one line of the audit log
+type Entry struct {
+	Time   time.Time       `json:"time"`
+	Event  Event           `json:"event"`
+	User   string          `json:"user,omitempty"`
+	Region *capture.Region `json:"region,omitempty"`
+	Output string          `json:"output,omitempty"`
+	Detail string          `json:"detail,omitempty"`
+}
+
+// getLogPath returns the path to the audit log
+func getLogPath() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	return filepath.Join(configDir, "audit.log"), nil
+}
+
+// Append adds an entry to the audit log. Time and User are filled in when
+// not set. The log is only ever appended to, one JSON object per line.
+func Append(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.User == "" {
+		e.User = currentUser()
+	}
+
+	path, err := getLogPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	return nil
+}
+
+// Read returns every entry in the audit log, oldest first. A missing log
+// has no entries.
+func Read() ([]Entry, error) {
+	path, err := getLogPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("failed to parse audit log line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// String formats the entry as a single human-readable line
+func (e Entry) String() string {
+	line := fmt.Sprintf("%s  %-16s %s", e.Time.Format("2006-01-02 15:04:05"), e.Event, e.User)
+	if e.Region != nil {
+		line += fmt.Sprintf("  region=%d,%d,%d,%d", e.Region.X, e.Region.Y, e.Region.Width, e.Region.Height)
+	}
+	if e.Output != "" {
+		line += "  output=" + e.Output
+	}
+	if e.Detail != "" {
+		line += "  " + e.Detail
+	}
+	return line
+}
+
+// Recording logs the start and stop of one recording
+type Recording struct {
+	region *capture.Region
+	output string
+	start  time.Time
+}
+
+// StartRecording appends a start entry for a recording of region (nil for
+// the full screen) to output
+func StartRecording(region *capture.Region, output string) (*Recording, error) {
+	r := &Recording{region: region, output: output, start: time.Now()}
+	err := Append(Entry{Time: r.start, Event: EventStart, Region: region, Output: output})
+	return r, err
+}
+
+// Stop appends a stop entry with the recording's duration and the error
+// that ended it, if any
+func (r *Recording) Stop(err error) error {
+	now := time.Now()
+	detail := fmt.Sprintf("duration=%s", now.Sub(r.start).Round(time.Millisecond))
+	if err != nil {
+		detail += fmt.Sprintf(" error=%q", err.Error())
+	}
+	return Append(Entry{Time: now, Event: EventStop, Region: r.region, Output: r.output, Detail: detail})
+}
+
+// currentUser returns the login name of the user running Witness
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
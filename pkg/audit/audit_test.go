@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Helper function to read every entry from the audit log
+func readLog(t *testing.T, home string) []Entry {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join(home, ".config", "witness", "audit.log"))
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("Failed to parse audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAppend(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	region := &capture.Region{X: 0, Y: 0, Width: 800, Height: 600}
+	if err := Append(Entry{Event: EventConsentGiven, Region: region, Output: "demo.gif"}); err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+	if err := Append(Entry{Event: EventConsentDeclined, User: "alice"}); err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+
+	entries := readLog(t, home)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	first := entries[0]
+	if first.Event != EventConsentGiven || first.Output != "demo.gif" || *first.Region != *region {
+		t.Errorf("first entry = %+v", first)
+	}
+	if first.Time.IsZero() || time.Since(first.Time) > time.Minute {
+		t.Errorf("first entry time = %v, want now", first.Time)
+	}
+	if first.User == "" {
+		t.Error("User should default to the current user")
+	}
+	if entries[1].User != "alice" {
+		t.Errorf("second entry user = %q, want alice", entries[1].User)
+	}
+}
+
+func TestAppendPermissions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	if err := Append(Entry{Event: EventConsentGiven}); err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(home, ".config", "witness", "audit.log"))
+	if err != nil {
+		t.Fatalf("Stat() failed: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("audit log permissions = %v, want 0600", perm)
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	entries, err := Read()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Read() of a missing log = %v, %v; want no entries", entries, err)
+	}
+
+	region := &capture.Region{X: 10, Y: 20, Width: 300, Height: 200}
+	rec, err := StartRecording(region, "demo.gif")
+	if err != nil {
+		t.Fatalf("StartRecording() failed: %v", err)
+	}
+	if err := rec.Stop(errors.New("screen locked")); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+
+	entries, err = Read()
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Event != EventStart || entries[1].Event != EventStop {
+		t.Errorf("events = %s, %s; want start, stop", entries[0].Event, entries[1].Event)
+	}
+	if !strings.Contains(entries[1].Detail, "duration=") || !strings.Contains(entries[1].Detail, "screen locked") {
+		t.Errorf("stop detail = %q, want duration and error", entries[1].Detail)
+	}
+	if entries[1].Output != "demo.gif" || *entries[1].Region != *region {
+		t.Errorf("stop entry = %+v, want region and output from the start", entries[1])
+	}
+}
+
+func TestReadCorruptLog(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	if err := Append(Entry{Event: EventStart}); err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+	path := filepath.Join(home, ".config", "witness", "audit.log")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	f.WriteString("not json\n")
+	f.Close()
+
+	if _, err := Read(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Read() error = %v, want a parse error on line 2", err)
+	}
+}
+
+func TestEntryString(t *testing.T) {
+	e := Entry{
+		Time:   time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+		Event:  EventStart,
+		User:   "alice",
+		Region: &capture.Region{X: 0, Y: 0, Width: 800, Height: 600},
+		Output: "demo.gif",
+	}
+
+	want := "2024-03-01 09:30:00  start            alice  region=0,0,800,600  output=demo.gif"
+	if got := e.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
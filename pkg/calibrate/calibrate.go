@@ -0,0 +1,228 @@
+// Package calibrate verifies that recordings keep colors accurate. It draws
+// a chart of known color patches, finds the chart in a captured frame, and
+// measures how far each captured patch has drifted from the color drawn.
+// Drift shows up when a display profile, HDR, Night Shift, or a color
+// conversion in the capture path changes the pixels that are recorded.
+package calibrate
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
+)
+
+// Columns and Rows lay out the patches on the chart
+const (
+	Columns = 6
+	Rows    = 4
+)
+
+// NoticeableDeltaE is the color difference, in CIE76 ΔE, below which most
+// people cannot tell two colors apart side by side
+const NoticeableDeltaE = 2.3
+
+// MaxDeltaE is the largest color difference a patch may have for the
+// capture to pass
+const MaxDeltaE = 5.0
+
+// minCell is the smallest patch, in pixels, that can be measured reliably
+const minCell = 8
+
+// ErrChartNotFound is returned when a frame does not contain the chart
+var ErrChartNotFound = errors.New("color chart not found")
+
+// Patch is one known color on the chart
+type Patch struct {
+	Name  string
+	Color color.RGBA
+}
+
+// Patches are the sRGB values of the 24 patches of the classic ColorChecker,
+// in chart order, covering skin tones, saturated primaries and secondaries,
+// and a gray ramp
+var Patches = [Columns * Rows]Patch{
+	{"dark skin", color.RGBA{115, 82, 68, 255}},
+	{"light skin", color.RGBA{194, 150, 130, 255}},
+	{"blue sky", color.RGBA{98, 122, 157, 255}},
+	{"foliage", color.RGBA{87, 108, 67, 255}},
+	{"blue flower", color.RGBA{133, 128, 177, 255}},
+	{"bluish green", color.RGBA{103, 189, 170, 255}},
+	{"orange", color.RGBA{214, 126, 44, 255}},
+	{"purplish blue", color.RGBA{80, 91, 166, 255}},
+	{"moderate red", color.RGBA{193, 90, 99, 255}},
+	{"purple", color.RGBA{94, 60, 108, 255}},
+	{"yellow green", color.RGBA{157, 188, 64, 255}},
+	{"orange yellow", color.RGBA{224, 163, 46, 255}},
+	{"blue", color.RGBA{56, 61, 150, 255}},
+	{"green", color.RGBA{70, 148, 73, 255}},
+	{"red", color.RGBA{175, 54, 60, 255}},
+	{"yellow", color.RGBA{231, 199, 31, 255}},
+	{"magenta", color.RGBA{187, 86, 149, 255}},
+	{"cyan", color.RGBA{8, 133, 161, 255}},
+	{"white", color.RGBA{243, 243, 242, 255}},
+	{"neutral 8", color.RGBA{200, 200, 200, 255}},
+	{"neutral 6.5", color.RGBA{160, 160, 160, 255}},
+	{"neutral 5", color.RGBA{122, 122, 121, 255}},
+	{"neutral 3.5", color.RGBA{85, 85, 85, 255}},
+	{"black", color.RGBA{52, 52, 52, 255}},
+}
+
+// Chart draws the patches as a grid with cells of the given size in pixels
+func Chart(cell int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, Columns*cell, Rows*cell))
+	for i, p := range Patches {
+		r := cellRect(img.Rect, i)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.SetRGBA(x, y, p.Color)
+			}
+		}
+	}
+	return img
+}
+
+// Result is the measurement of one patch
+type Result struct {
+	Patch Patch
+
+	// Captured is the patch's average color in the frame
+	Captured color.RGBA
+
+	// DeltaE is the CIE76 difference between the captured and drawn colors
+	DeltaE float64
+}
+
+// Report is the measurement of every patch on the chart
+type Report struct {
+	// Chart is where the chart was found in the frame
+	Chart image.Rectangle
+
+	Results []Result
+}
+
+// Mean returns the average color difference across the patches
+func (r Report) Mean() float64 {
+	if len(r.Results) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, res := range r.Results {
+		sum += res.DeltaE
+	}
+	return sum / float64(len(r.Results))
+}
+
+// Worst returns the patch that drifted furthest
+func (r Report) Worst() Result {
+	var worst Result
+	for _, res := range r.Results {
+		if res.DeltaE > worst.DeltaE {
+			worst = res
+		}
+	}
+	return worst
+}
+
+// Passed reports whether every patch is within MaxDeltaE
+func (r Report) Passed() bool {
+	return r.Worst().DeltaE <= MaxDeltaE
+}
+
+// Measure finds the chart in img and compares each patch with the color
+// drawn. The chart is found by trimming the uniform background around it,
+// so img should show the chart on a plain background, such as the page
+// served by Server filling the screen. Only the middle of each patch is
+// measured, which tolerates blurred or slightly misaligned edges.
+func Measure(img image.Image) (Report, error) {
+	chart := analyze.BorderCrop([]image.Image{img}, analyze.DefaultTolerance)
+	if chart.Dx() < Columns*minCell || chart.Dy() < Rows*minCell {
+		return Report{}, fmt.Errorf("%w: found only %dx%d pixels of content", ErrChartNotFound, chart.Dx(), chart.Dy())
+	}
+	aspect := float64(chart.Dx()*Rows) / float64(chart.Dy()*Columns)
+	if aspect < 0.9 || aspect > 1.1 {
+		return Report{}, fmt.Errorf("%w: content is %dx%d, but the chart is %d:%d", ErrChartNotFound, chart.Dx(), chart.Dy(), Columns, Rows)
+	}
+
+	report := Report{Chart: chart}
+	for i, p := range Patches {
+		r := cellRect(chart, i)
+		inner := r.Inset(min(r.Dx(), r.Dy()) / 4)
+		captured := average(img, inner)
+		report.Results = append(report.Results, Result{
+			Patch:    p,
+			Captured: captured,
+			DeltaE:   DeltaE(p.Color, captured),
+		})
+	}
+	return report, nil
+}
+
+// cellRect returns the cell of patch i within a chart drawn in bounds
+func cellRect(bounds image.Rectangle, i int) image.Rectangle {
+	col, row := i%Columns, i/Columns
+	return image.Rect(
+		bounds.Min.X+col*bounds.Dx()/Columns,
+		bounds.Min.Y+row*bounds.Dy()/Rows,
+		bounds.Min.X+(col+1)*bounds.Dx()/Columns,
+		bounds.Min.Y+(row+1)*bounds.Dy()/Rows,
+	)
+}
+
+// average returns the mean color of the pixels of img within r
+func average(img image.Image, r image.Rectangle) color.RGBA {
+	var sr, sg, sb, n int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := analyze.RGBAAt(img, x, y)
+			sr += int(c.R)
+			sg += int(c.G)
+			sb += int(c.B)
+			n++
+		}
+	}
+	if n == 0 {
+		return color.RGBA{}
+	}
+	return color.RGBA{uint8((sr + n/2) / n), uint8((sg + n/2) / n), uint8((sb + n/2) / n), 255}
+}
+
+// DeltaE returns the CIE76 color difference between two sRGB colors: their
+// distance in CIELAB. A difference of 1 is about the smallest visible.
+func DeltaE(a, b color.RGBA) float64 {
+	l1, a1, b1 := lab(a)
+	l2, a2, b2 := lab(b)
+	return math.Sqrt((l1-l2)*(l1-l2) + (a1-a2)*(a1-a2) + (b1-b2)*(b1-b2))
+}
+
+// lab converts an sRGB color to CIELAB with a D65 white point
+func lab(c color.RGBA) (l, a, b float64) {
+	r, g, bl := linear(c.R), linear(c.G), linear(c.B)
+	x := (0.4124*r + 0.3576*g + 0.1805*bl) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*bl
+	z := (0.0193*r + 0.1192*g + 0.9505*bl) / 1.08883
+
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// linear undoes the sRGB transfer curve
+func linear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// labF is the CIELAB companding function
+func labF(t float64) float64 {
+	const e = 216.0 / 24389
+	if t > e {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27*t + 16) / 116
+}
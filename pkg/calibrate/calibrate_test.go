@@ -0,0 +1,165 @@
+package calibrate
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Helper function to create a capture of the chart drawn at offset on a
+// black screen, with each captured pixel passed through adjust
+func screenWithChart(offset image.Point, cell int, adjust func(color.RGBA) color.RGBA) *image.RGBA {
+	chart := Chart(cell)
+	screen := image.NewRGBA(image.Rect(0, 0, chart.Rect.Dx()+2*offset.X, chart.Rect.Dy()+2*offset.Y))
+	draw.Draw(screen, screen.Rect, image.NewUniform(color.RGBA{A: 255}), image.Point{}, draw.Src)
+	draw.Draw(screen, chart.Rect.Add(offset), chart, image.Point{}, draw.Src)
+	if adjust != nil {
+		for y := 0; y < screen.Rect.Dy(); y++ {
+			for x := 0; x < screen.Rect.Dx(); x++ {
+				screen.SetRGBA(x, y, adjust(screen.RGBAAt(x, y)))
+			}
+		}
+	}
+	return screen
+}
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		name    string
+		adjust  func(color.RGBA) color.RGBA
+		maxMean float64
+		passed  bool
+	}{
+		{
+			name:    "exact capture",
+			maxMean: 0,
+			passed:  true,
+		},
+		{
+			name: "rounding noise",
+			adjust: func(c color.RGBA) color.RGBA {
+				return color.RGBA{c.R, c.G + 1, c.B, 255}
+			},
+			maxMean: 1,
+			passed:  true,
+		},
+		{
+			name: "blue cast",
+			adjust: func(c color.RGBA) color.RGBA {
+				return color.RGBA{uint8(float64(c.R) * 0.8), uint8(float64(c.G) * 0.9), c.B, 255}
+			},
+			maxMean: 100,
+			passed:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Measure(screenWithChart(image.Pt(30, 20), 16, tt.adjust))
+			if err != nil {
+				t.Fatalf("Measure() failed: %v", err)
+			}
+			if want := image.Rect(30, 20, 126, 84); report.Chart != want {
+				t.Errorf("Chart = %v, want %v", report.Chart, want)
+			}
+			if len(report.Results) != len(Patches) {
+				t.Fatalf("measured %d patches, want %d", len(report.Results), len(Patches))
+			}
+			if mean := report.Mean(); mean > tt.maxMean {
+				t.Errorf("Mean() = %.2f, want at most %.2f", mean, tt.maxMean)
+			}
+			if report.Passed() != tt.passed {
+				t.Errorf("Passed() = %v, want %v (worst %s at %.2f)", report.Passed(), tt.passed, report.Worst().Patch.Name, report.Worst().DeltaE)
+			}
+		})
+	}
+}
+
+func TestMeasureIgnoresPatchEdges(t *testing.T) {
+	// Blur the patch edges into their neighbors, as scaling does
+	screen := screenWithChart(image.Pt(10, 10), 20, nil)
+	for i := range Patches {
+		r := cellRect(image.Rect(10, 10, 130, 90), i)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			screen.SetRGBA(x, r.Min.Y+1, color.RGBA{255, 0, 255, 255})
+		}
+	}
+
+	report, err := Measure(screen)
+	if err != nil {
+		t.Fatalf("Measure() failed: %v", err)
+	}
+	if mean := report.Mean(); mean != 0 {
+		t.Errorf("Mean() = %.2f, want edges left out of the measurement", mean)
+	}
+}
+
+func TestMeasureChartNotFound(t *testing.T) {
+	// Part of the chart squeezed into a wide strip, as when it is covered
+	wide := image.NewRGBA(image.Rect(0, 0, 300, 40))
+	draw.Draw(wide, image.Rect(10, 10, 290, 30), Chart(16), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"blank screen", image.NewRGBA(image.Rect(0, 0, 200, 100))},
+		{"chart too small", screenWithChart(image.Pt(20, 20), 2, nil)},
+		{"wrong shape", wide},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Measure(tt.img); !errors.Is(err, ErrChartNotFound) {
+				t.Errorf("Measure() error = %v, want ErrChartNotFound", err)
+			}
+		})
+	}
+}
+
+func TestDeltaE(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b color.RGBA
+		want float64
+	}{
+		{"identical", color.RGBA{120, 80, 40, 255}, color.RGBA{120, 80, 40, 255}, 0},
+		{"black to white", color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}, 100},
+		{"red to black", color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 0, 255}, 117.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeltaE(tt.a, tt.b); math.Abs(got-tt.want) > 0.1 {
+				t.Errorf("DeltaE() = %.2f, want %.1f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerPage(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	for _, p := range Patches {
+		hex := fmt.Sprintf("#%02x%02x%02x", p.Color.R, p.Color.G, p.Color.B)
+		if !strings.Contains(string(body), hex) {
+			t.Errorf("page is missing the %s patch %s", p.Name, hex)
+		}
+	}
+}
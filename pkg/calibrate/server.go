@@ -0,0 +1,70 @@
+package calibrate
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+// DefaultAddr is the address the chart is served on by default. It only
+// accepts local connections.
+const DefaultAddr = "127.0.0.1:7422"
+
+// Server serves a page showing the chart on a black background. Clicking
+// the page makes it fill the screen, so a capture of the whole display
+// shows nothing but the chart and its background.
+type Server struct {
+	mux *http.ServeMux
+}
+
+// NewServer creates a chart server
+func NewServer() *Server {
+	s := &Server{mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /{$}", s.handlePage)
+	return s
+}
+
+// ServeHTTP routes chart requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handlePage serves the chart
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	colors := make([]template.CSS, len(Patches))
+	for i, p := range Patches {
+		colors[i] = template.CSS(fmt.Sprintf("#%02x%02x%02x", p.Color.R, p.Color.G, p.Color.B))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	page.Execute(w, colors)
+}
+
+// page is the chart, given the patch colors. The chart keeps the grid's
+// aspect ratio at the largest size that fits the window, with no gaps
+// between patches, and the pointer is hidden over it.
+var page = template.Must(template.New("calibrate").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>witness calibrate</title>
+<style>
+html, body { margin: 0; height: 100%; background: #000; cursor: none; }
+body { display: flex; align-items: center; justify-content: center; }
+#chart { display: grid; grid-template-columns: repeat(6, 1fr); width: min(80vw, 120vh); aspect-ratio: 3 / 2; }
+#chart div { width: 100%; height: 100%; }
+</style>
+</head>
+<body>
+<div id="chart">
+{{range .}}<div style="background: {{.}}"></div>
+{{end}}</div>
+<script>
+document.body.onclick = () => {
+  if (document.fullscreenElement) document.exitFullscreen();
+  else document.documentElement.requestFullscreen();
+};
+</script>
+</body>
+</html>
+`))
@@ -1,6 +1,7 @@
 package capture
 
 import (
+	"fmt"
 	"image"
 	"time"
 )
@@ -18,17 +19,147 @@ type Config struct {
 	// Region to capture. If nil, captures full screen
 	Region *Region
 
-	// Target frames per second
-	FPS int
+	// Window to capture instead of a region. Frames keep the size of the
+	// window when recording started.
+	Window *WindowTarget
+
+	// Exclude lists windows to leave out of a display or region capture,
+	// such as password managers, showing what is behind them instead. A
+	// title excludes every window whose title or application name contains
+	// it; matching windows are found whenever capture starts. Supported on
+	// macOS 12.3 and later.
+	Exclude []WindowTarget
+
+	// App limits a display or region capture to the windows of
+	// applications whose name contains App, ignoring case; the rest of the
+	// screen is black. Unlike Window,
+	// it covers every window of the application, including ones opened
+	// while recording. Supported on macOS 12.3 and later.
+	App string
+
+	// Target frame rate
+	FPS FPS
 
 	// Display ID (for multi-monitor setups). 0 for main display
 	DisplayID uint32
+
+	// Display picks the display by ID, UUID, or name instead of DisplayID,
+	// e.g. "DELL U2720Q" (see MatchDisplay). Display IDs can change when
+	// displays are plugged in or removed, while UUIDs and names do not.
+	Display string
+
+	// IncludeCursor draws the mouse pointer into frames
+	IncludeCursor bool
+
+	// ScaleMode sets the frame pixels captured per point on HiDPI
+	// displays. The zero value, ScalePhysical, keeps every physical pixel.
+	ScaleMode ScaleMode
+
+	// Scale resizes frames by this factor as they are captured, e.g. 0.5 to
+	// halve a 4K display before frames reach the recorder. 0 or 1 keeps the
+	// captured size; only factors below 1 are applied.
+	Scale float64
+
+	// MaxDuration stops the capture this long after Start. 0 means no limit.
+	MaxDuration time.Duration
+
+	// MaxFrames stops the capture once this many frames have been
+	// delivered. 0 means no limit.
+	MaxFrames int
 }
 
 // Frame represents a single captured frame
 type Frame struct {
+	// Image holds the frame's pixels. It is nil on frames whose pixels are
+	// still held by the system until Load is called.
 	Image     *image.RGBA
 	Timestamp time.Time
+
+	// Discontinuity marks the first frame after a capture interruption
+	Discontinuity bool
+
+	// pending holds pixels not yet converted into Image
+	pending pendingPixels
+}
+
+// pendingPixels are a frame's pixels still held outside Go memory, such as
+// the IOSurface a macOS display stream drew into. They are converted to
+// RGBA only when the frame is loaded, so frames dropped before encoding are
+// never copied.
+type pendingPixels interface {
+	// rgba converts the pixels into a new image
+	rgba() (*image.RGBA, error)
+
+	// release hands the pixels back to the system. It may be called more
+	// than once.
+	release()
+}
+
+// Load converts pixels still held by the system into Image. Consumers of a
+// capturer's Frames channel call it before reading Image; it does nothing
+// for frames that already have one.
+func (f *Frame) Load() error {
+	if f.pending == nil {
+		return nil
+	}
+	p := f.pending
+	f.pending = nil
+	defer p.release()
+
+	img, err := p.rgba()
+	if err != nil {
+		return err
+	}
+	f.Image = img
+	return nil
+}
+
+// Release hands back pixels still held by the system without converting
+// them, for frames dropped before Load. Frames that are neither loaded nor
+// released give their pixels back when garbage collected, which can hold up
+// the capture until then.
+func (f *Frame) Release() {
+	if f.pending != nil {
+		f.pending.release()
+		f.pending = nil
+	}
+}
+
+// Epoch is the fixed start time given to frames in deterministic mode, so
+// identical input produces identical timestamps on every run
+var Epoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// State describes the lifecycle state of a capturer
+type State int
+
+const (
+	// StateIdle means the capturer has not been started or has fully stopped
+	StateIdle State = iota
+	// StateRunning means the capturer is producing frames
+	StateRunning
+	// StateStopping means Stop has been called and is waiting for the
+	// capturer's goroutines to finish. Capturers release their lock
+	// meanwhile, so State reports it until Stop returns.
+	StateStopping
+	// StatePaused means the capturer is running but holding back frames
+	// until Resume is called
+	StatePaused
+)
+
+// String returns a human-readable name for the state
+func (s State) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StatePaused:
+		return "paused"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
 }
 
 // Capturer is the interface for screen capture implementations
@@ -39,16 +170,52 @@ type Capturer interface {
 	// Stop ends the capture process
 	Stop() error
 
+	// Pause stops delivering frames, without ending the capture, until
+	// Resume is called. The first frame after Resume is marked as a
+	// discontinuity.
+	Pause() error
+
+	// Resume delivers frames again after Pause
+	Resume() error
+
 	// Frames returns a channel that receives captured frames
 	Frames() <-chan *Frame
 
 	// Errors returns a channel for capture errors
 	Errors() <-chan error
+
+	// IsRunning reports whether the capturer has been started and not
+	// stopped, including while it is paused
+	IsRunning() bool
+
+	// State returns the current lifecycle state
+	State() State
+
+	// Stats returns the frames captured and dropped, average capture
+	// latency, and effective frame rate since Start
+	Stats() Stats
 }
 
 // NewCapturer creates a platform-specific capturer
 // This will be implemented per platform (macOS, Linux, etc.)
 func NewCapturer(config Config) (Capturer, error) {
+	// Resolved each time, so a recorder re-attaching after a display was
+	// reconnected finds it again under a new ID
+	if config.Display != "" {
+		id, err := ResolveDisplay(config.Display)
+		if err != nil {
+			return nil, err
+		}
+		config.DisplayID, config.Display = id, ""
+	}
+
 	// Platform-specific implementation will be called here
 	return newPlatformCapturer(config)
 }
+
+// ActiveSpace returns an identifier for the currently active Space (virtual
+// desktop). Only the active Space can be captured, so recorders compare this
+// against the Space they started on.
+func ActiveSpace() (uint64, error) {
+	return platformActiveSpace()
+}
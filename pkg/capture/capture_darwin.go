@@ -1,12 +1,205 @@
+//go:build darwin
 // +build darwin
 
 package capture
 
 import (
+	"errors"
+	"fmt"
+	"image"
+	"os/exec"
+
 	"github.com/ericmhalvorsen/witness/internal/macos"
 )
 
 // newPlatformCapturer creates a macOS-specific capturer
 func newPlatformCapturer(config Config) (Capturer, error) {
-	return macos.NewDisplayCapturer(config)
+	if err := platformCheckPermission(); err != nil {
+		return nil, err
+	}
+	if config.Window != nil {
+		id, err := config.Window.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		return newWindowCapturer(id, config)
+	}
+	var exclude []uint32
+	if len(config.Exclude) > 0 || config.App != "" {
+		windows, err := ListWindows()
+		if err != nil {
+			return nil, err
+		}
+		if config.App != "" {
+			if _, ok := MatchApplication(windows, config.App); !ok {
+				return nil, fmt.Errorf("no windows of application %q are open: %w", config.App, ErrWindowNotFound)
+			}
+		}
+		exclude = MatchWindows(windows, config.Exclude)
+	}
+	return newDisplayCapturer(config, exclude)
+}
+
+// platformActiveSpace returns the active macOS Space
+func platformActiveSpace() (uint64, error) {
+	space, err := macos.ActiveSpace()
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", err, ErrStreamInterrupted)
+	}
+	return space, nil
+}
+
+// platformLookupWindow returns the state of a macOS window
+func platformLookupWindow(id uint32) (Window, error) {
+	w, err := macos.LookupWindow(id)
+	if errors.Is(err, macos.ErrWindowNotFound) {
+		return Window{}, fmt.Errorf("window %d: %w", id, ErrWindowNotFound)
+	}
+	if err != nil {
+		return Window{}, err
+	}
+	return windowFromMacOS(w), nil
+}
+
+// platformListWindows returns the on-screen macOS windows
+func platformListWindows() ([]Window, error) {
+	found, err := macos.ListWindows()
+	if err != nil {
+		return nil, err
+	}
+	windows := make([]Window, 0, len(found))
+	for _, w := range found {
+		windows = append(windows, windowFromMacOS(w))
+	}
+	return windows, nil
+}
+
+// windowFromMacOS converts a window description from the macos package
+func windowFromMacOS(w macos.Window) Window {
+	window := Window{
+		ID:       w.ID,
+		Bounds:   Region(w.Bounds),
+		OnScreen: w.OnScreen,
+		Title:    w.Title,
+		Owner:    w.Owner,
+	}
+	for _, above := range w.Above {
+		window.Above = append(window.Above, Region(above))
+	}
+	return window
+}
+
+// platformListDisplays returns the active macOS displays
+func platformListDisplays() ([]DisplayInfo, error) {
+	found, err := macos.ListDisplays()
+	if err != nil {
+		return nil, err
+	}
+	displays := make([]DisplayInfo, 0, len(found))
+	for _, d := range found {
+		displays = append(displays, DisplayInfo{
+			Display: displayFromMacOS(d),
+			Name:    d.Name,
+			UUID:    d.UUID,
+			Main:    d.Main,
+		})
+	}
+	return displays, nil
+}
+
+// displayFromMacOS converts a display's geometry from the macos package
+func displayFromMacOS(d macos.DisplayInfo) Display {
+	return Display{
+		ID:          d.ID,
+		Bounds:      Region(d.Bounds),
+		ScaleFactor: d.ScaleFactor,
+	}
+}
+
+// platformWatchClicks watches clicks with a macOS event tap
+func platformWatchClicks() (ClickWatcher, error) {
+	tap, err := macos.WatchClicks()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrPermissionDenied)
+	}
+	return newClickTap(tap), nil
+}
+
+// clickTap adapts a macOS event tap to ClickWatcher
+type clickTap struct {
+	tap    *macos.ClickTap
+	clicks chan Click
+	done   chan struct{} // Closed once clicks is closed
+}
+
+// newClickTap forwards the clicks tap sees until it stops
+func newClickTap(tap *macos.ClickTap) *clickTap {
+	t := &clickTap{
+		tap:    tap,
+		clicks: make(chan Click, cap(tap.Clicks())),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(t.done)
+		defer close(t.clicks)
+		for c := range tap.Clicks() {
+			// Dropped when nobody is reading, as the tap does
+			select {
+			case t.clicks <- Click(c):
+			default:
+			}
+		}
+	}()
+	return t
+}
+
+// Clicks returns the channel clicks arrive on
+func (t *clickTap) Clicks() <-chan Click {
+	return t.clicks
+}
+
+// Stop removes the event tap, closing Clicks
+func (t *clickTap) Stop() error {
+	if err := t.tap.Stop(); errors.Is(err, macos.ErrStopped) {
+		return ErrNotRunning
+	} else if err != nil {
+		return err
+	}
+	<-t.done
+	return nil
+}
+
+// platformPointerPosition reads the pointer position from a new macOS event
+func platformPointerPosition() (image.Point, error) {
+	return macos.PointerPosition()
+}
+
+// platformCurrentSession returns the state of the macOS login session
+func platformCurrentSession() (Session, error) {
+	session, err := macos.CurrentSession()
+	if err != nil {
+		return Session{}, fmt.Errorf("%v: %w", err, ErrStreamInterrupted)
+	}
+	return Session(session), nil
+}
+
+// platformCheckPermission checks for Screen Recording permission
+func platformCheckPermission() error {
+	if !macos.ScreenCaptureAllowed() {
+		return fmt.Errorf("%w: allow your terminal under System Settings > Privacy & Security > Screen Recording, then restart it",
+			ErrPermissionDenied)
+	}
+	return nil
+}
+
+// platformRequestPermission prompts for Screen Recording permission and
+// opens its settings pane if it is still missing
+func platformRequestPermission() error {
+	if macos.RequestScreenCaptureAccess() {
+		return nil
+	}
+	if err := exec.Command("open", ScreenRecordingSettingsURL).Run(); err != nil {
+		return fmt.Errorf("failed to open System Settings: %w", err)
+	}
+	return nil
 }
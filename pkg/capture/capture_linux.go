@@ -0,0 +1,315 @@
+//go:build linux
+// +build linux
+
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/internal/wayland"
+)
+
+// newPlatformCapturer creates a capturer for Wayland sessions. X11 sessions
+// are not supported yet.
+func newPlatformCapturer(config Config) (Capturer, error) {
+	if os.Getenv("WAYLAND_DISPLAY") == "" {
+		return nil, fmt.Errorf("no Wayland session found: %w", ErrUnsupportedPlatform)
+	}
+	if config.Window != nil {
+		return nil, fmt.Errorf("recording a single window is not supported on Wayland; use a region instead")
+	}
+	if len(config.Exclude) > 0 || config.App != "" {
+		return nil, fmt.Errorf("excluding windows or recording one application is not supported on Wayland")
+	}
+	return newWaylandCapturer(config), nil
+}
+
+// platformActiveSpace returns an error; Wayland does not expose workspaces
+func platformActiveSpace() (uint64, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+// platformLookupWindow returns an error; Wayland does not expose windows
+func platformLookupWindow(id uint32) (Window, error) {
+	return Window{}, ErrUnsupportedPlatform
+}
+
+// platformListWindows returns an error; Wayland does not expose windows
+func platformListWindows() ([]Window, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// platformListDisplays returns an error; Wayland only shares displays
+// through the portal's picker
+func platformListDisplays() ([]DisplayInfo, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// platformWatchClicks returns an error; Wayland does not let clients see
+// input meant for other windows
+func platformWatchClicks() (ClickWatcher, error) {
+	return nil, fmt.Errorf("watching mouse clicks is not supported on Wayland")
+}
+
+// platformPointerPosition returns an error; Wayland does not let clients
+// see the pointer outside their own windows
+func platformPointerPosition() (image.Point, error) {
+	return image.Point{}, fmt.Errorf("reading the pointer position is not supported on Wayland")
+}
+
+// platformCheckPermission passes; the portal asks for permission each time
+// a screencast starts
+func platformCheckPermission() error {
+	return nil
+}
+
+// platformRequestPermission returns an error; Wayland has no lasting
+// screen recording permission to request
+func platformRequestPermission() error {
+	return fmt.Errorf("the screencast portal asks for permission each time recording starts: %w", ErrUnsupportedPlatform)
+}
+
+// platformCurrentSession returns an error on Linux
+func platformCurrentSession() (Session, error) {
+	return Session{}, ErrUnsupportedPlatform
+}
+
+// waylandCapturer captures a monitor shared through the xdg-desktop-portal.
+// Start shows the portal's dialog, so it blocks until the user picks a
+// monitor.
+type waylandCapturer struct {
+	config   Config
+	session  *wayland.Session
+	reader   *wayland.FrameReader
+	crop     image.Rectangle
+	frames   chan *Frame
+	errors   chan error
+	stopChan chan struct{}
+	done     chan struct{}
+	state    State
+	mu       sync.Mutex
+	pause    PauseGate
+	stats    StatsCounter
+	limit    Limit
+}
+
+// newWaylandCapturer creates a capturer that starts a portal session on Start
+func newWaylandCapturer(config Config) *waylandCapturer {
+	return &waylandCapturer{
+		config:   config,
+		frames:   make(chan *Frame, 30),
+		errors:   make(chan error, 10),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start opens a screencast session and begins reading frames
+func (w *waylandCapturer) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != StateIdle {
+		return ErrAlreadyRunning
+	}
+
+	session, err := wayland.OpenScreenCast(w.config.IncludeCursor)
+	if errors.Is(err, wayland.ErrCancelled) {
+		return fmt.Errorf("%v: %w", err, ErrPermissionDenied)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start screencast: %w", err)
+	}
+
+	// Portal streams measure the monitor in logical pixels, so physical
+	// and logical frames match; GStreamer resizes for other factors
+	stream := session.Streams[0]
+	display := Display{
+		Bounds:      Region{X: stream.X, Y: stream.Y, Width: stream.Width, Height: stream.Height},
+		ScaleFactor: 1,
+	}.Scaled(w.config.ScaleMode)
+	stream.Width, stream.Height = display.PixelSize()
+	w.crop = image.Rect(0, 0, stream.Width, stream.Height)
+	if w.config.Region != nil {
+		local, err := display.GlobalToLocal(*w.config.Region)
+		if err != nil {
+			session.Close()
+			return err
+		}
+		w.crop = image.Rect(local.X, local.Y, local.X+local.Width, local.Y+local.Height)
+	}
+
+	fps := w.config.FPS
+	if !fps.Valid() {
+		fps = FPS15
+	}
+	reader, err := session.Record(stream, fps.Num, fps.Den)
+	if err != nil {
+		session.Close()
+		return err
+	}
+
+	w.session = session
+	w.reader = reader
+	w.state = StateRunning
+	w.stats.Start()
+	w.limit.Start(w.config)
+	go w.captureLoop()
+
+	return nil
+}
+
+// Stop ends the screencast session
+func (w *waylandCapturer) Stop() error {
+	w.mu.Lock()
+	if w.state != StateRunning {
+		w.mu.Unlock()
+		return ErrNotRunning
+	}
+	w.state = StateStopping
+	w.mu.Unlock()
+
+	close(w.stopChan)
+	w.reader.Close()
+	<-w.done
+	w.session.Close()
+	w.stats.Stop()
+
+	w.mu.Lock()
+	w.state = StateIdle
+	w.mu.Unlock()
+
+	return nil
+}
+
+// Pause holds back frames until Resume is called
+func (w *waylandCapturer) Pause() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != StateRunning {
+		return ErrNotRunning
+	}
+	w.pause.Pause()
+
+	return nil
+}
+
+// Resume delivers frames again after Pause
+func (w *waylandCapturer) Resume() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != StateRunning {
+		return ErrNotRunning
+	}
+	w.pause.Resume()
+
+	return nil
+}
+
+// Stats returns the capture statistics since Start
+func (w *waylandCapturer) Stats() Stats {
+	return w.stats.Stats()
+}
+
+// Frames returns the channel for captured frames
+func (w *waylandCapturer) Frames() <-chan *Frame {
+	return w.frames
+}
+
+// Errors returns the channel for errors
+func (w *waylandCapturer) Errors() <-chan error {
+	return w.errors
+}
+
+// IsRunning returns whether the capturer is currently running
+func (w *waylandCapturer) IsRunning() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state == StateRunning
+}
+
+// State returns the current lifecycle state
+func (w *waylandCapturer) State() State {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.state == StateRunning && w.pause.Paused() {
+		return StatePaused
+	}
+	return w.state
+}
+
+// captureLoop forwards frames from the stream until it stops
+func (w *waylandCapturer) captureLoop() {
+	defer close(w.done)
+	defer close(w.frames)
+	defer close(w.errors)
+
+	for {
+		if err := w.limit.Reached(); err != nil {
+			select {
+			case w.errors <- err:
+			case <-w.stopChan:
+			}
+			return
+		}
+
+		grabbed := time.Now()
+		data, err := w.reader.ReadFrame()
+		if err != nil {
+			select {
+			case <-w.stopChan:
+			default:
+				w.errors <- fmt.Errorf("screencast stream ended: %v: %w", err, ErrStreamInterrupted)
+			}
+			return
+		}
+
+		// The stream keeps running while paused so it does not fall behind
+		if w.pause.Paused() {
+			continue
+		}
+		size := image.Pt(w.reader.Width, w.reader.Height)
+		if err := ValidateBuffer(size, 4*size.X, 4, len(data)); err != nil {
+			select {
+			case w.errors <- fmt.Errorf("screencast frame: %w", err):
+			case <-w.stopChan:
+			}
+			return
+		}
+		frame := &Frame{
+			Image:     w.config.Downscale(cropRGBA(data, w.reader.Width, w.reader.Height, w.crop)),
+			Timestamp: time.Now(),
+		}
+		w.stats.Captured(frame.Timestamp.Sub(grabbed))
+		if !w.pause.Admit(frame) {
+			w.stats.Dropped()
+			continue
+		}
+		select {
+		case w.frames <- frame:
+			w.limit.Delivered()
+		case <-w.stopChan:
+			w.stats.Dropped()
+			return
+		}
+	}
+}
+
+// cropRGBA copies the r portion of a tightly packed width x height RGBA
+// frame into a new image
+func cropRGBA(data []byte, width, height int, r image.Rectangle) *image.RGBA {
+	r = r.Intersect(image.Rect(0, 0, width, height))
+	img := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := 0; y < r.Dy(); y++ {
+		src := ((r.Min.Y+y)*width + r.Min.X) * 4
+		copy(img.Pix[y*img.Stride:(y+1)*img.Stride], data[src:src+4*r.Dx()])
+	}
+	return img
+}
@@ -0,0 +1,88 @@
+//go:build linux
+// +build linux
+
+package capture
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestNewCapturerWithoutWayland(t *testing.T) {
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	if _, err := NewCapturer(Config{FPS: FPS15}); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("NewCapturer() error = %v, want %v", err, ErrUnsupportedPlatform)
+	}
+}
+
+func TestPermissionOnWayland(t *testing.T) {
+	// The portal asks each time, so there is nothing to check or request
+	if err := CheckPermission(); err != nil {
+		t.Errorf("CheckPermission() error = %v, want nil", err)
+	}
+	if err := RequestPermission(); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("RequestPermission() error = %v, want %v", err, ErrUnsupportedPlatform)
+	}
+}
+
+func TestNewCapturerWayland(t *testing.T) {
+	t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+
+	c, err := NewCapturer(Config{FPS: FPS15})
+	if err != nil {
+		t.Fatalf("NewCapturer() failed: %v", err)
+	}
+	if c.State() != StateIdle {
+		t.Errorf("State() = %v, want %v", c.State(), StateIdle)
+	}
+	if err := c.Stop(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Stop() error = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+func TestNewCapturerWaylandExclude(t *testing.T) {
+	t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+
+	if _, err := NewCapturer(Config{FPS: FPS15, Exclude: []WindowTarget{{Title: "1Password"}}}); err == nil {
+		t.Error("NewCapturer() should reject -exclude on Wayland")
+	}
+	if _, err := NewCapturer(Config{FPS: FPS15, App: "Safari"}); err == nil {
+		t.Error("NewCapturer() should reject -app on Wayland")
+	}
+}
+
+func TestCropRGBA(t *testing.T) {
+	// A 4x3 frame whose red channel holds the pixel's index
+	data := make([]byte, 4*4*3)
+	for i := 0; i < 12; i++ {
+		data[4*i] = byte(i)
+		data[4*i+3] = 255
+	}
+
+	tests := []struct {
+		name string
+		rect image.Rectangle
+		want []byte // Red channel, row by row
+	}{
+		{name: "full frame", rect: image.Rect(0, 0, 4, 3), want: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{name: "interior", rect: image.Rect(1, 1, 3, 3), want: []byte{5, 6, 9, 10}},
+		{name: "clipped", rect: image.Rect(2, 2, 10, 10), want: []byte{10, 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := cropRGBA(data, 4, 3, tt.rect)
+			var got []byte
+			for y := 0; y < img.Bounds().Dy(); y++ {
+				for x := 0; x < img.Bounds().Dx(); x++ {
+					got = append(got, img.RGBAAt(x, y).R)
+				}
+			}
+			if string(got) != string(tt.want) {
+				t.Errorf("cropRGBA() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
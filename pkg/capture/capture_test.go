@@ -63,7 +63,7 @@ func TestConfig(t *testing.T) {
 			name: "default config",
 			config: Config{
 				Region:    nil,
-				FPS:       15,
+				FPS:       IntFPS(15),
 				DisplayID: 0,
 			},
 		},
@@ -76,7 +76,7 @@ func TestConfig(t *testing.T) {
 					Width:  800,
 					Height: 600,
 				},
-				FPS:       30,
+				FPS:       IntFPS(30),
 				DisplayID: 0,
 			},
 		},
@@ -84,7 +84,7 @@ func TestConfig(t *testing.T) {
 			name: "config with secondary display",
 			config: Config{
 				Region:    nil,
-				FPS:       24,
+				FPS:       IntFPS(24),
 				DisplayID: 1,
 			},
 		},
@@ -94,7 +94,7 @@ func TestConfig(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			c := tt.config
 
-			if c.FPS <= 0 {
+			if !c.FPS.Valid() {
 				t.Error("FPS should be positive")
 			}
 			if c.Region != nil {
@@ -106,6 +106,24 @@ func TestConfig(t *testing.T) {
 	}
 }
 
+func TestStateString(t *testing.T) {
+	tests := []struct {
+		state State
+		want  string
+	}{
+		{StateIdle, "idle"},
+		{StateRunning, "running"},
+		{StateStopping, "stopping"},
+		{State(42), "State(42)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.state.String(); got != tt.want {
+			t.Errorf("State(%d).String() = %q, want %q", int(tt.state), got, tt.want)
+		}
+	}
+}
+
 func TestFrame(t *testing.T) {
 	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
 	timestamp := time.Now()
@@ -141,18 +159,83 @@ func TestFrameTimestamp(t *testing.T) {
 	}
 }
 
+// fakePixels counts conversions and releases of pending frame pixels
+type fakePixels struct {
+	img      *image.RGBA
+	err      error
+	converts int
+	releases int
+}
+
+func (p *fakePixels) rgba() (*image.RGBA, error) {
+	p.converts++
+	return p.img, p.err
+}
+
+func (p *fakePixels) release() {
+	p.releases++
+}
+
+func TestFrameLoad(t *testing.T) {
+	pixels := &fakePixels{img: image.NewRGBA(image.Rect(0, 0, 4, 4))}
+	frame := &Frame{pending: pixels}
+
+	if err := frame.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if frame.Image != pixels.img {
+		t.Error("Load() did not set Image to the converted pixels")
+	}
+	if err := frame.Load(); err != nil {
+		t.Fatalf("second Load() error = %v", err)
+	}
+	frame.Release()
+	if pixels.converts != 1 || pixels.releases != 1 {
+		t.Errorf("converted %d and released %d times, want 1 and 1", pixels.converts, pixels.releases)
+	}
+}
+
+func TestFrameLoadError(t *testing.T) {
+	pixels := &fakePixels{err: ErrCorruptFrame}
+	frame := &Frame{pending: pixels}
+
+	if err := frame.Load(); err != ErrCorruptFrame {
+		t.Errorf("Load() error = %v, want %v", err, ErrCorruptFrame)
+	}
+	if frame.Image != nil {
+		t.Error("Load() set Image despite the error")
+	}
+	if pixels.releases != 1 {
+		t.Errorf("released %d times, want 1", pixels.releases)
+	}
+}
+
+func TestFrameRelease(t *testing.T) {
+	pixels := &fakePixels{}
+	frame := &Frame{pending: pixels}
+
+	frame.Release()
+	frame.Release()
+	if err := frame.Load(); err != nil {
+		t.Fatalf("Load() after Release error = %v", err)
+	}
+	if pixels.converts != 0 || pixels.releases != 1 {
+		t.Errorf("converted %d and released %d times, want 0 and 1", pixels.converts, pixels.releases)
+	}
+}
+
 func TestConfigWithNilRegion(t *testing.T) {
 	config := Config{
 		Region:    nil, // Full screen capture
-		FPS:       15,
+		FPS:       IntFPS(15),
 		DisplayID: 0,
 	}
 
 	if config.Region != nil {
 		t.Error("Region should be nil for full screen capture")
 	}
-	if config.FPS != 15 {
-		t.Errorf("FPS = %d, want 15", config.FPS)
+	if config.FPS != IntFPS(15) {
+		t.Errorf("FPS = %v, want 15", config.FPS)
 	}
 	if config.DisplayID != 0 {
 		t.Errorf("DisplayID = %d, want 0", config.DisplayID)
@@ -169,7 +252,7 @@ func TestConfigWithRegion(t *testing.T) {
 
 	config := Config{
 		Region:    region,
-		FPS:       30,
+		FPS:       IntFPS(30),
 		DisplayID: 0,
 	}
 
@@ -206,3 +289,20 @@ func TestMultipleFramesSequence(t *testing.T) {
 		lastTimestamp = frame.Timestamp
 	}
 }
+
+func TestSessionCapturable(t *testing.T) {
+	tests := []struct {
+		session Session
+		want    bool
+	}{
+		{Session{OnConsole: true}, true},
+		{Session{OnConsole: true, Locked: true}, false},
+		{Session{OnConsole: false}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.session.Capturable(); got != tt.want {
+			t.Errorf("%+v.Capturable() = %v, want %v", tt.session, got, tt.want)
+		}
+	}
+}
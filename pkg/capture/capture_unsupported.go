@@ -1,10 +1,56 @@
-// +build !darwin
+//go:build !darwin && !linux
+// +build !darwin,!linux
 
 package capture
 
-import "fmt"
+import "image"
 
 // newPlatformCapturer returns an error on unsupported platforms
 func newPlatformCapturer(config Config) (Capturer, error) {
-	return nil, fmt.Errorf("screen capture is not supported on this platform (only macOS is currently supported)")
+	return nil, ErrUnsupportedPlatform
+}
+
+// platformActiveSpace returns an error on unsupported platforms
+func platformActiveSpace() (uint64, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+// platformLookupWindow returns an error on unsupported platforms
+func platformLookupWindow(id uint32) (Window, error) {
+	return Window{}, ErrUnsupportedPlatform
+}
+
+// platformListWindows returns an error on unsupported platforms
+func platformListWindows() ([]Window, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// platformListDisplays returns an error on unsupported platforms
+func platformListDisplays() ([]DisplayInfo, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// platformWatchClicks returns an error on unsupported platforms
+func platformWatchClicks() (ClickWatcher, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// platformPointerPosition returns an error on unsupported platforms
+func platformPointerPosition() (image.Point, error) {
+	return image.Point{}, ErrUnsupportedPlatform
+}
+
+// platformCurrentSession returns an error on unsupported platforms
+func platformCurrentSession() (Session, error) {
+	return Session{}, ErrUnsupportedPlatform
+}
+
+// platformCheckPermission returns an error on unsupported platforms
+func platformCheckPermission() error {
+	return ErrUnsupportedPlatform
+}
+
+// platformRequestPermission returns an error on unsupported platforms
+func platformRequestPermission() error {
+	return ErrUnsupportedPlatform
 }
@@ -0,0 +1,35 @@
+package capture
+
+import (
+	"image"
+	"time"
+)
+
+// Click is a mouse button press anywhere on screen
+type Click struct {
+	// X and Y are the pointer position in global points
+	X, Y int
+
+	// Time is when the button was pressed
+	Time time.Time
+}
+
+// ClickWatcher reports mouse clicks while recording
+type ClickWatcher interface {
+	// Clicks returns the channel clicks arrive on. It is closed by Stop.
+	Clicks() <-chan Click
+
+	// Stop stops watching for clicks
+	Stop() error
+}
+
+// WatchClicks starts watching for mouse clicks. On macOS this needs the
+// Input Monitoring permission.
+func WatchClicks() (ClickWatcher, error) {
+	return platformWatchClicks()
+}
+
+// PointerPosition returns the mouse pointer position in global points
+func PointerPosition() (image.Point, error) {
+	return platformPointerPosition()
+}
@@ -0,0 +1,151 @@
+package capture
+
+import (
+	"fmt"
+	"math"
+)
+
+// Coordinate spaces
+//
+// Regions passed around Witness (saved regions, Config.Region, selector
+// output) are always expressed in global points using the Core Graphics
+// convention: the origin is the top-left corner of the primary display and
+// Y grows downwards. Secondary displays may have negative coordinates.
+//
+// Capturers work in display-local pixels: the origin is the top-left corner
+// of the captured display and one unit is one physical pixel, so on a Retina
+// display a 100x100 point region is 200x200 pixels.
+//
+// Cocoa (NSScreen, NSWindow) uses a bottom-left origin with Y growing
+// upwards. Use CocoaToCG and CGToCocoa to translate between the two.
+
+// Display describes a display's placement in the global coordinate space
+type Display struct {
+	// ID is the platform display identifier
+	ID uint32
+
+	// Bounds is the display rectangle in global points
+	Bounds Region
+
+	// ScaleFactor is the number of pixels per point (2 on Retina displays)
+	ScaleFactor float64
+}
+
+// scale returns the display's scale factor, treating unset values as 1
+func (d Display) scale() float64 {
+	if d.ScaleFactor <= 0 {
+		return 1
+	}
+	return d.ScaleFactor
+}
+
+// PixelSize returns the display dimensions in pixels
+func (d Display) PixelSize() (width, height int) {
+	s := d.scale()
+	return int(math.Round(float64(d.Bounds.Width) * s)), int(math.Round(float64(d.Bounds.Height) * s))
+}
+
+// Contains reports whether the region lies entirely within the display
+func (d Display) Contains(r Region) bool {
+	return r.X >= d.Bounds.X && r.Y >= d.Bounds.Y &&
+		r.X+r.Width <= d.Bounds.X+d.Bounds.Width &&
+		r.Y+r.Height <= d.Bounds.Y+d.Bounds.Height
+}
+
+// GlobalToLocal converts a region in global points to display-local pixels.
+// The region is clipped to the display; an error is returned if it does not
+// overlap the display at all.
+func (d Display) GlobalToLocal(r Region) (Region, error) {
+	clipped, ok := r.Intersect(d.Bounds)
+	if !ok {
+		return Region{}, fmt.Errorf("region %dx%d at (%d,%d) is outside display %d",
+			r.Width, r.Height, r.X, r.Y, d.ID)
+	}
+
+	s := d.scale()
+	x0 := math.Floor(float64(clipped.X-d.Bounds.X) * s)
+	y0 := math.Floor(float64(clipped.Y-d.Bounds.Y) * s)
+	x1 := math.Ceil(float64(clipped.X-d.Bounds.X+clipped.Width) * s)
+	y1 := math.Ceil(float64(clipped.Y-d.Bounds.Y+clipped.Height) * s)
+
+	return Region{
+		X:      int(x0),
+		Y:      int(y0),
+		Width:  int(x1 - x0),
+		Height: int(y1 - y0),
+	}, nil
+}
+
+// LocalToGlobal converts a region in display-local pixels to global points.
+// Fractional points are rounded outwards so the result covers the input.
+func (d Display) LocalToGlobal(r Region) Region {
+	s := d.scale()
+	return RegionFromPoints(
+		float64(d.Bounds.X)+float64(r.X)/s,
+		float64(d.Bounds.Y)+float64(r.Y)/s,
+		float64(r.Width)/s,
+		float64(r.Height)/s,
+	)
+}
+
+// DisplayForRegion returns the display that contains the largest part of the region
+func DisplayForRegion(displays []Display, r Region) (Display, bool) {
+	var best Display
+	bestArea := 0
+	for _, d := range displays {
+		overlap, ok := r.Intersect(d.Bounds)
+		if !ok {
+			continue
+		}
+		if area := overlap.Width * overlap.Height; area > bestArea {
+			best = d
+			bestArea = area
+		}
+	}
+	return best, bestArea > 0
+}
+
+// RegionFromPoints builds a region from fractional point coordinates,
+// rounding outwards so the region covers the whole requested area
+func RegionFromPoints(x, y, width, height float64) Region {
+	x0 := math.Floor(x)
+	y0 := math.Floor(y)
+	x1 := math.Ceil(x + width)
+	y1 := math.Ceil(y + height)
+
+	return Region{
+		X:      int(x0),
+		Y:      int(y0),
+		Width:  int(x1 - x0),
+		Height: int(y1 - y0),
+	}
+}
+
+// Intersect returns the overlap of two regions and whether they overlap
+func (r Region) Intersect(other Region) (Region, bool) {
+	x0 := max(r.X, other.X)
+	y0 := max(r.Y, other.Y)
+	x1 := min(r.X+r.Width, other.X+other.Width)
+	y1 := min(r.Y+r.Height, other.Y+other.Height)
+
+	if x1 <= x0 || y1 <= y0 {
+		return Region{}, false
+	}
+
+	return Region{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}, true
+}
+
+// CocoaToCG converts a region from Cocoa coordinates (bottom-left origin)
+// to Core Graphics coordinates (top-left origin). primaryHeight is the
+// height in points of the primary display.
+func CocoaToCG(r Region, primaryHeight int) Region {
+	r.Y = primaryHeight - r.Y - r.Height
+	return r
+}
+
+// CGToCocoa converts a region from Core Graphics coordinates (top-left
+// origin) to Cocoa coordinates (bottom-left origin). The transform is its
+// own inverse.
+func CGToCocoa(r Region, primaryHeight int) Region {
+	return CocoaToCG(r, primaryHeight)
+}
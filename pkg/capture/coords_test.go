@@ -0,0 +1,145 @@
+package capture
+
+import "testing"
+
+// Two displays side by side: a 1440x900pt Retina primary and a
+// 1920x1080 non-Retina secondary to its left
+var (
+	retinaDisplay = Display{
+		ID:          1,
+		Bounds:      Region{X: 0, Y: 0, Width: 1440, Height: 900},
+		ScaleFactor: 2,
+	}
+	externalDisplay = Display{
+		ID:          2,
+		Bounds:      Region{X: -1920, Y: -180, Width: 1920, Height: 1080},
+		ScaleFactor: 1,
+	}
+)
+
+func TestGlobalToLocal(t *testing.T) {
+	tests := []struct {
+		name    string
+		display Display
+		region  Region
+		want    Region
+		wantErr bool
+	}{
+		{
+			name:    "retina doubles pixels",
+			display: retinaDisplay,
+			region:  Region{X: 100, Y: 50, Width: 400, Height: 300},
+			want:    Region{X: 200, Y: 100, Width: 800, Height: 600},
+		},
+		{
+			name:    "secondary display with negative origin",
+			display: externalDisplay,
+			region:  Region{X: -1000, Y: 0, Width: 200, Height: 100},
+			want:    Region{X: 920, Y: 180, Width: 200, Height: 100},
+		},
+		{
+			name:    "region straddling displays is clipped",
+			display: retinaDisplay,
+			region:  Region{X: -100, Y: 0, Width: 200, Height: 100},
+			want:    Region{X: 0, Y: 0, Width: 200, Height: 200},
+		},
+		{
+			name:    "unset scale factor treated as 1",
+			display: Display{Bounds: Region{Width: 800, Height: 600}},
+			region:  Region{X: 10, Y: 20, Width: 30, Height: 40},
+			want:    Region{X: 10, Y: 20, Width: 30, Height: 40},
+		},
+		{
+			name:    "region outside display",
+			display: retinaDisplay,
+			region:  Region{X: 2000, Y: 0, Width: 100, Height: 100},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.display.GlobalToLocal(tt.region)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GlobalToLocal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("GlobalToLocal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalToGlobalRoundTrip(t *testing.T) {
+	for _, d := range []Display{retinaDisplay, externalDisplay} {
+		global := Region{X: d.Bounds.X + 10, Y: d.Bounds.Y + 20, Width: 300, Height: 200}
+
+		local, err := d.GlobalToLocal(global)
+		if err != nil {
+			t.Fatalf("GlobalToLocal() failed: %v", err)
+		}
+		if got := d.LocalToGlobal(local); got != global {
+			t.Errorf("display %d: round trip = %+v, want %+v", d.ID, got, global)
+		}
+	}
+}
+
+func TestLocalToGlobalOddPixels(t *testing.T) {
+	// A 3x3 pixel area on a Retina display covers 1.5x1.5 points
+	got := retinaDisplay.LocalToGlobal(Region{X: 1, Y: 1, Width: 3, Height: 3})
+	want := Region{X: 0, Y: 0, Width: 2, Height: 2}
+	if got != want {
+		t.Errorf("LocalToGlobal() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDisplayForRegion(t *testing.T) {
+	displays := []Display{retinaDisplay, externalDisplay}
+
+	d, ok := DisplayForRegion(displays, Region{X: -300, Y: 0, Width: 400, Height: 100})
+	if !ok || d.ID != externalDisplay.ID {
+		t.Errorf("DisplayForRegion() = %d, %v, want %d", d.ID, ok, externalDisplay.ID)
+	}
+
+	d, ok = DisplayForRegion(displays, Region{X: -100, Y: 0, Width: 400, Height: 100})
+	if !ok || d.ID != retinaDisplay.ID {
+		t.Errorf("DisplayForRegion() = %d, %v, want %d", d.ID, ok, retinaDisplay.ID)
+	}
+
+	if _, ok := DisplayForRegion(displays, Region{X: 5000, Y: 5000, Width: 10, Height: 10}); ok {
+		t.Error("DisplayForRegion() should not match an off-screen region")
+	}
+}
+
+func TestRegionFromPoints(t *testing.T) {
+	got := RegionFromPoints(50.2, 100.9, 640.7, 480.5)
+	want := Region{X: 50, Y: 100, Width: 641, Height: 482}
+	if got != want {
+		t.Errorf("RegionFromPoints() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCocoaToCG(t *testing.T) {
+	r := Region{X: 100, Y: 100, Width: 200, Height: 50}
+
+	cg := CocoaToCG(r, 900)
+	if cg.Y != 750 {
+		t.Errorf("CocoaToCG() Y = %d, want 750", cg.Y)
+	}
+	if back := CGToCocoa(cg, 900); back != r {
+		t.Errorf("CGToCocoa() = %+v, want %+v", back, r)
+	}
+}
+
+func TestPixelSize(t *testing.T) {
+	w, h := retinaDisplay.PixelSize()
+	if w != 2880 || h != 1800 {
+		t.Errorf("PixelSize() = %dx%d, want 2880x1800", w, h)
+	}
+	if !retinaDisplay.Contains(Region{X: 0, Y: 0, Width: 1440, Height: 900}) {
+		t.Error("Contains() should include the full display")
+	}
+	if retinaDisplay.Contains(Region{X: 1, Y: 0, Width: 1440, Height: 900}) {
+		t.Error("Contains() should exclude regions past the edge")
+	}
+}
@@ -0,0 +1,138 @@
+package capture
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DisplayInfo describes a connected display, for choosing Config.DisplayID
+// or Config.Display
+type DisplayInfo struct {
+	Display
+
+	// Name is the display's product name, e.g. "Built-in Retina Display"
+	Name string
+
+	// UUID identifies the display across reboots and reconnections, unlike
+	// its ID, which can change when displays are plugged in or removed
+	UUID string
+
+	// Main is true for the main display, which DisplayID 0 selects
+	Main bool
+}
+
+// ListDisplays returns the connected displays, main display first
+func ListDisplays() ([]DisplayInfo, error) {
+	return platformListDisplays()
+}
+
+// MatchDisplay picks the display a target refers to: its ID, with 0 or an
+// empty target for the main display, its UUID, or its name. An exact name
+// wins over a partial one, and names and UUIDs are matched ignoring case.
+func MatchDisplay(displays []DisplayInfo, target string) (DisplayInfo, bool) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		target = "0"
+	}
+
+	lower := strings.ToLower(target)
+	matchers := []func(d DisplayInfo) bool{
+		func(d DisplayInfo) bool {
+			id, err := strconv.ParseUint(target, 10, 32)
+			return err == nil && (uint32(id) == d.ID || (id == 0 && d.Main))
+		},
+		func(d DisplayInfo) bool { return d.UUID != "" && strings.EqualFold(d.UUID, target) },
+		func(d DisplayInfo) bool { return strings.EqualFold(d.Name, target) },
+		func(d DisplayInfo) bool { return strings.Contains(strings.ToLower(d.Name), lower) },
+	}
+
+	for _, match := range matchers {
+		for _, d := range displays {
+			if match(d) {
+				return d, true
+			}
+		}
+	}
+	return DisplayInfo{}, false
+}
+
+// ResolveDisplay returns the ID of the display a target refers to, as for
+// MatchDisplay. A numeric target is returned as is, without listing the
+// displays.
+func ResolveDisplay(target string) (uint32, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return 0, nil
+	}
+	if id, err := strconv.ParseUint(target, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+
+	displays, err := ListDisplays()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list displays to find %q: %w", target, err)
+	}
+	d, ok := MatchDisplay(displays, target)
+	if !ok {
+		return 0, fmt.Errorf("no display matches %q: %w", target, ErrDisplayNotFound)
+	}
+	return d.ID, nil
+}
+
+// String describes the display on one line
+func (d DisplayInfo) String() string {
+	w, h := d.PixelSize()
+	s := fmt.Sprintf("%d: %s, %dx%d at (%d,%d), %dx%d pixels (%gx)",
+		d.ID, d.Name, d.Bounds.Width, d.Bounds.Height, d.Bounds.X, d.Bounds.Y, w, h, d.scale())
+	if d.Main {
+		s += " [main]"
+	}
+	return s
+}
+
+// DisplayChanged is sent on a capturer's Errors channel when the captured
+// display is disconnected or its resolution, scale, or position changes.
+// Frames captured after such a change would no longer match the recording,
+// so the capturer stops delivering them. It wraps ErrDisplayLost, so a
+// recorder re-attaches by creating a new capturer.
+type DisplayChanged struct {
+	// Before is the display's geometry when capture started
+	Before Display
+
+	// After is the display's geometry now; zero if Disconnected
+	After Display
+
+	// Disconnected is true if the display is no longer attached
+	Disconnected bool
+}
+
+// Error describes the change
+func (e *DisplayChanged) Error() string {
+	if e.Disconnected {
+		return fmt.Sprintf("display %d was disconnected", e.Before.ID)
+	}
+	bw, bh := e.Before.PixelSize()
+	aw, ah := e.After.PixelSize()
+	return fmt.Sprintf("display %d changed from %dx%d (%gx) at (%d,%d) to %dx%d (%gx) at (%d,%d)",
+		e.Before.ID, bw, bh, e.Before.scale(), e.Before.Bounds.X, e.Before.Bounds.Y,
+		aw, ah, e.After.scale(), e.After.Bounds.X, e.After.Bounds.Y)
+}
+
+// Unwrap returns ErrDisplayLost
+func (e *DisplayChanged) Unwrap() error {
+	return ErrDisplayLost
+}
+
+// CheckDisplay compares a display's geometry when capture started with its
+// geometry now, and returns the change or nil. connected reports whether
+// the display is still attached.
+func CheckDisplay(before, after Display, connected bool) *DisplayChanged {
+	if !connected {
+		return &DisplayChanged{Before: before, Disconnected: true}
+	}
+	if before.Bounds != after.Bounds || before.scale() != after.scale() {
+		return &DisplayChanged{Before: before, After: after}
+	}
+	return nil
+}
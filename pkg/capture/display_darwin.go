@@ -0,0 +1,378 @@
+//go:build darwin
+// +build darwin
+
+package capture
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/internal/macos"
+)
+
+// displayCheckInterval is how often a displayCapturer checks whether its
+// display was disconnected or reconfigured
+const displayCheckInterval = time.Second
+
+// displayCapturer captures frames from macOS displays using a display
+// stream. The stream delivers a frame only when the display changes; frames
+// are still emitted at the configured rate, repeating the latest one while
+// the screen is still. Emitted frames hold the IOSurface the stream drew
+// into rather than a copy, and are converted to RGBA, and downscaled by
+// Config.Scale, only when they are loaded (see Frame.Load).
+//
+// When Config.Region is set, frames are cropped to it and measure the
+// region's size in pixels, as set by Config.ScaleMode. If the display is
+// disconnected or its resolution changes, a DisplayChanged error is sent
+// and no further frames are delivered. Config.Exclude and Config.App are
+// applied by a ScreenCaptureKit stream, from macOS 12.3.
+type displayCapturer struct {
+	config   Config
+	display  Display  // Geometry when the capturer was created
+	exclude  []uint32 // IDs of the windows matching Config.Exclude
+	stream   *macos.DisplayStream
+	frames   chan *Frame
+	errors   chan error
+	stopChan chan struct{}
+	loopDone chan struct{} // Closed when captureLoop returns
+	state    State
+	mu       sync.Mutex
+	pause    PauseGate
+	stats    StatsCounter
+	limit    Limit
+	crop     image.Rectangle // Config.Region in display pixels; empty for the whole display
+
+	latestMu sync.Mutex
+	latest   *macos.Surface // Most recent frame from the stream
+}
+
+// newDisplayCapturer creates a capturer for the display Config.DisplayID
+// picks, leaving out the windows with the IDs in exclude
+func newDisplayCapturer(config Config, exclude []uint32) (*displayCapturer, error) {
+	// 0 is the main display
+	id := config.DisplayID
+	if id == 0 {
+		id = macos.MainDisplay()
+	}
+
+	info, ok := macos.LookupDisplay(id)
+	if !ok {
+		return nil, fmt.Errorf("display %d is not connected: %w", id, ErrDisplayLost)
+	}
+
+	return &displayCapturer{
+		config:   config,
+		display:  displayFromMacOS(info),
+		exclude:  exclude,
+		frames:   make(chan *Frame, 30), // Buffer 30 frames
+		errors:   make(chan error, 10),
+		stopChan: make(chan struct{}),
+		loopDone: make(chan struct{}),
+		state:    StateIdle,
+	}, nil
+}
+
+// checkDisplay returns how the display has changed since the capturer was
+// created, or nil
+func (d *displayCapturer) checkDisplay() *DisplayChanged {
+	info, connected := macos.LookupDisplay(d.display.ID)
+	return CheckDisplay(d.display, displayFromMacOS(info), connected)
+}
+
+// Start begins the capture process
+func (d *displayCapturer) Start() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.state != StateIdle {
+		return ErrAlreadyRunning
+	}
+
+	// Capture the whole display in pixels, or at the frame pixels per
+	// point Config.ScaleMode asks for; the stream scales for us
+	display := d.display.Scaled(d.config.ScaleMode)
+	width := int(math.Round(float64(display.Bounds.Width) * display.ScaleFactor))
+	height := int(math.Round(float64(display.Bounds.Height) * display.ScaleFactor))
+
+	if d.config.Region != nil {
+		// Config.Region is in global points; frames are cropped in pixels
+		local, err := display.GlobalToLocal(*d.config.Region)
+		if err != nil {
+			return err
+		}
+		d.crop = image.Rect(local.X, local.Y, local.X+local.Width, local.Y+local.Height)
+	}
+
+	stream, err := macos.StartDisplayStream(d.display.ID, macos.StreamOptions{
+		Width:        width,
+		Height:       height,
+		MinFrameTime: d.config.FPS.FrameDuration(),
+		ShowCursor:   d.config.IncludeCursor,
+		Exclude:      d.exclude,
+		App:          d.config.App,
+	}, d.receive)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrStreamInterrupted)
+	}
+
+	d.stream = stream
+	d.state = StateRunning
+	d.stats.Start()
+	d.limit.Start(d.config)
+
+	// Start capture loop
+	go d.captureLoop()
+
+	return nil
+}
+
+// Stop ends the capture process
+func (d *displayCapturer) Stop() error {
+	d.mu.Lock()
+	if d.state != StateRunning {
+		d.mu.Unlock()
+		return ErrNotRunning
+	}
+	d.state = StateStopping
+	d.mu.Unlock()
+
+	// Signal stop and wait for the loop so no frame is sent after the
+	// channels close
+	close(d.stopChan)
+	<-d.loopDone
+	d.stats.Stop()
+	d.stream.Stop()
+
+	d.latestMu.Lock()
+	if d.latest != nil {
+		d.latest.Release()
+		d.latest = nil
+	}
+	d.latestMu.Unlock()
+
+	d.mu.Lock()
+	d.state = StateIdle
+	close(d.frames)
+	close(d.errors)
+	d.mu.Unlock()
+
+	return nil
+}
+
+// IsRunning returns whether the capturer is currently running
+func (d *displayCapturer) IsRunning() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state == StateRunning
+}
+
+// State returns the current lifecycle state
+func (d *displayCapturer) State() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state == StateRunning && d.pause.Paused() {
+		return StatePaused
+	}
+	return d.state
+}
+
+// Pause holds back frames until Resume is called
+func (d *displayCapturer) Pause() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.state != StateRunning {
+		return ErrNotRunning
+	}
+	d.pause.Pause()
+
+	return nil
+}
+
+// Resume delivers frames again after Pause
+func (d *displayCapturer) Resume() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.state != StateRunning {
+		return ErrNotRunning
+	}
+	d.pause.Resume()
+
+	return nil
+}
+
+// Stats returns the capture statistics since Start
+func (d *displayCapturer) Stats() Stats {
+	return d.stats.Stats()
+}
+
+// Frames returns the channel for captured frames
+func (d *displayCapturer) Frames() <-chan *Frame {
+	return d.frames
+}
+
+// Errors returns the channel for errors
+func (d *displayCapturer) Errors() <-chan error {
+	return d.errors
+}
+
+// captureLoop emits the latest frame at the configured rate
+func (d *displayCapturer) captureLoop() {
+	defer close(d.loopDone)
+
+	ticker := time.NewTicker(d.config.FPS.FrameDuration())
+	defer ticker.Stop()
+	check := time.NewTicker(displayCheckInterval)
+	defer check.Stop()
+
+	for {
+		select {
+		case <-d.stopChan:
+			return
+		case <-check.C:
+			// Frames from a reconfigured display would be scaled or
+			// cropped wrongly, so stop until the recorder re-attaches
+			if change := d.checkDisplay(); change != nil {
+				select {
+				case d.errors <- change:
+				default:
+				}
+				return
+			}
+		case <-ticker.C:
+			if err := d.limit.Reached(); err != nil {
+				select {
+				case d.errors <- err:
+				default:
+				}
+				return
+			}
+			if d.pause.Paused() {
+				continue
+			}
+			grabbed := time.Now()
+			frame := d.nextFrame()
+			if frame == nil {
+				continue // The stream has not delivered a frame yet
+			}
+			d.stats.Captured(time.Since(grabbed))
+			if !d.pause.Admit(frame) {
+				frame.Release()
+				d.stats.Dropped()
+				continue
+			}
+			select {
+			case d.frames <- frame:
+				d.limit.Delivered()
+			case <-d.stopChan:
+				frame.Release()
+				d.stats.Dropped()
+				return
+			}
+		}
+	}
+}
+
+// nextFrame returns a frame holding the latest surface, or nil before the
+// first. Loading the frame converts the surface into an image of its own,
+// so consumers may modify it.
+func (d *displayCapturer) nextFrame() *Frame {
+	d.latestMu.Lock()
+	defer d.latestMu.Unlock()
+
+	if d.latest == nil {
+		return nil
+	}
+	d.latest.Retain()
+	pending := &surfacePixels{
+		surface:   d.latest,
+		crop:      d.crop,
+		downscale: d.config.Downscale,
+	}
+	frame := &Frame{
+		Timestamp: time.Now(),
+		pending:   pending,
+	}
+	// Frames dropped by consumers without Release still hand the surface back
+	runtime.AddCleanup(frame, (*surfacePixels).release, pending)
+
+	return frame
+}
+
+// receive replaces the latest frame with a changed one from the stream,
+// handing the previous surface back. It is called on the stream's queue.
+func (d *displayCapturer) receive(s *macos.Surface) {
+	d.latestMu.Lock()
+	defer d.latestMu.Unlock()
+
+	if d.latest != nil {
+		d.latest.Release()
+	}
+	d.latest = s
+}
+
+// surfacePixels are the pending pixels of a frame from a display stream
+type surfacePixels struct {
+	surface   *macos.Surface
+	crop      image.Rectangle
+	downscale func(*image.RGBA) *image.RGBA
+	once      sync.Once
+}
+
+// rgba converts the surface, cropped and downscaled, into a new image
+func (p *surfacePixels) rgba() (*image.RGBA, error) {
+	img, err := surfaceRGBA(p.surface, p.crop)
+	if err != nil {
+		return nil, err
+	}
+	return p.downscale(img), nil
+}
+
+// release drops the frame's reference to the surface
+func (p *surfacePixels) release() {
+	p.once.Do(p.surface.Release)
+}
+
+// surfaceRGBA returns the crop area of a display stream surface converted
+// to RGBA. A surface that is not laid out as BGRA rows fails with
+// ErrCorruptFrame rather than being read skewed.
+func surfaceRGBA(s *macos.Surface, crop image.Rectangle) (*image.RGBA, error) {
+	p := s.Lock()
+	defer s.Unlock()
+
+	if p.Format != macos.PixelFormatBGRA {
+		return nil, fmt.Errorf("display stream delivered pixel format %#08x, want BGRA: %w", p.Format, ErrCorruptFrame)
+	}
+	if p.BytesPerPixel != 4 {
+		return nil, fmt.Errorf("display stream delivered %d bytes per pixel, want 4: %w", p.BytesPerPixel, ErrCorruptFrame)
+	}
+	if err := ValidateBuffer(image.Pt(p.Width, p.Height), p.Stride, p.BytesPerPixel, len(p.Data)); err != nil {
+		return nil, fmt.Errorf("display stream surface: %w", err)
+	}
+
+	area := image.Rect(0, 0, p.Width, p.Height)
+	if !crop.Empty() {
+		area = crop.Intersect(area)
+	}
+	w, h := area.Dx(), area.Dy()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		row := (area.Min.Y+y)*p.Stride + 4*area.Min.X
+		src := p.Data[row : row+4*w]
+		dst := img.Pix[y*img.Stride : y*img.Stride+4*w]
+		for x := 0; x < len(src); x += 4 {
+			dst[x] = src[x+2]
+			dst[x+1] = src[x+1]
+			dst[x+2] = src[x]
+			dst[x+3] = 255
+		}
+	}
+
+	return img, nil
+}
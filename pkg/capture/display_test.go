@@ -0,0 +1,135 @@
+package capture
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDisplayInfoString(t *testing.T) {
+	tests := []struct {
+		name string
+		info DisplayInfo
+		want string
+	}{
+		{
+			"retina main display",
+			DisplayInfo{
+				Display: Display{ID: 1, Bounds: Region{Width: 1512, Height: 982}, ScaleFactor: 2},
+				Name:    "Built-in Retina Display",
+				Main:    true,
+			},
+			"1: Built-in Retina Display, 1512x982 at (0,0), 3024x1964 pixels (2x) [main]",
+		},
+		{
+			"secondary display to the left",
+			DisplayInfo{
+				Display: Display{ID: 5, Bounds: Region{X: -1920, Y: 0, Width: 1920, Height: 1080}},
+				Name:    "DELL U2720Q",
+			},
+			"5: DELL U2720Q, 1920x1080 at (-1920,0), 1920x1080 pixels (1x)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchDisplay(t *testing.T) {
+	displays := []DisplayInfo{
+		{Display: Display{ID: 1}, Name: "Built-in Retina Display", UUID: "37D8832A-2D66-02CA-B9F7-8F30A301B230", Main: true},
+		{Display: Display{ID: 5}, Name: "DELL U2720Q", UUID: "9D0C6B2E-4F0A-4C1B-8E25-3A5E1F7C9B10"},
+		{Display: Display{ID: 7}, Name: "DELL U2720Q (2)", UUID: "B41E2C7D-1A3F-4E59-9C08-6D2F8A0E5B77"},
+	}
+
+	tests := []struct {
+		target string
+		want   uint32 // 0 for no match
+	}{
+		{"", 1},
+		{"0", 1},
+		{"5", 5},
+		{"9d0c6b2e-4f0a-4c1b-8e25-3a5e1f7c9b10", 5},
+		{"B41E2C7D-1A3F-4E59-9C08-6D2F8A0E5B77", 7},
+		{"dell u2720q", 5},
+		{" (2) ", 7},
+		{"retina", 1},
+		{"3", 0},
+		{"LG UltraFine", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			d, ok := MatchDisplay(displays, tt.target)
+			if ok != (tt.want != 0) || d.ID != tt.want {
+				t.Errorf("MatchDisplay(%q) = %d, %v; want %d", tt.target, d.ID, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveDisplayID(t *testing.T) {
+	// IDs are used as given, so they work where displays cannot be listed
+	for target, want := range map[string]uint32{"": 0, "0": 0, " 3 ": 3} {
+		if got, err := ResolveDisplay(target); err != nil || got != want {
+			t.Errorf("ResolveDisplay(%q) = %d, %v; want %d", target, got, err, want)
+		}
+	}
+}
+
+func TestCheckDisplay(t *testing.T) {
+	retina := Display{ID: 1, Bounds: Region{Width: 1512, Height: 982}, ScaleFactor: 2}
+
+	tests := []struct {
+		name      string
+		after     Display
+		connected bool
+		want      string // Error text, or empty for no change
+	}{
+		{"unchanged", retina, true, ""},
+		{"disconnected", Display{}, false, "display 1 was disconnected"},
+		{
+			"resolution changed",
+			Display{ID: 1, Bounds: Region{Width: 1728, Height: 1117}, ScaleFactor: 2},
+			true,
+			"display 1 changed from 3024x1964 (2x) at (0,0) to 3456x2234 (2x) at (0,0)",
+		},
+		{
+			"scale changed",
+			Display{ID: 1, Bounds: Region{Width: 1512, Height: 982}, ScaleFactor: 1},
+			true,
+			"display 1 changed from 3024x1964 (2x) at (0,0) to 1512x982 (1x) at (0,0)",
+		},
+		{
+			"moved",
+			Display{ID: 1, Bounds: Region{X: 1920, Width: 1512, Height: 982}, ScaleFactor: 2},
+			true,
+			"display 1 changed from 3024x1964 (2x) at (0,0) to 3024x1964 (2x) at (1920,0)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := CheckDisplay(retina, tt.after, tt.connected)
+			if tt.want == "" {
+				if change != nil {
+					t.Errorf("CheckDisplay() = %v, want no change", change)
+				}
+				return
+			}
+			if change == nil {
+				t.Fatalf("CheckDisplay() = nil, want %q", tt.want)
+			}
+			if got := change.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+			if !errors.Is(change, ErrDisplayLost) || !IsRecoverable(change) {
+				t.Error("DisplayChanged should be a recoverable ErrDisplayLost")
+			}
+		})
+	}
+}
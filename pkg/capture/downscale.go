@@ -0,0 +1,48 @@
+package capture
+
+import (
+	"image"
+	"math"
+)
+
+// Downscale returns img resized by Config.Scale, averaging the source pixels
+// each output pixel covers. Capturers call it on every frame before sending
+// it, so large displays never fill the frame channel with full-size images.
+// img is returned unchanged when Scale is 0 or not below 1.
+func (c Config) Downscale(img *image.RGBA) *image.RGBA {
+	if c.Scale <= 0 || c.Scale >= 1 || math.IsNaN(c.Scale) {
+		return img
+	}
+
+	src := img.Rect
+	sw, sh := src.Dx(), src.Dy()
+	dw := max(1, int(float64(sw)*c.Scale+0.5))
+	dh := max(1, int(float64(sh)*c.Scale+0.5))
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+
+			// RGBA is premultiplied, so channels can be averaged directly
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := img.Pix[img.PixOffset(src.Min.X+x0, src.Min.Y+sy):]
+				for i := 0; i < 4*(x1-x0); i += 4 {
+					r += int(row[i])
+					g += int(row[i+1])
+					b += int(row[i+2])
+					a += int(row[i+3])
+				}
+				n += x1 - x0
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8((r + n/2) / n)
+			dst.Pix[i+1] = uint8((g + n/2) / n)
+			dst.Pix[i+2] = uint8((b + n/2) / n)
+			dst.Pix[i+3] = uint8((a + n/2) / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,88 @@
+package capture
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+// Helper function to create a 4x4 image with a different color in each 2x2
+// quadrant
+func quadrants() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 255, 255}}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetRGBA(x, y, colors[y/2*2+x/2])
+		}
+	}
+	return img
+}
+
+func TestDownscale(t *testing.T) {
+	img := quadrants()
+
+	for _, scale := range []float64{0, 1, 1.5} {
+		if got := (Config{Scale: scale}).Downscale(img); got != img {
+			t.Errorf("Downscale() with Scale %v should return the image unchanged", scale)
+		}
+	}
+
+	half := Config{Scale: 0.5}.Downscale(img)
+	if half.Rect != image.Rect(0, 0, 2, 2) {
+		t.Fatalf("Downscale() size = %v, want 2x2", half.Rect)
+	}
+	for i, want := range []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 255, 255}} {
+		if got := half.RGBAAt(i%2, i/2); got != want {
+			t.Errorf("pixel %d = %v, want %v", i, got, want)
+		}
+	}
+
+	// Averaging the whole image
+	quarter := Config{Scale: 0.25}.Downscale(img)
+	if got, want := quarter.RGBAAt(0, 0), (color.RGBA{128, 128, 128, 255}); got != want {
+		t.Errorf("averaged pixel = %v, want %v", got, want)
+	}
+}
+
+func TestDownscaleSizes(t *testing.T) {
+	tests := []struct {
+		name  string
+		rect  image.Rectangle
+		scale float64
+		want  image.Point
+	}{
+		{"rounds up at half", image.Rect(0, 0, 5, 3), 0.5, image.Pt(3, 2)},
+		{"never empty", image.Rect(0, 0, 3, 3), 0.01, image.Pt(1, 1)},
+		{"offset sub-image", image.Rect(10, 20, 18, 24), 0.5, image.Pt(4, 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewRGBA(tt.rect)
+			got := Config{Scale: tt.scale}.Downscale(img)
+			if got.Rect.Size() != tt.want || got.Rect.Min != (image.Point{}) {
+				t.Errorf("Downscale() rect = %v, want size %v at the origin", got.Rect, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternCapturerDownscales(t *testing.T) {
+	p := NewPatternCapturer(Config{FPS: IntFPS(100), Region: &Region{Width: 64, Height: 48}, Scale: 0.5}, PatternBars)
+	p.Realtime = false
+	if err := p.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer p.Stop()
+
+	select {
+	case frame := <-p.Frames():
+		if size := frame.Image.Rect.Size(); size != image.Pt(32, 24) {
+			t.Errorf("frame size = %v, want 32x24", size)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no frame received")
+	}
+}
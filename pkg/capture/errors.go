@@ -0,0 +1,82 @@
+package capture
+
+import "errors"
+
+// Error is a classified capture error. Recoverable errors are transient
+// conditions (a stream hiccup, a display briefly disappearing) that a
+// recorder may retry; unrecoverable errors require user action or a bug fix.
+type Error struct {
+	msg         string
+	recoverable bool
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Recoverable reports whether retrying the operation may succeed
+func (e *Error) Recoverable() bool {
+	return e.recoverable
+}
+
+// Capture errors. Implementations wrap these with context using %w, so
+// callers should compare with errors.Is rather than ==.
+var (
+	// ErrAlreadyRunning is returned by Start when the capturer is running
+	ErrAlreadyRunning = &Error{msg: "capturer already running"}
+
+	// ErrNotRunning is returned by Stop when the capturer is not running
+	ErrNotRunning = &Error{msg: "capturer not running"}
+
+	// ErrPermissionDenied means the process lacks screen recording permission
+	ErrPermissionDenied = &Error{msg: "screen recording permission denied"}
+
+	// ErrUnsupportedPlatform means screen capture is not available on this OS
+	ErrUnsupportedPlatform = &Error{msg: "screen capture is not supported on this platform (only macOS and Linux Wayland sessions are currently supported)"}
+
+	// ErrEndOfStream is sent by capturers of finite input, such as a replayed
+	// recording, after their last frame has been delivered
+	ErrEndOfStream = &Error{msg: "end of stream"}
+
+	// ErrLimitReached is sent by a capturer that stopped itself because
+	// Config.MaxDuration or Config.MaxFrames was reached
+	ErrLimitReached = &Error{msg: "capture limit reached"}
+
+	// ErrWindowNotFound means the requested window does not exist
+	ErrWindowNotFound = &Error{msg: "window not found"}
+
+	// ErrDisplayNotFound means no connected display matches the requested
+	// UUID or name. It is recoverable, since a display being reconnected
+	// may not be back yet when a recorder re-attaches.
+	ErrDisplayNotFound = &Error{msg: "display not found", recoverable: true}
+
+	// ErrDisplayLost means the captured display was disconnected or reconfigured
+	ErrDisplayLost = &Error{msg: "display lost", recoverable: true}
+
+	// ErrStreamInterrupted means the capture stream stopped delivering frames
+	ErrStreamInterrupted = &Error{msg: "capture stream interrupted", recoverable: true}
+
+	// ErrFrameCapture means a single frame could not be captured
+	ErrFrameCapture = &Error{msg: "failed to capture frame", recoverable: true}
+
+	// ErrCorruptFrame means a frame's pixel buffer does not match its
+	// declared size, stride, or pixel format, as when a bitmap context or
+	// surface is laid out differently than expected. Encoding it would
+	// write skewed or garbled output, and retrying would not change the
+	// layout, so it is not recoverable.
+	ErrCorruptFrame = &Error{msg: "corrupt frame"}
+
+	// ErrTimeout means an operation did not complete in time
+	ErrTimeout = &Error{msg: "capture timed out", recoverable: true}
+)
+
+// IsRecoverable reports whether err, or any error it wraps, is a
+// recoverable capture error. Unclassified errors are not recoverable.
+func IsRecoverable(err error) bool {
+	var r interface{ Recoverable() bool }
+	if errors.As(err, &r) {
+		return r.Recoverable()
+	}
+	return false
+}
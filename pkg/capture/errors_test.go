@@ -0,0 +1,52 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsRecoverable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "display lost", err: ErrDisplayLost, want: true},
+		{name: "stream interrupted", err: ErrStreamInterrupted, want: true},
+		{name: "frame capture", err: ErrFrameCapture, want: true},
+		{name: "wrapped recoverable", err: fmt.Errorf("display 2: %w", ErrDisplayLost), want: true},
+		{name: "permission denied", err: ErrPermissionDenied, want: false},
+		{name: "already running", err: ErrAlreadyRunning, want: false},
+		{name: "unsupported platform", err: ErrUnsupportedPlatform, want: false},
+		{name: "window not found", err: ErrWindowNotFound, want: false},
+		{name: "display not found", err: ErrDisplayNotFound, want: true},
+		{name: "unclassified", err: errors.New("boom"), want: false},
+		{name: "nil", err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRecoverable(tt.err); got != tt.want {
+				t.Errorf("IsRecoverable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockCapturerTypedErrors(t *testing.T) {
+	capturer := NewMockCapturer(Config{FPS: IntFPS(15)})
+
+	if err := capturer.Stop(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Stop() error = %v, want %v", err, ErrNotRunning)
+	}
+
+	if err := capturer.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer capturer.Stop()
+
+	if err := capturer.Start(); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("Start() error = %v, want %v", err, ErrAlreadyRunning)
+	}
+}
@@ -0,0 +1,521 @@
+package capture
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// FileFFmpeg is the ffmpeg binary used to decode video files
+const FileFFmpeg = "ffmpeg"
+
+// FileCapturer plays frames from files as if they were being captured from
+// a screen, so encoders, processors, and recorders can be exercised in CI
+// and during development without a display. It reads a directory of PNG
+// images, one frame per image in natural order; an animated GIF, showing
+// each frame for its delay; or a video such as an MP4, decoded by ffmpeg.
+// Frames are delivered at Config.FPS, cropped to Config.Region when set,
+// and timestamped from Start, with a gap for any time paused. After the
+// last frame it sends ErrEndOfStream, which ends a recording cleanly.
+type FileCapturer struct {
+	// Realtime paces frames at the configured rate. Otherwise frames are
+	// delivered as fast as they are read.
+	Realtime bool
+
+	config Config
+	path   string
+	ffmpeg string // Set for video files
+
+	source   frameFile
+	start    time.Time
+	frames   chan *Frame
+	errors   chan error
+	stopChan chan struct{}
+	done     chan struct{}
+	state    State
+	mu       sync.Mutex
+	pause    PauseGate
+	stats    StatsCounter
+	limit    Limit
+}
+
+// frameFile reads the frames of a file in order. next returns io.EOF
+// after the last frame.
+type frameFile interface {
+	next() (*image.RGBA, error)
+	close() error
+}
+
+// NewFileCapturer creates a capturer that plays the frames at path in real
+// time. Video files need ffmpeg.
+func NewFileCapturer(config Config, path string) (*FileCapturer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open frames: %w", err)
+	}
+
+	var ffmpeg string
+	if !info.IsDir() && !strings.EqualFold(filepath.Ext(path), ".gif") {
+		ffmpeg, err = exec.LookPath(FileFFmpeg)
+		if err != nil {
+			return nil, fmt.Errorf("ffmpeg is required to read %s (install it with 'brew install ffmpeg'): %w", filepath.Base(path), err)
+		}
+	}
+	return newFileCapturer(config, path, ffmpeg), nil
+}
+
+// newFileCapturer fills in defaults and creates a capturer for path, which
+// is decoded by the given ffmpeg binary when set
+func newFileCapturer(config Config, path, ffmpeg string) *FileCapturer {
+	if !config.FPS.Valid() {
+		config.FPS = FPS15
+	}
+
+	return &FileCapturer{
+		Realtime: true,
+		config:   config,
+		path:     path,
+		ffmpeg:   ffmpeg,
+		frames:   make(chan *Frame, 30),
+		errors:   make(chan error, 10),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start opens the file and begins delivering frames
+func (f *FileCapturer) Start() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.state != StateIdle {
+		return ErrAlreadyRunning
+	}
+
+	var err error
+	switch {
+	case f.ffmpeg != "":
+		f.source, err = openVideoFrames(f.ffmpeg, f.path, f.config.FPS)
+	case strings.EqualFold(filepath.Ext(f.path), ".gif"):
+		f.source, err = openGIFFrames(f.path, f.config.FPS)
+	default:
+		f.source, err = openImageDir(f.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	f.start = time.Now()
+	f.state = StateRunning
+	f.stats.Start()
+	f.limit.Start(f.config)
+	go f.playLoop()
+
+	return nil
+}
+
+// Stop ends playback and closes the file
+func (f *FileCapturer) Stop() error {
+	f.mu.Lock()
+	if f.state != StateRunning {
+		f.mu.Unlock()
+		return ErrNotRunning
+	}
+	f.state = StateStopping
+	f.mu.Unlock()
+
+	close(f.stopChan)
+	<-f.done
+	f.source.close()
+	f.stats.Stop()
+
+	f.mu.Lock()
+	f.state = StateIdle
+	close(f.frames)
+	close(f.errors)
+	f.mu.Unlock()
+
+	return nil
+}
+
+// Pause holds back frames until Resume is called
+func (f *FileCapturer) Pause() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.state != StateRunning {
+		return ErrNotRunning
+	}
+	f.pause.Pause()
+
+	return nil
+}
+
+// Resume delivers frames again after Pause
+func (f *FileCapturer) Resume() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.state != StateRunning {
+		return ErrNotRunning
+	}
+	f.pause.Resume()
+
+	return nil
+}
+
+// Stats returns the playback statistics since Start. Latency is the time
+// taken to decode each frame.
+func (f *FileCapturer) Stats() Stats {
+	return f.stats.Stats()
+}
+
+// Frames returns the channel for frames read from the file
+func (f *FileCapturer) Frames() <-chan *Frame {
+	return f.frames
+}
+
+// Errors returns the channel for errors
+func (f *FileCapturer) Errors() <-chan error {
+	return f.errors
+}
+
+// IsRunning returns whether the capturer is currently running
+func (f *FileCapturer) IsRunning() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state == StateRunning
+}
+
+// State returns the current lifecycle state
+func (f *FileCapturer) State() State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state == StateRunning && f.pause.Paused() {
+		return StatePaused
+	}
+	return f.state
+}
+
+// playLoop sends frames until the file ends or playback is stopped
+func (f *FileCapturer) playLoop() {
+	defer close(f.done)
+
+	var tick <-chan time.Time
+	if f.Realtime {
+		ticker := time.NewTicker(f.config.FPS.FrameDuration())
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for n := 0; ; n++ {
+		if tick != nil && n > 0 {
+			select {
+			case <-f.stopChan:
+				return
+			case <-tick:
+			}
+		}
+
+		if err := f.limit.Reached(); err != nil {
+			f.sendError(err)
+			return
+		}
+
+		read := time.Now()
+		img, err := f.source.next()
+		if err == io.EOF {
+			err = ErrEndOfStream
+		} else if err != nil {
+			err = fmt.Errorf("failed to read frame %d of %s: %w", n, filepath.Base(f.path), err)
+		}
+		if err != nil {
+			f.sendError(err)
+			return
+		}
+		frame := &Frame{
+			Image:     f.config.Downscale(cropToRegion(img, f.config.Region)),
+			Timestamp: f.start.Add(f.config.FPS.FrameTime(n)),
+		}
+		f.stats.Captured(time.Since(read))
+
+		// While paused, hold the next frame until Resume. The time spent
+		// paused moves the timestamps on, as it would for a screen, and the
+		// gate marks the frame after it as a discontinuity.
+		for !f.pause.Admit(frame) {
+			pausedAt := time.Now()
+			if !f.pause.Wait(f.stopChan) {
+				f.stats.Dropped()
+				return
+			}
+			f.start = f.start.Add(time.Since(pausedAt))
+			frame.Timestamp = f.start.Add(f.config.FPS.FrameTime(n))
+		}
+		select {
+		case f.frames <- frame:
+			f.limit.Delivered()
+		case <-f.stopChan:
+			f.stats.Dropped()
+			return
+		}
+	}
+}
+
+// sendError reports err unless playback is being stopped
+func (f *FileCapturer) sendError(err error) {
+	select {
+	case f.errors <- err:
+	case <-f.stopChan:
+	}
+}
+
+// cropToRegion returns the part of img inside region, as a capture of that
+// region of the screen would, or img itself without a region
+func cropToRegion(img *image.RGBA, region *Region) *image.RGBA {
+	if region == nil {
+		return img
+	}
+	r := image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height).
+		Add(img.Rect.Min).Intersect(img.Rect)
+	out := image.NewRGBA(image.Rectangle{Max: r.Size()})
+	draw.Draw(out, out.Rect, img, r.Min, draw.Src)
+	return out
+}
+
+// imageDir reads the PNG images in a directory, one frame each
+type imageDir struct {
+	paths []string
+}
+
+// openImageDir lists the PNG images in dir in natural order
+func openImageDir(dir string) (*imageDir, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frames: %w", err)
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".png") {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no PNG images in %s", dir)
+	}
+	SortNatural(paths)
+	return &imageDir{paths: paths}, nil
+}
+
+// next decodes the next image
+func (d *imageDir) next() (*image.RGBA, error) {
+	if len(d.paths) == 0 {
+		return nil, io.EOF
+	}
+	path := d.paths[0]
+	d.paths = d.paths[1:]
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return ToRGBA(img), nil
+}
+
+func (d *imageDir) close() error { return nil }
+
+// gifFrames shows the frames of an animated GIF for their delays, sampled
+// at a fixed frame rate
+type gifFrames struct {
+	gif    *gif.GIF
+	fps    FPS
+	canvas *image.RGBA
+	index  int           // GIF frame drawn on the canvas
+	end    time.Duration // When the drawn frame's delay ends
+	n      int           // Next output frame
+}
+
+// openGIFFrames decodes the GIF at path
+func openGIFFrames(path string, fps FPS) (*gifFrames, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frames: %w", err)
+	}
+	defer file.Close()
+	g, err := gif.DecodeAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", filepath.Base(path), err)
+	}
+	if len(g.Image) == 0 {
+		return nil, fmt.Errorf("%s has no frames", filepath.Base(path))
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+	frames := &gifFrames{gif: g, fps: fps, canvas: image.NewRGBA(bounds), index: -1}
+	frames.advance()
+	return frames, nil
+}
+
+// next returns the GIF frame showing at the next output frame's time
+func (g *gifFrames) next() (*image.RGBA, error) {
+	t := g.fps.FrameTime(g.n)
+	for t >= g.end {
+		if g.index == len(g.gif.Image)-1 {
+			return nil, io.EOF
+		}
+		g.advance()
+	}
+	g.n++
+
+	out := image.NewRGBA(g.canvas.Rect)
+	copy(out.Pix, g.canvas.Pix)
+	return out, nil
+}
+
+// advance disposes of the drawn frame and draws the next one onto the
+// canvas, as a browser would
+func (g *gifFrames) advance() {
+	if g.index >= 0 {
+		prev := g.gif.Image[g.index]
+		if g.index < len(g.gif.Disposal) && g.gif.Disposal[g.index] == gif.DisposalBackground {
+			draw.Draw(g.canvas, prev.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		}
+	}
+
+	g.index++
+	frame := g.gif.Image[g.index]
+	draw.Draw(g.canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+	// Browsers show frames without a delay for a tenth of a second
+	delay := 10
+	if g.index < len(g.gif.Delay) && g.gif.Delay[g.index] > 0 {
+		delay = g.gif.Delay[g.index]
+	}
+	g.end += time.Duration(delay) * 10 * time.Millisecond
+}
+
+func (g *gifFrames) close() error { return nil }
+
+// videoFrames reads a video's frames from ffmpeg as a stream of PNGs,
+// resampled to a fixed frame rate
+type videoFrames struct {
+	cmd    *exec.Cmd
+	out    io.ReadCloser
+	r      *bufio.Reader
+	stderr strings.Builder
+}
+
+// openVideoFrames starts ffmpeg decoding path
+func openVideoFrames(ffmpeg, path string, fps FPS) (*videoFrames, error) {
+	v := &videoFrames{}
+	v.cmd = exec.Command(ffmpeg, "-hide_banner", "-loglevel", "error", "-nostdin",
+		"-i", path, "-an", "-vf", "fps="+fps.String(), "-f", "image2pipe", "-c:v", "png", "-")
+	v.cmd.Stderr = &v.stderr
+	v.cmd.WaitDelay = time.Second
+	out, err := v.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", filepath.Base(path), err)
+	}
+	if err := v.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", filepath.Base(path), err)
+	}
+	v.out = out
+	v.r = bufio.NewReader(out)
+	return v, nil
+}
+
+// next decodes the next PNG from ffmpeg
+func (v *videoFrames) next() (*image.RGBA, error) {
+	if _, err := v.r.Peek(1); err == io.EOF {
+		if err := v.cmd.Wait(); err != nil {
+			return nil, fmt.Errorf("ffmpeg failed: %s", strings.TrimSpace(v.stderr.String()))
+		}
+		return nil, io.EOF
+	}
+	img, err := png.Decode(v.r)
+	if err != nil {
+		return nil, err
+	}
+	return ToRGBA(img), nil
+}
+
+// close stops ffmpeg if it is still decoding
+func (v *videoFrames) close() error {
+	if v.cmd.ProcessState == nil {
+		v.cmd.Process.Kill()
+		v.out.Close()
+		v.cmd.Wait()
+	}
+	return nil
+}
+
+// ToRGBA returns img as an RGBA image with its origin at (0,0), converting
+// it only if needed
+func ToRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rectangle{Max: b.Size()})
+	draw.Draw(out, out.Rect, img, b.Min, draw.Src)
+	return out
+}
+
+// SortNatural sorts names so runs of digits compare by value, putting
+// frame2.png before frame10.png
+func SortNatural(names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		return naturalLess(names[i], names[j])
+	})
+}
+
+// naturalLess compares a and b, treating runs of digits as numbers
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// digitPrefix returns the leading run of digits in s
+func digitPrefix(s string) string {
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+	if i < 0 {
+		return s
+	}
+	return s[:i]
+}
@@ -0,0 +1,272 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Helper function to write a solid PNG of the given size and color
+func writeSolidPNG(t *testing.T, path string, w, h int, c color.RGBA) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	fill(img, img.Rect, c)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Helper function to play a capturer without pacing and collect its frames
+// until the end of the stream
+func playAll(t *testing.T, f *FileCapturer) []*Frame {
+	t.Helper()
+	f.Realtime = false
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer f.Stop()
+
+	var frames []*Frame
+	for {
+		select {
+		case frame := <-f.Frames():
+			frames = append(frames, frame)
+		case err := <-f.Errors():
+			if err != ErrEndOfStream {
+				t.Fatalf("error = %v, want %v", err, ErrEndOfStream)
+			}
+			// Frames sent before the error may still be buffered
+			for len(f.Frames()) > 0 {
+				frames = append(frames, <-f.Frames())
+			}
+			return frames
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the end of the stream")
+		}
+	}
+}
+
+func TestFileCapturerImageDir(t *testing.T) {
+	dir := t.TempDir()
+	red, green, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255}
+	writeSolidPNG(t, filepath.Join(dir, "frame10.png"), 8, 6, blue)
+	writeSolidPNG(t, filepath.Join(dir, "frame2.png"), 8, 6, green)
+	writeSolidPNG(t, filepath.Join(dir, "frame1.png"), 8, 6, red)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a frame"), 0644)
+
+	f, err := NewFileCapturer(Config{FPS: FPS10}, dir)
+	if err != nil {
+		t.Fatalf("NewFileCapturer() failed: %v", err)
+	}
+	frames := playAll(t, f)
+
+	var got []color.RGBA
+	for _, frame := range frames {
+		got = append(got, frame.Image.RGBAAt(0, 0))
+	}
+	if want := []color.RGBA{red, green, blue}; !reflect.DeepEqual(got, want) {
+		t.Errorf("frame colors = %v, want %v in natural order", got, want)
+	}
+	if gap := frames[2].Timestamp.Sub(frames[1].Timestamp); gap != 100*time.Millisecond {
+		t.Errorf("frames are %v apart, want 100ms at 10 fps", gap)
+	}
+}
+
+func TestFileCapturerPause(t *testing.T) {
+	dir := t.TempDir()
+	for i := 1; i <= 3; i++ {
+		writeSolidPNG(t, filepath.Join(dir, fmt.Sprintf("%d.png", i)), 4, 4, color.RGBA{A: 255})
+	}
+	f, err := NewFileCapturer(Config{FPS: FPS10}, dir)
+	if err != nil {
+		t.Fatalf("NewFileCapturer() failed: %v", err)
+	}
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer f.Stop()
+
+	next := func() *Frame {
+		select {
+		case frame := <-f.Frames():
+			return frame
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for a frame")
+			return nil
+		}
+	}
+
+	first := next()
+	if err := f.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if err := f.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+
+	second := next()
+	if !second.Discontinuity {
+		t.Error("first frame after a pause is not marked as a discontinuity")
+	}
+	// The second frame is taken when Resume is called, give or take a
+	// tick, not a frame interval after the first
+	if gap := second.Timestamp.Sub(first.Timestamp); gap < 250*time.Millisecond {
+		t.Errorf("frames are %v apart across a 300ms pause, want about 300ms", gap)
+	}
+	if third := next(); third.Timestamp.Sub(second.Timestamp) != 100*time.Millisecond {
+		t.Errorf("frames after the pause are %v apart, want 100ms at 10 fps", third.Timestamp.Sub(second.Timestamp))
+	}
+}
+
+func TestFileCapturerRegion(t *testing.T) {
+	dir := t.TempDir()
+	writeSolidPNG(t, filepath.Join(dir, "1.png"), 40, 30, color.RGBA{9, 9, 9, 255})
+
+	f, err := NewFileCapturer(Config{Region: &Region{X: 10, Y: 5, Width: 20, Height: 50}}, dir)
+	if err != nil {
+		t.Fatalf("NewFileCapturer() failed: %v", err)
+	}
+	frames := playAll(t, f)
+
+	if len(frames) != 1 || frames[0].Image.Bounds() != image.Rect(0, 0, 20, 25) {
+		t.Errorf("frames = %d, want 1 cropped to the 20x25 part of the region inside the image", len(frames))
+	}
+}
+
+func TestFileCapturerGIF(t *testing.T) {
+	// Two frames of 1/10 and 3/10 of a second, sampled at 10 fps
+	g := &gif.GIF{Delay: []int{10, 30}}
+	for _, idx := range []uint8{1, 2} {
+		img := image.NewPaletted(image.Rect(0, 0, 4, 4), palette.Plan9)
+		for i := range img.Pix {
+			img.Pix[i] = idx
+		}
+		g.Image = append(g.Image, img)
+	}
+	path := filepath.Join(t.TempDir(), "clip.gif")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.EncodeAll(out, g); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	f, err := NewFileCapturer(Config{FPS: FPS10}, path)
+	if err != nil {
+		t.Fatalf("NewFileCapturer() failed: %v", err)
+	}
+	frames := playAll(t, f)
+
+	first := color.RGBAModel.Convert(palette.Plan9[1]).(color.RGBA)
+	second := color.RGBAModel.Convert(palette.Plan9[2]).(color.RGBA)
+	want := []color.RGBA{first, second, second, second}
+	var got []color.RGBA
+	for _, frame := range frames {
+		got = append(got, frame.Image.RGBAAt(0, 0))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("frame colors = %v, want %v", got, want)
+	}
+}
+
+func TestFileCapturerVideo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	frame := filepath.Join(dir, "frame.png")
+	writeSolidPNG(t, frame, 6, 4, color.RGBA{200, 100, 50, 255})
+
+	// The fake ffmpeg writes the same PNG twice, as a two-frame video
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\ncat '" + frame + "' '" + frame + "'\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := playAll(t, newFileCapturer(Config{}, filepath.Join(dir, "demo.mp4"), ffmpeg))
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	if got := frames[1].Image.RGBAAt(5, 3); got != (color.RGBA{200, 100, 50, 255}) {
+		t.Errorf("pixel = %v, want the decoded color", got)
+	}
+}
+
+func TestFileCapturerVideoFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	os.WriteFile(ffmpeg, []byte("#!/bin/sh\necho 'demo.mp4: No such file' >&2\nexit 1\n"), 0755)
+
+	f := newFileCapturer(Config{}, "demo.mp4", ffmpeg)
+	f.Realtime = false
+	if err := f.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer f.Stop()
+
+	select {
+	case err := <-f.Errors():
+		if err == ErrEndOfStream || !strings.Contains(err.Error(), "No such file") {
+			t.Errorf("error = %v, want ffmpeg's message", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an error")
+	}
+}
+
+func TestNewFileCapturerMissing(t *testing.T) {
+	if _, err := NewFileCapturer(Config{}, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("NewFileCapturer() should fail for a missing path")
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"frame2.png", "frame10.png", true},
+		{"frame10.png", "frame2.png", false},
+		{"frame002.png", "frame10.png", true},
+		{"a.png", "b.png", true},
+		{"frame.png", "frame1.png", true},
+	}
+
+	for _, tt := range tests {
+		if got := naturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortNatural(t *testing.T) {
+	// Shell globs expand in lexical order
+	names := []string{"f1.png", "f10.png", "f11.png", "f2.png"}
+	SortNatural(names)
+
+	want := []string{"f1.png", "f2.png", "f10.png", "f11.png"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("SortNatural() = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,167 @@
+package capture
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FPS is a frame rate expressed as a rational number (Num/Den frames per second).
+// Using a rational allows exact representation of broadcast rates such as
+// 29.97 (30000/1001) that cannot be expressed as an integer.
+type FPS struct {
+	Num int
+	Den int
+}
+
+// Common frame rates
+var (
+	FPS10    = FPS{Num: 10, Den: 1}
+	FPS15    = FPS{Num: 15, Den: 1}
+	FPS23976 = FPS{Num: 24000, Den: 1001}
+	FPS24    = FPS{Num: 24, Den: 1}
+	FPS25    = FPS{Num: 25, Den: 1}
+	FPS2997  = FPS{Num: 30000, Den: 1001}
+	FPS30    = FPS{Num: 30, Den: 1}
+	FPS50    = FPS{Num: 50, Den: 1}
+	FPS5994  = FPS{Num: 60000, Den: 1001}
+	FPS60    = FPS{Num: 60, Den: 1}
+)
+
+// NewFPS creates a frame rate of num/den frames per second, reduced to lowest terms
+func NewFPS(num, den int) FPS {
+	if den < 0 {
+		num, den = -num, -den
+	}
+	if g := gcd(num, den); g > 1 {
+		num /= g
+		den /= g
+	}
+	return FPS{Num: num, Den: den}
+}
+
+// IntFPS creates a whole-number frame rate
+func IntFPS(fps int) FPS {
+	return FPS{Num: fps, Den: 1}
+}
+
+// ParseFPS parses a frame rate from a string.
+// Accepted forms are integers ("15"), rationals ("30000/1001") and
+// decimals ("29.97"). The well-known NTSC decimals 23.976, 29.97 and 59.94
+// map to their exact 1001-denominator rationals.
+func ParseFPS(s string) (FPS, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return FPS{}, fmt.Errorf("empty frame rate")
+	}
+
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		n, err := strconv.Atoi(strings.TrimSpace(num))
+		if err != nil {
+			return FPS{}, fmt.Errorf("invalid frame rate numerator %q: %w", num, err)
+		}
+		d, err := strconv.Atoi(strings.TrimSpace(den))
+		if err != nil {
+			return FPS{}, fmt.Errorf("invalid frame rate denominator %q: %w", den, err)
+		}
+		fps := NewFPS(n, d)
+		if !fps.Valid() {
+			return FPS{}, fmt.Errorf("frame rate must be positive, got %s", s)
+		}
+		return fps, nil
+	}
+
+	if n, err := strconv.Atoi(s); err == nil {
+		fps := IntFPS(n)
+		if !fps.Valid() {
+			return FPS{}, fmt.Errorf("frame rate must be positive, got %s", s)
+		}
+		return fps, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return FPS{}, fmt.Errorf("invalid frame rate %q", s)
+	}
+	if f <= 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return FPS{}, fmt.Errorf("frame rate must be positive, got %s", s)
+	}
+
+	// Snap to NTSC rates when the decimal is a common abbreviation of one
+	for _, ntsc := range []FPS{FPS23976, FPS2997, FPS5994} {
+		if math.Abs(ntsc.Float64()-f) < 0.005 {
+			return ntsc, nil
+		}
+	}
+
+	// Otherwise represent the decimal with millisecond-frame precision,
+	// which rounds rates below 0.0005 down to nothing
+	fps := NewFPS(int(math.Round(f*1000)), 1000)
+	if !fps.Valid() {
+		return FPS{}, fmt.Errorf("frame rate %s is too low", s)
+	}
+	return fps, nil
+}
+
+// Valid reports whether the frame rate is positive
+func (f FPS) Valid() bool {
+	return f.Num > 0 && f.Den > 0
+}
+
+// Float64 returns the frame rate as a floating point number
+func (f FPS) Float64() float64 {
+	if f.Den == 0 {
+		return 0
+	}
+	return float64(f.Num) / float64(f.Den)
+}
+
+// FrameDuration returns the exact duration of a single frame.
+// It returns 0 for an invalid frame rate.
+func (f FPS) FrameDuration() time.Duration {
+	if !f.Valid() {
+		return 0
+	}
+	return time.Duration(int64(time.Second) * int64(f.Den) / int64(f.Num))
+}
+
+// FrameTime returns the presentation time of frame n relative to the first
+// frame. Unlike n*FrameDuration() it does not accumulate rounding error.
+func (f FPS) FrameTime(n int) time.Duration {
+	if !f.Valid() {
+		return 0
+	}
+	return time.Duration(int64(n) * int64(time.Second) * int64(f.Den) / int64(f.Num))
+}
+
+// FramesIn returns the number of whole frames that fit in d
+func (f FPS) FramesIn(d time.Duration) int {
+	if !f.Valid() || d <= 0 {
+		return 0
+	}
+	return int(int64(d) * int64(f.Num) / (int64(time.Second) * int64(f.Den)))
+}
+
+// String formats the frame rate as an integer when whole, otherwise as num/den
+func (f FPS) String() string {
+	if f.Den == 1 {
+		return strconv.Itoa(f.Num)
+	}
+	return fmt.Sprintf("%d/%d", f.Num, f.Den)
+}
+
+// gcd returns the greatest common divisor of a and b
+func gcd(a, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,112 @@
+package capture
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewFPS(t *testing.T) {
+	tests := []struct {
+		name     string
+		num, den int
+		want     FPS
+	}{
+		{name: "already reduced", num: 30000, den: 1001, want: FPS{Num: 30000, Den: 1001}},
+		{name: "reduces to lowest terms", num: 60, den: 2, want: FPS{Num: 30, Den: 1}},
+		{name: "negative denominator", num: -15, den: -1, want: FPS{Num: 15, Den: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewFPS(tt.num, tt.den); got != tt.want {
+				t.Errorf("NewFPS(%d, %d) = %v, want %v", tt.num, tt.den, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFPS(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    FPS
+		wantErr bool
+	}{
+		{name: "integer", input: "15", want: FPS15},
+		{name: "rational", input: "30000/1001", want: FPS2997},
+		{name: "reducible rational", input: "48/2", want: FPS24},
+		{name: "NTSC decimal 29.97", input: "29.97", want: FPS2997},
+		{name: "NTSC decimal 23.976", input: "23.976", want: FPS23976},
+		{name: "NTSC decimal 59.94", input: "59.94", want: FPS5994},
+		{name: "plain decimal", input: "12.5", want: FPS{Num: 25, Den: 2}},
+		{name: "whitespace", input: " 30 ", want: FPS30},
+		{name: "empty", input: "", wantErr: true},
+		{name: "zero", input: "0", wantErr: true},
+		{name: "negative", input: "-10", wantErr: true},
+		{name: "decimal rounding to zero", input: "0.0004", wantErr: true},
+		{name: "zero denominator", input: "30/0", wantErr: true},
+		{name: "garbage", input: "fast", wantErr: true},
+		{name: "bad numerator", input: "x/1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFPS(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseFPS(%q) expected error, got %v", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFPS(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFPS(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFPSFrameDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		fps  FPS
+		want time.Duration
+	}{
+		{name: "30 FPS", fps: FPS30, want: 33333333 * time.Nanosecond},
+		{name: "29.97 FPS", fps: FPS2997, want: 33366666 * time.Nanosecond},
+		{name: "half FPS", fps: NewFPS(1, 2), want: 2 * time.Second},
+		{name: "invalid", fps: FPS{}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fps.FrameDuration(); got != tt.want {
+				t.Errorf("FrameDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFPSFrameTimeNoDrift(t *testing.T) {
+	// 30000 frames at 29.97 FPS is exactly 1001 seconds
+	if got := FPS2997.FrameTime(30000); got != 1001*time.Second {
+		t.Errorf("FrameTime(30000) = %v, want %v", got, 1001*time.Second)
+	}
+	if got := FPS2997.FramesIn(1001 * time.Second); got != 30000 {
+		t.Errorf("FramesIn(1001s) = %d, want 30000", got)
+	}
+}
+
+func TestFPSString(t *testing.T) {
+	if got := FPS15.String(); got != "15" {
+		t.Errorf("String() = %q, want %q", got, "15")
+	}
+	if got := FPS2997.String(); got != "30000/1001" {
+		t.Errorf("String() = %q, want %q", got, "30000/1001")
+	}
+	if got := FPS2997.Float64(); got < 29.97 || got > 29.98 {
+		t.Errorf("Float64() = %v, want ~29.97", got)
+	}
+}
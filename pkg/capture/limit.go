@@ -0,0 +1,74 @@
+package capture
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limit ends a capture once Config.MaxFrames frames have been delivered or
+// Config.MaxDuration has passed since Start. Capturers embed one, calling
+// Start with their config, Reached before grabbing each frame, and
+// Delivered after sending one. Once Reached returns an error they send it
+// and stop capturing. The zero value has no limits.
+type Limit struct {
+	mu          sync.Mutex
+	maxFrames   int
+	maxDuration time.Duration
+	start       time.Time
+	delivered   int
+}
+
+// Start sets the limits from config and starts the clock
+func (l *Limit) Start(config Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.maxFrames = config.MaxFrames
+	l.maxDuration = config.MaxDuration
+	l.start = time.Now()
+	l.delivered = 0
+}
+
+// Delivered counts a frame sent on the Frames channel
+func (l *Limit) Delivered() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.delivered++
+}
+
+// Reached returns an error wrapping ErrLimitReached once either limit has
+// been reached, or nil
+func (l *Limit) Reached() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxFrames > 0 && l.delivered >= l.maxFrames {
+		return fmt.Errorf("%w after %d frames", ErrLimitReached, l.maxFrames)
+	}
+	if l.maxDuration > 0 && !l.start.IsZero() && time.Since(l.start) >= l.maxDuration {
+		return fmt.Errorf("%w after %v", ErrLimitReached, l.maxDuration)
+	}
+	return nil
+}
+
+// Remaining returns config with its limits reduced by the frames already
+// delivered and the time already elapsed, for a capturer that continues an
+// interrupted recording. It returns an error wrapping ErrLimitReached if
+// nothing remains.
+func (c Config) Remaining(delivered int, elapsed time.Duration) (Config, error) {
+	if c.MaxFrames > 0 {
+		if delivered >= c.MaxFrames {
+			return c, fmt.Errorf("%w after %d frames", ErrLimitReached, c.MaxFrames)
+		}
+		c.MaxFrames -= delivered
+	}
+	if c.MaxDuration > 0 {
+		if elapsed >= c.MaxDuration {
+			return c, fmt.Errorf("%w after %v", ErrLimitReached, c.MaxDuration)
+		}
+		c.MaxDuration -= elapsed
+	}
+	return c, nil
+}
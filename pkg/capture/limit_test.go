@@ -0,0 +1,108 @@
+package capture
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimitFrames(t *testing.T) {
+	var limit Limit
+	if err := limit.Reached(); err != nil {
+		t.Errorf("Reached() on the zero value = %v, want nil", err)
+	}
+
+	limit.Start(Config{MaxFrames: 2})
+	limit.Delivered()
+	if err := limit.Reached(); err != nil {
+		t.Errorf("Reached() after 1 of 2 frames = %v, want nil", err)
+	}
+	limit.Delivered()
+	if err := limit.Reached(); !errors.Is(err, ErrLimitReached) {
+		t.Errorf("Reached() after 2 of 2 frames = %v, want ErrLimitReached", err)
+	}
+
+	// Starting again resets the count
+	limit.Start(Config{MaxFrames: 2})
+	if err := limit.Reached(); err != nil {
+		t.Errorf("Reached() after restarting = %v, want nil", err)
+	}
+}
+
+func TestLimitDuration(t *testing.T) {
+	var limit Limit
+	limit.Start(Config{MaxDuration: 20 * time.Millisecond})
+	if err := limit.Reached(); err != nil {
+		t.Errorf("Reached() right after Start = %v, want nil", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := limit.Reached(); !errors.Is(err, ErrLimitReached) {
+		t.Errorf("Reached() after MaxDuration = %v, want ErrLimitReached", err)
+	}
+}
+
+func TestConfigRemaining(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		delivered int
+		elapsed   time.Duration
+		want      Config
+		wantErr   bool
+	}{
+		{"no limits", Config{}, 100, time.Minute, Config{}, false},
+		{"frames left", Config{MaxFrames: 10}, 4, 0, Config{MaxFrames: 6}, false},
+		{"time left", Config{MaxDuration: 30 * time.Second}, 0, 10 * time.Second, Config{MaxDuration: 20 * time.Second}, false},
+		{"no frames left", Config{MaxFrames: 10}, 10, 0, Config{}, true},
+		{"no time left", Config{MaxDuration: time.Second, MaxFrames: 10}, 1, time.Second, Config{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.Remaining(tt.delivered, tt.elapsed)
+			if tt.wantErr {
+				if !errors.Is(err, ErrLimitReached) {
+					t.Errorf("Remaining() error = %v, want ErrLimitReached", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Remaining() error = %v", err)
+			}
+			if got.MaxFrames != tt.want.MaxFrames || got.MaxDuration != tt.want.MaxDuration {
+				t.Errorf("Remaining() = %d frames, %v, want %d frames, %v",
+					got.MaxFrames, got.MaxDuration, tt.want.MaxFrames, tt.want.MaxDuration)
+			}
+		})
+	}
+}
+
+func TestPatternCapturerStopsAtMaxFrames(t *testing.T) {
+	p := NewPatternCapturer(Config{Region: &Region{Width: 16, Height: 16}, MaxFrames: 3}, PatternBars)
+	p.Realtime = false
+	if err := p.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer p.Stop()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-p.Frames():
+		case <-time.After(time.Second):
+			t.Fatalf("frame %d was not delivered", i)
+		}
+	}
+	select {
+	case err := <-p.Errors():
+		if !errors.Is(err, ErrLimitReached) {
+			t.Errorf("error = %v, want ErrLimitReached", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("capturer did not stop at MaxFrames")
+	}
+	select {
+	case <-p.Frames():
+		t.Error("frame delivered past MaxFrames")
+	default:
+	}
+}
@@ -10,20 +10,24 @@ import (
 
 // MockCapturer is a mock implementation of the Capturer interface for testing
 type MockCapturer struct {
-	config    Config
-	frames    chan *Frame
-	errors    chan error
-	stopChan  chan struct{}
-	isRunning bool
-	mu        sync.Mutex
+	config   Config
+	frames   chan *Frame
+	errors   chan error
+	stopChan chan struct{}
+	done     chan struct{} // Closed when captureLoop returns
+	state    State
+	mu       sync.Mutex
+	pause    PauseGate
+	stats    StatsCounter
+	limit    Limit
 
 	// Configuration options for the mock
-	FrameWidth     int
-	FrameHeight    int
-	FrameColor     color.Color
-	FramesToSend   int
-	SimulateError  error
-	FrameDelay     time.Duration
+	FrameWidth    int
+	FrameHeight   int
+	FrameColor    color.Color
+	FramesToSend  int
+	SimulateError error
+	FrameDelay    time.Duration
 }
 
 // NewMockCapturer creates a new mock capturer for testing
@@ -33,6 +37,7 @@ func NewMockCapturer(config Config) *MockCapturer {
 		frames:       make(chan *Frame, 10),
 		errors:       make(chan error, 10),
 		stopChan:     make(chan struct{}),
+		done:         make(chan struct{}),
 		FrameWidth:   640,
 		FrameHeight:  480,
 		FrameColor:   color.RGBA{R: 128, G: 128, B: 128, A: 255},
@@ -46,8 +51,8 @@ func (m *MockCapturer) Start() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.isRunning {
-		return fmt.Errorf("capturer already running")
+	if m.state != StateIdle {
+		return ErrAlreadyRunning
 	}
 
 	// Simulate an error if configured
@@ -55,7 +60,9 @@ func (m *MockCapturer) Start() error {
 		return m.SimulateError
 	}
 
-	m.isRunning = true
+	m.state = StateRunning
+	m.stats.Start()
+	m.limit.Start(m.config)
 	go m.captureLoop()
 
 	return nil
@@ -63,19 +70,56 @@ func (m *MockCapturer) Start() error {
 
 // Stop ends the mock capture process
 func (m *MockCapturer) Stop() error {
+	m.mu.Lock()
+	if m.state != StateRunning {
+		m.mu.Unlock()
+		return ErrNotRunning
+	}
+	m.state = StateStopping
+	m.mu.Unlock()
+
+	close(m.stopChan)
+	<-m.done
+	m.stats.Stop()
+
+	m.mu.Lock()
+	m.state = StateIdle
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Pause holds back frames until Resume is called
+func (m *MockCapturer) Pause() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if !m.isRunning {
-		return fmt.Errorf("capturer not running")
+	if m.state != StateRunning {
+		return ErrNotRunning
 	}
+	m.pause.Pause()
 
-	close(m.stopChan)
-	m.isRunning = false
+	return nil
+}
+
+// Resume delivers frames again after Pause
+func (m *MockCapturer) Resume() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state != StateRunning {
+		return ErrNotRunning
+	}
+	m.pause.Resume()
 
 	return nil
 }
 
+// Stats returns the capture statistics since Start
+func (m *MockCapturer) Stats() Stats {
+	return m.stats.Stats()
+}
+
 // Frames returns the channel for captured frames
 func (m *MockCapturer) Frames() <-chan *Frame {
 	return m.frames
@@ -90,13 +134,24 @@ func (m *MockCapturer) Errors() <-chan error {
 func (m *MockCapturer) IsRunning() bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	return m.isRunning
+	return m.state == StateRunning
+}
+
+// State returns the current lifecycle state
+func (m *MockCapturer) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state == StateRunning && m.pause.Paused() {
+		return StatePaused
+	}
+	return m.state
 }
 
 // captureLoop generates mock frames at the configured FPS
 func (m *MockCapturer) captureLoop() {
-	ticker := time.NewTicker(time.Second / time.Duration(m.config.FPS))
+	ticker := time.NewTicker(m.config.FPS.FrameDuration())
 	defer ticker.Stop()
+	defer close(m.done)
 	defer close(m.frames)
 	defer close(m.errors)
 
@@ -111,15 +166,35 @@ func (m *MockCapturer) captureLoop() {
 			if m.FramesToSend >= 0 && frameCount >= m.FramesToSend {
 				return
 			}
+			if err := m.limit.Reached(); err != nil {
+				select {
+				case m.errors <- err:
+				case <-m.stopChan:
+				}
+				return
+			}
 
-			// Apply frame delay if configured
+			// Apply frame delay if configured; it counts as capture latency
+			grabbed := time.Now()
 			if m.FrameDelay > 0 {
 				time.Sleep(m.FrameDelay)
 			}
 
 			// Generate a mock frame
 			frame := m.generateFrame()
-			m.frames <- frame
+			frame.Image = m.config.Downscale(frame.Image)
+			m.stats.Captured(time.Since(grabbed))
+			if !m.pause.Admit(frame) {
+				m.stats.Dropped()
+				continue
+			}
+			select {
+			case m.frames <- frame:
+			case <-m.stopChan:
+				m.stats.Dropped()
+				return
+			}
+			m.limit.Delivered()
 			frameCount++
 		}
 	}
@@ -167,20 +242,28 @@ func (m *MockCapturer) GenerateCustomFrame(width, height int, fillFunc func(x, y
 	}
 }
 
-// SendFrame manually sends a frame to the frames channel (useful for controlled testing)
+// SendFrame manually sends a frame to the frames channel (useful for
+// controlled testing). Frames sent while paused are dropped.
 func (m *MockCapturer) SendFrame(frame *Frame) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if !m.isRunning {
-		return fmt.Errorf("capturer not running")
+	if m.state != StateRunning {
+		return ErrNotRunning
+	}
+	m.stats.Captured(0)
+	if !m.pause.Admit(frame) {
+		m.stats.Dropped()
+		return nil
 	}
 
 	select {
 	case m.frames <- frame:
+		m.limit.Delivered()
 		return nil
 	case <-time.After(time.Second):
-		return fmt.Errorf("timeout sending frame")
+		m.stats.Dropped()
+		return fmt.Errorf("timeout sending frame: %w", ErrTimeout)
 	}
 }
 
@@ -189,14 +272,14 @@ func (m *MockCapturer) SendError(err error) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if !m.isRunning {
-		return fmt.Errorf("capturer not running")
+	if m.state != StateRunning {
+		return ErrNotRunning
 	}
 
 	select {
 	case m.errors <- err:
 		return nil
 	case <-time.After(time.Second):
-		return fmt.Errorf("timeout sending error")
+		return fmt.Errorf("timeout sending error: %w", ErrTimeout)
 	}
 }
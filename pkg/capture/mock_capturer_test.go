@@ -1,6 +1,7 @@
 package capture
 
 import (
+	"errors"
 	"fmt"
 	"image/color"
 	"testing"
@@ -9,7 +10,7 @@ import (
 
 func TestMockCapturerStartStop(t *testing.T) {
 	config := Config{
-		FPS:       15,
+		FPS:       IntFPS(15),
 		DisplayID: 0,
 	}
 
@@ -48,9 +49,70 @@ func TestMockCapturerStartStop(t *testing.T) {
 	}
 }
 
+func TestMockCapturerState(t *testing.T) {
+	// MockCapturer must be usable anywhere a Capturer is expected
+	var capturer Capturer = NewMockCapturer(Config{FPS: IntFPS(15)})
+
+	if capturer.State() != StateIdle {
+		t.Errorf("State() = %v, want %v", capturer.State(), StateIdle)
+	}
+
+	if err := capturer.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if capturer.State() != StateRunning || !capturer.IsRunning() {
+		t.Errorf("State() = %v, want %v", capturer.State(), StateRunning)
+	}
+
+	if err := capturer.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	if capturer.State() != StateIdle || capturer.IsRunning() {
+		t.Errorf("State() = %v, want %v", capturer.State(), StateIdle)
+	}
+}
+
+func TestMockCapturerStateStopping(t *testing.T) {
+	// A long frame delay keeps the capture loop busy, so Stop has to wait
+	capturer := NewMockCapturer(Config{FPS: IntFPS(100)})
+	capturer.FrameDelay = 300 * time.Millisecond
+
+	if err := capturer.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- capturer.Stop() }()
+
+	seen := false
+	for !seen {
+		select {
+		case err := <-stopped:
+			t.Fatalf("Stop() returned (%v) before State() reported %v", err, StateStopping)
+		default:
+		}
+		seen = capturer.State() == StateStopping
+		time.Sleep(time.Millisecond)
+	}
+	if capturer.IsRunning() {
+		t.Error("IsRunning() = true while stopping")
+	}
+	if err := capturer.Stop(); err == nil {
+		t.Error("second Stop() while stopping succeeded")
+	}
+
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	if capturer.State() != StateIdle {
+		t.Errorf("State() = %v after Stop, want %v", capturer.State(), StateIdle)
+	}
+}
+
 func TestMockCapturerFrames(t *testing.T) {
 	config := Config{
-		FPS:       30,
+		FPS:       IntFPS(30),
 		DisplayID: 0,
 	}
 
@@ -102,7 +164,7 @@ func TestMockCapturerWithRegion(t *testing.T) {
 
 	config := Config{
 		Region:    region,
-		FPS:       15,
+		FPS:       IntFPS(15),
 		DisplayID: 0,
 	}
 
@@ -129,7 +191,7 @@ func TestMockCapturerWithRegion(t *testing.T) {
 }
 
 func TestMockCapturerCustomFrame(t *testing.T) {
-	capturer := NewMockCapturer(Config{FPS: 15})
+	capturer := NewMockCapturer(Config{FPS: IntFPS(15)})
 
 	// Generate a custom frame with a gradient
 	frame := capturer.GenerateCustomFrame(100, 100, func(x, y int) color.Color {
@@ -152,7 +214,7 @@ func TestMockCapturerCustomFrame(t *testing.T) {
 }
 
 func TestMockCapturerSendFrame(t *testing.T) {
-	config := Config{FPS: 15}
+	config := Config{FPS: IntFPS(15)}
 	capturer := NewMockCapturer(config)
 
 	// Should fail when not running
@@ -190,8 +252,95 @@ func TestMockCapturerSendFrame(t *testing.T) {
 	}
 }
 
+func TestMockCapturerPauseResume(t *testing.T) {
+	// A slow rate keeps generated frames out of the way
+	capturer := NewMockCapturer(Config{FPS: IntFPS(1)})
+
+	if err := capturer.Pause(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Pause() before Start() error = %v, want %v", err, ErrNotRunning)
+	}
+
+	capturer.Start()
+	defer capturer.Stop()
+
+	if err := capturer.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if capturer.State() != StatePaused || !capturer.IsRunning() {
+		t.Errorf("State() = %v, IsRunning() = %v, want %v and true", capturer.State(), capturer.IsRunning(), StatePaused)
+	}
+
+	// Frames are dropped while paused
+	if err := capturer.SendFrame(&Frame{}); err != nil {
+		t.Fatalf("SendFrame() failed: %v", err)
+	}
+	select {
+	case <-capturer.Frames():
+		t.Error("received a frame while paused")
+	default:
+	}
+
+	if err := capturer.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	if capturer.State() != StateRunning {
+		t.Errorf("State() = %v, want %v", capturer.State(), StateRunning)
+	}
+
+	// Only the first frame after resuming is marked
+	for i, want := range []bool{true, false} {
+		if err := capturer.SendFrame(&Frame{}); err != nil {
+			t.Fatalf("SendFrame() failed: %v", err)
+		}
+		if frame := <-capturer.Frames(); frame.Discontinuity != want {
+			t.Errorf("frame %d Discontinuity = %v, want %v", i, frame.Discontinuity, want)
+		}
+	}
+}
+
+func TestMockCapturerStats(t *testing.T) {
+	// A slow rate keeps generated frames out of the way
+	capturer := NewMockCapturer(Config{FPS: IntFPS(1)})
+	capturer.Start()
+
+	capturer.Pause()
+	capturer.SendFrame(&Frame{})
+	capturer.Resume()
+	for i := 0; i < 2; i++ {
+		capturer.SendFrame(&Frame{})
+		<-capturer.Frames()
+	}
+	capturer.Stop()
+
+	s := capturer.Stats()
+	if s.FramesCaptured != 3 || s.FramesDropped != 1 {
+		t.Errorf("captured, dropped = %d, %d, want 3, 1", s.FramesCaptured, s.FramesDropped)
+	}
+	if s.Elapsed <= 0 || s.EffectiveFPS <= 0 {
+		t.Errorf("Elapsed = %v, EffectiveFPS = %v, want both positive", s.Elapsed, s.EffectiveFPS)
+	}
+}
+
+func TestMockCapturerStatsLatency(t *testing.T) {
+	capturer := NewMockCapturer(Config{FPS: IntFPS(100)})
+	capturer.FrameWidth, capturer.FrameHeight = 8, 8
+	capturer.FrameDelay = 5 * time.Millisecond
+	capturer.FramesToSend = 3
+	capturer.Start()
+	defer capturer.Stop()
+
+	for i := 0; i < 3; i++ {
+		<-capturer.Frames()
+	}
+
+	// FrameDelay simulates a slow capture
+	if s := capturer.Stats(); s.AverageLatency < capturer.FrameDelay {
+		t.Errorf("AverageLatency = %v, want at least %v", s.AverageLatency, capturer.FrameDelay)
+	}
+}
+
 func TestMockCapturerSendError(t *testing.T) {
-	config := Config{FPS: 15}
+	config := Config{FPS: IntFPS(15)}
 	capturer := NewMockCapturer(config)
 
 	// Should fail when not running
@@ -226,7 +375,7 @@ func TestMockCapturerSendError(t *testing.T) {
 }
 
 func TestMockCapturerSimulateError(t *testing.T) {
-	config := Config{FPS: 15}
+	config := Config{FPS: IntFPS(15)}
 	capturer := NewMockCapturer(config)
 
 	testErr := fmt.Errorf("simulated startup error")
@@ -243,7 +392,7 @@ func TestMockCapturerSimulateError(t *testing.T) {
 
 func TestMockCapturerFPSRate(t *testing.T) {
 	config := Config{
-		FPS: 30, // High FPS for testing
+		FPS: IntFPS(30), // High FPS for testing
 	}
 
 	capturer := NewMockCapturer(config)
@@ -272,7 +421,7 @@ func TestMockCapturerFPSRate(t *testing.T) {
 }
 
 func TestMockCapturerCustomColor(t *testing.T) {
-	config := Config{FPS: 15}
+	config := Config{FPS: IntFPS(15)}
 	capturer := NewMockCapturer(config)
 	capturer.FrameWidth = 10
 	capturer.FrameHeight = 10
@@ -0,0 +1,378 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/internal/font"
+)
+
+// Pattern is a synthetic image generated by PatternCapturer
+type Pattern int
+
+const (
+	// PatternGradient is a color gradient that scrolls diagonally, so every
+	// pixel changes on every frame
+	PatternGradient Pattern = iota
+	// PatternBars is the SMPTE color bar test card
+	PatternBars
+)
+
+// String returns the pattern's name
+func (p Pattern) String() string {
+	switch p {
+	case PatternGradient:
+		return "gradient"
+	case PatternBars:
+		return "bars"
+	default:
+		return fmt.Sprintf("Pattern(%d)", int(p))
+	}
+}
+
+// ParsePattern parses a pattern name
+func ParsePattern(s string) (Pattern, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "gradient":
+		return PatternGradient, nil
+	case "bars", "smpte":
+		return PatternBars, nil
+	default:
+		return 0, fmt.Errorf("unknown pattern %q (want gradient or bars)", s)
+	}
+}
+
+// PatternCapturer generates synthetic frames for exercising encoders
+// without a screen. Frame n always has the same pixels, so output can be
+// compared between runs and machines.
+type PatternCapturer struct {
+	// Pattern is the image to draw
+	Pattern Pattern
+
+	// Timecode burns the frame's HH:MM:SS:FF timecode into it
+	Timecode bool
+
+	// Realtime paces frames at the configured rate. Otherwise frames are
+	// generated as fast as they are read, for benchmarking.
+	Realtime bool
+
+	config   Config
+	size     image.Point
+	start    time.Time
+	frames   chan *Frame
+	errors   chan error
+	stopChan chan struct{}
+	done     chan struct{}
+	state    State
+	mu       sync.Mutex
+	pause    PauseGate
+	stats    StatsCounter
+	limit    Limit
+}
+
+// NewPatternCapturer creates a capturer that generates the pattern in real
+// time with a burned-in timecode. Frames are the size of Config.Region, or
+// 640x480 without one.
+func NewPatternCapturer(config Config, pattern Pattern) *PatternCapturer {
+	if !config.FPS.Valid() {
+		config.FPS = FPS15
+	}
+	size := image.Pt(640, 480)
+	if config.Region != nil {
+		size = image.Pt(config.Region.Width, config.Region.Height)
+	}
+
+	return &PatternCapturer{
+		Pattern:  pattern,
+		Timecode: true,
+		Realtime: true,
+		config:   config,
+		size:     size,
+		frames:   make(chan *Frame, 30),
+		errors:   make(chan error, 10),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins generating frames
+func (p *PatternCapturer) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state != StateIdle {
+		return ErrAlreadyRunning
+	}
+	if p.size.X <= 0 || p.size.Y <= 0 {
+		return fmt.Errorf("invalid pattern size %dx%d", p.size.X, p.size.Y)
+	}
+
+	p.start = time.Now()
+	p.state = StateRunning
+	p.stats.Start()
+	p.limit.Start(p.config)
+	go p.generateLoop()
+
+	return nil
+}
+
+// Stop ends frame generation
+func (p *PatternCapturer) Stop() error {
+	p.mu.Lock()
+	if p.state != StateRunning {
+		p.mu.Unlock()
+		return ErrNotRunning
+	}
+	p.state = StateStopping
+	p.mu.Unlock()
+
+	close(p.stopChan)
+	<-p.done
+	p.stats.Stop()
+
+	p.mu.Lock()
+	p.state = StateIdle
+	close(p.frames)
+	close(p.errors)
+	p.mu.Unlock()
+
+	return nil
+}
+
+// Pause holds back frames until Resume is called
+func (p *PatternCapturer) Pause() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state != StateRunning {
+		return ErrNotRunning
+	}
+	p.pause.Pause()
+
+	return nil
+}
+
+// Resume delivers frames again after Pause
+func (p *PatternCapturer) Resume() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state != StateRunning {
+		return ErrNotRunning
+	}
+	p.pause.Resume()
+
+	return nil
+}
+
+// Stats returns the capture statistics since Start
+func (p *PatternCapturer) Stats() Stats {
+	return p.stats.Stats()
+}
+
+// Frames returns the channel for generated frames
+func (p *PatternCapturer) Frames() <-chan *Frame {
+	return p.frames
+}
+
+// Errors returns the channel for errors, which only receives
+// ErrLimitReached
+func (p *PatternCapturer) Errors() <-chan error {
+	return p.errors
+}
+
+// IsRunning returns whether the capturer is currently running
+func (p *PatternCapturer) IsRunning() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state == StateRunning
+}
+
+// State returns the current lifecycle state
+func (p *PatternCapturer) State() State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state == StateRunning && p.pause.Paused() {
+		return StatePaused
+	}
+	return p.state
+}
+
+// generateLoop sends frames until stopped
+func (p *PatternCapturer) generateLoop() {
+	defer close(p.done)
+
+	var tick <-chan time.Time
+	if p.Realtime {
+		ticker := time.NewTicker(p.config.FPS.FrameDuration())
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for n := 0; ; n++ {
+		if tick != nil {
+			select {
+			case <-p.stopChan:
+				return
+			case <-tick:
+			}
+		}
+
+		if err := p.limit.Reached(); err != nil {
+			select {
+			case p.errors <- err:
+			case <-p.stopChan:
+			}
+			return
+		}
+
+		// While paused, hold the next frame until Resume
+		generated := time.Now()
+		frame := p.Frame(n)
+		frame.Image = p.config.Downscale(frame.Image)
+		p.stats.Captured(time.Since(generated))
+		for !p.pause.Admit(frame) {
+			if !p.pause.Wait(p.stopChan) {
+				p.stats.Dropped()
+				return
+			}
+		}
+		select {
+		case p.frames <- frame:
+			p.limit.Delivered()
+		case <-p.stopChan:
+			p.stats.Dropped()
+			return
+		}
+	}
+}
+
+// Frame generates frame n. Its timestamp is n frame times after Start.
+func (p *PatternCapturer) Frame(n int) *Frame {
+	img := image.NewRGBA(image.Rectangle{Max: p.size})
+	switch p.Pattern {
+	case PatternBars:
+		drawBars(img)
+	default:
+		drawGradient(img, n)
+	}
+	if p.Timecode {
+		drawTimecode(img, timecode(n, p.config.FPS))
+	}
+
+	return &Frame{
+		Image:     img,
+		Timestamp: p.start.Add(p.config.FPS.FrameTime(n)),
+	}
+}
+
+// drawGradient fills img with red rising left to right and green top to
+// bottom, scrolled by n pixels, with blue cycling over time
+func drawGradient(img *image.RGBA, n int) {
+	b := img.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < b.Dx(); x++ {
+			i := 4 * x
+			row[i] = uint8((x + n) * 256 / b.Dx())
+			row[i+1] = uint8((y + n) * 256 / b.Dy())
+			row[i+2] = uint8(n * 4)
+			row[i+3] = 255
+		}
+	}
+}
+
+// SMPTE bar colors (EG 1-1990) at 75% intensity, with the PLUGE blacks
+// 4% below and above black
+var (
+	bars75 = []color.RGBA{
+		{191, 191, 191, 255}, {191, 191, 0, 255}, {0, 191, 191, 255}, {0, 191, 0, 255},
+		{191, 0, 191, 255}, {191, 0, 0, 255}, {0, 0, 191, 255},
+	}
+	barsCastellations = []color.RGBA{
+		{0, 0, 191, 255}, {19, 19, 19, 255}, {191, 0, 191, 255}, {19, 19, 19, 255},
+		{0, 191, 191, 255}, {19, 19, 19, 255}, {191, 191, 191, 255},
+	}
+	barsMinusI = color.RGBA{0, 33, 76, 255}
+	barsWhite  = color.RGBA{255, 255, 255, 255}
+	barsPlusQ  = color.RGBA{50, 0, 106, 255}
+	barsBlack  = color.RGBA{19, 19, 19, 255}
+	barsPluge  = []color.RGBA{{9, 9, 9, 255}, {19, 19, 19, 255}, {29, 29, 29, 255}}
+)
+
+// drawBars draws the SMPTE color bars: seven bars over two thirds of the
+// height, a strip of reversed blue bars, and -I, white, +Q, and PLUGE
+// blocks along the bottom quarter
+func drawBars(img *image.RGBA) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	barX := func(i int) int { return i * w / 7 } // Left edge of bar i
+	topH, midH := h*2/3, h*3/4
+
+	for i := 0; i < 7; i++ {
+		fill(img, image.Rect(barX(i), 0, barX(i+1), topH), bars75[i])
+		fill(img, image.Rect(barX(i), topH, barX(i+1), midH), barsCastellations[i])
+	}
+
+	// The bottom row splits the first five bars' width into four blocks and
+	// the sixth bar's into three PLUGE steps
+	blockX := func(i int) int { return i * barX(5) / 4 }
+	for i, c := range []color.RGBA{barsMinusI, barsWhite, barsPlusQ, barsBlack} {
+		fill(img, image.Rect(blockX(i), midH, blockX(i+1), h), c)
+	}
+	plugeX := func(i int) int { return barX(5) + i*(barX(6)-barX(5))/3 }
+	for i, c := range barsPluge {
+		fill(img, image.Rect(plugeX(i), midH, plugeX(i+1), h), c)
+	}
+	fill(img, image.Rect(barX(6), midH, w, h), barsBlack)
+}
+
+// fill sets every pixel of r in img to c
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// timecode formats frame n as non-drop-frame HH:MM:SS:FF, counting frames
+// at the rate rounded up to a whole number as broadcast timecode does
+func timecode(n int, fps FPS) string {
+	base := (fps.Num + fps.Den - 1) / fps.Den
+	if base <= 0 {
+		base = 1
+	}
+	frames := n % base
+	seconds := n / base
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60, frames)
+}
+
+// drawTimecode draws tc in white on a black box centered near the bottom,
+// scaled with the frame height
+func drawTimecode(img *image.RGBA, tc string) {
+	b := img.Bounds()
+	scale := max(1, b.Dy()/120)
+	width, height := font.TextSize(tc, scale)
+	x := b.Min.X + (b.Dx()-width)/2
+	y := b.Max.Y - height - 4*scale
+
+	fill(img, image.Rect(x-2*scale, y-2*scale, x+width+2*scale, y+height+2*scale), color.RGBA{A: 255})
+	for _, r := range tc {
+		g := font.Glyph(r)
+		for col := 0; col < font.Width; col++ {
+			for row := 0; row < font.Height; row++ {
+				if g[col]&(1<<row) != 0 {
+					px, py := x+col*scale, y+row*scale
+					fill(img, image.Rect(px, py, px+scale, py+scale), color.RGBA{255, 255, 255, 255})
+				}
+			}
+		}
+		x += (font.Width + 1) * scale
+	}
+}
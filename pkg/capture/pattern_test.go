@@ -0,0 +1,157 @@
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Pattern
+		wantErr bool
+	}{
+		{"gradient", PatternGradient, false},
+		{"Bars", PatternBars, false},
+		{"smpte", PatternBars, false},
+		{"noise", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePattern(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePattern(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePattern(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTimecode(t *testing.T) {
+	tests := []struct {
+		n    int
+		fps  FPS
+		want string
+	}{
+		{0, FPS30, "00:00:00:00"},
+		{29, FPS30, "00:00:00:29"},
+		{30, FPS30, "00:00:01:00"},
+		{30*3661 + 5, FPS30, "01:01:01:05"},
+		{30, FPS2997, "00:00:01:00"},
+		{24, FPS23976, "00:00:01:00"},
+		{16, FPS15, "00:00:01:01"},
+	}
+
+	for _, tt := range tests {
+		if got := timecode(tt.n, tt.fps); got != tt.want {
+			t.Errorf("timecode(%d, %v) = %q, want %q", tt.n, tt.fps, got, tt.want)
+		}
+	}
+}
+
+func TestPatternFramesAreReproducible(t *testing.T) {
+	config := Config{Region: &Region{Width: 64, Height: 48}, FPS: FPS30}
+	a := NewPatternCapturer(config, PatternGradient).Frame(7)
+	b := NewPatternCapturer(config, PatternGradient).Frame(7)
+	if !bytes.Equal(a.Image.Pix, b.Image.Pix) {
+		t.Error("frame 7 differs between capturers")
+	}
+
+	next := NewPatternCapturer(config, PatternGradient).Frame(8)
+	if bytes.Equal(a.Image.Pix, next.Image.Pix) {
+		t.Error("gradient did not move between frames")
+	}
+	if a.Image.Bounds().Dx() != 64 || a.Image.Bounds().Dy() != 48 {
+		t.Errorf("frame size = %v, want 64x48", a.Image.Bounds())
+	}
+}
+
+func TestPatternBars(t *testing.T) {
+	p := NewPatternCapturer(Config{Region: &Region{Width: 700, Height: 480}}, PatternBars)
+	p.Timecode = false
+	img := p.Frame(0).Image
+
+	tests := []struct {
+		name string
+		x, y int
+		want color.RGBA
+	}{
+		{"gray bar", 50, 100, bars75[0]},
+		{"yellow bar", 150, 100, bars75[1]},
+		{"blue bar", 650, 100, bars75[6]},
+		{"blue castellation", 50, 340, barsCastellations[0]},
+		{"-I block", 50, 450, barsMinusI},
+		{"white block", 200, 450, barsWhite},
+		{"super black PLUGE", 510, 450, barsPluge[0]},
+		{"bright PLUGE", 590, 450, barsPluge[2]},
+		{"black corner", 650, 450, barsBlack},
+	}
+
+	for _, tt := range tests {
+		if got := img.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("%s at (%d,%d) = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestPatternTimecodeBurnIn(t *testing.T) {
+	config := Config{Region: &Region{Width: 240, Height: 120}, FPS: FPS30}
+	p := NewPatternCapturer(config, PatternBars)
+
+	white := color.RGBA{255, 255, 255, 255}
+	countWhite := func(n int) int {
+		img := p.Frame(n).Image
+		count := 0
+		for y := 90; y < 120; y++ {
+			for x := 0; x < 240; x++ {
+				if img.RGBAAt(x, y) == white {
+					count++
+				}
+			}
+		}
+		return count
+	}
+
+	// Frame 0 is all zeros; frame 18 ends in 18, which lights more pixels
+	zero, later := countWhite(0), countWhite(18)
+	if zero == 0 {
+		t.Fatal("no timecode drawn")
+	}
+	if zero == later {
+		t.Error("timecode did not change between frames")
+	}
+}
+
+func TestPatternCapturerLifecycle(t *testing.T) {
+	p := NewPatternCapturer(Config{Region: &Region{Width: 16, Height: 16}, FPS: FPS30}, PatternGradient)
+	p.Realtime = false
+
+	if err := p.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if err := p.Start(); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("second Start() error = %v, want ErrAlreadyRunning", err)
+	}
+
+	first := <-p.Frames()
+	second := <-p.Frames()
+	if got := second.Timestamp.Sub(first.Timestamp); got != FPS30.FrameDuration() {
+		t.Errorf("frame interval = %v, want %v", got, FPS30.FrameDuration())
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	for range p.Frames() {
+	}
+	if p.IsRunning() {
+		t.Error("IsRunning() = true after Stop")
+	}
+	if err := p.Stop(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("second Stop() error = %v, want ErrNotRunning", err)
+	}
+}
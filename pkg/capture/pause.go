@@ -0,0 +1,81 @@
+package capture
+
+import "sync"
+
+// PauseGate holds back a capturer's frames while it is paused. Capturers
+// embed one to implement Pause and Resume: live sources pass each frame
+// through Admit and drop those it rejects, while sources that generate or
+// read frames on demand call Wait first so nothing is produced while
+// paused. The first frame admitted after a resume is marked as a
+// discontinuity. The zero value is an open gate.
+type PauseGate struct {
+	mu      sync.Mutex
+	paused  bool
+	resumed bool
+	resume  chan struct{} // Closed by Resume; nil when not paused
+}
+
+// Pause closes the gate. Pausing a paused gate does nothing.
+func (g *PauseGate) Pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.paused {
+		g.paused = true
+		g.resume = make(chan struct{})
+	}
+}
+
+// Resume opens the gate. Resuming an open gate does nothing.
+func (g *PauseGate) Resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused {
+		g.paused = false
+		g.resumed = true
+		close(g.resume)
+		g.resume = nil
+	}
+}
+
+// Paused reports whether the gate is closed
+func (g *PauseGate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Admit reports whether frame may be delivered, marking the first frame
+// after a resume as a discontinuity
+func (g *PauseGate) Admit(frame *Frame) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused {
+		return false
+	}
+	if g.resumed && frame != nil {
+		frame.Discontinuity = true
+		g.resumed = false
+	}
+	return true
+}
+
+// Wait blocks while the gate is closed. It returns false if stop is closed
+// first.
+func (g *PauseGate) Wait(stop <-chan struct{}) bool {
+	g.mu.Lock()
+	resume := g.resume
+	g.mu.Unlock()
+
+	if resume == nil {
+		return true
+	}
+	select {
+	case <-resume:
+		return true
+	case <-stop:
+		return false
+	}
+}
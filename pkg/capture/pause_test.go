@@ -0,0 +1,67 @@
+package capture
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPauseGate(t *testing.T) {
+	var gate PauseGate
+
+	if frame := (&Frame{}); !gate.Admit(frame) || frame.Discontinuity {
+		t.Error("an open gate should admit frames unmarked")
+	}
+
+	gate.Pause()
+	gate.Pause()
+	if !gate.Paused() {
+		t.Error("Paused() = false after Pause()")
+	}
+	if gate.Admit(&Frame{}) {
+		t.Error("a paused gate should reject frames")
+	}
+
+	gate.Resume()
+	first, second := &Frame{}, &Frame{}
+	if !gate.Admit(first) || !gate.Admit(second) {
+		t.Fatal("a resumed gate should admit frames")
+	}
+	if !first.Discontinuity || second.Discontinuity {
+		t.Errorf("discontinuity = %v, %v, want only the first frame marked", first.Discontinuity, second.Discontinuity)
+	}
+}
+
+func TestPauseGateWait(t *testing.T) {
+	var gate PauseGate
+	stop := make(chan struct{})
+
+	if !gate.Wait(stop) {
+		t.Error("Wait() on an open gate = false, want true")
+	}
+
+	gate.Pause()
+	resumed := make(chan bool)
+	go func() { resumed <- gate.Wait(stop) }()
+
+	select {
+	case <-resumed:
+		t.Fatal("Wait() returned while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	gate.Resume()
+	select {
+	case ok := <-resumed:
+		if !ok {
+			t.Error("Wait() = false after Resume(), want true")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait() did not return after Resume()")
+	}
+
+	gate.Pause()
+	close(stop)
+	if gate.Wait(stop) {
+		t.Error("Wait() = true after stop, want false")
+	}
+}
@@ -0,0 +1,22 @@
+package capture
+
+// ScreenRecordingSettingsURL opens the Screen Recording privacy settings on
+// macOS when passed to open(1)
+const ScreenRecordingSettingsURL = "x-apple.systempreferences:com.apple.preference.security?Privacy_ScreenCapture"
+
+// CheckPermission returns an error wrapping ErrPermissionDenied if the
+// process may not record the screen. macOS delivers blank frames instead of
+// failing when permission is missing, so NewCapturer checks first.
+// Platforms that ask for permission each time capture starts, such as
+// Wayland, always pass.
+func CheckPermission() error {
+	return platformCheckPermission()
+}
+
+// RequestPermission asks the system for screen recording permission and,
+// if it has not been granted, opens the settings where the user can grant
+// it. Permission usually only takes effect after the app holding it (such
+// as the terminal) is restarted.
+func RequestPermission() error {
+	return platformRequestPermission()
+}
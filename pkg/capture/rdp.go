@@ -0,0 +1,309 @@
+package capture
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/internal/rdp"
+)
+
+// rdpDialTimeout bounds connecting, signing in, and the RDP connection
+// sequence, which is slower than VNC's handshake
+const rdpDialTimeout = 20 * time.Second
+
+// ErrRDPWithoutNLA means the RDP server does not support Network Level
+// Authentication and RDPOptions.AllowTLSOnly is not set
+var ErrRDPWithoutNLA = rdp.ErrTLSOnly
+
+// RDPOptions holds the account, desktop size, and certificate check for an
+// RDP capture
+type RDPOptions struct {
+	// User is the account to sign in as: name, DOMAIN\name, or
+	// name@domain
+	User     string
+	Password string
+
+	// Width and Height are the desktop size to ask for. The server may
+	// pick another size.
+	Width  int
+	Height int
+
+	// Fingerprint pins the server's certificate by its SHA-256
+	// fingerprint. Without it, the certificate is trusted the first time
+	// it is seen and recorded in the KnownHosts file, and a different one
+	// is refused after that. With neither, the connection is refused.
+	Fingerprint string
+	KnownHosts  string
+
+	// Trusted is called when a server's certificate is recorded in
+	// KnownHosts for the first time
+	Trusted func(addr, fingerprint string)
+
+	// AllowTLSOnly signs in to servers without Network Level
+	// Authentication, which sends the password protected only by TLS
+	AllowTLSOnly bool
+}
+
+// ParseRDPFingerprint checks a SHA-256 certificate fingerprint for
+// RDPOptions.Fingerprint, given as hex with or without colons
+func ParseRDPFingerprint(s string) (string, error) {
+	return rdp.ParseFingerprint(s)
+}
+
+// rdpCapturer records a Windows desktop over the Remote Desktop Protocol,
+// so servers can be recorded without installing anything on them. Updates
+// are applied as the server sends them, and frames of the current screen
+// are emitted at the configured rate.
+type rdpCapturer struct {
+	addr     string
+	options  RDPOptions
+	config   Config
+	client   *rdp.Client
+	crop     image.Rectangle
+	frames   chan *Frame
+	errors   chan error
+	stopChan chan struct{}
+	done     chan struct{} // Closed when both loops have returned
+	state    State
+	mu       sync.Mutex
+	pause    PauseGate
+	stats    StatsCounter
+	limit    Limit
+}
+
+// NewRDPCapturer creates a capturer for the Remote Desktop server at addr
+// (host or host:port). Config.Region is in the remote screen's pixels.
+func NewRDPCapturer(addr string, options RDPOptions, config Config) Capturer {
+	return &rdpCapturer{
+		addr:     addr,
+		options:  options,
+		config:   config,
+		frames:   make(chan *Frame, 30),
+		errors:   make(chan error, 10),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start connects to the server, signs in, and begins receiving updates
+func (r *rdpCapturer) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state != StateIdle {
+		return ErrAlreadyRunning
+	}
+
+	verify, err := r.verify()
+	if err != nil {
+		return err
+	}
+	client, err := rdp.Dial(r.addr, rdp.Config{
+		Login:        rdp.ParseLogin(r.options.User, r.options.Password),
+		Size:         image.Pt(r.options.Width, r.options.Height),
+		Verify:       verify,
+		AllowTLSOnly: r.options.AllowTLSOnly,
+		Cursor:       r.config.IncludeCursor,
+	}, rdpDialTimeout)
+	switch {
+	case errors.Is(err, rdp.ErrAuthFailed):
+		// Not recoverable: retrying a wrong password can lock the account
+		return fmt.Errorf("failed to sign in to RDP server %s: %w", r.addr, err)
+	case errors.Is(err, rdp.ErrTLSOnly), errors.Is(err, rdp.ErrCertificateMismatch):
+		// Not recoverable: the server will not change on a retry
+		return fmt.Errorf("refused to sign in to RDP server %s: %w", r.addr, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to RDP server %s: %v: %w", r.addr, err, ErrStreamInterrupted)
+	}
+
+	width, height := client.Size()
+	r.crop = image.Rect(0, 0, width, height)
+	if region := r.config.Region; region != nil {
+		crop := image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height).Intersect(r.crop)
+		if crop.Empty() {
+			client.Close()
+			return fmt.Errorf("region %dx%d at (%d,%d) is outside the %dx%d RDP screen",
+				region.Width, region.Height, region.X, region.Y, width, height)
+		}
+		r.crop = crop
+	}
+
+	r.client = client
+	r.state = StateRunning
+	r.stats.Start()
+	r.limit.Start(r.config)
+
+	var loops sync.WaitGroup
+	loops.Add(2)
+	go func() {
+		defer loops.Done()
+		r.updateLoop()
+	}()
+	go func() {
+		defer loops.Done()
+		r.captureLoop()
+	}()
+	go func() {
+		loops.Wait()
+		close(r.done)
+	}()
+
+	return nil
+}
+
+// verify returns the check for the server's certificate
+func (r *rdpCapturer) verify() (func(*x509.Certificate) error, error) {
+	switch {
+	case r.options.Fingerprint != "":
+		return rdp.PinFingerprint(r.options.Fingerprint)
+	case r.options.KnownHosts != "":
+		return rdp.KnownHosts{Path: r.options.KnownHosts, Added: r.options.Trusted}.Verify(r.addr), nil
+	}
+	return nil, nil // Refused by rdp.Dial
+}
+
+// Stop disconnects from the server. The remote session stays signed in.
+func (r *rdpCapturer) Stop() error {
+	r.mu.Lock()
+	if r.state != StateRunning {
+		r.mu.Unlock()
+		return ErrNotRunning
+	}
+	r.state = StateStopping
+	r.mu.Unlock()
+
+	close(r.stopChan)
+	r.client.Close()
+	<-r.done
+	r.stats.Stop()
+
+	r.mu.Lock()
+	r.state = StateIdle
+	close(r.frames)
+	close(r.errors)
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Pause holds back frames until Resume is called
+func (r *rdpCapturer) Pause() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state != StateRunning {
+		return ErrNotRunning
+	}
+	r.pause.Pause()
+
+	return nil
+}
+
+// Resume delivers frames again after Pause
+func (r *rdpCapturer) Resume() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state != StateRunning {
+		return ErrNotRunning
+	}
+	r.pause.Resume()
+
+	return nil
+}
+
+// Stats returns the capture statistics since Start
+func (r *rdpCapturer) Stats() Stats {
+	return r.stats.Stats()
+}
+
+// Frames returns the channel for captured frames
+func (r *rdpCapturer) Frames() <-chan *Frame {
+	return r.frames
+}
+
+// Errors returns the channel for errors
+func (r *rdpCapturer) Errors() <-chan error {
+	return r.errors
+}
+
+// IsRunning returns whether the capturer is currently running
+func (r *rdpCapturer) IsRunning() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state == StateRunning
+}
+
+// State returns the current lifecycle state
+func (r *rdpCapturer) State() State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == StateRunning && r.pause.Paused() {
+		return StatePaused
+	}
+	return r.state
+}
+
+// updateLoop applies updates until the connection closes
+func (r *rdpCapturer) updateLoop() {
+	for {
+		if err := r.client.Update(); err != nil {
+			select {
+			case <-r.stopChan:
+			case r.errors <- fmt.Errorf("RDP connection lost: %v: %w", err, ErrStreamInterrupted):
+			}
+			return
+		}
+	}
+}
+
+// captureLoop emits the current screen at the configured rate
+func (r *rdpCapturer) captureLoop() {
+	fps := r.config.FPS
+	if !fps.Valid() {
+		fps = FPS15
+	}
+	ticker := time.NewTicker(fps.FrameDuration())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		case <-ticker.C:
+			if err := r.limit.Reached(); err != nil {
+				select {
+				case r.errors <- err:
+				case <-r.stopChan:
+				}
+				return
+			}
+			if r.pause.Paused() {
+				continue
+			}
+			grabbed := time.Now()
+			img := r.client.Image(r.crop)
+			if img == nil {
+				continue // No update received yet
+			}
+			frame := &Frame{Image: r.config.Downscale(img), Timestamp: time.Now()}
+			r.stats.Captured(frame.Timestamp.Sub(grabbed))
+			if !r.pause.Admit(frame) {
+				r.stats.Dropped()
+				continue
+			}
+			select {
+			case r.frames <- frame:
+				r.limit.Delivered()
+			case <-r.stopChan:
+				r.stats.Dropped()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package capture
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ScaleMode sets how many frame pixels are captured per point on scaled
+// (HiDPI) displays, where one point covers several physical pixels. Values
+// other than ScalePhysical and ScaleLogical are custom factors, e.g. 1.5
+// pixels per point.
+type ScaleMode float64
+
+const (
+	// ScalePhysical captures every physical pixel, so a 400x300 point
+	// region records 800x600 frames on a Retina display
+	ScalePhysical ScaleMode = 0
+
+	// ScaleLogical captures one pixel per point, so a region records
+	// frames of the same size on every display
+	ScaleLogical ScaleMode = 1
+)
+
+// ParseScaleMode parses "physical", "logical", or a custom factor such as
+// "1.5" or "1.5x"
+func ParseScaleMode(s string) (ScaleMode, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "", "physical":
+		return ScalePhysical, nil
+	case "logical":
+		return ScaleLogical, nil
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid scale mode %q (use physical, logical, or a factor such as 1.5)", s)
+	}
+	if m := ScaleMode(f); f == 0 || !m.Valid() {
+		return 0, fmt.Errorf("scale factor must be positive, got %s", s)
+	}
+	return ScaleMode(f), nil
+}
+
+// Valid reports whether the mode is ScalePhysical or a positive factor
+func (m ScaleMode) Valid() bool {
+	f := float64(m)
+	return f >= 0 && !math.IsInf(f, 0) && !math.IsNaN(f)
+}
+
+// String returns "physical", "logical", or the factor, e.g. "1.5x"
+func (m ScaleMode) String() string {
+	switch m {
+	case ScalePhysical:
+		return "physical"
+	case ScaleLogical:
+		return "logical"
+	}
+	return strconv.FormatFloat(float64(m), 'g', -1, 64) + "x"
+}
+
+// Scaled returns the display with its ScaleFactor set to the frame pixels
+// per point captured in mode m, so its PixelSize and GlobalToLocal give
+// frame dimensions and coordinates
+func (d Display) Scaled(m ScaleMode) Display {
+	if m != ScalePhysical {
+		d.ScaleFactor = float64(m)
+	}
+	return d
+}
@@ -0,0 +1,78 @@
+package capture
+
+import "testing"
+
+func TestParseScaleMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ScaleMode
+		wantErr bool
+	}{
+		{"", ScalePhysical, false},
+		{"physical", ScalePhysical, false},
+		{"Logical", ScaleLogical, false},
+		{"1", ScaleLogical, false},
+		{"1.5", 1.5, false},
+		{"2x", 2, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"inf", 0, true},
+		{"retina", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseScaleMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScaleMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseScaleMode(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScaleModeString(t *testing.T) {
+	tests := []struct {
+		mode ScaleMode
+		want string
+	}{
+		{ScalePhysical, "physical"},
+		{ScaleLogical, "logical"},
+		{1.5, "1.5x"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.mode.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestDisplayScaled(t *testing.T) {
+	retina := Display{ID: 1, Bounds: Region{X: 0, Y: 0, Width: 1512, Height: 982}, ScaleFactor: 2}
+	region := Region{X: 100, Y: 50, Width: 400, Height: 300}
+
+	tests := []struct {
+		name string
+		mode ScaleMode
+		want Region
+	}{
+		{"physical", ScalePhysical, Region{X: 200, Y: 100, Width: 800, Height: 600}},
+		{"logical", ScaleLogical, Region{X: 100, Y: 50, Width: 400, Height: 300}},
+		{"custom", 1.5, Region{X: 150, Y: 75, Width: 600, Height: 450}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := retina.Scaled(tt.mode).GlobalToLocal(region)
+			if err != nil {
+				t.Fatalf("GlobalToLocal() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GlobalToLocal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
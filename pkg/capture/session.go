@@ -0,0 +1,23 @@
+package capture
+
+// Session describes the state of the user's login session
+type Session struct {
+	// Locked is true while the screen is locked or the screen saver asks
+	// for a password
+	Locked bool
+
+	// OnConsole is false when another user has taken over the display
+	// through fast user switching
+	OnConsole bool
+}
+
+// Capturable reports whether the session's screen can be captured. Locked
+// and switched-away sessions produce black or blank frames.
+func (s Session) Capturable() bool {
+	return s.OnConsole && !s.Locked
+}
+
+// CurrentSession returns the state of the session Witness is running in
+func CurrentSession() (Session, error) {
+	return platformCurrentSession()
+}
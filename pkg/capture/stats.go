@@ -0,0 +1,137 @@
+package capture
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Stats summarizes how a capturer has performed since it was started
+type Stats struct {
+	// FramesCaptured counts frames grabbed from the source
+	FramesCaptured int
+
+	// FramesDropped counts captured frames that were never delivered,
+	// because the capturer was paused or stopped before they were read
+	FramesDropped int
+
+	// AverageLatency is the mean time taken to grab a frame
+	AverageLatency time.Duration
+
+	// Elapsed is the time from Start to Stop, or to now while running
+	Elapsed time.Duration
+
+	// EffectiveFPS is the rate frames were delivered over Elapsed
+	EffectiveFPS float64
+}
+
+// FramesDelivered returns the number of frames sent on the Frames channel
+func (s Stats) FramesDelivered() int {
+	return s.FramesCaptured - s.FramesDropped
+}
+
+// Add combines the stats of two capture sessions, such as a capturer and
+// the one that replaced it after an interruption
+func (s Stats) Add(o Stats) Stats {
+	sum := Stats{
+		FramesCaptured: s.FramesCaptured + o.FramesCaptured,
+		FramesDropped:  s.FramesDropped + o.FramesDropped,
+		Elapsed:        s.Elapsed + o.Elapsed,
+	}
+	if sum.FramesCaptured > 0 {
+		total := s.AverageLatency*time.Duration(s.FramesCaptured) + o.AverageLatency*time.Duration(o.FramesCaptured)
+		sum.AverageLatency = total / time.Duration(sum.FramesCaptured)
+	}
+	sum.EffectiveFPS = effectiveFPS(sum.FramesDelivered(), sum.Elapsed)
+	return sum
+}
+
+// String returns a one-line summary, e.g.
+// "120 frames captured, 2 dropped, 4.1ms average latency, 14.8 fps"
+func (s Stats) String() string {
+	return fmt.Sprintf("%d frames captured, %d dropped, %v average latency, %.1f fps",
+		s.FramesCaptured, s.FramesDropped, s.AverageLatency.Round(100*time.Microsecond), s.EffectiveFPS)
+}
+
+// effectiveFPS returns the rate of n frames over elapsed
+func effectiveFPS(n int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(n) / elapsed.Seconds()
+}
+
+// StatsCounter collects a capturer's Stats. Capturers embed one, calling
+// Start and Stop with their own, Captured for every frame grabbed, and
+// Dropped for every one of those frames they discard. The zero value is
+// ready to use.
+type StatsCounter struct {
+	mu       sync.Mutex
+	start    time.Time
+	stop     time.Time
+	captured int
+	dropped  int
+	latency  time.Duration // Total over all captured frames
+}
+
+// Start clears the counts and starts the clock
+func (c *StatsCounter) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.start = time.Now()
+	c.stop = time.Time{}
+	c.captured = 0
+	c.dropped = 0
+	c.latency = 0
+}
+
+// Stop stops the clock
+func (c *StatsCounter) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stop.IsZero() {
+		c.stop = time.Now()
+	}
+}
+
+// Captured records a frame that took latency to grab
+func (c *StatsCounter) Captured(latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.captured++
+	c.latency += latency
+}
+
+// Dropped records a captured frame that will not be delivered
+func (c *StatsCounter) Dropped() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropped++
+}
+
+// Stats returns the counts so far
+func (c *StatsCounter) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Stats{
+		FramesCaptured: c.captured,
+		FramesDropped:  c.dropped,
+	}
+	if c.captured > 0 {
+		s.AverageLatency = c.latency / time.Duration(c.captured)
+	}
+	if !c.start.IsZero() {
+		end := c.stop
+		if end.IsZero() {
+			end = time.Now()
+		}
+		s.Elapsed = end.Sub(c.start)
+	}
+	s.EffectiveFPS = effectiveFPS(s.FramesDelivered(), s.Elapsed)
+	return s
+}
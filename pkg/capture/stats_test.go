@@ -0,0 +1,92 @@
+package capture
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsCounter(t *testing.T) {
+	var counter StatsCounter
+
+	if s := counter.Stats(); s != (Stats{}) {
+		t.Errorf("Stats() before Start() = %+v, want zero", s)
+	}
+
+	counter.Start()
+	counter.Captured(2 * time.Millisecond)
+	counter.Captured(4 * time.Millisecond)
+	counter.Captured(6 * time.Millisecond)
+	counter.Dropped()
+	time.Sleep(10 * time.Millisecond)
+	counter.Stop()
+
+	s := counter.Stats()
+	if s.FramesCaptured != 3 || s.FramesDropped != 1 || s.FramesDelivered() != 2 {
+		t.Errorf("captured, dropped, delivered = %d, %d, %d, want 3, 1, 2",
+			s.FramesCaptured, s.FramesDropped, s.FramesDelivered())
+	}
+	if s.AverageLatency != 4*time.Millisecond {
+		t.Errorf("AverageLatency = %v, want 4ms", s.AverageLatency)
+	}
+	if s.Elapsed < 10*time.Millisecond {
+		t.Errorf("Elapsed = %v, want at least 10ms", s.Elapsed)
+	}
+	if want := 2 / s.Elapsed.Seconds(); s.EffectiveFPS != want {
+		t.Errorf("EffectiveFPS = %v, want %v", s.EffectiveFPS, want)
+	}
+
+	// The clock stops with Stop
+	time.Sleep(5 * time.Millisecond)
+	if again := counter.Stats(); again.Elapsed != s.Elapsed {
+		t.Errorf("Elapsed changed after Stop(): %v then %v", s.Elapsed, again.Elapsed)
+	}
+
+	// Start clears the counts
+	counter.Start()
+	if s := counter.Stats(); s.FramesCaptured != 0 || s.FramesDropped != 0 || s.AverageLatency != 0 {
+		t.Errorf("Stats() after restarting = %+v, want no frames", s)
+	}
+}
+
+func TestStatsAdd(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Stats
+		want Stats
+	}{
+		{
+			name: "weighted latency",
+			a:    Stats{FramesCaptured: 30, FramesDropped: 2, AverageLatency: 2 * time.Millisecond, Elapsed: 2 * time.Second},
+			b:    Stats{FramesCaptured: 10, AverageLatency: 6 * time.Millisecond, Elapsed: 2 * time.Second},
+			want: Stats{FramesCaptured: 40, FramesDropped: 2, AverageLatency: 3 * time.Millisecond, Elapsed: 4 * time.Second, EffectiveFPS: 9.5},
+		},
+		{
+			name: "empty",
+			a:    Stats{},
+			b:    Stats{FramesCaptured: 15, AverageLatency: time.Millisecond, Elapsed: time.Second},
+			want: Stats{FramesCaptured: 15, AverageLatency: time.Millisecond, Elapsed: time.Second, EffectiveFPS: 15},
+		},
+		{
+			name: "no frames",
+			a:    Stats{Elapsed: time.Second},
+			b:    Stats{},
+			want: Stats{Elapsed: time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Add(tt.b); got != tt.want {
+				t.Errorf("Add() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsString(t *testing.T) {
+	s := Stats{FramesCaptured: 120, FramesDropped: 2, AverageLatency: 4123 * time.Microsecond, EffectiveFPS: 14.76}
+	want := "120 frames captured, 2 dropped, 4.1ms average latency, 14.8 fps"
+	if got := s.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
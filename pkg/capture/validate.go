@@ -0,0 +1,40 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+)
+
+// ValidateFrame checks that frame's pixel buffer holds every row of its
+// bounds at its stride. A frame that fails would be read with rows shifted
+// or cut short, so it is reported with ErrCorruptFrame instead.
+func ValidateFrame(frame *Frame) error {
+	if frame == nil || frame.Image == nil {
+		return fmt.Errorf("frame has no image: %w", ErrCorruptFrame)
+	}
+	img := frame.Image
+	if img.Rect.Empty() {
+		return fmt.Errorf("frame has empty bounds %v: %w", img.Rect, ErrCorruptFrame)
+	}
+	return ValidateBuffer(img.Rect.Size(), img.Stride, 4, len(img.Pix))
+}
+
+// ValidateBuffer checks that a pixel buffer length bytes long can hold an
+// image of the given size, with rows stride bytes apart and bytesPerPixel
+// bytes per pixel. Capturers call it on buffers handed over by the system
+// before converting them, since a mismatched stride or pixel size skews
+// every row after the first.
+func ValidateBuffer(size image.Point, stride, bytesPerPixel, length int) error {
+	if size.X <= 0 || size.Y <= 0 {
+		return fmt.Errorf("buffer has empty size %dx%d: %w", size.X, size.Y, ErrCorruptFrame)
+	}
+	if row := size.X * bytesPerPixel; stride < row {
+		return fmt.Errorf("row stride of %d bytes is shorter than a row of %d pixels at %d bytes each (%d bytes): %w",
+			stride, size.X, bytesPerPixel, row, ErrCorruptFrame)
+	}
+	if need := (size.Y-1)*stride + size.X*bytesPerPixel; length < need {
+		return fmt.Errorf("buffer holds %d bytes, but %dx%d pixels with a %d-byte stride need %d: %w",
+			length, size.X, size.Y, stride, need, ErrCorruptFrame)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package capture
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestValidateFrame(t *testing.T) {
+	sub := image.NewRGBA(image.Rect(0, 0, 10, 10)).SubImage(image.Rect(2, 3, 6, 8)).(*image.RGBA)
+
+	tests := []struct {
+		name    string
+		frame   *Frame
+		wantErr bool
+	}{
+		{"whole image", &Frame{Image: image.NewRGBA(image.Rect(0, 0, 4, 3))}, false},
+		{"sub-image with a wider stride", &Frame{Image: sub}, false},
+		{"nil frame", nil, true},
+		{"nil image", &Frame{}, true},
+		{"empty bounds", &Frame{Image: image.NewRGBA(image.Rect(0, 0, 0, 5))}, true},
+		{"stride shorter than a row", &Frame{Image: &image.RGBA{
+			Pix: make([]byte, 4*4*3), Stride: 12, Rect: image.Rect(0, 0, 4, 3),
+		}}, true},
+		{"buffer missing the last row", &Frame{Image: &image.RGBA{
+			Pix: make([]byte, 4*4*2), Stride: 16, Rect: image.Rect(0, 0, 4, 3),
+		}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFrame(tt.frame)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateFrame() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrCorruptFrame) {
+				t.Errorf("ValidateFrame() error = %v, want ErrCorruptFrame", err)
+			}
+		})
+	}
+}
+
+func TestValidateBuffer(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        image.Point
+		stride, bpp int
+		length      int
+		wantErr     bool
+	}{
+		{"tightly packed", image.Pt(4, 3), 16, 4, 48, false},
+		{"padded rows", image.Pt(4, 3), 64, 4, 64*2 + 16, false},
+		{"3 bytes per pixel read as 4", image.Pt(4, 3), 12, 4, 36, true},
+		{"short buffer", image.Pt(4, 3), 16, 4, 47, true},
+		{"empty", image.Pt(0, 3), 16, 4, 48, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBuffer(tt.size, tt.stride, tt.bpp, tt.length)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateBuffer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && IsRecoverable(err) {
+				t.Errorf("ValidateBuffer() error %v should not be recoverable", err)
+			}
+		})
+	}
+}
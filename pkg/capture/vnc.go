@@ -0,0 +1,241 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/internal/vnc"
+)
+
+// vncDialTimeout bounds connecting and the VNC handshake
+const vncDialTimeout = 10 * time.Second
+
+// vncCapturer records a remote desktop from a VNC server, so apps running
+// in containers or VMs can be recorded without a local display. Updates are
+// applied as the server sends them, and frames of the current screen are
+// emitted at the configured rate.
+type vncCapturer struct {
+	addr     string
+	password string
+	config   Config
+	client   *vnc.Client
+	crop     image.Rectangle
+	frames   chan *Frame
+	errors   chan error
+	stopChan chan struct{}
+	done     chan struct{} // Closed when both loops have returned
+	state    State
+	mu       sync.Mutex
+	pause    PauseGate
+	stats    StatsCounter
+	limit    Limit
+}
+
+// NewVNCCapturer creates a capturer for the VNC server at addr (host,
+// host:display, or host:port). Config.Region is in the remote screen's
+// pixels.
+func NewVNCCapturer(addr, password string, config Config) Capturer {
+	return &vncCapturer{
+		addr:     addr,
+		password: password,
+		config:   config,
+		frames:   make(chan *Frame, 30),
+		errors:   make(chan error, 10),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start connects to the server and begins receiving updates
+func (v *vncCapturer) Start() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.state != StateIdle {
+		return ErrAlreadyRunning
+	}
+
+	client, err := vnc.Dial(v.addr, v.password, v.config.IncludeCursor, vncDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to VNC server %s: %v: %w", v.addr, err, ErrStreamInterrupted)
+	}
+
+	width, height := client.Size()
+	v.crop = image.Rect(0, 0, width, height)
+	if r := v.config.Region; r != nil {
+		crop := image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height).Intersect(v.crop)
+		if crop.Empty() {
+			client.Close()
+			return fmt.Errorf("region %dx%d at (%d,%d) is outside the %dx%d VNC screen",
+				r.Width, r.Height, r.X, r.Y, width, height)
+		}
+		v.crop = crop
+	}
+
+	v.client = client
+	v.state = StateRunning
+	v.stats.Start()
+	v.limit.Start(v.config)
+
+	var loops sync.WaitGroup
+	loops.Add(2)
+	go func() {
+		defer loops.Done()
+		v.updateLoop()
+	}()
+	go func() {
+		defer loops.Done()
+		v.captureLoop()
+	}()
+	go func() {
+		loops.Wait()
+		close(v.done)
+	}()
+
+	return nil
+}
+
+// Stop disconnects from the server
+func (v *vncCapturer) Stop() error {
+	v.mu.Lock()
+	if v.state != StateRunning {
+		v.mu.Unlock()
+		return ErrNotRunning
+	}
+	v.state = StateStopping
+	v.mu.Unlock()
+
+	close(v.stopChan)
+	v.client.Close()
+	<-v.done
+	v.stats.Stop()
+
+	v.mu.Lock()
+	v.state = StateIdle
+	close(v.frames)
+	close(v.errors)
+	v.mu.Unlock()
+
+	return nil
+}
+
+// Pause holds back frames until Resume is called
+func (v *vncCapturer) Pause() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.state != StateRunning {
+		return ErrNotRunning
+	}
+	v.pause.Pause()
+
+	return nil
+}
+
+// Resume delivers frames again after Pause
+func (v *vncCapturer) Resume() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.state != StateRunning {
+		return ErrNotRunning
+	}
+	v.pause.Resume()
+
+	return nil
+}
+
+// Stats returns the capture statistics since Start
+func (v *vncCapturer) Stats() Stats {
+	return v.stats.Stats()
+}
+
+// Frames returns the channel for captured frames
+func (v *vncCapturer) Frames() <-chan *Frame {
+	return v.frames
+}
+
+// Errors returns the channel for errors
+func (v *vncCapturer) Errors() <-chan error {
+	return v.errors
+}
+
+// IsRunning returns whether the capturer is currently running
+func (v *vncCapturer) IsRunning() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.state == StateRunning
+}
+
+// State returns the current lifecycle state
+func (v *vncCapturer) State() State {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.state == StateRunning && v.pause.Paused() {
+		return StatePaused
+	}
+	return v.state
+}
+
+// updateLoop applies framebuffer updates until the connection closes
+func (v *vncCapturer) updateLoop() {
+	incremental := false
+	for {
+		if err := v.client.Update(incremental); err != nil {
+			select {
+			case <-v.stopChan:
+			case v.errors <- fmt.Errorf("VNC connection lost: %v: %w", err, ErrStreamInterrupted):
+			}
+			return
+		}
+		incremental = true
+	}
+}
+
+// captureLoop emits the current screen at the configured rate
+func (v *vncCapturer) captureLoop() {
+	fps := v.config.FPS
+	if !fps.Valid() {
+		fps = FPS15
+	}
+	ticker := time.NewTicker(fps.FrameDuration())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-v.stopChan:
+			return
+		case <-ticker.C:
+			if err := v.limit.Reached(); err != nil {
+				select {
+				case v.errors <- err:
+				case <-v.stopChan:
+				}
+				return
+			}
+			if v.pause.Paused() {
+				continue
+			}
+			grabbed := time.Now()
+			img := v.client.Image(v.crop)
+			if img == nil {
+				continue // No update received yet
+			}
+			frame := &Frame{Image: v.config.Downscale(img), Timestamp: time.Now()}
+			v.stats.Captured(frame.Timestamp.Sub(grabbed))
+			if !v.pause.Admit(frame) {
+				v.stats.Dropped()
+				continue
+			}
+			select {
+			case v.frames <- frame:
+				v.limit.Delivered()
+			case <-v.stopChan:
+				v.stats.Dropped()
+				return
+			}
+		}
+	}
+}
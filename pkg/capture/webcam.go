@@ -0,0 +1,266 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebcamFFmpeg is the ffmpeg binary used to read webcams
+const WebcamFFmpeg = "ffmpeg"
+
+// WebcamConfig selects a camera and the size of its frames
+type WebcamConfig struct {
+	// Device names the camera: an index such as "0" on macOS, a path such
+	// as /dev/video0 on Linux, or the camera's name on Windows. Empty
+	// selects the first camera on macOS and Linux.
+	Device string
+
+	// Width and Height are the frame size. The camera image is scaled to
+	// cover it and cropped at the edges. Defaults to 320x240.
+	Width, Height int
+
+	// FPS is the frame rate to deliver (default 15)
+	FPS FPS
+}
+
+// WebcamCapturer reads frames from a webcam through ffmpeg, which knows
+// each platform's camera API (AVFoundation, Video4Linux, DirectShow)
+type WebcamCapturer struct {
+	config WebcamConfig
+	ffmpeg string
+
+	cmd      *exec.Cmd
+	out      io.ReadCloser
+	stderr   strings.Builder
+	frames   chan *Frame
+	errors   chan error
+	stopChan chan struct{}
+	done     chan struct{}
+	state    State
+	mu       sync.Mutex
+	pause    PauseGate
+	stats    StatsCounter
+}
+
+// NewWebcamCapturer creates a webcam capturer. It fails if ffmpeg is not
+// installed.
+func NewWebcamCapturer(config WebcamConfig) (*WebcamCapturer, error) {
+	ffmpeg, err := exec.LookPath(WebcamFFmpeg)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg is required for webcam capture (install it with 'brew install ffmpeg'): %w", err)
+	}
+	return newWebcamCapturer(config, ffmpeg), nil
+}
+
+// newWebcamCapturer fills in defaults and creates a capturer that runs
+// the given ffmpeg binary
+func newWebcamCapturer(config WebcamConfig, ffmpeg string) *WebcamCapturer {
+	if config.Width <= 0 || config.Height <= 0 {
+		config.Width, config.Height = 320, 240
+	}
+	if !config.FPS.Valid() {
+		config.FPS = FPS15
+	}
+
+	return &WebcamCapturer{
+		config:   config,
+		ffmpeg:   ffmpeg,
+		frames:   make(chan *Frame, 2),
+		errors:   make(chan error, 10),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// webcamArgs returns the ffmpeg arguments that read the camera on goos and
+// write scaled RGBA frames to stdout
+func webcamArgs(goos string, config WebcamConfig) ([]string, error) {
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin"}
+
+	switch goos {
+	case "darwin":
+		device := config.Device
+		if device == "" {
+			device = "0"
+		}
+		// AVFoundation rejects its default of 29.97 on most cameras
+		args = append(args, "-f", "avfoundation", "-framerate", "30", "-i", device)
+	case "linux":
+		device := config.Device
+		if device == "" {
+			device = "/dev/video0"
+		}
+		args = append(args, "-f", "v4l2", "-i", device)
+	case "windows":
+		if config.Device == "" {
+			return nil, fmt.Errorf("name the camera to use with -webcam-device (list them with: ffmpeg -list_devices true -f dshow -i dummy)")
+		}
+		args = append(args, "-f", "dshow", "-i", "video="+config.Device)
+	default:
+		return nil, fmt.Errorf("webcam capture: %w", ErrUnsupportedPlatform)
+	}
+
+	filter := fmt.Sprintf("fps=%s,scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d",
+		config.FPS, config.Width, config.Height, config.Width, config.Height)
+	return append(args, "-an", "-vf", filter, "-pix_fmt", "rgba", "-f", "rawvideo", "-"), nil
+}
+
+// Start launches ffmpeg and begins reading frames
+func (w *WebcamCapturer) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != StateIdle {
+		return ErrAlreadyRunning
+	}
+
+	args, err := webcamArgs(runtime.GOOS, w.config)
+	if err != nil {
+		return err
+	}
+
+	w.cmd = exec.Command(w.ffmpeg, args...)
+	w.cmd.Stderr = &w.stderr
+	w.cmd.WaitDelay = time.Second
+	w.out, err = w.cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start webcam: %w", err)
+	}
+	if err := w.cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start webcam: %w", err)
+	}
+
+	w.state = StateRunning
+	w.stats.Start()
+	go w.captureLoop()
+
+	return nil
+}
+
+// Stop stops ffmpeg and closes the frame channel
+func (w *WebcamCapturer) Stop() error {
+	w.mu.Lock()
+	if w.state != StateRunning {
+		w.mu.Unlock()
+		return ErrNotRunning
+	}
+	w.state = StateStopping
+	w.mu.Unlock()
+
+	close(w.stopChan)
+	w.cmd.Process.Kill()
+	w.out.Close()
+	<-w.done
+	w.cmd.Wait()
+	w.stats.Stop()
+
+	w.mu.Lock()
+	w.state = StateIdle
+	w.mu.Unlock()
+
+	return nil
+}
+
+// Pause holds back frames until Resume is called
+func (w *WebcamCapturer) Pause() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != StateRunning {
+		return ErrNotRunning
+	}
+	w.pause.Pause()
+
+	return nil
+}
+
+// Resume delivers frames again after Pause
+func (w *WebcamCapturer) Resume() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != StateRunning {
+		return ErrNotRunning
+	}
+	w.pause.Resume()
+
+	return nil
+}
+
+// Stats returns the capture statistics since Start. Latency includes
+// waiting for ffmpeg to deliver each frame.
+func (w *WebcamCapturer) Stats() Stats {
+	return w.stats.Stats()
+}
+
+// Frames returns the channel for captured frames
+func (w *WebcamCapturer) Frames() <-chan *Frame {
+	return w.frames
+}
+
+// Errors returns the channel for errors
+func (w *WebcamCapturer) Errors() <-chan error {
+	return w.errors
+}
+
+// IsRunning returns whether the capturer is currently running
+func (w *WebcamCapturer) IsRunning() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state == StateRunning
+}
+
+// State returns the current lifecycle state
+func (w *WebcamCapturer) State() State {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.state == StateRunning && w.pause.Paused() {
+		return StatePaused
+	}
+	return w.state
+}
+
+// captureLoop forwards frames from ffmpeg until it exits or is stopped
+func (w *WebcamCapturer) captureLoop() {
+	defer close(w.done)
+	defer close(w.frames)
+	defer close(w.errors)
+
+	size := 4 * w.config.Width * w.config.Height
+	for {
+		img := image.NewRGBA(image.Rect(0, 0, w.config.Width, w.config.Height))
+		grabbed := time.Now()
+		if _, err := io.ReadFull(w.out, img.Pix[:size]); err != nil {
+			select {
+			case <-w.stopChan:
+			default:
+				w.cmd.Wait()
+				if msg := strings.TrimSpace(w.stderr.String()); msg != "" {
+					err = fmt.Errorf("%v: %s", err, msg)
+				}
+				w.errors <- fmt.Errorf("webcam stopped: %v: %w", err, ErrStreamInterrupted)
+			}
+			return
+		}
+
+		// ffmpeg keeps running while paused so the camera stays open
+		frame := &Frame{Image: img, Timestamp: time.Now()}
+		w.stats.Captured(frame.Timestamp.Sub(grabbed))
+		if !w.pause.Admit(frame) {
+			w.stats.Dropped()
+			continue
+		}
+		select {
+		case w.frames <- frame:
+		case <-w.stopChan:
+			w.stats.Dropped()
+			return
+		}
+	}
+}
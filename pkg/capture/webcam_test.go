@@ -0,0 +1,114 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebcamArgs(t *testing.T) {
+	tests := []struct {
+		goos    string
+		device  string
+		want    []string
+		wantErr bool
+	}{
+		{"darwin", "", []string{"-f avfoundation -framerate 30 -i 0 "}, false},
+		{"darwin", "FaceTime HD Camera", []string{"-i FaceTime HD Camera "}, false},
+		{"linux", "", []string{"-f v4l2 -i /dev/video0 "}, false},
+		{"linux", "/dev/video2", []string{"-i /dev/video2 "}, false},
+		{"windows", "Integrated Webcam", []string{"-f dshow -i video=Integrated Webcam "}, false},
+		{"windows", "", nil, true},
+		{"plan9", "", nil, true},
+	}
+
+	config := WebcamConfig{Width: 160, Height: 120, FPS: FPS30}
+	for _, tt := range tests {
+		t.Run(tt.goos+"/"+tt.device, func(t *testing.T) {
+			config.Device = tt.device
+			args, err := webcamArgs(tt.goos, config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("webcamArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			joined := strings.Join(args, " ")
+			want := append(tt.want,
+				"fps=30,scale=160:120:force_original_aspect_ratio=increase,crop=160:120",
+				"-pix_fmt rgba -f rawvideo -")
+			for _, w := range want {
+				if !strings.Contains(joined, w) {
+					t.Errorf("args %q missing %q", joined, w)
+				}
+			}
+		})
+	}
+}
+
+// Helper function to create a webcam capturer that runs a fake ffmpeg
+// printing script's output
+func fakeWebcam(t *testing.T, script string, config WebcamConfig) *WebcamCapturer {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return newWebcamCapturer(config, path)
+}
+
+func TestWebcamCapturer(t *testing.T) {
+	// Three 2x2 frames of 0xff bytes, then the camera keeps running
+	w := fakeWebcam(t, "head -c 48 /dev/zero | tr '\\000' '\\377'\nexec sleep 10\n", WebcamConfig{Width: 2, Height: 2})
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if !w.IsRunning() {
+		t.Error("IsRunning() = false after Start")
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case frame := <-w.Frames():
+			if frame.Image.Bounds().Dx() != 2 || frame.Image.Pix[0] != 0xff {
+				t.Errorf("frame %d = %v, want 2x2 of 0xff", i, frame.Image.Pix)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for frame %d", i)
+		}
+	}
+
+	if err := w.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	if _, ok := <-w.Frames(); ok {
+		t.Error("frames channel still open after Stop")
+	}
+	if err := w.Stop(); err != ErrNotRunning {
+		t.Errorf("second Stop() = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+func TestWebcamCapturerFailure(t *testing.T) {
+	w := fakeWebcam(t, "echo 'Could not open video device' >&2\nexit 1\n", WebcamConfig{})
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer w.Stop()
+
+	select {
+	case err := <-w.Errors():
+		if !IsRecoverable(err) || !strings.Contains(err.Error(), "Could not open video device") {
+			t.Errorf("error = %v, want a stream interruption with ffmpeg's message", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an error")
+	}
+}
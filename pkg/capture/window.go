@@ -0,0 +1,216 @@
+package capture
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Window describes the visibility of an on-screen window
+type Window struct {
+	// ID is the platform window identifier
+	ID uint32
+
+	// Bounds is the window rectangle in global points
+	Bounds Region
+
+	// OnScreen is false when the window is minimized, hidden, or on another Space
+	OnScreen bool
+
+	// Above holds the bounds of visible windows stacked in front of this one
+	Above []Region
+
+	// Title is the window's title, if the platform reports it
+	Title string
+
+	// Owner is the name of the application that owns the window
+	Owner string
+}
+
+// WindowTarget selects a single window to record. Unlike a fixed Region,
+// the capture follows the window when it moves.
+type WindowTarget struct {
+	// ID is the platform window identifier. It takes precedence over Title.
+	ID uint32
+
+	// Title picks the frontmost window whose title or application name
+	// matches, ignoring case
+	Title string
+}
+
+// ParseWindowTarget parses a window ID or title, as given to -window
+func ParseWindowTarget(s string) (*WindowTarget, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("window title or ID is empty")
+	}
+	if id, err := strconv.ParseUint(s, 10, 32); err == nil && id != 0 {
+		return &WindowTarget{ID: uint32(id)}, nil
+	}
+	return &WindowTarget{Title: s}, nil
+}
+
+// String describes the target for messages
+func (t WindowTarget) String() string {
+	if t.ID != 0 {
+		return fmt.Sprintf("window %d", t.ID)
+	}
+	return fmt.Sprintf("window %q", t.Title)
+}
+
+// Resolve returns the ID of the targeted window
+func (t WindowTarget) Resolve() (uint32, error) {
+	if t.ID != 0 {
+		return t.ID, nil
+	}
+
+	windows, err := ListWindows()
+	if err != nil {
+		return 0, err
+	}
+	w, ok := MatchWindow(windows, t.Title)
+	if !ok {
+		return 0, fmt.Errorf("no window matches %q: %w", t.Title, ErrWindowNotFound)
+	}
+	return w.ID, nil
+}
+
+// ListWindows returns the on-screen application windows, frontmost first
+func ListWindows() ([]Window, error) {
+	return platformListWindows()
+}
+
+// MatchWindow picks the window a title refers to from windows ordered
+// frontmost first. An exact title match wins over a partial one, and a
+// partial title match wins over the application name, all ignoring case.
+func MatchWindow(windows []Window, title string) (Window, bool) {
+	title = strings.ToLower(title)
+	matchers := []func(w Window) bool{
+		func(w Window) bool { return strings.ToLower(w.Title) == title },
+		func(w Window) bool { return strings.Contains(strings.ToLower(w.Title), title) },
+		func(w Window) bool { return strings.Contains(strings.ToLower(w.Owner), title) },
+	}
+
+	for _, match := range matchers {
+		for _, w := range windows {
+			if match(w) {
+				return w, true
+			}
+		}
+	}
+	return Window{}, false
+}
+
+// MatchWindows returns the IDs of every window in windows that a target
+// refers to: the window with the target's ID, or each window whose title
+// or application name contains the target's title, ignoring case. Targets
+// that match nothing are skipped, since the window may not be open.
+func MatchWindows(windows []Window, targets []WindowTarget) []uint32 {
+	var ids []uint32
+	for _, w := range windows {
+		for _, t := range targets {
+			title := strings.ToLower(t.Title)
+			if (t.ID != 0 && w.ID == t.ID) || (t.ID == 0 &&
+				(strings.Contains(strings.ToLower(w.Title), title) || strings.Contains(strings.ToLower(w.Owner), title))) {
+				ids = append(ids, w.ID)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// MatchApplication returns the name of the application that owns the
+// frontmost window whose application name contains app, ignoring case
+func MatchApplication(windows []Window, app string) (string, bool) {
+	app = strings.ToLower(app)
+	for _, w := range windows {
+		if strings.Contains(strings.ToLower(w.Owner), app) {
+			return w.Owner, true
+		}
+	}
+	return "", false
+}
+
+// FrontmostApplication returns the name of the application that owns the
+// frontmost window, or "" if no windows are open
+func FrontmostApplication() (string, error) {
+	windows, err := ListWindows()
+	if err != nil {
+		return "", err
+	}
+	if len(windows) == 0 {
+		return "", nil
+	}
+	return windows[0].Owner, nil
+}
+
+// LookupWindow returns the current state of the window with the given ID
+func LookupWindow(id uint32) (Window, error) {
+	return platformLookupWindow(id)
+}
+
+// Occlusion returns the fraction of the window covered by the windows above
+// it, from 0 (fully visible) to 1 (fully covered)
+func (w Window) Occlusion() float64 {
+	total := w.Bounds.Width * w.Bounds.Height
+	if total <= 0 {
+		return 0
+	}
+
+	var covers []Region
+	for _, r := range w.Above {
+		if c, ok := r.Intersect(w.Bounds); ok {
+			covers = append(covers, c)
+		}
+	}
+
+	return float64(unionArea(covers)) / float64(total)
+}
+
+// unionArea returns the area covered by at least one of the regions
+func unionArea(regions []Region) int {
+	if len(regions) == 0 {
+		return 0
+	}
+
+	// Sweep across the distinct x edges, measuring the covered height of
+	// each vertical strip
+	var xs []int
+	for _, r := range regions {
+		xs = append(xs, r.X, r.X+r.Width)
+	}
+	sort.Ints(xs)
+
+	area := 0
+	for i := 0; i+1 < len(xs); i++ {
+		x0, x1 := xs[i], xs[i+1]
+		if x0 == x1 {
+			continue
+		}
+
+		type span struct{ y0, y1 int }
+		var spans []span
+		for _, r := range regions {
+			if r.X <= x0 && r.X+r.Width >= x1 {
+				spans = append(spans, span{r.Y, r.Y + r.Height})
+			}
+		}
+		sort.Slice(spans, func(a, b int) bool { return spans[a].y0 < spans[b].y0 })
+
+		height, end := 0, 0
+		for j, s := range spans {
+			if j == 0 || s.y0 > end {
+				height += s.y1 - s.y0
+				end = s.y1
+			} else if s.y1 > end {
+				height += s.y1 - end
+				end = s.y1
+			}
+		}
+		area += height * (x1 - x0)
+	}
+
+	return area
+}
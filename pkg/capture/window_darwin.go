@@ -0,0 +1,260 @@
+//go:build darwin
+// +build darwin
+
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/internal/macos"
+)
+
+// windowCapturer records a single window with CGWindowListCreateImage. The
+// window is followed as it moves and is captured even while covered. Frames
+// keep the window's size at the first frame; if it is resized, later frames
+// are cropped or padded to match. Config.ScaleMode sets the frame pixels
+// per point.
+type windowCapturer struct {
+	config   Config
+	windowID uint32
+	frames   chan *Frame
+	errors   chan error
+	stopChan chan struct{}
+	loopDone chan struct{} // Closed when captureLoop returns
+	state    State
+	mu       sync.Mutex
+	pause    PauseGate
+	stats    StatsCounter
+	limit    Limit
+	size     image.Point // Frame size, set by the first frame
+}
+
+// newWindowCapturer creates a capturer for the window with the given ID
+func newWindowCapturer(id uint32, config Config) (*windowCapturer, error) {
+	if _, err := LookupWindow(id); err != nil {
+		return nil, err
+	}
+
+	return &windowCapturer{
+		config:   config,
+		windowID: id,
+		frames:   make(chan *Frame, 30), // Buffer 30 frames
+		errors:   make(chan error, 10),
+		stopChan: make(chan struct{}),
+		loopDone: make(chan struct{}),
+		state:    StateIdle,
+	}, nil
+}
+
+// Start begins the capture process
+func (w *windowCapturer) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != StateIdle {
+		return ErrAlreadyRunning
+	}
+	w.state = StateRunning
+	w.stats.Start()
+	w.limit.Start(w.config)
+
+	go w.captureLoop()
+
+	return nil
+}
+
+// Stop ends the capture process
+func (w *windowCapturer) Stop() error {
+	w.mu.Lock()
+	if w.state != StateRunning {
+		w.mu.Unlock()
+		return ErrNotRunning
+	}
+	w.state = StateStopping
+	w.mu.Unlock()
+
+	close(w.stopChan)
+	<-w.loopDone
+	w.stats.Stop()
+
+	w.mu.Lock()
+	w.state = StateIdle
+	close(w.frames)
+	close(w.errors)
+	w.mu.Unlock()
+
+	return nil
+}
+
+// IsRunning returns whether the capturer is currently running
+func (w *windowCapturer) IsRunning() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state == StateRunning
+}
+
+// State returns the current lifecycle state
+func (w *windowCapturer) State() State {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.state == StateRunning && w.pause.Paused() {
+		return StatePaused
+	}
+	return w.state
+}
+
+// Pause holds back frames until Resume is called
+func (w *windowCapturer) Pause() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != StateRunning {
+		return ErrNotRunning
+	}
+	w.pause.Pause()
+
+	return nil
+}
+
+// Resume delivers frames again after Pause
+func (w *windowCapturer) Resume() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != StateRunning {
+		return ErrNotRunning
+	}
+	w.pause.Resume()
+
+	return nil
+}
+
+// Stats returns the capture statistics since Start
+func (w *windowCapturer) Stats() Stats {
+	return w.stats.Stats()
+}
+
+// Frames returns the channel for captured frames
+func (w *windowCapturer) Frames() <-chan *Frame {
+	return w.frames
+}
+
+// Errors returns the channel for errors
+func (w *windowCapturer) Errors() <-chan error {
+	return w.errors
+}
+
+// captureLoop captures the window at the configured rate
+func (w *windowCapturer) captureLoop() {
+	defer close(w.loopDone)
+
+	ticker := time.NewTicker(w.config.FPS.FrameDuration())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			if err := w.limit.Reached(); err != nil {
+				select {
+				case w.errors <- err:
+				default:
+				}
+				return
+			}
+			if w.pause.Paused() {
+				continue
+			}
+			grabbed := time.Now()
+			frame, err := w.captureFrame()
+			if err != nil {
+				select {
+				case w.errors <- err:
+				default:
+				}
+				if !IsRecoverable(err) {
+					return
+				}
+				continue
+			}
+			if frame == nil {
+				continue // Minimized or on another Space
+			}
+			frame.Image = w.config.Downscale(frame.Image)
+			w.stats.Captured(time.Since(grabbed))
+			if !w.pause.Admit(frame) {
+				w.stats.Dropped()
+				continue
+			}
+			select {
+			case w.frames <- frame:
+				w.limit.Delivered()
+			case <-w.stopChan:
+				w.stats.Dropped()
+				return
+			}
+		}
+	}
+}
+
+// captureFrame captures the window, returning nil while it is off screen
+func (w *windowCapturer) captureFrame() (*Frame, error) {
+	img := macos.CaptureWindow(w.windowID)
+	if img == nil {
+		window, err := LookupWindow(w.windowID)
+		if err != nil {
+			return nil, err
+		}
+		if !window.OnScreen {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to capture window %d: %w", w.windowID, ErrFrameCapture)
+	}
+	defer img.Close()
+
+	// Window bounds are in points and the image is in physical pixels
+	imgSize := img.Size()
+	imgWidth, imgHeight := float64(imgSize.X), float64(imgSize.Y)
+	var window Window
+	physical := 0.0 // Image pixels per point; 0 if unknown
+	if w.config.IncludeCursor || w.config.ScaleMode != ScalePhysical {
+		if found, err := LookupWindow(w.windowID); err == nil && found.Bounds.Width > 0 {
+			window = found
+			physical = imgWidth / float64(window.Bounds.Width)
+		}
+	}
+	resize := 1.0 // Frame pixels per image pixel
+	if physical > 0 && w.config.ScaleMode != ScalePhysical {
+		resize = float64(w.config.ScaleMode) / physical
+	}
+	draw := image.Pt(int(math.Round(imgWidth*resize)), int(math.Round(imgHeight*resize)))
+
+	if w.size == (image.Point{}) {
+		if draw.X == 0 || draw.Y == 0 {
+			return nil, nil
+		}
+		w.size = draw
+	}
+
+	rgba := image.NewRGBA(image.Rectangle{Max: w.size})
+	if err := img.Draw(rgba, draw); errors.Is(err, macos.ErrBitmapLayout) {
+		return nil, fmt.Errorf("bitmap context for window %d: %v: %w", w.windowID, err, ErrCorruptFrame)
+	} else if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrFrameCapture)
+	}
+
+	if w.config.IncludeCursor && physical > 0 {
+		macos.DrawCursor(rgba, image.Pt(window.Bounds.X, window.Bounds.Y), physical*resize)
+	}
+
+	return &Frame{
+		Image:     rgba,
+		Timestamp: time.Now(),
+	}, nil
+}
@@ -0,0 +1,155 @@
+package capture
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestWindowOcclusion(t *testing.T) {
+	bounds := Region{X: 100, Y: 100, Width: 200, Height: 100}
+
+	tests := []struct {
+		name  string
+		above []Region
+		want  float64
+	}{
+		{"nothing above", nil, 0},
+		{"disjoint window", []Region{{X: 400, Y: 400, Width: 50, Height: 50}}, 0},
+		{"left half", []Region{{X: 0, Y: 0, Width: 200, Height: 300}}, 0.5},
+		{"fully covered", []Region{{X: 0, Y: 0, Width: 1000, Height: 1000}}, 1},
+		{
+			"overlapping windows counted once",
+			[]Region{
+				{X: 100, Y: 100, Width: 100, Height: 100},
+				{X: 150, Y: 100, Width: 100, Height: 100},
+			},
+			0.75,
+		},
+		{
+			"stacked quarters",
+			[]Region{
+				{X: 100, Y: 100, Width: 100, Height: 50},
+				{X: 100, Y: 150, Width: 100, Height: 50},
+				{X: 200, Y: 100, Width: 100, Height: 50},
+			},
+			0.75,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Window{Bounds: bounds, OnScreen: true, Above: tt.above}
+			if got := w.Occlusion(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Occlusion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowOcclusionEmpty(t *testing.T) {
+	w := Window{Above: []Region{{X: 0, Y: 0, Width: 10, Height: 10}}}
+	if got := w.Occlusion(); got != 0 {
+		t.Errorf("Occlusion() of an empty window = %v, want 0", got)
+	}
+}
+
+func TestParseWindowTarget(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    WindowTarget
+		wantErr bool
+	}{
+		{"Safari", WindowTarget{Title: "Safari"}, false},
+		{"4242", WindowTarget{ID: 4242}, false},
+		{" Terminal — zsh ", WindowTarget{Title: "Terminal — zsh"}, false},
+		{"0", WindowTarget{Title: "0"}, false},
+		{"", WindowTarget{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseWindowTarget(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWindowTarget(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("ParseWindowTarget(%q) = %+v, want %+v", tt.input, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchWindow(t *testing.T) {
+	// Frontmost first
+	windows := []Window{
+		{ID: 1, Title: "witness — README.md", Owner: "Code"},
+		{ID: 2, Title: "Apple", Owner: "Safari"},
+		{ID: 3, Title: "Safari Tips", Owner: "Notes"},
+		{ID: 4, Title: "code review", Owner: "Safari"},
+	}
+
+	tests := []struct {
+		title  string
+		wantID uint32
+		wantOK bool
+	}{
+		{"code review", 4, true}, // Exact title beats the Code app in front
+		{"README", 1, true},
+		{"safari", 3, true}, // Title match beats the Safari app
+		{"notes", 3, true},
+		{"Xcode", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			got, ok := MatchWindow(windows, tt.title)
+			if ok != tt.wantOK || got.ID != tt.wantID {
+				t.Errorf("MatchWindow(%q) = %d, %v; want %d, %v", tt.title, got.ID, ok, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMatchApplication(t *testing.T) {
+	windows := []Window{
+		{ID: 1, Title: "Figma", Owner: "Google Chrome"},
+		{ID: 2, Title: "Inbox", Owner: "Mail"},
+	}
+
+	if app, ok := MatchApplication(windows, "chrome"); !ok || app != "Google Chrome" {
+		t.Errorf("MatchApplication(chrome) = %q, %v; want Google Chrome", app, ok)
+	}
+	// Window titles don't count
+	if _, ok := MatchApplication(windows, "figma"); ok {
+		t.Error("MatchApplication(figma) should not match a window title")
+	}
+}
+
+func TestMatchWindows(t *testing.T) {
+	windows := []Window{
+		{ID: 1, Title: "Vault — Personal", Owner: "1Password"},
+		{ID: 2, Title: "Apple", Owner: "Safari"},
+		{ID: 3, Title: "1Password mini", Owner: "1Password"},
+		{ID: 4, Title: "Slack | general", Owner: "Slack"},
+	}
+
+	tests := []struct {
+		name    string
+		targets []WindowTarget
+		want    []uint32
+	}{
+		{"every window of an app", []WindowTarget{{Title: "1password"}}, []uint32{1, 3}},
+		{"by ID", []WindowTarget{{ID: 2}}, []uint32{2}},
+		{"several targets", []WindowTarget{{ID: 4}, {Title: "vault"}}, []uint32{1, 4}},
+		{"matching nothing", []WindowTarget{{Title: "Keychain"}, {ID: 99}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchWindows(windows, tt.targets); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchWindows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
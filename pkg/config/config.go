@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Config holds user settings from config.json in Dir
+type Config struct {
+	// OutputDir is where recordings are written when -o is a bare file
+	// name. It may start with ~ and contain date placeholders such as
+	// {year} and {month}.
+	OutputDir string `json:"output_dir,omitempty"`
+
+	// Palettes overrides the GIF palette for a quality level ("low",
+	// "medium", or "high"). Each value is a palette file (.gpl or .hex) or
+	// a color count such as "64".
+	Palettes map[string]string `json:"palettes,omitempty"`
+
+	// Presets defines recording presets selected with -preset. A preset
+	// named like a built-in one replaces it.
+	Presets map[string]Preset `json:"presets,omitempty"`
+
+	// QuickPreset is the preset witness quick records with
+	QuickPreset string `json:"quick_preset,omitempty"`
+
+	// Schedules are recordings witness serve repeats on a cron schedule
+	Schedules []Schedule `json:"schedules,omitempty"`
+
+	// Watches are recordings witness serve makes while an application is
+	// frontmost
+	Watches []Watch `json:"watches,omitempty"`
+
+	// Retention limits how much of OutputDir recordings may fill. Older
+	// recordings are deleted by witness prune, or when new ones are written
+	// there if Auto is set.
+	Retention *Retention `json:"retention,omitempty"`
+}
+
+// Retention is a storage limit for the recordings directory
+type Retention struct {
+	// MaxSize is the most space recordings may take, e.g. "10GB"
+	MaxSize string `json:"max_size,omitempty"`
+
+	// MaxAge is how long recordings are kept, e.g. "30d" or "2w"
+	MaxAge string `json:"max_age,omitempty"`
+
+	// Auto applies the policy whenever a new recording is written to
+	// OutputDir, instead of only when witness prune runs
+	Auto bool `json:"auto,omitempty"`
+}
+
+// PaletteFor returns the palette override for a quality level: either a
+// palette file path, with a leading ~ expanded, or a color count. Both are
+// empty when the quality preset should be used.
+func (c *Config) PaletteFor(quality string) (path string, colors int, err error) {
+	value := strings.TrimSpace(c.Palettes[strings.ToLower(quality)])
+	if value == "" {
+		return "", 0, nil
+	}
+
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 1 {
+			return "", 0, fmt.Errorf("invalid color count %d for %s quality", n, quality)
+		}
+		return "", n, nil
+	}
+
+	if value == "~" || strings.HasPrefix(value, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		value = filepath.Join(home, value[1:])
+	}
+
+	return value, 0, nil
+}
+
+// Dir returns the directory witness keeps its settings and state in:
+// $XDG_CONFIG_HOME/witness when that is set, %AppData%\witness on Windows,
+// and ~/.config/witness otherwise, macOS included. The directory may not
+// exist yet.
+func Dir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "witness"), nil
+	}
+	if runtime.GOOS == "windows" {
+		appData, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get config directory: %w", err)
+		}
+		return filepath.Join(appData, "witness"), nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".config", "witness"), nil
+}
+
+// getConfigPath returns the path to the config file
+func getConfigPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// Load reads the user's settings. A missing file yields the defaults.
+func Load() (*Config, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	return &config, nil
+}
+
+// Save writes the user's settings
+func Save(config *Config) error {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return nil
+}
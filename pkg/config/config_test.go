@@ -0,0 +1,126 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLoadMissing(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if config.OutputDir != "" {
+		t.Errorf("OutputDir = %q, want empty default", config.OutputDir)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	if err := Save(&Config{OutputDir: "~/Recordings/{year}/{month}/"}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".config", "witness", "config.json"))
+	if err != nil {
+		t.Fatalf("config file not written: %v", err)
+	}
+	if want := `"output_dir": "~/Recordings/{year}/{month}/"`; !strings.Contains(string(data), want) {
+		t.Errorf("config file %s should contain %s", data, want)
+	}
+
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if config.OutputDir != "~/Recordings/{year}/{month}/" {
+		t.Errorf("OutputDir = %q after round trip", config.OutputDir)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	dir := filepath.Join(home, ".config", "witness")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte("{"), 0644)
+
+	if _, err := Load(); err == nil {
+		t.Error("Load() should fail on invalid JSON")
+	}
+}
+
+func TestPaletteFor(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	config := &Config{Palettes: map[string]string{
+		"low":    "32",
+		"high":   "~/themes/dracula.gpl",
+		"medium": "0",
+	}}
+
+	tests := []struct {
+		quality    string
+		wantPath   string
+		wantColors int
+		wantErr    bool
+	}{
+		{"low", "", 32, false},
+		{"HIGH", filepath.Join(home, "themes", "dracula.gpl"), 0, false},
+		{"medium", "", 0, true},
+		{"ultra", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.quality, func(t *testing.T) {
+			path, colors, err := config.PaletteFor(tt.quality)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PaletteFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if path != tt.wantPath || colors != tt.wantColors {
+				t.Errorf("PaletteFor() = %q, %d, want %q, %d", path, colors, tt.wantPath, tt.wantColors)
+			}
+		})
+	}
+}
+
+func TestDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	dir, err := Dir()
+	if err != nil {
+		t.Fatalf("Dir() failed: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		if want := filepath.Join(home, ".config", "witness"); dir != want {
+			t.Errorf("Dir() = %q, want %q", dir, want)
+		}
+	}
+
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	if dir, _ := Dir(); dir != filepath.Join(xdg, "witness") {
+		t.Errorf("Dir() = %q with XDG_CONFIG_HOME set, want %q", dir, filepath.Join(xdg, "witness"))
+	}
+
+	// A relative XDG_CONFIG_HOME is invalid and ignored
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	if dir, _ := Dir(); filepath.Base(filepath.Dir(dir)) == "relative" {
+		t.Errorf("Dir() = %q, want a relative XDG_CONFIG_HOME ignored", dir)
+	}
+}
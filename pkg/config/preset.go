@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Preset bundles recording settings tuned for a kind of content. Empty
+// fields leave the command's own default in place.
+type Preset struct {
+	// FPS is the frame rate, e.g. "10" or "30000/1001"
+	FPS string `json:"fps,omitempty"`
+
+	// Quality is the quality level: low, medium, or high
+	Quality string `json:"quality,omitempty"`
+
+	// Colors is the adaptive palette size, 1 to 256
+	Colors int `json:"colors,omitempty"`
+
+	// Palette is a palette file (.gpl or .hex), used instead of Colors
+	Palette string `json:"palette,omitempty"`
+
+	// Dither selects Floyd-Steinberg dithering for GIFs
+	Dither *bool `json:"dither,omitempty"`
+
+	// Scale resizes frames by this factor
+	Scale float64 `json:"scale,omitempty"`
+
+	// ScaleMode is the resampling used for Scale: smooth, text, or nearest
+	ScaleMode string `json:"scale_mode,omitempty"`
+
+	// HiDPI is the frame pixels captured per point on Retina displays:
+	// physical, logical, or a factor such as "1.5"
+	HiDPI string `json:"hidpi,omitempty"`
+
+	// IdleSkip caps how long an unchanged screen is kept, e.g. "1s"
+	IdleSkip string `json:"idle_skip,omitempty"`
+}
+
+// BuiltinPresets are the presets available without any configuration
+var BuiltinPresets = map[string]Preset{
+	// Few flat colors and long pauses while reading or typing
+	"terminal": {
+		FPS:       "10",
+		Colors:    32,
+		Dither:    boolPtr(false),
+		ScaleMode: "text",
+		IdleSkip:  "1s",
+	},
+	// Smooth scrolling and photos, with pauses while pages load
+	"browser-demo": {
+		FPS:       "15",
+		Colors:    128,
+		Dither:    boolPtr(true),
+		ScaleMode: "smooth",
+		IdleSkip:  "2s",
+	},
+	// Full-screen walkthroughs that keep their pacing for narration
+	"full-tutorial": {
+		FPS:       "24",
+		Quality:   "high",
+		Dither:    boolPtr(true),
+		ScaleMode: "smooth",
+	},
+}
+
+// Preset returns the named preset. Presets in the config file take
+// precedence over built-in presets with the same name.
+func (c *Config) Preset(name string) (Preset, error) {
+	key := strings.TrimSpace(name)
+	for _, presets := range []map[string]Preset{c.Presets, BuiltinPresets} {
+		for n, p := range presets {
+			if strings.EqualFold(n, key) {
+				return p, nil
+			}
+		}
+	}
+	return Preset{}, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(c.PresetNames(), ", "))
+}
+
+// PresetNames returns the names of all built-in and configured presets in
+// sorted order
+func (c *Config) PresetNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, presets := range []map[string]Preset{BuiltinPresets, c.Presets} {
+		for name := range presets {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// boolPtr returns a pointer to b
+func boolPtr(b bool) *bool {
+	return &b
+}
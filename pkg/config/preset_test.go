@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPreset(t *testing.T) {
+	config := &Config{Presets: map[string]Preset{
+		"Slides":   {FPS: "5", Colors: 64},
+		"terminal": {FPS: "8"},
+	}}
+
+	tests := []struct {
+		name    string
+		want    Preset
+		wantErr bool
+	}{
+		{name: "slides", want: Preset{FPS: "5", Colors: 64}},
+		{name: "terminal", want: Preset{FPS: "8"}},
+		{name: "Browser-Demo", want: BuiltinPresets["browser-demo"]},
+		{name: "cinema", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config.Preset(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Preset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Preset() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPresetNames(t *testing.T) {
+	config := &Config{Presets: map[string]Preset{"slides": {}, "terminal": {}}}
+
+	want := []string{"browser-demo", "full-tutorial", "slides", "terminal"}
+	if got := config.PresetNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("PresetNames() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadPresets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	dir := filepath.Join(home, ".config", "witness")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{
+  "presets": {
+    "slides": {"fps": "5", "dither": false, "scale": 0.5, "scale_mode": "text", "idle_skip": "3s"}
+  }
+}`), 0644)
+
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	p, err := config.Preset("slides")
+	if err != nil {
+		t.Fatalf("Preset() failed: %v", err)
+	}
+	if p.FPS != "5" || p.Dither == nil || *p.Dither || p.Scale != 0.5 || p.ScaleMode != "text" || p.IdleSkip != "3s" {
+		t.Errorf("Preset() = %+v", p)
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+// Schedule is a recording that witness serve repeats on a cron schedule
+type Schedule struct {
+	// Name identifies the schedule in witness schedule -list and -remove
+	Name string `json:"name"`
+
+	// Cron is when each recording starts, in crontab form such as
+	// "0 14 * * mon-fri", or a shorthand such as "@daily"
+	Cron string `json:"cron"`
+
+	// Duration is how long each recording lasts, e.g. "10m"
+	Duration string `json:"duration"`
+
+	// Output is the file name of each recording. Placeholders such as
+	// {date} and {time} are replaced with the start time, so recordings
+	// do not overwrite each other. The extension picks GIF or MP4.
+	Output string `json:"output"`
+
+	// Region is a saved region name or x,y,w,h; empty records the whole
+	// screen
+	Region string `json:"region,omitempty"`
+
+	// FPS is the frame rate, e.g. "15" or "30000/1001"
+	FPS string `json:"fps,omitempty"`
+
+	// Quality is the quality level: low, medium, or high
+	Quality string `json:"quality,omitempty"`
+}
@@ -0,0 +1,23 @@
+package config
+
+// Watch is a rule for witness serve to record while an application is
+// frontmost, starting a new recording each time it comes to the front
+type Watch struct {
+	// App is the application's name, or part of it, ignoring case
+	App string `json:"app"`
+
+	// Output is the file name of each recording. Placeholders such as
+	// {date} and {time} are replaced with the start time, so sessions do
+	// not overwrite each other. The extension picks GIF or MP4.
+	Output string `json:"output"`
+
+	// Region is a saved region name or x,y,w,h; empty records the whole
+	// screen
+	Region string `json:"region,omitempty"`
+
+	// FPS is the frame rate, e.g. "15" or "30000/1001"
+	FPS string `json:"fps,omitempty"`
+
+	// Quality is the quality level: low, medium, or high
+	Quality string `json:"quality,omitempty"`
+}
@@ -0,0 +1,90 @@
+package consent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/selector"
+)
+
+// DefaultMessage is shown in the consent banner when none is configured
+const DefaultMessage = "This screen is about to be recorded. Everything shown on it, including notifications, will be captured."
+
+// ErrDeclined is returned when the user dismisses the consent banner
+var ErrDeclined = errors.New("recording declined")
+
+// ErrUnavailable is returned when the consent banner cannot be shown, so
+// no answer was given
+var ErrUnavailable = errors.New("consent banner unavailable")
+
+// canceled is what the dialog script prints when the user cancels
+const canceled = "User canceled"
+
+// Banner asks the user to acknowledge that recording is about to start,
+// for shared machines and compliance-sensitive environments
+type Banner struct {
+	// Message is the body of the banner
+	Message string
+
+	cmd selector.SystemCommand
+}
+
+// NewBanner creates a banner that uses native macOS dialogs
+func NewBanner(message string) *Banner {
+	return NewBannerWithExecutor(message, selector.NewRealSystemCommand())
+}
+
+// NewBannerWithExecutor creates a banner with a custom command executor
+// This is primarily used for testing with mock commands
+func NewBannerWithExecutor(message string, executor selector.SystemCommand) *Banner {
+	if message == "" {
+		message = DefaultMessage
+	}
+	return &Banner{Message: message, cmd: executor}
+}
+
+// Confirm shows a "recording in progress" dialog that must be clicked through
+// before capture starts. It returns ErrDeclined if the user cancels, and
+// ErrUnavailable if the dialog could not be shown.
+func (b *Banner) Confirm() error {
+	// Cancelling raises AppleScript error -128, which is caught so that it
+	// can be told apart from osascript failing
+	script := fmt.Sprintf(`try
+	display dialog %s with title "Recording in progress" buttons {"Cancel", "Start Recording"} default button "Start Recording" cancel button "Cancel" with icon caution
+on error number -128
+	return "%s"
+end try`,
+		appleScriptString(b.Message), canceled)
+
+	out, err := b.cmd.Run("osascript", "-e", script)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if strings.Contains(string(out), canceled) {
+		return ErrDeclined
+	}
+	if !strings.Contains(string(out), "Start Recording") {
+		return fmt.Errorf("%w: unexpected dialog result %q", ErrUnavailable, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// Notify shows a notification that recording has started, so it stays
+// visible to anyone at the machine after the banner is dismissed
+func (b *Banner) Notify() error {
+	script := fmt.Sprintf(`display notification %s with title "Witness" subtitle "Recording in progress"`,
+		appleScriptString(b.Message))
+	if _, err := b.cmd.Run("osascript", "-e", script); err != nil {
+		return fmt.Errorf("failed to show notification: %w", err)
+	}
+	return nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
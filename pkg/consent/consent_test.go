@@ -0,0 +1,99 @@
+package consent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ericmhalvorsen/witness/pkg/selector"
+)
+
+func TestConfirmAccepted(t *testing.T) {
+	mock := selector.NewMockSystemCommand()
+	mock.SetOutput("osascript", []byte("button returned:Start Recording\n"))
+
+	banner := NewBannerWithExecutor("", mock)
+	if err := banner.Confirm(); err != nil {
+		t.Errorf("Confirm() error = %v, want nil", err)
+	}
+
+	if mock.GetCallCount("osascript") != 1 {
+		t.Fatalf("osascript called %d times, want 1", mock.GetCallCount("osascript"))
+	}
+	script := mock.CallLog[0].Args[1]
+	if !strings.Contains(script, DefaultMessage) {
+		t.Errorf("dialog script %q should contain the default message", script)
+	}
+}
+
+func TestConfirmDeclined(t *testing.T) {
+	mock := selector.NewMockSystemCommand()
+	mock.SetOutput("osascript", []byte("User canceled\n"))
+
+	banner := NewBannerWithExecutor("Recording", mock)
+	if err := banner.Confirm(); !errors.Is(err, ErrDeclined) {
+		t.Errorf("Confirm() error = %v, want %v", err, ErrDeclined)
+	}
+	if script := mock.CallLog[0].Args[1]; !strings.Contains(script, "on error number -128") {
+		t.Errorf("dialog script %q should catch the cancel error", script)
+	}
+}
+
+func TestConfirmUnavailable(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		err    error
+	}{
+		{"osascript fails", "", errors.New("exit status 1")},
+		{"unexpected output", "gave up:true\n", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := selector.NewMockSystemCommand()
+			mock.SetOutput("osascript", []byte(tt.output))
+			if tt.err != nil {
+				mock.SetError("osascript", tt.err)
+			}
+
+			banner := NewBannerWithExecutor("Recording", mock)
+			err := banner.Confirm()
+			if !errors.Is(err, ErrUnavailable) {
+				t.Errorf("Confirm() error = %v, want %v", err, ErrUnavailable)
+			}
+			if errors.Is(err, ErrDeclined) {
+				t.Errorf("Confirm() error = %v, should not count as a decline", err)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	mock := selector.NewMockSystemCommand()
+
+	banner := NewBannerWithExecutor(`Say "hi"`, mock)
+	if err := banner.Notify(); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if script := mock.CallLog[0].Args[1]; !strings.Contains(script, `"Say \"hi\""`) {
+		t.Errorf("notification script %q should quote the message", script)
+	}
+}
+
+func TestAppleScriptString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", `"plain"`},
+		{`a "quote"`, `"a \"quote\""`},
+		{`back\slash`, `"back\\slash"`},
+	}
+
+	for _, tt := range tests {
+		if got := appleScriptString(tt.in); got != tt.want {
+			t.Errorf("appleScriptString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
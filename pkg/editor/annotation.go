@@ -0,0 +1,389 @@
+package editor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/internal/font"
+)
+
+// Annotation types
+const (
+	AnnotationArrow   = "arrow"
+	AnnotationBox     = "box"
+	AnnotationEllipse = "ellipse"
+	AnnotationBlur    = "blur"
+	AnnotationText    = "text"
+)
+
+// Annotation is a shape or caption drawn over a range of frames.
+// Coordinates are frame pixels with the origin at the top-left corner.
+type Annotation struct {
+	// Type is one of the Annotation* constants
+	Type string `json:"type"`
+
+	// StartMS and EndMS bound the time the annotation is visible, in
+	// milliseconds from the start of the clip. EndMS of 0 means until the end.
+	StartMS int64 `json:"start_ms"`
+	EndMS   int64 `json:"end_ms,omitempty"`
+
+	// X and Y are the top-left corner of a box, ellipse or blur patch, the
+	// anchor of text, or the tail of an arrow
+	X int `json:"x"`
+	Y int `json:"y"`
+
+	// X2 and Y2 are the head of an arrow
+	X2 int `json:"x2,omitempty"`
+	Y2 int `json:"y2,omitempty"`
+
+	// Width and Height are the size of a box, ellipse or blur patch
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+
+	// Text is the caption for text annotations
+	Text string `json:"text,omitempty"`
+
+	// Color is a hex color ("#ff0000", "#ff000080") or a basic color name.
+	// Defaults to red.
+	Color string `json:"color,omitempty"`
+
+	// Background fills behind text when set
+	Background string `json:"background,omitempty"`
+
+	// Thickness is the stroke width in pixels (default 3)
+	Thickness int `json:"thickness,omitempty"`
+
+	// Scale multiplies the size of the built-in 5x7 font (default 2)
+	Scale int `json:"scale,omitempty"`
+
+	// Radius is the strength of a blur patch in pixels (default 8)
+	Radius int `json:"radius,omitempty"`
+}
+
+// Active reports whether the annotation is visible at time t (milliseconds)
+func (a Annotation) Active(t int64) bool {
+	if t < a.StartMS {
+		return false
+	}
+	return a.EndMS == 0 || t < a.EndMS
+}
+
+// Validate checks that the annotation has the fields its type requires
+func (a Annotation) Validate() error {
+	if a.EndMS != 0 && a.EndMS <= a.StartMS {
+		return fmt.Errorf("%s annotation ends before it starts", a.Type)
+	}
+	if _, err := ParseColor(a.colorOrDefault()); err != nil {
+		return err
+	}
+	if a.Background != "" {
+		if _, err := ParseColor(a.Background); err != nil {
+			return err
+		}
+	}
+
+	switch a.Type {
+	case AnnotationArrow:
+		if a.X == a.X2 && a.Y == a.Y2 {
+			return fmt.Errorf("arrow annotation has zero length")
+		}
+	case AnnotationBox, AnnotationEllipse, AnnotationBlur:
+		if a.Width <= 0 || a.Height <= 0 {
+			return fmt.Errorf("%s annotation must have positive width and height", a.Type)
+		}
+	case AnnotationText:
+		if a.Text == "" {
+			return fmt.Errorf("text annotation has no text")
+		}
+	default:
+		return fmt.Errorf("unknown annotation type %q", a.Type)
+	}
+
+	return nil
+}
+
+// Draw renders the annotation onto img
+func (a Annotation) Draw(img draw.Image) {
+	c, err := ParseColor(a.colorOrDefault())
+	if err != nil {
+		return
+	}
+
+	thickness := a.Thickness
+	if thickness <= 0 {
+		thickness = 3
+	}
+
+	switch a.Type {
+	case AnnotationArrow:
+		drawArrow(img, a.X, a.Y, a.X2, a.Y2, thickness, c)
+	case AnnotationBox:
+		drawBox(img, image.Rect(a.X, a.Y, a.X+a.Width, a.Y+a.Height), thickness, c)
+	case AnnotationEllipse:
+		drawEllipse(img, image.Rect(a.X, a.Y, a.X+a.Width, a.Y+a.Height), thickness, c)
+	case AnnotationBlur:
+		radius := a.Radius
+		if radius <= 0 {
+			radius = 8
+		}
+		blurRect(img, image.Rect(a.X, a.Y, a.X+a.Width, a.Y+a.Height), radius)
+	case AnnotationText:
+		scale := a.Scale
+		if scale <= 0 {
+			scale = 2
+		}
+		if a.Background != "" {
+			if bg, err := ParseColor(a.Background); err == nil {
+				w, h := font.TextSize(a.Text, scale)
+				pad := scale * 2
+				rect := image.Rect(a.X-pad, a.Y-pad, a.X+w+pad, a.Y+h+pad)
+				draw.Draw(img, rect, image.NewUniform(bg), image.Point{}, draw.Over)
+			}
+		}
+		drawText(img, a.X, a.Y, a.Text, scale, c)
+	}
+}
+
+// colorOrDefault returns the annotation color, defaulting to red
+func (a Annotation) colorOrDefault() string {
+	if a.Color == "" {
+		return "red"
+	}
+	return a.Color
+}
+
+// namedColors are the color names accepted by ParseColor
+var namedColors = map[string]color.RGBA{
+	"red":    {R: 255, A: 255},
+	"green":  {G: 200, A: 255},
+	"blue":   {B: 255, A: 255},
+	"yellow": {R: 255, G: 220, A: 255},
+	"orange": {R: 255, G: 140, A: 255},
+	"white":  {R: 255, G: 255, B: 255, A: 255},
+	"black":  {A: 255},
+}
+
+// ParseColor parses "#rrggbb", "#rrggbbaa" or a basic color name
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	if len(hex) == 6 {
+		v = v<<8 | 0xff
+	}
+
+	// Store premultiplied, as color.RGBA requires
+	a := uint32(v & 0xff)
+	return color.RGBA{
+		R: uint8(uint32(v>>24&0xff) * a / 255),
+		G: uint8(uint32(v>>16&0xff) * a / 255),
+		B: uint8(uint32(v>>8&0xff) * a / 255),
+		A: uint8(a),
+	}, nil
+}
+
+// fillRect blends c over r
+func fillRect(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r.Intersect(img.Bounds()), image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// drawBox draws a rectangle outline of the given thickness inside r
+func drawBox(img draw.Image, r image.Rectangle, thickness int, c color.Color) {
+	t := min(thickness, r.Dx()/2+1, r.Dy()/2+1)
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+t), c)
+	fillRect(img, image.Rect(r.Min.X, r.Max.Y-t, r.Max.X, r.Max.Y), c)
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y+t, r.Min.X+t, r.Max.Y-t), c)
+	fillRect(img, image.Rect(r.Max.X-t, r.Min.Y+t, r.Max.X, r.Max.Y-t), c)
+}
+
+// drawEllipse draws an ellipse outline of the given thickness inscribed in r
+func drawEllipse(img draw.Image, r image.Rectangle, thickness int, c color.Color) {
+	bounds := r.Intersect(img.Bounds())
+	if bounds.Empty() {
+		return
+	}
+
+	// A pixel is on the outline when its center is inside the outer ellipse
+	// and outside the inner one
+	cx := float64(r.Min.X+r.Max.X) / 2
+	cy := float64(r.Min.Y+r.Max.Y) / 2
+	ax, ay := float64(r.Dx())/2, float64(r.Dy())/2
+	bx, by := ax-float64(thickness), ay-float64(thickness)
+
+	mask := image.NewAlpha(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dx := float64(x) + 0.5 - cx
+			dy := float64(y) + 0.5 - cy
+			if dx*dx/(ax*ax)+dy*dy/(ay*ay) > 1 {
+				continue
+			}
+			if bx > 0 && by > 0 && dx*dx/(bx*bx)+dy*dy/(by*by) < 1 {
+				continue
+			}
+			mask.SetAlpha(x, y, color.Alpha{A: 0xff})
+		}
+	}
+
+	draw.DrawMask(img, bounds, image.NewUniform(c), image.Point{}, mask, bounds.Min, draw.Over)
+}
+
+// blurRect blurs the pixels inside r with two passes of a box blur,
+// approximating a Gaussian blur of the given radius
+func blurRect(img draw.Image, r image.Rectangle, radius int) {
+	bounds := r.Intersect(img.Bounds())
+	if bounds.Empty() {
+		return
+	}
+
+	patch := image.NewRGBA(bounds)
+	draw.Draw(patch, bounds, img, bounds.Min, draw.Src)
+
+	for pass := 0; pass < 2; pass++ {
+		boxBlur(patch, radius, true)
+		boxBlur(patch, radius, false)
+	}
+
+	draw.Draw(img, bounds, patch, bounds.Min, draw.Src)
+}
+
+// boxBlur applies a one-dimensional running-sum box blur in place
+func boxBlur(img *image.RGBA, radius int, horizontal bool) {
+	b := img.Bounds()
+	outer, inner := b.Dy(), b.Dx()
+	if !horizontal {
+		outer, inner = inner, outer
+	}
+
+	offset := func(o, i int) int {
+		if horizontal {
+			return o*img.Stride + i*4
+		}
+		return i*img.Stride + o*4
+	}
+
+	line := make([]uint8, inner*4)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			copy(line[i*4:i*4+4], img.Pix[offset(o, i):offset(o, i)+4])
+		}
+
+		var sum [4]int
+		for i := -radius; i <= radius; i++ {
+			j := min(max(i, 0), inner-1)
+			for ch := 0; ch < 4; ch++ {
+				sum[ch] += int(line[j*4+ch])
+			}
+		}
+
+		n := 2*radius + 1
+		for i := 0; i < inner; i++ {
+			px := img.Pix[offset(o, i) : offset(o, i)+4]
+			for ch := 0; ch < 4; ch++ {
+				px[ch] = uint8(sum[ch] / n)
+			}
+
+			add := min(i+radius+1, inner-1)
+			sub := max(i-radius, 0)
+			for ch := 0; ch < 4; ch++ {
+				sum[ch] += int(line[add*4+ch]) - int(line[sub*4+ch])
+			}
+		}
+	}
+}
+
+// drawLine draws a line of the given thickness from (x0,y0) to (x1,y1)
+func drawLine(img draw.Image, x0, y0, x1, y1, thickness int, c color.Color) {
+	// Plot each pixel of the line as a square brush, tracking covered
+	// pixels so translucent colors are not blended twice
+	half := thickness / 2
+	bounds := image.Rect(x0, y0, x1, y1).Canon().Inset(-thickness).Intersect(img.Bounds())
+	if bounds.Empty() {
+		return
+	}
+	mask := image.NewAlpha(bounds)
+
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+
+	for {
+		r := image.Rect(x0-half, y0-half, x0-half+thickness, y0-half+thickness)
+		draw.Draw(mask, r.Intersect(mask.Bounds()), image.Opaque, image.Point{}, draw.Src)
+		if x0 == x1 && y0 == y1 {
+			break
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+
+	draw.DrawMask(img, bounds, image.NewUniform(c), image.Point{}, mask, bounds.Min, draw.Over)
+}
+
+// drawArrow draws a line from the tail (x0,y0) with a head at (x1,y1)
+func drawArrow(img draw.Image, x0, y0, x1, y1, thickness int, c color.Color) {
+	drawLine(img, x0, y0, x1, y1, thickness, c)
+
+	angle := math.Atan2(float64(y1-y0), float64(x1-x0))
+	length := float64(thickness*3 + 8)
+	for _, spread := range []float64{math.Pi / 6, -math.Pi / 6} {
+		hx := x1 - int(math.Round(length*math.Cos(angle+spread)))
+		hy := y1 - int(math.Round(length*math.Sin(angle+spread)))
+		drawLine(img, x1, y1, hx, hy, thickness, c)
+	}
+}
+
+// drawText renders s with the built-in bitmap font, top-left at (x,y)
+func drawText(img draw.Image, x, y int, s string, scale int, c color.Color) {
+	for _, r := range s {
+		g := font.Glyph(r)
+		for col := 0; col < font.Width; col++ {
+			for row := 0; row < font.Height; row++ {
+				if g[col]&(1<<row) == 0 {
+					continue
+				}
+				px := x + col*scale
+				py := y + row*scale
+				fillRect(img, image.Rect(px, py, px+scale, py+scale), c)
+			}
+		}
+		x += (font.Width + 1) * scale
+	}
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,192 @@
+package editor
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    color.RGBA
+		wantErr bool
+	}{
+		{input: "red", want: color.RGBA{R: 255, A: 255}},
+		{input: "#00ff00", want: color.RGBA{G: 255, A: 255}},
+		{input: "#0000FF", want: color.RGBA{B: 255, A: 255}},
+		{input: "#ffffff80", want: color.RGBA{R: 128, G: 128, B: 128, A: 128}},
+		{input: "purple", wantErr: true},
+		{input: "#fff", wantErr: true},
+		{input: "#gggggg", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseColor(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseColor(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseColor(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnotationValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       Annotation
+		wantErr bool
+	}{
+		{name: "valid box", a: Annotation{Type: AnnotationBox, Width: 10, Height: 10}},
+		{name: "valid arrow", a: Annotation{Type: AnnotationArrow, X2: 10, Y2: 10}},
+		{name: "valid text", a: Annotation{Type: AnnotationText, Text: "hi"}},
+		{name: "empty box", a: Annotation{Type: AnnotationBox}, wantErr: true},
+		{name: "zero-length arrow", a: Annotation{Type: AnnotationArrow}, wantErr: true},
+		{name: "empty text", a: Annotation{Type: AnnotationText}, wantErr: true},
+		{name: "unknown type", a: Annotation{Type: "star"}, wantErr: true},
+		{name: "bad color", a: Annotation{Type: AnnotationText, Text: "hi", Color: "nope"}, wantErr: true},
+		{name: "ends before start", a: Annotation{Type: AnnotationText, Text: "hi", StartMS: 500, EndMS: 100}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.a.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnnotationActive(t *testing.T) {
+	a := Annotation{StartMS: 100, EndMS: 200}
+	if a.Active(99) || !a.Active(100) || !a.Active(199) || a.Active(200) {
+		t.Error("Active() should cover [StartMS, EndMS)")
+	}
+
+	open := Annotation{StartMS: 100}
+	if !open.Active(1_000_000) {
+		t.Error("Active() with no EndMS should last until the end")
+	}
+}
+
+// Helper function to count pixels matching c
+func countColor(img *image.RGBA, c color.RGBA) int {
+	n := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.RGBAAt(x, y) == c {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestAnnotationDraw(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+
+	tests := []struct {
+		name string
+		a    Annotation
+		want func(img *image.RGBA) bool
+	}{
+		{
+			name: "box outline leaves interior untouched",
+			a:    Annotation{Type: AnnotationBox, X: 10, Y: 10, Width: 20, Height: 20, Thickness: 2},
+			want: func(img *image.RGBA) bool {
+				return img.RGBAAt(10, 10) == red && img.RGBAAt(20, 20) != red
+			},
+		},
+		{
+			name: "arrow covers tail and head",
+			a:    Annotation{Type: AnnotationArrow, X: 5, Y: 50, X2: 50, Y2: 50},
+			want: func(img *image.RGBA) bool {
+				return img.RGBAAt(5, 50) == red && img.RGBAAt(50, 50) == red && img.RGBAAt(27, 50) == red
+			},
+		},
+		{
+			name: "text draws glyph pixels",
+			a:    Annotation{Type: AnnotationText, X: 0, Y: 0, Text: "I", Scale: 1},
+			want: func(img *image.RGBA) bool {
+				// The stem of 'I' is the middle column
+				return img.RGBAAt(2, 3) == red && img.RGBAAt(0, 3) != red
+			},
+		},
+		{
+			name: "annotations outside the frame are clipped",
+			a:    Annotation{Type: AnnotationBox, X: -50, Y: -50, Width: 500, Height: 500},
+			want: func(img *image.RGBA) bool { return true },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+			tt.a.Draw(img)
+			if !tt.want(img) {
+				t.Errorf("unexpected rendering, %d red pixels", countColor(img, red))
+			}
+		})
+	}
+}
+
+func TestTextBackground(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	a := Annotation{Type: AnnotationText, X: 10, Y: 10, Text: "ok", Color: "white", Background: "black", Scale: 1}
+	a.Draw(img)
+
+	black := color.RGBA{A: 255}
+	if img.RGBAAt(9, 9) != black {
+		t.Errorf("background pixel = %v, want %v", img.RGBAAt(9, 9), black)
+	}
+}
+
+func TestEllipseDraw(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	a := Annotation{Type: AnnotationEllipse, X: 0, Y: 0, Width: 64, Height: 32, Thickness: 2}
+	a.Draw(img)
+
+	red := color.RGBA{R: 255, A: 255}
+	if img.RGBAAt(32, 0) != red || img.RGBAAt(0, 16) != red {
+		t.Error("ellipse outline should touch the bounding box edges")
+	}
+	if img.RGBAAt(32, 16) == red {
+		t.Error("ellipse interior should be untouched")
+	}
+	if img.RGBAAt(0, 0) == red {
+		t.Error("bounding box corners should be outside the ellipse")
+	}
+	if img.RGBAAt(32, 48) == red {
+		t.Error("pixels below the ellipse should be untouched")
+	}
+}
+
+func TestBlurDraw(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+
+	// Vertical stripes blur into a flat gray inside the patch
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			if x%2 == 0 {
+				img.SetRGBA(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+			} else {
+				img.SetRGBA(x, y, color.RGBA{A: 255})
+			}
+		}
+	}
+
+	a := Annotation{Type: AnnotationBlur, X: 8, Y: 8, Width: 16, Height: 16, Radius: 3}
+	a.Draw(img)
+
+	c := img.RGBAAt(16, 16)
+	if c.R < 100 || c.R > 155 {
+		t.Errorf("blurred pixel = %v, want mid gray", c)
+	}
+	if img.RGBAAt(0, 0).R != 255 || img.RGBAAt(1, 0).R != 0 {
+		t.Error("pixels outside the patch should be untouched")
+	}
+}
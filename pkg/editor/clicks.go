@@ -0,0 +1,104 @@
+package editor
+
+import (
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// ClickRipples draws an expanding ring wherever the user clicks, so
+// tutorials show what was clicked. Clicks are in global points and are
+// mapped into frames showing area, which is also in global points.
+type ClickRipples struct {
+	// Duration is how long each ripple lasts
+	Duration time.Duration
+
+	// Radius is the ring's final radius in points
+	Radius int
+
+	// Color is the ring's color; it fades out as the ring expands
+	Color color.RGBA
+
+	next    recorder.FrameSink
+	clicks  <-chan capture.Click
+	area    capture.Region
+	pending []capture.Click // Clicks whose ripples have not finished
+}
+
+// NewClickRipples creates a filter that draws ripples for clicks onto
+// frames showing area and forwards them to next
+func NewClickRipples(next recorder.FrameSink, clicks <-chan capture.Click, area capture.Region) *ClickRipples {
+	return &ClickRipples{
+		Duration: 600 * time.Millisecond,
+		Radius:   24,
+		Color:    namedColors["yellow"],
+		next:     next,
+		clicks:   clicks,
+		area:     area,
+	}
+}
+
+// AddFrame draws the ripples of recent clicks onto the frame and forwards it
+func (c *ClickRipples) AddFrame(frame *capture.Frame) error {
+	c.receive()
+	if frame != nil && frame.Image != nil {
+		c.draw(frame)
+	}
+
+	return c.next.AddFrame(frame)
+}
+
+// receive collects the clicks that have arrived without waiting
+func (c *ClickRipples) receive() {
+	for {
+		select {
+		case click, ok := <-c.clicks:
+			if !ok {
+				return
+			}
+			c.pending = append(c.pending, click)
+		default:
+			return
+		}
+	}
+}
+
+// draw draws a ring for each click in progress at the frame's timestamp,
+// growing from a third of the radius and fading out, and forgets finished
+// clicks
+func (c *ClickRipples) draw(frame *capture.Frame) {
+	if c.area.Width <= 0 || c.area.Height <= 0 {
+		return
+	}
+	bounds := frame.Image.Bounds()
+	scaleX := float64(bounds.Dx()) / float64(c.area.Width)
+	scaleY := float64(bounds.Dy()) / float64(c.area.Height)
+
+	kept := c.pending[:0]
+	for _, click := range c.pending {
+		age := frame.Timestamp.Sub(click.Time)
+		if age >= c.Duration {
+			continue
+		}
+		kept = append(kept, click)
+		if age < 0 {
+			continue // Clicked after this frame was captured
+		}
+
+		progress := float64(age) / float64(c.Duration)
+		radius := float64(c.Radius) * (1 + 2*progress) / 3
+		rx, ry := int(radius*scaleX+0.5), int(radius*scaleY+0.5)
+		center := image.Pt(
+			bounds.Min.X+int(float64(click.X-c.area.X)*scaleX+0.5),
+			bounds.Min.Y+int(float64(click.Y-c.area.Y)*scaleY+0.5),
+		)
+
+		ring := color.NRGBA{R: c.Color.R, G: c.Color.G, B: c.Color.B, A: uint8(float64(c.Color.A) * (1 - progress))}
+		thickness := max(1, int(3*scaleX+0.5))
+		drawEllipse(frame.Image, image.Rect(center.X-rx, center.Y-ry, center.X+rx, center.Y+ry), thickness, ring)
+	}
+	c.pending = kept
+}
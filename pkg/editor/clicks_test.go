@@ -0,0 +1,85 @@
+package editor
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestClickRipples(t *testing.T) {
+	start := time.Now()
+	clicks := make(chan capture.Click, 2)
+	clicks <- capture.Click{X: 110, Y: 60, Time: start}
+
+	// Frames show a 40x40 point area at 2x, so the click is at (20,20)
+	sink := &recordingSink{}
+	ripples := NewClickRipples(sink, clicks, capture.Region{X: 100, Y: 50, Width: 40, Height: 40})
+
+	tests := []struct {
+		name    string
+		offset  time.Duration
+		onRing  image.Point
+		drawn   bool
+		pending int
+	}{
+		{"just clicked", 0, image.Pt(34, 20), true, 1},
+		{"fully expanded", 590 * time.Millisecond, image.Pt(20, 66), true, 1},
+		{"finished", 700 * time.Millisecond, image.Pt(34, 20), false, 0},
+	}
+
+	yellow := namedColors["yellow"]
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := &capture.Frame{
+				Image:     image.NewRGBA(image.Rect(0, 0, 80, 80)),
+				Timestamp: start.Add(tt.offset),
+			}
+			if err := ripples.AddFrame(frame); err != nil {
+				t.Fatalf("AddFrame() failed: %v", err)
+			}
+
+			got := frame.Image.RGBAAt(tt.onRing.X, tt.onRing.Y)
+			if drawn := got != (color.RGBA{}); drawn != tt.drawn {
+				t.Errorf("pixel %v = %v, drawn = %v, want %v", tt.onRing, got, drawn, tt.drawn)
+			}
+			if tt.offset == 0 && got != yellow {
+				t.Errorf("new ripple pixel = %v, want %v", got, yellow)
+			}
+			if center := frame.Image.RGBAAt(20, 20); center != (color.RGBA{}) {
+				t.Errorf("center pixel = %v, want the ring to be hollow", center)
+			}
+			if len(ripples.pending) != tt.pending {
+				t.Errorf("pending clicks = %d, want %d", len(ripples.pending), tt.pending)
+			}
+		})
+	}
+
+	if len(sink.frames) != len(tests) {
+		t.Errorf("forwarded %d frames, want %d", len(sink.frames), len(tests))
+	}
+}
+
+func TestClickRipplesWaitForLaterClicks(t *testing.T) {
+	start := time.Now()
+	clicks := make(chan capture.Click, 1)
+	clicks <- capture.Click{X: 5, Y: 5, Time: start.Add(time.Second)}
+	close(clicks)
+
+	ripples := NewClickRipples(&recordingSink{}, clicks, capture.Region{Width: 10, Height: 10})
+	frame := &capture.Frame{Image: image.NewRGBA(image.Rect(0, 0, 10, 10)), Timestamp: start}
+	if err := ripples.AddFrame(frame); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+
+	for i, b := range frame.Image.Pix {
+		if b != 0 {
+			t.Fatalf("byte %d = %d, want a click after the frame not to be drawn", i, b)
+		}
+	}
+	if len(ripples.pending) != 1 {
+		t.Errorf("pending clicks = %d, want 1", len(ripples.pending))
+	}
+}
@@ -0,0 +1,246 @@
+package editor
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Clip is an editable sequence of full-size frames with per-frame delays
+type Clip struct {
+	// Frames are full-canvas paletted images
+	Frames []*image.Paletted
+
+	// Delays holds the delay of each frame in 100ths of a second
+	Delays []int
+
+	// LoopCount is the GIF loop count (0 loops forever)
+	LoopCount int
+}
+
+// LoadGIF reads an animated GIF into a clip.
+// Frames that only cover part of the canvas are composited so every frame
+// in the clip can be reordered or edited independently.
+func LoadGIF(path string) (*Clip, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GIF: %w", err)
+	}
+	defer f.Close()
+
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode GIF: %w", err)
+	}
+
+	return clipFromGIF(g), nil
+}
+
+// clipFromGIF composites decoded GIF frames into full-size frames
+func clipFromGIF(g *gif.GIF) *Clip {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+
+	canvas := image.NewRGBA(bounds)
+	clip := &Clip{
+		Frames:    make([]*image.Paletted, 0, len(g.Image)),
+		Delays:    make([]int, 0, len(g.Image)),
+		LoopCount: g.LoopCount,
+	}
+
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		full := image.NewPaletted(bounds, frame.Palette)
+		draw.Draw(full, bounds, canvas, bounds.Min, draw.Src)
+		clip.Frames = append(clip.Frames, full)
+
+		delay := 0
+		if i < len(g.Delay) {
+			delay = g.Delay[i]
+		}
+		clip.Delays = append(clip.Delays, delay)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return clip
+}
+
+// Len returns the number of frames in the clip
+func (c *Clip) Len() int {
+	return len(c.Frames)
+}
+
+// Reverse reverses the frame order. Each frame keeps its own delay.
+func (c *Clip) Reverse() {
+	for i, j := 0, len(c.Frames)-1; i < j; i, j = i+1, j-1 {
+		c.Frames[i], c.Frames[j] = c.Frames[j], c.Frames[i]
+		c.Delays[i], c.Delays[j] = c.Delays[j], c.Delays[i]
+	}
+}
+
+// Trim keeps frames first through last, counting from 0, and drops the rest
+func (c *Clip) Trim(first, last int) error {
+	if first < 0 || last >= len(c.Frames) || first > last {
+		return fmt.Errorf("frames %d-%d are outside the clip's %d frames", first, last, len(c.Frames))
+	}
+	c.Frames = c.Frames[first : last+1]
+	c.Delays = c.Delays[first : last+1]
+	return nil
+}
+
+// ParseTrim parses a frame range such as "12:87", counting from 0 and
+// including both ends, for a clip of n frames. Either end may be left out
+// to keep the start or end of the clip, e.g. "12:" or ":87".
+func ParseTrim(s string, n int) (first, last int, err error) {
+	start, end, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid trim %q: want first:last, e.g. 12:87", s)
+	}
+
+	first, last = 0, n-1
+	if start != "" {
+		if first, err = strconv.Atoi(start); err != nil {
+			return 0, 0, fmt.Errorf("invalid trim start %q", start)
+		}
+	}
+	if end != "" {
+		if last, err = strconv.Atoi(end); err != nil {
+			return 0, 0, fmt.Errorf("invalid trim end %q", end)
+		}
+	}
+	return first, last, nil
+}
+
+// ClipReader reads a clip's frames in order as capture frames
+type ClipReader struct {
+	clip  *Clip
+	times []int64
+	n     int
+}
+
+// Reader returns a reader for the clip's frames, timestamped from their
+// delays starting at capture.Epoch. It satisfies source.Reader.
+func (c *Clip) Reader() *ClipReader {
+	return &ClipReader{clip: c, times: c.frameTimes()}
+}
+
+// ReadFrame returns the next frame as RGBA, or io.EOF after the last
+func (r *ClipReader) ReadFrame() (*capture.Frame, error) {
+	if r.n >= len(r.clip.Frames) {
+		return nil, io.EOF
+	}
+	f := r.clip.Frames[r.n]
+	img := image.NewRGBA(image.Rectangle{Max: f.Bounds().Size()})
+	draw.Draw(img, img.Rect, f, f.Bounds().Min, draw.Src)
+	at := capture.Epoch.Add(time.Duration(r.times[r.n]) * time.Millisecond)
+	r.n++
+	return &capture.Frame{Image: img, Timestamp: at}, nil
+}
+
+// AutoCrop trims static, uniform borders (such as desktop background around
+// a window) from every frame and returns the rectangle that was kept, in the
+// original frame coordinates
+func (c *Clip) AutoCrop() image.Rectangle {
+	if len(c.Frames) == 0 {
+		return image.Rectangle{}
+	}
+
+	crop := analyze.BorderCrop(c.images(), analyze.DefaultTolerance)
+	c.Crop(crop)
+	return crop
+}
+
+// Activity reports which parts of the clip change over time
+func (c *Clip) Activity() analyze.Activity {
+	return analyze.DetectActivity(c.images(), analyze.DefaultTolerance)
+}
+
+// Loop finds the frames of the clip that loop most seamlessly
+func (c *Clip) Loop() (analyze.Loop, bool) {
+	return analyze.FindLoop(c.images(), analyze.DefaultTolerance)
+}
+
+// Crop replaces every frame with its part inside r, moved to the origin
+func (c *Clip) Crop(r image.Rectangle) {
+	if len(c.Frames) == 0 || r == c.Frames[0].Bounds() {
+		return
+	}
+	for i, f := range c.Frames {
+		c.Frames[i] = analyze.CropPaletted(f, r)
+	}
+}
+
+// images returns the frames for use with the analyze package
+func (c *Clip) images() []image.Image {
+	out := make([]image.Image, len(c.Frames))
+	for i, f := range c.Frames {
+		out[i] = f
+	}
+	return out
+}
+
+// SaveGIF writes the clip as an animated GIF
+func (c *Clip) SaveGIF(path string) error {
+	if len(c.Frames) == 0 {
+		return fmt.Errorf("no frames to encode")
+	}
+
+	outFile, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outFile.Close()
+
+	if err := c.WriteGIF(outFile); err != nil {
+		return err
+	}
+
+	return outFile.Close()
+}
+
+// WriteGIF writes the clip to w as an animated GIF
+func (c *Clip) WriteGIF(w io.Writer) error {
+	if len(c.Frames) == 0 {
+		return fmt.Errorf("no frames to encode")
+	}
+
+	anim := &gif.GIF{
+		Image:     c.Frames,
+		Delay:     c.Delays,
+		LoopCount: c.LoopCount,
+	}
+
+	if err := gif.EncodeAll(w, anim); err != nil {
+		return fmt.Errorf("failed to encode GIF: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,286 @@
+package editor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+var testColors = []color.RGBA{
+	{R: 255, A: 255},
+	{G: 255, A: 255},
+	{B: 255, A: 255},
+}
+
+// Helper function to write a GIF with one solid frame per color
+func writeTestGIF(t *testing.T, path string, delays []int) {
+	t.Helper()
+
+	g := &gif.GIF{}
+	for i, c := range testColors {
+		img := image.NewPaletted(image.Rect(0, 0, 4, 4), palette.Plan9)
+		idx := uint8(color.Palette(palette.Plan9).Index(c))
+		for j := range img.Pix {
+			img.Pix[j] = idx
+		}
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, delays[i])
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create test GIF: %v", err)
+	}
+	defer f.Close()
+
+	if err := gif.EncodeAll(f, g); err != nil {
+		t.Fatalf("Failed to encode test GIF: %v", err)
+	}
+}
+
+// Helper function to read the color of the top-left pixel of each frame
+func frameColors(c *Clip) []color.RGBA {
+	colors := make([]color.RGBA, len(c.Frames))
+	for i, f := range c.Frames {
+		colors[i] = color.RGBAModel.Convert(f.At(0, 0)).(color.RGBA)
+	}
+	return colors
+}
+
+func TestLoadGIF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.gif")
+	writeTestGIF(t, path, []int{10, 20, 30})
+
+	clip, err := LoadGIF(path)
+	if err != nil {
+		t.Fatalf("LoadGIF() failed: %v", err)
+	}
+
+	if clip.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", clip.Len())
+	}
+	for i, c := range frameColors(clip) {
+		if c != testColors[i] {
+			t.Errorf("frame %d color = %v, want %v", i, c, testColors[i])
+		}
+	}
+}
+
+func TestLoadGIFMissingFile(t *testing.T) {
+	if _, err := LoadGIF(filepath.Join(t.TempDir(), "missing.gif")); err == nil {
+		t.Error("LoadGIF() should fail for a missing file")
+	}
+}
+
+func TestClipReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.gif")
+	writeTestGIF(t, path, []int{10, 20, 30})
+
+	clip, err := LoadGIF(path)
+	if err != nil {
+		t.Fatalf("LoadGIF() failed: %v", err)
+	}
+
+	r := clip.Reader()
+	for i, offset := range []time.Duration{0, 100 * time.Millisecond, 300 * time.Millisecond} {
+		frame, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame() %d failed: %v", i, err)
+		}
+		if got := frame.Timestamp.Sub(capture.Epoch); got != offset {
+			t.Errorf("frame %d offset = %v, want %v", i, got, offset)
+		}
+		if got := frame.Image.RGBAAt(0, 0); got != testColors[i] {
+			t.Errorf("frame %d color = %v, want %v", i, got, testColors[i])
+		}
+	}
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("ReadFrame() after the last frame = %v, want io.EOF", err)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.gif")
+	out := filepath.Join(dir, "out.gif")
+	writeTestGIF(t, in, []int{10, 20, 30})
+
+	clip, err := LoadGIF(in)
+	if err != nil {
+		t.Fatalf("LoadGIF() failed: %v", err)
+	}
+	clip.Reverse()
+
+	if err := clip.SaveGIF(out); err != nil {
+		t.Fatalf("SaveGIF() failed: %v", err)
+	}
+
+	reversed, err := LoadGIF(out)
+	if err != nil {
+		t.Fatalf("LoadGIF() failed: %v", err)
+	}
+
+	wantDelays := []int{30, 20, 10}
+	for i, c := range frameColors(reversed) {
+		want := testColors[len(testColors)-1-i]
+		if c != want {
+			t.Errorf("frame %d color = %v, want %v", i, c, want)
+		}
+		if reversed.Delays[i] != wantDelays[i] {
+			t.Errorf("frame %d delay = %d, want %d", i, reversed.Delays[i], wantDelays[i])
+		}
+	}
+}
+
+func TestTrim(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantColors []color.RGBA
+		wantErr    bool
+	}{
+		{"1:2", testColors[1:], false},
+		{"1:1", testColors[1:2], false},
+		{":1", testColors[:2], false},
+		{"2:", testColors[2:], false},
+		{":", testColors, false},
+		{"2:1", nil, true},
+		{"0:3", nil, true},
+		{"-1:1", nil, true},
+		{"1-2", nil, true},
+		{"a:2", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "in.gif")
+			writeTestGIF(t, path, []int{10, 20, 30})
+			clip, err := LoadGIF(path)
+			if err != nil {
+				t.Fatalf("LoadGIF() failed: %v", err)
+			}
+
+			first, last, err := ParseTrim(tt.spec, clip.Len())
+			if err == nil {
+				err = clip.Trim(first, last)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("trim %q error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := frameColors(clip); !reflect.DeepEqual(got, tt.wantColors) {
+				t.Errorf("frames = %v, want %v", got, tt.wantColors)
+			}
+			if len(clip.Delays) != len(clip.Frames) {
+				t.Errorf("%d delays for %d frames", len(clip.Delays), len(clip.Frames))
+			}
+		})
+	}
+}
+
+func TestLoadGIFCompositesPartialFrames(t *testing.T) {
+	// Second frame only updates the top-left pixel of a red canvas
+	red := image.NewPaletted(image.Rect(0, 0, 4, 4), palette.Plan9)
+	redIdx := uint8(color.Palette(palette.Plan9).Index(testColors[0]))
+	for i := range red.Pix {
+		red.Pix[i] = redIdx
+	}
+	patch := image.NewPaletted(image.Rect(0, 0, 1, 1), palette.Plan9)
+	patch.Pix[0] = uint8(color.Palette(palette.Plan9).Index(testColors[2]))
+
+	clip := clipFromGIF(&gif.GIF{
+		Image:  []*image.Paletted{red, patch},
+		Delay:  []int{10, 10},
+		Config: image.Config{Width: 4, Height: 4},
+	})
+
+	second := clip.Frames[1]
+	if second.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("frame bounds = %v, want full canvas", second.Bounds())
+	}
+	if got := color.RGBAModel.Convert(second.At(0, 0)); got != testColors[2] {
+		t.Errorf("patched pixel = %v, want %v", got, testColors[2])
+	}
+	if got := color.RGBAModel.Convert(second.At(3, 3)); got != testColors[0] {
+		t.Errorf("background pixel = %v, want %v", got, testColors[0])
+	}
+
+	// Reversing keeps the composited content
+	clip.Reverse()
+	if got := color.RGBAModel.Convert(clip.Frames[0].At(3, 3)); got != testColors[0] {
+		t.Errorf("reversed background pixel = %v, want %v", got, testColors[0])
+	}
+}
+
+func TestSaveGIFNoFrames(t *testing.T) {
+	clip := &Clip{}
+	if err := clip.SaveGIF(filepath.Join(t.TempDir(), "empty.gif")); err == nil {
+		t.Error("SaveGIF() should fail with no frames")
+	}
+}
+
+func TestAutoCrop(t *testing.T) {
+	// A 2x2 window that changes color, surrounded by a static red border
+	redIdx := uint8(color.Palette(palette.Plan9).Index(testColors[0]))
+	clip := &Clip{Delays: []int{10, 10}}
+	for _, c := range testColors[1:] {
+		img := image.NewPaletted(image.Rect(0, 0, 6, 5), palette.Plan9)
+		for i := range img.Pix {
+			img.Pix[i] = redIdx
+		}
+		idx := uint8(color.Palette(palette.Plan9).Index(c))
+		for y := 1; y < 3; y++ {
+			for x := 2; x < 4; x++ {
+				img.SetColorIndex(x, y, idx)
+			}
+		}
+		clip.Frames = append(clip.Frames, img)
+	}
+
+	if got, want := clip.AutoCrop(), image.Rect(2, 1, 4, 3); got != want {
+		t.Errorf("AutoCrop() = %v, want %v", got, want)
+	}
+
+	for i, c := range frameColors(clip) {
+		if clip.Frames[i].Bounds() != image.Rect(0, 0, 2, 2) {
+			t.Errorf("frame %d bounds = %v, want 2x2 at origin", i, clip.Frames[i].Bounds())
+		}
+		if c != testColors[i+1] {
+			t.Errorf("frame %d color = %v, want %v", i, c, testColors[i+1])
+		}
+	}
+}
+
+func TestWriteGIF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.gif")
+	writeTestGIF(t, path, []int{10, 20, 30})
+
+	clip, err := LoadGIF(path)
+	if err != nil {
+		t.Fatalf("LoadGIF() failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := clip.WriteGIF(&buf); err != nil {
+		t.Fatalf("WriteGIF() failed: %v", err)
+	}
+
+	decoded, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode GIF: %v", err)
+	}
+	if len(decoded.Image) != 3 || decoded.Delay[2] != 30 {
+		t.Errorf("decoded %d frames with delays %v, want 3 frames ending in 30", len(decoded.Image), decoded.Delay)
+	}
+}
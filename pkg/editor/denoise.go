@@ -0,0 +1,107 @@
+package editor
+
+import (
+	"image"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// Denoise suppresses flicker between live frames before passing them on.
+// Pixels that change by no more than the tolerance keep their previous
+// value, as do isolated pixels that change for a single frame, such as
+// antialiasing shimmer. Static areas then stay identical from frame to
+// frame, which looks cleaner and compresses far better.
+type Denoise struct {
+	next      recorder.FrameSink
+	tolerance uint8
+	prevIn    *image.RGBA // Last frame as received
+	prevOut   *image.RGBA // Last frame as forwarded
+}
+
+// NewDenoise creates a filter that forwards denoised frames to next.
+// tolerance is the largest per-channel change treated as noise.
+func NewDenoise(next recorder.FrameSink, tolerance uint8) *Denoise {
+	return &Denoise{
+		next:      next,
+		tolerance: tolerance,
+	}
+}
+
+// AddFrame denoises the frame in place and forwards it
+func (d *Denoise) AddFrame(frame *capture.Frame) error {
+	if frame != nil && frame.Image != nil {
+		d.filter(frame)
+	}
+
+	return d.next.AddFrame(frame)
+}
+
+// filter replaces noisy pixels in frame with the previous output. The first
+// frame, and frames after a size change or capture gap, pass through.
+func (d *Denoise) filter(frame *capture.Frame) {
+	in := packedCopy(frame.Image)
+	if d.prevOut == nil || frame.Discontinuity || in.Bounds() != d.prevOut.Bounds() {
+		d.prevIn = in
+		d.prevOut = packedCopy(frame.Image)
+		return
+	}
+
+	w, h := in.Bounds().Dx(), in.Bounds().Dy()
+	changed := make([]bool, w*h)
+	for i := range changed {
+		changed[i] = d.differs(in.Pix[4*i:], d.prevOut.Pix[4*i:])
+	}
+
+	out := d.prevOut
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if changed[i] && !(isolated(changed, w, h, x, y) && d.differs(in.Pix[4*i:], d.prevIn.Pix[4*i:])) {
+				copy(out.Pix[4*i:4*i+4], in.Pix[4*i:4*i+4])
+			}
+		}
+	}
+
+	// Write the result back in the frame's own coordinates
+	b := frame.Image.Bounds()
+	for y := 0; y < h; y++ {
+		copy(frame.Image.Pix[frame.Image.PixOffset(b.Min.X, b.Min.Y+y):], out.Pix[4*w*y:4*w*(y+1)])
+	}
+
+	d.prevIn = in
+}
+
+// differs reports whether two RGBA pixels differ by more than the tolerance
+// in any channel
+func (d *Denoise) differs(a, b []uint8) bool {
+	for c := 0; c < 4; c++ {
+		diff := int(a[c]) - int(b[c])
+		if diff > int(d.tolerance) || -diff > int(d.tolerance) {
+			return true
+		}
+	}
+	return false
+}
+
+// isolated reports whether none of a pixel's eight neighbors changed
+func isolated(changed []bool, w, h, x, y int) bool {
+	for ny := max(y-1, 0); ny <= min(y+1, h-1); ny++ {
+		for nx := max(x-1, 0); nx <= min(x+1, w-1); nx++ {
+			if (nx != x || ny != y) && changed[ny*w+nx] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// packedCopy returns a tightly packed copy of img with its origin at (0, 0)
+func packedCopy(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		copy(out.Pix[y*out.Stride:(y+1)*out.Stride], img.Pix[img.PixOffset(b.Min.X, b.Min.Y+y):])
+	}
+	return out
+}
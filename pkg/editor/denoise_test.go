@@ -0,0 +1,153 @@
+package editor
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestDenoise(t *testing.T) {
+	gray := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	tests := []struct {
+		name   string
+		frames []func(img *image.RGBA)
+		x, y   int
+		want   []color.RGBA // Pixel (x, y) in each forwarded frame
+	}{
+		{
+			name: "small change suppressed",
+			frames: []func(img *image.RGBA){
+				nil,
+				func(img *image.RGBA) { img.SetRGBA(3, 3, color.RGBA{R: 104, G: 97, B: 100, A: 255}) },
+			},
+			x: 3, y: 3,
+			want: []color.RGBA{gray, gray},
+		},
+		{
+			name: "single frame flicker suppressed",
+			frames: []func(img *image.RGBA){
+				nil,
+				func(img *image.RGBA) { img.SetRGBA(3, 3, white) },
+				nil,
+			},
+			x: 3, y: 3,
+			want: []color.RGBA{gray, gray, gray},
+		},
+		{
+			name: "lasting single pixel change kept",
+			frames: []func(img *image.RGBA){
+				nil,
+				func(img *image.RGBA) { img.SetRGBA(3, 3, white) },
+				func(img *image.RGBA) { img.SetRGBA(3, 3, white) },
+			},
+			x: 3, y: 3,
+			want: []color.RGBA{gray, gray, white},
+		},
+		{
+			name: "changed area kept",
+			frames: []func(img *image.RGBA){
+				nil,
+				func(img *image.RGBA) {
+					img.SetRGBA(3, 3, white)
+					img.SetRGBA(4, 3, white)
+				},
+			},
+			x: 3, y: 3,
+			want: []color.RGBA{gray, white},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			denoise := NewDenoise(sink, 8)
+
+			for _, draw := range tt.frames {
+				img := solidRGBA(8, 8, gray)
+				if draw != nil {
+					draw(img)
+				}
+				if err := denoise.AddFrame(&capture.Frame{Image: img, Timestamp: time.Now()}); err != nil {
+					t.Fatalf("AddFrame() failed: %v", err)
+				}
+			}
+
+			for i, f := range sink.frames {
+				if got := f.Image.RGBAAt(tt.x, tt.y); got != tt.want[i] {
+					t.Errorf("frame %d pixel = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDenoiseResets(t *testing.T) {
+	gray := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	shifted := color.RGBA{R: 104, G: 104, B: 104, A: 255}
+
+	tests := []struct {
+		name  string
+		frame *capture.Frame
+	}{
+		{name: "discontinuity", frame: &capture.Frame{Image: solidRGBA(8, 8, shifted), Discontinuity: true}},
+		{name: "size change", frame: &capture.Frame{Image: solidRGBA(6, 6, shifted)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			denoise := NewDenoise(sink, 8)
+			denoise.AddFrame(&capture.Frame{Image: solidRGBA(8, 8, gray)})
+			denoise.AddFrame(tt.frame)
+
+			if got := sink.frames[1].Image.RGBAAt(0, 0); got != shifted {
+				t.Errorf("pixel = %v, want %v passed through", got, shifted)
+			}
+		})
+	}
+}
+
+func TestDenoiseSubImage(t *testing.T) {
+	gray := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	sink := &recordingSink{}
+	denoise := NewDenoise(sink, 8)
+
+	for i := 0; i < 2; i++ {
+		img := solidRGBA(10, 10, gray)
+		if i == 1 {
+			img.SetRGBA(5, 5, color.RGBA{R: 103, G: 100, B: 100, A: 255})
+		}
+		sub := img.SubImage(image.Rect(2, 2, 8, 8)).(*image.RGBA)
+		denoise.AddFrame(&capture.Frame{Image: sub})
+	}
+
+	if got := sink.frames[1].Image.RGBAAt(5, 5); got != gray {
+		t.Errorf("pixel = %v, want %v", got, gray)
+	}
+}
+
+func TestDenoiseNilFrame(t *testing.T) {
+	sink := &recordingSink{}
+	if err := NewDenoise(sink, 8).AddFrame(nil); err != nil {
+		t.Fatalf("AddFrame(nil) failed: %v", err)
+	}
+	if len(sink.frames) != 1 {
+		t.Errorf("forwarded %d frames, want 1", len(sink.frames))
+	}
+}
+
+// Helper function to create a solid RGBA image
+func solidRGBA(width, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
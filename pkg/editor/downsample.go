@@ -0,0 +1,105 @@
+package editor
+
+import (
+	"image"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// Downsample reduces live frames to a lower frame rate. Of the frames
+// captured during each output frame's interval, the sharpest is kept, so
+// fast scrolling and typing land on crisp frames rather than mid-motion
+// blur. Call Flush after the last frame to deliver the final interval.
+type Downsample struct {
+	next recorder.FrameSink
+	fps  capture.FPS
+
+	start         time.Time      // Timestamp of the first frame
+	slot          int            // Output frame the held frame belongs to
+	best          *capture.Frame // Sharpest frame of the current slot
+	bestScore     float64
+	discontinuity bool // Whether any frame in the slot followed a gap
+}
+
+// NewDownsample creates a filter that forwards frames to next at fps
+func NewDownsample(next recorder.FrameSink, fps capture.FPS) *Downsample {
+	return &Downsample{
+		next: next,
+		fps:  fps,
+	}
+}
+
+// AddFrame holds the frame if it is the sharpest of its output interval so
+// far, forwarding the previous interval's pick once a new one begins
+func (d *Downsample) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil {
+		return d.next.AddFrame(frame)
+	}
+
+	if d.start.IsZero() {
+		d.start = frame.Timestamp
+	}
+	slot := d.fps.FramesIn(frame.Timestamp.Sub(d.start))
+	if d.best != nil && slot != d.slot {
+		if err := d.Flush(); err != nil {
+			return err
+		}
+	}
+	d.slot = slot
+
+	d.discontinuity = d.discontinuity || frame.Discontinuity
+	if score := sharpness(frame.Image); d.best == nil || score > d.bestScore {
+		d.best = frame
+		d.bestScore = score
+	}
+	return nil
+}
+
+// Flush forwards the frame held for the current interval, if any. It is
+// timestamped at the start of its interval, so output frames stay evenly
+// spaced whichever frame of each interval was kept.
+func (d *Downsample) Flush() error {
+	if d.best == nil {
+		return nil
+	}
+
+	frame := *d.best
+	frame.Timestamp = d.start.Add(d.fps.FrameTime(d.slot))
+	frame.Discontinuity = d.discontinuity
+	d.best = nil
+	d.discontinuity = false
+
+	return d.next.AddFrame(&frame)
+}
+
+// sharpness scores an image by its mean squared luma difference between
+// neighboring pixels. Motion blur smears edges and lowers the score.
+func sharpness(img *image.RGBA) float64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w < 2 || h < 2 {
+		return 0
+	}
+
+	luma := make([]int, w*h)
+	for y := 0; y < h; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, b.Min.Y+y):]
+		for x := 0; x < w; x++ {
+			p := row[4*x:]
+			luma[y*w+x] = (299*int(p[0]) + 587*int(p[1]) + 114*int(p[2])) / 1000
+		}
+	}
+
+	var sum float64
+	for y := 0; y < h-1; y++ {
+		for x := 0; x < w-1; x++ {
+			i := y*w + x
+			dx := luma[i+1] - luma[i]
+			dy := luma[i+w] - luma[i]
+			sum += float64(dx*dx + dy*dy)
+		}
+	}
+	return sum / float64((w-1)*(h-1))
+}
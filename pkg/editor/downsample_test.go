@@ -0,0 +1,105 @@
+package editor
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestDownsample(t *testing.T) {
+	sink := &recordingSink{}
+	downsample := NewDownsample(sink, capture.IntFPS(15))
+
+	// Four frames per output frame at 60fps. The second of each group has
+	// a hard edge; the others are blurred versions of it.
+	start := time.Now()
+	for i := 0; i < 12; i++ {
+		img := blurredEdge(16, 8, 4)
+		if i%4 == 1 {
+			img = blurredEdge(16, 8, 0)
+		}
+		img.Pix[0] = uint8(i) // Tag the frame
+
+		frame := &capture.Frame{Image: img, Timestamp: start.Add(time.Duration(i) * 16667 * time.Microsecond)}
+		if err := downsample.AddFrame(frame); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	if len(sink.frames) != 2 {
+		t.Fatalf("forwarded %d frames before Flush, want 2", len(sink.frames))
+	}
+	if err := downsample.Flush(); err != nil {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	if len(sink.frames) != 3 {
+		t.Fatalf("forwarded %d frames, want 3", len(sink.frames))
+	}
+	for i, f := range sink.frames {
+		if got, want := f.Image.Pix[0], uint8(4*i+1); got != want {
+			t.Errorf("output frame %d is capture frame %d, want sharp frame %d", i, got, want)
+		}
+		if want := start.Add(capture.IntFPS(15).FrameTime(i)); !f.Timestamp.Equal(want) {
+			t.Errorf("output frame %d at %v, want the start of its interval", i, f.Timestamp.Sub(start))
+		}
+	}
+
+	// Flushing again has nothing left to send
+	downsample.Flush()
+	if len(sink.frames) != 3 {
+		t.Errorf("second Flush() forwarded a frame")
+	}
+}
+
+func TestDownsampleDiscontinuity(t *testing.T) {
+	sink := &recordingSink{}
+	downsample := NewDownsample(sink, capture.IntFPS(10))
+
+	start := time.Now()
+	downsample.AddFrame(&capture.Frame{Image: blurredEdge(8, 8, 0), Timestamp: start})
+	downsample.AddFrame(&capture.Frame{Image: blurredEdge(8, 8, 4), Timestamp: start.Add(20 * time.Millisecond), Discontinuity: true})
+	downsample.Flush()
+
+	if len(sink.frames) != 1 {
+		t.Fatalf("forwarded %d frames, want 1", len(sink.frames))
+	}
+	if !sink.frames[0].Discontinuity {
+		t.Error("discontinuity within the interval was lost")
+	}
+}
+
+func TestSharpness(t *testing.T) {
+	sharp := sharpness(blurredEdge(16, 8, 0))
+	soft := sharpness(blurredEdge(16, 8, 4))
+	flat := sharpness(solidRGBA(16, 8, color.RGBA{R: 80, G: 80, B: 80, A: 255}))
+
+	if !(sharp > soft && soft > flat) {
+		t.Errorf("sharpness() sharp %v, soft %v, flat %v; want decreasing", sharp, soft, flat)
+	}
+	if flat != 0 {
+		t.Errorf("sharpness() of a flat image = %v, want 0", flat)
+	}
+}
+
+// Helper function to create a black-to-white vertical edge ramped over
+// blur pixels
+func blurredEdge(width, height, blur int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	mid := width / 2
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var v uint8
+			switch {
+			case x >= mid+blur/2:
+				v = 255
+			case x >= mid-blur/2 && blur > 0:
+				v = uint8(255 * (x - (mid - blur/2)) / blur)
+			}
+			img.SetRGBA(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
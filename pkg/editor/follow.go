@@ -0,0 +1,136 @@
+package editor
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// Follow crops frames showing area to a smaller view that follows a moving
+// point, such as the mouse pointer. The view eases toward the point and
+// ignores small movements around its center, so the output pans steadily
+// instead of jittering with every twitch of the hand. The target and area
+// are in global points, like clicks.
+type Follow struct {
+	// Smoothing is how much of the distance to the target the view still
+	// has to cover after each frame: 0 tracks the target exactly, and
+	// values toward 1 glide more slowly and steadily
+	Smoothing float64
+
+	// DeadZone is the fraction of the view's size, around its center,
+	// within which the target moves without moving the view
+	DeadZone float64
+
+	next   recorder.FrameSink
+	target func() (image.Point, error)
+	area   capture.Region
+	size   image.Point // View size in points
+
+	started bool
+	x, y    float64 // View center in global points
+}
+
+// NewFollow creates a filter that crops frames showing area to a view of
+// size points following target, and forwards them to next
+func NewFollow(next recorder.FrameSink, target func() (image.Point, error), area capture.Region, size image.Point) *Follow {
+	return &Follow{
+		Smoothing: 0.85,
+		DeadZone:  0.2,
+		next:      next,
+		target:    target,
+		area:      area,
+		size:      size,
+	}
+}
+
+// AddFrame moves the view toward the target, crops the frame to it, and
+// forwards the crop
+func (f *Follow) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil || f.area.Width <= 0 || f.area.Height <= 0 {
+		return f.next.AddFrame(frame)
+	}
+
+	f.move(frame.Discontinuity)
+
+	cropped := *frame
+	cropped.Image = crop(frame.Image, f.view(frame.Image.Bounds()))
+	return f.next.AddFrame(&cropped)
+}
+
+// move eases the view center toward the target. The view jumps straight to
+// the target on the first frame and after an interruption, and stays put
+// when the target cannot be read.
+func (f *Follow) move(jump bool) {
+	p, err := f.target()
+	if err != nil {
+		if !f.started {
+			f.x = float64(f.area.X) + float64(f.area.Width)/2
+			f.y = float64(f.area.Y) + float64(f.area.Height)/2
+			f.started = true
+		}
+		return
+	}
+
+	if !f.started || jump {
+		f.x, f.y = float64(p.X), float64(p.Y)
+		f.started = true
+	} else {
+		f.x = f.ease(f.x, float64(p.X), float64(f.size.X))
+		f.y = f.ease(f.y, float64(p.Y), float64(f.size.Y))
+	}
+
+	// Keep the view inside the area so it never pans past an edge
+	f.x = clampCenter(f.x, float64(f.area.X), float64(f.area.Width), float64(f.size.X))
+	f.y = clampCenter(f.y, float64(f.area.Y), float64(f.area.Height), float64(f.size.Y))
+}
+
+// ease moves center along one axis toward the nearest position that puts
+// target inside the dead zone of a view extent long
+func (f *Follow) ease(center, target, extent float64) float64 {
+	slack := extent * f.DeadZone / 2
+	var goal float64
+	switch {
+	case target > center+slack:
+		goal = target - slack
+	case target < center-slack:
+		goal = target + slack
+	default:
+		return center
+	}
+	return goal + (center-goal)*math.Min(math.Max(f.Smoothing, 0), 1)
+}
+
+// clampCenter limits a view center so a view extent long stays within the
+// span of length starting at origin
+func clampCenter(center, origin, length, extent float64) float64 {
+	if extent >= length {
+		return origin + length/2
+	}
+	return math.Min(math.Max(center, origin+extent/2), origin+length-extent/2)
+}
+
+// view converts the view to pixels within frames of bounds, keeping its
+// size fixed so every output frame has the same dimensions
+func (f *Follow) view(bounds image.Rectangle) image.Rectangle {
+	scaleX := float64(bounds.Dx()) / float64(f.area.Width)
+	scaleY := float64(bounds.Dy()) / float64(f.area.Height)
+	w := min(int(float64(f.size.X)*scaleX+0.5), bounds.Dx())
+	h := min(int(float64(f.size.Y)*scaleY+0.5), bounds.Dy())
+
+	x := bounds.Min.X + int(math.Round((f.x-float64(f.area.X))*scaleX-float64(w)/2))
+	y := bounds.Min.Y + int(math.Round((f.y-float64(f.area.Y))*scaleY-float64(h)/2))
+	x = min(max(x, bounds.Min.X), bounds.Max.X-w)
+	y = min(max(y, bounds.Min.Y), bounds.Max.Y-h)
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// crop copies r out of img into a new image whose bounds start at the
+// origin, leaving img untouched for any other sinks holding it
+func crop(img *image.RGBA, r image.Rectangle) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(out, out.Rect, img, r.Min, draw.Src)
+	return out
+}
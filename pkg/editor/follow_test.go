@@ -0,0 +1,175 @@
+package editor
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Helper function to create a target that reports each point in turn
+func pointerPath(points ...image.Point) func() (image.Point, error) {
+	i := 0
+	return func() (image.Point, error) {
+		p := points[min(i, len(points)-1)]
+		i++
+		return p, nil
+	}
+}
+
+// Helper function to create a frame whose pixels encode their own position,
+// so a crop's origin can be read back from its first pixel
+func positionFrame(w, h int) *capture.Frame {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	return &capture.Frame{Image: img}
+}
+
+// Helper function to read the origin of a crop made from a positionFrame
+func cropOrigin(frame *capture.Frame) image.Point {
+	p := frame.Image.RGBAAt(0, 0)
+	return image.Pt(int(p.R), int(p.G))
+}
+
+func TestFollowCrops(t *testing.T) {
+	// Frames show a 100x100 point area at 2x
+	area := capture.Region{X: 1000, Y: 500, Width: 100, Height: 100}
+
+	tests := []struct {
+		name    string
+		pointer image.Point
+		want    image.Point
+	}{
+		{"centered on the pointer", image.Pt(1050, 550), image.Pt(60, 70)},
+		{"clamped at the top left", image.Pt(1000, 500), image.Pt(0, 0)},
+		{"clamped at the bottom right", image.Pt(1100, 600), image.Pt(120, 140)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			follow := NewFollow(sink, pointerPath(tt.pointer), area, image.Pt(40, 30))
+			if err := follow.AddFrame(positionFrame(200, 200)); err != nil {
+				t.Fatalf("AddFrame() failed: %v", err)
+			}
+
+			got := sink.frames[0]
+			if b := got.Image.Bounds(); b != image.Rect(0, 0, 80, 60) {
+				t.Errorf("bounds = %v, want 80x60 at the origin", b)
+			}
+			if origin := cropOrigin(got); origin != tt.want {
+				t.Errorf("crop origin = %v, want %v", origin, tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowSmoothsJitter(t *testing.T) {
+	area := capture.Region{Width: 200, Height: 200}
+	jitter := []image.Point{{100, 100}}
+	for i := 0; i < 20; i++ {
+		jitter = append(jitter, image.Pt(100+6*(i%2*2-1), 100))
+	}
+
+	tests := []struct {
+		name      string
+		smoothing float64
+		deadZone  float64
+		minMove   int
+		maxMove   int
+	}{
+		{"unsmoothed view follows every twitch", 0, 0, 12, 12},
+		{"dead zone ignores the twitch", 0, 0.2, 0, 0},
+		{"smoothing damps the twitch", 0.85, 0, 1, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			follow := NewFollow(sink, pointerPath(jitter...), area, image.Pt(100, 100))
+			follow.Smoothing = tt.smoothing
+			follow.DeadZone = tt.deadZone
+			for range jitter {
+				if err := follow.AddFrame(positionFrame(200, 200)); err != nil {
+					t.Fatalf("AddFrame() failed: %v", err)
+				}
+			}
+
+			// Only the later frames count, once smoothing has settled
+			largest := 0
+			for i := len(sink.frames) - 10; i < len(sink.frames); i++ {
+				move := cropOrigin(sink.frames[i]).X - cropOrigin(sink.frames[i-1]).X
+				largest = max(largest, int(math.Abs(float64(move))))
+			}
+			if largest < tt.minMove || largest > tt.maxMove {
+				t.Errorf("largest move between frames = %d pixels, want %d-%d", largest, tt.minMove, tt.maxMove)
+			}
+		})
+	}
+}
+
+func TestFollowEasesTowardTarget(t *testing.T) {
+	area := capture.Region{Width: 200, Height: 200}
+	sink := &recordingSink{}
+	follow := NewFollow(sink, pointerPath(image.Pt(50, 100), image.Pt(150, 100)), area, image.Pt(100, 100))
+	follow.DeadZone = 0
+
+	previous := -1
+	for i := 0; i < 60; i++ {
+		if err := follow.AddFrame(positionFrame(200, 200)); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+		x := cropOrigin(sink.frames[i]).X
+		if x < previous {
+			t.Fatalf("frame %d: view moved back to x=%d from %d", i, x, previous)
+		}
+		previous = x
+	}
+
+	if x := cropOrigin(sink.frames[1]).X; x >= 100 {
+		t.Errorf("second frame x = %d, want the view to ease rather than jump", x)
+	}
+	if previous != 100 {
+		t.Errorf("final x = %d, want the view to settle on the target at 100", previous)
+	}
+}
+
+func TestFollowJumpsAfterDiscontinuity(t *testing.T) {
+	area := capture.Region{Width: 200, Height: 200}
+	sink := &recordingSink{}
+	follow := NewFollow(sink, pointerPath(image.Pt(50, 50), image.Pt(150, 150)), area, image.Pt(100, 100))
+
+	follow.AddFrame(positionFrame(200, 200))
+	frame := positionFrame(200, 200)
+	frame.Discontinuity = true
+	follow.AddFrame(frame)
+
+	if got := cropOrigin(sink.frames[1]); got != image.Pt(100, 100) {
+		t.Errorf("crop origin after a gap = %v, want the view to jump to (100,100)", got)
+	}
+	if !sink.frames[1].Discontinuity {
+		t.Error("Discontinuity was not forwarded")
+	}
+}
+
+func TestFollowWithoutTarget(t *testing.T) {
+	area := capture.Region{Width: 200, Height: 200}
+	sink := &recordingSink{}
+	follow := NewFollow(sink, func() (image.Point, error) {
+		return image.Point{}, errors.New("no pointer")
+	}, area, image.Pt(100, 100))
+
+	if err := follow.AddFrame(positionFrame(200, 200)); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if got := cropOrigin(sink.frames[0]); got != image.Pt(50, 50) {
+		t.Errorf("crop origin = %v, want the center of the area (50,50)", got)
+	}
+}
@@ -0,0 +1,71 @@
+package editor
+
+import (
+	"image"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// IdleSkip drops live frames while the screen sits unchanged, so a pause in
+// the recording plays back for at most maxIdle. Any visible change, such as
+// a blinking cursor, counts as activity. The first frame after dropped ones
+// is marked as a discontinuity, so encoders timing frames by their capture
+// timestamps do not show the skipped stretch after all.
+type IdleSkip struct {
+	next    recorder.FrameSink
+	maxIdle time.Duration
+	last    *image.RGBA // Frame at the last change
+	since   time.Time   // Timestamp of the last change
+	skipped bool        // Whether frames were dropped since the last forwarded one
+}
+
+// NewIdleSkip creates a filter that forwards frames to next until the
+// screen has been idle for maxIdle
+func NewIdleSkip(next recorder.FrameSink, maxIdle time.Duration) *IdleSkip {
+	return &IdleSkip{
+		next:    next,
+		maxIdle: maxIdle,
+	}
+}
+
+// AddFrame forwards the frame unless the screen has been idle too long
+func (s *IdleSkip) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil {
+		return s.next.AddFrame(frame)
+	}
+
+	if s.last == nil || frame.Discontinuity || !sameImage(frame.Image, s.last) {
+		s.last = packedCopy(frame.Image)
+		s.since = frame.Timestamp
+	} else if frame.Timestamp.Sub(s.since) > s.maxIdle {
+		s.skipped = true
+		return nil
+	}
+
+	if s.skipped {
+		frame.Discontinuity = true
+		s.skipped = false
+	}
+	return s.next.AddFrame(frame)
+}
+
+// sameImage reports whether every pixel of img is within the default
+// tolerance of ref, a packed image of the same size
+func sameImage(img, ref *image.RGBA) bool {
+	b := img.Bounds()
+	if b.Dx() != ref.Bounds().Dx() || b.Dy() != ref.Bounds().Dy() {
+		return false
+	}
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if !analyze.Similar(img.RGBAAt(b.Min.X+x, b.Min.Y+y), ref.RGBAAt(x, y), analyze.DefaultTolerance) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package editor
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestIdleSkip(t *testing.T) {
+	gray := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	sink := &recordingSink{}
+	skip := NewIdleSkip(sink, time.Second)
+
+	// Ten frames a second: idle from 0s, a change at 3s, idle again after
+	start := time.Now()
+	var forwarded []int
+	for i := 0; i < 50; i++ {
+		img := solidRGBA(8, 8, gray)
+		if i >= 30 {
+			img.SetRGBA(2, 2, white)
+		}
+		if i%10 == 5 {
+			img.SetRGBA(4, 4, color.RGBA{R: 103, G: 98, B: 100, A: 255}) // Noise
+		}
+
+		before := len(sink.frames)
+		frame := &capture.Frame{Image: img, Timestamp: start.Add(time.Duration(i) * 100 * time.Millisecond)}
+		if err := skip.AddFrame(frame); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+		if len(sink.frames) > before {
+			forwarded = append(forwarded, i)
+		}
+	}
+
+	// Frames 0-10 and 30-40 cover a second of idle time each
+	if len(forwarded) != 22 || forwarded[10] != 10 || forwarded[11] != 30 || forwarded[21] != 40 {
+		t.Errorf("forwarded frames %v, want 0-10 and 30-40", forwarded)
+	}
+
+	// Only the frame after the skipped stretch follows a gap
+	for i, f := range sink.frames {
+		if want := i == 11; f.Discontinuity != want {
+			t.Errorf("forwarded frame %d Discontinuity = %v, want %v", i, f.Discontinuity, want)
+		}
+	}
+}
+
+func TestIdleSkipDiscontinuity(t *testing.T) {
+	gray := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	sink := &recordingSink{}
+	skip := NewIdleSkip(sink, time.Second)
+
+	start := time.Now()
+	skip.AddFrame(&capture.Frame{Image: solidRGBA(8, 8, gray), Timestamp: start})
+	skip.AddFrame(&capture.Frame{Image: solidRGBA(8, 8, gray), Timestamp: start.Add(5 * time.Second), Discontinuity: true})
+	skip.AddFrame(&capture.Frame{Image: solidRGBA(8, 8, gray), Timestamp: start.Add(5500 * time.Millisecond)})
+
+	if len(sink.frames) != 3 {
+		t.Errorf("forwarded %d frames, want 3", len(sink.frames))
+	}
+}
+
+func TestIdleSkipNilFrame(t *testing.T) {
+	sink := &recordingSink{}
+	if err := NewIdleSkip(sink, time.Second).AddFrame(nil); err != nil {
+		t.Fatalf("AddFrame(nil) failed: %v", err)
+	}
+	if len(sink.frames) != 1 {
+		t.Errorf("forwarded %d frames, want 1", len(sink.frames))
+	}
+}
@@ -0,0 +1,43 @@
+package editor
+
+import (
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// Overlay draws annotations onto live frames before passing them on, so
+// callouts can be burned in while recording. Annotation times are measured
+// from the timestamp of the first frame.
+type Overlay struct {
+	next        recorder.FrameSink
+	annotations []Annotation
+	start       time.Time
+}
+
+// NewOverlay creates an overlay that forwards annotated frames to next
+func NewOverlay(next recorder.FrameSink, annotations []Annotation) *Overlay {
+	return &Overlay{
+		next:        next,
+		annotations: annotations,
+	}
+}
+
+// AddFrame draws the active annotations onto the frame and forwards it
+func (o *Overlay) AddFrame(frame *capture.Frame) error {
+	if frame != nil && frame.Image != nil {
+		if o.start.IsZero() {
+			o.start = frame.Timestamp
+		}
+		at := frame.Timestamp.Sub(o.start).Milliseconds()
+
+		for _, a := range o.annotations {
+			if a.Active(at) {
+				a.Draw(frame.Image)
+			}
+		}
+	}
+
+	return o.next.AddFrame(frame)
+}
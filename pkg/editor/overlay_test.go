@@ -0,0 +1,46 @@
+package editor
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// recordingSink stores frames it receives
+type recordingSink struct {
+	frames []*capture.Frame
+}
+
+func (s *recordingSink) AddFrame(frame *capture.Frame) error {
+	s.frames = append(s.frames, frame)
+	return nil
+}
+
+func TestOverlay(t *testing.T) {
+	sink := &recordingSink{}
+	overlay := NewOverlay(sink, []Annotation{
+		{Type: AnnotationBox, X: 0, Y: 0, Width: 8, Height: 8, Thickness: 1, StartMS: 500},
+	})
+
+	start := time.Now()
+	for _, offset := range []time.Duration{0, 250 * time.Millisecond, 600 * time.Millisecond} {
+		frame := &capture.Frame{
+			Image:     image.NewRGBA(image.Rect(0, 0, 8, 8)),
+			Timestamp: start.Add(offset),
+		}
+		if err := overlay.AddFrame(frame); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+
+	red := color.RGBA{R: 255, A: 255}
+	for i, f := range sink.frames {
+		drawn := f.Image.RGBAAt(0, 0) == red
+		if drawn != (i == 2) {
+			t.Errorf("frame %d annotated = %v, want %v", i, drawn, i == 2)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package editor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// Corner is a corner of the frame
+type Corner int
+
+const (
+	// CornerBottomRight is the bottom-right corner
+	CornerBottomRight Corner = iota
+	// CornerBottomLeft is the bottom-left corner
+	CornerBottomLeft
+	// CornerTopRight is the top-right corner
+	CornerTopRight
+	// CornerTopLeft is the top-left corner
+	CornerTopLeft
+)
+
+// ParseCorner parses a corner name such as bottom-right or br
+func ParseCorner(s string) (Corner, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "bottom-right", "br", "":
+		return CornerBottomRight, nil
+	case "bottom-left", "bl":
+		return CornerBottomLeft, nil
+	case "top-right", "tr":
+		return CornerTopRight, nil
+	case "top-left", "tl":
+		return CornerTopLeft, nil
+	default:
+		return 0, fmt.Errorf("invalid corner %q (must be bottom-right, bottom-left, top-right, or top-left)", s)
+	}
+}
+
+// String returns the corner's name
+func (c Corner) String() string {
+	switch c {
+	case CornerBottomRight:
+		return "bottom-right"
+	case CornerBottomLeft:
+		return "bottom-left"
+	case CornerTopRight:
+		return "top-right"
+	case CornerTopLeft:
+		return "top-left"
+	default:
+		return fmt.Sprintf("Corner(%d)", int(c))
+	}
+}
+
+// PictureInPicture overlays the latest webcam frame in a corner of each
+// screen frame, for talking-head demos. Camera frames arrive at their own
+// rate; each screen frame shows whichever arrived last.
+type PictureInPicture struct {
+	// Corner places the webcam image
+	Corner Corner
+
+	// Size is the webcam image's width as a fraction of the frame's width
+	Size float64
+
+	// Margin is the gap between the webcam image and the frame's edges,
+	// in pixels
+	Margin int
+
+	// Border is the width of the frame drawn around the webcam image, in
+	// pixels; 0 draws none
+	Border int
+
+	// BorderColor is the color of the border
+	BorderColor color.RGBA
+
+	next   recorder.FrameSink
+	camera <-chan *capture.Frame
+	latest *image.RGBA
+	scaled *image.RGBA // latest resized for the last frame size
+}
+
+// NewPictureInPicture creates a filter that draws frames from camera onto
+// screen frames and forwards them to next
+func NewPictureInPicture(next recorder.FrameSink, camera <-chan *capture.Frame, corner Corner) *PictureInPicture {
+	return &PictureInPicture{
+		Corner:      corner,
+		Size:        0.25,
+		Margin:      16,
+		Border:      2,
+		BorderColor: namedColors["white"],
+		next:        next,
+		camera:      camera,
+	}
+}
+
+// AddFrame draws the latest camera frame onto the frame and forwards it
+func (p *PictureInPicture) AddFrame(frame *capture.Frame) error {
+	p.receive()
+	if frame != nil && frame.Image != nil && p.latest != nil {
+		p.draw(frame.Image)
+	}
+
+	return p.next.AddFrame(frame)
+}
+
+// receive keeps the newest camera frame that has arrived, without waiting
+func (p *PictureInPicture) receive() {
+	for {
+		select {
+		case cam, ok := <-p.camera:
+			if !ok {
+				return
+			}
+			if cam != nil && cam.Image != nil {
+				p.latest = cam.Image
+				p.scaled = nil
+			}
+		default:
+			return
+		}
+	}
+}
+
+// placement returns where the webcam image goes in a frame with the given
+// bounds, keeping the camera's aspect ratio. The rectangle is empty when
+// the frame is too small to fit it.
+func (p *PictureInPicture) placement(bounds image.Rectangle, camera image.Point) image.Rectangle {
+	if camera.X <= 0 || camera.Y <= 0 {
+		return image.Rectangle{}
+	}
+
+	inset := p.Margin + p.Border
+	width := int(math.Round(float64(bounds.Dx()) * p.Size))
+	width = min(width, bounds.Dx()-2*inset)
+	height := int(math.Round(float64(width) * float64(camera.Y) / float64(camera.X)))
+	if height > bounds.Dy()-2*inset {
+		height = bounds.Dy() - 2*inset
+		width = int(math.Round(float64(height) * float64(camera.X) / float64(camera.Y)))
+	}
+	if width <= 0 || height <= 0 {
+		return image.Rectangle{}
+	}
+
+	x := bounds.Max.X - inset - width
+	if p.Corner == CornerBottomLeft || p.Corner == CornerTopLeft {
+		x = bounds.Min.X + inset
+	}
+	y := bounds.Max.Y - inset - height
+	if p.Corner == CornerTopRight || p.Corner == CornerTopLeft {
+		y = bounds.Min.Y + inset
+	}
+	return image.Rect(x, y, x+width, y+height)
+}
+
+// draw paints the latest camera frame and its border onto img
+func (p *PictureInPicture) draw(img *image.RGBA) {
+	r := p.placement(img.Bounds(), p.latest.Bounds().Size())
+	if r.Empty() {
+		return
+	}
+
+	if p.scaled == nil || p.scaled.Bounds().Size() != r.Size() {
+		p.scaled = ScaleImage(p.latest, r.Dx(), r.Dy(), ScaleSmooth)
+	}
+
+	if p.Border > 0 {
+		fillRect(img, r.Inset(-p.Border), p.BorderColor)
+	}
+	draw.Draw(img, r, p.scaled, image.Point{}, draw.Src)
+}
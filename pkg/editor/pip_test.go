@@ -0,0 +1,142 @@
+package editor
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Helper function to create a camera frame filled with one color
+func cameraFrame(w, h int, c color.RGBA) *capture.Frame {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return &capture.Frame{Image: img}
+}
+
+func TestParseCorner(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Corner
+		wantErr bool
+	}{
+		{"bottom-right", CornerBottomRight, false},
+		{"", CornerBottomRight, false},
+		{"BL", CornerBottomLeft, false},
+		{"top-right", CornerTopRight, false},
+		{"tl", CornerTopLeft, false},
+		{"middle", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCorner(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCorner(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCorner(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCornerStringRoundTrip(t *testing.T) {
+	for _, c := range []Corner{CornerBottomRight, CornerBottomLeft, CornerTopRight, CornerTopLeft} {
+		if got, err := ParseCorner(c.String()); err != nil || got != c {
+			t.Errorf("ParseCorner(%q) = %v, %v, want %v", c.String(), got, err, c)
+		}
+	}
+}
+
+func TestPictureInPicturePlacement(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 300)
+	camera := image.Pt(320, 240)
+
+	tests := []struct {
+		corner Corner
+		size   float64
+		want   image.Rectangle
+	}{
+		{CornerBottomRight, 0.25, image.Rect(282, 207, 382, 282)},
+		{CornerBottomLeft, 0.25, image.Rect(18, 207, 118, 282)},
+		{CornerTopRight, 0.25, image.Rect(282, 18, 382, 93)},
+		{CornerTopLeft, 0.25, image.Rect(18, 18, 118, 93)},
+		// Too tall for the frame, so height limits the size
+		{CornerTopLeft, 1, image.Rect(18, 18, 370, 282)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.corner.String(), func(t *testing.T) {
+			p := NewPictureInPicture(&recordingSink{}, nil, tt.corner)
+			p.Size = tt.size
+			if got := p.placement(bounds, camera); got != tt.want {
+				t.Errorf("placement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	p := NewPictureInPicture(&recordingSink{}, nil, CornerBottomRight)
+	if got := p.placement(image.Rect(0, 0, 30, 30), camera); !got.Empty() {
+		t.Errorf("placement() in a tiny frame = %v, want empty", got)
+	}
+}
+
+func TestPictureInPicture(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	white := namedColors["white"]
+
+	camera := make(chan *capture.Frame, 2)
+	sink := &recordingSink{}
+	pip := NewPictureInPicture(sink, camera, CornerBottomRight)
+
+	// No camera frame yet: the screen passes through untouched
+	frame := &capture.Frame{Image: image.NewRGBA(image.Rect(0, 0, 400, 300))}
+	if err := pip.AddFrame(frame); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if got := frame.Image.RGBAAt(330, 240); got != (color.RGBA{}) {
+		t.Errorf("pixel before the camera starts = %v, want untouched", got)
+	}
+
+	// Only the newest camera frame is shown
+	camera <- cameraFrame(32, 24, red)
+	camera <- cameraFrame(32, 24, blue)
+	frame = &capture.Frame{Image: image.NewRGBA(image.Rect(0, 0, 400, 300))}
+	if err := pip.AddFrame(frame); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+
+	checks := []struct {
+		at   image.Point
+		want color.RGBA
+	}{
+		{image.Pt(330, 240), blue},         // Inside the webcam image
+		{image.Pt(281, 240), white},        // Border
+		{image.Pt(278, 240), color.RGBA{}}, // Outside
+		{image.Pt(10, 10), color.RGBA{}},
+	}
+	for _, c := range checks {
+		if got := frame.Image.RGBAAt(c.at.X, c.at.Y); got != c.want {
+			t.Errorf("pixel %v = %v, want %v", c.at, got, c.want)
+		}
+	}
+
+	// The last camera frame stays up when the camera stops
+	close(camera)
+	frame = &capture.Frame{Image: image.NewRGBA(image.Rect(0, 0, 400, 300))}
+	if err := pip.AddFrame(frame); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if got := frame.Image.RGBAAt(330, 240); got != blue {
+		t.Errorf("pixel after the camera stops = %v, want %v", got, blue)
+	}
+
+	if len(sink.frames) != 3 {
+		t.Errorf("forwarded %d frames, want 3", len(sink.frames))
+	}
+}
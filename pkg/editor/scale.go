@@ -0,0 +1,280 @@
+package editor
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// ScaleMode selects the resampling filter used to resize frames
+type ScaleMode int
+
+const (
+	// ScaleSmooth uses bilinear interpolation, which suits photos and video
+	ScaleSmooth ScaleMode = iota
+
+	// ScaleText averages the source pixels each output pixel covers and
+	// then sharpens, keeping terminal and code text legible when shrinking
+	ScaleText
+
+	// ScaleNearest copies the nearest source pixel. It is only allowed at
+	// whole-number ratios, where it is exact: halving a Retina capture
+	// recovers the on-screen pixels.
+	ScaleNearest
+)
+
+// ParseScaleMode converts a string to a scale mode
+func ParseScaleMode(s string) (ScaleMode, error) {
+	switch strings.ToLower(s) {
+	case "smooth", "bilinear":
+		return ScaleSmooth, nil
+	case "text", "sharp":
+		return ScaleText, nil
+	case "nearest":
+		return ScaleNearest, nil
+	default:
+		return 0, fmt.Errorf("invalid scale mode: %s (must be smooth, text, or nearest)", s)
+	}
+}
+
+// String returns the mode's name
+func (m ScaleMode) String() string {
+	switch m {
+	case ScaleSmooth:
+		return "smooth"
+	case ScaleText:
+		return "text"
+	case ScaleNearest:
+		return "nearest"
+	default:
+		return fmt.Sprintf("ScaleMode(%d)", int(m))
+	}
+}
+
+// Scale describes how frames are resized
+type Scale struct {
+	// Factor multiplies both dimensions, e.g. 0.5 for half size
+	Factor float64
+
+	// Mode selects the resampling filter
+	Mode ScaleMode
+}
+
+// Validate checks that the factor is usable with the mode
+func (s Scale) Validate() error {
+	if s.Factor <= 0 || math.IsInf(s.Factor, 0) || math.IsNaN(s.Factor) {
+		return fmt.Errorf("scale factor must be positive, got %g", s.Factor)
+	}
+	if s.Mode == ScaleNearest && !wholeRatio(s.Factor) {
+		return fmt.Errorf("nearest scaling needs a whole-number ratio such as 0.5 or 2, got %g", s.Factor)
+	}
+	return nil
+}
+
+// wholeRatio reports whether f or 1/f is a whole number
+func wholeRatio(f float64) bool {
+	whole := func(x float64) bool { return math.Abs(x-math.Round(x)) < 1e-9 }
+	return whole(f) || whole(1/f)
+}
+
+// Size returns the scaled size of a width x height frame, at least 1x1
+func (s Scale) Size(width, height int) (int, int) {
+	return max(int(math.Round(float64(width)*s.Factor)), 1),
+		max(int(math.Round(float64(height)*s.Factor)), 1)
+}
+
+// Scaler resizes live frames before passing them on
+type Scaler struct {
+	next  recorder.FrameSink
+	scale Scale
+}
+
+// NewScaler creates a scaler that forwards resized frames to next
+func NewScaler(next recorder.FrameSink, scale Scale) *Scaler {
+	return &Scaler{
+		next:  next,
+		scale: scale,
+	}
+}
+
+// AddFrame resizes the frame and forwards it
+func (s *Scaler) AddFrame(frame *capture.Frame) error {
+	if frame != nil && frame.Image != nil {
+		b := frame.Image.Bounds()
+		width, height := s.scale.Size(b.Dx(), b.Dy())
+		scaled := *frame
+		scaled.Image = ScaleImage(frame.Image, width, height, s.scale.Mode)
+		frame = &scaled
+	}
+
+	return s.next.AddFrame(frame)
+}
+
+// ScaleImage resizes img to width x height
+func ScaleImage(img *image.RGBA, width, height int, mode ScaleMode) *image.RGBA {
+	src := packedCopy(img)
+	if src.Bounds().Dx() == width && src.Bounds().Dy() == height {
+		return src
+	}
+
+	switch mode {
+	case ScaleNearest:
+		return scaleNearest(src, width, height)
+	case ScaleText:
+		out := scaleArea(src, width, height)
+		if width < src.Bounds().Dx() || height < src.Bounds().Dy() {
+			out = sharpen(out, 0.5)
+		}
+		return out
+	default:
+		return scaleBilinear(src, width, height)
+	}
+}
+
+// scaleNearest resizes by copying the source pixel under each output pixel
+func scaleNearest(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := y * sh / height
+		for x := 0; x < width; x++ {
+			sx := x * sw / width
+			copy(out.Pix[out.PixOffset(x, y):out.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):])
+		}
+	}
+	return out
+}
+
+// scaleBilinear resizes by interpolating between the four nearest pixels
+func scaleBilinear(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		fy := math.Max((float64(y)+0.5)*float64(sh)/float64(height)-0.5, 0)
+		y0 := min(int(fy), sh-1)
+		y1 := min(y0+1, sh-1)
+		wy := fy - float64(y0)
+
+		for x := 0; x < width; x++ {
+			fx := math.Max((float64(x)+0.5)*float64(sw)/float64(width)-0.5, 0)
+			x0 := min(int(fx), sw-1)
+			x1 := min(x0+1, sw-1)
+			wx := fx - float64(x0)
+
+			i := out.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				top := float64(src.Pix[src.PixOffset(x0, y0)+c])*(1-wx) + float64(src.Pix[src.PixOffset(x1, y0)+c])*wx
+				bottom := float64(src.Pix[src.PixOffset(x0, y1)+c])*(1-wx) + float64(src.Pix[src.PixOffset(x1, y1)+c])*wx
+				out.Pix[i+c] = clamp(top*(1-wy) + bottom*wy)
+			}
+		}
+	}
+	return out
+}
+
+// tap is one source pixel's share of an output pixel
+type tap struct {
+	index  int
+	weight float64
+}
+
+// areaTaps returns, for each of n output pixels, the source pixels it
+// covers when src pixels are resized to n and how much of each it covers
+func areaTaps(src, n int) [][]tap {
+	taps := make([][]tap, n)
+	ratio := float64(src) / float64(n)
+	for i := range taps {
+		start, end := float64(i)*ratio, float64(i+1)*ratio
+		for j := int(start); j < src && float64(j) < end; j++ {
+			w := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if w > 0 {
+				taps[i] = append(taps[i], tap{index: j, weight: w / ratio})
+			}
+		}
+	}
+	return taps
+}
+
+// scaleArea resizes by averaging the source pixels each output pixel covers
+func scaleArea(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	xTaps, yTaps := areaTaps(sw, width), areaTaps(sh, height)
+
+	// Resize horizontally into a float buffer, then vertically
+	rows := make([]float64, 4*width*sh)
+	for y := 0; y < sh; y++ {
+		for x, taps := range xTaps {
+			for _, t := range taps {
+				p := src.PixOffset(t.index, y)
+				for c := 0; c < 4; c++ {
+					rows[4*(y*width+x)+c] += float64(src.Pix[p+c]) * t.weight
+				}
+			}
+		}
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, taps := range yTaps {
+		for x := 0; x < width; x++ {
+			var sum [4]float64
+			for _, t := range taps {
+				for c := 0; c < 4; c++ {
+					sum[c] += rows[4*(t.index*width+x)+c] * t.weight
+				}
+			}
+			i := out.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				out.Pix[i+c] = clamp(sum[c])
+			}
+		}
+	}
+	return out
+}
+
+// sharpen applies an unsharp mask, adding back amount times the difference
+// between each pixel and the average of its 3x3 neighborhood. Alpha is left
+// unchanged.
+func sharpen(img *image.RGBA, amount float64) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	out := packedCopy(img)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [3]float64
+			n := 0.0
+			for ny := max(y-1, 0); ny <= min(y+1, h-1); ny++ {
+				for nx := max(x-1, 0); nx <= min(x+1, w-1); nx++ {
+					p := img.PixOffset(nx, ny)
+					for c := 0; c < 3; c++ {
+						sum[c] += float64(img.Pix[p+c])
+					}
+					n++
+				}
+			}
+
+			i := img.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				v := float64(img.Pix[i+c])
+				out.Pix[i+c] = clamp(v + amount*(v-sum[c]/n))
+			}
+		}
+	}
+	return out
+}
+
+// clamp rounds v to the nearest byte value
+func clamp(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	default:
+		return uint8(v + 0.5)
+	}
+}
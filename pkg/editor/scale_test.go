@@ -0,0 +1,202 @@
+package editor
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestParseScaleMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ScaleMode
+		wantErr bool
+	}{
+		{input: "smooth", want: ScaleSmooth},
+		{input: "bilinear", want: ScaleSmooth},
+		{input: "text", want: ScaleText},
+		{input: "Sharp", want: ScaleText},
+		{input: "nearest", want: ScaleNearest},
+		{input: "bicubic", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseScaleMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScaleMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseScaleMode(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScaleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		scale   Scale
+		wantErr bool
+	}{
+		{name: "half smooth", scale: Scale{Factor: 0.5, Mode: ScaleSmooth}},
+		{name: "odd ratio text", scale: Scale{Factor: 0.6, Mode: ScaleText}},
+		{name: "half nearest", scale: Scale{Factor: 0.5, Mode: ScaleNearest}},
+		{name: "third nearest", scale: Scale{Factor: 1.0 / 3, Mode: ScaleNearest}},
+		{name: "double nearest", scale: Scale{Factor: 2, Mode: ScaleNearest}},
+		{name: "odd ratio nearest", scale: Scale{Factor: 0.75, Mode: ScaleNearest}, wantErr: true},
+		{name: "zero", scale: Scale{Factor: 0}, wantErr: true},
+		{name: "negative", scale: Scale{Factor: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.scale.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScaleSize(t *testing.T) {
+	tests := []struct {
+		factor        float64
+		width, height int
+		wantW, wantH  int
+	}{
+		{factor: 0.5, width: 2880, height: 1800, wantW: 1440, wantH: 900},
+		{factor: 0.5, width: 101, height: 51, wantW: 51, wantH: 26},
+		{factor: 2, width: 10, height: 5, wantW: 20, wantH: 10},
+		{factor: 0.01, width: 10, height: 10, wantW: 1, wantH: 1},
+	}
+
+	for _, tt := range tests {
+		w, h := Scale{Factor: tt.factor}.Size(tt.width, tt.height)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("Size(%d, %d) at %g = %dx%d, want %dx%d", tt.width, tt.height, tt.factor, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestScaleImageNearest(t *testing.T) {
+	// A 2x2 checkerboard doubled should become 2x2 blocks
+	black := color.RGBA{A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	img := solidRGBA(2, 2, white)
+	img.SetRGBA(0, 0, black)
+	img.SetRGBA(1, 1, black)
+
+	out := ScaleImage(img, 4, 4, ScaleNearest)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := white
+			if (x < 2) == (y < 2) {
+				want = black
+			}
+			if got := out.RGBAAt(x, y); got != want {
+				t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	// Halving again recovers the original
+	back := ScaleImage(out, 2, 2, ScaleNearest)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			if back.RGBAAt(x, y) != img.RGBAAt(x, y) {
+				t.Errorf("round trip pixel (%d, %d) = %v, want %v", x, y, back.RGBAAt(x, y), img.RGBAAt(x, y))
+			}
+		}
+	}
+}
+
+func TestScaleImageTextKeepsThinLines(t *testing.T) {
+	// A one-pixel vertical stroke, like the stem of a glyph
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	img := solidRGBA(9, 9, white)
+	for y := 0; y < 9; y++ {
+		img.SetRGBA(0, y, color.RGBA{A: 255})
+	}
+
+	// Bilinear sampling at a third skips the stroke entirely
+	smooth := ScaleImage(img, 3, 3, ScaleSmooth)
+	if got := smooth.RGBAAt(0, 1).R; got != 255 {
+		t.Errorf("smooth stroke = %d, want 255 (stroke skipped)", got)
+	}
+
+	text := ScaleImage(img, 3, 3, ScaleText)
+	if got := text.RGBAAt(0, 1).R; got >= 170 {
+		t.Errorf("text stroke = %d, want darker than the plain average 170", got)
+	}
+	if got := text.RGBAAt(2, 1); got != white {
+		t.Errorf("text background = %v, want %v", got, white)
+	}
+}
+
+func TestScaleImageTextSharpens(t *testing.T) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	img := solidRGBA(12, 12, white)
+	for y := 0; y < 12; y++ {
+		img.SetRGBA(4, y, color.RGBA{A: 255})
+	}
+
+	smooth := ScaleImage(img, 6, 6, ScaleSmooth).RGBAAt(2, 3).R
+	text := ScaleImage(img, 6, 6, ScaleText).RGBAAt(2, 3).R
+	if text >= smooth {
+		t.Errorf("text stroke = %d, want darker than smooth stroke %d", text, smooth)
+	}
+}
+
+func TestScaleImageSolid(t *testing.T) {
+	gray := color.RGBA{R: 90, G: 120, B: 150, A: 255}
+	img := solidRGBA(10, 7, gray)
+
+	for _, mode := range []ScaleMode{ScaleSmooth, ScaleText, ScaleNearest} {
+		t.Run(mode.String(), func(t *testing.T) {
+			out := ScaleImage(img, 5, 3, mode)
+			if out.Bounds() != image.Rect(0, 0, 5, 3) {
+				t.Fatalf("bounds = %v, want 5x3", out.Bounds())
+			}
+			for y := 0; y < 3; y++ {
+				for x := 0; x < 5; x++ {
+					if got := out.RGBAAt(x, y); got != gray {
+						t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, gray)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestScaler(t *testing.T) {
+	sink := &recordingSink{}
+	scaler := NewScaler(sink, Scale{Factor: 0.5, Mode: ScaleText})
+
+	img := solidRGBA(20, 20, color.RGBA{R: 10, A: 255})
+	sub := img.SubImage(image.Rect(4, 4, 16, 12)).(*image.RGBA)
+	stamp := time.Now()
+	if err := scaler.AddFrame(&capture.Frame{Image: sub, Timestamp: stamp, Discontinuity: true}); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if err := scaler.AddFrame(nil); err != nil {
+		t.Fatalf("AddFrame(nil) failed: %v", err)
+	}
+
+	if len(sink.frames) != 2 {
+		t.Fatalf("forwarded %d frames, want 2", len(sink.frames))
+	}
+	got := sink.frames[0]
+	if got.Image.Bounds() != image.Rect(0, 0, 6, 4) {
+		t.Errorf("bounds = %v, want 6x4", got.Image.Bounds())
+	}
+	if !got.Timestamp.Equal(stamp) || !got.Discontinuity {
+		t.Errorf("frame metadata not preserved: %+v", got)
+	}
+	if sub.Bounds() != image.Rect(4, 4, 16, 12) {
+		t.Errorf("source frame modified: bounds = %v", sub.Bounds())
+	}
+}
@@ -0,0 +1,122 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseAnnotation parses a command-line annotation spec of the form
+//
+//	type:x,y,...[,key=value...]
+//
+// Arrows take x,y,x2,y2; boxes, ellipses and blur patches take x,y,w,h;
+// text takes x,y and a text= option, which must come last and may contain
+// commas. Options are color, background, thickness, scale, radius and
+// at=START-END, where START and END are durations such as 1s or 2.5s and
+// END may be omitted to last until the end.
+//
+// Examples:
+//
+//	box:10,10,200,80,color=yellow
+//	arrow:40,200,180,120,at=1s-3s
+//	blur:0,0,300,40
+//	text:20,20,at=0s-2s,text=Click Save
+func ParseAnnotation(spec string) (Annotation, error) {
+	kind, rest, ok := strings.Cut(spec, ":")
+	if !ok {
+		return Annotation{}, fmt.Errorf("invalid annotation %q: expected type:args", spec)
+	}
+
+	a := Annotation{Type: strings.TrimSpace(kind)}
+
+	// text= consumes the rest of the spec so captions can contain commas
+	if i := strings.Index(rest, "text="); i >= 0 {
+		a.Text = rest[i+len("text="):]
+		rest = strings.TrimSuffix(rest[:i], ",")
+	}
+
+	var nums []int
+	for _, field := range strings.Split(rest, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		key, value, isOption := strings.Cut(field, "=")
+		if !isOption {
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				return Annotation{}, fmt.Errorf("invalid annotation %q: bad coordinate %q", spec, field)
+			}
+			nums = append(nums, n)
+			continue
+		}
+
+		if err := a.setOption(key, value); err != nil {
+			return Annotation{}, fmt.Errorf("invalid annotation %q: %w", spec, err)
+		}
+	}
+
+	want := 4
+	if a.Type == AnnotationText {
+		want = 2
+	}
+	if len(nums) != want {
+		return Annotation{}, fmt.Errorf("invalid annotation %q: %s needs %d coordinates, got %d",
+			spec, a.Type, want, len(nums))
+	}
+
+	a.X, a.Y = nums[0], nums[1]
+	switch a.Type {
+	case AnnotationArrow:
+		a.X2, a.Y2 = nums[2], nums[3]
+	case AnnotationBox, AnnotationEllipse, AnnotationBlur:
+		a.Width, a.Height = nums[2], nums[3]
+	}
+
+	if err := a.Validate(); err != nil {
+		return Annotation{}, fmt.Errorf("invalid annotation %q: %w", spec, err)
+	}
+
+	return a, nil
+}
+
+// setOption applies a key=value option from an annotation spec
+func (a *Annotation) setOption(key, value string) error {
+	var err error
+
+	switch strings.TrimSpace(key) {
+	case "color":
+		a.Color = value
+	case "background":
+		a.Background = value
+	case "thickness":
+		a.Thickness, err = strconv.Atoi(value)
+	case "scale":
+		a.Scale, err = strconv.Atoi(value)
+	case "radius":
+		a.Radius, err = strconv.Atoi(value)
+	case "at":
+		start, end, _ := strings.Cut(value, "-")
+		var d time.Duration
+		if d, err = time.ParseDuration(start); err != nil {
+			return fmt.Errorf("bad start time %q", start)
+		}
+		a.StartMS = d.Milliseconds()
+		if end != "" {
+			if d, err = time.ParseDuration(end); err != nil {
+				return fmt.Errorf("bad end time %q", end)
+			}
+			a.EndMS = d.Milliseconds()
+		}
+	default:
+		return fmt.Errorf("unknown option %q", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("bad %s value %q", key, value)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package editor
+
+import "testing"
+
+func TestParseAnnotation(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    Annotation
+		wantErr bool
+	}{
+		{
+			name: "box with color",
+			spec: "box:10,20,200,80,color=yellow",
+			want: Annotation{Type: AnnotationBox, X: 10, Y: 20, Width: 200, Height: 80, Color: "yellow"},
+		},
+		{
+			name: "arrow with time range",
+			spec: "arrow:40,200,180,120,at=1s-3.5s",
+			want: Annotation{Type: AnnotationArrow, X: 40, Y: 200, X2: 180, Y2: 120, StartMS: 1000, EndMS: 3500},
+		},
+		{
+			name: "open-ended time range",
+			spec: "ellipse:0,0,50,30,at=2s-,thickness=4",
+			want: Annotation{Type: AnnotationEllipse, Width: 50, Height: 30, StartMS: 2000, Thickness: 4},
+		},
+		{
+			name: "blur with radius",
+			spec: "blur:0,0,300,40,radius=12",
+			want: Annotation{Type: AnnotationBlur, Width: 300, Height: 40, Radius: 12},
+		},
+		{
+			name: "text with commas",
+			spec: "text:20,20,scale=3,text=Click Save, then wait",
+			want: Annotation{Type: AnnotationText, X: 20, Y: 20, Scale: 3, Text: "Click Save, then wait"},
+		},
+		{name: "missing type separator", spec: "box10,10,10,10", wantErr: true},
+		{name: "too few coordinates", spec: "box:10,10,10", wantErr: true},
+		{name: "bad coordinate", spec: "box:a,10,10,10", wantErr: true},
+		{name: "unknown option", spec: "box:0,0,10,10,size=3", wantErr: true},
+		{name: "bad time", spec: "box:0,0,10,10,at=soon", wantErr: true},
+		{name: "bad thickness", spec: "box:0,0,10,10,thickness=thick", wantErr: true},
+		{name: "unknown type", spec: "star:0,0,10,10", wantErr: true},
+		{name: "text without text", spec: "text:0,0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAnnotation(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAnnotation(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseAnnotation(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package editor
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+)
+
+// Timeline is a JSON sidecar describing a clip's frames and the annotations
+// to render over them. It is exported with `witness edit -timeline`, edited
+// by hand, and applied with `witness render`.
+type Timeline struct {
+	// Source is the recording the timeline was exported from
+	Source string `json:"source,omitempty"`
+
+	// Width and Height are the frame dimensions in pixels
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Frames lists every frame with its presentation time
+	Frames []TimelineFrame `json:"frames"`
+
+	// Annotations are drawn over the frames whose time they cover
+	Annotations []Annotation `json:"annotations"`
+}
+
+// TimelineFrame describes a single frame in a timeline
+type TimelineFrame struct {
+	Index   int   `json:"index"`
+	TimeMS  int64 `json:"time_ms"`
+	DelayMS int64 `json:"delay_ms"`
+}
+
+// Timeline builds a timeline for the clip with no annotations
+func (c *Clip) Timeline(source string) *Timeline {
+	t := &Timeline{
+		Source:      source,
+		Frames:      make([]TimelineFrame, len(c.Frames)),
+		Annotations: []Annotation{},
+	}
+	if len(c.Frames) > 0 {
+		t.Width = c.Frames[0].Bounds().Dx()
+		t.Height = c.Frames[0].Bounds().Dy()
+	}
+
+	for i, at := range c.frameTimes() {
+		t.Frames[i] = TimelineFrame{
+			Index:   i,
+			TimeMS:  at,
+			DelayMS: int64(c.Delays[i]) * 10,
+		}
+	}
+
+	return t
+}
+
+// frameTimes returns the start time of each frame in milliseconds
+func (c *Clip) frameTimes() []int64 {
+	times := make([]int64, len(c.Frames))
+	var at int64
+	for i := range c.Frames {
+		times[i] = at
+		if i < len(c.Delays) {
+			at += int64(c.Delays[i]) * 10
+		}
+	}
+	return times
+}
+
+// LoadTimeline reads a timeline sidecar file
+func LoadTimeline(path string) (*Timeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timeline: %w", err)
+	}
+
+	var t Timeline
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse timeline: %w", err)
+	}
+
+	for i, a := range t.Annotations {
+		if err := a.Validate(); err != nil {
+			return nil, fmt.Errorf("annotation %d: %w", i, err)
+		}
+	}
+
+	return &t, nil
+}
+
+// Save writes the timeline as indented JSON
+func (t *Timeline) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal timeline: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write timeline: %w", err)
+	}
+
+	return nil
+}
+
+// Annotate draws the annotations over every frame whose start time they
+// cover. Annotation colors are added to each frame's palette when there is
+// room, so they are not approximated by the nearest recorded color.
+func (c *Clip) Annotate(annotations []Annotation) {
+	if len(annotations) == 0 {
+		return
+	}
+
+	for i, at := range c.frameTimes() {
+		var active []Annotation
+		for _, a := range annotations {
+			if a.Active(at) {
+				active = append(active, a)
+			}
+		}
+		if len(active) == 0 {
+			continue
+		}
+
+		frame := c.Frames[i]
+		bounds := frame.Bounds()
+
+		rgba := image.NewRGBA(bounds)
+		draw.Draw(rgba, bounds, frame, bounds.Min, draw.Src)
+		for _, a := range active {
+			a.Draw(rgba)
+		}
+
+		out := image.NewPaletted(bounds, withAnnotationColors(frame.Palette, active))
+		draw.Draw(out, bounds, rgba, bounds.Min, draw.Src)
+		c.Frames[i] = out
+	}
+}
+
+// withAnnotationColors returns p extended with the opaque annotation colors
+// it lacks, up to the 256 color GIF limit
+func withAnnotationColors(p color.Palette, annotations []Annotation) color.Palette {
+	out := make(color.Palette, len(p), 256)
+	copy(out, p)
+
+	for _, a := range annotations {
+		for _, s := range []string{a.colorOrDefault(), a.Background} {
+			if s == "" || len(out) >= 256 {
+				continue
+			}
+			c, err := ParseColor(s)
+			if err != nil || c.A != 0xff {
+				continue
+			}
+			if !paletteHas(out, c) {
+				out = append(out, c)
+			}
+		}
+	}
+
+	return out
+}
+
+// paletteHas reports whether p contains exactly c
+func paletteHas(p color.Palette, c color.Color) bool {
+	r, g, b, a := c.RGBA()
+	for _, pc := range p {
+		pr, pg, pb, pa := pc.RGBA()
+		if pr == r && pg == g && pb == b && pa == a {
+			return true
+		}
+	}
+	return false
+}
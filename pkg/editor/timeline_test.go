@@ -0,0 +1,102 @@
+package editor
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClipTimeline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.gif")
+	writeTestGIF(t, path, []int{10, 20, 30})
+
+	clip, err := LoadGIF(path)
+	if err != nil {
+		t.Fatalf("LoadGIF() failed: %v", err)
+	}
+
+	tl := clip.Timeline(path)
+	if tl.Source != path || tl.Width != 4 || tl.Height != 4 {
+		t.Errorf("Timeline() header = %q %dx%d", tl.Source, tl.Width, tl.Height)
+	}
+
+	wantTimes := []int64{0, 100, 300}
+	for i, f := range tl.Frames {
+		if f.Index != i || f.TimeMS != wantTimes[i] {
+			t.Errorf("frame %d = %+v, want time %d", i, f, wantTimes[i])
+		}
+	}
+	if tl.Frames[2].DelayMS != 300 {
+		t.Errorf("frame 2 delay = %d, want 300", tl.Frames[2].DelayMS)
+	}
+}
+
+func TestTimelineSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timeline.json")
+
+	tl := &Timeline{
+		Width:  4,
+		Height: 4,
+		Frames: []TimelineFrame{{Index: 0, TimeMS: 0, DelayMS: 100}},
+		Annotations: []Annotation{
+			{Type: AnnotationText, Text: "Click", StartMS: 0, EndMS: 500},
+		},
+	}
+	if err := tl.Save(path); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	loaded, err := LoadTimeline(path)
+	if err != nil {
+		t.Fatalf("LoadTimeline() failed: %v", err)
+	}
+	if len(loaded.Annotations) != 1 || loaded.Annotations[0].Text != "Click" {
+		t.Errorf("LoadTimeline() annotations = %+v", loaded.Annotations)
+	}
+}
+
+func TestLoadTimelineRejectsInvalidAnnotations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timeline.json")
+	data := `{"width": 4, "height": 4, "frames": [], "annotations": [{"type": "box"}]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write timeline: %v", err)
+	}
+
+	if _, err := LoadTimeline(path); err == nil {
+		t.Error("LoadTimeline() should reject a box with no size")
+	}
+}
+
+func TestClipAnnotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.gif")
+	writeTestGIF(t, path, []int{10, 10, 10})
+
+	clip, err := LoadGIF(path)
+	if err != nil {
+		t.Fatalf("LoadGIF() failed: %v", err)
+	}
+
+	// Visible on the second frame only (frames start at 0, 100, 200ms)
+	magenta := "#ff00ff"
+	clip.Annotate([]Annotation{
+		{Type: AnnotationBox, X: 0, Y: 0, Width: 4, Height: 4, Thickness: 1, Color: magenta, StartMS: 100, EndMS: 200},
+	})
+
+	want := color.RGBA{R: 255, B: 255, A: 255}
+	for i, f := range clip.Frames {
+		got := color.RGBAModel.Convert(f.At(0, 0))
+		if (got == want) != (i == 1) {
+			t.Errorf("frame %d corner = %v", i, got)
+		}
+	}
+
+	// The annotation color was added to the palette rather than approximated
+	if !paletteHas(clip.Frames[1].Palette, want) {
+		t.Error("annotation color missing from frame palette")
+	}
+	if clip.Frames[1].Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Errorf("frame bounds = %v", clip.Frames[1].Bounds())
+	}
+}
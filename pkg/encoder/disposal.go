@@ -0,0 +1,99 @@
+package encoder
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"strings"
+)
+
+// Disposal selects what a GIF viewer does with a frame before drawing the
+// next one
+type Disposal int
+
+const (
+	// DisposalAuto leaves frames in place unless the next frame has
+	// transparent pixels, in which case the frame is cleared so it does not
+	// show through
+	DisposalAuto Disposal = iota
+	// DisposalNone leaves every frame in place for the next to draw over
+	DisposalNone
+	// DisposalBackground clears every frame to the background (transparent
+	// in browsers)
+	DisposalBackground
+	// DisposalPrevious restores the canvas to how it was before the frame
+	DisposalPrevious
+)
+
+// ParseDisposal parses a disposal method name (auto, none, background, or
+// previous)
+func ParseDisposal(s string) (Disposal, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "auto", "":
+		return DisposalAuto, nil
+	case "none":
+		return DisposalNone, nil
+	case "background":
+		return DisposalBackground, nil
+	case "previous":
+		return DisposalPrevious, nil
+	default:
+		return 0, fmt.Errorf("invalid disposal %q: want auto, none, background, or previous", s)
+	}
+}
+
+// method returns the GIF disposal method byte
+func (d Disposal) method() byte {
+	switch d {
+	case DisposalBackground:
+		return gif.DisposalBackground
+	case DisposalPrevious:
+		return gif.DisposalPrevious
+	default:
+		return gif.DisposalNone
+	}
+}
+
+// frameDisposals returns the disposal method for each frame. With
+// DisposalAuto, a frame is cleared only when the frame after it (wrapping
+// around for looping) has transparent pixels that would otherwise reveal it.
+func frameDisposals(frames []*image.Paletted, d Disposal) []byte {
+	out := make([]byte, len(frames))
+	if d != DisposalAuto {
+		for i := range out {
+			out[i] = d.method()
+		}
+		return out
+	}
+
+	for i := range frames {
+		out[i] = gif.DisposalNone
+		if hasTransparency(frames[(i+1)%len(frames)]) {
+			out[i] = gif.DisposalBackground
+		}
+	}
+	return out
+}
+
+// hasTransparency reports whether any pixel of p uses a transparent
+// palette entry
+func hasTransparency(p *image.Paletted) bool {
+	var clear [256]bool
+	found := false
+	for i, c := range p.Palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			clear[i] = true
+			found = true
+		}
+	}
+	if !found {
+		return false
+	}
+
+	for _, idx := range p.Pix {
+		if clear[idx] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package encoder
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestParseDisposal(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Disposal
+		wantErr bool
+	}{
+		{"auto", DisposalAuto, false},
+		{"", DisposalAuto, false},
+		{"none", DisposalNone, false},
+		{"Background", DisposalBackground, false},
+		{"previous", DisposalPrevious, false},
+		{"keep", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDisposal(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDisposal(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDisposal(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrameDisposals(t *testing.T) {
+	withClear := color.Palette{color.RGBA{A: 255}, color.RGBA{}}
+
+	opaque := image.NewPaletted(image.Rect(0, 0, 2, 2), withClear)
+	clear := image.NewPaletted(image.Rect(0, 0, 2, 2), withClear)
+	clear.SetColorIndex(1, 1, 1)
+
+	tests := []struct {
+		name     string
+		frames   []*image.Paletted
+		disposal Disposal
+		want     []byte
+	}{
+		{
+			name:     "auto without transparency",
+			frames:   []*image.Paletted{opaque, opaque},
+			disposal: DisposalAuto,
+			want:     []byte{gif.DisposalNone, gif.DisposalNone},
+		},
+		{
+			name:     "auto clears before transparent frames",
+			frames:   []*image.Paletted{opaque, clear, opaque},
+			disposal: DisposalAuto,
+			want:     []byte{gif.DisposalBackground, gif.DisposalNone, gif.DisposalNone},
+		},
+		{
+			name:     "auto wraps around for looping",
+			frames:   []*image.Paletted{clear, opaque},
+			disposal: DisposalAuto,
+			want:     []byte{gif.DisposalNone, gif.DisposalBackground},
+		},
+		{
+			name:     "override",
+			frames:   []*image.Paletted{opaque, clear},
+			disposal: DisposalPrevious,
+			want:     []byte{gif.DisposalPrevious, gif.DisposalPrevious},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := frameDisposals(tt.frames, tt.disposal)
+			if string(got) != string(tt.want) {
+				t.Errorf("frameDisposals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetDisposal(t *testing.T) {
+	encoder := NewGIFEncoder("", 10, QualityMedium)
+	encoder.SetDisposal(DisposalBackground)
+	encoder.AddFrame(createTestFrame(4, 4, color.White))
+	encoder.AddFrame(createTestFrame(4, 4, color.Black))
+
+	anim := encodeAndDecode(t, encoder)
+	for i, d := range anim.Disposal {
+		if d != gif.DisposalBackground {
+			t.Errorf("frame %d disposal = %d, want DisposalBackground", i, d)
+		}
+	}
+}
+
+// Helper function to encode buffered frames and decode the result
+func encodeAndDecode(t *testing.T, encoder *GIFEncoder) *gif.GIF {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encoder.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo() failed: %v", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode GIF: %v", err)
+	}
+	return anim
+}
@@ -0,0 +1,269 @@
+// Package encodertest compares encoder and frame processor output against
+// golden files, for testing custom encoders and processors the same way
+// Witness tests its own.
+//
+// Output is decoded and compared frame by frame rather than byte for byte,
+// with a tolerance for small perceptual differences. A golden file then
+// survives changes that do not visibly alter the result, such as a new
+// palette order or a different ffmpeg version.
+//
+// Run tests with WITNESS_UPDATE_GOLDEN=1 to write the current output as the
+// golden files instead of comparing against them.
+package encodertest
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/replay"
+	"github.com/ericmhalvorsen/witness/pkg/source"
+)
+
+// UpdateEnv is the environment variable that, when set to 1, rewrites
+// golden files from the current output
+const UpdateEnv = "WITNESS_UPDATE_GOLDEN"
+
+// Tolerance bounds how far output may drift from a golden file
+type Tolerance struct {
+	// Pixel is the largest perceptual difference (0-255) at which two
+	// pixels still count as matching
+	Pixel int
+
+	// Mismatched is the fraction of a frame's pixels (0-1) allowed to
+	// differ by more than Pixel
+	Mismatched float64
+
+	// Delay is the largest allowed difference in GIF frame delay, in 100ths
+	// of a second
+	Delay int
+}
+
+var (
+	// Exact requires identical pixels and delays
+	Exact = Tolerance{}
+
+	// GIFTolerance allows for dithering and palette changes
+	GIFTolerance = Tolerance{Pixel: 24, Mismatched: 0.01}
+
+	// VideoTolerance allows for lossy compression and differences between
+	// ffmpeg versions
+	VideoTolerance = Tolerance{Pixel: 32, Mismatched: 0.05}
+)
+
+// Frames is decoded output, with every frame at full canvas size
+type Frames struct {
+	Images []*image.RGBA
+
+	// Delays holds each frame's delay in 100ths of a second. It is nil for
+	// output without per-frame delays, such as video.
+	Delays []int
+}
+
+// DecodeGIF decodes an animated GIF. Frames that only cover part of the
+// canvas are composited the way a viewer would show them.
+func DecodeGIF(r io.Reader) (*Frames, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode GIF: %w", err)
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+
+	canvas := image.NewRGBA(bounds)
+	frames := &Frames{Delays: g.Delay}
+	for i, frame := range g.Image {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		previous := cloneRGBA(canvas)
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		frames.Images = append(frames.Images, cloneRGBA(canvas))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return frames, nil
+}
+
+// DecodeVideo decodes a video file with ffmpeg. It fails if ffmpeg is not
+// installed.
+func DecodeVideo(path string) (*Frames, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg is required to decode video: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(ffmpeg, "-v", "error", "-i", path, "-f", "yuv4mpegpipe", "-pix_fmt", "yuv444p", "-")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	y4m, err := source.NewY4MReader(&stdout)
+	if err != nil {
+		return nil, err
+	}
+	return readAll(y4m)
+}
+
+// ReadCapture reads the frames of a .wrec capture file
+func ReadCapture(path string) (*Frames, error) {
+	r, err := replay.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return readAll(r)
+}
+
+// readAll reads frames until io.EOF
+func readAll(r source.Reader) (*Frames, error) {
+	frames := &Frames{}
+	for {
+		frame, err := r.ReadFrame()
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		frames.Images = append(frames.Images, frame.Image)
+	}
+}
+
+// FrameDiff describes how one frame differs from its golden counterpart
+type FrameDiff struct {
+	// Mismatched is the number of pixels that differ by more than the
+	// tolerance, out of Total
+	Mismatched int
+	Total      int
+
+	// Max is the largest perceptual difference of any pixel
+	Max int
+}
+
+// Fraction returns the fraction of pixels that are mismatched
+func (d FrameDiff) Fraction() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return float64(d.Mismatched) / float64(d.Total)
+}
+
+// DiffFrame compares two frames of the same size pixel by pixel, counting
+// pixels whose perceptual difference is over tolerance
+func DiffFrame(got, want *image.RGBA, tolerance int) FrameDiff {
+	b := want.Bounds()
+	d := FrameDiff{Total: b.Dx() * b.Dy()}
+	for row := 0; row < b.Dy(); row++ {
+		g := got.Pix[got.PixOffset(got.Rect.Min.X, got.Rect.Min.Y+row):]
+		w := want.Pix[want.PixOffset(b.Min.X, b.Min.Y+row):]
+		for x := 0; x < b.Dx()*4; x += 4 {
+			diff := PixelDiff(g[x:x+4], w[x:x+4])
+			d.Max = max(d.Max, diff)
+			if diff > tolerance {
+				d.Mismatched++
+			}
+		}
+	}
+	return d
+}
+
+// PixelDiff returns the perceptual difference (0-255) between two RGBA
+// pixels. Color differences are weighted the way the eye sees them (the
+// "redmean" approximation), so a change in green counts for more than the
+// same change in blue. Fully transparent pixels always match each other.
+func PixelDiff(a, b []uint8) int {
+	if a[3] == 0 && b[3] == 0 {
+		return 0
+	}
+
+	rmean := (float64(a[0]) + float64(b[0])) / 2
+	dr := float64(a[0]) - float64(b[0])
+	dg := float64(a[1]) - float64(b[1])
+	db := float64(a[2]) - float64(b[2])
+	color := math.Sqrt((2+rmean/256)*dr*dr+4*dg*dg+(2+(255-rmean)/256)*db*db) / 3
+
+	return max(int(math.Round(color)), abs(int(a[3])-int(b[3])))
+}
+
+// Compare checks decoded output against golden frames. The error lists
+// every frame over tolerance.
+func Compare(got, want *Frames, tol Tolerance) error {
+	if len(got.Images) != len(want.Images) {
+		return fmt.Errorf("got %d frames, want %d", len(got.Images), len(want.Images))
+	}
+
+	var problems []string
+	for i := range want.Images {
+		gb, wb := got.Images[i].Bounds(), want.Images[i].Bounds()
+		if gb.Size() != wb.Size() {
+			problems = append(problems, fmt.Sprintf("frame %d: size %dx%d, want %dx%d", i, gb.Dx(), gb.Dy(), wb.Dx(), wb.Dy()))
+			continue
+		}
+
+		d := DiffFrame(got.Images[i], want.Images[i], tol.Pixel)
+		if d.Fraction() > tol.Mismatched {
+			problems = append(problems, fmt.Sprintf("frame %d: %d of %d pixels differ (%.2f%%, allowed %.2f%%), largest difference %d",
+				i, d.Mismatched, d.Total, d.Fraction()*100, tol.Mismatched*100, d.Max))
+		}
+
+		if want.Delays != nil && got.Delays != nil && abs(got.Delays[i]-want.Delays[i]) > tol.Delay {
+			problems = append(problems, fmt.Sprintf("frame %d: delay %d, want %d", i, got.Delays[i], want.Delays[i]))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("output differs from golden:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// updating reports whether golden files should be rewritten
+func updating() bool {
+	return os.Getenv(UpdateEnv) == "1"
+}
+
+// writeGolden replaces a golden file with data
+func writeGolden(golden string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+		return fmt.Errorf("failed to create golden directory: %w", err)
+	}
+	return os.WriteFile(golden, data, 0644)
+}
+
+// cloneRGBA returns a copy of img
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	out := image.NewRGBA(img.Rect)
+	copy(out.Pix, img.Pix)
+	return out
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
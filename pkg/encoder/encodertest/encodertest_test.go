@@ -0,0 +1,191 @@
+package encodertest
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"strings"
+	"testing"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+)
+
+// Helper function to generate deterministic test pattern frames
+func patternFrames(pattern capture.Pattern, n int) []*capture.Frame {
+	p := capture.NewPatternCapturer(capture.Config{Region: &capture.Region{Width: 96, Height: 64}, FPS: capture.FPS15}, pattern)
+	frames := make([]*capture.Frame, n)
+	for i := range frames {
+		frames[i] = p.Frame(i)
+	}
+	return frames
+}
+
+// Helper function to create a frame filled with one color
+func solid(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestPixelDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []uint8
+		want int
+	}{
+		{"identical", []uint8{10, 20, 30, 255}, []uint8{10, 20, 30, 255}, 0},
+		{"black and white", []uint8{0, 0, 0, 255}, []uint8{255, 255, 255, 255}, 255},
+		{"both transparent", []uint8{0, 0, 0, 0}, []uint8{255, 0, 0, 0}, 0},
+		{"alpha only", []uint8{0, 0, 0, 255}, []uint8{0, 0, 0, 155}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PixelDiff(tt.a, tt.b); got != tt.want {
+				t.Errorf("PixelDiff() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Green differences are more visible than blue ones
+	green := PixelDiff([]uint8{0, 0, 0, 255}, []uint8{0, 40, 0, 255})
+	blue := PixelDiff([]uint8{0, 0, 0, 255}, []uint8{0, 0, 40, 255})
+	if green <= blue {
+		t.Errorf("green difference %d should be larger than blue difference %d", green, blue)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	gray := color.RGBA{128, 128, 128, 255}
+	base := &Frames{Images: []*image.RGBA{solid(10, 10, gray)}, Delays: []int{10}}
+
+	// One pixel in a hundred changed a lot
+	speck := solid(10, 10, gray)
+	speck.SetRGBA(3, 3, color.RGBA{255, 0, 0, 255})
+
+	tests := []struct {
+		name    string
+		got     *Frames
+		tol     Tolerance
+		wantErr string
+	}{
+		{"identical", base, Exact, ""},
+		{"slight shift within tolerance", &Frames{Images: []*image.RGBA{solid(10, 10, color.RGBA{132, 130, 126, 255})}}, GIFTolerance, ""},
+		{"slight shift exact", &Frames{Images: []*image.RGBA{solid(10, 10, color.RGBA{132, 130, 126, 255})}}, Exact, "100 of 100 pixels differ"},
+		{"speck allowed", &Frames{Images: []*image.RGBA{speck}}, Tolerance{Mismatched: 0.01}, ""},
+		{"speck not allowed", &Frames{Images: []*image.RGBA{speck}}, Tolerance{Mismatched: 0.005}, "1 of 100 pixels differ"},
+		{"frame count", &Frames{}, Exact, "got 0 frames, want 1"},
+		{"size", &Frames{Images: []*image.RGBA{solid(8, 10, gray)}}, GIFTolerance, "size 8x10, want 10x10"},
+		{"delay", &Frames{Images: base.Images, Delays: []int{12}}, Tolerance{Delay: 1}, "delay 12, want 10"},
+		{"delay within tolerance", &Frames{Images: base.Images, Delays: []int{11}}, Tolerance{Delay: 1}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Compare(tt.got, base, tt.tol)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Compare() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compare() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiffFrameSubImage(t *testing.T) {
+	big := solid(20, 20, color.RGBA{0, 0, 255, 255})
+	sub := big.SubImage(image.Rect(5, 5, 15, 15)).(*image.RGBA)
+
+	d := DiffFrame(sub, solid(10, 10, color.RGBA{0, 0, 255, 255}), 0)
+	if d.Mismatched != 0 || d.Total != 100 {
+		t.Errorf("DiffFrame() = %+v, want 0 of 100 mismatched", d)
+	}
+}
+
+func TestDecodeGIFComposites(t *testing.T) {
+	palette := color.Palette{color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}}
+	first := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+	second := image.NewPaletted(image.Rect(1, 1, 2, 2), palette)
+	second.SetColorIndex(1, 1, 1)
+
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
+		Image:    []*image.Paletted{first, second},
+		Delay:    []int{5, 7},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone},
+		Config:   image.Config{Width: 4, Height: 4},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode GIF: %v", err)
+	}
+
+	frames, err := DecodeGIF(&buf)
+	if err != nil {
+		t.Fatalf("DecodeGIF() failed: %v", err)
+	}
+	if len(frames.Images) != 2 || frames.Delays[1] != 7 {
+		t.Fatalf("got %d frames with delays %v, want 2 with delays [5 7]", len(frames.Images), frames.Delays)
+	}
+
+	second2 := frames.Images[1]
+	if second2.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Errorf("second frame bounds = %v, want the full canvas", second2.Bounds())
+	}
+	if got := second2.RGBAAt(1, 1); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("changed pixel = %v, want white", got)
+	}
+	if got := second2.RGBAAt(3, 3); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("pixel kept from first frame = %v, want black", got)
+	}
+}
+
+// TestGoldenGIF guards the GIF encoder's output for SMPTE bars
+func TestGoldenGIF(t *testing.T) {
+	enc := encoder.NewGIFEncoderFPS("", capture.FPS15, encoder.QualityHigh)
+	for _, f := range patternFrames(capture.PatternBars, 3) {
+		if err := enc.AddFrame(f); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := enc.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo() failed: %v", err)
+	}
+
+	AssertGIF(t, buf.Bytes(), "testdata/bars.gif", GIFTolerance)
+}
+
+// TestGoldenFrames guards a frame processor's output with a lossless golden
+func TestGoldenFrames(t *testing.T) {
+	sink := &Sink{}
+	denoise := editor.NewDenoise(sink, 8)
+	for _, f := range patternFrames(capture.PatternGradient, 4) {
+		if err := denoise.AddFrame(f); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+
+	AssertFrames(t, sink.Frames(), "testdata/gradient-denoised.wrec", Exact)
+}
+
+func TestSinkCopiesFrames(t *testing.T) {
+	frame := &capture.Frame{Image: solid(2, 2, color.RGBA{1, 2, 3, 255})}
+
+	sink := &Sink{}
+	sink.AddFrame(frame)
+	frame.Image.Pix[0] = 99
+
+	if got := sink.Frames()[0].Image.Pix[0]; got != 1 {
+		t.Errorf("stored pixel = %d, want 1 (unaffected by later changes)", got)
+	}
+}
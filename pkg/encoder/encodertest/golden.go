@@ -0,0 +1,164 @@
+package encodertest
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/replay"
+)
+
+// AssertGIF compares an encoded GIF against a golden GIF file
+func AssertGIF(t testing.TB, got []byte, golden string, tol Tolerance) {
+	t.Helper()
+
+	if updating() {
+		if err := writeGolden(golden, got); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	gotFrames, err := DecodeGIF(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("output: %v", err)
+	}
+	want := readGolden(t, golden)
+	wantFrames, err := DecodeGIF(bytes.NewReader(want))
+	if err != nil {
+		t.Fatalf("%s: %v", golden, err)
+	}
+
+	if err := Compare(gotFrames, wantFrames, tol); err != nil {
+		t.Errorf("%s: %v", golden, err)
+	}
+}
+
+// AssertVideo compares a video file against a golden video file. Both are
+// decoded with ffmpeg, and the test is skipped if ffmpeg is not installed.
+func AssertVideo(t testing.TB, got, golden string, tol Tolerance) {
+	t.Helper()
+
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+
+	if updating() {
+		data, err := os.ReadFile(got)
+		if err != nil {
+			t.Fatalf("failed to read output: %v", err)
+		}
+		if err := writeGolden(golden, data); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	readGolden(t, golden)
+	gotFrames, err := DecodeVideo(got)
+	if err != nil {
+		t.Fatalf("output: %v", err)
+	}
+	wantFrames, err := DecodeVideo(golden)
+	if err != nil {
+		t.Fatalf("%s: %v", golden, err)
+	}
+
+	if err := Compare(gotFrames, wantFrames, tol); err != nil {
+		t.Errorf("%s: %v", golden, err)
+	}
+}
+
+// AssertFrames compares frames, such as the output of a frame processor,
+// against a golden .wrec capture file. Frames are stored losslessly, so
+// Exact is usually the right tolerance.
+func AssertFrames(t testing.TB, got []*capture.Frame, golden string, tol Tolerance) {
+	t.Helper()
+
+	if updating() {
+		if err := writeCapture(golden, got); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	readGolden(t, golden)
+	want, err := ReadCapture(golden)
+	if err != nil {
+		t.Fatalf("%s: %v", golden, err)
+	}
+
+	gotFrames := &Frames{}
+	for _, f := range got {
+		gotFrames.Images = append(gotFrames.Images, f.Image)
+	}
+	if err := Compare(gotFrames, want, tol); err != nil {
+		t.Errorf("%s: %v", golden, err)
+	}
+}
+
+// readGolden reads a golden file, failing the test with a hint if it does
+// not exist yet
+func readGolden(t testing.TB, golden string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(golden)
+	if os.IsNotExist(err) {
+		t.Fatalf("golden file %s does not exist; run with %s=1 to create it", golden, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	return data
+}
+
+// writeCapture saves frames as a .wrec golden file
+func writeCapture(golden string, frames []*capture.Frame) error {
+	if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+		return err
+	}
+	w, err := replay.Create(golden, capture.FPS15)
+	if err != nil {
+		return err
+	}
+	for _, f := range frames {
+		if err := w.AddFrame(f); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// Sink is a recorder.FrameSink that keeps a copy of every frame, for
+// collecting the output of a frame processor
+type Sink struct {
+	mu     sync.Mutex
+	frames []*capture.Frame
+}
+
+// AddFrame records a copy of the frame, since processors may reuse it
+func (s *Sink) AddFrame(frame *capture.Frame) error {
+	img := image.NewRGBA(frame.Image.Rect)
+	draw.Draw(img, img.Rect, frame.Image, img.Rect.Min, draw.Src)
+	copied := *frame
+	copied.Image = img
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frames = append(s.frames, &copied)
+	return nil
+}
+
+// Frames returns the frames received so far
+func (s *Sink) Frames() []*capture.Frame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*capture.Frame(nil), s.frames...)
+}
@@ -0,0 +1,373 @@
+package encoder
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// FFmpegEnv names the environment variable that overrides where ffmpeg is
+// found
+const FFmpegEnv = "WITNESS_FFMPEG"
+
+// ErrFFmpegNotFound is returned when no ffmpeg binary can be found
+var ErrFFmpegNotFound = errors.New("ffmpeg not found")
+
+// ffmpegDirs are searched after PATH, since apps launched from the Finder or
+// a service manager often run with a PATH that leaves out package managers
+var ffmpegDirs = []string{"/opt/homebrew/bin", "/usr/local/bin", "/usr/bin", "/snap/bin"}
+
+// FindFFmpeg returns the path of the ffmpeg binary: $WITNESS_FFMPEG if set,
+// else the first ffmpeg on PATH or in the usual install locations. The
+// error wraps ErrFFmpegNotFound and says how to install it.
+func FindFFmpeg() (string, error) {
+	if path := os.Getenv(FFmpegEnv); path != "" {
+		found, err := exec.LookPath(path)
+		if err != nil {
+			return "", fmt.Errorf("%w: $%s is %q: %v", ErrFFmpegNotFound, FFmpegEnv, path, err)
+		}
+		return found, nil
+	}
+
+	if path, err := exec.LookPath(DefaultFFmpeg); err == nil {
+		return path, nil
+	}
+	for _, dir := range ffmpegDirs {
+		if path, err := exec.LookPath(filepath.Join(dir, DefaultFFmpeg)); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w (%s, or set $%s to its path)", ErrFFmpegNotFound, installHint(), FFmpegEnv)
+}
+
+// installHint says how to install ffmpeg on this system
+func installHint() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "install it with 'brew install ffmpeg'"
+	case "windows":
+		return "install it with 'winget install ffmpeg'"
+	default:
+		return "install it with your package manager, e.g. 'apt install ffmpeg'"
+	}
+}
+
+// FFmpegFormat is an output format encoded by an ffmpeg child process. Args
+// is the template of output arguments; see ExpandFFmpegArgs for its
+// placeholders.
+type FFmpegFormat struct {
+	Name string
+	Args []string
+
+	// CRF is the constant rate factor for low, medium, and high quality
+	CRF [3]int
+}
+
+// FFmpegFormats are the built-in formats, keyed by name. Each pads frames to
+// even dimensions, which their 4:2:0 chroma requires.
+var FFmpegFormats = map[string]FFmpegFormat{
+	"mp4": {
+		Name: "mp4",
+		Args: []string{"-vf", evenSize, "-c:v", "libx264", "-preset", "medium", "-pix_fmt", "yuv420p",
+			"-crf", "{crf}", "-movflags", "+faststart", "{output}"},
+		CRF: [3]int{28, 23, 18},
+	},
+	"webm": {
+		Name: "webm",
+		Args: []string{"-vf", evenSize, "-c:v", "libvpx-vp9", "-b:v", "0", "-crf", "{crf}",
+			"-row-mt", "1", "-pix_fmt", "yuv420p", "{output}"},
+		CRF: [3]int{40, 33, 24},
+	},
+	"av1": {
+		Name: "av1",
+		Args: []string{"-vf", evenSize, "-c:v", "libsvtav1", "-preset", "8", "-crf", "{crf}",
+			"-pix_fmt", "yuv420p", "-movflags", "+faststart", "{output}"},
+		CRF: [3]int{45, 35, 25},
+	},
+}
+
+// ParseFFmpegFormat looks up a built-in format by name (mp4, webm, or av1)
+func ParseFFmpegFormat(name string) (FFmpegFormat, error) {
+	f, ok := FFmpegFormats[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		names := make([]string, 0, len(FFmpegFormats))
+		for n := range FFmpegFormats {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return FFmpegFormat{}, fmt.Errorf("unknown ffmpeg format %q (want %s)", name, strings.Join(names, ", "))
+	}
+	return f, nil
+}
+
+// crf returns the format's constant rate factor for a quality level
+func (f FFmpegFormat) crf(q GIFQuality) int {
+	switch q {
+	case QualityLow:
+		return f.CRF[0]
+	case QualityHigh:
+		return f.CRF[2]
+	default:
+		return f.CRF[1]
+	}
+}
+
+// ExpandFFmpegArgs replaces the placeholders {fps}, {width}, {height},
+// {crf}, and {output} in each argument of template with their values in
+// vars. A placeholder may be part of a longer argument, as in
+// "scale={width}/2:-2". It fails on unknown or unclosed placeholders.
+func ExpandFFmpegArgs(template []string, vars map[string]string) ([]string, error) {
+	args := make([]string, len(template))
+	for i, arg := range template {
+		var b strings.Builder
+		for {
+			start := strings.IndexByte(arg, '{')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(arg[start:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed placeholder in ffmpeg argument %q", template[i])
+			}
+			name := arg[start+1 : start+end]
+			value, ok := vars[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown placeholder {%s} in ffmpeg argument %q", name, template[i])
+			}
+			b.WriteString(arg[:start])
+			b.WriteString(value)
+			arg = arg[start+end+1:]
+		}
+		b.WriteString(arg)
+		args[i] = b.String()
+	}
+	return args, nil
+}
+
+// FFmpegEncoder pipes frames as raw RGBA to an ffmpeg child process, which
+// encodes them in any format ffmpeg supports. Like Y4MEncoder, it repeats
+// frames to keep the fixed-rate output in step with the capture timestamps.
+type FFmpegEncoder struct {
+	outputPath string
+	fps        capture.FPS
+	quality    GIFQuality
+	format     FFmpegFormat
+	ffmpeg     string
+
+	proc   *ffmpegProcess
+	clock  frameClock
+	width  int
+	height int
+	frames int
+	pix    []byte
+}
+
+// NewFFmpegEncoder creates an encoder writing outputPath in format. It fails
+// if ffmpeg cannot be found.
+func NewFFmpegEncoder(outputPath string, fps capture.FPS, quality GIFQuality, format FFmpegFormat) (*FFmpegEncoder, error) {
+	ffmpeg, err := FindFFmpeg()
+	if err != nil {
+		return nil, err
+	}
+
+	return &FFmpegEncoder{
+		outputPath: outputPath,
+		fps:        fps,
+		quality:    quality,
+		format:     format,
+		ffmpeg:     ffmpeg,
+	}, nil
+}
+
+// SetArgs replaces the format's output arguments with template, e.g. to use
+// another codec or add filters. The output path is appended if template has
+// no {output}. It must be called before frames are added.
+func (e *FFmpegEncoder) SetArgs(template []string) {
+	e.format.Args = template
+}
+
+// AddFrame sends a frame to ffmpeg, after repeating the previous one as
+// often as needed to reach the frame's capture time. ffmpeg is started on
+// the first frame, and every frame must have the same size.
+func (e *FFmpegEncoder) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil {
+		return fmt.Errorf("invalid frame")
+	}
+
+	b := frame.Image.Bounds()
+	if e.proc == nil {
+		if err := e.start(b); err != nil {
+			return err
+		}
+	} else if b.Dx() != e.width || b.Dy() != e.height {
+		return fmt.Errorf("frame size %dx%d does not match stream size %dx%d", b.Dx(), b.Dy(), e.width, e.height)
+	}
+
+	// Frames captured early are kept rather than dropped
+	slot := e.fps.FramesIn(e.clock.next(frame) + e.fps.FrameDuration()/2)
+	for e.frames > 0 && e.frames < slot {
+		if err := e.writeFrame(); err != nil {
+			return err
+		}
+	}
+
+	row := 4 * e.width
+	for y := 0; y < e.height; y++ {
+		i := frame.Image.PixOffset(b.Min.X, b.Min.Y+y)
+		copy(e.pix[y*row:(y+1)*row], frame.Image.Pix[i:i+row])
+	}
+	return e.writeFrame()
+}
+
+// FrameCount returns the number of frames sent to ffmpeg, including repeats
+func (e *FFmpegEncoder) FrameCount() int {
+	return e.frames
+}
+
+// Close finishes encoding and waits for ffmpeg to exit
+func (e *FFmpegEncoder) Close() error {
+	if e.proc == nil {
+		return fmt.Errorf("no frames to encode")
+	}
+	return e.proc.close()
+}
+
+// start launches ffmpeg for frames with the given bounds
+func (e *FFmpegEncoder) start(bounds image.Rectangle) error {
+	e.width, e.height = bounds.Dx(), bounds.Dy()
+	args, err := e.args()
+	if err != nil {
+		return err
+	}
+
+	proc := newFFmpegProcess(e.ffmpeg, args, nil)
+	if err := proc.start(); err != nil {
+		return err
+	}
+
+	e.proc = proc
+	e.clock = newFrameClock(e.fps)
+	e.pix = make([]byte, 4*e.width*e.height)
+	return nil
+}
+
+// args returns the ffmpeg arguments: raw RGBA on stdin, then the format's
+// expanded output arguments
+func (e *FFmpegEncoder) args() ([]string, error) {
+	template := e.format.Args
+	if !hasPlaceholder(template, "{output}") {
+		template = append(template[:len(template):len(template)], "{output}")
+	}
+	rate := fmt.Sprintf("%d/%d", e.fps.Num, e.fps.Den)
+	output, err := ExpandFFmpegArgs(template, map[string]string{
+		"fps":    rate,
+		"width":  strconv.Itoa(e.width),
+		"height": strconv.Itoa(e.height),
+		"crf":    strconv.Itoa(e.format.crf(e.quality)),
+		"output": e.outputPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-f", "rawvideo", "-pix_fmt", "rgba",
+		"-s", fmt.Sprintf("%dx%d", e.width, e.height),
+		"-r", rate,
+		"-i", "-",
+	}
+	return append(args, output...), nil
+}
+
+// hasPlaceholder reports whether any argument contains placeholder
+func hasPlaceholder(args []string, placeholder string) bool {
+	for _, arg := range args {
+		if strings.Contains(arg, placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFrame writes the current frame to ffmpeg
+func (e *FFmpegEncoder) writeFrame() error {
+	if _, err := e.proc.buf.Write(e.pix); err != nil {
+		return e.proc.fail(err)
+	}
+	e.frames++
+	return nil
+}
+
+// ffmpegProcess is an ffmpeg child process, keeping its error output to
+// explain a failure
+type ffmpegProcess struct {
+	cmd    *exec.Cmd
+	pipe   io.WriteCloser
+	buf    *bufio.Writer // Buffers stdin once started
+	stderr bytes.Buffer
+}
+
+// newFFmpegProcess prepares ffmpeg to run with args, writing its output
+// to stdout if set
+func newFFmpegProcess(ffmpeg string, args []string, stdout io.Writer) *ffmpegProcess {
+	p := &ffmpegProcess{cmd: exec.Command(ffmpeg, args...)}
+	p.cmd.Stdout = stdout
+	p.cmd.Stderr = &p.stderr
+	return p
+}
+
+// start launches ffmpeg to read its input from buf
+func (p *ffmpegProcess) start() error {
+	pipe, err := p.cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to connect to ffmpeg: %w", err)
+	}
+	if err := p.cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	p.pipe = pipe
+	p.buf = bufio.NewWriter(pipe)
+	return nil
+}
+
+// close flushes buf, ends ffmpeg's input, and waits for it to exit
+func (p *ffmpegProcess) close() error {
+	err := p.buf.Flush()
+	p.pipe.Close()
+	if waitErr := p.cmd.Wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return p.fail(err)
+	}
+	return nil
+}
+
+// run runs ffmpeg to completion on input it reads itself
+func (p *ffmpegProcess) run() error {
+	if err := p.cmd.Run(); err != nil {
+		return p.fail(err)
+	}
+	return nil
+}
+
+// fail adds ffmpeg's own error output to err
+func (p *ffmpegProcess) fail(err error) error {
+	if msg := strings.TrimSpace(p.stderr.String()); msg != "" {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, msg)
+	}
+	return fmt.Errorf("ffmpeg failed: %w", err)
+}
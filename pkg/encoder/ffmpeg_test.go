@@ -0,0 +1,208 @@
+package encoder
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Helper function to write a fake ffmpeg script that records its arguments
+// to $WITNESS_FAKE_ARGS and copies stdin to the last argument, or runs body
+// instead when it is not empty
+func fakeFFmpeg(t *testing.T, output, body string) string {
+	t.Helper()
+	if body == "" {
+		body = `echo "$@" > "$WITNESS_FAKE_ARGS"
+for last; do :; done
+cat > "$last"
+`
+	}
+	script := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WITNESS_FAKE_ARGS", output+".args")
+	return script
+}
+
+// Helper function to create a frame filled with c at the given time
+func solidFrame(w, h int, c color.RGBA, at time.Time) *capture.Frame {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return &capture.Frame{Image: img, Timestamp: at}
+}
+
+func TestFFmpegEncoder(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.webm")
+	format, err := ParseFFmpegFormat("webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &FFmpegEncoder{
+		outputPath: output,
+		fps:        capture.FPS30,
+		quality:    QualityHigh,
+		format:     format,
+		ffmpeg:     fakeFFmpeg(t, output, ""),
+	}
+
+	start := time.Now()
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	if err := e.AddFrame(solidFrame(3, 2, red, start)); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	// Captured three intervals later, so the red frame is shown three times
+	if err := e.AddFrame(solidFrame(3, 2, blue, start.Add(100*time.Millisecond))); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if e.FrameCount() != 4 {
+		t.Errorf("FrameCount() = %d, want 4", e.FrameCount())
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := 3 * 2 * 4
+	if len(data) != 4*frame {
+		t.Fatalf("ffmpeg got %d bytes, want 4 frames of %d", len(data), frame)
+	}
+	if !bytes.Equal(data[2*frame:2*frame+4], []byte{255, 0, 0, 255}) || !bytes.Equal(data[3*frame:3*frame+4], []byte{0, 0, 255, 255}) {
+		t.Error("ffmpeg should get the red frame three times, then the blue one")
+	}
+
+	args, err := os.ReadFile(output + ".args")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"-f rawvideo -pix_fmt rgba -s 3x2 -r 30/1 -i -", "-c:v libvpx-vp9", "-crf 24", output} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("ffmpeg args %q missing %q", args, want)
+		}
+	}
+}
+
+func TestFFmpegEncoderArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		template []string
+		want     string
+		wantErr  bool
+	}{
+		{"placeholders", []string{"-vf", "scale={width}/2:-2", "-r", "{fps}", "-crf", "{crf}", "{output}"},
+			"-vf scale=640/2:-2 -r 30000/1001 -crf 23 out.mp4", false},
+		{"output appended", []string{"-c:v", "libx265"}, "-c:v libx265 out.mp4", false},
+		{"unknown placeholder", []string{"-b:v", "{bitrate}"}, "", true},
+		{"unclosed placeholder", []string{"-s", "{width"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &FFmpegEncoder{outputPath: "out.mp4", fps: capture.FPS{Num: 30000, Den: 1001},
+				quality: QualityMedium, format: FFmpegFormats["mp4"], width: 640, height: 480}
+			e.SetArgs(tt.template)
+			args, err := e.args()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("args() = %q, want an error", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("args() failed: %v", err)
+			}
+			if got := strings.Join(args, " "); !strings.HasSuffix(got, "-i - "+tt.want) {
+				t.Errorf("args() = %q, want it to end with %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFFmpegEncoderErrors(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.mp4")
+	e := &FFmpegEncoder{
+		outputPath: output,
+		fps:        capture.FPS30,
+		format:     FFmpegFormats["av1"],
+		ffmpeg:     fakeFFmpeg(t, output, "echo 'Unknown encoder libsvtav1' >&2\nexit 1\n"),
+	}
+
+	if err := e.Close(); err == nil {
+		t.Error("Close() without frames should fail")
+	}
+
+	// The script may exit before or after the frame is written, so the error
+	// can come from either call, but it must carry ffmpeg's message
+	err := e.AddFrame(solidFrame(2, 2, color.RGBA{A: 255}, time.Now()))
+	if err == nil {
+		err = e.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), "Unknown encoder libsvtav1") {
+		t.Errorf("error = %v, want ffmpeg's message", err)
+	}
+
+	if err := e.AddFrame(solidFrame(4, 4, color.RGBA{A: 255}, time.Now())); err == nil {
+		t.Error("AddFrame() with a different size should fail")
+	}
+}
+
+func TestFindFFmpeg(t *testing.T) {
+	script := fakeFFmpeg(t, filepath.Join(t.TempDir(), "out"), "exit 0\n")
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv(FFmpegEnv, script)
+		if path, err := FindFFmpeg(); err != nil || path != script {
+			t.Errorf("FindFFmpeg() = %q, %v, want %q", path, err, script)
+		}
+	})
+
+	t.Run("environment missing", func(t *testing.T) {
+		t.Setenv(FFmpegEnv, filepath.Join(t.TempDir(), "ffmpeg"))
+		if _, err := FindFFmpeg(); !errors.Is(err, ErrFFmpegNotFound) {
+			t.Errorf("FindFFmpeg() error = %v, want ErrFFmpegNotFound", err)
+		}
+	})
+
+	t.Run("install location", func(t *testing.T) {
+		t.Setenv(FFmpegEnv, "")
+		t.Setenv("PATH", t.TempDir())
+		saved := ffmpegDirs
+		defer func() { ffmpegDirs = saved }()
+
+		ffmpegDirs = []string{t.TempDir(), filepath.Dir(script)}
+		if path, err := FindFFmpeg(); err != nil || path != script {
+			t.Errorf("FindFFmpeg() = %q, %v, want %q", path, err, script)
+		}
+
+		ffmpegDirs = nil
+		_, err := FindFFmpeg()
+		if !errors.Is(err, ErrFFmpegNotFound) || !strings.Contains(err.Error(), FFmpegEnv) {
+			t.Errorf("FindFFmpeg() error = %v, want ErrFFmpegNotFound with a hint", err)
+		}
+	})
+}
+
+func TestParseFFmpegFormat(t *testing.T) {
+	for _, name := range []string{"mp4", "WebM", " av1 "} {
+		if _, err := ParseFFmpegFormat(name); err != nil {
+			t.Errorf("ParseFFmpegFormat(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := ParseFFmpegFormat("mkv"); err == nil || !strings.Contains(err.Error(), "av1, mp4, webm") {
+		t.Errorf("ParseFFmpegFormat(mkv) error = %v, want the format names", err)
+	}
+}
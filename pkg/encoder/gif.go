@@ -7,8 +7,12 @@ import (
 	"image/color/palette"
 	"image/draw"
 	"image/gif"
+	"io"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
 	"github.com/ericmhalvorsen/witness/pkg/capture"
 )
 
@@ -24,20 +28,103 @@ const (
 	QualityHigh
 )
 
+// QualityInfo describes what a quality level trades off
+type QualityInfo struct {
+	Quality GIFQuality
+	Name    string
+
+	// GIF and Video summarize the effect on each output format
+	GIF   string
+	Video string
+}
+
+// Qualities lists the quality levels from smallest files to best quality
+func Qualities() []QualityInfo {
+	return []QualityInfo{
+		{
+			Quality: QualityLow,
+			Name:    "low",
+			GIF:     "64-color palette: smallest files, visible banding in gradients and photos",
+			Video:   fmt.Sprintf("x264 CRF %d: smallest files, softer detail in motion", crf(QualityLow)),
+		},
+		{
+			Quality: QualityMedium,
+			Name:    "medium",
+			GIF:     "256-color palette: balanced size, smooth enough for most screens",
+			Video:   fmt.Sprintf("x264 CRF %d: balanced size and detail", crf(QualityMedium)),
+		},
+		{
+			Quality: QualityHigh,
+			Name:    "high",
+			GIF:     "216-color web-safe palette: even color steps that keep flat UI colors stable",
+			Video:   fmt.Sprintf("x264 CRF %d: near-lossless text, largest files", crf(QualityHigh)),
+		},
+	}
+}
+
+// String returns the quality level's name
+func (q GIFQuality) String() string {
+	for _, info := range Qualities() {
+		if info.Quality == q {
+			return info.Name
+		}
+	}
+	return fmt.Sprintf("GIFQuality(%d)", int(q))
+}
+
+// ParseQuality parses a quality level name (low, medium, or high)
+func ParseQuality(s string) (GIFQuality, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	var names []string
+	for _, info := range Qualities() {
+		if info.Name == name {
+			return info.Quality, nil
+		}
+		names = append(names, info.Name)
+	}
+	return 0, fmt.Errorf("invalid quality %q: want %s, or %s", s,
+		strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
+
 // GIFEncoder encodes captured frames as an animated GIF
 type GIFEncoder struct {
 	quality    GIFQuality
-	delay      int  // Delay between frames in 100ths of a second
+	delay      int // Delay between frames in 100ths of a second
 	outputPath string
 	frames     []*image.Paletted
 	delays     []int
+	clock      frameClock
+	start      int // Start of the newest frame in 100ths of a second
+	holdFirst  int // Extra delay on the first frame in 100ths of a second
+	holdLast   int // Extra delay on the last frame in 100ths of a second
+	reverse    bool
+	autoCrop   bool
+	autoRegion bool
+	autoLoop   bool
+	palette    color.Palette // Overrides the quality preset when set
+	colors     int           // Adaptive palette size, 0 to use the preset
+	chromaKey  *color.RGBA   // Color made transparent, if set
+	keyTol     uint8         // Per-channel tolerance for chromaKey
+	alpha      bool          // Make mostly transparent pixels transparent
+	interlace  bool
+	disposal   Disposal
+	lossy      int  // LZW color tolerance, 0 for lossless
+	noDither   bool // Map each pixel to its nearest color instead of dithering
 }
 
-// NewGIFEncoder creates a new GIF encoder
+// NewGIFEncoder creates a new GIF encoder for a whole-number frame rate
 func NewGIFEncoder(outputPath string, fps int, quality GIFQuality) *GIFEncoder {
+	return NewGIFEncoderFPS(outputPath, capture.IntFPS(fps), quality)
+}
+
+// NewGIFEncoderFPS creates a new GIF encoder for a rational frame rate
+func NewGIFEncoderFPS(outputPath string, fps capture.FPS, quality GIFQuality) *GIFEncoder {
 	// Convert FPS to delay (in 100ths of a second)
-	// delay = 100 / fps
-	delay := 100 / fps
+	// delay = 100 * den / num
+	delay := 1
+	if fps.Valid() {
+		delay = 100 * fps.Den / fps.Num
+	}
 	if delay < 1 {
 		delay = 1 // Minimum delay
 	}
@@ -48,10 +135,13 @@ func NewGIFEncoder(outputPath string, fps int, quality GIFQuality) *GIFEncoder {
 		outputPath: outputPath,
 		frames:     make([]*image.Paletted, 0),
 		delays:     make([]int, 0),
+		clock:      newFrameClock(fps),
 	}
 }
 
-// AddFrame adds a frame to the GIF
+// AddFrame adds a frame to the GIF. The previous frame is shown until this
+// one was captured, so uneven capture timing plays back at the speed it was
+// recorded. The newest frame is shown for the nominal delay.
 func (e *GIFEncoder) AddFrame(frame *capture.Frame) error {
 	if frame == nil || frame.Image == nil {
 		return fmt.Errorf("invalid frame")
@@ -60,6 +150,16 @@ func (e *GIFEncoder) AddFrame(frame *capture.Frame) error {
 	// Convert RGBA to Paletted image
 	palettedImg := e.convertToPaletted(frame.Image)
 
+	// Frame starts are rounded on the whole timeline rather than per frame,
+	// so rounding never accumulates into drift. A delay of 0 is shown as
+	// 0.1s by browsers, so each frame keeps at least 1.
+	start := int((e.clock.next(frame) + 5*time.Millisecond) / (10 * time.Millisecond))
+	if n := len(e.delays); n > 0 {
+		start = max(start, e.start+1)
+		e.delays[n-1] = start - e.start
+	}
+	e.start = start
+
 	e.frames = append(e.frames, palettedImg)
 	e.delays = append(e.delays, e.delay)
 
@@ -79,39 +179,326 @@ func (e *GIFEncoder) Encode() error {
 	}
 	defer outFile.Close()
 
+	if err := e.EncodeTo(outFile); err != nil {
+		return err
+	}
+
+	return outFile.Close()
+}
+
+// EncodeTo writes all frames to w as an animated GIF. The output path is
+// not used, so this can stream to stdout or a network connection.
+func (e *GIFEncoder) EncodeTo(w io.Writer) error {
+	if len(e.frames) == 0 {
+		return fmt.Errorf("no frames to encode")
+	}
+
+	frames, delays := e.frames, e.delays
+	if e.autoLoop {
+		if loop, ok := e.Loop(); ok {
+			frames = frames[loop.First : loop.Last+1]
+			delays = delays[loop.First : loop.Last+1]
+		}
+	}
+	if e.reverse {
+		frames = reversed(frames)
+	}
+	if e.autoCrop {
+		frames = cropBorders(frames)
+	}
+	if e.autoRegion {
+		frames = cropToActivity(frames)
+	}
+	if e.lossy > 0 {
+		frames = lossyAll(frames, e.lossy, e.interlace)
+	}
+
 	// Create GIF
 	anim := &gif.GIF{
-		Image: e.frames,
-		Delay: e.delays,
+		Image:    frames,
+		Delay:    e.frameDelays(delays),
+		Disposal: frameDisposals(frames, e.disposal),
 	}
 
-	// Encode to file
-	if err := gif.EncodeAll(outFile, anim); err != nil {
+	encode := gif.EncodeAll
+	if e.interlace {
+		encode = encodeInterlaced
+	}
+	if err := encode(w, anim); err != nil {
 		return fmt.Errorf("failed to encode GIF: %w", err)
 	}
 
 	return nil
 }
 
+// SetInterlace makes Encode write interlaced frames, which browsers render
+// progressively while a large GIF is still downloading
+func (e *GIFEncoder) SetInterlace(interlace bool) {
+	e.interlace = interlace
+}
+
+// SetLossy lets frames trade slight artifacts for a much smaller file by
+// substituting colors within tolerance of each other where that lengthens
+// an LZW match. Around 20 is subtle and 80 to 200 is aggressive; 0 keeps
+// the output lossless.
+func (e *GIFEncoder) SetLossy(tolerance int) {
+	e.lossy = max(tolerance, 0)
+}
+
+// SetDither selects Floyd-Steinberg dithering (the default) or plain
+// nearest-color mapping. Turning dithering off suits flat UI and text,
+// where it avoids speckled backgrounds and compresses better. It must be
+// called before frames are added.
+func (e *GIFEncoder) SetDither(enabled bool) {
+	e.noDither = !enabled
+}
+
+// SetDisposal overrides how frames are disposed of before the next frame
+// is drawn. The default, DisposalAuto, suits full frames with or without
+// transparency.
+func (e *GIFEncoder) SetDisposal(d Disposal) {
+	e.disposal = d
+}
+
+// SetHoldFirst extends the display time of the first frame by d
+func (e *GIFEncoder) SetHoldFirst(d time.Duration) {
+	e.holdFirst = durationToDelay(d)
+}
+
+// SetHoldLast extends the display time of the last frame by d
+func (e *GIFEncoder) SetHoldLast(d time.Duration) {
+	e.holdLast = durationToDelay(d)
+}
+
+// SetReverse makes Encode write frames in reverse order.
+// Each frame keeps its own delay.
+func (e *GIFEncoder) SetReverse(reverse bool) {
+	e.reverse = reverse
+}
+
+// SetAutoCrop makes Encode trim static, uniform borders (such as desktop
+// background around a window) from every frame
+func (e *GIFEncoder) SetAutoCrop(autoCrop bool) {
+	e.autoCrop = autoCrop
+}
+
+// SetAutoRegion makes Encode crop mostly static recordings to the area
+// that changes
+func (e *GIFEncoder) SetAutoRegion(autoRegion bool) {
+	e.autoRegion = autoRegion
+}
+
+// SetAutoLoop makes Encode keep only the frames that loop seamlessly, when
+// the recording has such a loop (see analyze.FindLoop)
+func (e *GIFEncoder) SetAutoLoop(autoLoop bool) {
+	e.autoLoop = autoLoop
+}
+
+// SetPalette makes every frame use p instead of the quality preset's
+// palette. It must be called before frames are added.
+func (e *GIFEncoder) SetPalette(p color.Palette) {
+	e.palette = p
+}
+
+// SetColors builds an adaptive palette of up to n colors (at most
+// MaxColors) from the first frame instead of using the quality preset.
+// It has no effect when SetPalette is used, and 0 restores the preset.
+func (e *GIFEncoder) SetColors(n int) {
+	e.colors = min(n, MaxColors)
+}
+
+// SetChromaKey makes pixels within tolerance of key on every channel
+// transparent, so recordings of floating panels can sit over any page
+// background. It must be called before frames are added.
+func (e *GIFEncoder) SetChromaKey(key color.RGBA, tolerance uint8) {
+	e.chromaKey = &key
+	e.keyTol = tolerance
+}
+
+// SetAlphaTransparency makes pixels that are less than half opaque, such as
+// the corners and shadow of a captured window, transparent. It must be
+// called before frames are added.
+func (e *GIFEncoder) SetAlphaTransparency(enabled bool) {
+	e.alpha = enabled
+}
+
+// transparent reports whether any pixels may be made transparent
+func (e *GIFEncoder) transparent() bool {
+	return e.chromaKey != nil || e.alpha
+}
+
+// isTransparent reports whether a pixel should be made transparent
+func (e *GIFEncoder) isTransparent(c color.RGBA) bool {
+	if e.alpha && c.A < 128 {
+		return true
+	}
+	return e.chromaKey != nil && analyze.Similar(c, *e.chromaKey, e.keyTol)
+}
+
+// Activity reports which parts of the buffered frames change
+func (e *GIFEncoder) Activity() analyze.Activity {
+	return analyze.DetectActivity(images(e.frames), analyze.DefaultTolerance)
+}
+
+// Loop finds the frames that loop most seamlessly, counted in the order
+// they were added
+func (e *GIFEncoder) Loop() (analyze.Loop, bool) {
+	return analyze.FindLoop(images(e.frames), analyze.DefaultTolerance)
+}
+
+// frameDelays returns a copy of the per-frame delays d in output order with
+// first/last holds applied
+func (e *GIFEncoder) frameDelays(d []int) []int {
+	delays := make([]int, len(d))
+	copy(delays, d)
+	if e.reverse {
+		delays = reversed(delays)
+	}
+
+	if len(delays) > 0 {
+		delays[0] += e.holdFirst
+		delays[len(delays)-1] += e.holdLast
+	}
+
+	return delays
+}
+
+// reversed returns a reversed copy of s
+func reversed[T any](s []T) []T {
+	out := make([]T, len(s))
+	for i, v := range s {
+		out[len(s)-1-i] = v
+	}
+	return out
+}
+
+// cropBorders returns frames with their common static border removed
+func cropBorders(frames []*image.Paletted) []*image.Paletted {
+	return cropAll(frames, analyze.BorderCrop(images(frames), analyze.DefaultTolerance))
+}
+
+// cropToActivity returns frames cropped to the area that changes when most
+// of the frame is static
+func cropToActivity(frames []*image.Paletted) []*image.Paletted {
+	activity := analyze.DetectActivity(images(frames), analyze.DefaultTolerance)
+	if !activity.MostlyStatic() {
+		return frames
+	}
+	return cropAll(frames, activity.Suggest(frames[0].Bounds(), analyze.DefaultPadding))
+}
+
+// cropAll returns copies of frames cropped to r, or frames itself when r
+// covers them entirely
+func cropAll(frames []*image.Paletted, r image.Rectangle) []*image.Paletted {
+	if r == frames[0].Bounds() {
+		return frames
+	}
+
+	out := make([]*image.Paletted, len(frames))
+	for i, f := range frames {
+		out[i] = analyze.CropPaletted(f, r)
+	}
+	return out
+}
+
+// images converts paletted frames for use with the analyze package
+func images(frames []*image.Paletted) []image.Image {
+	out := make([]image.Image, len(frames))
+	for i, f := range frames {
+		out[i] = f
+	}
+	return out
+}
+
+// durationToDelay converts a duration to GIF delay units (100ths of a second)
+func durationToDelay(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + 5*time.Millisecond) / (10 * time.Millisecond))
+}
+
 // FrameCount returns the number of frames currently buffered
 func (e *GIFEncoder) FrameCount() int {
 	return len(e.frames)
 }
 
+// Bounds returns the size of the buffered frames, or an empty rectangle
+// before the first frame is added
+func (e *GIFEncoder) Bounds() image.Rectangle {
+	if len(e.frames) == 0 {
+		return image.Rectangle{}
+	}
+	return e.frames[0].Bounds()
+}
+
 // convertToPaletted converts an RGBA image to a paletted image
 func (e *GIFEncoder) convertToPaletted(img *image.RGBA) *image.Paletted {
+	if e.palette == nil && e.colors > 0 {
+		e.palette = AdaptivePalette(img, e.colors)
+	}
+
 	bounds := img.Bounds()
+	if e.transparent() {
+		return e.convertWithTransparency(img)
+	}
+
 	palettedImg := image.NewPaletted(bounds, e.getPalette())
 
 	// Draw the RGBA image onto the paletted image
 	// This will automatically handle color quantization
-	draw.FloydSteinberg.Draw(palettedImg, bounds, img, image.Point{})
+	e.drawer().Draw(palettedImg, bounds, img, image.Point{})
 
 	return palettedImg
 }
 
-// getPalette returns the color palette based on quality setting
+// convertWithTransparency converts img using the palette plus a transparent
+// entry, which replaces the last color if the palette is already full
+func (e *GIFEncoder) convertWithTransparency(img *image.RGBA) *image.Paletted {
+	bounds := img.Bounds()
+	opaque := e.getPalette()
+	if len(opaque) >= MaxColors {
+		opaque = opaque[:MaxColors-1]
+	}
+	clearIndex := uint8(len(opaque))
+
+	full := make(color.Palette, 0, len(opaque)+1)
+	full = append(full, opaque...)
+	full = append(full, color.RGBA{})
+	palettedImg := image.NewPaletted(bounds, full)
+
+	// Dither with only the opaque colors so no visible pixel is mapped to
+	// the transparent entry
+	dither := *palettedImg
+	dither.Palette = opaque
+	e.drawer().Draw(&dither, bounds, img, bounds.Min)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if e.isTransparent(img.RGBAAt(x, y)) {
+				palettedImg.SetColorIndex(x, y, clearIndex)
+			}
+		}
+	}
+
+	return palettedImg
+}
+
+// drawer returns the drawer that maps colors to the palette
+func (e *GIFEncoder) drawer() draw.Drawer {
+	if e.noDither {
+		return draw.Src
+	}
+	return draw.FloydSteinberg
+}
+
+// getPalette returns the custom palette if one is set, otherwise the
+// palette for the quality setting
 func (e *GIFEncoder) getPalette() color.Palette {
+	if e.palette != nil {
+		return e.palette
+	}
+
 	switch e.quality {
 	case QualityLow:
 		// Use a reduced palette (64 colors) for smaller file size
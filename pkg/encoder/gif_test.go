@@ -1,17 +1,21 @@
 package encoder
 
 import (
+	"bytes"
 	"image"
 	"image/color"
+	"image/gif"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/ericmhalvorsen/witness/pkg/capture"
 )
 
-// Helper function to create a test frame with a solid color
+// Helper function to create a test frame with a solid color. Frames have
+// no timestamp, so they are shown for the encoder's nominal delay.
 func createTestFrame(width, height int, c color.Color) *capture.Frame {
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
 
@@ -22,10 +26,7 @@ func createTestFrame(width, height int, c color.Color) *capture.Frame {
 		}
 	}
 
-	return &capture.Frame{
-		Image:     img,
-		Timestamp: time.Now(),
-	}
+	return &capture.Frame{Image: img}
 }
 
 // Helper function to create a test frame with a gradient pattern
@@ -42,18 +43,15 @@ func createGradientFrame(width, height int) *capture.Frame {
 		}
 	}
 
-	return &capture.Frame{
-		Image:     img,
-		Timestamp: time.Now(),
-	}
+	return &capture.Frame{Image: img}
 }
 
 func TestNewGIFEncoder(t *testing.T) {
 	tests := []struct {
-		name        string
-		fps         int
-		quality     GIFQuality
-		wantDelay   int
+		name      string
+		fps       int
+		quality   GIFQuality
+		wantDelay int
 	}{
 		{
 			name:      "15 FPS medium quality",
@@ -101,6 +99,44 @@ func TestNewGIFEncoder(t *testing.T) {
 	}
 }
 
+func TestNewGIFEncoderFPS(t *testing.T) {
+	tests := []struct {
+		name      string
+		fps       capture.FPS
+		wantDelay int
+	}{
+		{
+			name:      "23.976 FPS",
+			fps:       capture.FPS23976,
+			wantDelay: 4, // 100*1001/24000 = 4.17
+		},
+		{
+			name:      "29.97 FPS",
+			fps:       capture.FPS2997,
+			wantDelay: 3, // 100*1001/30000 = 3.34
+		},
+		{
+			name:      "half FPS",
+			fps:       capture.NewFPS(1, 2),
+			wantDelay: 200,
+		},
+		{
+			name:      "invalid FPS uses minimum delay",
+			fps:       capture.FPS{},
+			wantDelay: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder := NewGIFEncoderFPS("test.gif", tt.fps, QualityMedium)
+			if encoder.delay != tt.wantDelay {
+				t.Errorf("delay = %v, want %v", encoder.delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
 func TestAddFrame(t *testing.T) {
 	encoder := NewGIFEncoder("test.gif", 15, QualityMedium)
 
@@ -403,3 +439,468 @@ func TestEncodeInvalidPath(t *testing.T) {
 		t.Error("Encode() should fail for invalid output path")
 	}
 }
+
+func TestHoldFirstAndLast(t *testing.T) {
+	tests := []struct {
+		name       string
+		frames     int
+		holdFirst  time.Duration
+		holdLast   time.Duration
+		wantDelays []int
+	}{
+		{
+			name:       "no holds",
+			frames:     3,
+			wantDelays: []int{10, 10, 10},
+		},
+		{
+			name:       "hold first and last",
+			frames:     3,
+			holdFirst:  time.Second,
+			holdLast:   2 * time.Second,
+			wantDelays: []int{110, 10, 210},
+		},
+		{
+			name:       "single frame gets both holds",
+			frames:     1,
+			holdFirst:  500 * time.Millisecond,
+			holdLast:   250 * time.Millisecond,
+			wantDelays: []int{85},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			outputPath := filepath.Join(tmpDir, "hold.gif")
+
+			encoder := NewGIFEncoder(outputPath, 10, QualityLow)
+			encoder.SetHoldFirst(tt.holdFirst)
+			encoder.SetHoldLast(tt.holdLast)
+
+			for i := 0; i < tt.frames; i++ {
+				encoder.AddFrame(createTestFrame(10, 10, color.RGBA{R: 255, A: 255}))
+			}
+
+			if err := encoder.Encode(); err != nil {
+				t.Fatalf("Encode() failed: %v", err)
+			}
+
+			f, err := os.Open(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to open output: %v", err)
+			}
+			defer f.Close()
+
+			decoded, err := gif.DecodeAll(f)
+			if err != nil {
+				t.Fatalf("Failed to decode GIF: %v", err)
+			}
+
+			if len(decoded.Delay) != len(tt.wantDelays) {
+				t.Fatalf("frame count = %d, want %d", len(decoded.Delay), len(tt.wantDelays))
+			}
+			for i, want := range tt.wantDelays {
+				if decoded.Delay[i] != want {
+					t.Errorf("Delay[%d] = %d, want %d", i, decoded.Delay[i], want)
+				}
+			}
+
+			// Holds are applied at encode time, not stored per frame
+			if encoder.delays[0] != 10 {
+				t.Errorf("stored delay = %d, want 10", encoder.delays[0])
+			}
+		})
+	}
+}
+
+func TestCaptureTimestampDelays(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "timing.gif")
+	encoder := NewGIFEncoderFPS(outputPath, capture.FPS30, QualityMedium)
+
+	// Evenly captured at 30fps, then late, then a duplicate timestamp, then
+	// a pause of a minute
+	at := []time.Duration{0, 33333 * time.Microsecond, 66667 * time.Microsecond, 100 * time.Millisecond,
+		250 * time.Millisecond, 250 * time.Millisecond, time.Minute}
+	for i, d := range at {
+		frame := createTestFrame(4, 4, color.White)
+		frame.Timestamp = capture.Epoch.Add(d)
+		frame.Discontinuity = i == 6
+		if err := encoder.AddFrame(frame); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+
+	// 30fps does not divide into whole 100ths of a second, so delays vary
+	// to keep the total in step with the capture
+	want := []int{3, 4, 3, 15, 3, 4, 3}
+	if got := encoder.frameDelays(encoder.delays); !reflect.DeepEqual(got, want) {
+		t.Errorf("delays = %v, want %v", got, want)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "reverse.gif")
+
+	encoder := NewGIFEncoder(outputPath, 10, QualityMedium)
+	encoder.SetReverse(true)
+	encoder.SetHoldLast(time.Second)
+
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	encoder.AddFrame(createTestFrame(10, 10, red))
+	encoder.AddFrame(createTestFrame(10, 10, blue))
+
+	if err := encoder.Encode(); err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer f.Close()
+
+	decoded, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatalf("Failed to decode GIF: %v", err)
+	}
+
+	first := color.RGBAModel.Convert(decoded.Image[0].At(0, 0))
+	last := color.RGBAModel.Convert(decoded.Image[1].At(0, 0))
+	if first != blue || last != red {
+		t.Errorf("frame colors = %v, %v; want blue then red", first, last)
+	}
+
+	// The hold applies to the last frame of the output, not the input
+	if decoded.Delay[0] != 10 || decoded.Delay[1] != 110 {
+		t.Errorf("delays = %v, want [10 110]", decoded.Delay)
+	}
+}
+
+func TestAutoLoop(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "loop.gif")
+
+	encoder := NewGIFEncoder(outputPath, 10, QualityMedium)
+	encoder.SetAutoLoop(true)
+
+	// An intro frame, then red, green, blue cycling back to red
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	red := color.RGBA{R: 255, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	for _, c := range []color.RGBA{white, red, green, blue, red} {
+		encoder.AddFrame(createTestFrame(10, 10, c))
+	}
+
+	if err := encoder.Encode(); err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer f.Close()
+
+	decoded, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatalf("Failed to decode GIF: %v", err)
+	}
+
+	if len(decoded.Image) != 3 || len(decoded.Delay) != 3 {
+		t.Fatalf("frames = %d, want 3", len(decoded.Image))
+	}
+	if first := color.RGBAModel.Convert(decoded.Image[0].At(0, 0)); first != red {
+		t.Errorf("first frame = %v, want red", first)
+	}
+}
+
+func TestAutoCrop(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "autocrop.gif")
+
+	encoder := NewGIFEncoder(outputPath, 10, QualityMedium)
+	encoder.SetAutoCrop(true)
+
+	// A window that changes color on a static black desktop
+	black := color.RGBA{A: 255}
+	for _, c := range []color.RGBA{{R: 255, A: 255}, {B: 255, A: 255}} {
+		frame := createTestFrame(20, 16, black)
+		for y := 4; y < 12; y++ {
+			for x := 3; x < 13; x++ {
+				frame.Image.SetRGBA(x, y, c)
+			}
+		}
+		encoder.AddFrame(frame)
+	}
+
+	if err := encoder.Encode(); err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer f.Close()
+
+	decoded, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatalf("Failed to decode GIF: %v", err)
+	}
+
+	if decoded.Config.Width != 10 || decoded.Config.Height != 8 {
+		t.Errorf("size = %dx%d, want 10x8", decoded.Config.Width, decoded.Config.Height)
+	}
+	if got := decoded.Image[0].Bounds(); got != image.Rect(0, 0, 10, 8) {
+		t.Errorf("frame bounds = %v, want (0,0)-(10,8)", got)
+	}
+}
+
+func TestAutoRegion(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "autoregion.gif")
+
+	encoder := NewGIFEncoder(outputPath, 10, QualityMedium)
+	encoder.SetAutoRegion(true)
+
+	// Only a small square in a large frame changes
+	black := color.RGBA{A: 255}
+	for _, c := range []color.RGBA{{R: 255, A: 255}, {B: 255, A: 255}} {
+		frame := createTestFrame(100, 100, black)
+		for y := 40; y < 50; y++ {
+			for x := 40; x < 50; x++ {
+				frame.Image.SetRGBA(x, y, c)
+			}
+		}
+		encoder.AddFrame(frame)
+	}
+
+	if !encoder.Activity().MostlyStatic() {
+		t.Fatal("Activity() should report a mostly static recording")
+	}
+
+	if err := encoder.Encode(); err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer f.Close()
+
+	config, err := gif.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("Failed to decode GIF: %v", err)
+	}
+
+	// The 10x10 active area plus default padding on each side
+	if config.Width != 42 || config.Height != 42 {
+		t.Errorf("size = %dx%d, want 42x42", config.Width, config.Height)
+	}
+}
+
+func TestEncodeTo(t *testing.T) {
+	encoder := NewGIFEncoder("", 10, QualityMedium)
+	encoder.AddFrame(createTestFrame(8, 8, color.RGBA{R: 255, A: 255}))
+	encoder.AddFrame(createTestFrame(8, 8, color.RGBA{G: 255, A: 255}))
+
+	var buf bytes.Buffer
+	if err := encoder.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo() failed: %v", err)
+	}
+
+	decoded, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode GIF: %v", err)
+	}
+	if len(decoded.Image) != 2 {
+		t.Errorf("decoded %d frames, want 2", len(decoded.Image))
+	}
+}
+
+func TestEncodeToNoFrames(t *testing.T) {
+	encoder := NewGIFEncoder("", 10, QualityMedium)
+	if err := encoder.EncodeTo(&bytes.Buffer{}); err == nil {
+		t.Error("EncodeTo() should fail with no frames")
+	}
+}
+
+func TestParseQuality(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    GIFQuality
+		wantErr bool
+	}{
+		{in: "low", want: QualityLow},
+		{in: "medium", want: QualityMedium},
+		{in: "HIGH", want: QualityHigh},
+		{in: "ultra", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseQuality(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseQuality(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseQuality(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQualities(t *testing.T) {
+	qualities := Qualities()
+	if len(qualities) != 3 {
+		t.Fatalf("Qualities() returned %d levels, want 3", len(qualities))
+	}
+
+	for i, info := range qualities {
+		if i > 0 && info.Quality <= qualities[i-1].Quality {
+			t.Errorf("Qualities() not ordered from low to high: %v after %v", info.Quality, qualities[i-1].Quality)
+		}
+		if info.GIF == "" || info.Video == "" {
+			t.Errorf("quality %s is missing a description", info.Name)
+		}
+
+		// Every listed name parses back to its level
+		got, err := ParseQuality(info.Name)
+		if err != nil || got != info.Quality {
+			t.Errorf("ParseQuality(%q) = %v, %v, want %v", info.Name, got, err, info.Quality)
+		}
+		if info.Quality.String() != info.Name {
+			t.Errorf("String() = %q, want %q", info.Quality.String(), info.Name)
+		}
+	}
+
+	_, err := ParseQuality("ultra")
+	if err == nil || err.Error() != `invalid quality "ultra": want low, medium, or high` {
+		t.Errorf("ParseQuality(\"ultra\") error = %v", err)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	encoder := NewGIFEncoder("", 10, QualityMedium)
+	if !encoder.Bounds().Empty() {
+		t.Errorf("Bounds() = %v before any frames, want empty", encoder.Bounds())
+	}
+
+	if err := encoder.AddFrame(createTestFrame(30, 20, color.White)); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if got := encoder.Bounds(); got != image.Rect(0, 0, 30, 20) {
+		t.Errorf("Bounds() = %v, want 30x20", got)
+	}
+}
+
+// Helper function to create a frame with a green background and a red panel
+func createPanelFrame() *capture.Frame {
+	frame := createTestFrame(20, 20, color.RGBA{G: 255, A: 255})
+	for y := 5; y < 15; y++ {
+		for x := 5; x < 15; x++ {
+			frame.Image.SetRGBA(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	return frame
+}
+
+func TestChromaKey(t *testing.T) {
+	encoder := NewGIFEncoder("", 10, QualityMedium)
+	encoder.SetChromaKey(color.RGBA{G: 255, A: 255}, 8)
+
+	for i := 0; i < 2; i++ {
+		if err := encoder.AddFrame(createPanelFrame()); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := encoder.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo() failed: %v", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode GIF: %v", err)
+	}
+
+	frame := anim.Image[1]
+	if _, _, _, a := frame.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("background alpha = %d, want transparent", a)
+	}
+	if _, _, _, a := frame.At(10, 10).RGBA(); a == 0 {
+		t.Error("panel pixel is transparent, want opaque")
+	}
+	for i, d := range anim.Disposal {
+		if d != gif.DisposalBackground {
+			t.Errorf("frame %d disposal = %d, want DisposalBackground", i, d)
+		}
+	}
+}
+
+func TestAlphaTransparency(t *testing.T) {
+	frame := createTestFrame(10, 10, color.RGBA{B: 255, A: 255})
+	frame.Image.SetRGBA(0, 0, color.RGBA{})
+
+	encoder := NewGIFEncoder("", 10, QualityHigh)
+	encoder.SetAlphaTransparency(true)
+	if err := encoder.AddFrame(frame); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+
+	p := encoder.frames[0]
+	if _, _, _, a := p.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("clear pixel alpha = %d, want transparent", a)
+	}
+	if _, _, _, a := p.At(5, 5).RGBA(); a == 0 {
+		t.Error("opaque pixel became transparent")
+	}
+}
+
+func TestTransparencyWithFullPalette(t *testing.T) {
+	encoder := NewGIFEncoder("", 10, QualityMedium)
+	encoder.SetChromaKey(color.RGBA{G: 255, A: 255}, 0)
+	if err := encoder.AddFrame(createPanelFrame()); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+
+	if got := len(encoder.frames[0].Palette); got > MaxColors {
+		t.Errorf("palette has %d colors, GIF allows %d", got, MaxColors)
+	}
+}
+
+func TestDither(t *testing.T) {
+	// A gradient that falls between palette colors
+	img := image.NewRGBA(image.Rect(0, 0, 32, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{R: 100 + uint8(x), G: 100, B: 100, A: 255})
+		}
+	}
+
+	countColors := func(p *image.Paletted) int {
+		seen := map[uint8]bool{}
+		for _, i := range p.Pix {
+			seen[i] = true
+		}
+		return len(seen)
+	}
+
+	encoder := NewGIFEncoder("test.gif", 15, QualityLow)
+	dithered := countColors(encoder.convertToPaletted(img))
+
+	encoder.SetDither(false)
+	plain := encoder.convertToPaletted(img)
+	if got := countColors(plain); got >= dithered {
+		t.Errorf("undithered frame uses %d colors, want fewer than dithered %d", got, dithered)
+	}
+
+	// Every pixel maps to its nearest palette color
+	p := encoder.getPalette()
+	for x := 0; x < 32; x++ {
+		want := p.Index(img.At(x, 0))
+		if got := int(plain.ColorIndexAt(x, 0)); got != want {
+			t.Fatalf("pixel %d index = %d, want nearest %d", x, got, want)
+		}
+	}
+}
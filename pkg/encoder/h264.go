@@ -0,0 +1,124 @@
+package encoder
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// H264Profile selects the H.264 feature set, trading compression for
+// compatibility with older decoders
+type H264Profile int
+
+const (
+	// ProfileHigh compresses best and plays on any device from the last
+	// decade
+	ProfileHigh H264Profile = iota
+	// ProfileMain plays on older hardware decoders such as early phones
+	ProfileMain
+	// ProfileBaseline plays everywhere, at larger file sizes
+	ProfileBaseline
+)
+
+// String returns the profile's name
+func (p H264Profile) String() string {
+	switch p {
+	case ProfileHigh:
+		return "high"
+	case ProfileMain:
+		return "main"
+	case ProfileBaseline:
+		return "baseline"
+	default:
+		return fmt.Sprintf("H264Profile(%d)", int(p))
+	}
+}
+
+// ParseH264Profile parses a profile name (high, main, or baseline)
+func ParseH264Profile(s string) (H264Profile, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "high", "":
+		return ProfileHigh, nil
+	case "main":
+		return ProfileMain, nil
+	case "baseline":
+		return ProfileBaseline, nil
+	default:
+		return 0, fmt.Errorf("invalid profile %q: want high, main, or baseline", s)
+	}
+}
+
+// ParseBitrate parses a bitrate in bits per second, with an optional k or M
+// suffix, e.g. 800k or 2.5M
+func ParseBitrate(s string) (int, error) {
+	num := strings.TrimSpace(s)
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(num, "k"), strings.HasSuffix(num, "K"):
+		scale, num = 1e3, num[:len(num)-1]
+	case strings.HasSuffix(num, "M"), strings.HasSuffix(num, "m"):
+		scale, num = 1e6, num[:len(num)-1]
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid bitrate %q: want bits per second, e.g. 800k or 4M", s)
+	}
+	return int(v * scale), nil
+}
+
+// NativeVideo reports whether MP4s can be encoded without ffmpeg, as they
+// can on macOS with VideoToolbox
+func NativeVideo() bool {
+	return newH264Session != nil
+}
+
+// h264Settings configures a native H.264 session
+type h264Settings struct {
+	width, height    int
+	fps              capture.FPS
+	bitrate          int
+	keyframeInterval int
+	profile          H264Profile
+}
+
+// h264Sample is one encoded frame: NAL units with 4-byte length prefixes
+type h264Sample struct {
+	data     []byte
+	pts      time.Duration
+	keyframe bool
+}
+
+// h264Session encodes frames to H.264 without ffmpeg. Frames may be encoded
+// asynchronously; samples returns those that are done, in presentation
+// order.
+type h264Session interface {
+	encode(img *image.RGBA, pts time.Duration) error
+	flush() error
+	samples() []h264Sample
+	parameterSets() (sps, pps []byte)
+	close()
+}
+
+// bitsPerPixel is the bitrate budget per pixel per frame at each quality
+// level when no bitrate is set. Screen content is mostly static, so this is
+// well below what camera footage needs.
+func bitsPerPixel(q GIFQuality) float64 {
+	switch q {
+	case QualityLow:
+		return 0.05
+	case QualityHigh:
+		return 0.2
+	default:
+		return 0.1
+	}
+}
+
+// defaultBitrate picks a bitrate for the frame size and rate at a quality
+// level
+func defaultBitrate(q GIFQuality, width, height int, fps capture.FPS) int {
+	return int(bitsPerPixel(q) * float64(width*height) * fps.Float64())
+}
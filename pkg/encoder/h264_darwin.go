@@ -0,0 +1,64 @@
+//go:build darwin
+// +build darwin
+
+package encoder
+
+import (
+	"image"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/internal/macos"
+)
+
+// newH264Session encodes with VideoToolbox, so MP4s need no ffmpeg on macOS
+var newH264Session = func(s h264Settings) (h264Session, error) {
+	profile := macos.H264ProfileHigh
+	switch s.profile {
+	case ProfileMain:
+		profile = macos.H264ProfileMain
+	case ProfileBaseline:
+		profile = macos.H264ProfileBaseline
+	}
+
+	enc, err := macos.NewH264Encoder(macos.H264Options{
+		Width:            s.width,
+		Height:           s.height,
+		FPS:              s.fps.Float64(),
+		Bitrate:          s.bitrate,
+		KeyframeInterval: s.keyframeInterval,
+		Profile:          profile,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return videoToolboxSession{enc}, nil
+}
+
+// videoToolboxSession adapts macos.H264Encoder to h264Session
+type videoToolboxSession struct {
+	enc *macos.H264Encoder
+}
+
+func (v videoToolboxSession) encode(img *image.RGBA, pts time.Duration) error {
+	return v.enc.Encode(img, pts)
+}
+
+func (v videoToolboxSession) flush() error {
+	return v.enc.Flush()
+}
+
+func (v videoToolboxSession) samples() []h264Sample {
+	var samples []h264Sample
+	for _, s := range v.enc.Samples() {
+		samples = append(samples, h264Sample{data: s.Data, pts: s.PTS, keyframe: s.Keyframe})
+	}
+	return samples
+}
+
+func (v videoToolboxSession) parameterSets() (sps, pps []byte) {
+	return v.enc.ParameterSets()
+}
+
+func (v videoToolboxSession) close() {
+	v.enc.Close()
+}
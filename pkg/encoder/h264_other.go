@@ -0,0 +1,8 @@
+//go:build !darwin
+// +build !darwin
+
+package encoder
+
+// newH264Session is nil where there is no native H.264 encoder, so MP4s are
+// encoded with ffmpeg
+var newH264Session func(h264Settings) (h264Session, error)
@@ -0,0 +1,78 @@
+package encoder
+
+import (
+	"testing"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestParseH264Profile(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    H264Profile
+		wantErr bool
+	}{
+		{"high", ProfileHigh, false},
+		{"", ProfileHigh, false},
+		{"Main", ProfileMain, false},
+		{" baseline ", ProfileBaseline, false},
+		{"extended", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseH264Profile(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseH264Profile(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseH264Profile(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			if !tt.wantErr && tt.input != "" {
+				if round, _ := ParseH264Profile(got.String()); round != got {
+					t.Errorf("String() = %q does not parse back", got.String())
+				}
+			}
+		})
+	}
+}
+
+func TestParseBitrate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"800000", 800000, false},
+		{"800k", 800000, false},
+		{"4M", 4000000, false},
+		{"2.5M", 2500000, false},
+		{"fast", 0, true},
+		{"0", 0, true},
+		{"-1M", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseBitrate(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBitrate(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBitrate(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultBitrate(t *testing.T) {
+	low := defaultBitrate(QualityLow, 1920, 1080, capture.FPS30)
+	medium := defaultBitrate(QualityMedium, 1920, 1080, capture.FPS30)
+	high := defaultBitrate(QualityHigh, 1920, 1080, capture.FPS30)
+	if !(low < medium && medium < high) {
+		t.Errorf("bitrate should rise with quality: low=%d medium=%d high=%d", low, medium, high)
+	}
+	if medium != 6220800 {
+		t.Errorf("medium 1080p30 bitrate = %d, want 6220800", medium)
+	}
+}
@@ -0,0 +1,165 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+)
+
+// GIF block markers
+const (
+	gifExtension  = 0x21
+	gifImage      = 0x2C
+	gifTrailer    = 0x3B
+	gifInterlaced = 0x40 // image descriptor flag
+	gifColorTable = 0x80 // color table present flag
+)
+
+// interlacePasses lists the starting row and step of each GIF interlace pass
+var interlacePasses = []struct{ start, step int }{
+	{0, 8},
+	{4, 8},
+	{2, 4},
+	{1, 2},
+}
+
+// encodeInterlaced writes anim with every frame interlaced, so large GIFs
+// render progressively on slow connections. image/gif cannot write
+// interlaced frames, so rows are stored in interlace order and the flag is
+// set in the encoded image descriptors.
+func encodeInterlaced(w io.Writer, anim *gif.GIF) error {
+	interlaced := *anim
+	interlaced.Image = make([]*image.Paletted, len(anim.Image))
+	for i, frame := range anim.Image {
+		interlaced.Image[i] = interlaceRows(frame)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &interlaced); err != nil {
+		return err
+	}
+	if err := setInterlaceFlags(buf.Bytes()); err != nil {
+		return err
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// interlaceRows returns a copy of p with its rows in interlace order
+func interlaceRows(p *image.Paletted) *image.Paletted {
+	b := p.Bounds()
+	out := image.NewPaletted(b, p.Palette)
+	width := b.Dx()
+
+	dst := 0
+	for _, pass := range interlacePasses {
+		for y := pass.start; y < b.Dy(); y += pass.step {
+			src := p.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(out.Pix[dst*out.Stride:dst*out.Stride+width], p.Pix[src:src+width])
+			dst++
+		}
+	}
+	return out
+}
+
+// deinterlaceRows returns a copy of p, whose rows are in interlace order,
+// with its rows back in top to bottom order
+func deinterlaceRows(p *image.Paletted) *image.Paletted {
+	b := p.Bounds()
+	out := image.NewPaletted(b, p.Palette)
+	width := b.Dx()
+
+	src := 0
+	for _, pass := range interlacePasses {
+		for y := pass.start; y < b.Dy(); y += pass.step {
+			from := p.PixOffset(b.Min.X, b.Min.Y+src)
+			copy(out.Pix[y*out.Stride:y*out.Stride+width], p.Pix[from:from+width])
+			src++
+		}
+	}
+	return out
+}
+
+// setInterlaceFlags sets the interlace flag on every image descriptor in
+// an encoded GIF
+func setInterlaceFlags(data []byte) error {
+	return walkImages(data, func(flags int) {
+		data[flags] |= gifInterlaced
+	})
+}
+
+// walkImages calls fn with the offset of each image descriptor's flags
+// byte in an encoded GIF
+func walkImages(data []byte, fn func(flags int)) error {
+	// Header and logical screen descriptor
+	if len(data) < 13 {
+		return fmt.Errorf("GIF too short")
+	}
+	pos := 13
+	if data[10]&gifColorTable != 0 {
+		pos += colorTableSize(data[10])
+	}
+
+	for pos < len(data) {
+		switch data[pos] {
+		case gifTrailer:
+			return nil
+
+		case gifExtension:
+			// Introducer, label, then sub-blocks
+			end, err := skipSubBlocks(data, pos+2)
+			if err != nil {
+				return err
+			}
+			pos = end
+
+		case gifImage:
+			// Separator, position, size, then the flags byte
+			if pos+10 > len(data) {
+				return fmt.Errorf("truncated image descriptor")
+			}
+			flags := pos + 9
+			fn(flags)
+			pos += 10
+			if data[flags]&gifColorTable != 0 {
+				pos += colorTableSize(data[flags])
+			}
+
+			// LZW minimum code size, then sub-blocks
+			end, err := skipSubBlocks(data, pos+1)
+			if err != nil {
+				return err
+			}
+			pos = end
+
+		default:
+			return fmt.Errorf("unexpected GIF block 0x%02x at offset %d", data[pos], pos)
+		}
+	}
+
+	return fmt.Errorf("GIF has no trailer")
+}
+
+// colorTableSize returns the size in bytes of the color table described by
+// a flags byte
+func colorTableSize(flags byte) int {
+	return 3 << (flags&0x07 + 1)
+}
+
+// skipSubBlocks returns the offset just past the data sub-blocks at pos
+func skipSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, fmt.Errorf("truncated GIF data")
+		}
+		n := int(data[pos])
+		pos++
+		if n == 0 {
+			return pos, nil
+		}
+		pos += n
+	}
+}
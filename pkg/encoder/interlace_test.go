@@ -0,0 +1,107 @@
+package encoder
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestInterlaceRows(t *testing.T) {
+	p := image.NewPaletted(image.Rect(0, 0, 1, 10), paletteOf(10))
+	for y := 0; y < 10; y++ {
+		p.SetColorIndex(0, y, uint8(y))
+	}
+
+	got := interlaceRows(p).Pix
+	want := []uint8{0, 8, 4, 2, 6, 1, 3, 5, 7, 9}
+	if !bytes.Equal(got, want) {
+		t.Errorf("interlaceRows() order = %v, want %v", got, want)
+	}
+}
+
+func TestDeinterlaceRows(t *testing.T) {
+	p := image.NewPaletted(image.Rect(0, 0, 3, 13), paletteOf(13))
+	for y := 0; y < 13; y++ {
+		for x := 0; x < 3; x++ {
+			p.SetColorIndex(x, y, uint8(y))
+		}
+	}
+
+	if got := deinterlaceRows(interlaceRows(p)); !bytes.Equal(got.Pix, p.Pix) {
+		t.Errorf("deinterlaceRows(interlaceRows()) = %v, want %v", got.Pix, p.Pix)
+	}
+}
+
+func TestSetInterlace(t *testing.T) {
+	encoder := NewGIFEncoder("", 10, QualityMedium)
+	encoder.SetInterlace(true)
+	frames := []*image.Paletted{}
+	for i := 0; i < 2; i++ {
+		if err := encoder.AddFrame(createGradientFrame(23, 17)); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+		frames = append(frames, encoder.frames[i])
+	}
+
+	var buf bytes.Buffer
+	if err := encoder.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo() failed: %v", err)
+	}
+	data := append([]byte(nil), buf.Bytes()...)
+
+	// The decoder de-interlaces, so the frames should round trip unchanged
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode GIF: %v", err)
+	}
+	for i, frame := range anim.Image {
+		if !bytes.Equal(frame.Pix, frames[i].Pix) {
+			t.Errorf("frame %d pixels changed after interlacing", i)
+		}
+	}
+
+	// Every image descriptor must have the interlace flag
+	descriptors := 0
+	err = walkImages(data, func(flags int) {
+		descriptors++
+		if data[flags]&gifInterlaced == 0 {
+			t.Errorf("image %d is not marked interlaced", descriptors)
+		}
+	})
+	if err != nil {
+		t.Fatalf("walkImages() failed: %v", err)
+	}
+	if descriptors != 2 {
+		t.Errorf("found %d image descriptors, want 2", descriptors)
+	}
+}
+
+func TestSetInterlaceFlagsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too short", []byte("GIF89a")},
+		{"no trailer", append([]byte("GIF89a"), 1, 0, 1, 0, 0, 0, 0)},
+		{"unknown block", append([]byte("GIF89a"), 1, 0, 1, 0, 0, 0, 0, 0x99)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := setInterlaceFlags(tt.data); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// Helper function to create a grayscale palette with n entries
+func paletteOf(n int) color.Palette {
+	p := make(color.Palette, n)
+	for i := range p {
+		p[i] = color.Gray{Y: uint8(i * 255 / n)}
+	}
+	return p
+}
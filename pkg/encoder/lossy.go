@@ -0,0 +1,159 @@
+package encoder
+
+import (
+	"image"
+	"image/color"
+)
+
+// maxLZWCode is the largest code a GIF LZW dictionary can hold
+const maxLZWCode = 4095
+
+// lossyLZW returns a copy of p in which pixels are nudged to similar
+// palette colors whenever that extends a string already in the LZW
+// dictionary, in the style of gifsicle --lossy. Longer matches mean fewer
+// codes, so the frame compresses much better at the cost of slight
+// artifacts. tolerance is the largest RGB distance between a pixel's color
+// and its replacement; 0 returns p unchanged.
+func lossyLZW(p *image.Paletted, tolerance int) *image.Paletted {
+	if tolerance <= 0 || len(p.Pix) == 0 {
+		return p
+	}
+
+	out := image.NewPaletted(p.Bounds(), p.Palette)
+	b := p.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		copy(out.Pix[(y-b.Min.Y)*out.Stride:], p.Pix[p.PixOffset(b.Min.X, y):p.PixOffset(b.Max.X, y)])
+	}
+
+	dist := paletteDistances(p.Palette, tolerance)
+	dict := newLZWDict(litWidth(len(p.Palette)))
+
+	pix := out.Pix[:b.Dx()*b.Dy()]
+	code := int(pix[0])
+	for i := 1; i < len(pix); i++ {
+		want := pix[i]
+		if next, ok := dict.child(code, want); ok {
+			code = next
+			continue
+		}
+
+		// Extend the current string with the closest acceptable color
+		best, bestCode := -1, 0
+		for _, c := range dict.children[code] {
+			if d := dist[want][c.index]; d >= 0 && (best < 0 || d < best) {
+				best, bestCode = d, c.code
+				pix[i] = c.index
+			}
+		}
+		if best >= 0 {
+			code = bestCode
+			continue
+		}
+
+		dict.add(code, want)
+		code = int(want)
+	}
+
+	return out
+}
+
+// litWidth returns the LZW literal width image/gif uses for a palette
+func litWidth(colors int) int {
+	w := 2
+	for 1<<w < colors {
+		w++
+	}
+	return w
+}
+
+// paletteDistances returns the squared RGB distance between every pair of
+// palette entries, or -1 where the distance exceeds tolerance or only one
+// of the pair is transparent
+func paletteDistances(p color.Palette, tolerance int) [][]int {
+	limit := tolerance * tolerance
+	rgba := make([]color.RGBA, len(p))
+	for i, c := range p {
+		rgba[i] = color.RGBAModel.Convert(c).(color.RGBA)
+	}
+
+	dist := make([][]int, 256)
+	for i := range dist {
+		dist[i] = make([]int, len(p))
+		for j := range dist[i] {
+			dist[i][j] = -1
+			if i >= len(p) {
+				continue
+			}
+			a, b := rgba[i], rgba[j]
+			if (a.A == 0) != (b.A == 0) {
+				continue
+			}
+			dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+			if d := dr*dr + dg*dg + db*db; d <= limit {
+				dist[i][j] = d
+			}
+		}
+	}
+	return dist
+}
+
+// lzwChild is a dictionary entry extending a string by one index
+type lzwChild struct {
+	index uint8
+	code  int
+}
+
+// lzwDict mirrors the dictionary of a GIF LZW encoder
+type lzwDict struct {
+	first    int // first code after the clear and end codes
+	next     int
+	children [][]lzwChild
+}
+
+// newLZWDict creates an empty dictionary for the given literal width
+func newLZWDict(litWidth int) *lzwDict {
+	d := &lzwDict{first: 1<<litWidth + 2}
+	d.reset()
+	return d
+}
+
+// reset clears the dictionary, as the encoder does when it runs out of codes
+func (d *lzwDict) reset() {
+	d.next = d.first
+	d.children = make([][]lzwChild, maxLZWCode+1)
+}
+
+// child returns the code for code's string followed by index
+func (d *lzwDict) child(code int, index uint8) (int, bool) {
+	for _, c := range d.children[code] {
+		if c.index == index {
+			return c.code, true
+		}
+	}
+	return 0, false
+}
+
+// add records code's string followed by index as a new code
+func (d *lzwDict) add(code int, index uint8) {
+	if d.next > maxLZWCode {
+		d.reset()
+		return
+	}
+	d.children[code] = append(d.children[code], lzwChild{index: index, code: d.next})
+	d.next++
+}
+
+// lossyAll applies lossyLZW to every frame. The LZW encoder sees an
+// interlaced frame's rows in interlace order, so when interlaced is set the
+// matches are searched in that order too.
+func lossyAll(frames []*image.Paletted, tolerance int, interlaced bool) []*image.Paletted {
+	out := make([]*image.Paletted, len(frames))
+	for i, f := range frames {
+		if interlaced {
+			out[i] = deinterlaceRows(lossyLZW(interlaceRows(f), tolerance))
+			continue
+		}
+		out[i] = lossyLZW(f, tolerance)
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package encoder
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"math/rand"
+	"testing"
+)
+
+func TestLossyLZWZeroTolerance(t *testing.T) {
+	img := noisyPaletted(32, 32)
+	if got := lossyLZW(img, 0); got != img {
+		t.Error("lossyLZW() with tolerance 0 should return the frame unchanged")
+	}
+}
+
+func TestLossyLZWStaysWithinTolerance(t *testing.T) {
+	tests := []int{40, 80, 120}
+
+	for _, tolerance := range tests {
+		img := noisyPaletted(48, 48)
+		got := lossyLZW(img, tolerance)
+
+		if got.Bounds() != img.Bounds() {
+			t.Fatalf("bounds = %v, want %v", got.Bounds(), img.Bounds())
+		}
+
+		changed := 0
+		for i, idx := range got.Pix {
+			if idx == img.Pix[i] {
+				continue
+			}
+			changed++
+			a := img.Palette[img.Pix[i]].(color.RGBA)
+			b := img.Palette[idx].(color.RGBA)
+			dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+			if dr*dr+dg*dg+db*db > tolerance*tolerance {
+				t.Errorf("tolerance %d: pixel %d changed from %v to %v", tolerance, i, a, b)
+			}
+		}
+		if changed == 0 {
+			t.Errorf("tolerance %d: expected some pixels to be substituted", tolerance)
+		}
+	}
+}
+
+func TestLossyLZWKeepsTransparency(t *testing.T) {
+	p := color.Palette{
+		color.RGBA{R: 10, G: 10, B: 10, A: 255},
+		color.RGBA{R: 12, G: 12, B: 12, A: 255},
+		color.RGBA{},
+	}
+	img := image.NewPaletted(image.Rect(0, 0, 16, 16), p)
+	rng := rand.New(rand.NewSource(1))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.Intn(len(p)))
+	}
+
+	got := lossyLZW(img, 255)
+	for i, idx := range got.Pix {
+		if (idx == 2) != (img.Pix[i] == 2) {
+			t.Fatalf("pixel %d changed transparency: %d -> %d", i, img.Pix[i], idx)
+		}
+	}
+}
+
+func TestLossyLZWSubImage(t *testing.T) {
+	img := noisyPaletted(32, 32).SubImage(image.Rect(4, 6, 20, 30)).(*image.Paletted)
+
+	got := lossyLZW(img, 30)
+	if got.Bounds() != img.Bounds() {
+		t.Errorf("bounds = %v, want %v", got.Bounds(), img.Bounds())
+	}
+}
+
+func TestLossyAllInterlaced(t *testing.T) {
+	img := noisyPaletted(40, 37)
+
+	// Matches must follow the rows in the order the LZW encoder sees them
+	got := lossyAll([]*image.Paletted{img}, 80, true)[0]
+	want := lossyLZW(interlaceRows(img), 80)
+	if !bytes.Equal(interlaceRows(got).Pix, want.Pix) {
+		t.Error("lossyAll() did not search an interlaced frame in interlace order")
+	}
+	if bytes.Equal(got.Pix, lossyAll([]*image.Paletted{img}, 80, false)[0].Pix) {
+		t.Error("lossyAll() gave the same result interlaced and not")
+	}
+}
+
+func TestSetLossy(t *testing.T) {
+	encode := func(lossy int, interlace bool) []byte {
+		encoder := NewGIFEncoder("", 10, QualityHigh)
+		encoder.SetLossy(lossy)
+		encoder.SetInterlace(interlace)
+		for i := 0; i < 3; i++ {
+			if err := encoder.AddFrame(createGradientFrame(64, 64)); err != nil {
+				t.Fatalf("AddFrame() failed: %v", err)
+			}
+		}
+		var buf bytes.Buffer
+		if err := encoder.EncodeTo(&buf); err != nil {
+			t.Fatalf("EncodeTo() failed: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	for _, interlace := range []bool{false, true} {
+		lossless := encode(0, interlace)
+		lossy := encode(80, interlace)
+		if len(lossy) >= len(lossless) {
+			t.Errorf("interlace %v: lossy GIF is %d bytes, want smaller than lossless %d bytes", interlace, len(lossy), len(lossless))
+		}
+		if _, err := gif.DecodeAll(bytes.NewReader(lossy)); err != nil {
+			t.Errorf("interlace %v: failed to decode lossy GIF: %v", interlace, err)
+		}
+	}
+
+	encoder := NewGIFEncoder("", 10, QualityHigh)
+	encoder.SetLossy(-5)
+	if encoder.lossy != 0 {
+		t.Errorf("SetLossy(-5) stored %d, want 0", encoder.lossy)
+	}
+}
+
+// Helper function to create a frame of random Plan 9 palette colors
+func noisyPaletted(width, height int) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, width, height), palette.Plan9)
+	rng := rand.New(rand.NewSource(42))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.Intn(len(palette.Plan9)))
+	}
+	return img
+}
@@ -0,0 +1,124 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"io"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// MJPEGBoundary separates the frames of a multipart MJPEG stream
+const MJPEGBoundary = "witness-frame"
+
+// DefaultJPEGQuality is the JPEG quality of MJPEG frames unless set
+const DefaultJPEGQuality = 80
+
+// MJPEGEncoder streams frames as Motion JPEG. By default each frame is a
+// part of a multipart/x-mixed-replace body, which browsers show as live
+// video when it is served over HTTP with ContentType. Without a boundary
+// the JPEGs are written back to back, as ffmpeg's mjpeg demuxer reads them.
+//
+// Every frame is flushed as soon as it is written when w can be flushed,
+// such as a bufio.Writer or an http.ResponseWriter, to keep latency low.
+type MJPEGEncoder struct {
+	w        io.Writer
+	quality  int
+	boundary string
+	buf      bytes.Buffer
+	frames   int
+}
+
+// NewMJPEGEncoder creates a multipart MJPEG encoder writing to w
+func NewMJPEGEncoder(w io.Writer) *MJPEGEncoder {
+	return &MJPEGEncoder{w: w, quality: DefaultJPEGQuality, boundary: MJPEGBoundary}
+}
+
+// SetQuality sets the JPEG quality, from 1 to 100
+func (e *MJPEGEncoder) SetQuality(q int) error {
+	if q < 1 || q > 100 {
+		return fmt.Errorf("JPEG quality must be between 1 and 100, got %d", q)
+	}
+	e.quality = q
+	return nil
+}
+
+// SetBoundary sets the multipart boundary, or writes bare JPEGs if it is
+// empty. It must be called before frames are added.
+func (e *MJPEGEncoder) SetBoundary(boundary string) {
+	e.boundary = boundary
+}
+
+// ContentType returns the media type of the stream, for an HTTP
+// Content-Type header
+func (e *MJPEGEncoder) ContentType() string {
+	if e.boundary == "" {
+		return "video/x-motion-jpeg"
+	}
+	return "multipart/x-mixed-replace; boundary=" + e.boundary
+}
+
+// AddFrame writes a frame as one JPEG image. Each frame, with its part
+// headers, is written with a single call so frames are not split across
+// writes to a pipe or connection.
+func (e *MJPEGEncoder) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil {
+		return fmt.Errorf("invalid frame")
+	}
+
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, frame.Image, &jpeg.Options{Quality: e.quality}); err != nil {
+		return fmt.Errorf("failed to encode JPEG frame: %w", err)
+	}
+
+	e.buf.Reset()
+	if e.boundary != "" {
+		fmt.Fprintf(&e.buf, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", e.boundary, img.Len())
+	}
+	e.buf.Write(img.Bytes())
+	if e.boundary != "" {
+		e.buf.WriteString("\r\n")
+	}
+	if _, err := e.w.Write(e.buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write JPEG frame: %w", err)
+	}
+	if err := flush(e.w); err != nil {
+		return fmt.Errorf("failed to write JPEG frame: %w", err)
+	}
+
+	e.frames++
+	return nil
+}
+
+// FrameCount returns the number of frames written
+func (e *MJPEGEncoder) FrameCount() int {
+	return e.frames
+}
+
+// Close ends a multipart stream with its closing boundary, so readers know
+// no frames follow. It does not close w.
+func (e *MJPEGEncoder) Close() error {
+	if e.boundary == "" {
+		return nil
+	}
+	if _, err := fmt.Fprintf(e.w, "--%s--\r\n", e.boundary); err != nil {
+		return fmt.Errorf("failed to end MJPEG stream: %w", err)
+	}
+	if err := flush(e.w); err != nil {
+		return fmt.Errorf("failed to end MJPEG stream: %w", err)
+	}
+	return nil
+}
+
+// flush pushes buffered output through w if it can be flushed, either with
+// an error as bufio.Writer does or without as http.Flusher does
+func flush(w io.Writer) error {
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package encoder
+
+import (
+	"bufio"
+	"bytes"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"testing"
+)
+
+// Helper function to check that a decoded JPEG pixel is close to want,
+// since JPEG is lossy
+func nearColor(got color.Color, want color.RGBA) bool {
+	c := color.RGBAModel.Convert(got).(color.RGBA)
+	near := func(a, b uint8) bool { return int(a)-int(b) <= 8 && int(b)-int(a) <= 8 }
+	return near(c.R, want.R) && near(c.G, want.G) && near(c.B, want.B)
+}
+
+func TestMJPEGEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewMJPEGEncoder(&buf)
+
+	colors := []color.RGBA{{R: 255, A: 255}, {B: 255, A: 255}}
+	for _, c := range colors {
+		if err := enc.AddFrame(createTestFrame(16, 8, c)); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	if enc.FrameCount() != 2 {
+		t.Errorf("FrameCount() = %d, want 2", enc.FrameCount())
+	}
+	if err := enc.AddFrame(nil); err == nil {
+		t.Error("expected error for nil frame")
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if want := "multipart/x-mixed-replace; boundary=" + MJPEGBoundary; enc.ContentType() != want {
+		t.Errorf("ContentType() = %q, want %q", enc.ContentType(), want)
+	}
+
+	// Each part is a JPEG with its length
+	r := multipart.NewReader(&buf, MJPEGBoundary)
+	for i, want := range colors {
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatalf("reading part %d failed: %v", i, err)
+		}
+		if ct := part.Header.Get("Content-Type"); ct != "image/jpeg" {
+			t.Errorf("part %d Content-Type = %q, want image/jpeg", i, ct)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := strconv.Atoi(part.Header.Get("Content-Length")); n != len(data) {
+			t.Errorf("part %d Content-Length = %d, want %d", i, n, len(data))
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("decoding frame %d failed: %v", i, err)
+		}
+		if got := img.At(4, 4); !nearColor(got, want) {
+			t.Errorf("frame %d color = %v, want about %v", i, got, want)
+		}
+	}
+	if _, err := r.NextPart(); err != io.EOF {
+		t.Errorf("after the last frame NextPart() error = %v, want io.EOF", err)
+	}
+}
+
+func TestMJPEGEncoderBare(t *testing.T) {
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	enc := NewMJPEGEncoder(w)
+	enc.SetBoundary("")
+
+	colors := []color.RGBA{{G: 255, A: 255}, {R: 255, G: 255, A: 255}}
+	for i, c := range colors {
+		if err := enc.AddFrame(createTestFrame(8, 8, c)); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+		// Frames are flushed through buffered writers as they are written
+		if w.Buffered() != 0 {
+			t.Errorf("frame %d left %d bytes buffered", i, w.Buffered())
+		}
+	}
+	if enc.ContentType() != "video/x-motion-jpeg" {
+		t.Errorf("ContentType() = %q, want video/x-motion-jpeg", enc.ContentType())
+	}
+
+	// The images follow each other with nothing between them. The decoder
+	// reads ahead, so split the stream at the second start-of-image marker.
+	data := out.Bytes()
+	second := bytes.Index(data[2:], []byte{0xff, 0xd8, 0xff}) + 2
+	if second < 2 {
+		t.Fatal("the second JPEG's start-of-image marker is missing")
+	}
+	for i, want := range colors {
+		frame := data[:second]
+		if i == 1 {
+			frame = data[second:]
+		}
+		img, err := jpeg.Decode(bytes.NewReader(frame))
+		if err != nil {
+			t.Fatalf("decoding frame %d failed: %v", i, err)
+		}
+		if got := img.At(4, 4); !nearColor(got, want) {
+			t.Errorf("frame %d color = %v, want about %v", i, got, want)
+		}
+	}
+}
+
+func TestMJPEGEncoderQuality(t *testing.T) {
+	// A noisy frame, so quality changes the size
+	frame := createTestFrame(64, 64, color.RGBA{A: 255})
+	for i := range frame.Image.Pix {
+		frame.Image.Pix[i] = byte(i * 7919 % 251)
+	}
+
+	size := func(q int) int {
+		var buf bytes.Buffer
+		enc := NewMJPEGEncoder(&buf)
+		if err := enc.SetQuality(q); err != nil {
+			t.Fatalf("SetQuality(%d) failed: %v", q, err)
+		}
+		if err := enc.AddFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Len()
+	}
+	if low, high := size(20), size(95); low >= high {
+		t.Errorf("quality 20 wrote %d bytes, quality 95 wrote %d; want fewer at low quality", low, high)
+	}
+
+	enc := NewMJPEGEncoder(io.Discard)
+	for _, q := range []int{0, 101} {
+		if err := enc.SetQuality(q); err == nil {
+			t.Errorf("SetQuality(%d) should fail", q)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// mp4Timescale is the number of MP4 time units per second. 90kHz divides
+// evenly into every common frame rate, including NTSC rates.
+const mp4Timescale = 90000
+
+// mp4Writer muxes H.264 samples into an MP4 file. Samples are written into
+// a single mdat box as they arrive, and the index describing them (the moov
+// box) is written at the end, so the output must be seekable to patch the
+// mdat size.
+type mp4Writer struct {
+	w             io.WriteSeeker
+	width, height int
+	sps, pps      []byte
+
+	mdatStart int64 // Offset of the mdat box header
+	offset    int64 // Offset the next sample is written at
+	samples   []mp4Sample
+	pending   *mp4Sample // Last sample, until the next one gives its duration
+}
+
+// mp4Sample is where one coded frame was written
+type mp4Sample struct {
+	offset   int64
+	size     uint32
+	pts      time.Duration
+	duration uint32
+	sync     bool
+}
+
+// newMP4Writer writes the file header to w for a video of the given size
+func newMP4Writer(w io.WriteSeeker, width, height int) (*mp4Writer, error) {
+	m := &mp4Writer{w: w, width: width, height: height}
+
+	ftyp := box("ftyp", []byte("isom"), u32(0x200), []byte("isomiso2avc1mp41"))
+	if _, err := w.Write(ftyp); err != nil {
+		return nil, err
+	}
+
+	// A 64-bit mdat header, with the size filled in by close
+	m.mdatStart = int64(len(ftyp))
+	if _, err := w.Write(append(u32(1), append([]byte("mdat"), u64(0)...)...)); err != nil {
+		return nil, err
+	}
+	m.offset = m.mdatStart + 16
+	return m, nil
+}
+
+// setParameterSets records the H.264 sequence and picture parameter sets
+// the samples were coded with
+func (m *mp4Writer) setParameterSets(sps, pps []byte) {
+	m.sps = append([]byte(nil), sps...)
+	m.pps = append([]byte(nil), pps...)
+}
+
+// writeSample appends a coded frame in AVCC form (NAL units with 4-byte
+// length prefixes) presented at pts. Samples must be in presentation order.
+func (m *mp4Writer) writeSample(data []byte, pts time.Duration, sync bool) error {
+	if _, err := m.w.Write(data); err != nil {
+		return err
+	}
+
+	if m.pending != nil {
+		m.finishSample(pts)
+	}
+	m.pending = &mp4Sample{offset: m.offset, size: uint32(len(data)), pts: pts, sync: sync}
+	m.offset += int64(len(data))
+	return nil
+}
+
+// finishSample gives the pending sample its duration, now that the next
+// sample's presentation time is known
+func (m *mp4Writer) finishSample(next time.Duration) {
+	s := m.pending
+	s.duration = uint32(mp4Time(next) - mp4Time(s.pts))
+	m.samples = append(m.samples, *s)
+	m.pending = nil
+}
+
+// close patches the mdat size and writes the index. The last sample lasts
+// for last, normally one frame interval.
+func (m *mp4Writer) close(last time.Duration) error {
+	if m.pending != nil {
+		m.finishSample(m.pending.pts + last)
+	}
+	if len(m.samples) == 0 {
+		return fmt.Errorf("no frames to encode")
+	}
+	if len(m.sps) < 4 || len(m.pps) == 0 {
+		return fmt.Errorf("missing H.264 parameter sets")
+	}
+
+	if _, err := m.w.Seek(m.mdatStart+8, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := m.w.Write(u64(uint64(m.offset - m.mdatStart))); err != nil {
+		return err
+	}
+	if _, err := m.w.Seek(m.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err := m.w.Write(m.moov())
+	return err
+}
+
+// moov builds the index box describing the samples
+func (m *mp4Writer) moov() []byte {
+	var duration uint64
+	for _, s := range m.samples {
+		duration += uint64(s.duration)
+	}
+
+	matrix := []byte{
+		0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0x40, 0, 0, 0,
+	}
+
+	mvhd := fullBox("mvhd", 1, 0,
+		u64(0), u64(0), u32(mp4Timescale), u64(duration),
+		u32(0x00010000), u16(0x0100), make([]byte, 10),
+		matrix, make([]byte, 24), u32(2))
+
+	tkhd := fullBox("tkhd", 1, 3, // Enabled and in the movie
+		u64(0), u64(0), u32(1), u32(0), u64(duration),
+		make([]byte, 8), u16(0), u16(0), u16(0), u16(0),
+		matrix, u32(uint32(m.width)<<16), u32(uint32(m.height)<<16))
+
+	mdhd := fullBox("mdhd", 1, 0,
+		u64(0), u64(0), u32(mp4Timescale), u64(duration),
+		u16(0x55c4), u16(0)) // Language "und"
+
+	hdlr := fullBox("hdlr", 0, 0,
+		u32(0), []byte("vide"), make([]byte, 12), []byte("VideoHandler\x00"))
+
+	vmhd := fullBox("vmhd", 0, 1, u16(0), make([]byte, 6))
+	dinf := box("dinf", fullBox("dref", 0, 0, u32(1), fullBox("url ", 0, 1)))
+
+	return box("moov", mvhd,
+		box("trak", tkhd,
+			box("mdia", mdhd, hdlr,
+				box("minf", vmhd, dinf, m.stbl()))))
+}
+
+// stbl builds the sample table, with each sample in a chunk of its own
+func (m *mp4Writer) stbl() []byte {
+	avcC := box("avcC",
+		[]byte{1, m.sps[1], m.sps[2], m.sps[3], 0xff, 0xe1}, // 4-byte lengths, one SPS
+		u16(uint16(len(m.sps))), m.sps,
+		[]byte{1}, u16(uint16(len(m.pps))), m.pps)
+
+	compressor := make([]byte, 32)
+	avc1 := box("avc1",
+		make([]byte, 6), u16(1), // Data reference index
+		make([]byte, 16), u16(uint16(m.width)), u16(uint16(m.height)),
+		u32(0x00480000), u32(0x00480000), u32(0), u16(1), // 72dpi, one frame per sample
+		compressor, u16(0x18), u16(0xffff), avcC)
+	stsd := fullBox("stsd", 0, 0, u32(1), avc1)
+
+	// Runs of equal durations
+	var stts bytes.Buffer
+	runs := 0
+	for i := 0; i < len(m.samples); {
+		j := i
+		for j < len(m.samples) && m.samples[j].duration == m.samples[i].duration {
+			j++
+		}
+		stts.Write(u32(uint32(j - i)))
+		stts.Write(u32(m.samples[i].duration))
+		runs++
+		i = j
+	}
+
+	var stss, stsz, co64 bytes.Buffer
+	syncs := 0
+	for i, s := range m.samples {
+		if s.sync {
+			stss.Write(u32(uint32(i + 1)))
+			syncs++
+		}
+		stsz.Write(u32(s.size))
+		co64.Write(u64(uint64(s.offset)))
+	}
+	count := u32(uint32(len(m.samples)))
+
+	boxes := [][]byte{
+		stsd,
+		fullBox("stts", 0, 0, u32(uint32(runs)), stts.Bytes()),
+	}
+	if syncs < len(m.samples) {
+		// Without stss every sample is a sync sample
+		boxes = append(boxes, fullBox("stss", 0, 0, u32(uint32(syncs)), stss.Bytes()))
+	}
+	boxes = append(boxes,
+		fullBox("stsz", 0, 0, u32(0), count, stsz.Bytes()),
+		fullBox("stsc", 0, 0, u32(1), u32(1), u32(1), u32(1)),
+		fullBox("co64", 0, 0, count, co64.Bytes()),
+	)
+	return box("stbl", boxes...)
+}
+
+// mp4Time converts a presentation time to MP4 time units
+func mp4Time(d time.Duration) int64 {
+	return (int64(d)*mp4Timescale + int64(time.Second)/2) / int64(time.Second)
+}
+
+// box builds an MP4 box of the given type around payload
+func box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	b := make([]byte, 0, size)
+	b = append(b, u32(uint32(size))...)
+	b = append(b, typ...)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// fullBox builds a box whose payload starts with a version and flags
+func fullBox(typ string, version byte, flags uint32, payload ...[]byte) []byte {
+	header := u32(uint32(version)<<24 | flags&0xffffff)
+	return box(typ, append([][]byte{header}, payload...)...)
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
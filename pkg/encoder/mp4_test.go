@@ -0,0 +1,191 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Helper function to find the payload of the first box at path, e.g.
+// "moov/trak/mdia/mdhd", walking only container boxes on the way
+func findBox(t *testing.T, data []byte, path ...string) []byte {
+	t.Helper()
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		header := uint64(8)
+		if size == 1 {
+			size = binary.BigEndian.Uint64(data[8:])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			t.Fatalf("box %q has size %d with %d bytes left", typ, size, len(data))
+		}
+		if typ == path[0] {
+			payload := data[header:size]
+			if len(path) == 1 {
+				return payload
+			}
+			if typ == "stsd" {
+				payload = payload[8:] // Version, flags, and entry count
+			}
+			if typ == "avc1" {
+				payload = payload[78:] // Visual sample entry fields
+			}
+			return findBox(t, payload, path[1:]...)
+		}
+		data = data[size:]
+	}
+	t.Fatalf("box %q not found", path[0])
+	return nil
+}
+
+// Helper function to read a 32-bit field of a full box, counting from just
+// after its version and flags
+func field32(payload []byte, i int) uint32 {
+	return binary.BigEndian.Uint32(payload[4+4*i:])
+}
+
+func TestMP4Writer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.mp4")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := newMP4Writer(f, 320, 240)
+	if err != nil {
+		t.Fatalf("newMP4Writer() failed: %v", err)
+	}
+	sps := []byte{0x67, 0x64, 0x00, 0x1f, 0xac}
+	m.setParameterSets(sps, []byte{0x68, 0xeb})
+
+	samples := []struct {
+		data []byte
+		pts  time.Duration
+		sync bool
+	}{
+		{[]byte{0, 0, 0, 3, 0x65, 1, 2}, 0, true},
+		{[]byte{0, 0, 0, 1, 0x41}, 100 * time.Millisecond, false},
+		{[]byte{0, 0, 0, 2, 0x41, 9}, 200 * time.Millisecond, false},
+		{[]byte{0, 0, 0, 1, 0x65}, 500 * time.Millisecond, true},
+	}
+	for _, s := range samples {
+		if err := m.writeSample(s.data, s.pts, s.sync); err != nil {
+			t.Fatalf("writeSample() failed: %v", err)
+		}
+	}
+	if err := m.close(300 * time.Millisecond); err != nil {
+		t.Fatalf("close() failed: %v", err)
+	}
+	f.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ftyp := findBox(t, data, "ftyp"); !bytes.HasPrefix(ftyp, []byte("isom")) {
+		t.Errorf("ftyp brand = %q, want isom", ftyp[:4])
+	}
+	mdat := findBox(t, data, "mdat")
+	var want []byte
+	for _, s := range samples {
+		want = append(want, s.data...)
+	}
+	if !bytes.Equal(mdat, want) {
+		t.Errorf("mdat = %v, want the samples back to back %v", mdat, want)
+	}
+
+	stbl := []string{"moov", "trak", "mdia", "minf", "stbl"}
+	mdhd := findBox(t, data, "moov", "trak", "mdia", "mdhd")
+	if d := binary.BigEndian.Uint64(mdhd[24:]); d != 72000 {
+		t.Errorf("duration = %d, want 72000 (0.8s at 90kHz)", d)
+	}
+
+	avcC := findBox(t, data, append(stbl, "stsd", "avc1", "avcC")...)
+	if !bytes.Equal(avcC[8:8+len(sps)], sps) || avcC[1] != 0x64 || avcC[3] != 0x1f {
+		t.Errorf("avcC = %v, want the SPS with its profile and level", avcC)
+	}
+
+	stts := findBox(t, data, append(stbl, "stts")...)
+	if runs := field32(stts, 0); runs != 2 || field32(stts, 1) != 2 || field32(stts, 2) != 9000 ||
+		field32(stts, 3) != 2 || field32(stts, 4) != 27000 {
+		t.Errorf("stts = %v, want 2x9000 then 2x27000", stts)
+	}
+
+	stss := findBox(t, data, append(stbl, "stss")...)
+	if field32(stss, 0) != 2 || field32(stss, 1) != 1 || field32(stss, 2) != 4 {
+		t.Errorf("stss = %v, want samples 1 and 4", stss)
+	}
+
+	stsz := findBox(t, data, append(stbl, "stsz")...)
+	for i, s := range samples {
+		if got := field32(stsz, 2+i); got != uint32(len(s.data)) {
+			t.Errorf("sample %d size = %d, want %d", i, got, len(s.data))
+		}
+	}
+
+	// Each chunk offset points at its sample in the file
+	co64 := findBox(t, data, append(stbl, "co64")...)
+	for i, s := range samples {
+		offset := binary.BigEndian.Uint64(co64[8+8*i:])
+		if !bytes.HasPrefix(data[offset:], s.data) {
+			t.Errorf("sample %d offset %d does not point at its data", i, offset)
+		}
+	}
+}
+
+func TestMP4WriterErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(m *mp4Writer)
+	}{
+		{"no samples", func(m *mp4Writer) {
+			m.setParameterSets([]byte{0x67, 0x64, 0, 0x1f}, []byte{0x68})
+		}},
+		{"no parameter sets", func(m *mp4Writer) {
+			m.writeSample([]byte{0, 0, 0, 1, 0x65}, 0, true)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Create(filepath.Join(t.TempDir(), "out.mp4"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			m, err := newMP4Writer(f, 16, 16)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.write(m)
+			if err := m.close(time.Second / 30); err == nil {
+				t.Error("close() should fail")
+			}
+		})
+	}
+}
+
+func TestMP4Time(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want int64
+	}{
+		{0, 0},
+		{time.Second, 90000},
+		{time.Second / 30, 3000},
+		{time.Second * 1001 / 30000, 3003},
+	}
+
+	for _, tt := range tests {
+		if got := mp4Time(tt.d); got != tt.want {
+			t.Errorf("mp4Time(%v) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package encoder
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxColors is the largest palette a GIF frame can use
+const MaxColors = 256
+
+// maxPaletteSamples bounds how many pixels are examined when building an
+// adaptive palette, so large frames stay fast
+const maxPaletteSamples = 1 << 16
+
+// LoadPalette reads a palette file. GIMP palettes (.gpl) and files with one
+// hex color per line (.hex or .txt, as exported by Lospec) are supported.
+func LoadPalette(path string) (color.Palette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open palette: %w", err)
+	}
+	defer f.Close()
+
+	var p color.Palette
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gpl":
+		p, err = ParseGPL(f)
+	case ".hex", ".txt":
+		p, err = ParseHex(f)
+	default:
+		return nil, fmt.Errorf("unsupported palette file %s: want .gpl or .hex", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid palette %s: %w", path, err)
+	}
+
+	return p, nil
+}
+
+// ParseGPL parses a GIMP palette
+func ParseGPL(r io.Reader) (color.Palette, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "GIMP Palette" {
+		return nil, fmt.Errorf("missing \"GIMP Palette\" header")
+	}
+
+	var p color.Palette
+	for line := 2; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") ||
+			strings.HasPrefix(text, "Name:") || strings.HasPrefix(text, "Columns:") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: want R G B", line)
+		}
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid channel %q", line, fields[i])
+			}
+			rgb[i] = uint8(v)
+		}
+		p = append(p, color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return p, validatePalette(p)
+}
+
+// ParseHex parses one RRGGBB color per line, with or without a leading #
+func ParseHex(r io.Reader) (color.Palette, error) {
+	scanner := bufio.NewScanner(r)
+
+	var p color.Palette
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "#")
+		if text == "" || strings.HasPrefix(text, ";") {
+			continue
+		}
+
+		v, err := strconv.ParseUint(text, 16, 32)
+		if err != nil || len(text) != 6 {
+			return nil, fmt.Errorf("line %d: invalid color %q", line, text)
+		}
+		p = append(p, color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return p, validatePalette(p)
+}
+
+// validatePalette checks that p can be used for a GIF frame
+func validatePalette(p color.Palette) error {
+	if len(p) == 0 {
+		return fmt.Errorf("palette has no colors")
+	}
+	if len(p) > MaxColors {
+		return fmt.Errorf("palette has %d colors, GIF allows at most %d", len(p), MaxColors)
+	}
+	return nil
+}
+
+// AdaptivePalette picks up to n colors that best represent img, using
+// median cut
+func AdaptivePalette(img *image.RGBA, n int) color.Palette {
+	if n < 1 {
+		n = 1
+	}
+	if n > MaxColors {
+		n = MaxColors
+	}
+
+	boxes := []colorBox{{pixels: samplePixels(img)}}
+	for len(boxes) < n {
+		// Split the box with the widest channel range
+		widest, spread := -1, 0
+		for i, b := range boxes {
+			if len(b.pixels) < 2 {
+				continue
+			}
+			if _, s := b.widestChannel(); s > spread {
+				widest, spread = i, s
+			}
+		}
+		if widest < 0 {
+			break
+		}
+
+		lo, hi := boxes[widest].split()
+		boxes[widest] = lo
+		boxes = append(boxes, hi)
+	}
+
+	p := make(color.Palette, 0, len(boxes))
+	for _, b := range boxes {
+		if len(b.pixels) > 0 {
+			p = append(p, b.average())
+		}
+	}
+	return p
+}
+
+// colorBox is a set of pixels in median cut
+type colorBox struct {
+	pixels [][3]uint8
+}
+
+// widestChannel returns the channel with the largest range and that range
+func (b colorBox) widestChannel() (channel, spread int) {
+	for c := 0; c < 3; c++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, px := range b.pixels {
+			lo, hi = min(lo, px[c]), max(hi, px[c])
+		}
+		if s := int(hi) - int(lo); s > spread {
+			channel, spread = c, s
+		}
+	}
+	return channel, spread
+}
+
+// split divides the box at the median of its widest channel
+func (b colorBox) split() (colorBox, colorBox) {
+	c, _ := b.widestChannel()
+	sort.Slice(b.pixels, func(i, j int) bool { return b.pixels[i][c] < b.pixels[j][c] })
+	mid := len(b.pixels) / 2
+	return colorBox{pixels: b.pixels[:mid]}, colorBox{pixels: b.pixels[mid:]}
+}
+
+// average returns the mean color of the box
+func (b colorBox) average() color.RGBA {
+	var sum [3]int
+	for _, px := range b.pixels {
+		for c := range sum {
+			sum[c] += int(px[c])
+		}
+	}
+	n := len(b.pixels)
+	return color.RGBA{R: uint8(sum[0] / n), G: uint8(sum[1] / n), B: uint8(sum[2] / n), A: 255}
+}
+
+// samplePixels returns the colors of up to maxPaletteSamples evenly spaced
+// pixels of img
+func samplePixels(img *image.RGBA) [][3]uint8 {
+	b := img.Bounds()
+	total := b.Dx() * b.Dy()
+	step := 1
+	if total > maxPaletteSamples {
+		step = (total + maxPaletteSamples - 1) / maxPaletteSamples
+	}
+
+	pixels := make([][3]uint8, 0, total/step+1)
+	for i := 0; i < total; i += step {
+		c := img.RGBAAt(b.Min.X+i%b.Dx(), b.Min.Y+i/b.Dx())
+		pixels = append(pixels, [3]uint8{c.R, c.G, c.B})
+	}
+	return pixels
+}
@@ -0,0 +1,160 @@
+package encoder
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGPL(t *testing.T) {
+	input := `GIMP Palette
+Name: Test
+Columns: 4
+#
+ 40  42  54	Background
+255 121 198	Pink
+`
+	p, err := ParseGPL(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseGPL() failed: %v", err)
+	}
+
+	want := color.Palette{
+		color.RGBA{R: 40, G: 42, B: 54, A: 255},
+		color.RGBA{R: 255, G: 121, B: 198, A: 255},
+	}
+	if len(p) != len(want) {
+		t.Fatalf("ParseGPL() returned %d colors, want %d", len(p), len(want))
+	}
+	for i := range want {
+		if p[i] != want[i] {
+			t.Errorf("color %d = %v, want %v", i, p[i], want[i])
+		}
+	}
+}
+
+func TestParseGPLInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing header", "255 0 0\n"},
+		{"short line", "GIMP Palette\n255 0\n"},
+		{"channel out of range", "GIMP Palette\n256 0 0\n"},
+		{"no colors", "GIMP Palette\nName: Empty\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseGPL(strings.NewReader(tt.input)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	p, err := ParseHex(strings.NewReader("282a36\n#FF79C6\n\n"))
+	if err != nil {
+		t.Fatalf("ParseHex() failed: %v", err)
+	}
+	if len(p) != 2 {
+		t.Fatalf("ParseHex() returned %d colors, want 2", len(p))
+	}
+	if want := (color.RGBA{R: 255, G: 121, B: 198, A: 255}); p[1] != want {
+		t.Errorf("second color = %v, want %v", p[1], want)
+	}
+
+	for _, input := range []string{"fff\n", "gggggg\n", ""} {
+		if _, err := ParseHex(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseHex(%q) expected error", input)
+		}
+	}
+
+	tooMany := strings.Repeat("000000\n", MaxColors+1)
+	if _, err := ParseHex(strings.NewReader(tooMany)); err == nil {
+		t.Error("expected error for more than 256 colors")
+	}
+}
+
+func TestLoadPalette(t *testing.T) {
+	dir := t.TempDir()
+	hexPath := filepath.Join(dir, "theme.hex")
+	if err := os.WriteFile(hexPath, []byte("000000\nffffff\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadPalette(hexPath)
+	if err != nil {
+		t.Fatalf("LoadPalette() failed: %v", err)
+	}
+	if len(p) != 2 {
+		t.Errorf("LoadPalette() returned %d colors, want 2", len(p))
+	}
+
+	if _, err := LoadPalette(filepath.Join(dir, "theme.act")); err == nil {
+		t.Error("expected error for unsupported extension")
+	}
+	if _, err := LoadPalette(filepath.Join(dir, "missing.gpl")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestAdaptivePalette(t *testing.T) {
+	// Left half red, right half blue
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 5 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	p := AdaptivePalette(img, 2)
+	if len(p) != 2 {
+		t.Fatalf("AdaptivePalette() returned %d colors, want 2", len(p))
+	}
+	if p.Index(color.RGBA{R: 255, A: 255}) == p.Index(color.RGBA{B: 255, A: 255}) {
+		t.Error("red and blue mapped to the same palette entry")
+	}
+
+	// A solid image cannot be split further
+	if p := AdaptivePalette(createTestFrame(4, 4, color.White).Image, 16); len(p) != 1 {
+		t.Errorf("AdaptivePalette() of solid image returned %d colors, want 1", len(p))
+	}
+}
+
+func TestCustomPalette(t *testing.T) {
+	custom := color.Palette{color.RGBA{A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255}}
+
+	enc := NewGIFEncoder("", 10, QualityHigh)
+	enc.SetPalette(custom)
+	if err := enc.AddFrame(createGradientFrame(16, 16)); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if got := len(enc.frames[0].Palette); got != 2 {
+		t.Errorf("frame palette has %d colors, want 2", got)
+	}
+}
+
+func TestColorCount(t *testing.T) {
+	enc := NewGIFEncoder("", 10, QualityMedium)
+	enc.SetColors(8)
+	for i := 0; i < 2; i++ {
+		if err := enc.AddFrame(createGradientFrame(32, 32)); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+
+	for i, f := range enc.frames {
+		if got := len(f.Palette); got != 8 {
+			t.Errorf("frame %d palette has %d colors, want 8", i, got)
+		}
+	}
+}
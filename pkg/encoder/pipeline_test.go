@@ -0,0 +1,57 @@
+package encoder
+
+import (
+	"bytes"
+	"image/gif"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// TestRecordToGIF runs the capture-to-GIF pipeline used by 'witness gif'
+// with a mock capturer
+func TestRecordToGIF(t *testing.T) {
+	factory := func(config capture.Config) (capture.Capturer, error) {
+		m := capture.NewMockCapturer(config)
+		m.FrameWidth = 16
+		m.FrameHeight = 12
+		m.FrameDelay = 0
+		return m, nil
+	}
+
+	fps := capture.IntFPS(50)
+	enc := NewGIFEncoderFPS("", fps, QualityMedium)
+	rec := recorder.NewRecorderWithFactory(recorder.DefaultConfig(capture.Config{FPS: fps}), enc, factory)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+
+	if enc.FrameCount() == 0 {
+		t.Fatal("no frames were recorded")
+	}
+	if got := enc.Bounds().Size(); got.X != 16 || got.Y != 12 {
+		t.Errorf("Bounds() size = %v, want 16x12", got)
+	}
+
+	var buf bytes.Buffer
+	if err := enc.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo() failed: %v", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode GIF: %v", err)
+	}
+	if len(anim.Image) != enc.FrameCount() {
+		t.Errorf("GIF has %d frames, want %d", len(anim.Image), enc.FrameCount())
+	}
+	if anim.Delay[0] != 2 {
+		t.Errorf("frame delay = %d, want 2 (50 FPS)", anim.Delay[0])
+	}
+}
@@ -0,0 +1,52 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// PNGEncoder streams frames as concatenated PNG images, the format read by
+// "witness encode -input png". Screen content compresses well losslessly,
+// so this suits sending frames over a network.
+type PNGEncoder struct {
+	w      io.Writer
+	enc    png.Encoder
+	buf    bytes.Buffer
+	frames int
+}
+
+// NewPNGEncoder creates a PNG stream encoder writing to w
+func NewPNGEncoder(w io.Writer) *PNGEncoder {
+	return &PNGEncoder{
+		w:   w,
+		enc: png.Encoder{CompressionLevel: png.BestSpeed},
+	}
+}
+
+// AddFrame writes a frame as one PNG image. Each image is written with a
+// single call so frames are not split across writes to a pipe.
+func (e *PNGEncoder) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil {
+		return fmt.Errorf("invalid frame")
+	}
+
+	e.buf.Reset()
+	if err := e.enc.Encode(&e.buf, frame.Image); err != nil {
+		return fmt.Errorf("failed to encode PNG frame: %w", err)
+	}
+	if _, err := e.w.Write(e.buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write PNG frame: %w", err)
+	}
+
+	e.frames++
+	return nil
+}
+
+// FrameCount returns the number of frames written
+func (e *PNGEncoder) FrameCount() int {
+	return e.frames
+}
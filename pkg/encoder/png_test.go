@@ -0,0 +1,39 @@
+package encoder
+
+import (
+	"bufio"
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestPNGEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewPNGEncoder(&buf)
+
+	colors := []color.RGBA{{R: 255, A: 255}, {B: 255, A: 255}}
+	for _, c := range colors {
+		if err := enc.AddFrame(createTestFrame(4, 2, c)); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	if enc.FrameCount() != 2 {
+		t.Errorf("FrameCount() = %d, want 2", enc.FrameCount())
+	}
+	if err := enc.AddFrame(nil); err == nil {
+		t.Error("expected error for nil frame")
+	}
+
+	// The images decode back to back from one stream
+	r := bufio.NewReader(&buf)
+	for i, want := range colors {
+		img, err := png.Decode(r)
+		if err != nil {
+			t.Fatalf("decoding frame %d failed: %v", i, err)
+		}
+		if got := color.RGBAModel.Convert(img.At(0, 0)); got != want {
+			t.Errorf("frame %d color = %v, want %v", i, got, want)
+		}
+	}
+}
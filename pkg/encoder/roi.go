@@ -0,0 +1,117 @@
+package encoder
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// ROI marks a rectangle to encode at a different quality than the rest of
+// the frame. QOffset ranges from -1 (best quality) to 1 (worst quality),
+// matching the ffmpeg addroi filter.
+type ROI struct {
+	Rect    image.Rectangle
+	QOffset float64
+}
+
+// ROIConfig controls region-of-interest video encoding, which keeps the area
+// around the cursor and on-screen activity sharp while compressing static
+// surroundings harder
+type ROIConfig struct {
+	// CursorRadius is the half-size of the sharp square around the cursor
+	CursorRadius int
+
+	// Focus is the quality offset for the cursor and active areas
+	Focus float64
+
+	// Background is the quality offset for everything else
+	Background float64
+}
+
+// DefaultROIConfig returns a moderate ROI configuration
+func DefaultROIConfig() ROIConfig {
+	return ROIConfig{
+		CursorRadius: 128,
+		Focus:        -0.3,
+		Background:   0.3,
+	}
+}
+
+// Regions returns the ROIs for frames with the given bounds. cursors are
+// the cursor positions over those frames in frame coordinates, if known.
+// active is the area where content changes, or empty if unknown. Encoders
+// use the first region that covers a block, so focus areas come before
+// the background.
+func (c ROIConfig) Regions(bounds image.Rectangle, cursors []image.Point, active image.Rectangle) []ROI {
+	var regions []ROI
+
+	var around image.Rectangle
+	for _, p := range cursors {
+		around = around.Union(image.Rect(p.X, p.Y, p.X, p.Y).Inset(-c.CursorRadius))
+	}
+	if r := around.Intersect(bounds); !r.Empty() {
+		regions = append(regions, ROI{Rect: r, QOffset: c.Focus})
+	}
+
+	if r := active.Intersect(bounds); !r.Empty() && r != bounds {
+		regions = append(regions, ROI{Rect: r, QOffset: c.Focus})
+	}
+
+	// With nothing to focus on, the whole frame keeps the base quality
+	if len(regions) == 0 {
+		return nil
+	}
+
+	return append(regions, ROI{Rect: bounds, QOffset: c.Background})
+}
+
+// ROISegment is a run of frames that share regions of interest
+type ROISegment struct {
+	// Start is the first frame, and End the frame after the last, or 0 to
+	// run to the end of the video
+	Start, End int
+
+	Regions []ROI
+}
+
+// FFmpegSegmentFilter renders segments, which cover a video in order, as an
+// ffmpeg filter graph that reads [0:v], applies each segment's addroi
+// chain to that segment's frames only, and writes [roi]. addroi fixes its
+// regions for the whole stream, so the stream is split into segments and
+// joined again.
+func FFmpegSegmentFilter(segments []ROISegment, origin image.Point) string {
+	var graph strings.Builder
+	fmt.Fprintf(&graph, "[0:v]split=%d", len(segments))
+	for i := range segments {
+		fmt.Fprintf(&graph, "[in%d]", i)
+	}
+	for i, s := range segments {
+		fmt.Fprintf(&graph, ";[in%d]trim=start_frame=%d", i, s.Start)
+		if s.End > 0 {
+			fmt.Fprintf(&graph, ":end_frame=%d", s.End)
+		}
+		graph.WriteString(",setpts=PTS-STARTPTS")
+		if filter := FFmpegFilter(s.Regions, origin); filter != "" {
+			graph.WriteString("," + filter)
+		}
+		fmt.Fprintf(&graph, "[out%d]", i)
+	}
+	graph.WriteString(";")
+	for i := range segments {
+		fmt.Fprintf(&graph, "[out%d]", i)
+	}
+	fmt.Fprintf(&graph, "concat=n=%d:v=1:a=0[roi]", len(segments))
+	return graph.String()
+}
+
+// FFmpegFilter renders regions as a chain of ffmpeg addroi filters, relative
+// to a frame whose top-left corner is origin
+func FFmpegFilter(regions []ROI, origin image.Point) string {
+	filters := make([]string, len(regions))
+	for i, r := range regions {
+		rect := r.Rect.Sub(origin)
+		filters[i] = fmt.Sprintf("addroi=x=%d:y=%d:w=%d:h=%d:qoffset=%g",
+			rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), r.QOffset)
+	}
+	return strings.Join(filters, ",")
+}
@@ -0,0 +1,101 @@
+package encoder
+
+import (
+	"image"
+	"testing"
+)
+
+func TestROIRegions(t *testing.T) {
+	bounds := image.Rect(0, 0, 1920, 1080)
+	config := DefaultROIConfig()
+	cursor := image.Point{X: 50, Y: 500}
+	active := image.Rect(800, 200, 1200, 600)
+
+	regions := config.Regions(bounds, []image.Point{cursor}, active)
+	if len(regions) != 3 {
+		t.Fatalf("got %d regions, want 3", len(regions))
+	}
+
+	// The cursor square is clipped at the left edge
+	if want := image.Rect(0, 372, 178, 628); regions[0].Rect != want {
+		t.Errorf("cursor region = %v, want %v", regions[0].Rect, want)
+	}
+	if regions[1].Rect != active {
+		t.Errorf("active region = %v, want %v", regions[1].Rect, active)
+	}
+	if regions[0].QOffset != config.Focus || regions[1].QOffset != config.Focus {
+		t.Errorf("focus regions should use offset %g", config.Focus)
+	}
+
+	// The background comes last so focus areas take precedence
+	last := regions[len(regions)-1]
+	if last.Rect != bounds || last.QOffset != config.Background {
+		t.Errorf("background region = %+v, want full frame at %g", last, config.Background)
+	}
+}
+
+func TestROIRegionsNoFocus(t *testing.T) {
+	bounds := image.Rect(0, 0, 640, 480)
+
+	if regions := DefaultROIConfig().Regions(bounds, nil, image.Rectangle{}); regions != nil {
+		t.Errorf("Regions() = %v, want nil with nothing to focus on", regions)
+	}
+
+	// Activity over the whole frame is not a region of interest
+	if regions := DefaultROIConfig().Regions(bounds, nil, bounds); regions != nil {
+		t.Errorf("Regions() = %v, want nil when the whole frame is active", regions)
+	}
+}
+
+func TestROIRegionsCursors(t *testing.T) {
+	bounds := image.Rect(0, 0, 640, 480)
+	config := DefaultROIConfig()
+	config.CursorRadius = 10
+
+	// A cursor that moves keeps the whole path it covered sharp
+	regions := config.Regions(bounds, []image.Point{{X: 100, Y: 100}, {X: 300, Y: 50}}, image.Rectangle{})
+	if len(regions) != 2 {
+		t.Fatalf("got %d regions, want 2", len(regions))
+	}
+	if want := image.Rect(90, 40, 310, 110); regions[0].Rect != want {
+		t.Errorf("cursor region = %v, want %v", regions[0].Rect, want)
+	}
+
+	// A cursor off the frame is not a region of interest
+	if regions := config.Regions(bounds, []image.Point{{X: -100, Y: 100}}, image.Rectangle{}); regions != nil {
+		t.Errorf("Regions() = %v, want nil for a cursor off the frame", regions)
+	}
+}
+
+func TestFFmpegSegmentFilter(t *testing.T) {
+	segments := []ROISegment{
+		{Start: 0, End: 30, Regions: []ROI{{Rect: image.Rect(10, 10, 20, 20), QOffset: -0.3}}},
+		{Start: 30},
+	}
+
+	got := FFmpegSegmentFilter(segments, image.Point{})
+	want := "[0:v]split=2[in0][in1]" +
+		";[in0]trim=start_frame=0:end_frame=30,setpts=PTS-STARTPTS,addroi=x=10:y=10:w=10:h=10:qoffset=-0.3[out0]" +
+		";[in1]trim=start_frame=30,setpts=PTS-STARTPTS[out1]" +
+		";[out0][out1]concat=n=2:v=1:a=0[roi]"
+	if got != want {
+		t.Errorf("FFmpegSegmentFilter() = %q, want %q", got, want)
+	}
+}
+
+func TestFFmpegFilter(t *testing.T) {
+	regions := []ROI{
+		{Rect: image.Rect(110, 70, 210, 120), QOffset: -0.3},
+		{Rect: image.Rect(100, 50, 740, 530), QOffset: 0.3},
+	}
+
+	got := FFmpegFilter(regions, image.Point{X: 100, Y: 50})
+	want := "addroi=x=10:y=20:w=100:h=50:qoffset=-0.3,addroi=x=0:y=0:w=640:h=480:qoffset=0.3"
+	if got != want {
+		t.Errorf("FFmpegFilter() = %q, want %q", got, want)
+	}
+
+	if got := FFmpegFilter(nil, image.Point{}); got != "" {
+		t.Errorf("FFmpegFilter(nil) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,139 @@
+package encoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// SequenceManifest is the name of the timings file written alongside a
+// PNG frame sequence
+const SequenceManifest = "timings.json"
+
+// SequenceFrameName returns the file name of frame n in a PNG sequence,
+// e.g. frame_000123.png, matching ffmpeg's frame_%06d.png pattern
+func SequenceFrameName(n int) string {
+	return fmt.Sprintf("frame_%06d.png", n)
+}
+
+// SequenceTimings is the manifest of a PNG frame sequence, giving when each
+// frame was shown so tools that read the images can keep the recording's
+// timing even though frames were not captured at an even rate
+type SequenceTimings struct {
+	FPS    string          `json:"fps"`
+	Width  int             `json:"width"`
+	Height int             `json:"height"`
+	Frames []SequenceFrame `json:"frames"`
+}
+
+// SequenceFrame is one image of a PNG frame sequence
+type SequenceFrame struct {
+	File string `json:"file"`
+
+	// TimeMS is when the frame is shown, in milliseconds from the first
+	TimeMS float64 `json:"time_ms"`
+
+	// DurationMS is how long the frame is shown, until the next one
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// PNGSequenceEncoder writes each frame as a numbered PNG file in a
+// directory, for post-processing in tools that read image sequences. The
+// frames are written as they arrive; Close writes the SequenceManifest.
+type PNGSequenceEncoder struct {
+	dir     string
+	fps     capture.FPS
+	clock   frameClock
+	enc     png.Encoder
+	timings SequenceTimings
+	times   []time.Duration
+}
+
+// NewPNGSequenceEncoder creates a frame sequence encoder writing to dir,
+// which is created if needed
+func NewPNGSequenceEncoder(dir string, fps capture.FPS) *PNGSequenceEncoder {
+	return &PNGSequenceEncoder{
+		dir:     dir,
+		fps:     fps,
+		clock:   newFrameClock(fps),
+		enc:     png.Encoder{CompressionLevel: png.BestSpeed},
+		timings: SequenceTimings{FPS: fps.String()},
+	}
+}
+
+// AddFrame writes a frame as the next PNG file. Every frame must have the
+// same size.
+func (e *PNGSequenceEncoder) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil {
+		return fmt.Errorf("invalid frame")
+	}
+
+	b := frame.Image.Bounds()
+	n := len(e.times)
+	if n == 0 {
+		if err := os.MkdirAll(e.dir, 0755); err != nil {
+			return fmt.Errorf("failed to create frame directory: %w", err)
+		}
+		e.timings.Width, e.timings.Height = b.Dx(), b.Dy()
+	} else if b.Dx() != e.timings.Width || b.Dy() != e.timings.Height {
+		return fmt.Errorf("frame size %dx%d does not match sequence size %dx%d", b.Dx(), b.Dy(), e.timings.Width, e.timings.Height)
+	}
+
+	name := SequenceFrameName(n)
+	f, err := os.Create(filepath.Join(e.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to create frame file: %w", err)
+	}
+	err = e.enc.Encode(f, frame.Image)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	e.times = append(e.times, e.clock.next(frame))
+	e.timings.Frames = append(e.timings.Frames, SequenceFrame{File: name})
+	return nil
+}
+
+// FrameCount returns the number of frames written
+func (e *PNGSequenceEncoder) FrameCount() int {
+	return len(e.times)
+}
+
+// Close writes the timings manifest. Each frame lasts until the next one,
+// and the last for one frame interval.
+func (e *PNGSequenceEncoder) Close() error {
+	if len(e.times) == 0 {
+		return nil
+	}
+
+	for i, at := range e.times {
+		duration := e.fps.FrameDuration()
+		if i+1 < len(e.times) {
+			duration = e.times[i+1] - at
+		}
+		e.timings.Frames[i].TimeMS = milliseconds(at)
+		e.timings.Frames[i].DurationMS = milliseconds(duration)
+	}
+
+	data, err := json.MarshalIndent(e.timings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal frame timings: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(e.dir, SequenceManifest), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write frame timings: %w", err)
+	}
+	return nil
+}
+
+// milliseconds returns d in milliseconds, to the microsecond
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,99 @@
+package encoder
+
+import (
+	"encoding/json"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestPNGSequenceEncoder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "frames")
+	enc := NewPNGSequenceEncoder(dir, capture.IntFPS(10))
+
+	// The second frame came late, and the third follows a pause
+	colors := []color.RGBA{{R: 255, A: 255}, {G: 255, A: 255}, {B: 255, A: 255}}
+	at := []time.Duration{0, 250 * time.Millisecond, time.Minute}
+	for i, c := range colors {
+		frame := createTestFrame(4, 2, c)
+		frame.Timestamp = capture.Epoch.Add(at[i])
+		frame.Discontinuity = i == 2
+		if err := enc.AddFrame(frame); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	if enc.FrameCount() != 3 {
+		t.Errorf("FrameCount() = %d, want 3", enc.FrameCount())
+	}
+	if err := enc.AddFrame(createTestFrame(2, 2, color.White)); err == nil {
+		t.Error("expected error for a frame of a different size")
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	for i, want := range colors {
+		f, err := os.Open(filepath.Join(dir, SequenceFrameName(i)))
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("decoding frame %d failed: %v", i, err)
+		}
+		if got := color.RGBAModel.Convert(img.At(0, 0)); got != want {
+			t.Errorf("frame %d color = %v, want %v", i, got, want)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, SequenceManifest))
+	if err != nil {
+		t.Fatalf("reading manifest: %v", err)
+	}
+	var timings SequenceTimings
+	if err := json.Unmarshal(data, &timings); err != nil {
+		t.Fatalf("parsing manifest: %v", err)
+	}
+	if timings.FPS != "10" || timings.Width != 4 || timings.Height != 2 {
+		t.Errorf("manifest header = %+v", timings)
+	}
+	want := []SequenceFrame{
+		{File: "frame_000000.png", TimeMS: 0, DurationMS: 250},
+		{File: "frame_000001.png", TimeMS: 250, DurationMS: 100},
+		{File: "frame_000002.png", TimeMS: 350, DurationMS: 100},
+	}
+	if len(timings.Frames) != len(want) {
+		t.Fatalf("manifest has %d frames, want %d", len(timings.Frames), len(want))
+	}
+	for i := range want {
+		if timings.Frames[i] != want[i] {
+			t.Errorf("frame %d = %+v, want %+v", i, timings.Frames[i], want[i])
+		}
+	}
+}
+
+func TestPNGSequenceEncoderEmpty(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "frames")
+	enc := NewPNGSequenceEncoder(dir, capture.IntFPS(10))
+	if err := enc.AddFrame(nil); err == nil {
+		t.Error("expected error for nil frame")
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("directory created with no frames: %v", err)
+	}
+}
+
+func TestSequenceFrameName(t *testing.T) {
+	if got := SequenceFrameName(123); got != "frame_000123.png" {
+		t.Errorf("SequenceFrameName(123) = %q, want frame_000123.png", got)
+	}
+}
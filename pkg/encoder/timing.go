@@ -0,0 +1,50 @@
+package encoder
+
+import (
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// frameClock places frames on an output timeline from their capture
+// timestamps, so frames that arrive late or are dropped by the capturer do
+// not make playback run fast. A gap after an interruption, such as a pause,
+// counts as a single frame so the output does not freeze for its length.
+type frameClock struct {
+	interval time.Duration // Nominal time between frames
+	started  bool
+	last     time.Time     // Capture time of the previous frame
+	pts      time.Duration // Presentation time of the previous frame
+}
+
+// newFrameClock creates a clock for frames captured at fps
+func newFrameClock(fps capture.FPS) frameClock {
+	return frameClock{interval: fps.FrameDuration()}
+}
+
+// next returns the presentation time of frame, measured from the first
+// frame. Frames without a timestamp, or captured no later than the previous
+// frame, follow it by the nominal interval.
+func (c *frameClock) next(frame *capture.Frame) time.Duration {
+	if !c.started {
+		c.started = true
+		c.last = frame.Timestamp
+		return 0
+	}
+
+	gap := frame.Timestamp.Sub(c.last)
+	if frame.Discontinuity || frame.Timestamp.IsZero() || c.last.IsZero() || gap <= 0 {
+		gap = c.interval
+	}
+	c.last = frame.Timestamp
+	c.pts += gap
+	return c.pts
+}
+
+// slot returns the index of frame in a stream at fps that already holds
+// written frames. Any frames between the end of the stream and the slot are
+// repeats of the previous one. Frames captured early are kept rather than
+// dropped, so the slot is never before the end of the stream.
+func (c *frameClock) slot(frame *capture.Frame, fps capture.FPS, written int) int {
+	return max(written, fps.FramesIn(c.next(frame)+fps.FrameDuration()/2))
+}
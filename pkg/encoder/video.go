@@ -0,0 +1,492 @@
+package encoder
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/analyze"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/replay"
+)
+
+// DefaultFFmpeg is the ffmpeg binary used for video encoding
+const DefaultFFmpeg = "ffmpeg"
+
+// evenSize pads frames to even dimensions, which yuv420p H.264 requires
+const evenSize = "pad=ceil(iw/2)*2:ceil(ih/2)*2"
+
+// VideoEncoder encodes frames as an H.264 MP4. On macOS frames are encoded
+// with VideoToolbox, on the hardware encoder where there is one, and muxed
+// into the MP4 natively. Elsewhere, and when streaming or using ROI, frames
+// are streamed to ffmpeg as Y4M. Unlike GIFEncoder, frames are not buffered
+// in memory.
+//
+// With ROI enabled the frames are spooled to a temporary .wrec file
+// instead, so the areas that change in each second of the recording are
+// known before encoding. Screen content compresses well there, but a busy
+// recording can still take tens of megabytes a second.
+type VideoEncoder struct {
+	outputPath       string
+	fps              capture.FPS
+	quality          GIFQuality
+	bitrate          int
+	keyframeInterval int
+	profile          H264Profile
+	ffmpeg           string
+	native           func(h264Settings) (h264Session, error)
+	out              io.Writer
+	roi              *ROIConfig
+
+	session h264Session
+	file    *os.File
+	mp4     *mp4Writer
+	clock   frameClock
+	frames  int
+
+	proc    *ffmpegProcess
+	spool   *os.File
+	spooled *replay.Writer // Writes the spool file
+	y4m     *Y4MEncoder
+
+	bounds  image.Rectangle
+	pointer func() (image.Point, error)
+	area    capture.Region
+	prev    *image.RGBA
+	windows []roiWindow
+}
+
+// roiWindow is what ROI encoding tracks over a window of frames
+type roiWindow struct {
+	cursors []image.Point   // Cursor positions in frame coordinates
+	active  image.Rectangle // Pixels that changed between frames
+}
+
+// roiWindowLength is how long a run of frames shares regions of interest
+const roiWindowLength = time.Second
+
+// maxROISegments bounds the size of the ffmpeg filter graph for long
+// recordings, by lengthening the windows
+const maxROISegments = 64
+
+// NewVideoEncoder creates an MP4 encoder. It fails if there is no native
+// encoder and ffmpeg is not installed.
+func NewVideoEncoder(outputPath string, fps capture.FPS, quality GIFQuality) (*VideoEncoder, error) {
+	ffmpeg, err := FindFFmpeg()
+	if err != nil && newH264Session == nil {
+		return nil, fmt.Errorf("ffmpeg is required for video recording: %w", err)
+	}
+
+	return &VideoEncoder{
+		outputPath: outputPath,
+		fps:        fps,
+		quality:    quality,
+		ffmpeg:     ffmpeg,
+		native:     newH264Session,
+	}, nil
+}
+
+// SetBitrate targets an average bitrate in bits per second instead of the
+// quality level's. It must be called before frames are added.
+func (e *VideoEncoder) SetBitrate(bitsPerSecond int) {
+	e.bitrate = bitsPerSecond
+}
+
+// SetKeyframeInterval limits how many frames may pass between keyframes;
+// 0 leaves it to the encoder. Shorter intervals make seeking faster and
+// files larger. It must be called before frames are added.
+func (e *VideoEncoder) SetKeyframeInterval(frames int) {
+	e.keyframeInterval = frames
+}
+
+// SetProfile selects the H.264 profile (default ProfileHigh). It must be
+// called before frames are added.
+func (e *VideoEncoder) SetProfile(p H264Profile) {
+	e.profile = p
+}
+
+// SetOutput streams the video to w as fragmented MP4 instead of writing the
+// output file. Streaming requires ffmpeg. It must be called before frames
+// are added.
+func (e *VideoEncoder) SetOutput(w io.Writer) {
+	e.out = w
+}
+
+// SetROI encodes the cursor and active areas at higher quality than static
+// surroundings. ROI requires ffmpeg. It must be called before frames are
+// added.
+func (e *VideoEncoder) SetROI(c ROIConfig) {
+	e.roi = &c
+}
+
+// SetROICursor gives ROI encoding the cursor position, read from pointer in
+// global points as each frame is added. area is the part of the screen the
+// frames show, in global points, so positions can be scaled to pixels.
+// Without it only on-screen activity is kept sharp.
+func (e *VideoEncoder) SetROICursor(pointer func() (image.Point, error), area capture.Region) {
+	e.pointer = pointer
+	e.area = area
+}
+
+// AddFrame sends a frame to the encoder. Every frame must have the same
+// size as the first.
+func (e *VideoEncoder) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil {
+		return fmt.Errorf("invalid frame")
+	}
+
+	if e.y4m == nil && e.session == nil && e.spool == nil {
+		if err := e.start(frame.Image.Bounds()); err != nil {
+			return err
+		}
+	}
+
+	if e.session != nil {
+		return e.encodeNative(frame)
+	}
+	if e.spool != nil {
+		return e.spoolFrame(frame)
+	}
+	if err := e.y4m.AddFrame(frame); err != nil {
+		return e.proc.fail(err)
+	}
+
+	return nil
+}
+
+// FrameCount returns the number of frames sent to the encoder
+func (e *VideoEncoder) FrameCount() int {
+	if e.session != nil || e.spool != nil {
+		return e.frames
+	}
+	if e.y4m == nil {
+		return 0
+	}
+	return e.y4m.FrameCount()
+}
+
+// Close finishes encoding and waits for ffmpeg to exit
+func (e *VideoEncoder) Close() error {
+	if e.session != nil {
+		return e.closeNative()
+	}
+	if e.spool != nil {
+		return e.encodeSpool()
+	}
+	if e.y4m == nil {
+		return fmt.Errorf("no frames to encode")
+	}
+	return e.proc.close()
+}
+
+// start opens the native encoder, or launches ffmpeg, or creates the spool
+// file when ROI is enabled. If the native encoder cannot be opened, ffmpeg
+// is used when it is installed.
+func (e *VideoEncoder) start(bounds image.Rectangle) error {
+	e.bounds = bounds
+
+	if e.native != nil && e.out == nil && e.roi == nil {
+		err := e.startNative(bounds)
+		if err == nil || e.ffmpeg == "" {
+			return err
+		}
+	}
+	if e.ffmpeg == "" {
+		return fmt.Errorf("ffmpeg is required to stream video or use ROI (%s)", installHint())
+	}
+
+	if e.roi != nil {
+		spool, err := os.CreateTemp("", "witness-*"+replay.Extension)
+		if err != nil {
+			return fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		spooled, err := replay.NewWriter(spool, e.fps)
+		if err != nil {
+			spool.Close()
+			os.Remove(spool.Name())
+			return fmt.Errorf("failed to write temporary file: %w", err)
+		}
+		e.spool = spool
+		e.spooled = spooled
+		e.clock = newFrameClock(e.fps)
+		return nil
+	}
+
+	proc := newFFmpegProcess(e.ffmpeg, e.args("-", "", ""), e.out)
+	if err := proc.start(); err != nil {
+		return err
+	}
+
+	e.proc = proc
+	e.y4m = NewY4MEncoder(proc.buf, e.fps)
+	return nil
+}
+
+// startNative opens the native encoder and the output file
+func (e *VideoEncoder) startNative(bounds image.Rectangle) error {
+	// Odd sizes are padded to even, as with ffmpeg
+	width, height := bounds.Dx()+bounds.Dx()%2, bounds.Dy()+bounds.Dy()%2
+	bitrate := e.bitrate
+	if bitrate == 0 {
+		bitrate = defaultBitrate(e.quality, width, height, e.fps)
+	}
+
+	session, err := e.native(h264Settings{
+		width:            width,
+		height:           height,
+		fps:              e.fps,
+		bitrate:          bitrate,
+		keyframeInterval: e.keyframeInterval,
+		profile:          e.profile,
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(e.outputPath)
+	if err != nil {
+		session.close()
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	mp4, err := newMP4Writer(file, width, height)
+	if err != nil {
+		session.close()
+		file.Close()
+		return fmt.Errorf("failed to write video: %w", err)
+	}
+
+	e.session = session
+	e.file = file
+	e.mp4 = mp4
+	e.clock = newFrameClock(e.fps)
+	return nil
+}
+
+// encodeNative sends a frame to the native encoder and writes whatever it
+// has finished
+func (e *VideoEncoder) encodeNative(frame *capture.Frame) error {
+	if err := e.session.encode(frame.Image, e.clock.next(frame)); err != nil {
+		return err
+	}
+	e.frames++
+	return e.writeSamples()
+}
+
+// writeSamples moves encoded frames from the native encoder to the MP4
+func (e *VideoEncoder) writeSamples() error {
+	for _, s := range e.session.samples() {
+		if e.mp4.sps == nil {
+			e.mp4.setParameterSets(e.session.parameterSets())
+		}
+		if err := e.mp4.writeSample(s.data, s.pts, s.keyframe); err != nil {
+			return fmt.Errorf("failed to write video: %w", err)
+		}
+	}
+	return nil
+}
+
+// closeNative waits for the native encoder and finishes the MP4. The last
+// frame lasts one frame interval.
+func (e *VideoEncoder) closeNative() error {
+	defer e.session.close()
+
+	err := e.session.flush()
+	if err == nil {
+		err = e.writeSamples()
+	}
+	if err == nil {
+		if err = e.mp4.close(e.fps.FrameDuration()); err != nil {
+			err = fmt.Errorf("failed to write video: %w", err)
+		}
+	}
+	if closeErr := e.file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write video: %w", closeErr)
+	}
+	return err
+}
+
+// spoolFrame saves a frame to the spool file and tracks its regions of
+// interest, numbering frames as they will be sent to ffmpeg
+func (e *VideoEncoder) spoolFrame(frame *capture.Frame) error {
+	n := e.clock.slot(frame, e.fps, e.frames)
+	if err := e.spooled.AddFrame(frame); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	e.trackROI(frame.Image, n)
+	e.frames = n + 1
+	return nil
+}
+
+// encodeSpool streams the spooled frames to ffmpeg with the ROI filter
+func (e *VideoEncoder) encodeSpool() error {
+	defer os.Remove(e.spool.Name())
+
+	err := e.spooled.Close()
+	if closeErr := e.spool.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write temporary file: %w", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	r, err := replay.Open(e.spool.Name())
+	if err != nil {
+		return fmt.Errorf("failed to read temporary file: %w", err)
+	}
+	defer r.Close()
+
+	var filter, graph string
+	switch segments := e.roiSegments(); len(segments) {
+	case 0:
+	case 1:
+		filter = FFmpegFilter(segments[0].Regions, e.bounds.Min)
+	default:
+		graph = FFmpegSegmentFilter(segments, e.bounds.Min)
+	}
+	e.proc = newFFmpegProcess(e.ffmpeg, e.args("-", filter, graph), e.out)
+	if err := e.proc.start(); err != nil {
+		return err
+	}
+
+	e.y4m = NewY4MEncoder(e.proc.buf, e.fps)
+	for {
+		frame, err := r.ReadFrame()
+		if err == io.EOF {
+			return e.proc.close()
+		}
+		if err != nil {
+			e.proc.close()
+			return fmt.Errorf("failed to read temporary file: %w", err)
+		}
+		if err := e.y4m.AddFrame(frame); err != nil {
+			e.proc.close()
+			return e.proc.fail(err)
+		}
+	}
+}
+
+// args returns the ffmpeg arguments for encoding Y4M from input ("-" for
+// stdin) with an optional extra video filter, or a filter graph writing
+// [roi] as FFmpegSegmentFilter's does
+func (e *VideoEncoder) args(input, filter, graph string) []string {
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-f", "yuv4mpegpipe", "-i", input,
+	}
+	switch {
+	case graph != "":
+		args = append(args, "-filter_complex", graph+";[roi]"+evenSize+"[video]", "-map", "[video]")
+	case filter != "":
+		args = append(args, "-vf", filter+","+evenSize)
+	default:
+		args = append(args, "-vf", evenSize)
+	}
+
+	args = append(args,
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-profile:v", e.profile.String(),
+		"-pix_fmt", "yuv420p",
+	)
+	if e.bitrate > 0 {
+		args = append(args, "-b:v", strconv.Itoa(e.bitrate))
+	} else {
+		args = append(args, "-crf", strconv.Itoa(crf(e.quality)))
+	}
+	if e.keyframeInterval > 0 {
+		args = append(args, "-g", strconv.Itoa(e.keyframeInterval))
+	}
+
+	if e.out != nil {
+		// A plain MP4 needs a seekable output to write its index
+		return append(args, "-movflags", "frag_keyframe+empty_moov", "-f", "mp4", "-")
+	}
+	return append(args, "-movflags", "+faststart", e.outputPath)
+}
+
+// trackROI adds frame n of the video's cursor position and the pixels that
+// changed since the previous frame to the window holding it
+func (e *VideoEncoder) trackROI(img *image.RGBA, n int) {
+	w := n / max(1, e.fps.FramesIn(roiWindowLength))
+	for len(e.windows) <= w {
+		e.windows = append(e.windows, roiWindow{})
+	}
+	window := &e.windows[w]
+
+	b := img.Bounds()
+	if e.pointer != nil && e.area.Width > 0 && e.area.Height > 0 {
+		if p, err := e.pointer(); err == nil {
+			window.cursors = append(window.cursors, image.Pt(
+				b.Min.X+(p.X-e.area.X)*b.Dx()/e.area.Width,
+				b.Min.Y+(p.Y-e.area.Y)*b.Dy()/e.area.Height,
+			))
+		}
+	}
+
+	if e.prev != nil {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if !analyze.Similar(img.RGBAAt(x, y), e.prev.RGBAAt(x, y), analyze.DefaultTolerance) {
+					window.active = window.active.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+	}
+	e.prev = img
+}
+
+// roiSegments returns the regions of interest of each window, merging
+// neighbouring windows that share them. It returns nil when no window has
+// anything to focus on.
+func (e *VideoEncoder) roiSegments() []ROISegment {
+	windows := e.windows
+	length := max(1, e.fps.FramesIn(roiWindowLength))
+	if len(windows) > maxROISegments {
+		group := (len(windows) + maxROISegments - 1) / maxROISegments
+		var merged []roiWindow
+		for i := 0; i < len(windows); i += group {
+			var m roiWindow
+			for _, w := range windows[i:min(i+group, len(windows))] {
+				m.cursors = append(m.cursors, w.cursors...)
+				m.active = m.active.Union(w.active)
+			}
+			merged = append(merged, m)
+		}
+		windows, length = merged, length*group
+	}
+
+	var segments []ROISegment
+	focused := false
+	for i, w := range windows {
+		regions := e.roi.Regions(e.bounds, w.cursors, w.active)
+		focused = focused || regions != nil
+		if n := len(segments); n > 0 && reflect.DeepEqual(segments[n-1].Regions, regions) {
+			segments[n-1].End = (i + 1) * length
+			continue
+		}
+		segments = append(segments, ROISegment{Start: i * length, End: (i + 1) * length, Regions: regions})
+	}
+	if !focused {
+		return nil
+	}
+	// The last segment takes any frames repeated after it
+	segments[len(segments)-1].End = 0
+	return segments
+}
+
+// crf returns the x264 constant rate factor for a quality level. Lower
+// values give better quality and larger files.
+func crf(q GIFQuality) int {
+	switch q {
+	case QualityLow:
+		return 28
+	case QualityHigh:
+		return 18
+	default:
+		return 23
+	}
+}
@@ -0,0 +1,349 @@
+package encoder
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Helper function to create a video encoder that runs a fake ffmpeg. The
+// script copies its input (stdin or the -i file) to the last argument and
+// records its arguments next to the output.
+func fakeVideoEncoder(t *testing.T, output string) *VideoEncoder {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "ffmpeg")
+	body := `#!/bin/sh
+echo "$@" > "$WITNESS_FAKE_ARGS"
+input=-
+while [ $# -gt 1 ]; do
+	if [ "$1" = "-i" ]; then input=$2; fi
+	shift
+done
+if [ "$input" = "-" ]; then cat > "$1"; else cat "$input" > "$1"; fi
+`
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WITNESS_FAKE_ARGS", output+".args")
+
+	return &VideoEncoder{
+		outputPath: output,
+		fps:        capture.FPS30,
+		quality:    QualityHigh,
+		ffmpeg:     script,
+	}
+}
+
+// fakeH264Session stands in for a native encoder. Each frame is coded as a
+// single NAL unit holding its number, and is held back until the next frame
+// or a flush, as a real encoder finishes frames asynchronously.
+type fakeH264Session struct {
+	settings h264Settings
+	encoded  int
+	held     *h264Sample
+	done     []h264Sample
+	closed   bool
+}
+
+func (f *fakeH264Session) encode(img *image.RGBA, pts time.Duration) error {
+	f.flush()
+	f.held = &h264Sample{data: []byte{0, 0, 0, 1, byte(f.encoded)}, pts: pts, keyframe: f.encoded == 0}
+	f.encoded++
+	return nil
+}
+
+func (f *fakeH264Session) flush() error {
+	if f.held != nil {
+		f.done = append(f.done, *f.held)
+		f.held = nil
+	}
+	return nil
+}
+
+func (f *fakeH264Session) samples() []h264Sample {
+	samples := f.done
+	f.done = nil
+	return samples
+}
+
+func (f *fakeH264Session) parameterSets() (sps, pps []byte) {
+	return []byte{0x67, 0x64, 0, 0x1f}, []byte{0x68}
+}
+
+func (f *fakeH264Session) close() {
+	f.closed = true
+}
+
+// Helper function to create a video encoder that uses a fake native
+// encoder, returning the session once the first frame opens it
+func fakeNativeEncoder(t *testing.T, output string) (*VideoEncoder, **fakeH264Session) {
+	t.Helper()
+	var session *fakeH264Session
+	enc := &VideoEncoder{
+		outputPath: output,
+		fps:        capture.FPS30,
+		quality:    QualityMedium,
+		native: func(s h264Settings) (h264Session, error) {
+			session = &fakeH264Session{settings: s}
+			return session, nil
+		},
+	}
+	return enc, &session
+}
+
+func TestVideoEncoderNative(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.mp4")
+	enc, session := fakeNativeEncoder(t, output)
+	enc.SetKeyframeInterval(60)
+	enc.SetProfile(ProfileBaseline)
+
+	for i := 0; i < 3; i++ {
+		if err := enc.AddFrame(createTestFrame(9, 6, color.White)); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if enc.FrameCount() != 3 {
+		t.Errorf("FrameCount() = %d, want 3", enc.FrameCount())
+	}
+
+	want := h264Settings{
+		width: 10, height: 6, fps: capture.FPS30,
+		bitrate: defaultBitrate(QualityMedium, 10, 6, capture.FPS30), keyframeInterval: 60, profile: ProfileBaseline,
+	}
+	if (*session).settings != want {
+		t.Errorf("settings = %+v, want %+v", (*session).settings, want)
+	}
+	if !(*session).closed {
+		t.Error("native session was not closed")
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("output not written: %v", err)
+	}
+	mdat := findBox(t, data, "mdat")
+	if !bytes.Equal(mdat, []byte{0, 0, 0, 1, 0, 0, 0, 0, 1, 1, 0, 0, 0, 1, 2}) {
+		t.Errorf("mdat = %v, want all three frames, including the one held until Close", mdat)
+	}
+	stsz := findBox(t, data, "moov", "trak", "mdia", "minf", "stbl", "stsz")
+	if n := field32(stsz, 1); n != 3 {
+		t.Errorf("sample count = %d, want 3", n)
+	}
+}
+
+func TestVideoEncoderNativeBitrate(t *testing.T) {
+	enc, session := fakeNativeEncoder(t, filepath.Join(t.TempDir(), "out.mp4"))
+	enc.SetBitrate(4000000)
+
+	if err := enc.AddFrame(createTestFrame(8, 6, color.White)); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if got := (*session).settings.bitrate; got != 4000000 {
+		t.Errorf("bitrate = %d, want 4000000", got)
+	}
+}
+
+func TestVideoEncoderNativeNeedsFFmpeg(t *testing.T) {
+	// Streaming and ROI go through ffmpeg even with a native encoder
+	tests := []struct {
+		name  string
+		setup func(enc *VideoEncoder)
+	}{
+		{"stdout", func(enc *VideoEncoder) { enc.SetOutput(&bytes.Buffer{}) }},
+		{"roi", func(enc *VideoEncoder) { enc.SetROI(DefaultROIConfig()) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, session := fakeNativeEncoder(t, filepath.Join(t.TempDir(), "out.mp4"))
+			tt.setup(enc)
+			err := enc.AddFrame(createTestFrame(8, 6, color.White))
+			if err == nil || !strings.Contains(err.Error(), "ffmpeg is required") {
+				t.Errorf("AddFrame() error = %v, want ffmpeg required", err)
+			}
+			if *session != nil {
+				t.Error("native encoder should not be used")
+			}
+		})
+	}
+}
+
+func TestVideoEncoderStreams(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.mp4")
+	enc := fakeVideoEncoder(t, output)
+
+	for i := 0; i < 3; i++ {
+		if err := enc.AddFrame(createTestFrame(8, 6, color.White)); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if enc.FrameCount() != 3 {
+		t.Errorf("FrameCount() = %d, want 3", enc.FrameCount())
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("output not written: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("YUV4MPEG2 W8 H6 F30:1")) {
+		t.Errorf("ffmpeg input does not start with a Y4M header: %q", data[:min(len(data), 40)])
+	}
+
+	args, _ := os.ReadFile(output + ".args")
+	for _, want := range []string{"-i -", "-crf 18", "libx264", "+faststart"} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("ffmpeg args %q should contain %q", args, want)
+		}
+	}
+}
+
+func TestVideoEncoderROI(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	output := filepath.Join(t.TempDir(), "out.mp4")
+	enc := fakeVideoEncoder(t, output)
+	enc.SetROI(DefaultROIConfig())
+
+	frames := []*capture.Frame{
+		createTestFrame(400, 300, color.Black),
+		createTestFrame(400, 300, color.Black),
+	}
+	frames[1].Image.SetRGBA(10, 20, color.RGBA{R: 255, A: 255})
+
+	for _, f := range frames {
+		if err := enc.AddFrame(f); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	args, _ := os.ReadFile(output + ".args")
+	if !strings.Contains(string(args), "addroi=x=10:y=20:w=1:h=1:qoffset=-0.3") {
+		t.Errorf("ffmpeg args %q should focus on the changed pixel", args)
+	}
+
+	// The spooled frames are sent to ffmpeg as Y4M, and the spool removed
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("output not written: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("YUV4MPEG2 W400 H300 F30:1")) || bytes.Count(data, []byte("FRAME\n")) != 2 {
+		t.Errorf("ffmpeg input is not the two spooled frames as Y4M: %q", data[:min(len(data), 40)])
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("spool file %s was not removed", entries[0].Name())
+	}
+}
+
+func TestVideoEncoderROIWindows(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.mp4")
+	enc := fakeVideoEncoder(t, output)
+	config := DefaultROIConfig()
+	config.CursorRadius = 10
+	enc.SetROI(config)
+
+	// Frames are twice the size of the area they show. The pointer is
+	// only known in the last second.
+	added := 0
+	enc.SetROICursor(func() (image.Point, error) {
+		if added < 60 {
+			return image.Point{}, capture.ErrUnsupportedPlatform
+		}
+		return image.Pt(1045, 525), nil
+	}, capture.Region{X: 1000, Y: 500, Width: 100, Height: 50})
+
+	// Three seconds at 30 fps: a pixel blinks in the first second, another
+	// in the second, and nothing changes in the third
+	red := color.RGBA{R: 255, A: 255}
+	for i := 0; i < 90; i++ {
+		frame := createTestFrame(200, 100, color.Black)
+		frame.Timestamp = capture.Epoch.Add(capture.FPS30.FrameTime(i))
+		if i%2 == 1 && i < 29 {
+			frame.Image.SetRGBA(10, 10, red)
+		}
+		if i%2 == 1 && i > 30 && i < 59 {
+			frame.Image.SetRGBA(150, 80, red)
+		}
+		added = i
+		if err := enc.AddFrame(frame); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	args, _ := os.ReadFile(output + ".args")
+	for _, want := range []string{
+		"[in0]trim=start_frame=0:end_frame=30,setpts=PTS-STARTPTS,addroi=x=10:y=10:w=1:h=1:qoffset=-0.3,addroi=x=0:y=0:w=200:h=100:qoffset=0.3[out0]",
+		"[in1]trim=start_frame=30:end_frame=60,setpts=PTS-STARTPTS,addroi=x=150:y=80:w=1:h=1:qoffset=-0.3,",
+		"[in2]trim=start_frame=60,setpts=PTS-STARTPTS,addroi=x=80:y=40:w=20:h=20:qoffset=-0.3,",
+		"concat=n=3:v=1:a=0[roi];[roi]" + evenSize + "[video] -map [video]",
+	} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("ffmpeg args %q should contain %q", args, want)
+		}
+	}
+}
+
+func TestVideoEncoderOptions(t *testing.T) {
+	enc := fakeVideoEncoder(t, "out.mp4")
+	enc.SetBitrate(2500000)
+	enc.SetKeyframeInterval(60)
+	enc.SetProfile(ProfileMain)
+
+	args := strings.Join(enc.args("-", "", ""), " ")
+	for _, want := range []string{"-b:v 2500000", "-g 60", "-profile:v main"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args %q should contain %q", args, want)
+		}
+	}
+	if strings.Contains(args, "-crf") {
+		t.Errorf("args %q should not set a CRF with a bitrate", args)
+	}
+}
+
+func TestVideoEncoderStdout(t *testing.T) {
+	enc := fakeVideoEncoder(t, "")
+	enc.SetOutput(&bytes.Buffer{})
+
+	args := strings.Join(enc.args("-", "", ""), " ")
+	if !strings.HasSuffix(args, "-movflags frag_keyframe+empty_moov -f mp4 -") {
+		t.Errorf("args = %q, want fragmented MP4 on stdout", args)
+	}
+}
+
+func TestVideoEncoderNoFrames(t *testing.T) {
+	enc := fakeVideoEncoder(t, filepath.Join(t.TempDir(), "out.mp4"))
+	if err := enc.Close(); err == nil {
+		t.Error("expected error when closing without frames")
+	}
+	if err := enc.AddFrame(nil); err == nil {
+		t.Error("expected error for nil frame")
+	}
+}
+
+func TestCRF(t *testing.T) {
+	if !(crf(QualityHigh) < crf(QualityMedium) && crf(QualityMedium) < crf(QualityLow)) {
+		t.Errorf("crf should fall as quality rises: low=%d medium=%d high=%d",
+			crf(QualityLow), crf(QualityMedium), crf(QualityHigh))
+	}
+}
@@ -0,0 +1,132 @@
+package encoder
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Y4MEncoder streams frames as a YUV4MPEG2 (Y4M) video for interchange with
+// ffmpeg-based pipelines. Frames are written as they arrive with full-range
+// 4:4:4 chroma, so no color resolution is lost. Y4M has a fixed frame rate,
+// so when frames were captured further apart than it, the previous frame is
+// repeated to keep the video in step with the capture timestamps.
+type Y4MEncoder struct {
+	w      io.Writer
+	fps    capture.FPS
+	clock  frameClock
+	width  int
+	height int
+	frames int
+	planes []byte
+}
+
+// NewY4MEncoder creates a Y4M encoder writing to w
+func NewY4MEncoder(w io.Writer, fps capture.FPS) *Y4MEncoder {
+	return &Y4MEncoder{w: w, fps: fps, clock: newFrameClock(fps)}
+}
+
+// AddFrame writes a frame, after repeating the previous one as often as
+// needed to reach the frame's capture time. The stream header is written
+// before the first frame, and every frame must have the same size.
+func (e *Y4MEncoder) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil {
+		return fmt.Errorf("invalid frame")
+	}
+
+	b := frame.Image.Bounds()
+	if e.frames == 0 {
+		e.width, e.height = b.Dx(), b.Dy()
+		e.planes = make([]byte, 3*e.width*e.height)
+		header := fmt.Sprintf("YUV4MPEG2 W%d H%d F%d:%d Ip A1:1 C444 XCOLORRANGE=FULL\n",
+			e.width, e.height, e.fps.Num, e.fps.Den)
+		if _, err := io.WriteString(e.w, header); err != nil {
+			return fmt.Errorf("failed to write Y4M header: %w", err)
+		}
+	} else if b.Dx() != e.width || b.Dy() != e.height {
+		return fmt.Errorf("frame size %dx%d does not match stream size %dx%d", b.Dx(), b.Dy(), e.width, e.height)
+	}
+
+	for slot := e.clock.slot(frame, e.fps, e.frames); e.frames < slot; {
+		if err := e.writePlanes(); err != nil {
+			return err
+		}
+	}
+
+	n := e.width * e.height
+	yPlane, cbPlane, crPlane := e.planes[:n], e.planes[n:2*n], e.planes[2*n:]
+	for y := 0; y < e.height; y++ {
+		for x := 0; x < e.width; x++ {
+			c := frame.Image.RGBAAt(b.Min.X+x, b.Min.Y+y)
+			i := y*e.width + x
+			yPlane[i], cbPlane[i], crPlane[i] = color.RGBToYCbCr(c.R, c.G, c.B)
+		}
+	}
+
+	return e.writePlanes()
+}
+
+// writePlanes writes the converted planes as the next frame
+func (e *Y4MEncoder) writePlanes() error {
+	if _, err := io.WriteString(e.w, "FRAME\n"); err != nil {
+		return fmt.Errorf("failed to write Y4M frame: %w", err)
+	}
+	if _, err := e.w.Write(e.planes); err != nil {
+		return fmt.Errorf("failed to write Y4M frame: %w", err)
+	}
+
+	e.frames++
+	return nil
+}
+
+// FrameCount returns the number of frames written
+func (e *Y4MEncoder) FrameCount() int {
+	return e.frames
+}
+
+// RawEncoder streams frames as headerless RGBA, matching ffmpeg's
+// "-f rawvideo -pix_fmt rgba". The frame size and rate are not recorded, so
+// the reader must be told them.
+type RawEncoder struct {
+	w      io.Writer
+	width  int
+	height int
+	frames int
+}
+
+// NewRawEncoder creates a raw RGBA encoder writing to w
+func NewRawEncoder(w io.Writer) *RawEncoder {
+	return &RawEncoder{w: w}
+}
+
+// AddFrame writes a frame. Every frame must have the same size.
+func (e *RawEncoder) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil {
+		return fmt.Errorf("invalid frame")
+	}
+
+	img := frame.Image
+	b := img.Bounds()
+	if e.frames == 0 {
+		e.width, e.height = b.Dx(), b.Dy()
+	} else if b.Dx() != e.width || b.Dy() != e.height {
+		return fmt.Errorf("frame size %dx%d does not match stream size %dx%d", b.Dx(), b.Dy(), e.width, e.height)
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y) : img.PixOffset(b.Min.X, y)+4*e.width]
+		if _, err := e.w.Write(row); err != nil {
+			return fmt.Errorf("failed to write raw frame: %w", err)
+		}
+	}
+
+	e.frames++
+	return nil
+}
+
+// FrameCount returns the number of frames written
+func (e *RawEncoder) FrameCount() int {
+	return e.frames
+}
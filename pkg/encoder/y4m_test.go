@@ -0,0 +1,105 @@
+package encoder
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestY4MEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewY4MEncoder(&buf, capture.IntFPS(12))
+
+	for i := 0; i < 2; i++ {
+		if err := enc.AddFrame(createTestFrame(4, 2, color.RGBA{R: 255, A: 255})); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	if enc.FrameCount() != 2 {
+		t.Errorf("FrameCount() = %d, want 2", enc.FrameCount())
+	}
+
+	header, body, ok := strings.Cut(buf.String(), "\n")
+	if !ok {
+		t.Fatal("missing stream header")
+	}
+	if want := "YUV4MPEG2 W4 H2 F12:1 Ip A1:1 C444 XCOLORRANGE=FULL"; header != want {
+		t.Errorf("header = %q, want %q", header, want)
+	}
+
+	frameSize := len("FRAME\n") + 3*4*2
+	if len(body) != 2*frameSize {
+		t.Errorf("body length = %d, want %d", len(body), 2*frameSize)
+	}
+
+	y, cb, cr := color.RGBToYCbCr(255, 0, 0)
+	planes := body[len("FRAME\n"):frameSize]
+	if planes[0] != y || planes[8] != cb || planes[16] != cr {
+		t.Errorf("first pixel = %d,%d,%d, want %d,%d,%d", planes[0], planes[8], planes[16], y, cb, cr)
+	}
+}
+
+func TestY4MEncoderSizeChange(t *testing.T) {
+	enc := NewY4MEncoder(&bytes.Buffer{}, capture.FPS30)
+	if err := enc.AddFrame(createTestFrame(4, 2, color.White)); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if err := enc.AddFrame(createTestFrame(2, 2, color.White)); err == nil {
+		t.Error("expected error for a frame of a different size")
+	}
+	if err := enc.AddFrame(nil); err == nil {
+		t.Error("expected error for nil frame")
+	}
+}
+
+func TestY4MEncoderTimestamps(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewY4MEncoder(&buf, capture.IntFPS(10))
+
+	// The capturer fell behind for 0.3s after the first frame, then a
+	// resumed recording jumped ahead by a minute
+	at := []time.Duration{0, 300 * time.Millisecond, 390 * time.Millisecond, time.Minute}
+	for i, d := range at {
+		frame := createTestFrame(4, 2, color.White)
+		frame.Timestamp = capture.Epoch.Add(d)
+		frame.Discontinuity = i == 3
+		if err := enc.AddFrame(frame); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+
+	// Frames land at 0, 0.3s, 0.4s, and one frame after that
+	if enc.FrameCount() != 6 {
+		t.Errorf("FrameCount() = %d, want 6", enc.FrameCount())
+	}
+	if got := strings.Count(buf.String(), "FRAME\n"); got != 6 {
+		t.Errorf("wrote %d frames, want 6", got)
+	}
+}
+
+func TestRawEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewRawEncoder(&buf)
+
+	blue := color.RGBA{B: 255, A: 255}
+	if err := enc.AddFrame(createTestFrame(3, 2, blue)); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if err := enc.AddFrame(createTestFrame(2, 3, blue)); err == nil {
+		t.Error("expected error for a frame of a different size")
+	}
+
+	if enc.FrameCount() != 1 {
+		t.Errorf("FrameCount() = %d, want 1", enc.FrameCount())
+	}
+	if buf.Len() != 3*2*4 {
+		t.Errorf("output length = %d, want %d", buf.Len(), 3*2*4)
+	}
+	if got := buf.Bytes()[:4]; !bytes.Equal(got, []byte{0, 0, 255, 255}) {
+		t.Errorf("first pixel = %v, want blue", got)
+	}
+}
@@ -0,0 +1,144 @@
+package ocr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// IndexExtension is added to a recording's name to give its index sidecar
+const IndexExtension = ".index.json"
+
+// Index is a JSON sidecar holding the transcript of a recording, so it can
+// be searched without running OCR again. It records the recording's size
+// and modification time and the settings it was read with, to tell when it
+// is out of date.
+type Index struct {
+	// Recording is the base name of the indexed recording
+	Recording string `json:"recording"`
+
+	// Size and ModTime identify the version of the recording indexed
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+
+	// EveryMS and Language are the OCR settings used
+	EveryMS  int64  `json:"every_ms"`
+	Language string `json:"language"`
+
+	// Entries is the transcript, in recording order
+	Entries []IndexEntry `json:"entries"`
+}
+
+// IndexEntry is the text on screen from TimeMS until the next entry
+type IndexEntry struct {
+	TimeMS int64  `json:"time_ms"`
+	Text   string `json:"text"`
+}
+
+// Match is a place in a recording where searched-for text appeared
+type Match struct {
+	// Offset is the time since the first frame
+	Offset time.Duration
+
+	// Line is the first line of text on screen containing the match
+	Line string
+}
+
+// IndexPath returns where the index for recording is kept
+func IndexPath(recording string) string {
+	return recording + IndexExtension
+}
+
+// NewIndex creates an index of entries, read from the recording described
+// by info with the given OCR settings
+func NewIndex(info os.FileInfo, every time.Duration, language string, entries []Entry) *Index {
+	x := &Index{
+		Recording: info.Name(),
+		Size:      info.Size(),
+		ModTime:   info.ModTime().UTC(),
+		EveryMS:   every.Milliseconds(),
+		Language:  language,
+		Entries:   make([]IndexEntry, len(entries)),
+	}
+	for i, e := range entries {
+		x.Entries[i] = IndexEntry{TimeMS: e.Offset.Milliseconds(), Text: e.Text}
+	}
+	return x
+}
+
+// LoadIndex reads an index sidecar file
+func LoadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var x Index
+	if err := json.Unmarshal(data, &x); err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", filepath.Base(path), err)
+	}
+	return &x, nil
+}
+
+// Save writes the index as indented JSON
+func (x *Index) Save(path string) error {
+	data, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// Current reports whether the index still describes the recording in info
+// read with the given OCR settings
+func (x *Index) Current(info os.FileInfo, every time.Duration, language string) bool {
+	return x.Size == info.Size() &&
+		x.ModTime.Equal(info.ModTime()) &&
+		x.EveryMS == every.Milliseconds() &&
+		x.Language == language
+}
+
+// Search returns each time query appeared on screen, ignoring case. Text
+// that stays on screen across several entries is reported once, when it
+// first appeared.
+func (x *Index) Search(query string) []Match {
+	query = strings.ToLower(query)
+	var matches []Match
+	shown := false
+
+	for _, e := range x.Entries {
+		line, found := matchLine(e.Text, query)
+		if found && !shown {
+			matches = append(matches, Match{
+				Offset: time.Duration(e.TimeMS) * time.Millisecond,
+				Line:   line,
+			})
+		}
+		shown = found
+	}
+
+	return matches
+}
+
+// matchLine returns the first line of text containing query, which must
+// be lower case
+func matchLine(text, query string) (string, bool) {
+	if !strings.Contains(strings.ToLower(text), query) {
+		return "", false
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.Contains(strings.ToLower(line), query) {
+			return strings.TrimSpace(line), true
+		}
+	}
+	// The match spans lines
+	return strings.TrimSpace(strings.SplitN(text, "\n", 2)[0]), true
+}
@@ -0,0 +1,117 @@
+package ocr
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndexSearch(t *testing.T) {
+	sec := time.Second
+	_, info := writeRecording(t)
+	x := NewIndex(info, time.Second, "eng", []Entry{
+		{0, "$ make test"},
+		{5 * sec, "$ make test\npanic: NullPointerException\nexit 2"},
+		{6 * sec, "$ make test\nPANIC: NullPointerException\nexit 2\n$"},
+		{9 * sec, "$ clear"},
+		{12 * sec, "error: nullpointerexception again"},
+		{15 * sec, "Null\nPointer"},
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []Match
+	}{
+		{
+			name:  "reported when it appears, ignoring case",
+			query: "NullPointerException",
+			want: []Match{
+				{5 * sec, "panic: NullPointerException"},
+				{12 * sec, "error: nullpointerexception again"},
+			},
+		},
+		{
+			name:  "spanning lines",
+			query: "null\npointer",
+			want:  []Match{{15 * sec, "Null"}},
+		},
+		{
+			name:  "no match",
+			query: "segfault",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := x.Search(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexSaveLoad(t *testing.T) {
+	recording, info := writeRecording(t)
+	x := NewIndex(info, time.Second, "eng", []Entry{{1500 * time.Millisecond, "hello"}})
+
+	path := IndexPath(recording)
+	if err := x.Save(path); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	loaded, err := LoadIndex(path)
+	if err != nil {
+		t.Fatalf("LoadIndex() failed: %v", err)
+	}
+	if loaded.Recording != "demo.gif" || !reflect.DeepEqual(loaded.Entries, []IndexEntry{{1500, "hello"}}) {
+		t.Errorf("LoadIndex() = %+v, want the saved index", loaded)
+	}
+
+	if !loaded.Current(info, time.Second, "eng") {
+		t.Error("Current() = false for an unchanged recording")
+	}
+	if loaded.Current(info, 2*time.Second, "eng") || loaded.Current(info, time.Second, "deu") {
+		t.Error("Current() = true with different OCR settings")
+	}
+	later := info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(recording, later, later); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := os.Stat(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Current(changed, time.Second, "eng") {
+		t.Error("Current() = true after the recording changed")
+	}
+}
+
+func TestLoadIndexErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadIndex(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadIndex() should fail for a missing file")
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadIndex(bad); err == nil {
+		t.Error("LoadIndex() should fail for invalid JSON")
+	}
+}
+
+// Helper function to create a recording file and return its path and info
+func writeRecording(t *testing.T) (string, os.FileInfo) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "demo.gif")
+	if err := os.WriteFile(path, []byte("GIF89a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, info
+}
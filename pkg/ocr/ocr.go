@@ -0,0 +1,161 @@
+// Package ocr extracts the text shown in a recording, using the tesseract
+// OCR engine, to give a searchable log of what was on screen
+package ocr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// TesseractBinary is the tesseract command used for OCR
+const TesseractBinary = "tesseract"
+
+// Reader supplies frames to transcribe, such as a source.Reader
+type Reader interface {
+	// ReadFrame returns the next frame, or io.EOF when there are no more
+	ReadFrame() (*capture.Frame, error)
+}
+
+// Recognizer reads the text in an image
+type Recognizer interface {
+	Text(img image.Image) (string, error)
+}
+
+// Tesseract recognizes text by running the tesseract command
+type Tesseract struct {
+	// Language is the tesseract language code, e.g. "eng" or "eng+deu"
+	Language string
+
+	path string
+}
+
+// NewTesseract creates a recognizer for language. It fails if tesseract is
+// not installed.
+func NewTesseract(language string) (*Tesseract, error) {
+	path, err := exec.LookPath(TesseractBinary)
+	if err != nil {
+		return nil, fmt.Errorf("tesseract is required for OCR (install it with 'brew install tesseract' or 'apt install tesseract-ocr'): %w", err)
+	}
+	return newTesseract(path, language), nil
+}
+
+// newTesseract creates a recognizer that runs the given tesseract binary
+func newTesseract(path, language string) *Tesseract {
+	return &Tesseract{Language: language, path: path}
+}
+
+// Text passes img to tesseract as a PNG and returns the text it finds
+func (t *Tesseract) Text(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("failed to encode frame: %w", err)
+	}
+
+	args := []string{"stdin", "stdout"}
+	if t.Language != "" {
+		args = append(args, "-l", t.Language)
+	}
+	cmd := exec.Command(t.path, args...)
+	cmd.Stdin = &buf
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("tesseract failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// Entry is the text on screen from Offset until the next entry
+type Entry struct {
+	// Offset is the time since the first frame
+	Offset time.Duration
+
+	// Text is the recognized text, with blank lines and trailing spaces
+	// removed
+	Text string
+}
+
+// Transcribe runs OCR on a frame from src once every interval of recording
+// time, and returns an entry each time the text on screen changes. Frames
+// with no text are left out. An interval of 0 reads every frame.
+func Transcribe(src Reader, every time.Duration, r Recognizer) ([]Entry, error) {
+	var entries []Entry
+	var start, next time.Time
+	last := ""
+
+	for n := 0; ; n++ {
+		frame, err := src.ReadFrame()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		if n == 0 {
+			start, next = frame.Timestamp, frame.Timestamp
+		}
+		if frame.Timestamp.Before(next) {
+			continue
+		}
+		next = next.Add(every)
+		for every > 0 && !frame.Timestamp.Before(next) {
+			next = next.Add(every) // Catch up after a gap in the recording
+		}
+
+		raw, err := r.Text(frame.Image)
+		if err != nil {
+			return entries, fmt.Errorf("frame %d: %w", n, err)
+		}
+		text := clean(raw)
+		if text != last && text != "" {
+			entries = append(entries, Entry{Offset: frame.Timestamp.Sub(start), Text: text})
+		}
+		last = text
+	}
+}
+
+// clean drops blank lines and trailing spaces from OCR output
+func clean(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r\f")
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteTranscript writes entries as blocks of text, each headed by its
+// offset into the recording, e.g. "[01:05]"
+func WriteTranscript(w io.Writer, entries []Entry) error {
+	for i, e := range entries {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "[%s]\n%s\n", FormatOffset(e.Offset), e.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatOffset formats d as MM:SS, or HH:MM:SS from an hour on
+func FormatOffset(d time.Duration) string {
+	s := int(d / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
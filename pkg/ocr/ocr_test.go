@@ -0,0 +1,181 @@
+package ocr
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// frameReader returns a frame at each offset, with the offset's index in
+// its first pixel
+type frameReader struct {
+	offsets []time.Duration
+	n       int
+}
+
+func (r *frameReader) ReadFrame() (*capture.Frame, error) {
+	if r.n >= len(r.offsets) {
+		return nil, io.EOF
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Pix[0] = uint8(r.n)
+	frame := &capture.Frame{Image: img, Timestamp: capture.Epoch.Add(r.offsets[r.n])}
+	r.n++
+	return frame, nil
+}
+
+// fakeRecognizer returns texts[i] for frame i and records which frames it saw
+type fakeRecognizer struct {
+	texts []string
+	seen  []int
+}
+
+func (f *fakeRecognizer) Text(img image.Image) (string, error) {
+	i := int(img.(*image.RGBA).Pix[0])
+	f.seen = append(f.seen, i)
+	return f.texts[i], nil
+}
+
+// Helper function to build offsets one per step
+func offsets(n int, step time.Duration) []time.Duration {
+	out := make([]time.Duration, n)
+	for i := range out {
+		out[i] = time.Duration(i) * step
+	}
+	return out
+}
+
+func TestTranscribe(t *testing.T) {
+	sec := time.Second
+	tests := []struct {
+		name    string
+		offsets []time.Duration
+		texts   []string
+		every   time.Duration
+		seen    []int
+		want    []Entry
+	}{
+		{
+			name:    "samples every interval",
+			offsets: offsets(6, 500*time.Millisecond),
+			texts:   []string{"a", "x", "b", "x", "c", "x"},
+			every:   sec,
+			seen:    []int{0, 2, 4},
+			want:    []Entry{{0, "a"}, {sec, "b"}, {2 * sec, "c"}},
+		},
+		{
+			name:    "zero interval reads every frame",
+			offsets: offsets(3, sec),
+			texts:   []string{"a", "b", "c"},
+			seen:    []int{0, 1, 2},
+			want:    []Entry{{0, "a"}, {sec, "b"}, {2 * sec, "c"}},
+		},
+		{
+			name:    "unchanged and blank text left out",
+			offsets: offsets(5, sec),
+			texts:   []string{"a\n\n", "a  ", " \n", "a", "b"},
+			every:   sec,
+			seen:    []int{0, 1, 2, 3, 4},
+			want:    []Entry{{0, "a"}, {3 * sec, "a"}, {4 * sec, "b"}},
+		},
+		{
+			name:    "catches up after a gap",
+			offsets: []time.Duration{0, 5 * sec, 5500 * time.Millisecond, 6 * sec},
+			texts:   []string{"a", "b", "c", "d"},
+			every:   sec,
+			seen:    []int{0, 1, 3},
+			want:    []Entry{{0, "a"}, {5 * sec, "b"}, {6 * sec, "d"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRecognizer{texts: tt.texts}
+			got, err := Transcribe(&frameReader{offsets: tt.offsets}, tt.every, r)
+			if err != nil {
+				t.Fatalf("Transcribe() failed: %v", err)
+			}
+			if !reflect.DeepEqual(r.seen, tt.seen) {
+				t.Errorf("recognized frames %v, want %v", r.seen, tt.seen)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Transcribe() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+type failingRecognizer struct{}
+
+func (failingRecognizer) Text(image.Image) (string, error) {
+	return "", errors.New("no engine")
+}
+
+func TestTranscribeError(t *testing.T) {
+	_, err := Transcribe(&frameReader{offsets: offsets(2, time.Second)}, time.Second, failingRecognizer{})
+	if err == nil || !strings.Contains(err.Error(), "no engine") {
+		t.Errorf("Transcribe() error = %v, want the recognizer's error", err)
+	}
+}
+
+func TestWriteTranscript(t *testing.T) {
+	var buf bytes.Buffer
+	entries := []Entry{
+		{0, "$ make test"},
+		{65 * time.Second, "PASS\nok"},
+		{3725 * time.Second, "done"},
+	}
+	if err := WriteTranscript(&buf, entries); err != nil {
+		t.Fatalf("WriteTranscript() failed: %v", err)
+	}
+
+	want := "[00:00]\n$ make test\n\n[01:05]\nPASS\nok\n\n[01:02:05]\ndone\n"
+	if buf.String() != want {
+		t.Errorf("WriteTranscript() = %q, want %q", buf.String(), want)
+	}
+}
+
+// Helper function to create a recognizer that runs a fake tesseract script
+func fakeTesseract(t *testing.T, script string) *Tesseract {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tesseract is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "tesseract")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return newTesseract(path, "eng")
+}
+
+func TestTesseract(t *testing.T) {
+	// Echo the arguments, and check a PNG arrived on stdin
+	tess := fakeTesseract(t, `head -c 8 | od -c | grep -q P && echo "$@"`)
+	text, err := tess.Text(image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	if err != nil {
+		t.Fatalf("Text() failed: %v", err)
+	}
+	if got := strings.TrimSpace(text); got != "stdin stdout -l eng" {
+		t.Errorf("tesseract args = %q, want %q", got, "stdin stdout -l eng")
+	}
+
+	tess = fakeTesseract(t, `echo "Failed loading language 'xx'" >&2; exit 1`)
+	if _, err := tess.Text(image.NewRGBA(image.Rect(0, 0, 2, 2))); err == nil || !strings.Contains(err.Error(), "Failed loading language") {
+		t.Errorf("Text() error = %v, want tesseract's message", err)
+	}
+}
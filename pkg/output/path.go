@@ -0,0 +1,142 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Stdout is the output name that writes the encoded file to standard output
+const Stdout = "-"
+
+// placeholder matches {name} in an output directory template
+var placeholder = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// ExpandDir expands an output directory template. A leading ~ becomes the
+// home directory and {year}, {month}, {day}, {date}, and {time} are replaced
+// using now.
+func ExpandDir(template string, now time.Time) (string, error) {
+	dir := template
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, dir[1:])
+	}
+
+	var unknown string
+	dir = placeholder.ReplaceAllStringFunc(dir, func(m string) string {
+		switch m[1 : len(m)-1] {
+		case "year":
+			return now.Format("2006")
+		case "month":
+			return now.Format("01")
+		case "day":
+			return now.Format("02")
+		case "date":
+			return now.Format("2006-01-02")
+		case "time":
+			return now.Format("150405")
+		default:
+			unknown = m
+			return m
+		}
+	})
+	if unknown != "" {
+		return "", fmt.Errorf("unknown placeholder %s in output directory %q", unknown, template)
+	}
+
+	return dir, nil
+}
+
+// Resolve returns the path to write a recording named name. A bare file
+// name is placed in the expanded dir template, which is created if needed;
+// paths with a directory component, Stdout, and an empty dir are used as
+// given.
+func Resolve(name, dir string, now time.Time) (string, error) {
+	if dir == "" || name == Stdout || filepath.Base(name) != name {
+		return name, nil
+	}
+
+	expanded, err := ExpandDir(dir, now)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(expanded, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	return filepath.Join(expanded, name), nil
+}
+
+// maxSuffix bounds the search for a free file name in Unique and Reserve
+const maxSuffix = 9999
+
+// Unique returns path if nothing exists there, or otherwise the first free
+// name with a numbered suffix before the extension (demo-2.gif, demo-3.gif).
+// Stdout is returned unchanged.
+func Unique(path string) (string, error) {
+	return firstFree(path, func(candidate string) (bool, error) {
+		_, err := os.Lstat(candidate)
+		return os.IsNotExist(err), nil
+	})
+}
+
+// Reserve is Unique for recordings, which take a while to write their
+// output. The name it returns is created as an empty file, atomically, so
+// a recording started at the same time picks another name. Pass the name to
+// Release if the recording ends up not writing it.
+func Reserve(path string) (string, error) {
+	return firstFree(path, func(candidate string) (bool, error) {
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to create output file: %w", err)
+		}
+		return true, f.Close()
+	})
+}
+
+// Release removes a file created by Reserve, unless something has been
+// written to it since
+func Release(path string) {
+	if path == Stdout {
+		return
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() && info.Size() == 0 {
+		os.Remove(path)
+	}
+}
+
+// firstFree returns path, or the first name with a numbered suffix, that
+// free accepts. Stdout is returned unchanged.
+func firstFree(path string, free func(string) (bool, error)) (string, error) {
+	if path == Stdout {
+		return path, nil
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; i <= maxSuffix; i++ {
+		candidate := path
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		ok, err := free(candidate)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("no free file name for %s", path)
+}
@@ -0,0 +1,161 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2024, 3, 7, 14, 5, 9, 0, time.UTC)
+
+func TestExpandDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"recordings", "recordings"},
+		{"~/Recordings/{year}/{month}/", filepath.Join(home, "Recordings/2024/03")},
+		{"~", home},
+		{"/tmp/{date}", "/tmp/2024-03-07"},
+		{"out/{year}-{month}-{day}/{time}", "out/2024-03-07/140509"},
+		{"~user/clips", "~user/clips"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := ExpandDir(tt.template, testTime)
+			if err != nil {
+				t.Fatalf("ExpandDir() failed: %v", err)
+			}
+			if filepath.Clean(got) != filepath.Clean(tt.want) {
+				t.Errorf("ExpandDir(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandDirUnknownPlaceholder(t *testing.T) {
+	if _, err := ExpandDir("out/{week}", testTime); err == nil {
+		t.Error("ExpandDir() should reject unknown placeholders")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	template := filepath.Join(dir, "{year}", "{month}")
+
+	got, err := Resolve("demo.gif", template, testTime)
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if want := filepath.Join(dir, "2024", "03", "demo.gif"); got != want {
+		t.Errorf("Resolve() = %q, want %q", got, want)
+	}
+	if info, err := os.Stat(filepath.Dir(got)); err != nil || !info.IsDir() {
+		t.Errorf("output directory was not created: %v", err)
+	}
+}
+
+func TestResolveKeepsExplicitPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  string
+	}{
+		{"demo.gif", ""},
+		{"clips/demo.gif", "/should/not/be/created"},
+		{"/tmp/demo.gif", "/should/not/be/created"},
+		{Stdout, "/should/not/be/created"},
+	}
+
+	for _, tt := range tests {
+		got, err := Resolve(tt.name, tt.dir, testTime)
+		if err != nil {
+			t.Fatalf("Resolve(%q, %q) failed: %v", tt.name, tt.dir, err)
+		}
+		if got != tt.name {
+			t.Errorf("Resolve(%q, %q) = %q, want it unchanged", tt.name, tt.dir, got)
+		}
+	}
+}
+
+func TestUnique(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "demo.gif")
+
+	got, err := Unique(path)
+	if err != nil || got != path {
+		t.Fatalf("Unique() = %q, %v; want the path unchanged", got, err)
+	}
+
+	os.WriteFile(path, nil, 0644)
+	got, err = Unique(path)
+	if err != nil || got != filepath.Join(dir, "demo-2.gif") {
+		t.Errorf("Unique() = %q, %v; want demo-2.gif", got, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "demo-2.gif"), nil, 0644)
+	got, err = Unique(path)
+	if err != nil || got != filepath.Join(dir, "demo-3.gif") {
+		t.Errorf("Unique() = %q, %v; want demo-3.gif", got, err)
+	}
+}
+
+func TestUniqueNoExtension(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "recording")
+	os.WriteFile(path, nil, 0644)
+
+	got, err := Unique(path)
+	if err != nil || got != path+"-2" {
+		t.Errorf("Unique() = %q, %v; want %q", got, err, path+"-2")
+	}
+}
+
+func TestUniqueStdout(t *testing.T) {
+	if got, err := Unique(Stdout); err != nil || got != Stdout {
+		t.Errorf("Unique(Stdout) = %q, %v; want it unchanged", got, err)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "demo.gif")
+
+	// Each reservation takes a different name, as concurrent recordings would
+	for _, want := range []string{"demo.gif", "demo-2.gif", "demo-3.gif"} {
+		got, err := Reserve(path)
+		if err != nil || got != filepath.Join(dir, want) {
+			t.Fatalf("Reserve() = %q, %v; want %s", got, err, want)
+		}
+		if info, err := os.Stat(got); err != nil || info.Size() != 0 {
+			t.Errorf("Reserve() should create %s empty", want)
+		}
+	}
+
+	if got, err := Reserve(Stdout); err != nil || got != Stdout {
+		t.Errorf("Reserve(Stdout) = %q, %v; want it unchanged", got, err)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	dir := t.TempDir()
+	unused := filepath.Join(dir, "unused.gif")
+	written := filepath.Join(dir, "written.gif")
+	Reserve(unused)
+	Reserve(written)
+	os.WriteFile(written, []byte("GIF89a"), 0644)
+
+	Release(unused)
+	Release(written)
+	if _, err := os.Stat(unused); !os.IsNotExist(err) {
+		t.Error("Release() should remove an unused reservation")
+	}
+	if _, err := os.Stat(written); err != nil {
+		t.Error("Release() should keep a file that was written")
+	}
+}
@@ -0,0 +1,341 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecordingExtensions are the file types a retention policy may delete.
+// Anything else in the recordings directory is left alone.
+var RecordingExtensions = []string{".gif", ".mp4", ".webm", ".mkv", ".wrec", ".y4m", ".rgba", ".mjpeg"}
+
+// Retention limits how much the recordings directory keeps. Zero fields
+// are not limited.
+type Retention struct {
+	// MaxSize is the most bytes of recordings to keep
+	MaxSize int64
+
+	// MaxAge is how long a recording is kept after it was last written
+	MaxAge time.Duration
+}
+
+// ParseRetention parses a size limit such as "10GB" or "500MB" and an age
+// limit such as "30d", "2w", or "12h". Either may be empty for no limit.
+func ParseRetention(maxSize, maxAge string) (Retention, error) {
+	var r Retention
+	var err error
+	if maxSize != "" {
+		if r.MaxSize, err = ParseSize(maxSize); err != nil {
+			return Retention{}, err
+		}
+	}
+	if maxAge != "" {
+		if r.MaxAge, err = ParseAge(maxAge); err != nil {
+			return Retention{}, err
+		}
+	}
+	return r, nil
+}
+
+// Limited reports whether the policy limits anything
+func (r Retention) Limited() bool {
+	return r.MaxSize > 0 || r.MaxAge > 0
+}
+
+// sizeUnits are the multipliers of ParseSize's suffixes, binary as
+// elsewhere in witness
+var sizeUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+	{"t", 1 << 40}, {"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10},
+	{"b", 1},
+}
+
+// ParseSize parses a byte count with an optional unit: B, KB, MB, GB, or TB
+// (or K, M, G, T), ignoring case
+func ParseSize(s string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	multiplier := 1.0
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, u.suffix)), u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q: want a positive size such as 500MB or 10GB", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+// ParseAge parses a duration in days ("30d"), weeks ("2w"), or any unit
+// time.ParseDuration accepts ("12h")
+func ParseAge(s string) (time.Duration, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	var d time.Duration
+	var err error
+	switch {
+	case strings.HasSuffix(value, "d"):
+		var n float64
+		n, err = strconv.ParseFloat(strings.TrimSuffix(value, "d"), 64)
+		d = time.Duration(n * float64(24*time.Hour))
+	case strings.HasSuffix(value, "w"):
+		var n float64
+		n, err = strconv.ParseFloat(strings.TrimSuffix(value, "w"), 64)
+		d = time.Duration(n * float64(7*24*time.Hour))
+	default:
+		d, err = time.ParseDuration(value)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q: want a positive age such as 30d, 2w, or 12h", s)
+	}
+	return d, nil
+}
+
+// Root returns the directory under which an output directory template
+// writes every recording: the part before its first placeholder, with ~
+// expanded. ~/Recordings/{year}/{month} gives ~/Recordings.
+func Root(template string) (string, error) {
+	fixed := template
+	if loc := placeholder.FindStringIndex(fixed); loc != nil {
+		fixed = fixed[:loc[0]]
+		// A placeholder part way through a name, as in shots-{date},
+		// leaves the directory holding it
+		if !strings.HasSuffix(fixed, "/") && !strings.HasSuffix(fixed, string(filepath.Separator)) {
+			fixed = filepath.Dir(fixed)
+		}
+	}
+	root, err := ExpandDir(filepath.Clean(fixed), time.Time{})
+	if err != nil {
+		return "", err
+	}
+
+	// Pruning a root this broad could delete recordings kept elsewhere
+	home, _ := os.UserHomeDir()
+	if !filepath.IsAbs(root) || root == filepath.Dir(root) || root == filepath.Clean(home) {
+		return "", fmt.Errorf("output directory %q has no recordings directory of its own to prune; use one such as ~/Recordings/{year}", template)
+	}
+	return root, nil
+}
+
+// ManifestName is the file in a recordings directory's root that lists,
+// one path relative to the root per line, the recordings witness wrote
+// there. Retention only ever deletes recordings listed in it, so files
+// other programs or the user put in the directory are never touched.
+const ManifestName = ".witness-recordings"
+
+// Track adds path, a recording witness is writing under root, to root's
+// manifest so a retention policy may later delete it
+func Track(root, path string) error {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || escapesRoot(rel) {
+		return fmt.Errorf("recording %q is not under %q", path, root)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(root, ManifestName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open recordings manifest: %w", err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, filepath.ToSlash(rel)); err != nil {
+		return fmt.Errorf("failed to write recordings manifest: %w", err)
+	}
+	return nil
+}
+
+// escapesRoot reports whether the relative path rel leads out of its root
+func escapesRoot(rel string) bool {
+	rel = filepath.Clean(rel)
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readManifest returns the paths listed in root's manifest, each once. A
+// missing manifest lists none.
+func readManifest(root string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(root, ManifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recordings manifest: %w", err)
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		rel := filepath.FromSlash(strings.TrimSpace(line))
+		if rel == "" || seen[rel] || filepath.IsAbs(rel) || escapesRoot(rel) {
+			continue
+		}
+		seen[rel] = true
+		paths = append(paths, filepath.Join(root, rel))
+	}
+	return paths, nil
+}
+
+// writeManifest replaces root's manifest with recordings
+func writeManifest(root string, recordings []Recording) error {
+	var b strings.Builder
+	for _, rec := range recordings {
+		rel, err := filepath.Rel(root, rec.Path)
+		if err != nil {
+			continue
+		}
+		b.WriteString(filepath.ToSlash(rel) + "\n")
+	}
+
+	path := filepath.Join(root, ManifestName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write recordings manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write recordings manifest: %w", err)
+	}
+	return nil
+}
+
+// Recording is a recording file found by ListRecordings
+type Recording struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// ListRecordings returns the recordings witness wrote under root that still
+// exist, oldest first. Only files listed in root's manifest with one of
+// RecordingExtensions count; a root without a manifest has none.
+func ListRecordings(root string) ([]Recording, error) {
+	paths, err := readManifest(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var recordings []Recording
+	for _, path := range paths {
+		if !isRecording(path) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recordings: %w", err)
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		recordings = append(recordings, Recording{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+	}
+
+	sort.SliceStable(recordings, func(i, j int) bool {
+		return recordings[i].ModTime.Before(recordings[j].ModTime)
+	})
+	return recordings, nil
+}
+
+// isRecording reports whether path has one of RecordingExtensions
+func isRecording(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range RecordingExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired returns the recordings, ordered oldest first, that the policy
+// deletes at now: those older than MaxAge, then the oldest of the rest
+// until what remains fits in MaxSize
+func (r Retention) Expired(recordings []Recording, now time.Time) []Recording {
+	var expired []Recording
+	var total int64
+	for _, rec := range recordings {
+		total += rec.Size
+	}
+
+	for _, rec := range recordings {
+		tooOld := r.MaxAge > 0 && now.Sub(rec.ModTime) > r.MaxAge
+		tooBig := r.MaxSize > 0 && total > r.MaxSize
+		if !tooOld && !tooBig {
+			continue
+		}
+		expired = append(expired, rec)
+		total -= rec.Size
+	}
+	return expired
+}
+
+// Prune deletes the recordings witness wrote under root that the policy
+// expires at now, and any directories under root left empty, and returns
+// what it deleted.
+// It stops at the first recording it cannot delete.
+func (r Retention) Prune(root string, now time.Time) ([]Recording, error) {
+	recordings, err := ListRecordings(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []Recording
+	for _, rec := range r.Expired(recordings, now) {
+		if rmErr := os.Remove(rec.Path); rmErr != nil && !os.IsNotExist(rmErr) {
+			err = fmt.Errorf("failed to delete recording: %w", rmErr)
+			break
+		}
+		deleted = append(deleted, rec)
+		removeEmptyDirs(filepath.Dir(rec.Path), root)
+	}
+	if len(deleted) == 0 {
+		return nil, err
+	}
+
+	// The manifest keeps what is left, dropping recordings deleted here and
+	// any that were deleted by hand
+	var kept []Recording
+	for _, rec := range recordings {
+		if !containsRecording(deleted, rec) {
+			kept = append(kept, rec)
+		}
+	}
+	if werr := writeManifest(root, kept); werr != nil && err == nil {
+		err = werr
+	}
+	return deleted, err
+}
+
+// containsRecording reports whether recordings holds rec's path
+func containsRecording(recordings []Recording, rec Recording) bool {
+	for _, r := range recordings {
+		if r.Path == rec.Path {
+			return true
+		}
+	}
+	return false
+}
+
+// removeEmptyDirs removes dir and its parents below root while they are
+// empty, such as a month's directory once its last recording is pruned
+func removeEmptyDirs(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		// Remove fails on a directory that is not empty
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		size, age string
+		want      Retention
+	}{
+		{"", "", Retention{}},
+		{"10GB", "", Retention{MaxSize: 10 << 30}},
+		{"500mb", "30d", Retention{MaxSize: 500 << 20, MaxAge: 30 * 24 * time.Hour}},
+		{"1.5G", "2w", Retention{MaxSize: 3 << 29, MaxAge: 14 * 24 * time.Hour}},
+		{"4096", "12h", Retention{MaxSize: 4096, MaxAge: 12 * time.Hour}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRetention(tt.size, tt.age)
+		if err != nil {
+			t.Errorf("ParseRetention(%q, %q) failed: %v", tt.size, tt.age, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRetention(%q, %q) = %+v, want %+v", tt.size, tt.age, got, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"lots", ""}, {"-1GB", ""}, {"0", ""}, {"", "forever"}, {"", "0d"}, {"", "-5h"}} {
+		if _, err := ParseRetention(bad[0], bad[1]); err == nil {
+			t.Errorf("ParseRetention(%q, %q) should fail", bad[0], bad[1])
+		}
+	}
+}
+
+func TestRoot(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"~/Recordings/{year}/{month}", filepath.Join(home, "Recordings")},
+		{"~/Recordings", filepath.Join(home, "Recordings")},
+		{"/data/shots-{date}", "/data"},
+	}
+	for _, tt := range tests {
+		got, err := Root(tt.template)
+		if err != nil {
+			t.Errorf("Root(%q) failed: %v", tt.template, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Root(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+
+	// Too broad to prune safely
+	for _, template := range []string{"~/{year}", "/{date}", "recordings/{year}", "{year}"} {
+		if _, err := Root(template); err == nil {
+			t.Errorf("Root(%q) should fail", template)
+		}
+	}
+}
+
+func TestRetentionExpired(t *testing.T) {
+	now := testTime
+	day := 24 * time.Hour
+	recordings := []Recording{
+		{Path: "a.gif", Size: 400, ModTime: now.Add(-10 * day)},
+		{Path: "b.mp4", Size: 300, ModTime: now.Add(-5 * day)},
+		{Path: "c.gif", Size: 200, ModTime: now.Add(-2 * day)},
+		{Path: "d.gif", Size: 100, ModTime: now.Add(-time.Hour)},
+	}
+
+	tests := []struct {
+		name   string
+		policy Retention
+		want   []string
+	}{
+		{"unlimited", Retention{}, nil},
+		{"age", Retention{MaxAge: 3 * day}, []string{"a.gif", "b.mp4"}},
+		{"size", Retention{MaxSize: 350}, []string{"a.gif", "b.mp4"}},
+		{"size fits", Retention{MaxSize: 1000}, nil},
+		{"both", Retention{MaxSize: 250, MaxAge: 7 * day}, []string{"a.gif", "b.mp4", "c.gif"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, rec := range tt.policy.Expired(recordings, now) {
+				got = append(got, rec.Path)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetentionPrune(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	write := func(name string, size int, age time.Duration) string {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		at := now.Add(-age)
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	track := func(path string) string {
+		if err := Track(root, path); err != nil {
+			t.Fatalf("Track(%q) failed: %v", path, err)
+		}
+		return path
+	}
+
+	old := track(write("2024/01/old.gif", 10, 60*24*time.Hour))
+	notes := track(write("2024/01/notes.txt", 10, 60*24*time.Hour))
+	older := track(write("2024/02/older.mp4", 10, 45*24*time.Hour))
+	recent := track(write("2024/03/recent.gif", 10, time.Hour))
+	// Recordings witness did not write are never listed or deleted
+	foreign := write("2024/01/foreign.gif", 10, 90*24*time.Hour)
+	// Tracked twice, and tracked but never written
+	track(older)
+	track(filepath.Join(root, "2024/04/unwritten.gif"))
+
+	recordings, err := ListRecordings(root)
+	if err != nil {
+		t.Fatalf("ListRecordings() failed: %v", err)
+	}
+	if len(recordings) != 3 || recordings[0].Path != old || recordings[2].Path != recent {
+		t.Errorf("ListRecordings() = %+v, want old, older, and recent, oldest first", recordings)
+	}
+
+	deleted, err := Retention{MaxAge: 30 * 24 * time.Hour}.Prune(root, now)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if len(deleted) != 2 || deleted[0].Path != old || deleted[1].Path != older {
+		t.Errorf("Prune() deleted %+v, want old and older", deleted)
+	}
+
+	for path, want := range map[string]bool{old: false, older: false, recent: true, notes: true, foreign: true} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", path, err == nil, want)
+		}
+	}
+	// A directory emptied by pruning goes too, but not one with other files
+	if _, err := os.Stat(filepath.Join(root, "2024/02")); !os.IsNotExist(err) {
+		t.Errorf("emptied directory was kept: %v", err)
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Errorf("root was removed: %v", err)
+	}
+
+	// The manifest forgets what was pruned
+	recordings, err = ListRecordings(root)
+	if err != nil || len(recordings) != 1 || recordings[0].Path != recent {
+		t.Errorf("ListRecordings() after pruning = %+v, %v, want recent", recordings, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, ManifestName)); err != nil || string(data) != "2024/03/recent.gif\n" {
+		t.Errorf("manifest after pruning = %q, %v", data, err)
+	}
+
+	if err := Track(root, filepath.Join(root, "..", "outside.gif")); err == nil {
+		t.Error("Track() should refuse a recording outside root")
+	}
+	if recordings, err := ListRecordings(filepath.Join(root, "missing")); err != nil || len(recordings) != 0 {
+		t.Errorf("ListRecordings() of a missing directory = %v, %v, want none", recordings, err)
+	}
+}
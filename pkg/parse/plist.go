@@ -0,0 +1,498 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxPlistDepth bounds nesting, so hostile input cannot exhaust the stack
+const maxPlistDepth = 256
+
+// Plist parses a property list in the text format 'defaults read' prints
+// (OpenStep style) or in XML, as written by 'defaults export' and plutil.
+// Values are returned as:
+//
+//   - dictionaries as map[string]any
+//   - arrays as []any
+//   - strings as string
+//   - integers as int64 and reals as float64 (XML only; the text format
+//     prints numbers as strings, see Number)
+//   - booleans as bool (XML only)
+//   - data as []byte
+//   - dates as time.Time (XML only)
+//
+// Binary property lists are not supported; convert them with
+// 'plutil -convert xml1' first.
+func Plist(data []byte) (any, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("bplist")):
+		return nil, fmt.Errorf("binary property lists are not supported")
+	case bytes.HasPrefix(trimmed, []byte("<?xml")), bytes.HasPrefix(trimmed, []byte("<!DOCTYPE")), bytes.HasPrefix(trimmed, []byte("<plist")):
+		return parseXMLPlist(trimmed)
+	default:
+		return parseTextPlist(data)
+	}
+}
+
+// Number converts a numeric plist value to a float64. Besides integers and
+// reals, it accepts numeric strings, since the text format does not
+// distinguish numbers from strings.
+func Number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// textParser parses the OpenStep-style text format:
+//
+//	{
+//	    Height = 480;
+//	    "last name" = ( 1, 2 );
+//	    Data = <0fbd7788>;
+//	}
+type textParser struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func parseTextPlist(data []byte) (any, error) {
+	p := &textParser{data: data}
+	p.skipSpace()
+	if p.pos == len(p.data) {
+		return nil, fmt.Errorf("empty property list")
+	}
+
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos != len(p.data) {
+		return nil, p.errorf("unexpected %q after value", p.data[p.pos])
+	}
+	return v, nil
+}
+
+func (p *textParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid property list at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips whitespace and // and /* */ comments
+func (p *textParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.pos++
+		case bytes.HasPrefix(p.data[p.pos:], []byte("//")):
+			end := bytes.IndexByte(p.data[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.data)
+			} else {
+				p.pos += end + 1
+			}
+		case bytes.HasPrefix(p.data[p.pos:], []byte("/*")):
+			end := bytes.Index(p.data[p.pos+2:], []byte("*/"))
+			if end < 0 {
+				p.pos = len(p.data)
+			} else {
+				p.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+// expect consumes c after any whitespace
+func (p *textParser) expect(c byte) error {
+	p.skipSpace()
+	if p.pos == len(p.data) {
+		return p.errorf("expected %q, got end of input", c)
+	}
+	if p.data[p.pos] != c {
+		return p.errorf("expected %q, got %q", c, p.data[p.pos])
+	}
+	p.pos++
+	return nil
+}
+
+func (p *textParser) value() (any, error) {
+	p.skipSpace()
+	if p.pos == len(p.data) {
+		return nil, p.errorf("expected a value, got end of input")
+	}
+
+	switch p.data[p.pos] {
+	case '{':
+		return p.dict()
+	case '(':
+		return p.array()
+	case '<':
+		return p.hexData()
+	case '"', '\'':
+		return p.quoted()
+	default:
+		return p.unquoted()
+	}
+}
+
+func (p *textParser) nest() error {
+	p.depth++
+	if p.depth > maxPlistDepth {
+		return p.errorf("nested more than %d levels deep", maxPlistDepth)
+	}
+	return nil
+}
+
+func (p *textParser) dict() (any, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	p.pos++ // {
+	dict := map[string]any{}
+	for {
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == '}' {
+			p.pos++
+			return dict, nil
+		}
+
+		key, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, p.errorf("dictionary keys must be strings")
+		}
+		if err := p.expect('='); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		dict[name] = v
+
+		// The final entry may omit its semicolon
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == '}' {
+			continue
+		}
+		if err := p.expect(';'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *textParser) array() (any, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	p.pos++ // (
+	array := []any{}
+	for {
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == ')' {
+			p.pos++
+			return array, nil
+		}
+
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, v)
+
+		// A trailing comma is allowed
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == ')' {
+			continue
+		}
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *textParser) hexData() (any, error) {
+	p.pos++ // <
+	end := bytes.IndexByte(p.data[p.pos:], '>')
+	if end < 0 {
+		return nil, p.errorf("unterminated data")
+	}
+
+	digits := make([]byte, 0, end)
+	for _, c := range p.data[p.pos : p.pos+end] {
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			digits = append(digits, c)
+		}
+	}
+	data, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return nil, p.errorf("invalid data: %v", err)
+	}
+	p.pos += end + 1
+	return data, nil
+}
+
+func (p *textParser) quoted() (any, error) {
+	quote := p.data[p.pos]
+	p.pos++
+
+	var sb strings.Builder
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return sb.String(), nil
+		case c == '\\':
+			if err := p.escape(&sb); err != nil {
+				return nil, err
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+// escape decodes the escape sequence after a backslash
+func (p *textParser) escape(sb *strings.Builder) error {
+	if p.pos == len(p.data) {
+		return p.errorf("unterminated string")
+	}
+	c := p.data[p.pos]
+	p.pos++
+
+	switch c {
+	case 'n':
+		sb.WriteByte('\n')
+	case 't':
+		sb.WriteByte('\t')
+	case 'r':
+		sb.WriteByte('\r')
+	case 'U':
+		if p.pos+4 > len(p.data) {
+			return p.errorf("truncated \\U escape")
+		}
+		r, err := strconv.ParseUint(string(p.data[p.pos:p.pos+4]), 16, 32)
+		if err != nil {
+			return p.errorf("invalid \\U escape")
+		}
+		p.pos += 4
+		if !utf8.ValidRune(rune(r)) {
+			r = utf8.RuneError
+		}
+		sb.WriteRune(rune(r))
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		// Up to three octal digits
+		v := int(c - '0')
+		for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+			v = v*8 + int(p.data[p.pos]-'0')
+			p.pos++
+		}
+		sb.WriteByte(byte(v))
+	default:
+		sb.WriteByte(c)
+	}
+	return nil
+}
+
+// unquoted reads a bare word such as a number, identifier, or path
+func (p *textParser) unquoted() (any, error) {
+	start := p.pos
+	for p.pos < len(p.data) && isWordByte(p.data[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.errorf("unexpected %q", p.data[p.pos])
+	}
+	return string(p.data[start:p.pos]), nil
+}
+
+func isWordByte(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	case c >= 0x80:
+		return true
+	default:
+		return strings.IndexByte("_$+/:.-", c) >= 0
+	}
+}
+
+// parseXMLPlist parses an XML property list
+func parseXMLPlist(data []byte) (any, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+
+	start, err := nextElement(d)
+	if err == nil && start.Name.Local == "plist" {
+		start, err = nextElement(d)
+	}
+	if err == errEndElement {
+		return nil, fmt.Errorf("invalid property list: no value")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return xmlValue(d, start, 0)
+}
+
+// nextElement skips to the next start element
+func nextElement(d *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return xml.StartElement{}, fmt.Errorf("invalid property list: no value")
+		}
+		if err != nil {
+			return xml.StartElement{}, fmt.Errorf("invalid property list: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.EndElement:
+			return xml.StartElement{}, errEndElement
+		}
+	}
+}
+
+// errEndElement reports that a container closed while looking for a value
+var errEndElement = errors.New("end of element")
+
+func xmlValue(d *xml.Decoder, start xml.StartElement, depth int) (any, error) {
+	if depth > maxPlistDepth {
+		return nil, fmt.Errorf("invalid property list: nested more than %d levels deep", maxPlistDepth)
+	}
+
+	switch start.Name.Local {
+	case "dict":
+		return xmlDict(d, depth)
+	case "array":
+		return xmlArray(d, depth)
+	case "true", "false":
+		if err := d.Skip(); err != nil {
+			return nil, fmt.Errorf("invalid property list: %w", err)
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	var text string
+	if err := d.DecodeElement(&text, &start); err != nil {
+		return nil, fmt.Errorf("invalid property list: %w", err)
+	}
+	text = strings.TrimSpace(text)
+
+	switch start.Name.Local {
+	case "string", "key":
+		return text, nil
+	case "integer":
+		if n, err := strconv.ParseInt(text, 0, 64); err == nil {
+			return n, nil
+		}
+		// Values past int64 are written as unsigned
+		n, err := strconv.ParseUint(text, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid property list: bad integer %q", text)
+		}
+		return float64(n), nil
+	case "real":
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid property list: bad real %q", text)
+		}
+		return f, nil
+	case "data":
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid property list: bad data: %w", err)
+		}
+		return data, nil
+	case "date":
+		t, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid property list: bad date %q", text)
+		}
+		return t, nil
+	default:
+		return nil, fmt.Errorf("invalid property list: unknown element <%s>", start.Name.Local)
+	}
+}
+
+func xmlDict(d *xml.Decoder, depth int) (any, error) {
+	dict := map[string]any{}
+	for {
+		start, err := nextElement(d)
+		if err == errEndElement {
+			return dict, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start.Name.Local != "key" {
+			return nil, fmt.Errorf("invalid property list: expected <key> in dictionary, got <%s>", start.Name.Local)
+		}
+		var key string
+		if err := d.DecodeElement(&key, &start); err != nil {
+			return nil, fmt.Errorf("invalid property list: %w", err)
+		}
+
+		start, err = nextElement(d)
+		if err == errEndElement {
+			return nil, fmt.Errorf("invalid property list: key %q has no value", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		v, err := xmlValue(d, start, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		dict[key] = v
+	}
+}
+
+func xmlArray(d *xml.Decoder, depth int) (any, error) {
+	array := []any{}
+	for {
+		start, err := nextElement(d)
+		if err == errEndElement {
+			return array, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		v, err := xmlValue(d, start, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, v)
+	}
+}
@@ -0,0 +1,178 @@
+package parse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlistText(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  any
+	}{
+		{
+			name: "defaults read dictionary",
+			input: `{
+    Height = 480;
+    Width = 640;
+    X = "100.5";
+    Y = 200;
+}`,
+			want: map[string]any{"Height": "480", "Width": "640", "X": "100.5", "Y": "200"},
+		},
+		{
+			name:  "nested with quoted keys",
+			input: `{ "last-selection" = { X = 1; }; list = ( a, "b c", ); }`,
+			want:  map[string]any{"last-selection": map[string]any{"X": "1"}, "list": []any{"a", "b c"}},
+		},
+		{
+			name:  "final semicolon omitted",
+			input: `{ a = 1 }`,
+			want:  map[string]any{"a": "1"},
+		},
+		{
+			name:  "escapes",
+			input: `"tab\there \"quoted\" \U00e9 \101"`,
+			want:  "tab\there \"quoted\" é A",
+		},
+		{
+			name:  "data",
+			input: `<0fbd 7788>`,
+			want:  []byte{0x0f, 0xbd, 0x77, 0x88},
+		},
+		{
+			name: "comments",
+			input: `// generated
+{ /* inline */ a = 1; }`,
+			want: map[string]any{"a": "1"},
+		},
+		{
+			name:  "bare value",
+			input: "1\n",
+			want:  "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Plist([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Plist() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Plist() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlistXML(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>last-selection</key>
+	<dict>
+		<key>Height</key>
+		<real>480.5</real>
+		<key>Width</key>
+		<integer>640</integer>
+	</dict>
+	<key>show-thumbnail</key>
+	<false/>
+	<key>tags</key>
+	<array>
+		<string>a &amp; b</string>
+		<data>AQID</data>
+		<date>2024-03-01T12:00:00Z</date>
+	</array>
+</dict>
+</plist>`
+
+	got, err := Plist([]byte(input))
+	if err != nil {
+		t.Fatalf("Plist() failed: %v", err)
+	}
+
+	want := map[string]any{
+		"last-selection": map[string]any{"Height": 480.5, "Width": int64(640)},
+		"show-thumbnail": false,
+		"tags":           []any{"a & b", []byte{1, 2, 3}, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Plist() = %#v, want %#v", got, want)
+	}
+}
+
+func TestPlistErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"empty", "", "empty property list"},
+		{"binary", "bplist00\x00", "binary property lists are not supported"},
+		{"unterminated dictionary", "{ a = 1;", "expected a value"},
+		{"missing equals", "{ a 1; }", `expected '='`},
+		{"unterminated string", `"abc`, "unterminated string"},
+		{"bad data", "<zz>", "invalid data"},
+		{"trailing garbage", "{} }", "after value"},
+		{"deep nesting", strings.Repeat("(", maxPlistDepth+1), "nested more than"},
+		{"xml without value", "<plist></plist>", "no value"},
+		{"xml key without value", "<plist><dict><key>a</key></dict></plist>", `key "a" has no value`},
+		{"xml bad integer", "<plist><integer>x</integer></plist>", "bad integer"},
+		{"xml unknown element", "<plist><thing/></plist>", "unknown element"},
+		{"xml deep nesting", "<plist>" + strings.Repeat("<array>", maxPlistDepth+2) + "</plist>", "nested more than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Plist([]byte(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Plist(%q) error = %v, want one containing %q", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		value  any
+		want   float64
+		wantOK bool
+	}{
+		{int64(5), 5, true},
+		{2.5, 2.5, true},
+		{" 100.25 ", 100.25, true},
+		{"abc", 0, false},
+		{true, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Number(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Number(%#v) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func FuzzPlist(f *testing.F) {
+	for _, seed := range []string{
+		"{ Height = 480; Width = 640; X = 100; Y = 200; }",
+		`{ "a b" = ( 1, "two", <0a0b> ); c = { d = e; }; }`,
+		`"esc\U00e9\101\n"`,
+		"/* c */ ( ) // end",
+		`<?xml version="1.0"?><plist><dict><key>a</key><integer>1</integer><key>b</key><array><true/><real>1.5</real></array></dict></plist>`,
+		"<plist><data>AQID</data></plist>",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Any input must either parse or fail cleanly
+		Plist(data)
+	})
+}
@@ -0,0 +1,47 @@
+// Package parse holds the parsers for text that comes from users and other
+// programs: region strings and the property lists macOS tools print. They
+// take arbitrary input without panicking and are fuzz tested.
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Region parses a region string in format "x,y,w,h". Values after the
+// fourth are ignored.
+func Region(s string) (capture.Region, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) < 4 {
+		return capture.Region{}, fmt.Errorf("invalid region format: region must have 4 values (x,y,w,h), got %d", countValues(fields))
+	}
+
+	var values [4]int
+	for i := range values {
+		v, err := strconv.ParseInt(strings.TrimSpace(fields[i]), 10, 32)
+		if err != nil {
+			return capture.Region{}, fmt.Errorf("invalid region format: %q is not a whole number", strings.TrimSpace(fields[i]))
+		}
+		values[i] = int(v)
+	}
+
+	r := capture.Region{X: values[0], Y: values[1], Width: values[2], Height: values[3]}
+	if r.Width <= 0 || r.Height <= 0 {
+		return capture.Region{}, fmt.Errorf("width and height must be positive")
+	}
+	return r, nil
+}
+
+// countValues counts the non-empty fields, for error messages
+func countValues(fields []string) int {
+	n := 0
+	for _, f := range fields {
+		if strings.TrimSpace(f) != "" {
+			n++
+		}
+	}
+	return n
+}
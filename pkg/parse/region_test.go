@@ -0,0 +1,63 @@
+package parse
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestRegion(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    capture.Region
+		wantErr bool
+	}{
+		{"valid", "100,200,800,600", capture.Region{X: 100, Y: 200, Width: 800, Height: 600}, false},
+		{"negative origin", "100,-1000,640,480", capture.Region{X: 100, Y: -1000, Width: 640, Height: 480}, false},
+		{"spaces", " 1, 2, 3, 4 ", capture.Region{X: 1, Y: 2, Width: 3, Height: 4}, false},
+		{"extra values ignored", "1,2,3,4,5", capture.Region{X: 1, Y: 2, Width: 3, Height: 4}, false},
+		{"missing value", "100,200,800", capture.Region{}, true},
+		{"empty", "", capture.Region{}, true},
+		{"non-numeric", "abc,200,800,600", capture.Region{}, true},
+		{"fractional", "1.5,2,3,4", capture.Region{}, true},
+		{"zero width", "1,2,0,4", capture.Region{}, true},
+		{"negative height", "1,2,3,-4", capture.Region{}, true},
+		{"overflow", "1,2,99999999999,4", capture.Region{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Region(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Region(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Region(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzRegion(f *testing.F) {
+	for _, seed := range []string{"100,200,800,600", "0,0,1,1", "-5,-5,10,10", "1,2,3", "a,b,c,d", " 1 , 2 , 3 , 4 ,"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		r, err := Region(s)
+		if err != nil {
+			return
+		}
+		if r.Width <= 0 || r.Height <= 0 {
+			t.Fatalf("Region(%q) = %+v, want a positive size", s, r)
+		}
+
+		// Formatting and parsing again gives the same region
+		again, err := Region(fmt.Sprintf("%d,%d,%d,%d", r.X, r.Y, r.Width, r.Height))
+		if err != nil || again != r {
+			t.Fatalf("round trip of %+v = %+v, %v", r, again, err)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// maxCoordinate bounds selection coordinates so they convert to int safely
+const maxCoordinate = 1 << 24
+
+// ErrInvalidDimensions is returned by Selection for a selection without a
+// positive width and height. The region returned with it still holds the
+// selection's position.
+var ErrInvalidDimensions = errors.New("invalid region dimensions")
+
+// Selection parses the last screenshot selection from the output of
+// 'defaults read com.apple.screencapture last-selection'. The output may
+// also be the whole com.apple.screencapture domain, in the text or XML
+// format, and the selection may be a dictionary of X, Y, Width, and Height
+// or a rectangle string such as "{{100, 200}, {640, 480}}".
+//
+// Selection coordinates are global points and may be fractional on Retina
+// displays; they are rounded outwards so the whole selection is captured.
+func Selection(data []byte) (capture.Region, error) {
+	v, err := Plist(data)
+	if err != nil {
+		return capture.Region{}, err
+	}
+
+	if dict, ok := v.(map[string]any); ok {
+		if last, ok := lookup(dict, "last-selection"); ok {
+			v = last
+		}
+	}
+
+	var rect [4]float64
+	switch s := v.(type) {
+	case map[string]any:
+		for i, key := range []string{"X", "Y", "Width", "Height"} {
+			value, ok := lookup(s, key)
+			if !ok {
+				return capture.Region{}, fmt.Errorf("selection has no %s", key)
+			}
+			if rect[i], ok = Number(value); !ok {
+				return capture.Region{}, fmt.Errorf("selection %s is not a number: %v", key, value)
+			}
+		}
+	case string:
+		if rect, err = parseRectString(s); err != nil {
+			return capture.Region{}, err
+		}
+	default:
+		return capture.Region{}, fmt.Errorf("selection is not a dictionary or rectangle")
+	}
+
+	for _, n := range rect {
+		if math.IsNaN(n) || math.Abs(n) > maxCoordinate {
+			return capture.Region{}, fmt.Errorf("selection coordinate out of range: %v", n)
+		}
+	}
+
+	r := capture.RegionFromPoints(rect[0], rect[1], rect[2], rect[3])
+	if r.Width <= 0 || r.Height <= 0 {
+		return r, fmt.Errorf("%w: %dx%d", ErrInvalidDimensions, r.Width, r.Height)
+	}
+	return r, nil
+}
+
+// lookup finds a dictionary key, ignoring case
+func lookup(dict map[string]any, key string) (any, bool) {
+	if v, ok := dict[key]; ok {
+		return v, true
+	}
+	for k, v := range dict {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// parseRectString parses a rectangle as NSStringFromRect formats it:
+// "{{x, y}, {width, height}}"
+func parseRectString(s string) ([4]float64, error) {
+	var rect [4]float64
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '{' || r == '}' || r == ',' || r == ' '
+	})
+	if len(fields) != 4 {
+		return rect, fmt.Errorf("invalid selection rectangle %q", s)
+	}
+	for i, f := range fields {
+		n, ok := Number(f)
+		if !ok {
+			return rect, fmt.Errorf("invalid selection rectangle %q", s)
+		}
+		rect[i] = n
+	}
+	return rect, nil
+}
@@ -0,0 +1,100 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestSelection(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    capture.Region
+		wantErr bool
+	}{
+		{
+			name: "defaults read last-selection",
+			input: `{
+    Height = 600;
+    Width = 800;
+    X = 100;
+    Y = 200;
+}`,
+			want: capture.Region{X: 100, Y: 200, Width: 800, Height: 600},
+		},
+		{
+			name:  "fractional points round outwards",
+			input: `{ Height = "99.5"; Width = "100.5"; X = "10.5"; Y = "-20.25"; }`,
+			want:  capture.Region{X: 10, Y: -21, Width: 101, Height: 101},
+		},
+		{
+			name:  "whole domain",
+			input: `{ "last-selection" = { Height = 600; Width = 800; X = 100; Y = 200; }; "show-thumbnail" = 0; }`,
+			want:  capture.Region{X: 100, Y: 200, Width: 800, Height: 600},
+		},
+		{
+			name: "xml export",
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>last-selection</key><dict>
+<key>Height</key><real>600</real><key>Width</key><real>800</real>
+<key>X</key><integer>100</integer><key>Y</key><integer>200</integer>
+</dict></dict></plist>`,
+			want: capture.Region{X: 100, Y: 200, Width: 800, Height: 600},
+		},
+		{
+			name:  "rectangle string",
+			input: `"{{100, 200}, {800, 600}}"`,
+			want:  capture.Region{X: 100, Y: 200, Width: 800, Height: 600},
+		},
+		{
+			name:  "lowercase keys",
+			input: `{ height = 600; width = 800; x = 100; y = 200; }`,
+			want:  capture.Region{X: 100, Y: 200, Width: 800, Height: 600},
+		},
+		{name: "missing height", input: `{Width = 800; X = 100; Y = 200;}`, wantErr: true},
+		{name: "missing x", input: `{Height = 600; Width = 800; Y = 200;}`, wantErr: true},
+		{name: "non-numeric values", input: `{Height = abc; Width = def; X = 100; Y = 200;}`, wantErr: true},
+		{
+			name:    "zero width keeps the position",
+			input:   `{Height = 600; Width = 0; X = 100; Y = 200;}`,
+			want:    capture.Region{X: 100, Y: 200, Height: 600},
+			wantErr: true,
+		},
+		{name: "out of range", input: `{Height = 1e300; Width = 800; X = 100; Y = 200;}`, wantErr: true},
+		{name: "not a number", input: `{Height = NaN; Width = 800; X = 100; Y = 200;}`, wantErr: true},
+		{name: "bad rectangle", input: `"{{100, 200}, {800}}"`, wantErr: true},
+		{name: "array", input: `( 1, 2 )`, wantErr: true},
+		{name: "empty output", input: ``, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Selection([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Selection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Selection() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzSelection(f *testing.F) {
+	for _, seed := range []string{
+		"{ Height = 600; Width = 800; X = 100; Y = 200; }",
+		`{ "last-selection" = { Height = "1.5"; Width = 2; X = -3; Y = 4; }; }`,
+		`"{{1, 2}, {3, 4}}"`,
+		`<plist><dict><key>X</key><real>1</real></dict></plist>`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := Selection(data)
+		if err == nil && (r.Width <= 0 || r.Height <= 0) {
+			t.Fatalf("Selection(%q) = %+v, want a positive size", data, r)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+// Package preview serves a local web page for stepping through a GIF frame
+// by frame, to inspect individual frames and pick trim points for
+// `witness edit -trim`. Frames are decoded on the server, so the page shows
+// exactly the composited frame the editor works with.
+package preview
+
+import (
+	"encoding/json"
+	"html/template"
+	"image/png"
+	"net/http"
+	"strconv"
+
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+)
+
+// DefaultAddr is the address the preview server listens on by default. It
+// only accepts local connections.
+const DefaultAddr = "127.0.0.1:7421"
+
+// Server serves the preview page for a clip
+type Server struct {
+	clip   *editor.Clip
+	source string
+	mux    *http.ServeMux
+}
+
+// NewServer creates a preview of clip, which was loaded from source
+func NewServer(clip *editor.Clip, source string) *Server {
+	s := &Server{
+		clip:   clip,
+		source: source,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /{$}", s.handlePage)
+	s.mux.HandleFunc("GET /frames.json", s.handleFrames)
+	s.mux.HandleFunc("GET /frames/{n}", s.handleFrame)
+	return s
+}
+
+// ServeHTTP routes preview requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handlePage serves the scrubber page
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	page.Execute(w, s.source)
+}
+
+// handleFrames lists the frames and their times as a timeline
+func (s *Server) handleFrames(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.clip.Timeline(s.source))
+}
+
+// handleFrame serves frame n, counting from 0, as a PNG
+func (s *Server) handleFrame(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil || n < 0 || n >= s.clip.Len() {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Frames never change while the server runs
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "max-age=3600")
+	png.Encode(w, s.clip.Frames[n])
+}
+
+// page is the scrubber, given the recording's path
+var page = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}} - witness preview</title>
+<style>
+body { font: 14px -apple-system, sans-serif; margin: 24px; background: #1e1e1e; color: #ddd; }
+#frame { display: block; max-width: 100%; image-rendering: pixelated; background: repeating-conic-gradient(#444 0 25%, #333 0 50%) 0 0 / 16px 16px; }
+#scrub { width: 100%; margin: 16px 0 8px; }
+.row { display: flex; gap: 8px; align-items: center; flex-wrap: wrap; }
+button { font: inherit; padding: 4px 10px; }
+code { display: block; margin-top: 16px; padding: 8px; background: #111; user-select: all; }
+</style>
+</head>
+<body>
+<img id="frame" alt="">
+<input id="scrub" type="range" min="0" value="0">
+<div class="row">
+  <button id="prev">&larr;</button>
+  <button id="next">&rarr;</button>
+  <span id="info"></span>
+  <button id="start">Set start</button>
+  <button id="end">Set end</button>
+  <span id="range"></span>
+</div>
+<code id="command"></code>
+<script>
+const source = {{.}};
+const $ = id => document.getElementById(id);
+let frames = [], first = 0, last = 0;
+
+function show(n) {
+  n = Math.max(0, Math.min(frames.length - 1, n));
+  $("scrub").value = n;
+  $("frame").src = "/frames/" + n;
+  const f = frames[n];
+  $("info").textContent = "Frame " + n + " of " + (frames.length - 1) +
+    " at " + (f.time_ms / 1000).toFixed(2) + "s (" + f.delay_ms + "ms)";
+}
+
+function update() {
+  const seconds = (frames[last].time_ms + frames[last].delay_ms - frames[first].time_ms) / 1000;
+  $("range").textContent = "Keeping " + first + "-" + last + ", " + seconds.toFixed(2) + "s";
+  const quoted = "'" + source.replace(/'/g, "'\\''") + "'";
+  $("command").textContent = "witness edit -i " + quoted + " -o trimmed.gif -trim " + first + ":" + last;
+}
+
+$("scrub").oninput = e => show(+e.target.value);
+$("prev").onclick = () => show(+$("scrub").value - 1);
+$("next").onclick = () => show(+$("scrub").value + 1);
+$("start").onclick = () => { first = +$("scrub").value; last = Math.max(first, last); update(); };
+$("end").onclick = () => { last = +$("scrub").value; first = Math.min(first, last); update(); };
+document.onkeydown = e => {
+  if (e.key === "ArrowLeft") show(+$("scrub").value - 1);
+  if (e.key === "ArrowRight") show(+$("scrub").value + 1);
+  if (e.key === "[") $("start").click();
+  if (e.key === "]") $("end").click();
+};
+
+fetch("/frames.json").then(r => r.json()).then(t => {
+  frames = t.frames;
+  last = frames.length - 1;
+  $("scrub").max = last;
+  show(0);
+  update();
+});
+</script>
+</body>
+</html>
+`))
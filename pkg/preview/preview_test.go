@@ -0,0 +1,101 @@
+package preview
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ericmhalvorsen/witness/pkg/editor"
+)
+
+// Helper function to create a clip with one solid 4x2 frame per color
+func testClip(colors ...color.Color) *editor.Clip {
+	clip := &editor.Clip{}
+	for _, c := range colors {
+		img := image.NewPaletted(image.Rect(0, 0, 4, 2), palette.Plan9)
+		idx := uint8(color.Palette(palette.Plan9).Index(c))
+		for i := range img.Pix {
+			img.Pix[i] = idx
+		}
+		clip.Frames = append(clip.Frames, img)
+		clip.Delays = append(clip.Delays, 10)
+	}
+	return clip
+}
+
+func TestServerFrames(t *testing.T) {
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	ts := httptest.NewServer(NewServer(testClip(red, blue), "demo.gif"))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/frames.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var timeline editor.Timeline
+	err = json.NewDecoder(resp.Body).Decode(&timeline)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("failed to decode frames: %v", err)
+	}
+	if len(timeline.Frames) != 2 || timeline.Frames[1].TimeMS != 100 || timeline.Width != 4 {
+		t.Errorf("frames = %+v, want 2 4px frames 100ms apart", timeline)
+	}
+
+	resp, err = http.Get(ts.URL + "/frames/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("frame is not a PNG: %v", err)
+	}
+	if got := color.RGBAModel.Convert(img.At(0, 0)); got != blue || img.Bounds() != image.Rect(0, 0, 4, 2) {
+		t.Errorf("frame 1 = %v %v, want 4x2 %v", img.Bounds(), got, blue)
+	}
+
+	for _, path := range []string{"/frames/2", "/frames/-1", "/frames/x", "/missing"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s status = %d, want 404", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestServerPage(t *testing.T) {
+	ts := httptest.NewServer(NewServer(testClip(color.Black), `it's <demo>.gif`))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := string(body)
+	if !strings.Contains(page, "<title>it&#39;s &lt;demo&gt;.gif - witness preview</title>") {
+		t.Error("page title should name the escaped recording")
+	}
+	if strings.Contains(page, "<demo>") {
+		t.Error("recording name should be escaped in the script")
+	}
+	if !strings.Contains(page, "-trim") {
+		t.Error("page should offer a witness edit -trim command")
+	}
+}
@@ -0,0 +1,140 @@
+// Package quick lets witness quick toggle a recording across invocations.
+// The invocation that starts recording saves a State file before launching
+// it; the next one finds it and creates a stop file, which the recording
+// removes to acknowledge before it stops and saves.
+package quick
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File names within the state directory
+const (
+	stateFile = "quick.json"
+	stopFile  = "quick.stop"
+	logFile   = "quick.log"
+)
+
+// ErrNoResponse means a recording was recorded as running but did not
+// acknowledge the stop request, usually because it exited without cleaning
+// up its state
+var ErrNoResponse = errors.New("the running recording did not respond")
+
+// ErrRunning means another quick recording saved its state first
+var ErrRunning = errors.New("a quick recording is already running")
+
+// State describes the running quick recording
+type State struct {
+	Output  string    `json:"output"`
+	Started time.Time `json:"started"`
+}
+
+// StopPath returns the stop file the running recording watches for
+func StopPath(dir string) string {
+	return filepath.Join(dir, stopFile)
+}
+
+// LogPath returns the file the background recording writes its output to
+func LogPath(dir string) string {
+	return filepath.Join(dir, logFile)
+}
+
+// Load returns the running recording's state, or nil if none is running
+func Load(dir string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quick recording state: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse quick recording state: %w", err)
+	}
+	return &state, nil
+}
+
+// Save records that a quick recording is running, clearing any stale stop
+// request. The state file is created exclusively, so of two invocations
+// starting a recording at once, one gets ErrRunning.
+func Save(dir string, state State) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal quick recording state: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, stateFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return ErrRunning
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write quick recording state: %w", err)
+	}
+	os.Remove(StopPath(dir))
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write quick recording state: %w", err)
+	}
+	return nil
+}
+
+// Clear removes the state and any stop request
+func Clear(dir string) error {
+	os.Remove(StopPath(dir))
+	if err := os.Remove(filepath.Join(dir, stateFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear quick recording state: %w", err)
+	}
+	return nil
+}
+
+// RequestStop asks the running recording to stop and waits up to timeout
+// for it to acknowledge by removing the stop file. It returns
+// ErrNoResponse, withdrawing the request, if it does not.
+func RequestStop(dir string, timeout time.Duration) error {
+	path := StopPath(dir)
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return fmt.Errorf("failed to request stop: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			os.Remove(path)
+			return ErrNoResponse
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// WaitCleared waits up to timeout for the running recording to finish
+// saving and clear its state. It reports whether it did.
+func WaitCleared(dir string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := os.Stat(filepath.Join(dir, stateFile)); os.IsNotExist(err) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
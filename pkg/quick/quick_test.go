@@ -0,0 +1,109 @@
+package quick
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSaveLoadClear(t *testing.T) {
+	dir := t.TempDir()
+
+	if state, err := Load(dir); err != nil || state != nil {
+		t.Fatalf("Load() with no recording = %v, %v, want nil, nil", state, err)
+	}
+
+	want := State{Output: "demo.gif", Started: time.Date(2026, 10, 16, 9, 15, 0, 0, time.UTC)}
+	if err := Save(dir, want); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got == nil || got.Output != want.Output || !got.Started.Equal(want.Started) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+
+	if err := Clear(dir); err != nil {
+		t.Fatalf("Clear() failed: %v", err)
+	}
+	if state, _ := Load(dir); state != nil {
+		t.Errorf("Load() after Clear() = %+v, want nil", state)
+	}
+}
+
+func TestSaveRunning(t *testing.T) {
+	dir := t.TempDir()
+	if err := Save(dir, State{Output: "first.gif"}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	if err := Save(dir, State{Output: "second.gif"}); !errors.Is(err, ErrRunning) {
+		t.Errorf("second Save() error = %v, want ErrRunning", err)
+	}
+	if state, _ := Load(dir); state == nil || state.Output != "first.gif" {
+		t.Errorf("Load() = %+v, want the first recording kept", state)
+	}
+}
+
+func TestSaveClearsStaleStopRequest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(StopPath(dir), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Save(dir, State{Output: "demo.gif"}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if _, err := os.Stat(StopPath(dir)); !os.IsNotExist(err) {
+		t.Error("Save() left an old stop request that would end the new recording")
+	}
+}
+
+func TestRequestStopAcknowledged(t *testing.T) {
+	dir := t.TempDir()
+
+	// Stand in for the recording, which removes the stop file when it sees it
+	go func() {
+		for {
+			if os.Remove(StopPath(dir)) == nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	if err := RequestStop(dir, 2*time.Second); err != nil {
+		t.Errorf("RequestStop() error = %v, want nil", err)
+	}
+}
+
+func TestRequestStopNoResponse(t *testing.T) {
+	dir := t.TempDir()
+
+	err := RequestStop(dir, 100*time.Millisecond)
+	if !errors.Is(err, ErrNoResponse) {
+		t.Errorf("RequestStop() error = %v, want ErrNoResponse", err)
+	}
+	if _, err := os.Stat(StopPath(dir)); !os.IsNotExist(err) {
+		t.Error("unanswered stop request was left behind")
+	}
+}
+
+func TestWaitCleared(t *testing.T) {
+	dir := t.TempDir()
+	if err := Save(dir, State{Output: "demo.gif"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if WaitCleared(dir, 50*time.Millisecond) {
+		t.Error("WaitCleared() = true while the state remains")
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { Clear(dir) })
+	if !WaitCleared(dir, 2*time.Second) {
+		t.Error("WaitCleared() = false after the state was cleared")
+	}
+}
@@ -0,0 +1,126 @@
+package recorder
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// FrameAction tells the recorder what to do with a frame a FrameHook has seen
+type FrameAction int
+
+const (
+	// KeepFrame passes the frame on to the next hook and then the sink
+	KeepFrame FrameAction = iota
+	// DropFrame discards the frame; later hooks and the sink never see it
+	DropFrame
+	// StopRecording discards the frame and ends the recording cleanly, as a
+	// stop condition does. Frames already delivered are kept.
+	StopRecording
+)
+
+// FrameHook inspects each frame before it reaches the sink. Hooks run on the
+// recording goroutine, so a slow hook holds up the recording; one that
+// needs to do real work should hand frames off elsewhere. A hook may change
+// the frame's image, but must not keep it after returning unless it copies it.
+type FrameHook func(frame *capture.Frame) FrameAction
+
+// hookStopReason is the StopReason after a hook returns StopRecording
+const hookStopReason = "stopped by frame hook"
+
+// errHookStopped is used internally when a hook ends the recording
+var errHookStopped = errors.New(hookStopReason)
+
+// OnFrame adds a hook called with every frame that would be delivered to
+// the sink, in the order hooks were added. Frames skipped by a pause are not
+// passed to hooks. Hooks can be added before Start or while recording.
+func (r *Recorder) OnFrame(hook FrameHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// runHooks passes frame through the hooks, returning false if it should not
+// be delivered. It returns errHookStopped if a hook ended the recording.
+func (r *Recorder) runHooks(frame *capture.Frame) (bool, error) {
+	r.mu.Lock()
+	hooks := r.hooks
+	r.mu.Unlock()
+
+	for _, hook := range hooks {
+		switch hook(frame) {
+		case DropFrame:
+			return false, nil
+		case StopRecording:
+			r.endOfInput(hookStopReason)
+			return false, errHookStopped
+		}
+	}
+	return true, nil
+}
+
+// sampler sends copies of frames at most once per interval
+type sampler struct {
+	interval time.Duration
+	last     time.Time
+	frames   chan *capture.Frame
+}
+
+// Sample returns a channel receiving a copy of a delivered frame at most
+// once per interval of frame time, for analysis such as OCR that doesn't
+// need every frame. Frames are copied, so the receiver may keep or change
+// them. The channel holds one frame; while the receiver is busy, samples
+// are skipped rather than holding up the recording. Call Sample before
+// Start or while recording; the channel is closed when the recording ends,
+// and is returned already closed once it has ended.
+func (r *Recorder) Sample(interval time.Duration) <-chan *capture.Frame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &sampler{interval: interval, frames: make(chan *capture.Frame, 1)}
+	if r.samplersClosed {
+		close(s.frames)
+		return s.frames
+	}
+	r.samplers = append(r.samplers, s)
+	return s.frames
+}
+
+// sample offers frame to every sampler whose interval has passed
+func (r *Recorder) sample(frame *capture.Frame) {
+	r.mu.Lock()
+	samplers := r.samplers
+	r.mu.Unlock()
+
+	for _, s := range samplers {
+		if !s.last.IsZero() && frame.Timestamp.Sub(s.last) < s.interval {
+			continue
+		}
+		// Only the recording goroutine sends, so a full channel stays full
+		if len(s.frames) == cap(s.frames) {
+			continue
+		}
+		img := image.NewRGBA(frame.Image.Rect)
+		draw.Draw(img, img.Rect, frame.Image, img.Rect.Min, draw.Src)
+		copied := *frame
+		copied.Image = img
+		s.frames <- &copied
+		s.last = frame.Timestamp
+	}
+}
+
+// closeSamplers closes the channels returned by Sample once the recording
+// has ended
+func (r *Recorder) closeSamplers() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.samplers {
+		close(s.frames)
+	}
+	r.samplers = nil
+	r.samplersClosed = true
+}
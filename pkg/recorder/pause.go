@@ -0,0 +1,220 @@
+package recorder
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// PauseCondition decides whether captured frames should be skipped, for
+// example because the user switched away from the Space being recorded.
+// Conditions are checked once per frame.
+type PauseCondition interface {
+	// Paused reports whether recording should currently be paused.
+	// A condition that cannot be evaluated does not pause the recording.
+	Paused() (bool, error)
+
+	// String describes the condition for pause records and messages
+	String() string
+}
+
+// Pause records an interval during which frames were skipped because a
+// PauseCondition held
+type Pause struct {
+	Start time.Time
+	End   time.Time
+
+	// Reason describes the condition that paused the recording
+	Reason string
+}
+
+// Duration returns the length of the pause
+func (p Pause) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
+
+// SpaceCondition pauses recording while a Space (virtual desktop) other than
+// Target is active. macOS only captures the active Space, so this keeps other
+// desktops out of the recording instead of capturing them.
+type SpaceCondition struct {
+	// Target is the Space being recorded
+	Target uint64
+
+	// ActiveSpace returns the currently active Space
+	ActiveSpace func() (uint64, error)
+}
+
+// NewSpaceCondition creates a condition that pins the recording to the
+// currently active Space
+func NewSpaceCondition() (*SpaceCondition, error) {
+	target, err := capture.ActiveSpace()
+	if err != nil {
+		return nil, err
+	}
+	return &SpaceCondition{Target: target, ActiveSpace: capture.ActiveSpace}, nil
+}
+
+// Paused reports whether a different Space is active
+func (s *SpaceCondition) Paused() (bool, error) {
+	active, err := s.ActiveSpace()
+	if err != nil {
+		return false, err
+	}
+	return active != s.Target, nil
+}
+
+// String describes the condition
+func (s *SpaceCondition) String() string {
+	return fmt.Sprintf("switched away from Space %d", s.Target)
+}
+
+// DefaultOcclusionThreshold is the fraction of a window that may be covered
+// before a WindowCondition pauses the recording
+const DefaultOcclusionThreshold = 0.2
+
+// WindowCondition pauses recording while the target window is minimized,
+// hidden, or covered by other windows, so the recording does not show
+// whatever is in front of it
+type WindowCondition struct {
+	// ID is the window being recorded
+	ID uint32
+
+	// Threshold is the covered fraction above which recording pauses
+	Threshold float64
+
+	// Lookup returns the current state of a window
+	Lookup func(id uint32) (capture.Window, error)
+}
+
+// NewWindowCondition creates a condition for the window with the given ID
+func NewWindowCondition(id uint32) *WindowCondition {
+	return &WindowCondition{
+		ID:        id,
+		Threshold: DefaultOcclusionThreshold,
+		Lookup:    capture.LookupWindow,
+	}
+}
+
+// Paused reports whether the window is hidden or covered
+func (w *WindowCondition) Paused() (bool, error) {
+	win, err := w.Lookup(w.ID)
+	if err != nil {
+		return false, err
+	}
+	return !win.OnScreen || win.Occlusion() > w.Threshold, nil
+}
+
+// String describes the condition
+func (w *WindowCondition) String() string {
+	return fmt.Sprintf("window %d hidden or covered", w.ID)
+}
+
+// checkPause evaluates the pause conditions for a frame captured at the given
+// time and records pause intervals. It returns true when the frame should be
+// skipped.
+func (r *Recorder) checkPause(at time.Time) bool {
+	reason := ""
+	for _, c := range r.config.PauseWhen {
+		if paused, err := c.Paused(); err == nil && paused {
+			reason = c.String()
+			break
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case reason != "" && r.pausedSince.IsZero():
+		r.pausedSince = at
+		r.pauseReason = reason
+	case reason == "" && !r.pausedSince.IsZero() && !r.userPaused:
+		r.endPause(at)
+	}
+
+	return reason != ""
+}
+
+// userPauseReason is the reason recorded for pauses started by Pause
+const userPauseReason = "paused by user"
+
+// Pause holds back frames until Resume is called, so dead time can be left
+// out of a long recording without restarting it. The capturer keeps
+// running, and the interval is reported by Pauses.
+func (r *Recorder) Pause() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.running {
+		return fmt.Errorf("recorder not running")
+	}
+	if r.userPaused {
+		return nil
+	}
+	// While reconnecting there is no capturer; the new one starts paused
+	if r.capturer != nil {
+		if err := r.capturer.Pause(); err != nil {
+			return fmt.Errorf("failed to pause capture: %w", err)
+		}
+	}
+
+	r.userPaused = true
+	if r.pausedSince.IsZero() {
+		r.pausedSince = time.Now()
+		r.pauseReason = userPauseReason
+	}
+
+	return nil
+}
+
+// Resume continues a recording paused with Pause. The first frame after it
+// is marked as a discontinuity.
+func (r *Recorder) Resume() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.running {
+		return fmt.Errorf("recorder not running")
+	}
+	if !r.userPaused {
+		return nil
+	}
+	if r.capturer != nil {
+		if err := r.capturer.Resume(); err != nil {
+			return fmt.Errorf("failed to resume capture: %w", err)
+		}
+	}
+
+	r.userPaused = false
+	if r.pauseReason == userPauseReason {
+		r.endPause(time.Now())
+	}
+
+	return nil
+}
+
+// endPause closes the open pause interval at the given time.
+// The caller must hold r.mu.
+func (r *Recorder) endPause(at time.Time) {
+	if r.pausedSince.IsZero() {
+		return
+	}
+	r.pauses = append(r.pauses, Pause{Start: r.pausedSince, End: at, Reason: r.pauseReason})
+	r.pausedSince = time.Time{}
+	r.pauseReason = ""
+}
+
+// IsPaused reports whether frames are currently being skipped
+func (r *Recorder) IsPaused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.pausedSince.IsZero()
+}
+
+// Pauses returns the intervals during which frames were skipped
+func (r *Recorder) Pauses() []Pause {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Pause(nil), r.pauses...)
+}
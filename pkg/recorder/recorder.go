@@ -0,0 +1,557 @@
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"sync"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// FrameSink receives recorded frames (implemented by the encoders)
+type FrameSink interface {
+	AddFrame(frame *capture.Frame) error
+}
+
+// CapturerFactory creates a capturer for the given configuration
+type CapturerFactory func(config capture.Config) (capture.Capturer, error)
+
+// FlushMode controls what happens to frames still in flight when Stop is called
+type FlushMode int
+
+const (
+	// FlushAll delivers every frame captured before Stop was called, so the
+	// end of the recording is not lost
+	FlushAll FlushMode = iota
+	// FlushDrop discards frames that have not been delivered when Stop is called
+	FlushDrop
+)
+
+// defaultFlushTimeout bounds how long Stop waits for in-flight frames
+const defaultFlushTimeout = 2 * time.Second
+
+// Config holds configuration for a recording
+type Config struct {
+	// Capture is passed to every capturer the recorder creates
+	Capture capture.Config
+
+	// MaxRetries is the number of reconnect attempts made after a
+	// recoverable capture error. 0 disables reconnecting.
+	MaxRetries int
+
+	// RetryDelay is the wait before each reconnect attempt
+	RetryDelay time.Duration
+
+	// Flush selects how in-flight frames are handled on Stop
+	Flush FlushMode
+
+	// FlushTimeout bounds how long Stop waits for in-flight frames when
+	// Flush is FlushAll. If zero, a 2 second timeout is used.
+	FlushTimeout time.Duration
+
+	// PauseWhen lists conditions under which frames are skipped. The first
+	// frame after a pause is marked as a discontinuity.
+	PauseWhen []PauseCondition
+
+	// StopWhen lists conditions that end the recording cleanly. Done is
+	// closed and StopReason reports which condition held.
+	StopWhen []StopCondition
+}
+
+// DefaultConfig returns the default recorder configuration
+func DefaultConfig(captureConfig capture.Config) Config {
+	return Config{
+		Capture:      captureConfig,
+		MaxRetries:   3,
+		RetryDelay:   500 * time.Millisecond,
+		Flush:        FlushAll,
+		FlushTimeout: defaultFlushTimeout,
+	}
+}
+
+// Gap records an interval during which no frames were captured because the
+// capturer was being reconnected
+type Gap struct {
+	Start time.Time
+	End   time.Time
+
+	// Err is the capture error that caused the interruption
+	Err error
+}
+
+// Duration returns the length of the gap
+func (g Gap) Duration() time.Duration {
+	return g.End.Sub(g.Start)
+}
+
+// errStopped is used internally when Stop interrupts a reconnect
+var errStopped = errors.New("recorder stopped")
+
+// Recorder drives a Capturer and forwards its frames to a FrameSink,
+// re-creating the capturer when it fails with a recoverable error. After
+// re-attaching to a display whose resolution changed, frames are cropped or
+// padded to the size they had before the change.
+type Recorder struct {
+	config      Config
+	sink        FrameSink
+	newCapturer CapturerFactory
+
+	mu             sync.Mutex
+	running        bool
+	ended          bool             // The run loop ended on its own, before Stop was called
+	capturer       capture.Capturer // nil while reconnecting
+	stats          capture.Stats    // Totals from capturers already stopped
+	gaps           []Gap
+	pauses         []Pause
+	hooks          []FrameHook
+	samplers       []*sampler
+	samplersClosed bool // The samplers were closed by the last recording
+	err            error
+	started        time.Time
+	stopAt         time.Time
+	stopChan       chan struct{}
+	done           chan struct{}
+
+	// Only used by run
+	size image.Point // Size of the last frame delivered
+	fit  bool        // Whether to keep frames at size, after a display change
+
+	pausedSince time.Time
+	pauseReason string
+	userPaused  bool
+	stopReason  string
+}
+
+// NewRecorder creates a recorder using the platform capturer
+func NewRecorder(config Config, sink FrameSink) *Recorder {
+	return NewRecorderWithFactory(config, sink, capture.NewCapturer)
+}
+
+// NewRecorderWithFactory creates a recorder with a custom capturer factory
+// This is primarily used for testing with mock capturers
+func NewRecorderWithFactory(config Config, sink FrameSink, factory CapturerFactory) *Recorder {
+	return &Recorder{
+		config:      config,
+		sink:        sink,
+		newCapturer: factory,
+	}
+}
+
+// Start creates the capturer and begins recording
+func (r *Recorder) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running {
+		return fmt.Errorf("recorder already running")
+	}
+
+	c, err := r.newCapturer(r.config.Capture)
+	if err != nil {
+		return fmt.Errorf("failed to create capturer: %w", err)
+	}
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("failed to start capture: %w", err)
+	}
+
+	r.running = true
+	r.ended = false
+	r.samplersClosed = false
+	r.capturer = c
+	r.started = time.Now()
+	r.stats = capture.Stats{}
+	r.err = nil
+	r.gaps = nil
+	r.pauses = nil
+	r.pausedSince = time.Time{}
+	r.userPaused = false
+	r.stopReason = ""
+	r.size = image.Point{}
+	r.fit = false
+	r.stopChan = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go r.run(c)
+
+	return nil
+}
+
+// Stop ends the recording and waits for the capture loop to exit.
+// In-flight frames are delivered or dropped according to Config.Flush.
+// It returns the error that ended the recording early, if any.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	if !r.running {
+		// A recording that ended on its own still needs Stop to collect
+		// its result
+		ended := r.ended
+		r.ended = false
+		r.mu.Unlock()
+		if ended {
+			return r.Err()
+		}
+		return fmt.Errorf("recorder not running")
+	}
+	r.running = false
+	r.stopAt = time.Now()
+	close(r.stopChan)
+	done := r.done
+	r.mu.Unlock()
+
+	<-done
+
+	return r.Err()
+}
+
+// IsRunning reports whether the recorder is recording. It is false once the
+// recording ends, whether by Stop, a stop condition, or an error.
+func (r *Recorder) IsRunning() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running
+}
+
+// Done returns a channel that is closed when the capture loop exits because
+// Stop was called, a stop condition held, or an unrecoverable error occurred
+func (r *Recorder) Done() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done
+}
+
+// Err returns the error that ended the recording, or nil
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Gaps returns the interruptions recovered from during the recording
+func (r *Recorder) Gaps() []Gap {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Gap(nil), r.gaps...)
+}
+
+// Stats returns the capture statistics for the recording, combined across
+// the capturers created to recover from interruptions
+func (r *Recorder) Stats() capture.Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.capturer != nil {
+		return r.stats.Add(r.capturer.Stats())
+	}
+	return r.stats
+}
+
+// run forwards frames from the capturer to the sink until stopped
+func (r *Recorder) run(c capture.Capturer) {
+	defer close(r.done)
+	defer r.finish()
+	defer r.closeSamplers()
+	defer r.closePause()
+
+	discontinuity := false
+
+	for {
+		failure, stopped := r.forward(c, &discontinuity)
+		r.mu.Lock()
+		r.capturer = nil
+		r.mu.Unlock()
+		c.Stop()
+		r.mu.Lock()
+		r.stats = r.stats.Add(c.Stats())
+		r.mu.Unlock()
+		if stopped {
+			// Frames still buffered after a stop condition may already show
+			// a locked or blank screen
+			if r.config.Flush == FlushAll && r.StopReason() == "" {
+				r.flush(c, discontinuity)
+			}
+			return
+		}
+
+		if !capture.IsRecoverable(failure) || r.config.MaxRetries <= 0 {
+			r.fail(failure)
+			return
+		}
+
+		gap := Gap{Start: time.Now(), Err: failure}
+
+		next, err := r.reconnect()
+		if errors.Is(err, errStopped) {
+			return
+		}
+		if errors.Is(err, capture.ErrLimitReached) {
+			r.endOfInput(err.Error())
+			return
+		}
+		if err != nil {
+			r.fail(err)
+			return
+		}
+
+		gap.End = time.Now()
+		var change *capture.DisplayChanged
+		if errors.As(failure, &change) {
+			r.fit = true
+		}
+		r.mu.Lock()
+		r.gaps = append(r.gaps, gap)
+		r.capturer = next
+		if r.userPaused {
+			next.Pause()
+		}
+		r.mu.Unlock()
+
+		c = next
+		discontinuity = true
+	}
+}
+
+// forward copies frames from c to the sink until the capturer fails or the
+// recorder is stopped. It returns the failure, or stopped=true.
+func (r *Recorder) forward(c capture.Capturer, discontinuity *bool) (failure error, stopped bool) {
+	frames := c.Frames()
+	errs := c.Errors()
+
+	var poll <-chan time.Time
+	if len(r.config.StopWhen) > 0 {
+		ticker := time.NewTicker(conditionPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		// Check for Stop first so a busy frame channel cannot delay it
+		select {
+		case <-r.stopChan:
+			return nil, true
+		default:
+		}
+
+		select {
+		case <-r.stopChan:
+			return nil, true
+
+		case frame, ok := <-frames:
+			if !ok {
+				return fmt.Errorf("frame channel closed: %w", capture.ErrStreamInterrupted), false
+			}
+			if err := r.deliver(frame, discontinuity); errors.Is(err, errHookStopped) {
+				return nil, true
+			} else if err != nil {
+				return err, false
+			}
+
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if errors.Is(err, capture.ErrEndOfStream) {
+				r.endOfInput("end of input")
+				return nil, true
+			}
+			if errors.Is(err, capture.ErrLimitReached) {
+				// Frames sent before the limit was reached may still be
+				// buffered
+				r.endOfInput(err.Error())
+				for {
+					select {
+					case frame, ok := <-frames:
+						if !ok {
+							return nil, true
+						}
+						if err := r.deliver(frame, discontinuity); errors.Is(err, errHookStopped) {
+							return nil, true
+						} else if err != nil {
+							return err, false
+						}
+					default:
+						return nil, true
+					}
+				}
+			}
+			return err, false
+
+		case <-poll:
+			// Stop conditions may query the system, so they are checked
+			// on the ticker rather than for every frame
+			if r.checkStop() {
+				return nil, true
+			}
+		}
+	}
+}
+
+// flush delivers frames still buffered in the stopped capturer that were
+// captured before Stop was called
+func (r *Recorder) flush(c capture.Capturer, discontinuity bool) {
+	r.mu.Lock()
+	stopAt := r.stopAt
+	r.mu.Unlock()
+
+	timeout := r.config.FlushTimeout
+	if timeout <= 0 {
+		timeout = defaultFlushTimeout
+	}
+	deadline := time.After(timeout)
+
+	frames := c.Frames()
+	for {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			if frame.Timestamp.After(stopAt) {
+				frame.Release()
+				continue
+			}
+			if err := r.deliver(frame, &discontinuity); errors.Is(err, errHookStopped) {
+				return
+			} else if err != nil {
+				r.fail(err)
+				return
+			}
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// deliver sends frame to the sink, unless a pause condition holds or a hook
+// drops it, marking the first frame after an interruption as a discontinuity.
+// Pixels the capturer left with the system are converted only once the
+// frame is known to be kept. A frame whose buffer does not match its bounds
+// fails the recording rather than being encoded as garbled output.
+func (r *Recorder) deliver(frame *capture.Frame, discontinuity *bool) error {
+	if frame == nil {
+		return fmt.Errorf("capturer sent a nil frame: %w", capture.ErrCorruptFrame)
+	}
+	if r.checkPause(frame.Timestamp) {
+		frame.Release()
+		*discontinuity = true
+		return nil
+	}
+	if err := frame.Load(); err != nil {
+		return err
+	}
+	if err := capture.ValidateFrame(frame); err != nil {
+		return err
+	}
+	if *discontinuity {
+		frame.Discontinuity = true
+	}
+	r.fitFrame(frame)
+
+	// A dropped frame leaves the discontinuity for the next one delivered
+	if keep, err := r.runHooks(frame); !keep {
+		return err
+	}
+	*discontinuity = false
+
+	// Sample before the sink, which may reuse the image
+	r.sample(frame)
+	if err := r.sink.AddFrame(frame); err != nil {
+		return fmt.Errorf("failed to add frame: %w", err)
+	}
+	return nil
+}
+
+// endOfInput ends the recording cleanly because the capturer has no more
+// frames to give, recording why
+func (r *Recorder) endOfInput(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopReason = reason
+	r.stopAt = time.Now()
+}
+
+// fitFrame crops or pads frame to the size of the frames before a display
+// change, so a capturer re-attached to a reconfigured display cannot change
+// the output dimensions. Padding is left clear.
+func (r *Recorder) fitFrame(frame *capture.Frame) {
+	size := frame.Image.Rect.Size()
+	if !r.fit || r.size == (image.Point{}) {
+		r.size = size
+		return
+	}
+	if size == r.size {
+		return
+	}
+
+	img := image.NewRGBA(image.Rectangle{Max: r.size})
+	draw.Draw(img, img.Rect, frame.Image, frame.Image.Rect.Min, draw.Src)
+	frame.Image = img
+}
+
+// reconnect re-creates and starts the capturer, retrying up to MaxRetries times
+func (r *Recorder) reconnect() (capture.Capturer, error) {
+	var lastErr error
+
+	for attempt := 1; attempt <= r.config.MaxRetries; attempt++ {
+		select {
+		case <-r.stopChan:
+			return nil, errStopped
+		case <-time.After(r.config.RetryDelay):
+		}
+
+		// The new capturer only gets what is left of the recording's limits
+		r.mu.Lock()
+		config, err := r.config.Capture.Remaining(r.stats.FramesDelivered(), time.Since(r.started))
+		r.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := r.newCapturer(config)
+		if err == nil {
+			if err = c.Start(); err == nil {
+				return c, nil
+			}
+		}
+
+		lastErr = err
+		if !capture.IsRecoverable(err) {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("failed to reconnect capturer: %w", lastErr)
+}
+
+// closePause ends a pause that was still open when recording stopped
+func (r *Recorder) closePause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	end := r.stopAt
+	if end.IsZero() || end.Before(r.pausedSince) {
+		end = time.Now()
+	}
+	r.endPause(end)
+}
+
+// finish marks the recorder as no longer running when the run loop exits,
+// so it can be started again even if Stop was never called
+func (r *Recorder) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		r.running = false
+		r.ended = true
+	}
+}
+
+// fail records the error that ended the recording
+func (r *Recorder) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
@@ -0,0 +1,1010 @@
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// collectingSink records every frame it receives
+type collectingSink struct {
+	mu     sync.Mutex
+	frames []*capture.Frame
+	err    error
+}
+
+func (s *collectingSink) AddFrame(frame *capture.Frame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.frames = append(s.frames, frame)
+	return nil
+}
+
+func (s *collectingSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.frames)
+}
+
+// mockFactory hands out mock capturers and remembers them
+type mockFactory struct {
+	mu        sync.Mutex
+	capturers []*capture.MockCapturer
+	errs      []error // errors returned by successive calls, nil = succeed
+	widths    []int   // frame widths of successive capturers, 0 = 8
+}
+
+func (f *mockFactory) create(config capture.Config) (capture.Capturer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	call := len(f.capturers)
+	f.capturers = append(f.capturers, nil)
+	if call < len(f.errs) && f.errs[call] != nil {
+		return nil, f.errs[call]
+	}
+
+	m := capture.NewMockCapturer(config)
+	m.FrameWidth = 8
+	m.FrameHeight = 8
+	if call < len(f.widths) && f.widths[call] != 0 {
+		m.FrameWidth = f.widths[call]
+	}
+	m.FrameDelay = 0
+	f.capturers[call] = m
+	return m, nil
+}
+
+func (f *mockFactory) get(i int) *capture.MockCapturer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i >= len(f.capturers) {
+		return nil
+	}
+	return f.capturers[i]
+}
+
+func (f *mockFactory) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.capturers)
+}
+
+// waitFor polls cond until it returns true or the timeout expires
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timeout waiting for condition")
+}
+
+func testConfig() Config {
+	return Config{
+		Capture:    capture.Config{FPS: capture.IntFPS(100)},
+		MaxRetries: 3,
+		RetryDelay: time.Millisecond,
+	}
+}
+
+func TestRecorderForwardsFrames(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return sink.count() >= 3 })
+
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if len(rec.Gaps()) != 0 {
+		t.Errorf("Gaps() = %d, want 0", len(rec.Gaps()))
+	}
+	if factory.get(0).IsRunning() {
+		t.Error("capturer should be stopped after Stop()")
+	}
+}
+
+func TestRecorderReconnectsOnRecoverableError(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return sink.count() >= 1 })
+
+	if err := factory.get(0).SendError(fmt.Errorf("display 1: %w", capture.ErrDisplayLost)); err != nil {
+		t.Fatalf("SendError() failed: %v", err)
+	}
+
+	waitFor(t, 2*time.Second, func() bool { return factory.calls() >= 2 })
+	before := sink.count()
+	waitFor(t, 2*time.Second, func() bool { return sink.count() > before+1 })
+
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+
+	gaps := rec.Gaps()
+	if len(gaps) != 1 {
+		t.Fatalf("Gaps() = %d, want 1", len(gaps))
+	}
+	if !errors.Is(gaps[0].Err, capture.ErrDisplayLost) {
+		t.Errorf("gap error = %v, want %v", gaps[0].Err, capture.ErrDisplayLost)
+	}
+	if gaps[0].Duration() < 0 {
+		t.Errorf("gap duration = %v, want >= 0", gaps[0].Duration())
+	}
+
+	// Exactly one frame after the reconnect is marked as a discontinuity
+	marked := 0
+	for _, f := range sink.frames {
+		if f.Discontinuity {
+			marked++
+		}
+	}
+	if marked != 1 {
+		t.Errorf("discontinuity frames = %d, want 1", marked)
+	}
+}
+
+func TestRecorderReattachesAfterDisplayChange(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{widths: []int{8, 16}} // The display doubled its resolution
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return sink.count() >= 1 })
+
+	before := capture.Display{ID: 1, Bounds: capture.Region{Width: 8, Height: 8}, ScaleFactor: 1}
+	after := before
+	after.ScaleFactor = 2
+	if err := factory.get(0).SendError(capture.CheckDisplay(before, after, true)); err != nil {
+		t.Fatalf("SendError() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return factory.calls() >= 2 })
+	count := sink.count()
+	waitFor(t, 2*time.Second, func() bool { return sink.count() > count+1 })
+
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+
+	gaps := rec.Gaps()
+	if len(gaps) != 1 {
+		t.Fatalf("Gaps() = %d, want 1", len(gaps))
+	}
+	var change *capture.DisplayChanged
+	if !errors.As(gaps[0].Err, &change) || change.After.ScaleFactor != 2 {
+		t.Errorf("gap error = %v, want the DisplayChanged event", gaps[0].Err)
+	}
+
+	// Frames from the re-attached capturer keep the original size
+	for i, f := range sink.frames {
+		if size := f.Image.Bounds().Size(); size.X != 8 || size.Y != 8 {
+			t.Fatalf("frame %d is %v, want 8x8", i, size)
+		}
+	}
+}
+
+func TestRecorderStatsAcrossReconnect(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return sink.count() >= 1 })
+
+	if err := factory.get(0).SendError(capture.ErrStreamInterrupted); err != nil {
+		t.Fatalf("SendError() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return factory.calls() >= 2 })
+	before := sink.count()
+	waitFor(t, 2*time.Second, func() bool { return sink.count() > before+1 })
+	rec.Stop()
+
+	first, second := factory.get(0).Stats(), factory.get(1).Stats()
+	got := rec.Stats()
+	if want := first.FramesCaptured + second.FramesCaptured; got.FramesCaptured != want {
+		t.Errorf("FramesCaptured = %d, want %d from both capturers", got.FramesCaptured, want)
+	}
+	if got.FramesDelivered() < sink.count() {
+		t.Errorf("FramesDelivered() = %d, want at least the %d frames recorded", got.FramesDelivered(), sink.count())
+	}
+	if got.EffectiveFPS <= 0 {
+		t.Errorf("EffectiveFPS = %v, want > 0", got.EffectiveFPS)
+	}
+}
+
+func TestRecorderAbortsOnUnrecoverableError(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return factory.get(0) != nil })
+
+	if err := factory.get(0).SendError(capture.ErrPermissionDenied); err != nil {
+		t.Fatalf("SendError() failed: %v", err)
+	}
+
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop after unrecoverable error")
+	}
+
+	if !errors.Is(rec.Err(), capture.ErrPermissionDenied) {
+		t.Errorf("Err() = %v, want %v", rec.Err(), capture.ErrPermissionDenied)
+	}
+	if factory.calls() != 1 {
+		t.Errorf("capturer created %d times, want 1", factory.calls())
+	}
+	if err := rec.Stop(); !errors.Is(err, capture.ErrPermissionDenied) {
+		t.Errorf("Stop() error = %v, want %v", err, capture.ErrPermissionDenied)
+	}
+}
+
+func TestRecorderAbortsOnCorruptFrame(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return factory.get(0) != nil })
+
+	// Rows laid out 3 bytes per pixel, as from a misconfigured bitmap context
+	corrupt := &capture.Frame{Image: &image.RGBA{
+		Pix:    make([]byte, 3*100*100),
+		Stride: 3 * 100,
+		Rect:   image.Rect(0, 0, 100, 100),
+	}}
+	if err := factory.get(0).SendFrame(corrupt); err != nil {
+		t.Fatalf("SendFrame() failed: %v", err)
+	}
+
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop after a corrupt frame")
+	}
+
+	if !errors.Is(rec.Err(), capture.ErrCorruptFrame) {
+		t.Errorf("Err() = %v, want %v", rec.Err(), capture.ErrCorruptFrame)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	for _, frame := range sink.frames {
+		if frame == corrupt {
+			t.Error("corrupt frame reached the sink")
+		}
+	}
+}
+
+func TestRecorderStopsAtEndOfStream(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return factory.get(0) != nil })
+
+	if err := factory.get(0).SendError(capture.ErrEndOfStream); err != nil {
+		t.Fatalf("SendError() failed: %v", err)
+	}
+
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop at the end of the stream")
+	}
+
+	if factory.calls() != 1 {
+		t.Errorf("capturer created %d times, want 1", factory.calls())
+	}
+	if rec.StopReason() != "end of input" {
+		t.Errorf("StopReason() = %q, want %q", rec.StopReason(), "end of input")
+	}
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v, want nil after a clean stop", err)
+	}
+}
+
+func TestRecorderRestartsAfterEndingOnItsOwn(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if !rec.IsRunning() {
+		t.Error("IsRunning() = false while recording")
+	}
+	waitFor(t, 2*time.Second, func() bool { return factory.get(0) != nil })
+	if err := factory.get(0).SendError(capture.ErrEndOfStream); err != nil {
+		t.Fatalf("SendError() failed: %v", err)
+	}
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop at the end of the stream")
+	}
+
+	if rec.IsRunning() {
+		t.Error("IsRunning() = true after the recording ended on its own")
+	}
+	if err := rec.Start(); err != nil {
+		t.Fatalf("second Start() failed: %v", err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if rec.IsRunning() {
+		t.Error("IsRunning() = true after Stop")
+	}
+	if err := rec.Stop(); err == nil {
+		t.Error("second Stop() should fail when not running")
+	}
+}
+
+func TestRecorderStopsAtMaxFrames(t *testing.T) {
+	sink := &collectingSink{}
+	config := testConfig()
+	config.Capture.MaxFrames = 5
+	rec := NewRecorderWithFactory(config, sink, (&mockFactory{}).create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop at MaxFrames")
+	}
+
+	if sink.count() != 5 {
+		t.Errorf("recorded %d frames, want 5", sink.count())
+	}
+	if want := "capture limit reached after 5 frames"; rec.StopReason() != want {
+		t.Errorf("StopReason() = %q, want %q", rec.StopReason(), want)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v, want nil after a clean stop", err)
+	}
+}
+
+func TestRecorderFrameHookDrops(t *testing.T) {
+	sink := &collectingSink{}
+	config := testConfig()
+	config.Capture.MaxFrames = 10
+	rec := NewRecorderWithFactory(config, sink, (&mockFactory{}).create)
+
+	// Hooks run in order and later hooks don't see dropped frames
+	var seen, counted int
+	rec.OnFrame(func(frame *capture.Frame) FrameAction {
+		seen++
+		if seen%2 == 0 {
+			return DropFrame
+		}
+		return KeepFrame
+	})
+	rec.OnFrame(func(frame *capture.Frame) FrameAction {
+		counted++
+		return KeepFrame
+	})
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop at MaxFrames")
+	}
+
+	if seen != 10 {
+		t.Errorf("first hook saw %d frames, want 10", seen)
+	}
+	if counted != 5 || sink.count() != 5 {
+		t.Errorf("second hook saw %d frames and sink got %d, want 5 each", counted, sink.count())
+	}
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
+
+func TestRecorderFrameHookStops(t *testing.T) {
+	sink := &collectingSink{}
+	rec := NewRecorderWithFactory(testConfig(), sink, (&mockFactory{}).create)
+
+	n := 0
+	rec.OnFrame(func(frame *capture.Frame) FrameAction {
+		n++
+		if n == 4 {
+			return StopRecording
+		}
+		return KeepFrame
+	})
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop when the hook asked it to")
+	}
+
+	if sink.count() != 3 {
+		t.Errorf("recorded %d frames, want 3", sink.count())
+	}
+	if rec.StopReason() != hookStopReason {
+		t.Errorf("StopReason() = %q, want %q", rec.StopReason(), hookStopReason)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v, want nil after a clean stop", err)
+	}
+}
+
+func TestRecorderSample(t *testing.T) {
+	sink := &collectingSink{}
+	config := testConfig()
+	config.Capture.MaxFrames = 20
+	rec := NewRecorderWithFactory(config, sink, (&mockFactory{}).create)
+
+	hourly := rec.Sample(time.Hour)
+	// Never read until the recording ends, so must not hold it up
+	unread := rec.Sample(0)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop at MaxFrames")
+	}
+
+	if sink.count() != 20 {
+		t.Errorf("recorded %d frames, want 20", sink.count())
+	}
+
+	// Sampling an ended recording gets a closed channel rather than one
+	// that blocks forever
+	select {
+	case frame, ok := <-rec.Sample(0):
+		if ok {
+			t.Errorf("Sample() after the recording ended sent %v", frame)
+		}
+	case <-time.After(time.Second):
+		t.Error("Sample() after the recording ended was not closed")
+	}
+
+	tests := []struct {
+		name    string
+		samples <-chan *capture.Frame
+		want    int
+	}{
+		{"hourly", hourly, 1},
+		{"unread", unread, 1},
+	}
+	for _, tt := range tests {
+		got := 0
+		for frame := range tt.samples {
+			got++
+			// Samples are copies
+			frame.Image.Pix[0] ^= 0xff
+			if sink.frames[0].Image.Pix[0] == frame.Image.Pix[0] {
+				t.Errorf("%s: sample shares its image with the recorded frame", tt.name)
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %d samples, want %d", tt.name, got, tt.want)
+		}
+	}
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
+
+func TestRecorderGivesUpAfterMaxRetries(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{
+		errs: []error{nil, capture.ErrDisplayLost, capture.ErrDisplayLost, capture.ErrDisplayLost},
+	}
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return factory.get(0) != nil })
+	factory.get(0).SendError(capture.ErrStreamInterrupted)
+
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not give up")
+	}
+
+	if !errors.Is(rec.Err(), capture.ErrDisplayLost) {
+		t.Errorf("Err() = %v, want %v", rec.Err(), capture.ErrDisplayLost)
+	}
+	if factory.calls() != 4 {
+		t.Errorf("capturer created %d times, want 4", factory.calls())
+	}
+}
+
+func TestRecorderRetriesDisabled(t *testing.T) {
+	config := testConfig()
+	config.MaxRetries = 0
+
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(config, &collectingSink{}, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return factory.get(0) != nil })
+	factory.get(0).SendError(capture.ErrDisplayLost)
+
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop")
+	}
+
+	if !errors.Is(rec.Err(), capture.ErrDisplayLost) {
+		t.Errorf("Err() = %v, want %v", rec.Err(), capture.ErrDisplayLost)
+	}
+}
+
+func TestRecorderStartErrors(t *testing.T) {
+	factory := &mockFactory{errs: []error{capture.ErrUnsupportedPlatform}}
+	rec := NewRecorderWithFactory(testConfig(), &collectingSink{}, factory.create)
+
+	if err := rec.Start(); !errors.Is(err, capture.ErrUnsupportedPlatform) {
+		t.Errorf("Start() error = %v, want %v", err, capture.ErrUnsupportedPlatform)
+	}
+	if err := rec.Stop(); err == nil {
+		t.Error("Stop() should fail when not running")
+	}
+}
+
+// gatedSink blocks on its first frame until released
+type gatedSink struct {
+	collectingSink
+	gate    chan struct{}
+	blocked chan struct{}
+	once    sync.Once
+}
+
+func newGatedSink() *gatedSink {
+	return &gatedSink{gate: make(chan struct{}), blocked: make(chan struct{})}
+}
+
+func (s *gatedSink) AddFrame(frame *capture.Frame) error {
+	s.once.Do(func() {
+		close(s.blocked)
+		<-s.gate
+	})
+	return s.collectingSink.AddFrame(frame)
+}
+
+// recordWithBacklog stops the recorder while frames are queued behind a slow sink
+func recordWithBacklog(t *testing.T, mode FlushMode) (*Recorder, *gatedSink) {
+	t.Helper()
+
+	config := testConfig()
+	config.Flush = mode
+	config.FlushTimeout = time.Second
+
+	sink := newGatedSink()
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(config, sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	// Let the capturer fill its buffer while the sink is blocked
+	<-sink.blocked
+	time.Sleep(200 * time.Millisecond)
+
+	stopped := make(chan error)
+	go func() { stopped <- rec.Stop() }()
+	waitFor(t, time.Second, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return !rec.stopAt.IsZero()
+	})
+	close(sink.gate)
+
+	if err := <-stopped; err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	return rec, sink
+}
+
+func TestRecorderFlushAll(t *testing.T) {
+	rec, sink := recordWithBacklog(t, FlushAll)
+	if sink.count() < 5 {
+		t.Errorf("flushed %d frames, want buffered frames delivered", sink.count())
+	}
+
+	// Frames captured after Stop was called are not part of the recording
+	for i, f := range sink.frames {
+		if f.Timestamp.After(rec.stopAt) {
+			t.Errorf("frame %d captured after Stop() was delivered", i)
+		}
+	}
+}
+
+func TestRecorderFlushDrop(t *testing.T) {
+	_, sink := recordWithBacklog(t, FlushDrop)
+	if sink.count() != 1 {
+		t.Errorf("delivered %d frames, want only the in-progress frame", sink.count())
+	}
+}
+
+// switchCondition pauses while its flag is set
+type switchCondition struct {
+	mu     sync.Mutex
+	paused bool
+}
+
+func (c *switchCondition) set(paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = paused
+}
+
+func (c *switchCondition) Paused() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused, nil
+}
+
+func (c *switchCondition) String() string {
+	return "test switch"
+}
+
+func TestRecorderPausesWhileConditionHolds(t *testing.T) {
+	cond := &switchCondition{}
+	config := testConfig()
+	config.PauseWhen = []PauseCondition{cond}
+
+	sink := &collectingSink{}
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(config, sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return sink.count() >= 2 })
+
+	cond.set(true)
+	waitFor(t, 2*time.Second, rec.IsPaused)
+	paused := sink.count()
+	time.Sleep(50 * time.Millisecond)
+	if sink.count() > paused+1 {
+		t.Errorf("frames delivered while paused: %d -> %d", paused, sink.count())
+	}
+
+	cond.set(false)
+	waitFor(t, 2*time.Second, func() bool { return !rec.IsPaused() && sink.count() > paused+2 })
+
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+
+	pauses := rec.Pauses()
+	if len(pauses) != 1 {
+		t.Fatalf("Pauses() = %d, want 1", len(pauses))
+	}
+	if pauses[0].Reason != "test switch" || pauses[0].Duration() <= 0 {
+		t.Errorf("pause = %+v, want a positive pause for the test switch", pauses[0])
+	}
+
+	marked := 0
+	for _, f := range sink.frames {
+		if f.Discontinuity {
+			marked++
+		}
+	}
+	if marked != 1 {
+		t.Errorf("discontinuity frames = %d, want 1", marked)
+	}
+}
+
+func TestRecorderClosesPauseOnStop(t *testing.T) {
+	cond := &switchCondition{paused: true}
+	config := testConfig()
+	config.PauseWhen = []PauseCondition{cond}
+
+	sink := &collectingSink{}
+	rec := NewRecorderWithFactory(config, sink, (&mockFactory{}).create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, rec.IsPaused)
+
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+
+	if sink.count() != 0 {
+		t.Errorf("delivered %d frames, want 0 while paused", sink.count())
+	}
+	if pauses := rec.Pauses(); len(pauses) != 1 || pauses[0].End.IsZero() {
+		t.Errorf("Pauses() = %+v, want one closed pause", pauses)
+	}
+	if rec.IsPaused() {
+		t.Error("IsPaused() should be false after Stop()")
+	}
+}
+
+func TestRecorderPauseResume(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Pause(); err == nil {
+		t.Error("Pause() before Start() should fail")
+	}
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return sink.count() >= 2 })
+
+	if err := rec.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if !rec.IsPaused() || factory.get(0).State() != capture.StatePaused {
+		t.Errorf("IsPaused() = %v, capturer state = %v, want paused", rec.IsPaused(), factory.get(0).State())
+	}
+
+	// Frames already buffered may still arrive; nothing new after that
+	time.Sleep(50 * time.Millisecond)
+	paused := sink.count()
+	time.Sleep(50 * time.Millisecond)
+	if sink.count() != paused {
+		t.Errorf("frames delivered while paused: %d -> %d", paused, sink.count())
+	}
+
+	if err := rec.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return sink.count() > paused+2 })
+
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+
+	pauses := rec.Pauses()
+	if len(pauses) != 1 || pauses[0].Reason != userPauseReason || pauses[0].Duration() < 50*time.Millisecond {
+		t.Errorf("Pauses() = %+v, want one pause by the user of at least 50ms", pauses)
+	}
+
+	marked := 0
+	for _, f := range sink.frames {
+		if f.Discontinuity {
+			marked++
+		}
+	}
+	if marked != 1 {
+		t.Errorf("discontinuity frames = %d, want 1", marked)
+	}
+}
+
+func TestRecorderStaysPausedAcrossReconnect(t *testing.T) {
+	sink := &collectingSink{}
+	factory := &mockFactory{}
+	rec := NewRecorderWithFactory(testConfig(), sink, factory.create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer rec.Stop()
+	waitFor(t, 2*time.Second, func() bool { return sink.count() >= 1 })
+
+	if err := rec.Pause(); err != nil {
+		t.Fatalf("Pause() failed: %v", err)
+	}
+	if err := factory.get(0).SendError(capture.ErrStreamInterrupted); err != nil {
+		t.Fatalf("SendError() failed: %v", err)
+	}
+
+	waitFor(t, 2*time.Second, func() bool {
+		c := factory.get(1)
+		return c != nil && c.State() == capture.StatePaused
+	})
+	if !rec.IsPaused() {
+		t.Error("IsPaused() = false after reconnecting")
+	}
+}
+
+func TestSpaceCondition(t *testing.T) {
+	active := uint64(3)
+	cond := &SpaceCondition{
+		Target:      3,
+		ActiveSpace: func() (uint64, error) { return active, nil },
+	}
+
+	if paused, err := cond.Paused(); err != nil || paused {
+		t.Errorf("Paused() = %v, %v on the target Space", paused, err)
+	}
+
+	active = 5
+	if paused, err := cond.Paused(); err != nil || !paused {
+		t.Errorf("Paused() = %v, %v on another Space", paused, err)
+	}
+
+	cond.ActiveSpace = func() (uint64, error) { return 0, capture.ErrUnsupportedPlatform }
+	if _, err := cond.Paused(); !errors.Is(err, capture.ErrUnsupportedPlatform) {
+		t.Errorf("Paused() error = %v, want %v", err, capture.ErrUnsupportedPlatform)
+	}
+}
+
+func TestWindowCondition(t *testing.T) {
+	win := capture.Window{
+		ID:       7,
+		Bounds:   capture.Region{X: 0, Y: 0, Width: 100, Height: 100},
+		OnScreen: true,
+	}
+	cond := NewWindowCondition(7)
+	cond.Lookup = func(id uint32) (capture.Window, error) {
+		if id != 7 {
+			return capture.Window{}, capture.ErrWindowNotFound
+		}
+		return win, nil
+	}
+
+	tests := []struct {
+		name     string
+		onScreen bool
+		above    []capture.Region
+		want     bool
+	}{
+		{name: "visible", onScreen: true, want: false},
+		{name: "minimized", onScreen: false, want: true},
+		{name: "slightly covered", onScreen: true, above: []capture.Region{{Width: 10, Height: 100}}, want: false},
+		{name: "mostly covered", onScreen: true, above: []capture.Region{{Width: 60, Height: 100}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			win.OnScreen = tt.onScreen
+			win.Above = tt.above
+			if paused, err := cond.Paused(); err != nil || paused != tt.want {
+				t.Errorf("Paused() = %v, %v; want %v", paused, err, tt.want)
+			}
+		})
+	}
+
+	cond.ID = 8
+	if _, err := cond.Paused(); !errors.Is(err, capture.ErrWindowNotFound) {
+		t.Errorf("Paused() error = %v, want %v", err, capture.ErrWindowNotFound)
+	}
+}
+
+// lockCondition reports a locked session once its flag is set, counting
+// how often it is checked
+type lockCondition struct {
+	switchCondition
+	checks atomic.Int32
+}
+
+func (c *lockCondition) ShouldStop() (bool, error) {
+	c.checks.Add(1)
+	return c.Paused()
+}
+
+func TestRecorderStopsWhenConditionHolds(t *testing.T) {
+	cond := &lockCondition{}
+	config := testConfig()
+	config.StopWhen = []StopCondition{cond}
+
+	sink := &collectingSink{}
+	rec := NewRecorderWithFactory(config, sink, (&mockFactory{}).create)
+
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return sink.count() >= 20 })
+
+	// Conditions are polled, not checked for every frame
+	if checks := int(cond.checks.Load()); checks >= sink.count() {
+		t.Errorf("stop condition checked %d times for %d frames", checks, sink.count())
+	}
+
+	cond.set(true)
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop when the condition held")
+	}
+
+	if rec.StopReason() != "test switch" {
+		t.Errorf("StopReason() = %q, want %q", rec.StopReason(), "test switch")
+	}
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v, want nil after a clean stop", err)
+	}
+}
+
+func TestFileCondition(t *testing.T) {
+	cond := &FileCondition{Path: filepath.Join(t.TempDir(), "stop")}
+
+	if stop, err := cond.ShouldStop(); err != nil || stop {
+		t.Errorf("ShouldStop() = %v, %v before the file exists", stop, err)
+	}
+
+	if err := os.WriteFile(cond.Path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stop, err := cond.ShouldStop(); err != nil || !stop {
+		t.Errorf("ShouldStop() = %v, %v once the file exists, want stop", stop, err)
+	}
+	if _, err := os.Stat(cond.Path); !os.IsNotExist(err) {
+		t.Error("ShouldStop() did not remove the file to acknowledge it")
+	}
+}
+
+func TestSessionCondition(t *testing.T) {
+	session := capture.Session{OnConsole: true}
+	cond := NewSessionCondition()
+	cond.Session = func() (capture.Session, error) { return session, nil }
+
+	if stop, err := cond.ShouldStop(); err != nil || stop {
+		t.Errorf("ShouldStop() = %v, %v for an active session", stop, err)
+	}
+
+	session.Locked = true
+	if stop, _ := cond.ShouldStop(); !stop || cond.String() != "screen locked" {
+		t.Errorf("ShouldStop() = %v (%s), want stop for a locked screen", stop, cond)
+	}
+
+	session = capture.Session{OnConsole: false}
+	if stop, _ := cond.ShouldStop(); !stop || cond.String() != "switched to another user" {
+		t.Errorf("ShouldStop() = %v (%s), want stop after a user switch", stop, cond)
+	}
+
+	cond.Session = func() (capture.Session, error) { return capture.Session{}, capture.ErrUnsupportedPlatform }
+	if stop, err := cond.ShouldStop(); stop || !errors.Is(err, capture.ErrUnsupportedPlatform) {
+		t.Errorf("ShouldStop() = %v, %v; want no stop and the lookup error", stop, err)
+	}
+}
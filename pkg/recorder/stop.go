@@ -0,0 +1,142 @@
+package recorder
+
+import (
+	"os"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// conditionPollInterval is how often stop conditions are checked
+const conditionPollInterval = 250 * time.Millisecond
+
+// StopCondition ends the recording cleanly when it holds, for example
+// because the screen was locked. Frames captured up to that point are kept
+// so the output can still be finalized.
+type StopCondition interface {
+	// ShouldStop reports whether recording should end.
+	// A condition that cannot be evaluated does not stop the recording.
+	ShouldStop() (bool, error)
+
+	// String describes why the recording stopped
+	String() string
+}
+
+// defaultSleepGap is how far the wall clock may run ahead of the monotonic
+// clock between checks before the system is assumed to have slept
+const defaultSleepGap = 2 * time.Second
+
+// SessionCondition stops recording when the screen is locked, the system
+// sleeps, or another user takes over the display, which would otherwise fill
+// the recording with black frames
+type SessionCondition struct {
+	// Session returns the current session state
+	Session func() (capture.Session, error)
+
+	// SleepGap is how much wall-clock time may pass unaccounted for between
+	// checks before the system is assumed to have slept
+	SleepGap time.Duration
+
+	last   time.Time
+	reason string
+}
+
+// NewSessionCondition creates a condition for the current login session
+func NewSessionCondition() *SessionCondition {
+	return &SessionCondition{
+		Session:  capture.CurrentSession,
+		SleepGap: defaultSleepGap,
+	}
+}
+
+// ShouldStop reports whether the session can no longer be captured
+func (s *SessionCondition) ShouldStop() (bool, error) {
+	now := time.Now()
+	slept := s.slept(now)
+	s.last = now
+	if slept {
+		s.reason = "system slept"
+		return true, nil
+	}
+
+	session, err := s.Session()
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case session.Locked:
+		s.reason = "screen locked"
+	case !session.OnConsole:
+		s.reason = "switched to another user"
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// slept reports whether the system slept since the last check. The monotonic
+// clock stops while the system sleeps but the wall clock does not.
+func (s *SessionCondition) slept(now time.Time) bool {
+	if s.last.IsZero() {
+		return false
+	}
+	wall := now.Round(0).Sub(s.last.Round(0))
+	return wall-now.Sub(s.last) > s.SleepGap
+}
+
+// String describes why the session stopped the recording
+func (s *SessionCondition) String() string {
+	if s.reason == "" {
+		return "session unavailable"
+	}
+	return s.reason
+}
+
+// FileCondition stops recording once a file exists, removing it to
+// acknowledge the request. It lets another process, such as a second
+// witness quick, stop the recording.
+type FileCondition struct {
+	// Path is the file to watch for
+	Path string
+}
+
+// ShouldStop reports whether the file exists, removing it if so
+func (f *FileCondition) ShouldStop() (bool, error) {
+	if _, err := os.Stat(f.Path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	os.Remove(f.Path)
+	return true, nil
+}
+
+// String describes why the recording stopped
+func (f *FileCondition) String() string {
+	return "stop requested"
+}
+
+// checkStop evaluates the stop conditions and records the reason when one
+// holds
+func (r *Recorder) checkStop() bool {
+	for _, c := range r.config.StopWhen {
+		if stop, err := c.ShouldStop(); err == nil && stop {
+			r.mu.Lock()
+			r.stopReason = c.String()
+			r.stopAt = time.Now()
+			r.mu.Unlock()
+			return true
+		}
+	}
+	return false
+}
+
+// StopReason describes the condition that ended the recording on its own,
+// or returns "" if it was stopped by Stop or an error
+func (r *Recorder) StopReason() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopReason
+}
@@ -0,0 +1,107 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Agent runs "witness agent" on another machine over SSH. The agent captures
+// that machine's screen and streams the frames back as PNG images on its
+// standard output; closing its standard input stops it.
+type Agent struct {
+	// Target is the SSH destination, e.g. user@host
+	Target string
+
+	// SSH is the ssh binary (default "ssh")
+	SSH string
+
+	// Witness is the witness binary on the remote machine (default "witness")
+	Witness string
+
+	// Args are passed to "witness agent"
+	Args []string
+
+	// Stderr receives the agent's progress and error messages
+	Stderr io.Writer
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// NewAgent creates an agent for target that runs witness agent with args
+func NewAgent(target string, args []string) *Agent {
+	return &Agent{
+		Target:  target,
+		SSH:     "ssh",
+		Witness: "witness",
+		Args:    args,
+	}
+}
+
+// Command returns the ssh command line. BatchMode makes ssh fail instead of
+// prompting for a password, since the terminal stays with the local
+// recording.
+func (a *Agent) Command() []string {
+	remote := []string{shellQuote(a.Witness), "agent"}
+	for _, arg := range a.Args {
+		remote = append(remote, shellQuote(arg))
+	}
+	return []string{a.SSH, "-T", "-o", "BatchMode=yes", a.Target, strings.Join(remote, " ")}
+}
+
+// Start connects to the remote machine and returns the frame stream
+func (a *Agent) Start() (io.Reader, error) {
+	if a.Target == "" {
+		return nil, fmt.Errorf("no SSH destination given")
+	}
+
+	args := a.Command()
+	a.cmd = exec.Command(args[0], args[1:]...)
+	a.cmd.Stderr = a.Stderr
+	detach(a.cmd)
+
+	stdin, err := a.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := a.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", a.SSH, err)
+	}
+
+	a.stdin = stdin
+	return stdout, nil
+}
+
+// Stop asks the agent to finish. Frames already captured are still
+// delivered before the stream ends.
+func (a *Agent) Stop() error {
+	if a.stdin == nil {
+		return nil
+	}
+	return a.stdin.Close()
+}
+
+// Wait waits for ssh to exit after the frame stream has been read
+func (a *Agent) Wait() error {
+	if a.cmd == nil {
+		return nil
+	}
+	if err := a.cmd.Wait(); err != nil {
+		return fmt.Errorf("remote agent failed: %w", err)
+	}
+	return nil
+}
+
+// shellQuote quotes s for the remote shell that ssh runs commands with
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./,:=@") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
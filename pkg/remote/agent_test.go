@@ -0,0 +1,35 @@
+package remote
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAgentCommand(t *testing.T) {
+	agent := NewAgent("alice@build-vm", []string{"-f", "15", "-region", "my demo"})
+
+	want := []string{"ssh", "-T", "-o", "BatchMode=yes", "alice@build-vm", "witness agent -f 15 -region 'my demo'"}
+	if got := agent.Command(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Command() = %q, want %q", got, want)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0,0,800,600", "0,0,800,600"},
+		{"/opt/witness/bin/witness", "/opt/witness/bin/witness"},
+		{"my demo", "'my demo'"},
+		{"it's", `'it'\''s'`},
+		{"$(rm -rf ~)", "'$(rm -rf ~)'"},
+		{"", "''"},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
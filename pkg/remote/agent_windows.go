@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package remote
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach moves cmd into its own process group so Ctrl+C reaches only
+// witness, which then stops the agent cleanly instead of killing ssh
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
@@ -0,0 +1,42 @@
+package remote
+
+// Client starts and stops recordings on one daemon on demand, for
+// automation tools such as Shortcuts, AppleScript, and Stream Deck buttons
+type Client struct {
+	Host string // Daemon address, e.g. "127.0.0.1:7420"
+
+	c *Coordinator
+}
+
+// NewClient creates a client for the daemon at host
+func NewClient(host, token string) *Client {
+	return &Client{Host: host, c: NewCoordinator([]string{host}, token)}
+}
+
+// Start begins recording req now. req.StartAt is ignored, and the daemon
+// names the output if req.Output is empty.
+func (c *Client) Start(req Request) (Status, error) {
+	return c.send("/start", req)
+}
+
+// Stop ends the current recording, which is still saved
+func (c *Client) Stop() (Status, error) {
+	return c.send("/stop", Request{})
+}
+
+// Toggle stops the current recording, or starts req if there is none
+func (c *Client) Toggle(req Request) (Status, error) {
+	return c.send("/toggle", req)
+}
+
+// Status fetches the daemon's status
+func (c *Client) Status() (Status, error) {
+	return c.c.Status(c.Host)
+}
+
+// send posts req to path and returns the resulting status
+func (c *Client) send(path string, req Request) (Status, error) {
+	var status Status
+	err := c.c.post(c.Host, path, req, &status)
+	return status, err
+}
@@ -0,0 +1,175 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultSamples is how many clock readings are taken per daemon. The
+// reading with the shortest round trip gives the offset.
+const DefaultSamples = 8
+
+// Offset is a daemon's clock relative to the coordinator's
+type Offset struct {
+	// Offset is added to a coordinator time to get the daemon's time
+	Offset time.Duration
+
+	// RTT is the round trip of the reading used. The offset is accurate to
+	// within half of it.
+	RTT time.Duration
+}
+
+// Scheduled is the outcome of scheduling a recording on one daemon
+type Scheduled struct {
+	Host    string
+	Offset  Offset
+	StartAt time.Time // The start time sent, in the daemon's clock
+	Err     error
+}
+
+// Coordinator schedules recordings on several daemons at once
+type Coordinator struct {
+	Hosts   []string // Daemon addresses, e.g. "10.0.0.5:7420"
+	Token   string   // Bearer token the daemons expect
+	Samples int      // Clock readings per daemon (default DefaultSamples)
+	Client  *http.Client
+
+	now func() time.Time
+}
+
+// NewCoordinator creates a coordinator for hosts
+func NewCoordinator(hosts []string, token string) *Coordinator {
+	return &Coordinator{
+		Hosts:   hosts,
+		Token:   token,
+		Samples: DefaultSamples,
+		Client:  &http.Client{Timeout: 5 * time.Second},
+		now:     time.Now,
+	}
+}
+
+// Start measures each daemon's clock offset and schedules req to begin on
+// every daemon at the same instant, lead from now. lead must cover the time
+// taken to reach all daemons. req.StartAt is ignored. One result is
+// returned per host; the error reports the first host that failed.
+func (c *Coordinator) Start(req Request, lead time.Duration) ([]Scheduled, error) {
+	results := make([]Scheduled, len(c.Hosts))
+	for i, host := range c.Hosts {
+		results[i].Host = host
+		results[i].Offset, results[i].Err = c.MeasureOffset(host)
+	}
+
+	start := c.now().Add(lead)
+	var firstErr error
+	for i := range results {
+		r := &results[i]
+		if r.Err == nil {
+			hostReq := req
+			hostReq.StartAt = start.Add(r.Offset.Offset)
+			r.StartAt = hostReq.StartAt
+			r.Err = c.post(r.Host, "/record", hostReq, nil)
+		}
+		if r.Err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", r.Host, r.Err)
+		}
+	}
+	return results, firstErr
+}
+
+// MeasureOffset estimates host's clock offset from several readings
+func (c *Coordinator) MeasureOffset(host string) (Offset, error) {
+	samples := c.Samples
+	if samples < 1 {
+		samples = 1
+	}
+
+	var best Offset
+	for i := 0; i < samples; i++ {
+		sent := c.now()
+		var clock clockResponse
+		if err := c.get(host, "/clock", &clock); err != nil {
+			return Offset{}, err
+		}
+		received := c.now()
+
+		sample := estimateOffset(sent, clock.Time, received)
+		if i == 0 || sample.RTT < best.RTT {
+			best = sample
+		}
+	}
+	return best, nil
+}
+
+// Status fetches a daemon's status
+func (c *Coordinator) Status(host string) (Status, error) {
+	var status Status
+	err := c.get(host, "/status", &status)
+	return status, err
+}
+
+// estimateOffset assumes the daemon read its clock halfway through the
+// round trip
+func estimateOffset(sent, remote, received time.Time) Offset {
+	rtt := received.Sub(sent)
+	midpoint := sent.Add(rtt / 2)
+	return Offset{Offset: remote.Sub(midpoint), RTT: rtt}
+}
+
+// get fetches path from host and decodes the JSON response into v
+func (c *Coordinator) get(host, path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, hostURL(host)+path, nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, v)
+}
+
+// post sends body to path on host as JSON and decodes the JSON response
+// into v, if not nil
+func (c *Coordinator) post(host, path string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, hostURL(host)+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, v)
+}
+
+// do sends req and decodes a successful JSON response into v, if not nil
+func (c *Coordinator) do(req *http.Request, v interface{}) error {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// hostURL returns the base URL for a daemon address
+func hostURL(host string) string {
+	if strings.Contains(host, "://") {
+		return strings.TrimSuffix(host, "/")
+	}
+	return "http://" + host
+}
@@ -0,0 +1,291 @@
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateOffset(t *testing.T) {
+	sent := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	received := sent.Add(40 * time.Millisecond)
+	remote := sent.Add(20*time.Millisecond + 3*time.Second) // Daemon runs 3s ahead
+
+	got := estimateOffset(sent, remote, received)
+	if got.Offset != 3*time.Second {
+		t.Errorf("Offset = %v, want 3s", got.Offset)
+	}
+	if got.RTT != 40*time.Millisecond {
+		t.Errorf("RTT = %v, want 40ms", got.RTT)
+	}
+}
+
+func TestRequestValidate(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name    string
+		req     Request
+		wantErr bool
+	}{
+		{"valid", Request{StartAt: start, Duration: time.Second, Output: "a.gif"}, false},
+		{"no output", Request{StartAt: start, Duration: time.Second}, true},
+		{"until stopped", Request{StartAt: start, Output: "a.gif"}, false},
+		{"negative duration", Request{StartAt: start, Duration: -time.Second, Output: "a.gif"}, true},
+		{"no start", Request{Duration: time.Second, Output: "a.gif"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCoordinatorStart(t *testing.T) {
+	// Two daemons whose clocks disagree with the coordinator's
+	skews := []time.Duration{2 * time.Second, -5 * time.Second}
+	requests := make(chan Request, len(skews))
+	var hosts []string
+	for _, skew := range skews {
+		server := NewServer(func(req Request, started func(), stop <-chan struct{}) error {
+			started()
+			requests <- req
+			return nil
+		}, "secret")
+		skew := skew
+		server.now = func() time.Time { return time.Now().Add(skew) }
+
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+		hosts = append(hosts, ts.URL)
+	}
+
+	c := NewCoordinator(hosts, "secret")
+	results, err := c.Start(Request{Duration: time.Second, Output: "demo.gif"}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	// Translated back to the coordinator's clock, both start times agree
+	var starts []time.Time
+	for i, r := range results {
+		if diff := r.Offset.Offset - skews[i]; diff < -r.Offset.RTT || diff > r.Offset.RTT {
+			t.Errorf("host %d offset = %v, want %v ± %v", i, r.Offset.Offset, skews[i], r.Offset.RTT)
+		}
+		starts = append(starts, r.StartAt.Add(-skews[i]))
+	}
+	if diff := starts[0].Sub(starts[1]); diff < -50*time.Millisecond || diff > 50*time.Millisecond {
+		t.Errorf("start times differ by %v in the coordinator's clock", diff)
+	}
+
+	for range skews {
+		select {
+		case req := <-requests:
+			if req.Output != "demo.gif" {
+				t.Errorf("daemon recorded %q, want demo.gif", req.Output)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("daemon did not start recording")
+		}
+	}
+}
+
+func TestServerBusy(t *testing.T) {
+	release := make(chan struct{})
+	server := NewServer(func(req Request, started func(), stop <-chan struct{}) error {
+		started()
+		<-release
+		return nil
+	}, "")
+	defer close(release)
+
+	req := Request{StartAt: time.Now(), Duration: time.Second, Output: "a.gif"}
+	if err := server.Schedule(req); err != nil {
+		t.Fatalf("Schedule() failed: %v", err)
+	}
+	if err := server.Schedule(req); err != ErrBusy {
+		t.Errorf("second Schedule() error = %v, want ErrBusy", err)
+	}
+}
+
+func TestServerToken(t *testing.T) {
+	ts := httptest.NewServer(NewServer(nil, "secret"))
+	defer ts.Close()
+
+	c := NewCoordinator([]string{ts.URL}, "wrong")
+	_, err := c.MeasureOffset(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("MeasureOffset() with a wrong token error = %v, want 401", err)
+	}
+
+	resp, err := http.Get(ts.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /status without a token = %d, want 401", resp.StatusCode)
+	}
+}
+
+// Helper function to run a daemon whose recordings last until stopped
+func newStoppableServer(t *testing.T) (*httptest.Server, chan Request) {
+	t.Helper()
+	recorded := make(chan Request, 4)
+	server := NewServer(func(req Request, started func(), stop <-chan struct{}) error {
+		started()
+		<-stop
+		recorded <- req
+		return nil
+	}, "secret")
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return ts, recorded
+}
+
+// Helper function to wait for a daemon to reach a state
+func waitForState(t *testing.T, c *Client, want State) Status {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		st, err := c.Status()
+		if err != nil {
+			t.Fatalf("Status() failed: %v", err)
+		}
+		if st.State == want {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want %s", st.State, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClientStartStop(t *testing.T) {
+	ts, recorded := newStoppableServer(t)
+	c := NewClient(ts.URL, "secret")
+
+	if _, err := c.Stop(); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("Stop() while idle error = %v, want 409", err)
+	}
+
+	st, err := c.Start(Request{})
+	if err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if !strings.HasPrefix(st.Output, "witness-") || !strings.HasSuffix(st.Output, ".gif") {
+		t.Errorf("Start() output = %q, want a default name", st.Output)
+	}
+	waitForState(t, c, StateRecording)
+
+	if _, err := c.Start(Request{}); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("second Start() error = %v, want 409", err)
+	}
+
+	if _, err := c.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	select {
+	case req := <-recorded:
+		if req.Duration != 0 || req.Output != st.Output {
+			t.Errorf("recorded %+v, want an open-ended recording to %s", req, st.Output)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("recording did not stop")
+	}
+	waitForState(t, c, StateIdle)
+}
+
+func TestClientToggle(t *testing.T) {
+	ts, recorded := newStoppableServer(t)
+	c := NewClient(ts.URL, "secret")
+
+	if _, err := c.Toggle(Request{Output: "demo.gif"}); err != nil {
+		t.Fatalf("first Toggle() failed: %v", err)
+	}
+	waitForState(t, c, StateRecording)
+
+	if _, err := c.Toggle(Request{Output: "other.gif"}); err != nil {
+		t.Fatalf("second Toggle() failed: %v", err)
+	}
+	select {
+	case req := <-recorded:
+		if req.Output != "demo.gif" {
+			t.Errorf("recorded %q, want demo.gif", req.Output)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("second Toggle() did not stop the recording")
+	}
+	waitForState(t, c, StateIdle)
+}
+
+func TestServerStopScheduled(t *testing.T) {
+	server := NewServer(func(req Request, started func(), stop <-chan struct{}) error {
+		t.Error("canceled recording started")
+		return nil
+	}, "")
+
+	req := Request{StartAt: time.Now().Add(time.Hour), Duration: time.Second, Output: "a.gif"}
+	if err := server.Schedule(req); err != nil {
+		t.Fatalf("Schedule() failed: %v", err)
+	}
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.Status().State != StateIdle {
+		if time.Now().After(deadline) {
+			t.Fatal("canceled recording is still scheduled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if server.Status().LastError == "" {
+		t.Error("LastError is empty after canceling")
+	}
+}
+
+func TestServerRejectsBrowsers(t *testing.T) {
+	ts := httptest.NewServer(NewServer(nil, ""))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/start", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("POST /start from a web page = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestLoopbackOnly(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:7420", true},
+		{"[::1]:7420", true},
+		{"localhost:7420", true},
+		{"0.0.0.0:7420", false},
+		{":7420", false},
+		{"192.168.1.5:7420", false},
+		{"7420", false},
+	}
+	for _, tt := range tests {
+		if got := LoopbackOnly(tt.addr); got != tt.want {
+			t.Errorf("LoopbackOnly(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
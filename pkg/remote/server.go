@@ -0,0 +1,338 @@
+// Package remote lets several witness instances record at the same moment.
+// Each machine runs a daemon (Server) that accepts recording requests over
+// HTTP; a Coordinator measures every daemon's clock offset and schedules one
+// shared start instant, translated into each machine's own clock. A Client
+// starts and stops a daemon's recordings on demand, for automation tools
+// such as Shortcuts. An Agent records a single remote machine over SSH
+// instead.
+package remote
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// DefaultAddr is the address the daemon listens on by default. It only
+// accepts local connections; pass a routable address to record across
+// machines.
+const DefaultAddr = "127.0.0.1:7420"
+
+// LoopbackOnly reports whether a host:port listen address only accepts
+// connections from this machine: its host is a loopback IP, or a name such
+// as localhost that resolves only to loopback addresses. An empty host
+// listens on every interface.
+func LoopbackOnly(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback()
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !ip.IsLoopback() {
+			return false
+		}
+	}
+	return true
+}
+
+// Request asks a daemon to record
+type Request struct {
+	// StartAt is when recording begins, in the daemon's clock
+	StartAt time.Time `json:"start_at"`
+
+	// Duration is how long to record. 0 records until stopped.
+	Duration time.Duration `json:"duration"`
+
+	// Output is the file name to write, resolved on the daemon's machine
+	Output string `json:"output"`
+
+	// FPS is the frame rate, e.g. "15" or "30000/1001" (default 15)
+	FPS string `json:"fps,omitempty"`
+
+	// Quality is the quality level (default medium)
+	Quality string `json:"quality,omitempty"`
+
+	// Region limits capture to part of the screen
+	Region *capture.Region `json:"region,omitempty"`
+}
+
+// Validate checks that a request can be scheduled
+func (r Request) Validate() error {
+	if r.Output == "" {
+		return fmt.Errorf("output is required")
+	}
+	if r.Duration < 0 {
+		return fmt.Errorf("duration must not be negative, got %v", r.Duration)
+	}
+	if r.StartAt.IsZero() {
+		return fmt.Errorf("start time is required")
+	}
+	return nil
+}
+
+// State is the daemon's recording state
+type State string
+
+const (
+	// StateIdle means the daemon can accept a request
+	StateIdle State = "idle"
+	// StateScheduled means a recording is waiting for its start time
+	StateScheduled State = "scheduled"
+	// StateRecording means a recording is in progress
+	StateRecording State = "recording"
+)
+
+// Status describes the daemon's current and last recording
+type Status struct {
+	State     State     `json:"state"`
+	Output    string    `json:"output,omitempty"`
+	StartAt   time.Time `json:"start_at,omitempty"`
+	StartedAt time.Time `json:"started_at,omitempty"` // When capture actually began
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// RecordFunc records until req.Duration passes, if it is positive, or stop
+// is closed, calling started as soon as capture begins, and returns once
+// the output is written
+type RecordFunc func(req Request, started func(), stop <-chan struct{}) error
+
+// Daemon errors
+var (
+	// ErrBusy means the daemon already has a recording scheduled or running
+	ErrBusy = errors.New("a recording is already scheduled or running")
+
+	// ErrIdle means there is no recording to stop
+	ErrIdle = errors.New("no recording is scheduled or running")
+)
+
+// DefaultOutput names the file for a recording started without one
+func DefaultOutput(now time.Time) string {
+	return "witness-" + now.Format("2006-01-02-150405") + ".gif"
+}
+
+// Server is the recording daemon. It is an http.Handler serving:
+//
+//	GET  /clock   the daemon's current time, for offset measurement
+//	POST /record  schedule a Request
+//	POST /start   start recording now; the Request body is optional
+//	POST /stop    stop the scheduled or running recording
+//	POST /toggle  start recording if idle, otherwise stop
+//	GET  /status  the current Status
+//
+// Requests from web pages are refused, so a site open in a browser cannot
+// start recording through a daemon listening on localhost.
+type Server struct {
+	record RecordFunc
+	token  string
+	now    func() time.Time
+
+	mu     sync.Mutex
+	status Status
+	stop   chan struct{} // Closed by Stop; nil while idle
+	mux    *http.ServeMux
+}
+
+// NewServer creates a daemon that records with record. If token is not
+// empty, requests must carry it as a bearer token.
+func NewServer(record RecordFunc, token string) *Server {
+	s := &Server{
+		record: record,
+		token:  token,
+		now:    time.Now,
+		status: Status{State: StateIdle},
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /clock", s.handleClock)
+	s.mux.HandleFunc("POST /record", s.handleRecord)
+	s.mux.HandleFunc("POST /start", s.handleStart)
+	s.mux.HandleFunc("POST /stop", s.handleStop)
+	s.mux.HandleFunc("POST /toggle", s.handleToggle)
+	s.mux.HandleFunc("GET /status", s.handleStatus)
+	return s
+}
+
+// ServeHTTP checks the token and dispatches the request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Browsers send Origin with cross-site requests; witness clients,
+	// Shortcuts, and curl do not
+	if r.Header.Get("Origin") != "" {
+		http.Error(w, "requests from web pages are not accepted", http.StatusForbidden)
+		return
+	}
+	if s.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+		http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Status returns the current status
+func (s *Server) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Schedule starts req in the background once its start time arrives
+func (s *Server) Schedule(req Request) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.State != StateIdle {
+		return ErrBusy
+	}
+	s.status = Status{State: StateScheduled, Output: req.Output, StartAt: req.StartAt}
+	s.stop = make(chan struct{})
+
+	go s.run(req, s.stop)
+	return nil
+}
+
+// Start schedules req to begin now, naming the output with DefaultOutput
+// if req.Output is empty
+func (s *Server) Start(req Request) error {
+	req.StartAt = s.now()
+	if req.Output == "" {
+		req.Output = DefaultOutput(req.StartAt)
+	}
+	return s.Schedule(req)
+}
+
+// Stop ends the running recording, which is still saved, or cancels a
+// scheduled one
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop == nil {
+		return ErrIdle
+	}
+	close(s.stop)
+	s.stop = nil
+	return nil
+}
+
+// Toggle stops the current recording, or starts req if there is none
+func (s *Server) Toggle(req Request) error {
+	if err := s.Stop(); !errors.Is(err, ErrIdle) {
+		return err
+	}
+	return s.Start(req)
+}
+
+// run waits for the start time and records until stop is closed
+func (s *Server) run(req Request, stop chan struct{}) {
+	wait := time.NewTimer(req.StartAt.Sub(s.now()))
+	defer wait.Stop()
+
+	var err error
+	select {
+	case <-stop:
+		err = fmt.Errorf("canceled before the start time")
+	case <-wait.C:
+		err = s.record(req, func() {
+			s.mu.Lock()
+			s.status.State = StateRecording
+			s.status.StartedAt = s.now()
+			s.mu.Unlock()
+		}, stop)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == stop {
+		s.stop = nil
+	}
+	s.status.State = StateIdle
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+}
+
+// clockResponse carries the daemon's time with nanosecond precision
+type clockResponse struct {
+	Time time.Time `json:"time"`
+}
+
+func (s *Server) handleClock(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, clockResponse{Time: s.now()})
+}
+
+func (s *Server) handleRecord(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	switch err := s.Schedule(req); {
+	case errors.Is(err, ErrBusy):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeJSON(w, http.StatusAccepted, s.Status())
+	}
+}
+
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	s.respond(w, r, s.Start)
+}
+
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	s.respond(w, r, func(Request) error { return s.Stop() })
+}
+
+func (s *Server) handleToggle(w http.ResponseWriter, r *http.Request) {
+	s.respond(w, r, s.Toggle)
+}
+
+// respond runs action with the request body, which may be empty, and
+// writes the resulting Status
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, action func(Request) error) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	switch err := action(req); {
+	case errors.Is(err, ErrBusy), errors.Is(err, ErrIdle):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeJSON(w, http.StatusAccepted, s.Status())
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Status())
+}
+
+// writeJSON writes v as the response body
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,189 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Capturer plays a saved capture session back as a capture.Capturer, so it
+// can be fed through a recorder and the same processors and encoders as a
+// live recording. Frames keep their original timestamps. After the last
+// frame it sends capture.ErrEndOfStream, which ends a recording cleanly.
+type Capturer struct {
+	// Realtime waits between frames as long as they were apart when
+	// captured. Otherwise frames are delivered as fast as they are read.
+	Realtime bool
+
+	reader   *Reader
+	frames   chan *capture.Frame
+	errors   chan error
+	stopChan chan struct{}
+	done     chan struct{}
+	state    capture.State
+	mu       sync.Mutex
+	pause    capture.PauseGate
+	stats    capture.StatsCounter
+}
+
+// NewCapturer creates a capturer that plays back the frames from r
+func NewCapturer(r *Reader) *Capturer {
+	return &Capturer{
+		reader: r,
+		// Unbuffered, so ErrEndOfStream cannot overtake the last frame
+		frames:   make(chan *capture.Frame),
+		errors:   make(chan error, 1),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins playback
+func (c *Capturer) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != capture.StateIdle {
+		return capture.ErrAlreadyRunning
+	}
+	c.state = capture.StateRunning
+	c.stats.Start()
+
+	go c.playLoop()
+
+	return nil
+}
+
+// Stop ends playback
+func (c *Capturer) Stop() error {
+	c.mu.Lock()
+	if c.state != capture.StateRunning {
+		c.mu.Unlock()
+		return capture.ErrNotRunning
+	}
+	c.state = capture.StateStopping
+	c.mu.Unlock()
+
+	close(c.stopChan)
+	<-c.done
+	c.stats.Stop()
+
+	c.mu.Lock()
+	c.state = capture.StateIdle
+	close(c.frames)
+	close(c.errors)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Pause holds back frames until Resume is called
+func (c *Capturer) Pause() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != capture.StateRunning {
+		return capture.ErrNotRunning
+	}
+	c.pause.Pause()
+
+	return nil
+}
+
+// Resume delivers frames again after Pause
+func (c *Capturer) Resume() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != capture.StateRunning {
+		return capture.ErrNotRunning
+	}
+	c.pause.Resume()
+
+	return nil
+}
+
+// Stats returns the playback statistics since Start. Latency is the time
+// taken to read each frame from the file.
+func (c *Capturer) Stats() capture.Stats {
+	return c.stats.Stats()
+}
+
+// Frames returns the channel for replayed frames
+func (c *Capturer) Frames() <-chan *capture.Frame {
+	return c.frames
+}
+
+// Errors returns the channel for errors
+func (c *Capturer) Errors() <-chan error {
+	return c.errors
+}
+
+// IsRunning returns whether the capturer is currently running
+func (c *Capturer) IsRunning() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state == capture.StateRunning
+}
+
+// State returns the current lifecycle state
+func (c *Capturer) State() capture.State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == capture.StateRunning && c.pause.Paused() {
+		return capture.StatePaused
+	}
+	return c.state
+}
+
+// playLoop sends frames until the file ends or playback is stopped
+func (c *Capturer) playLoop() {
+	defer close(c.done)
+
+	var last time.Time
+	for {
+		read := time.Now()
+		frame, err := c.reader.ReadFrame()
+		if err == io.EOF {
+			err = capture.ErrEndOfStream
+		} else if err != nil {
+			err = fmt.Errorf("failed to replay capture: %w", err)
+		}
+		if err != nil {
+			select {
+			case c.errors <- err:
+			case <-c.stopChan:
+			}
+			return
+		}
+		c.stats.Captured(time.Since(read))
+
+		if c.Realtime && !last.IsZero() {
+			select {
+			case <-time.After(frame.Timestamp.Sub(last)):
+			case <-c.stopChan:
+				c.stats.Dropped()
+				return
+			}
+		}
+		last = frame.Timestamp
+
+		// A frame read just before a pause is held until Resume
+		for !c.pause.Admit(frame) {
+			if !c.pause.Wait(c.stopChan) {
+				c.stats.Dropped()
+				return
+			}
+		}
+
+		select {
+		case c.frames <- frame:
+		case <-c.stopChan:
+			c.stats.Dropped()
+			return
+		}
+	}
+}
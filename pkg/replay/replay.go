@@ -0,0 +1,309 @@
+// Package replay saves capture sessions to .wrec files and plays them back,
+// so an encoder bug seen on one machine can be reproduced bit for bit on
+// another. A .wrec file holds every captured frame losslessly along with
+// its exact timestamp.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// Extension is the file extension of saved capture sessions
+const Extension = ".wrec"
+
+// magic starts every .wrec file, followed by a version byte
+const (
+	magic   = "WREC"
+	version = 1
+)
+
+// maxFramePixels bounds the frame size accepted from a file, so a corrupt
+// header cannot cause a huge allocation
+const maxFramePixels = 1 << 27
+
+// Frame flags
+const (
+	flagDiscontinuity = 1 << iota
+	flagDelta         // Pixels are XORed with the previous frame's
+)
+
+// fileHeader follows the magic and version
+type fileHeader struct {
+	FPSNum, FPSDen uint32
+}
+
+// frameHeader precedes each frame's compressed pixels. Frames the same
+// size as the previous one are stored as the XOR of the two, which is
+// mostly zeros for screen content and compresses well.
+type frameHeader struct {
+	Seconds       int64
+	Nanoseconds   uint32
+	Flags         uint8
+	X, Y          int32
+	Width, Height uint32
+	Length        uint32
+}
+
+// Writer saves frames to a .wrec stream. It implements recorder.FrameSink.
+type Writer struct {
+	w      *bufio.Writer
+	file   *os.File
+	zw     *flate.Writer
+	buf    bytes.Buffer
+	prev   []byte // Pixels of the previous frame, tightly packed
+	pix    []byte
+	delta  []byte
+	frames int
+}
+
+// Create creates a .wrec file for frames captured at fps
+func Create(path string, fps capture.FPS) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+
+	w, err := NewWriter(f, fps)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.file = f
+	return w, nil
+}
+
+// NewWriter writes a .wrec stream for frames captured at fps to w
+func NewWriter(w io.Writer, fps capture.FPS) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(magic); err != nil {
+		return nil, err
+	}
+	if err := bw.WriteByte(version); err != nil {
+		return nil, err
+	}
+	header := fileHeader{FPSNum: uint32(fps.Num), FPSDen: uint32(fps.Den)}
+	if err := binary.Write(bw, binary.BigEndian, header); err != nil {
+		return nil, err
+	}
+
+	zw, _ := flate.NewWriter(nil, flate.BestSpeed)
+	return &Writer{w: bw, zw: zw}, nil
+}
+
+// AddFrame appends a frame
+func (w *Writer) AddFrame(frame *capture.Frame) error {
+	if frame == nil || frame.Image == nil {
+		return nil
+	}
+
+	img := frame.Image
+	b := img.Bounds()
+	rowLen := 4 * b.Dx()
+	w.pix = w.pix[:0]
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		w.pix = append(w.pix, img.Pix[img.PixOffset(b.Min.X, y):][:rowLen]...)
+	}
+
+	header := frameHeader{
+		Seconds:     frame.Timestamp.Unix(),
+		Nanoseconds: uint32(frame.Timestamp.Nanosecond()),
+		X:           int32(b.Min.X),
+		Y:           int32(b.Min.Y),
+		Width:       uint32(b.Dx()),
+		Height:      uint32(b.Dy()),
+	}
+	if frame.Discontinuity {
+		header.Flags |= flagDiscontinuity
+	}
+
+	data := w.pix
+	if len(w.prev) == len(w.pix) && w.frames > 0 {
+		header.Flags |= flagDelta
+		w.delta = append(w.delta[:0], w.pix...)
+		for i := range w.delta {
+			w.delta[i] ^= w.prev[i]
+		}
+		data = w.delta
+	}
+
+	w.buf.Reset()
+	w.zw.Reset(&w.buf)
+	if _, err := w.zw.Write(data); err != nil {
+		return err
+	}
+	if err := w.zw.Close(); err != nil {
+		return err
+	}
+	header.Length = uint32(w.buf.Len())
+
+	if err := binary.Write(w.w, binary.BigEndian, header); err != nil {
+		return fmt.Errorf("failed to write frame %d: %w", w.frames, err)
+	}
+	if _, err := w.w.Write(w.buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write frame %d: %w", w.frames, err)
+	}
+
+	w.prev, w.pix = w.pix, w.prev
+	w.frames++
+	return nil
+}
+
+// FrameCount returns the number of frames written
+func (w *Writer) FrameCount() int {
+	return w.frames
+}
+
+// Close flushes buffered frames and closes the file from Create
+func (w *Writer) Close() error {
+	err := w.w.Flush()
+	if w.file != nil {
+		if closeErr := w.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write capture file: %w", err)
+	}
+	return nil
+}
+
+// tee saves each frame before passing it on
+type tee struct {
+	w    *Writer
+	next recorder.FrameSink
+}
+
+// Tee returns a sink that saves frames to w and then forwards them to next.
+// Frames are saved before next can modify them in place.
+func Tee(w *Writer, next recorder.FrameSink) recorder.FrameSink {
+	return &tee{w: w, next: next}
+}
+
+func (t *tee) AddFrame(frame *capture.Frame) error {
+	if err := t.w.AddFrame(frame); err != nil {
+		return err
+	}
+	return t.next.AddFrame(frame)
+}
+
+// Reader reads frames from a .wrec stream. It implements source.Reader.
+type Reader struct {
+	r    *bufio.Reader
+	file *os.File
+	fps  capture.FPS
+	prev []byte // Copy of the previous frame's pixels
+	data []byte
+	n    int
+}
+
+// Open opens a .wrec file
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	r.file = f
+	return r, nil
+}
+
+// NewReader reads a .wrec stream from r
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	start := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, start); err != nil || string(start[:len(magic)]) != magic {
+		return nil, fmt.Errorf("not a %s capture file", Extension)
+	}
+	if start[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported %s version %d", Extension, start[len(magic)])
+	}
+
+	var header fileHeader
+	if err := binary.Read(br, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("truncated %s header", Extension)
+	}
+	fps := capture.FPS{Num: int(header.FPSNum), Den: int(header.FPSDen)}
+	if !fps.Valid() {
+		return nil, fmt.Errorf("invalid frame rate %v", fps)
+	}
+
+	return &Reader{r: br, fps: fps}, nil
+}
+
+// FPS returns the rate the frames were captured at
+func (r *Reader) FPS() capture.FPS {
+	return r.fps
+}
+
+// ReadFrame returns the next frame with its original timestamp, or io.EOF
+// after the last one
+func (r *Reader) ReadFrame() (*capture.Frame, error) {
+	var header frameHeader
+	if err := binary.Read(r.r, binary.BigEndian, &header); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("truncated frame %d", r.n)
+	}
+	if uint64(header.Width)*uint64(header.Height) > maxFramePixels || header.Length > 8*maxFramePixels {
+		return nil, fmt.Errorf("frame %d is too large (%dx%d)", r.n, header.Width, header.Height)
+	}
+
+	if cap(r.data) < int(header.Length) {
+		r.data = make([]byte, header.Length)
+	}
+	r.data = r.data[:header.Length]
+	if _, err := io.ReadFull(r.r, r.data); err != nil {
+		return nil, fmt.Errorf("truncated frame %d", r.n)
+	}
+
+	bounds := image.Rect(0, 0, int(header.Width), int(header.Height)).Add(image.Pt(int(header.X), int(header.Y)))
+	img := image.NewRGBA(bounds)
+	zr := flate.NewReader(bytes.NewReader(r.data))
+	_, err := io.ReadFull(zr, img.Pix)
+	zr.Close()
+	if err != nil {
+		return nil, fmt.Errorf("corrupt frame %d: %v", r.n, err)
+	}
+
+	if header.Flags&flagDelta != 0 {
+		if len(r.prev) != len(img.Pix) {
+			return nil, fmt.Errorf("corrupt frame %d: delta from a frame of a different size", r.n)
+		}
+		for i := range img.Pix {
+			img.Pix[i] ^= r.prev[i]
+		}
+	}
+	r.prev = append(r.prev[:0], img.Pix...)
+	r.n++
+
+	return &capture.Frame{
+		Image:         img,
+		Timestamp:     time.Unix(header.Seconds, int64(header.Nanoseconds)),
+		Discontinuity: header.Flags&flagDiscontinuity != 0,
+	}, nil
+}
+
+// Close closes the file from Open
+func (r *Reader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,271 @@
+package replay
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// collectingSink records every frame it receives
+type collectingSink struct {
+	mu     sync.Mutex
+	frames []*capture.Frame
+}
+
+func (s *collectingSink) AddFrame(frame *capture.Frame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frames = append(s.frames, frame)
+	return nil
+}
+
+// Helper function to build a session with a size change, a sub-image, and
+// a discontinuity
+func testFrames() []*capture.Frame {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	pattern := capture.NewPatternCapturer(capture.Config{Region: &capture.Region{Width: 32, Height: 24}, FPS: capture.FPS30}, capture.PatternGradient)
+
+	var frames []*capture.Frame
+	for i := 0; i < 4; i++ {
+		frames = append(frames, &capture.Frame{Image: pattern.Frame(i).Image, Timestamp: start.Add(time.Duration(i) * 33 * time.Millisecond)})
+	}
+
+	small := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	small.SetRGBA(3, 4, color.RGBA{R: 200, G: 10, B: 99, A: 128})
+	frames = append(frames, &capture.Frame{
+		Image:         small.SubImage(image.Rect(2, 3, 12, 9)).(*image.RGBA),
+		Timestamp:     start.Add(time.Second),
+		Discontinuity: true,
+	})
+	return frames
+}
+
+// Helper function to compare frames pixel for pixel
+func sameFrame(a, b *capture.Frame) bool {
+	if a.Image.Bounds() != b.Image.Bounds() || !a.Timestamp.Equal(b.Timestamp) || a.Discontinuity != b.Discontinuity {
+		return false
+	}
+	bounds := a.Image.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if a.Image.RGBAAt(x, y) != b.Image.RGBAAt(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, capture.FPS2997)
+	if err != nil {
+		t.Fatalf("NewWriter() failed: %v", err)
+	}
+	frames := testFrames()
+	for _, f := range frames {
+		if err := w.AddFrame(f); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader() failed: %v", err)
+	}
+	if r.FPS() != capture.FPS2997 {
+		t.Errorf("FPS() = %v, want %v", r.FPS(), capture.FPS2997)
+	}
+
+	for i, want := range frames {
+		got, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame() %d failed: %v", i, err)
+		}
+		if !sameFrame(got, want) {
+			t.Errorf("frame %d = %v at %v, want %v at %v", i, got.Image.Bounds(), got.Timestamp, want.Image.Bounds(), want.Timestamp)
+		}
+		// Processors draw on frames in place, which must not affect later deltas
+		got.Image.Pix[0] ^= 0xff
+	}
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("ReadFrame() after the last frame error = %v, want io.EOF", err)
+	}
+}
+
+func TestStillFramesCompress(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, capture.FPS15)
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	for i := 0; i < 10; i++ {
+		if err := w.AddFrame(&capture.Frame{Image: img, Timestamp: time.Now()}); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+	w.Close()
+
+	if buf.Len() > len(img.Pix)/10 {
+		t.Errorf("10 still frames took %d bytes, want under %d", buf.Len(), len(img.Pix)/10)
+	}
+	if w.FrameCount() != 10 {
+		t.Errorf("FrameCount() = %d, want 10", w.FrameCount())
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, capture.FPS15)
+	w.AddFrame(testFrames()[0])
+	w.Close()
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"not a capture", []byte("GIF89a..."), "not a .wrec"},
+		{"future version", append([]byte("WREC\x02"), valid[5:]...), "unsupported .wrec version 2"},
+		{"truncated frame", valid[:len(valid)-10], "truncated frame 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.data))
+			if err == nil {
+				_, err = r.ReadFrame()
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// drawingSink paints over frames in place, like an annotation overlay
+type drawingSink struct{}
+
+func (drawingSink) AddFrame(frame *capture.Frame) error {
+	for i := range frame.Image.Pix {
+		frame.Image.Pix[i] = 0xff
+	}
+	return nil
+}
+
+func TestTeeSavesFramesBeforeProcessing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session"+Extension)
+	w, err := Create(path, capture.FPS15)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	want := testFrames()[0]
+	original := append([]byte(nil), want.Image.Pix...)
+	if err := Tee(w, drawingSink{}).AddFrame(want); err != nil {
+		t.Fatalf("AddFrame() failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer r.Close()
+	got, err := r.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame() failed: %v", err)
+	}
+	if !bytes.Equal(got.Image.Pix, original) {
+		t.Error("saved frame includes changes made after it was teed")
+	}
+}
+
+func TestCapturerReplaysThroughRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, capture.FPS30)
+	frames := testFrames()
+	for _, f := range frames {
+		w.AddFrame(f)
+	}
+	w.Close()
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader() failed: %v", err)
+	}
+	factory := func(capture.Config) (capture.Capturer, error) {
+		return NewCapturer(r), nil
+	}
+
+	sink := &collectingSink{}
+	rec := recorder.NewRecorderWithFactory(recorder.DefaultConfig(capture.Config{}), sink, factory)
+	if err := rec.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	select {
+	case <-rec.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop at the end of the replay")
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v, want nil", err)
+	}
+	if rec.StopReason() != "end of input" {
+		t.Errorf("StopReason() = %q, want %q", rec.StopReason(), "end of input")
+	}
+
+	if len(sink.frames) != len(frames) {
+		t.Fatalf("replayed %d frames, want %d", len(sink.frames), len(frames))
+	}
+	for i := range frames {
+		if !sameFrame(sink.frames[i], frames[i]) {
+			t.Errorf("replayed frame %d differs from the original", i)
+		}
+	}
+	if s := rec.Stats(); s.FramesCaptured != len(frames) || s.FramesDropped != 0 {
+		t.Errorf("Stats() = %d captured, %d dropped, want %d and 0", s.FramesCaptured, s.FramesDropped, len(frames))
+	}
+}
+
+func TestCapturerRealtime(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, capture.FPS10)
+	start := time.Now()
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := 0; i < 3; i++ {
+		w.AddFrame(&capture.Frame{Image: img, Timestamp: start.Add(time.Duration(i) * 50 * time.Millisecond)})
+	}
+	w.Close()
+
+	r, _ := NewReader(&buf)
+	c := NewCapturer(r)
+	c.Realtime = true
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer c.Stop()
+
+	began := time.Now()
+	for i := 0; i < 3; i++ {
+		<-c.Frames()
+	}
+	if elapsed := time.Since(began); elapsed < 90*time.Millisecond {
+		t.Errorf("3 frames 50ms apart replayed in %v, want at least 100ms", elapsed)
+	}
+	if err := <-c.Errors(); err != capture.ErrEndOfStream {
+		t.Errorf("error after the last frame = %v, want ErrEndOfStream", err)
+	}
+}
@@ -0,0 +1,75 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/encoder/encodertest"
+)
+
+// Unchanged reports whether the recording at next shows the same content
+// as the one at previous. It returns false if previous doesn't exist.
+//
+// Both recordings are decoded and compared frame by frame with the same
+// perceptual tolerance as golden file tests, so a new palette or encoder
+// version doesn't count as a change. How long each frame is shown is
+// ignored, since a scripted run never has exactly the same timing twice.
+func Unchanged(previous, next string) (bool, error) {
+	if _, err := os.Stat(previous); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	old, tol, err := decode(previous)
+	if err != nil {
+		return false, err
+	}
+	updated, _, err := decode(next)
+	if err != nil {
+		return false, err
+	}
+	return encodertest.Compare(distinct(updated, tol), distinct(old, tol), tol) == nil, nil
+}
+
+// decode reads a recording's frames, along with the tolerance for its
+// format. A .mp4 recording needs ffmpeg; anything else is read as a GIF,
+// matching how Args records it.
+func decode(path string) (*encodertest.Frames, encodertest.Tolerance, error) {
+	if strings.EqualFold(filepath.Ext(path), ".mp4") {
+		frames, err := encodertest.DecodeVideo(path)
+		return frames, encodertest.VideoTolerance, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, encodertest.Tolerance{}, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+	frames, err := encodertest.DecodeGIF(f)
+	return frames, encodertest.GIFTolerance, err
+}
+
+// distinct drops delays and every frame that looks the same as the one
+// before it, leaving the sequence of things the recording shows
+func distinct(frames *encodertest.Frames, tol encodertest.Tolerance) *encodertest.Frames {
+	out := &encodertest.Frames{}
+	for _, img := range frames.Images {
+		if n := len(out.Images); n > 0 && similar(out.Images[n-1], img, tol) {
+			continue
+		}
+		out.Images = append(out.Images, img)
+	}
+	return out
+}
+
+// similar reports whether two frames are the same size and match within tol
+func similar(a, b *image.RGBA, tol encodertest.Tolerance) bool {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return false
+	}
+	return encodertest.DiffFrame(a, b, tol.Pixel).Fraction() <= tol.Mismatched
+}
@@ -0,0 +1,282 @@
+// Package scenario reads scenario files, which describe a recording that
+// can be made again on demand, such as a GIF in a project's documentation.
+// A scenario is a small YAML file of key: value pairs:
+//
+//	# docs/login.yaml
+//	output: login.gif
+//	region: demo
+//	preset: terminal
+//	setup: ./scripts/reset-app.sh
+//	run: ./scripts/log-in.sh
+//	duration: 20s
+//
+// Only this flat subset of YAML is supported: one key per line, values
+// optionally in single or double quotes, and # comments.
+package scenario
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scenario describes one recording
+type Scenario struct {
+	// Name identifies the scenario, from its file name without the extension
+	Name string
+
+	// Dir is the directory holding the scenario file. Scripts run in it and
+	// a relative Output is resolved against it.
+	Dir string
+
+	// Output is the file to write. A .mp4 output is recorded with witness
+	// video, anything else with witness gif. Defaults to Name + ".gif".
+	Output string
+
+	// Region is a saved region name
+	Region string
+
+	// Rect is a region as x,y,w,h
+	Rect string
+
+	// Window is a window title, app name, or ID
+	Window string
+
+	// FPS is the frame rate, e.g. "10" or "30000/1001"
+	FPS string
+
+	// Quality is the quality level: low, medium, or high
+	Quality string
+
+	// Preset is a recording preset name
+	Preset string
+
+	// Duration stops the recording after this long
+	Duration time.Duration
+
+	// MaxFrames stops the recording after this many frames
+	MaxFrames int
+
+	// Setup is a shell command run before recording starts, such as one
+	// that resets the app being recorded
+	Setup string
+
+	// Run is a shell command run once recording has started, to drive the
+	// app. Recording stops when it exits.
+	Run string
+
+	// Flags are extra witness gif or video flags, e.g. "-lossy 40"
+	Flags []string
+}
+
+// Load reads a scenario file
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	// An absolute directory keeps the output from being treated as a bare
+	// file name and moved to the configured output directory
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	s, err := Parse(name, dir, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses the scenario named name from data, as if read from a file
+// in dir
+func Parse(name, dir string, data []byte) (*Scenario, error) {
+	s := &Scenario{Name: name, Dir: dir}
+	seen := make(map[string]bool)
+
+	for i, line := range strings.Split(string(data), "\n") {
+		n := i + 1
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("line %d: nested values are not supported", n)
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value, got %q", n, trimmed)
+		}
+		key = strings.TrimSpace(key)
+		value, err := parseValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: %s is set twice", n, key)
+		}
+		seen[key] = true
+
+		if err := s.set(key, value); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// set assigns the value of one key
+func (s *Scenario) set(key, value string) error {
+	var err error
+	switch key {
+	case "output":
+		s.Output = value
+	case "region":
+		s.Region = value
+	case "rect":
+		s.Rect = value
+	case "window":
+		s.Window = value
+	case "fps":
+		s.FPS = value
+	case "quality":
+		s.Quality = value
+	case "preset":
+		s.Preset = value
+	case "duration":
+		s.Duration, err = time.ParseDuration(value)
+	case "max_frames":
+		s.MaxFrames, err = strconv.Atoi(value)
+	case "setup":
+		s.Setup = value
+	case "run":
+		s.Run = value
+	case "flags":
+		s.Flags = strings.Fields(value)
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return nil
+}
+
+// parseValue strips a trailing comment and the quotes around a value
+func parseValue(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	switch {
+	case strings.HasPrefix(v, `"`):
+		end := closingQuote(v)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", v)
+		}
+		if rest := strings.TrimSpace(v[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after string", rest)
+		}
+		return strconv.Unquote(v[:end+1])
+	case strings.HasPrefix(v, "'"):
+		// Single-quoted YAML strings escape a quote by doubling it
+		var b strings.Builder
+		for i := 1; i < len(v); i++ {
+			if v[i] != '\'' {
+				b.WriteByte(v[i])
+				continue
+			}
+			if i+1 < len(v) && v[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			if rest := strings.TrimSpace(v[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unexpected %q after string", rest)
+			}
+			return b.String(), nil
+		}
+		return "", fmt.Errorf("unterminated string %s", v)
+	default:
+		if i := strings.Index(v, " #"); i >= 0 {
+			v = strings.TrimSpace(v[:i])
+		}
+		return v, nil
+	}
+}
+
+// closingQuote returns the index of the quote ending the double-quoted
+// string at the start of v, or -1
+func closingQuote(v string) int {
+	for i := 1; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate checks that the scenario can be recorded
+func (s *Scenario) Validate() error {
+	if s.Duration < 0 || s.MaxFrames < 0 {
+		return fmt.Errorf("duration and max_frames must not be negative")
+	}
+	if s.Duration == 0 && s.MaxFrames == 0 && s.Run == "" {
+		return fmt.Errorf("set duration, max_frames, or run so the recording stops")
+	}
+	if s.Region != "" && s.Rect != "" {
+		return fmt.Errorf("set region or rect, not both")
+	}
+	return nil
+}
+
+// OutputPath returns the file the scenario writes
+func (s *Scenario) OutputPath() string {
+	out := s.Output
+	if out == "" {
+		out = s.Name + ".gif"
+	}
+	if filepath.IsAbs(out) {
+		return out
+	}
+	return filepath.Join(s.Dir, out)
+}
+
+// Args returns the witness command line that records the scenario to out,
+// usually OutputPath, stopping once stopFile exists
+func (s *Scenario) Args(out, stopFile string) []string {
+	command := "gif"
+	if strings.EqualFold(filepath.Ext(out), ".mp4") {
+		command = "video"
+	}
+
+	args := []string{command, "-o", out, "-force", "-stop-file", stopFile}
+	add := func(flag, value string) {
+		if value != "" {
+			args = append(args, flag, value)
+		}
+	}
+	add("-region", s.Region)
+	add("-r", s.Rect)
+	add("-window", s.Window)
+	add("-f", s.FPS)
+	add("-q", s.Quality)
+	add("-preset", s.Preset)
+	if s.Duration > 0 {
+		add("-d", s.Duration.String())
+	}
+	if s.MaxFrames > 0 {
+		add("-max-frames", strconv.Itoa(s.MaxFrames))
+	}
+	return append(args, s.Flags...)
+}
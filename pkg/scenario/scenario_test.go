@@ -0,0 +1,239 @@
+package scenario
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	data := `# Log in to the demo app
+---
+output: "docs/login.gif"
+region: demo   # saved with witness select
+preset: terminal
+setup: './reset.sh --user ''demo'''
+run: ./log-in.sh
+duration: 20s
+max_frames: 300
+flags: -lossy 40 -hold-last 2s
+`
+	s, err := Parse("login", "/work", []byte(data))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	want := &Scenario{
+		Name:      "login",
+		Dir:       "/work",
+		Output:    "docs/login.gif",
+		Region:    "demo",
+		Preset:    "terminal",
+		Setup:     "./reset.sh --user 'demo'",
+		Run:       "./log-in.sh",
+		Duration:  20 * time.Second,
+		MaxFrames: 300,
+		Flags:     []string{"-lossy", "40", "-hold-last", "2s"},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("Parse() = %+v, want %+v", s, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"unknown key", "duration: 1s\ncolour: red\n", `line 2: unknown key "colour"`},
+		{"nested", "duration: 1s\nrun:\n  - ./a.sh\n", "line 3: nested values are not supported"},
+		{"no colon", "duration 1s\n", "line 1: expected key: value"},
+		{"duplicate", "duration: 1s\nduration: 2s\n", "line 2: duration is set twice"},
+		{"bad duration", "duration: soon\n", "line 1: invalid duration"},
+		{"unterminated", "duration: 1s\noutput: \"a.gif\n", "line 2: unterminated string"},
+		{"never stops", "output: a.gif\n", "set duration, max_frames, or run"},
+		{"region and rect", "duration: 1s\nregion: demo\nrect: 0,0,10,10\n", "set region or rect, not both"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("test", "/work", []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"", "/work/login.gif"},
+		{"out/demo.mp4", "/work/out/demo.mp4"},
+		{"/tmp/demo.gif", "/tmp/demo.gif"},
+	}
+
+	for _, tt := range tests {
+		s := &Scenario{Name: "login", Dir: "/work", Output: tt.output}
+		if got := s.OutputPath(); got != filepath.FromSlash(tt.want) {
+			t.Errorf("OutputPath() with output %q = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	s := &Scenario{Name: "login", Dir: "/work", Region: "demo", FPS: "10", Duration: 5 * time.Second, Flags: []string{"-lossy", "40"}}
+	want := []string{"gif", "-o", filepath.FromSlash("/work/login.gif"), "-force", "-stop-file", "stop", "-region", "demo", "-f", "10", "-d", "5s", "-lossy", "40"}
+	if got := s.Args(s.OutputPath(), "stop"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() = %q, want %q", got, want)
+	}
+
+	s = &Scenario{Name: "login", Dir: "/work", Output: "login.MP4", MaxFrames: 90}
+	want = []string{"video", "-o", filepath.FromSlash("/work/login.MP4"), "-force", "-stop-file", "stop", "-max-frames", "90"}
+	if got := s.Args(s.OutputPath(), "stop"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() for an MP4 = %q, want %q", got, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.yaml")
+	if err := os.WriteFile(path, []byte("duration: 3s\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if s.Name != "settings" || s.Dir != dir {
+		t.Errorf("Load() name, dir = %q, %q, want settings, %q", s.Name, s.Dir, dir)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Load() of a missing file succeeded")
+	}
+}
+
+// Helper function to write a GIF of solid frames in the given colors
+func writeGIF(t *testing.T, path string, palette color.Palette, colors ...color.Color) {
+	t.Helper()
+	g := &gif.GIF{}
+	for _, c := range colors {
+		img := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+		for i := range img.Pix {
+			img.Pix[i] = uint8(palette.Index(c))
+		}
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, 10)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create GIF: %v", err)
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, g); err != nil {
+		t.Fatalf("failed to encode GIF: %v", err)
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	red := color.RGBA{R: 255, A: 255}
+	nearRed := color.RGBA{R: 245, G: 4, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	black := color.RGBA{A: 255}
+
+	path := func(name string) string { return filepath.Join(dir, name) }
+	writeGIF(t, path("old.gif"), color.Palette{red, blue}, red, blue)
+	// Same frames with the palette in another order
+	writeGIF(t, path("same.gif"), color.Palette{black, blue, red}, red, blue)
+	// Red shown for longer, as happens when a run is slower
+	writeGIF(t, path("slower.gif"), color.Palette{red, blue}, red, red, blue)
+	// Colors that drifted less than an encoder change would
+	writeGIF(t, path("drifted.gif"), color.Palette{nearRed, blue}, nearRed, blue)
+	writeGIF(t, path("changed.gif"), color.Palette{red, blue}, red, red)
+	writeGIF(t, path("extra.gif"), color.Palette{red, blue, black}, red, blue, black)
+
+	tests := []struct {
+		name     string
+		previous string
+		next     string
+		want     bool
+	}{
+		{"same frames", "old.gif", "same.gif", true},
+		{"different timing", "old.gif", "slower.gif", true},
+		{"small color drift", "old.gif", "drifted.gif", true},
+		{"changed frames", "old.gif", "changed.gif", false},
+		{"extra frame", "old.gif", "extra.gif", false},
+		{"no previous output", "missing.gif", "old.gif", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unchanged(path(tt.previous), path(tt.next))
+			if err != nil {
+				t.Fatalf("Unchanged() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Unchanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnchangedVideo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+
+	// The fake ffmpeg decodes demo.mp4 from demo.mp4.y4m
+	script := "#!/bin/sh\ncat \"$4.y4m\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// Writes an MP4 whose frames are solid colors, given as Y, Cb, Cr
+	writeVideo := func(name string, colors ...[3]byte) string {
+		y4m := []byte("YUV4MPEG2 W4 H4 F30:1 C444\n")
+		for _, c := range colors {
+			y4m = append(y4m, "FRAME\n"...)
+			for _, v := range c {
+				y4m = append(y4m, bytes.Repeat([]byte{v}, 16)...)
+			}
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+".y4m", y4m, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	gray := [3]byte{100, 128, 128}
+	white := [3]byte{235, 128, 128}
+
+	old := writeVideo("old.mp4", gray, gray, white)
+	same := writeVideo("same.mp4", [3]byte{102, 127, 128}, white, white)
+	changed := writeVideo("changed.mp4", gray, gray, gray)
+
+	if got, err := Unchanged(old, same); err != nil || !got {
+		t.Errorf("Unchanged() for re-encoded video = %v, %v, want true", got, err)
+	}
+	if got, err := Unchanged(old, changed); err != nil || got {
+		t.Errorf("Unchanged() for changed video = %v, %v, want false", got, err)
+	}
+}
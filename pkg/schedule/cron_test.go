@@ -0,0 +1,73 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// A Sunday
+	now := time.Date(2024, time.March, 10, 14, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.March, 10, 14, 8, 0, 0, time.UTC)},
+		{"0 14 * * *", time.Date(2024, time.March, 11, 14, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.March, 10, 14, 15, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, time.March, 10, 14, 25, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2024, time.March, 11, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.March, 17, 0, 0, 0, 0, time.UTC)}, // 7 is Sunday too
+		{"0 12 1,15 * *", time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2024, time.March, 13, 0, 0, 0, 0, time.UTC)}, // Either day field matches
+		{"@hourly", time.Date(2024, time.March, 10, 15, 0, 0, 0, time.UTC)},
+		{"@DAILY", time.Date(2024, time.March, 11, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}}, // Never
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron() failed: %v", err)
+			}
+			if got := c.Next(now); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNextIsAfter(t *testing.T) {
+	c, err := ParseCron("0 14 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, time.March, 10, 14, 0, 0, 0, time.UTC)
+	if got, want := c.Next(at), at.AddDate(0, 0, 1); !got.Equal(want) {
+		t.Errorf("Next() at a matching time = %v, want the next day %v", got, want)
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * * someday",
+		"@sometimes",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) should fail", expr)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package schedule
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/config"
+	"github.com/ericmhalvorsen/witness/pkg/output"
+	"github.com/ericmhalvorsen/witness/pkg/selector"
+)
+
+// Job is a recording repeated on a cron schedule
+type Job struct {
+	Name     string
+	Cron     Cron
+	Duration time.Duration
+	Output   string
+	Region   string
+	FPS      string
+	Quality  string
+}
+
+// NewJob checks a configured schedule and parses its timing
+func NewJob(s config.Schedule) (Job, error) {
+	if s.Name == "" {
+		return Job{}, fmt.Errorf("schedule needs a name")
+	}
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return Job{}, fmt.Errorf("schedule %q: %w", s.Name, err)
+	}
+	duration, err := time.ParseDuration(s.Duration)
+	if err != nil || duration <= 0 {
+		return Job{}, fmt.Errorf("schedule %q: invalid duration %q: want a length such as 10m", s.Name, s.Duration)
+	}
+	if err := checkOutput(s.Output); err != nil {
+		return Job{}, fmt.Errorf("schedule %q: %w", s.Name, err)
+	}
+
+	return Job{
+		Name:     s.Name,
+		Cron:     cron,
+		Duration: duration,
+		Output:   s.Output,
+		Region:   s.Region,
+		FPS:      s.FPS,
+		Quality:  s.Quality,
+	}, nil
+}
+
+// NewJobs checks every configured schedule
+func NewJobs(schedules []config.Schedule) ([]Job, error) {
+	jobs := make([]Job, 0, len(schedules))
+	names := make(map[string]bool)
+	for _, s := range schedules {
+		job, err := NewJob(s)
+		if err != nil {
+			return nil, err
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("schedule %q is defined twice", job.Name)
+		}
+		names[job.Name] = true
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// OutputAt returns the file name of the recording starting at, with its
+// placeholders replaced
+func (j Job) OutputAt(at time.Time) (string, error) {
+	return output.ExpandDir(j.Output, at)
+}
+
+// ResolveRegion returns the region to record: the saved region named by
+// Region, or its x,y,w,h coordinates, or nil for the whole screen. Names
+// are looked up on each run so edits to a saved region take effect.
+func (j Job) ResolveRegion() (*capture.Region, error) {
+	region, err := resolveRegion(j.Region)
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q: %w", j.Name, err)
+	}
+	return region, nil
+}
+
+// checkOutput checks the file name pattern of recordings the daemon starts
+// on its own. They are written to its output directory, as GIF or MP4.
+func checkOutput(name string) error {
+	if name == "" || filepath.Base(name) != name {
+		return fmt.Errorf("output must be a file name, got %q", name)
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gif", ".mp4":
+	default:
+		return fmt.Errorf("output must end in .gif or .mp4, got %q", name)
+	}
+	_, err := output.ExpandDir(name, time.Time{})
+	return err
+}
+
+// resolveRegion returns a saved region by name, or parses x,y,w,h
+// coordinates, or returns nil for the whole screen if region is empty
+func resolveRegion(region string) (*capture.Region, error) {
+	if region == "" {
+		return nil, nil
+	}
+	if r, err := selector.ParseRegionString(region); err == nil {
+		return r, nil
+	}
+	return selector.LoadRegion(region)
+}
+
+// Scheduler starts jobs each time their schedules come round
+type Scheduler struct {
+	jobs  []Job
+	start func(job Job, at time.Time)
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+// NewScheduler creates a scheduler that calls start for each job when its
+// time arrives
+func NewScheduler(jobs []Job, start func(job Job, at time.Time)) *Scheduler {
+	return &Scheduler{jobs: jobs, start: start, now: time.Now, after: time.After}
+}
+
+// Next returns the jobs that run next, all starting at the returned time,
+// or none if no schedule will match again
+func (s *Scheduler) Next(after time.Time) ([]Job, time.Time) {
+	var due []Job
+	var at time.Time
+	for _, job := range s.jobs {
+		next := job.Cron.Next(after)
+		switch {
+		case next.IsZero():
+		case at.IsZero() || next.Before(at):
+			due, at = []Job{job}, next
+		case next.Equal(at):
+			due = append(due, job)
+		}
+	}
+	return due, at
+}
+
+// Run starts jobs as their times arrive until stop is closed. If the
+// machine slept through a run, it starts late on waking, and any further
+// runs missed are skipped rather than started in a burst.
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	last := s.now()
+	for {
+		due, at := s.Next(last)
+		if at.IsZero() {
+			<-stop
+			return
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-s.after(at.Sub(s.now())):
+		}
+
+		for _, job := range due {
+			s.start(job, at)
+		}
+		last = at
+		if now := s.now(); now.After(last) {
+			last = now
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/config"
+	"github.com/ericmhalvorsen/witness/pkg/selector"
+)
+
+func TestNewJob(t *testing.T) {
+	valid := config.Schedule{Name: "daily", Cron: "0 14 * * *", Duration: "10m", Output: "dashboard-{date}.mp4"}
+
+	job, err := NewJob(valid)
+	if err != nil {
+		t.Fatalf("NewJob() failed: %v", err)
+	}
+	if job.Duration != 10*time.Minute || job.Cron.String() != "0 14 * * *" {
+		t.Errorf("NewJob() = %+v", job)
+	}
+	at := time.Date(2024, time.March, 10, 14, 0, 0, 0, time.UTC)
+	if name, err := job.OutputAt(at); err != nil || name != "dashboard-2024-03-10.mp4" {
+		t.Errorf("OutputAt() = %q, %v, want dashboard-2024-03-10.mp4", name, err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *config.Schedule)
+	}{
+		{"no name", func(s *config.Schedule) { s.Name = "" }},
+		{"bad cron", func(s *config.Schedule) { s.Cron = "every day" }},
+		{"bad duration", func(s *config.Schedule) { s.Duration = "ten minutes" }},
+		{"zero duration", func(s *config.Schedule) { s.Duration = "0s" }},
+		{"no output", func(s *config.Schedule) { s.Output = "" }},
+		{"output path", func(s *config.Schedule) { s.Output = "../daily.mp4" }},
+		{"unsupported format", func(s *config.Schedule) { s.Output = "daily.webm" }},
+		{"unknown placeholder", func(s *config.Schedule) { s.Output = "{weekday}.mp4" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid
+			tt.change(&s)
+			if _, err := NewJob(s); err == nil {
+				t.Error("NewJob() should fail")
+			}
+		})
+	}
+
+	if _, err := NewJobs([]config.Schedule{valid, valid}); err == nil {
+		t.Error("NewJobs() should fail when a name is used twice")
+	}
+}
+
+func TestJobResolveRegion(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	saved := &capture.Region{X: 10, Y: 20, Width: 300, Height: 200}
+	if err := selector.SaveRegion("dashboard", saved); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		region  string
+		want    *capture.Region
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"0,0,800,600", &capture.Region{Width: 800, Height: 600}, false},
+		{"dashboard", saved, false},
+		{"missing", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := Job{Name: "daily", Region: tt.region}.ResolveRegion()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ResolveRegion(%q) error = %v, wantErr %v", tt.region, err, tt.wantErr)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("ResolveRegion(%q) = %+v, want %+v", tt.region, got, tt.want)
+		}
+	}
+}
+
+// fakeClock is a clock whose timers fire as soon as they are waited on,
+// moving the time forward by their duration, until stop is closed
+type fakeClock struct {
+	now  time.Time
+	stop chan struct{}
+}
+
+func (c *fakeClock) after(d time.Duration) <-chan time.Time {
+	select {
+	case <-c.stop:
+		return nil
+	default:
+	}
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestSchedulerRun(t *testing.T) {
+	hourly, _ := ParseCron("0 * * * *")
+	half, _ := ParseCron("30 * * * *")
+	jobs := []Job{{Name: "hourly", Cron: hourly}, {Name: "half", Cron: half}, {Name: "also-hourly", Cron: hourly}}
+
+	type run struct {
+		name string
+		at   time.Time
+	}
+	var runs []run
+	stop := make(chan struct{})
+	clock := &fakeClock{now: time.Date(2024, time.March, 10, 13, 45, 0, 0, time.UTC), stop: stop}
+	s := NewScheduler(jobs, func(job Job, at time.Time) {
+		runs = append(runs, run{job.Name, at})
+		if len(runs) == 5 {
+			close(stop)
+		}
+	})
+	s.now = func() time.Time { return clock.now }
+	s.after = clock.after
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(stop)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after stop was closed")
+	}
+
+	at := func(h, m int) time.Time { return time.Date(2024, time.March, 10, h, m, 0, 0, time.UTC) }
+	want := []run{
+		{"hourly", at(14, 0)}, {"also-hourly", at(14, 0)},
+		{"half", at(14, 30)},
+		{"hourly", at(15, 0)}, {"also-hourly", at(15, 0)},
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("runs = %v, want %v", runs, want)
+	}
+	for i := range want {
+		if runs[i].name != want[i].name || !runs[i].at.Equal(want[i].at) {
+			t.Errorf("run %d = %v, want %v", i, runs[i], want[i])
+		}
+	}
+}
+
+func TestSchedulerSkipsMissedRuns(t *testing.T) {
+	every, _ := ParseCron("*/10 * * * *")
+	stop := make(chan struct{})
+	clock := &fakeClock{now: time.Date(2024, time.March, 10, 13, 55, 0, 0, time.UTC), stop: stop}
+
+	var runs []time.Time
+	s := NewScheduler([]Job{{Name: "often", Cron: every}}, func(job Job, at time.Time) {
+		runs = append(runs, at)
+		if len(runs) == 1 {
+			// The machine sleeps for an hour after the first run
+			clock.now = clock.now.Add(time.Hour)
+		} else {
+			close(stop)
+		}
+	})
+	s.now = func() time.Time { return clock.now }
+	s.after = clock.after
+	s.Run(stop)
+
+	want := []time.Time{
+		time.Date(2024, time.March, 10, 14, 0, 0, 0, time.UTC),
+		time.Date(2024, time.March, 10, 15, 10, 0, 0, time.UTC),
+	}
+	if len(runs) != 2 || !runs[0].Equal(want[0]) || !runs[1].Equal(want[1]) {
+		t.Errorf("runs = %v, want %v", runs, want)
+	}
+}
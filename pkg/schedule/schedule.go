@@ -0,0 +1,75 @@
+// Package schedule works out when a scheduled recording starts and
+// announces it shortly beforehand
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/selector"
+)
+
+// clockLayouts are the accepted forms of a time of day
+var clockLayouts = []string{"15:04", "15:04:05", "3:04pm", "3:04:05pm", "3pm"}
+
+// ParseClock returns the next time at or after now that the clock shows
+// the time of day s, such as "14:30", "14:30:15", or "2:30pm", in now's
+// time zone. A time already past today means tomorrow.
+func ParseClock(s string, now time.Time) (time.Time, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+	for _, layout := range clockLayouts {
+		t, err := time.Parse(layout, normalized)
+		if err != nil {
+			continue
+		}
+		at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+		if at.Before(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want HH:MM, HH:MM:SS, or 2:30pm", s)
+}
+
+// Start returns when a recording scheduled with -at or -after begins, or
+// now if neither is set
+func Start(at string, after time.Duration, now time.Time) (time.Time, error) {
+	switch {
+	case at != "" && after != 0:
+		return time.Time{}, fmt.Errorf("set -at or -after, not both")
+	case after < 0:
+		return time.Time{}, fmt.Errorf("-after must not be negative, got %v", after)
+	case at != "":
+		return ParseClock(at, now)
+	default:
+		return now.Add(after), nil
+	}
+}
+
+// Notifier shows desktop notifications counting down to a scheduled
+// recording, so whoever is at the machine knows it is about to start
+type Notifier struct {
+	cmd selector.SystemCommand
+}
+
+// NewNotifier creates a notifier that uses macOS notifications
+func NewNotifier() *Notifier {
+	return NewNotifierWithExecutor(selector.NewRealSystemCommand())
+}
+
+// NewNotifierWithExecutor creates a notifier with a custom command executor
+// This is primarily used for testing with mock commands
+func NewNotifierWithExecutor(executor selector.SystemCommand) *Notifier {
+	return &Notifier{cmd: executor}
+}
+
+// Countdown announces that recording starts in remaining
+func (n *Notifier) Countdown(remaining time.Duration) error {
+	script := fmt.Sprintf(`display notification "Recording starts in %v" with title "Witness" subtitle "Scheduled recording"`,
+		remaining.Round(time.Second))
+	if _, err := n.cmd.Run("osascript", "-e", script); err != nil {
+		return fmt.Errorf("failed to show notification: %w", err)
+	}
+	return nil
+}
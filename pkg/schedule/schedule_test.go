@@ -0,0 +1,86 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/selector"
+)
+
+func TestParseClock(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"14:30", time.Date(2024, time.March, 10, 14, 30, 0, 0, time.UTC), false},
+		{"14:30:15", time.Date(2024, time.March, 10, 14, 30, 15, 0, time.UTC), false},
+		{"2:30pm", time.Date(2024, time.March, 10, 14, 30, 0, 0, time.UTC), false},
+		{"2:30 PM", time.Date(2024, time.March, 10, 14, 30, 0, 0, time.UTC), false},
+		{"5pm", time.Date(2024, time.March, 10, 17, 0, 0, 0, time.UTC), false},
+		{"14:00", now, false},
+		{"9:15", time.Date(2024, time.March, 11, 9, 15, 0, 0, time.UTC), false}, // Already past, so tomorrow
+		{"25:00", time.Time{}, true},
+		{"soon", time.Time{}, true},
+		{"", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseClock(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseClock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseClock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		at      string
+		after   time.Duration
+		want    time.Time
+		wantErr bool
+	}{
+		{"immediately", "", 0, now, false},
+		{"after", "", 10 * time.Minute, now.Add(10 * time.Minute), false},
+		{"at", "14:30", 0, now.Add(30 * time.Minute), false},
+		{"both", "14:30", time.Minute, time.Time{}, true},
+		{"negative", "", -time.Minute, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Start(tt.at, tt.after, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Start() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	mock := selector.NewMockSystemCommand()
+
+	if err := NewNotifierWithExecutor(mock).Countdown(9800 * time.Millisecond); err != nil {
+		t.Fatalf("Countdown() error = %v", err)
+	}
+	if mock.GetCallCount("osascript") != 1 {
+		t.Fatalf("osascript called %d times, want 1", mock.GetCallCount("osascript"))
+	}
+	if script := mock.CallLog[0].Args[1]; !strings.Contains(script, "Recording starts in 10s") {
+		t.Errorf("notification script %q should say when recording starts", script)
+	}
+}
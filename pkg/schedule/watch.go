@@ -0,0 +1,153 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/config"
+	"github.com/ericmhalvorsen/witness/pkg/output"
+)
+
+// DefaultWatchInterval is how often a Watcher checks which application is
+// frontmost
+const DefaultWatchInterval = time.Second
+
+// WatchRule is a recording made while an application is frontmost
+type WatchRule struct {
+	App     string
+	Output  string
+	Region  string
+	FPS     string
+	Quality string
+}
+
+// NewWatchRule checks a configured watch rule
+func NewWatchRule(w config.Watch) (WatchRule, error) {
+	if strings.TrimSpace(w.App) == "" {
+		return WatchRule{}, fmt.Errorf("watch rule needs an application name")
+	}
+	if err := checkOutput(w.Output); err != nil {
+		return WatchRule{}, fmt.Errorf("watch %q: %w", w.App, err)
+	}
+
+	return WatchRule{
+		App:     w.App,
+		Output:  w.Output,
+		Region:  w.Region,
+		FPS:     w.FPS,
+		Quality: w.Quality,
+	}, nil
+}
+
+// NewWatchRules checks every configured watch rule
+func NewWatchRules(watches []config.Watch) ([]WatchRule, error) {
+	rules := make([]WatchRule, 0, len(watches))
+	apps := make(map[string]bool)
+	for _, w := range watches {
+		rule, err := NewWatchRule(w)
+		if err != nil {
+			return nil, err
+		}
+		app := strings.ToLower(rule.App)
+		if apps[app] {
+			return nil, fmt.Errorf("application %q is watched twice", rule.App)
+		}
+		apps[app] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Matches reports whether app is the rule's application: its name contains
+// the rule's, ignoring case, as -window matches application names
+func (r WatchRule) Matches(app string) bool {
+	return app != "" && strings.Contains(strings.ToLower(app), strings.ToLower(r.App))
+}
+
+// OutputAt returns the file name of the session starting at, with its
+// placeholders replaced
+func (r WatchRule) OutputAt(at time.Time) (string, error) {
+	return output.ExpandDir(r.Output, at)
+}
+
+// ResolveRegion returns the region to record, as Job.ResolveRegion does
+func (r WatchRule) ResolveRegion() (*capture.Region, error) {
+	region, err := resolveRegion(r.Region)
+	if err != nil {
+		return nil, fmt.Errorf("watch %q: %w", r.App, err)
+	}
+	return region, nil
+}
+
+// Watcher records a session for as long as a watched application stays
+// frontmost
+type Watcher struct {
+	rules     []WatchRule
+	frontmost func() (string, error)
+	start     func(rule WatchRule, at time.Time) (stop func(), err error)
+	interval  time.Duration
+	now       func() time.Time
+	after     func(time.Duration) <-chan time.Time
+}
+
+// NewWatcher creates a watcher that asks frontmost for the frontmost
+// application's name. When a rule's application comes to the front it
+// calls start, and calls the stop function start returns once the
+// application loses focus.
+func NewWatcher(rules []WatchRule, frontmost func() (string, error), start func(rule WatchRule, at time.Time) (stop func(), err error)) *Watcher {
+	return &Watcher{
+		rules:     rules,
+		frontmost: frontmost,
+		start:     start,
+		interval:  DefaultWatchInterval,
+		now:       time.Now,
+		after:     time.After,
+	}
+}
+
+// Match returns the index of the first rule for app, or -1 if none
+// applies
+func (w *Watcher) Match(app string) int {
+	for i, rule := range w.rules {
+		if rule.Matches(app) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Run checks the frontmost application until stop is closed, then stops
+// any session still recording. A session that fails to start is not
+// retried until its application comes to the front again, and checks that
+// fail leave the current session running.
+func (w *Watcher) Run(stop <-chan struct{}) {
+	current := -1
+	var end func()
+	for {
+		if app, err := w.frontmost(); err == nil {
+			if i := w.Match(app); i != current {
+				if end != nil {
+					end()
+					end = nil
+				}
+				current = i
+				if i >= 0 {
+					if stopSession, err := w.start(w.rules[i], w.now()); err == nil {
+						end = stopSession
+					}
+				}
+			}
+		}
+
+		select {
+		case <-stop:
+			if end != nil {
+				end()
+			}
+			return
+		case <-w.after(w.interval):
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package schedule
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/config"
+)
+
+func TestNewWatchRule(t *testing.T) {
+	valid := config.Watch{App: "Figma", Output: "figma-{date}-{time}.mp4"}
+	rule, err := NewWatchRule(valid)
+	if err != nil {
+		t.Fatalf("NewWatchRule() failed: %v", err)
+	}
+	at := time.Date(2024, time.March, 10, 14, 5, 9, 0, time.UTC)
+	if name, err := rule.OutputAt(at); err != nil || name != "figma-2024-03-10-140509.mp4" {
+		t.Errorf("OutputAt() = %q, %v, want figma-2024-03-10-140509.mp4", name, err)
+	}
+
+	for _, w := range []config.Watch{
+		{App: " ", Output: "figma.mp4"},
+		{App: "Figma"},
+		{App: "Figma", Output: "designs/figma.mp4"},
+		{App: "Figma", Output: "figma.mov"},
+	} {
+		if _, err := NewWatchRule(w); err == nil {
+			t.Errorf("NewWatchRule(%+v) should fail", w)
+		}
+	}
+
+	if _, err := NewWatchRules([]config.Watch{valid, {App: "figma", Output: "other.gif"}}); err == nil {
+		t.Error("NewWatchRules() should fail when an application is watched twice")
+	}
+}
+
+func TestWatchRuleMatches(t *testing.T) {
+	rule := WatchRule{App: "figma"}
+	tests := []struct {
+		app  string
+		want bool
+	}{
+		{"Figma", true},
+		{"Figma Beta", true},
+		{"Sketch", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := rule.Matches(tt.app); got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.app, got, tt.want)
+		}
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	rules := []WatchRule{{App: "Figma"}, {App: "Sketch"}}
+	// The frontmost application at each check; an error keeps the session
+	checks := []string{"Terminal", "Figma", "Figma", "error", "Figma", "Sketch", "Safari", "Figma", "Figma"}
+
+	var events []string
+	stop := make(chan struct{})
+	n := 0
+	w := NewWatcher(rules, func() (string, error) {
+		app := checks[n]
+		n++
+		if n == len(checks) {
+			close(stop)
+		}
+		if app == "error" {
+			return "", errors.New("window list unavailable")
+		}
+		return app, nil
+	}, func(rule WatchRule, at time.Time) (func(), error) {
+		events = append(events, "start "+rule.App)
+		return func() { events = append(events, "stop "+rule.App) }, nil
+	})
+	w.after = tickUntil(stop)
+
+	done := make(chan struct{})
+	go func() {
+		w.Run(stop)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after stop was closed")
+	}
+
+	// The last session is stopped when the watcher is
+	want := []string{"start Figma", "stop Figma", "start Sketch", "stop Sketch", "start Figma", "stop Figma"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+func TestWatcherRetriesOnRefocus(t *testing.T) {
+	checks := []string{"Figma", "Figma", "Safari", "Figma"}
+	stop := make(chan struct{})
+	n, starts := 0, 0
+	w := NewWatcher([]WatchRule{{App: "Figma"}}, func() (string, error) {
+		app := checks[n]
+		n++
+		if n == len(checks) {
+			close(stop)
+		}
+		return app, nil
+	}, func(rule WatchRule, at time.Time) (func(), error) {
+		starts++
+		return nil, errors.New("a recording is already scheduled or running")
+	})
+	w.after = tickUntil(stop)
+	w.Run(stop)
+
+	if starts != 2 {
+		t.Errorf("start called %d times, want 2 (once per time Figma came to the front)", starts)
+	}
+}
+
+// Helper function returning timers that fire at once until stop is closed
+func tickUntil(stop <-chan struct{}) func(time.Duration) <-chan time.Time {
+	return func(time.Duration) <-chan time.Time {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+}
@@ -0,0 +1,86 @@
+package selector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Adjuster is implemented by selectors that can show an existing region
+// for the user to correct, instead of selecting a new one from scratch
+type Adjuster interface {
+	// Adjust shows region selected and returns it once the user accepts
+	// their changes, or ErrCanceled
+	Adjust(region *capture.Region) (*capture.Region, error)
+}
+
+// Nudge applies adjustments such as "left-5 right+10 y+2" to a region.
+// Each adjustment names a part of the region followed by +N or -N to
+// move it, or =N to set it:
+//
+//   - left, top, right, bottom move one edge, keeping the others in place
+//   - x and y move the whole region
+//   - w (width) and h (height) resize it from the bottom right corner
+//
+// Adjustments are separated by spaces or commas and applied in order.
+func Nudge(region capture.Region, spec string) (capture.Region, error) {
+	fields := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+
+	for _, field := range fields {
+		i := strings.IndexAny(field, "+-=")
+		if i <= 0 {
+			return region, fmt.Errorf("invalid adjustment %q (expected e.g. left-5, w+10, or x=0)", field)
+		}
+		part, op := strings.ToLower(field[:i]), field[i]
+		n, err := strconv.Atoi(field[i+1:])
+		if err != nil {
+			return region, fmt.Errorf("invalid adjustment %q: %q is not a number", field, field[i+1:])
+		}
+
+		// Edges are adjusted through their position, so =N places the
+		// edge at N and +N/-N moves it
+		left, top := region.X, region.Y
+		right, bottom := region.X+region.Width, region.Y+region.Height
+		apply := func(v int) int {
+			switch op {
+			case '+':
+				return v + n
+			case '-':
+				return v - n
+			default:
+				return n
+			}
+		}
+
+		switch part {
+		case "left", "l":
+			left = apply(left)
+		case "top", "t":
+			top = apply(top)
+		case "right", "r":
+			right = apply(right)
+		case "bottom", "b":
+			bottom = apply(bottom)
+		case "x":
+			left, right = apply(left), apply(left)+region.Width
+		case "y":
+			top, bottom = apply(top), apply(top)+region.Height
+		case "w", "width":
+			right = left + apply(region.Width)
+		case "h", "height":
+			bottom = top + apply(region.Height)
+		default:
+			return region, fmt.Errorf("invalid adjustment %q: unknown part %q (use left, top, right, bottom, x, y, w, or h)", field, part)
+		}
+
+		if right <= left || bottom <= top {
+			return region, fmt.Errorf("adjustment %q leaves the region empty", field)
+		}
+		region = capture.Region{X: left, Y: top, Width: right - left, Height: bottom - top}
+	}
+	return region, nil
+}
@@ -0,0 +1,47 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestNudge(t *testing.T) {
+	region := capture.Region{X: 100, Y: 100, Width: 800, Height: 600}
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    capture.Region
+		wantErr bool
+	}{
+		{name: "nothing", spec: "", want: region},
+		{name: "left edge", spec: "left-5", want: capture.Region{X: 95, Y: 100, Width: 805, Height: 600}},
+		{name: "right and bottom edges", spec: "right+10 bottom-20", want: capture.Region{X: 100, Y: 100, Width: 810, Height: 580}},
+		{name: "short names and commas", spec: "l+1,t+1,r-1,b-1", want: capture.Region{X: 101, Y: 101, Width: 798, Height: 598}},
+		{name: "move", spec: "x+10 y-100", want: capture.Region{X: 110, Y: 0, Width: 800, Height: 600}},
+		{name: "set position", spec: "x=0", want: capture.Region{X: 0, Y: 100, Width: 800, Height: 600}},
+		{name: "set an edge", spec: "top=50", want: capture.Region{X: 100, Y: 50, Width: 800, Height: 650}},
+		{name: "resize", spec: "w+4 height=480", want: capture.Region{X: 100, Y: 100, Width: 804, Height: 480}},
+		{name: "applied in order", spec: "x=0 left+10", want: capture.Region{X: 10, Y: 100, Width: 790, Height: 600}},
+		{name: "case insensitive", spec: "Left-5", want: capture.Region{X: 95, Y: 100, Width: 805, Height: 600}},
+		{name: "unknown part", spec: "middle+5", wantErr: true},
+		{name: "missing operator", spec: "left", wantErr: true},
+		{name: "missing part", spec: "+5", wantErr: true},
+		{name: "not a number", spec: "left-five", wantErr: true},
+		{name: "empty result", spec: "left+800", wantErr: true},
+		{name: "zero width", spec: "w=0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Nudge(region, tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Nudge(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Nudge(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,27 +2,31 @@ package selector
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/config"
 )
 
+// ErrNoDefault means no default region has been set
+var ErrNoDefault = errors.New("no default region set")
+
 // RegionConfig stores saved regions
 type RegionConfig struct {
 	Regions map[string]*capture.Region `json:"regions"`
-	Default string                      `json:"default,omitempty"`
+	Default string                     `json:"default,omitempty"`
 }
 
 // getConfigPath returns the path to the config file
 func getConfigPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	configDir, err := config.Dir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return "", err
 	}
 
-	configDir := filepath.Join(homeDir, ".config", "witness")
 	configFile := filepath.Join(configDir, "regions.json")
 
 	// Create config directory if it doesn't exist
@@ -179,7 +183,7 @@ func GetDefaultRegion() (*capture.Region, error) {
 	}
 
 	if config.Default == "" {
-		return nil, fmt.Errorf("no default region set")
+		return nil, ErrNoDefault
 	}
 
 	return LoadRegion(config.Default)
@@ -22,9 +22,12 @@ func setupTestConfig(t *testing.T) (string, func()) {
 	// Set HOME to temp directory
 	oldHome := os.Getenv("HOME")
 	os.Setenv("HOME", tmpDir)
+	oldXDG := os.Getenv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", "")
 
 	cleanup := func() {
 		os.Setenv("HOME", oldHome)
+		os.Setenv("XDG_CONFIG_HOME", oldXDG)
 		os.RemoveAll(tmpDir)
 	}
 
@@ -1,9 +1,14 @@
 package selector
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/parse"
 )
 
 // Selector provides methods for selecting screen regions
@@ -15,9 +20,27 @@ type Selector interface {
 	SelectWithName(name string) (*capture.Region, error)
 }
 
+// EnvRegion is the environment variable NonInteractive reads a region from
+const EnvRegion = "WITNESS_REGION"
+
+// Selection errors
+var (
+	// ErrCanceled means the user dismissed the selector without selecting
+	ErrCanceled = errors.New("selection canceled")
+
+	// ErrTimeout means the user did not finish selecting a region in time
+	ErrTimeout = errors.New("timed out waiting for a region to be selected")
+)
+
 // NewSelector creates a platform-specific selector
 func NewSelector() (Selector, error) {
-	return newPlatformSelector()
+	return NewSelectorWithConfig(DefaultConfig())
+}
+
+// NewSelectorWithConfig creates a platform-specific selector with the
+// given configuration
+func NewSelectorWithConfig(config Config) (Selector, error) {
+	return newPlatformSelector(config)
 }
 
 // Config holds selector configuration
@@ -27,36 +50,128 @@ type Config struct {
 
 	// Whether to show dimensions during selection
 	ShowDimensions bool
+
+	// Timeout ends an interactive selection that takes longer with
+	// ErrTimeout. 0 waits as long as it takes.
+	Timeout time.Duration
+
+	// SelectionRetries is how many more times a selection is read back
+	// when it is missing or does not match the screenshot taken, as when
+	// macOS has not yet saved it to the screencapture preferences
+	SelectionRetries int
+
+	// SelectionBackoff is the wait before the first retry, doubling after
+	// each one
+	SelectionBackoff time.Duration
+
+	// Method chooses how the macOS selector finds out where the region
+	// was dragged. The zero value means SelectionAuto.
+	Method SelectionMethod
+}
+
+// SelectionMethod is where the macOS selector reads the coordinates of the
+// region dragged out in screencapture from
+type SelectionMethod string
+
+const (
+	// SelectionAuto follows the drag with an event tap, and falls back to
+	// the screencapture preferences when the tap is not permitted or did
+	// not see a drag matching the screenshot, as when a window was clicked
+	SelectionAuto SelectionMethod = "auto"
+
+	// SelectionEventTap only follows the drag with an event tap, which
+	// needs the Input Monitoring permission. It works when the
+	// screencapture preferences are managed or unreadable, but cannot
+	// select whole windows.
+	SelectionEventTap SelectionMethod = "tap"
+
+	// SelectionPreferences only reads the last-selection entry that
+	// screencapture saves in the com.apple.screencapture preferences
+	SelectionPreferences SelectionMethod = "prefs"
+)
+
+// ParseSelectionMethod parses a selection method name (auto, tap, or prefs)
+func ParseSelectionMethod(s string) (SelectionMethod, error) {
+	switch m := SelectionMethod(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return SelectionAuto, nil
+	case SelectionAuto, SelectionEventTap, SelectionPreferences:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid selection method %q: want auto, tap, or prefs", s)
+	}
 }
 
 // DefaultConfig returns the default selector configuration
 func DefaultConfig() Config {
 	return Config{
-		Message:        "Select the screen region to capture",
-		ShowDimensions: true,
+		Message:          "Select the screen region to capture",
+		ShowDimensions:   true,
+		SelectionRetries: 5,
+		SelectionBackoff: 50 * time.Millisecond,
+		Method:           SelectionAuto,
 	}
 }
 
-// ParseRegionString parses a region string in format "x,y,w,h"
-func ParseRegionString(s string) (*capture.Region, error) {
-	var x, y, w, h int
-	n, err := fmt.Sscanf(s, "%d,%d,%d,%d", &x, &y, &w, &h)
-	if err != nil {
-		return nil, fmt.Errorf("invalid region format: %w", err)
+// SelectMultiple selects several regions in one session, saving each under
+// the name returned by name, which is given the region's number starting
+// at 1. It stops after count regions, or when the user cancels a selection
+// if count is 0. Canceling after at least one region was saved ends the
+// session without an error. It returns the names saved, in order.
+func SelectMultiple(sel Selector, count int, name func(n int, region *capture.Region) (string, error)) ([]string, error) {
+	var names []string
+	for n := 1; count <= 0 || n <= count; n++ {
+		region, err := sel.Select()
+		if errors.Is(err, ErrCanceled) && len(names) > 0 {
+			return names, nil
+		}
+		if err != nil {
+			return names, err
+		}
+
+		regionName, err := name(n, region)
+		if err != nil {
+			return names, err
+		}
+		if err := SaveRegion(regionName, region); err != nil {
+			return names, fmt.Errorf("failed to save region: %w", err)
+		}
+		fmt.Printf("✓ Saved region '%s'\n", regionName)
+		names = append(names, regionName)
+	}
+	return names, nil
+}
+
+// NonInteractive returns a region without asking the user, so scripts
+// never wait for someone to drag a rectangle. It uses regionStr if it is
+// not empty, then $WITNESS_REGION, then the default saved region. Both
+// strings are in "x,y,w,h" format.
+func NonInteractive(regionStr string) (*capture.Region, error) {
+	if s := strings.TrimSpace(regionStr); s != "" {
+		return ParseRegionString(s)
 	}
-	if n != 4 {
-		return nil, fmt.Errorf("region must have 4 values (x,y,w,h), got %d", n)
+	if s := strings.TrimSpace(os.Getenv(EnvRegion)); s != "" {
+		region, err := ParseRegionString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid $%s: %w", EnvRegion, err)
+		}
+		return region, nil
 	}
-	if w <= 0 || h <= 0 {
-		return nil, fmt.Errorf("width and height must be positive")
+
+	region, err := GetDefaultRegion()
+	if err != nil {
+		return nil, fmt.Errorf("no region to use without selecting one (pass -region, set $%s, or save a default region): %w", EnvRegion, err)
 	}
+	return region, nil
+}
 
-	return &capture.Region{
-		X:      x,
-		Y:      y,
-		Width:  w,
-		Height: h,
-	}, nil
+// ParseRegionString parses a region string in format "x,y,w,h"
+func ParseRegionString(s string) (*capture.Region, error) {
+	region, err := parse.Region(s)
+	if err != nil {
+		return nil, err
+	}
+	return &region, nil
 }
 
 // FormatRegionString converts a region to string format "x,y,w,h"
@@ -1,37 +1,68 @@
+//go:build darwin
 // +build darwin
 
 package selector
 
 import (
+	"errors"
 	"fmt"
+	"image"
+	_ "image/png"
+	"math"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"time"
 
+	"github.com/ericmhalvorsen/witness/internal/macos"
 	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/parse"
 )
 
-// macOSSelector uses macOS built-in tools for region selection
+// macOSSelector uses macOS built-in tools for region selection. Every
+// command it runs goes through sysCmdExecutor, so tests exercise the same
+// code as real selections.
 type macOSSelector struct {
-	config Config
+	config         Config
 	sysCmdExecutor SystemCommand
+
+	// sleep waits between attempts to read the selection
+	sleep func(time.Duration)
+
+	// scaleAt returns the pixels per point of the display showing a global
+	// point, or 0 if it is not known
+	scaleAt func(x, y int) float64
+
+	// watchDrag starts following the pointer before screencapture runs
+	watchDrag func() (dragWatcher, error)
 }
 
-// newPlatformSelector creates a macOS selector
-func newPlatformSelector() (Selector, error) {
-	return &macOSSelector{
-		config: DefaultConfig(),
-		sysCmdExecutor: NewRealSystemCommand(),
-	}, nil
+// dragWatcher follows the drag that selects a region
+type dragWatcher interface {
+	// stop ends the watch and returns the rectangle between the last press
+	// and the release after it, if a drag was seen
+	stop() (capture.Region, bool)
 }
 
-// NewMacOSSelectorWithExecutor creates a macOS selector with a custom command executor
-// This is primarily used for testing with mock commands
+// newPlatformSelector creates a macOS selector that runs real commands
+func newPlatformSelector(config Config) (Selector, error) {
+	s := NewMacOSSelectorWithExecutor(&RealSystemCommand{Timeout: config.Timeout}).(*macOSSelector)
+	s.config = config
+	return s, nil
+}
+
+// NewMacOSSelectorWithExecutor creates a macOS selector that runs
+// screencapture and defaults through executor, or through
+// RealSystemCommand if executor is nil. Tests pass a MockSystemCommand.
 func NewMacOSSelectorWithExecutor(executor SystemCommand) Selector {
+	if executor == nil {
+		executor = NewRealSystemCommand()
+	}
 	return &macOSSelector{
-		config: DefaultConfig(),
+		config:         DefaultConfig(),
 		sysCmdExecutor: executor,
+		sleep:          time.Sleep,
+		scaleAt:        displayScaleAt,
+		watchDrag:      watchDragTap,
 	}
 }
 
@@ -48,21 +79,40 @@ func (s *macOSSelector) Select() (*capture.Region, error) {
 	tmpFile := filepath.Join(tmpDir, "witness-selection-tmp.png")
 	defer os.Remove(tmpFile) // Clean up
 
+	// Follow the drag unless only the preferences are to be read. Without
+	// the Input Monitoring permission, auto falls back to the preferences.
+	var drag dragWatcher
+	if s.config.Method != SelectionPreferences {
+		w, err := s.watchDrag()
+		if err != nil && s.config.Method == SelectionEventTap {
+			return nil, fmt.Errorf("failed to follow the selection: %w", err)
+		}
+		drag = w
+	}
+
 	// Use screencapture with interactive selection
 	// -i: interactive mode (click and drag)
 	// -x: no sound
-	if err := s.sysCmdExecutor.RunInteractive("screencapture", "-i", "-x", tmpFile); err != nil {
+	err := s.sysCmdExecutor.RunInteractive("screencapture", "-i", "-x", tmpFile)
+	var dragged capture.Region
+	var seen bool
+	if drag != nil {
+		dragged, seen = drag.stop()
+	}
+	if err != nil {
+		if errors.Is(err, ErrTimeout) {
+			return nil, err
+		}
 		// User likely canceled (ESC)
-		return nil, fmt.Errorf("selection canceled")
+		return nil, ErrCanceled
 	}
 
 	// Check if file was created (user completed selection)
 	if _, err := os.Stat(tmpFile); os.IsNotExist(err) {
-		return nil, fmt.Errorf("no region selected")
+		return nil, fmt.Errorf("no region selected: %w", ErrCanceled)
 	}
 
-	// Read the last selection from macOS preferences
-	region, err := s.readLastSelection()
+	region, err := s.locateSelection(tmpFile, dragged, seen)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read selection coordinates: %w", err)
 	}
@@ -89,7 +139,107 @@ func (s *macOSSelector) SelectWithName(name string) (*capture.Region, error) {
 	return region, nil
 }
 
-// readLastSelection reads the last selection coordinates from macOS preferences
+// locateSelection finds where the region in screenshot was selected: where
+// it was dragged if a drag matching it was seen, else from the
+// screencapture preferences, unless the method only allows the event tap
+func (s *macOSSelector) locateSelection(screenshot string, dragged capture.Region, seen bool) (*capture.Region, error) {
+	if seen {
+		shot, err := screenshotSize(screenshot)
+		if err != nil || s.matchesScreenshot(dragged, shot) {
+			return &dragged, nil
+		}
+	}
+	if s.config.Method == SelectionEventTap {
+		return nil, fmt.Errorf("no drag matching the screenshot was seen (drag out the region; selecting a window needs the %q method)",
+			SelectionPreferences)
+	}
+	return s.readSelection(screenshot)
+}
+
+// readSelection reads back the selection of the screenshot just saved. The
+// preferences it is stored in may not be flushed yet when screencapture
+// exits, so a selection that cannot be read, or whose size does not match
+// the screenshot, is read again with backoff. If it never settles, a
+// selection that parsed is used as it is, and one whose position was read
+// but not its size takes its size from the screenshot.
+func (s *macOSSelector) readSelection(screenshot string) (*capture.Region, error) {
+	shot, shotErr := screenshotSize(screenshot)
+	delay := s.config.SelectionBackoff
+
+	var region *capture.Region
+	var err error
+	for attempt := 0; ; attempt++ {
+		region, err = s.readLastSelection()
+		if err == nil && (shotErr != nil || s.matchesScreenshot(*region, shot)) {
+			return region, nil
+		}
+		if attempt >= s.config.SelectionRetries {
+			break
+		}
+		s.sleep(delay)
+		delay *= 2
+	}
+
+	if err == nil {
+		return region, nil
+	}
+	if region != nil && shotErr == nil {
+		if scale := s.scaleAt(region.X, region.Y); scale > 0 {
+			region.Width = int(math.Ceil(float64(shot.X) / scale))
+			region.Height = int(math.Ceil(float64(shot.Y) / scale))
+			return region, nil
+		}
+	}
+	return nil, err
+}
+
+// matchesScreenshot reports whether a selection in points could have
+// produced a screenshot of the given size in pixels, allowing for the
+// selection being rounded out to whole points. It cannot tell when the
+// display's scale is unknown, and reports true.
+func (s *macOSSelector) matchesScreenshot(r capture.Region, shot image.Point) bool {
+	scale := s.scaleAt(r.X, r.Y)
+	if scale <= 0 {
+		return true
+	}
+	tolerance := 2*scale + 1
+	return math.Abs(float64(r.Width)*scale-float64(shot.X)) <= tolerance &&
+		math.Abs(float64(r.Height)*scale-float64(shot.Y)) <= tolerance
+}
+
+// screenshotSize returns the pixel size of a saved screenshot
+func screenshotSize(path string) (image.Point, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Point{}, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return image.Point{}, err
+	}
+	return image.Pt(config.Width, config.Height), nil
+}
+
+// displayScaleAt returns the scale factor of the display containing a
+// global point, or 0 if no display does
+func displayScaleAt(x, y int) float64 {
+	displays, err := capture.ListDisplays()
+	if err != nil {
+		return 0
+	}
+	for _, d := range displays {
+		if d.Contains(capture.Region{X: x, Y: y, Width: 1, Height: 1}) {
+			return d.ScaleFactor
+		}
+	}
+	return 0
+}
+
+// readLastSelection reads the last selection coordinates from macOS
+// preferences. A selection without a size is returned along with
+// parse.ErrInvalidDimensions, holding its position.
 func (s *macOSSelector) readLastSelection() (*capture.Region, error) {
 	// Read the screencapture preferences
 	output, err := s.sysCmdExecutor.Run("defaults", "read",
@@ -99,49 +249,84 @@ func (s *macOSSelector) readLastSelection() (*capture.Region, error) {
 		return nil, fmt.Errorf("failed to read last-selection: %w", err)
 	}
 
-	// Parse the output
-	// Format is like: {
-	//     Height = 480;
-	//     Width = 640;
-	//     X = 100;
-	//     Y = 200;
-	// }
-	region := &capture.Region{}
-
-	// Parse each line
-	lines := strings.Split(outputStr, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.Contains(line, "=") {
-			parts := strings.Split(line, "=")
-			if len(parts) != 2 {
+	region, err := parse.Selection(output)
+	if errors.Is(err, parse.ErrInvalidDimensions) {
+		return &region, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &region, nil
+}
+
+// tapDragWatcher follows a drag with a listen-only event tap, so the
+// selection is known without reading the screencapture preferences
+type tapDragWatcher struct {
+	tap    *macos.ClickTap
+	done   chan struct{}
+	region capture.Region
+	seen   bool
+}
+
+// watchDragTap starts an event tap for button presses and releases. It
+// fails without the Input Monitoring permission.
+func watchDragTap() (dragWatcher, error) {
+	tap, err := macos.WatchDrags()
+	if err != nil {
+		return nil, err
+	}
+	w := &tapDragWatcher{tap: tap, done: make(chan struct{})}
+	go w.follow()
+	return w, nil
+}
+
+// follow pairs each release with the press before it until the tap stops
+func (w *tapDragWatcher) follow() {
+	defer close(w.done)
+
+	clicks, releases := w.tap.Clicks(), w.tap.Releases()
+	var press *capture.Click
+	for clicks != nil || releases != nil {
+		select {
+		case c, ok := <-clicks:
+			if !ok {
+				clicks = nil
 				continue
 			}
-			key := strings.TrimSpace(parts[0])
-			valueStr := strings.TrimSpace(strings.TrimSuffix(parts[1], ";"))
-
-			value, err := strconv.ParseFloat(valueStr, 64)
-			if err != nil {
+			press = &capture.Click{X: c.X, Y: c.Y, Time: c.Time}
+		case r, ok := <-releases:
+			if !ok {
+				releases = nil
 				continue
 			}
-
-			switch key {
-			case "X":
-				region.X = int(value)
-			case "Y":
-				region.Y = int(value)
-			case "Width":
-				region.Width = int(value)
-			case "Height":
-				region.Height = int(value)
+			if press != nil && !r.Time.Before(press.Time) {
+				w.region, w.seen = dragRegion(*press, capture.Click(r))
 			}
 		}
 	}
+}
 
-	// Validate the region
-	if region.Width <= 0 || region.Height <= 0 {
-		return nil, fmt.Errorf("invalid region dimensions: %dx%d", region.Width, region.Height)
-	}
+// stop removes the event tap and returns the last drag it saw
+func (w *tapDragWatcher) stop() (capture.Region, bool) {
+	w.tap.Stop()
+	<-w.done
+	return w.region, w.seen
+}
 
-	return region, nil
+// dragRegion returns the rectangle spanned by a drag from press to release
+// in either direction. A click that did not move spans nothing, and is
+// reported as not a drag.
+func dragRegion(press, release capture.Click) (capture.Region, bool) {
+	x0, x1 := press.X, release.X
+	if x1 < x0 {
+		x0, x1 = x1, x0
+	}
+	y0, y1 := press.Y, release.Y
+	if y1 < y0 {
+		y0, y1 = y1, y0
+	}
+	if x1 == x0 || y1 == y0 {
+		return capture.Region{}, false
+	}
+	return capture.Region{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}, true
 }
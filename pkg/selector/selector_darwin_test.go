@@ -1,14 +1,53 @@
+//go:build darwin
 // +build darwin
 
 package selector
 
 import (
+	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
 )
 
+func TestNewMacOSSelectorWithExecutor(t *testing.T) {
+	mockCmd := NewMockSystemCommand()
+	if s := NewMacOSSelectorWithExecutor(mockCmd).(*macOSSelector); s.sysCmdExecutor != mockCmd {
+		t.Error("selector should run commands through the given executor")
+	}
+
+	// No executor means real commands, as NewSelector uses
+	s := NewMacOSSelectorWithExecutor(nil).(*macOSSelector)
+	if _, ok := s.sysCmdExecutor.(*RealSystemCommand); !ok {
+		t.Errorf("executor = %T, want *RealSystemCommand", s.sysCmdExecutor)
+	}
+
+	platform, err := newPlatformSelector(DefaultConfig())
+	if err != nil {
+		t.Fatalf("newPlatformSelector() failed: %v", err)
+	}
+	if _, ok := platform.(*macOSSelector).sysCmdExecutor.(*RealSystemCommand); !ok {
+		t.Errorf("platform selector executor = %T, want *RealSystemCommand", platform.(*macOSSelector).sysCmdExecutor)
+	}
+}
+
+func TestMacOSSelectorSelectTimeout(t *testing.T) {
+	mockCmd := NewMockSystemCommand()
+	mockCmd.SetError("screencapture", fmt.Errorf("screencapture: %w", ErrTimeout))
+
+	selector := NewMacOSSelectorWithExecutor(mockCmd)
+
+	if _, err := selector.Select(); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Select() error = %v, want %v", err, ErrTimeout)
+	}
+}
+
 func TestMacOSSelectorReadLastSelection(t *testing.T) {
 	mockCmd := NewMockSystemCommand()
 
@@ -117,6 +156,215 @@ func TestMacOSSelectorReadLastSelectionMalformedOutput(t *testing.T) {
 	}
 }
 
+// Helper function to write a blank PNG screenshot of the given pixel size
+func writeScreenshot(t *testing.T, width, height int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "selection.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMacOSSelectorReadSelectionRetries(t *testing.T) {
+	const (
+		valid   = `{ Height = 30; Width = 40; X = 100; Y = 200; }`
+		stale   = `{ Height = 300; Width = 300; X = 5; Y = 5; }`
+		noSize  = `{ Height = 0; Width = 0; X = 100; Y = 200; }`
+		missing = `The domain/default pair of (com.apple.screencapture, last-selection) does not exist`
+	)
+
+	tests := []struct {
+		name    string
+		outputs []string
+		want    capture.Region
+		reads   int
+		wantErr bool
+	}{
+		{"read at once", []string{valid}, capture.Region{X: 100, Y: 200, Width: 40, Height: 30}, 1, false},
+		{"saved after a retry", []string{missing, noSize, valid}, capture.Region{X: 100, Y: 200, Width: 40, Height: 30}, 3, false},
+		{"stale selection replaced", []string{stale, valid}, capture.Region{X: 100, Y: 200, Width: 40, Height: 30}, 2, false},
+		{"size taken from the screenshot", []string{noSize}, capture.Region{X: 100, Y: 200, Width: 40, Height: 30}, 4, false},
+		{"selection kept when it never matches", []string{stale}, capture.Region{X: 5, Y: 5, Width: 300, Height: 300}, 4, false},
+		{"no selection saved", []string{missing}, capture.Region{}, 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCmd := NewMockSystemCommand()
+			var responses []Response
+			for _, out := range tt.outputs {
+				responses = append(responses, Response{Output: []byte(out)})
+			}
+			mockCmd.SetResponses("defaults", nil, responses...)
+
+			// A 40x30 point selection on a Retina display
+			selector := NewMacOSSelectorWithExecutor(mockCmd).(*macOSSelector)
+			selector.config.SelectionRetries = 3
+			var sleeps []time.Duration
+			selector.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+			selector.scaleAt = func(x, y int) float64 { return 2 }
+
+			region, err := selector.readSelection(writeScreenshot(t, 80, 60))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readSelection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *region != tt.want {
+				t.Errorf("readSelection() = %+v, want %+v", *region, tt.want)
+			}
+			if got := mockCmd.GetCallCount("defaults"); got != tt.reads {
+				t.Errorf("read the selection %d times, want %d", got, tt.reads)
+			}
+
+			// Waits double from SelectionBackoff between reads
+			for i, d := range sleeps {
+				if want := DefaultConfig().SelectionBackoff << i; d != want {
+					t.Errorf("wait %d = %v, want %v", i, d, want)
+				}
+			}
+			if len(sleeps) != tt.reads-1 {
+				t.Errorf("waited %d times, want %d", len(sleeps), tt.reads-1)
+			}
+		})
+	}
+}
+
+func TestMacOSSelectorReadSelectionWithoutScreenshot(t *testing.T) {
+	mockCmd := NewMockSystemCommand()
+	mockCmd.SetOutput("defaults", []byte(`{ Height = 30; Width = 40; X = 100; Y = 200; }`))
+
+	selector := NewMacOSSelectorWithExecutor(mockCmd).(*macOSSelector)
+	selector.sleep = func(time.Duration) { t.Error("should not wait when there is no screenshot to compare") }
+
+	region, err := selector.readSelection(filepath.Join(t.TempDir(), "missing.png"))
+	if err != nil {
+		t.Fatalf("readSelection() failed: %v", err)
+	}
+	if want := (capture.Region{X: 100, Y: 200, Width: 40, Height: 30}); *region != want {
+		t.Errorf("readSelection() = %+v, want %+v", *region, want)
+	}
+}
+
+// fakeDragWatcher reports a drag chosen by the test
+type fakeDragWatcher struct {
+	region  capture.Region
+	seen    bool
+	stopped bool
+}
+
+func (w *fakeDragWatcher) stop() (capture.Region, bool) {
+	w.stopped = true
+	return w.region, w.seen
+}
+
+func TestMacOSSelectorSelectionMethods(t *testing.T) {
+	dragged := capture.Region{X: 10, Y: 20, Width: 40, Height: 30}
+	saved := capture.Region{X: 100, Y: 200, Width: 40, Height: 30}
+	missing := fmt.Errorf("the domain/default pair does not exist")
+
+	tests := []struct {
+		name     string
+		method   SelectionMethod
+		watchErr error
+		drag     *fakeDragWatcher
+		prefsErr error
+		want     capture.Region
+		readPref bool
+		wantErr  bool
+	}{
+		{"auto uses the drag", SelectionAuto, nil, &fakeDragWatcher{region: dragged, seen: true}, nil, dragged, false, false},
+		{"zero value is auto", "", nil, &fakeDragWatcher{region: dragged, seen: true}, nil, dragged, false, false},
+		{"auto without a drag", SelectionAuto, nil, &fakeDragWatcher{}, nil, saved, true, false},
+		{"auto with a mismatched drag", SelectionAuto, nil, &fakeDragWatcher{region: capture.Region{Width: 5, Height: 5}, seen: true}, nil, saved, true, false},
+		{"auto without permission", SelectionAuto, capture.ErrPermissionDenied, nil, nil, saved, true, false},
+		{"tap with preferences disabled", SelectionEventTap, nil, &fakeDragWatcher{region: dragged, seen: true}, missing, dragged, false, false},
+		{"tap without a drag", SelectionEventTap, nil, &fakeDragWatcher{}, nil, capture.Region{}, false, true},
+		{"tap without permission", SelectionEventTap, capture.ErrPermissionDenied, nil, nil, capture.Region{}, false, true},
+		{"prefs ignores the tap", SelectionPreferences, nil, nil, nil, saved, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCmd := NewMockSystemCommand()
+			mockCmd.SetResponses("screencapture", nil, Response{
+				Do: func(name string, args ...string) error {
+					// A 40x30 point selection on a Retina display
+					f, err := os.Create(args[len(args)-1])
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					return png.Encode(f, image.NewRGBA(image.Rect(0, 0, 80, 60)))
+				},
+			})
+			if tt.prefsErr != nil {
+				mockCmd.SetError("defaults", tt.prefsErr)
+			} else {
+				mockCmd.SetOutput("defaults", []byte(`{ Height = 30; Width = 40; X = 100; Y = 200; }`))
+			}
+
+			selector := NewMacOSSelectorWithExecutor(mockCmd).(*macOSSelector)
+			selector.config.Method = tt.method
+			selector.sleep = func(time.Duration) {}
+			selector.scaleAt = func(x, y int) float64 { return 2 }
+			watched := false
+			selector.watchDrag = func() (dragWatcher, error) {
+				watched = true
+				if tt.watchErr != nil {
+					return nil, tt.watchErr
+				}
+				return tt.drag, nil
+			}
+
+			region, err := selector.Select()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *region != tt.want {
+				t.Errorf("Select() = %+v, want %+v", *region, tt.want)
+			}
+			if got := mockCmd.GetCallCount("defaults") > 0; got != tt.readPref {
+				t.Errorf("read the preferences = %v, want %v", got, tt.readPref)
+			}
+			if watched != (tt.method != SelectionPreferences) {
+				t.Errorf("watched the drag = %v with method %q", watched, tt.method)
+			}
+			if tt.drag != nil && !tt.drag.stopped {
+				t.Error("the drag watcher should be stopped")
+			}
+		})
+	}
+}
+
+func TestDragRegion(t *testing.T) {
+	at := func(x, y int) capture.Click { return capture.Click{X: x, Y: y} }
+	tests := []struct {
+		name           string
+		press, release capture.Click
+		want           capture.Region
+		ok             bool
+	}{
+		{"down and right", at(10, 20), at(50, 50), capture.Region{X: 10, Y: 20, Width: 40, Height: 30}, true},
+		{"up and left", at(50, 50), at(10, 20), capture.Region{X: 10, Y: 20, Width: 40, Height: 30}, true},
+		{"click", at(10, 20), at(10, 20), capture.Region{}, false},
+		{"line", at(10, 20), at(10, 80), capture.Region{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := dragRegion(tt.press, tt.release)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("dragRegion() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
 func TestMacOSSelectorSelectWithName(t *testing.T) {
 	tmpDir, cleanup := setupTestConfig(t)
 	defer cleanup()
@@ -125,14 +373,13 @@ func TestMacOSSelectorSelectWithName(t *testing.T) {
 
 	// Mock the screencapture command (should succeed)
 	// We need to create the temp file to simulate successful capture
-	mockCmd.RunInteractive = func(name string, args ...string) error {
-		if name == "screencapture" {
+	mockCmd.SetResponses("screencapture", nil, Response{
+		Do: func(name string, args ...string) error {
 			// Create the temporary file to simulate successful selection
 			tmpFile := args[len(args)-1] // Last argument is the file path
 			return os.WriteFile(tmpFile, []byte("fake image data"), 0644)
-		}
-		return nil
-	}
+		},
+	})
 
 	// Mock the defaults read command
 	mockOutput := `{
@@ -187,13 +434,13 @@ func TestMacOSSelectorSelectCanceled(t *testing.T) {
 
 func TestMacOSSelectorParseDifferentFormats(t *testing.T) {
 	tests := []struct {
-		name     string
-		output   string
-		wantX    int
-		wantY    int
-		wantW    int
-		wantH    int
-		wantErr  bool
+		name    string
+		output  string
+		wantX   int
+		wantY   int
+		wantW   int
+		wantH   int
+		wantErr bool
 	}{
 		{
 			name: "standard format",
@@ -203,19 +450,19 @@ func TestMacOSSelectorParseDifferentFormats(t *testing.T) {
     X = 50;
     Y = 100;
 }`,
-			wantX: 50,
-			wantY: 100,
-			wantW: 640,
-			wantH: 480,
+			wantX:   50,
+			wantY:   100,
+			wantW:   640,
+			wantH:   480,
 			wantErr: false,
 		},
 		{
-			name: "compact format",
-			output: `{Height = 480; Width = 640; X = 50; Y = 100;}`,
-			wantX: 50,
-			wantY: 100,
-			wantW: 640,
-			wantH: 480,
+			name:    "compact format",
+			output:  `{Height = 480; Width = 640; X = 50; Y = 100;}`,
+			wantX:   50,
+			wantY:   100,
+			wantW:   640,
+			wantH:   480,
 			wantErr: false,
 		},
 		{
@@ -226,10 +473,11 @@ func TestMacOSSelectorParseDifferentFormats(t *testing.T) {
     X = 50.2;
     Y = 100.9;
 }`,
-			wantX: 50,
-			wantY: 100,
-			wantW: 640,
-			wantH: 480,
+			// Rounded outwards so the selection is fully covered
+			wantX:   50,
+			wantY:   100,
+			wantW:   641, // ceil(50.2+640.7) - 50
+			wantH:   482, // ceil(100.9+480.5) - 100
 			wantErr: false,
 		},
 		{
@@ -240,10 +488,10 @@ func TestMacOSSelectorParseDifferentFormats(t *testing.T) {
     X = 0;
     Y = 0;
 }`,
-			wantX: 0,
-			wantY: 0,
-			wantW: 3840,
-			wantH: 2160,
+			wantX:   0,
+			wantY:   0,
+			wantW:   3840,
+			wantH:   2160,
 			wantErr: false,
 		},
 	}
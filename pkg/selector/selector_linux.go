@@ -0,0 +1,98 @@
+//go:build linux
+// +build linux
+
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// regionFormat asks slop and slurp to print the selection as "x,y,w,h"
+const regionFormat = "%x,%y,%w,%h"
+
+// linuxSelector uses slurp on Wayland or slop on X11 for region selection
+type linuxSelector struct {
+	config         Config
+	tool           string
+	sysCmdExecutor SystemCommand
+}
+
+// newPlatformSelector creates a Linux selector for the current session
+func newPlatformSelector(config Config) (Selector, error) {
+	tool := selectionTool()
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil, fmt.Errorf("%s is required for interactive region selection (install it with your package manager)", tool)
+	}
+
+	s := NewLinuxSelectorWithExecutor(&RealSystemCommand{Timeout: config.Timeout}).(*linuxSelector)
+	s.config = config
+	return s, nil
+}
+
+// NewLinuxSelectorWithExecutor creates a Linux selector with a custom command executor
+// This is primarily used for testing with mock commands
+func NewLinuxSelectorWithExecutor(executor SystemCommand) Selector {
+	return &linuxSelector{
+		config:         DefaultConfig(),
+		tool:           selectionTool(),
+		sysCmdExecutor: executor,
+	}
+}
+
+// selectionTool returns slurp in Wayland sessions and slop otherwise
+func selectionTool() string {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return "slurp"
+	}
+	return "slop"
+}
+
+// Select launches an interactive region selector
+func (s *linuxSelector) Select() (*capture.Region, error) {
+	fmt.Println("📐 Select a screen region...")
+	fmt.Println("   - Click and drag to select the capture area")
+	fmt.Println("   - Press ESC to cancel")
+	fmt.Println()
+
+	// Both tools print the selection to stdout and exit non-zero when the
+	// user cancels
+	output, err := s.sysCmdExecutor.Run(s.tool, "-f", regionFormat)
+	if errors.Is(err, ErrTimeout) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, ErrCanceled
+	}
+
+	region, err := ParseRegionString(strings.TrimSpace(string(output)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read selection coordinates: %w", err)
+	}
+
+	fmt.Printf("✓ Selected region: %dx%d at (%d,%d)\n",
+		region.Width, region.Height, region.X, region.Y)
+
+	return region, nil
+}
+
+// SelectWithName selects a region and saves it with a name
+func (s *linuxSelector) SelectWithName(name string) (*capture.Region, error) {
+	region, err := s.Select()
+	if err != nil {
+		return nil, err
+	}
+
+	// Save the region with the name
+	if err := SaveRegion(name, region); err != nil {
+		return nil, fmt.Errorf("failed to save region: %w", err)
+	}
+
+	fmt.Printf("✓ Saved region '%s'\n", name)
+	return region, nil
+}
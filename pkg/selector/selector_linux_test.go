@@ -0,0 +1,124 @@
+//go:build linux
+// +build linux
+
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinuxSelectorTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		wayland string
+		want    string
+	}{
+		{name: "wayland", wayland: "wayland-0", want: "slurp"},
+		{name: "x11", wayland: "", want: "slop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WAYLAND_DISPLAY", tt.wayland)
+
+			mockCmd := NewMockSystemCommand()
+			mockCmd.SetOutput(tt.want, []byte("100,200,800,600\n"))
+
+			selector := NewLinuxSelectorWithExecutor(mockCmd)
+			region, err := selector.Select()
+			if err != nil {
+				t.Fatalf("Select() failed: %v", err)
+			}
+
+			if region.X != 100 || region.Y != 200 || region.Width != 800 || region.Height != 600 {
+				t.Errorf("Select() = %+v, want 800x600 at (100,200)", region)
+			}
+			if !mockCmd.WasCalled(tt.want, "-f", "%x,%y,%w,%h") {
+				t.Errorf("%s was not called with the expected arguments: %+v", tt.want, mockCmd.CallLog)
+			}
+		})
+	}
+}
+
+func TestLinuxSelectorSelectCanceled(t *testing.T) {
+	t.Setenv("WAYLAND_DISPLAY", "")
+	mockCmd := NewMockSystemCommand()
+	mockCmd.SetError("slop", fmt.Errorf("exit status 1"))
+
+	selector := NewLinuxSelectorWithExecutor(mockCmd)
+
+	_, err := selector.Select()
+	if err == nil {
+		t.Error("Select() should fail when user cancels")
+	}
+}
+
+func TestLinuxSelectorSelectTimeout(t *testing.T) {
+	t.Setenv("WAYLAND_DISPLAY", "")
+	mockCmd := NewMockSystemCommand()
+	mockCmd.SetError("slop", fmt.Errorf("slop: %w", ErrTimeout))
+
+	selector := NewLinuxSelectorWithExecutor(mockCmd)
+
+	if _, err := selector.Select(); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Select() error = %v, want %v", err, ErrTimeout)
+	}
+}
+
+func TestLinuxSelectorInvalidOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{name: "empty", output: ""},
+		{name: "malformed", output: "not a region"},
+		{name: "zero size", output: "10,10,0,0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+			mockCmd := NewMockSystemCommand()
+			mockCmd.SetOutput("slurp", []byte(tt.output))
+
+			selector := NewLinuxSelectorWithExecutor(mockCmd)
+			if _, err := selector.Select(); err == nil {
+				t.Errorf("Select() should fail for output %q", tt.output)
+			}
+		})
+	}
+}
+
+func TestLinuxSelectorSelectWithName(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+	mockCmd := NewMockSystemCommand()
+	mockCmd.SetOutput("slurp", []byte("-1920,0,1280,720\n"))
+
+	selector := NewLinuxSelectorWithExecutor(mockCmd)
+
+	region, err := selector.SelectWithName("test-region")
+	if err != nil {
+		t.Fatalf("SelectWithName() failed: %v", err)
+	}
+
+	// Verify the region was saved
+	configPath := filepath.Join(tmpDir, ".config", "witness", "regions.json")
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		t.Error("Config file was not created")
+	}
+
+	loaded, err := LoadRegion("test-region")
+	if err != nil {
+		t.Fatalf("Failed to load saved region: %v", err)
+	}
+	if *loaded != *region {
+		t.Errorf("Loaded region %+v doesn't match selected region %+v", loaded, region)
+	}
+}
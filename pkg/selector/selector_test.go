@@ -1,6 +1,9 @@
 package selector
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/ericmhalvorsen/witness/pkg/capture"
@@ -184,4 +187,200 @@ func TestDefaultConfig(t *testing.T) {
 	if !config.ShowDimensions {
 		t.Error("DefaultConfig() ShowDimensions should be true by default")
 	}
+	if config.Method != SelectionAuto {
+		t.Errorf("DefaultConfig() Method = %q, want %q", config.Method, SelectionAuto)
+	}
+}
+
+func TestParseSelectionMethod(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    SelectionMethod
+		wantErr bool
+	}{
+		{"", SelectionAuto, false},
+		{"auto", SelectionAuto, false},
+		{"TAP", SelectionEventTap, false},
+		{" prefs ", SelectionPreferences, false},
+		{"overlay", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSelectionMethod(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSelectionMethod(%q) = %q, %v, want %q (error %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSelectedRegionMapsToCapturePixels(t *testing.T) {
+	// A region selected on a Retina secondary display above the primary
+	// must map to the same physical pixels the capturer records
+	display := capture.Display{
+		ID:          2,
+		Bounds:      capture.Region{X: 0, Y: -1117, Width: 1728, Height: 1117},
+		ScaleFactor: 2,
+	}
+
+	region, err := ParseRegionString("100,-1000,640,480")
+	if err != nil {
+		t.Fatalf("ParseRegionString() failed: %v", err)
+	}
+
+	local, err := display.GlobalToLocal(*region)
+	if err != nil {
+		t.Fatalf("GlobalToLocal() failed: %v", err)
+	}
+
+	want := capture.Region{X: 200, Y: 234, Width: 1280, Height: 960}
+	if local != want {
+		t.Errorf("GlobalToLocal() = %+v, want %+v", local, want)
+	}
+
+	// Saving and reloading must not drift
+	global := display.LocalToGlobal(local)
+	if got := FormatRegionString(&global); got != "100,-1000,640,480" {
+		t.Errorf("round trip = %q, want %q", got, "100,-1000,640,480")
+	}
+}
+
+func TestNonInteractive(t *testing.T) {
+	saved := &capture.Region{X: 1, Y: 2, Width: 30, Height: 40}
+
+	tests := []struct {
+		name       string
+		region     string
+		env        string
+		hasDefault bool
+		want       *capture.Region
+		wantErr    bool
+	}{
+		{name: "region wins", region: "10,20,300,400", env: "5,5,50,50", hasDefault: true, want: &capture.Region{X: 10, Y: 20, Width: 300, Height: 400}},
+		{name: "environment", env: "5,5,50,50", hasDefault: true, want: &capture.Region{X: 5, Y: 5, Width: 50, Height: 50}},
+		{name: "default region", hasDefault: true, want: saved},
+		{name: "nothing set", wantErr: true},
+		{name: "invalid region", region: "10,20", wantErr: true},
+		{name: "invalid environment", env: "abc", hasDefault: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cleanup := setupTestConfig(t)
+			defer cleanup()
+
+			t.Setenv(EnvRegion, tt.env)
+			if err := SaveRegion("saved", saved); err != nil {
+				t.Fatalf("SaveRegion() failed: %v", err)
+			}
+			if tt.hasDefault {
+				if err := SetDefaultRegion("saved"); err != nil {
+					t.Fatalf("SetDefaultRegion() failed: %v", err)
+				}
+			}
+
+			got, err := NonInteractive(tt.region)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NonInteractive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && *got != *tt.want {
+				t.Errorf("NonInteractive() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// scriptedSelector returns queued selections in order
+type scriptedSelector struct {
+	results []scriptedResult
+	calls   int
+}
+
+type scriptedResult struct {
+	region *capture.Region
+	err    error
+}
+
+func (s *scriptedSelector) Select() (*capture.Region, error) {
+	r := s.results[s.calls]
+	s.calls++
+	return r.region, r.err
+}
+
+func (s *scriptedSelector) SelectWithName(name string) (*capture.Region, error) {
+	return s.Select()
+}
+
+func TestSelectMultiple(t *testing.T) {
+	a := &capture.Region{X: 0, Y: 0, Width: 100, Height: 100}
+	b := &capture.Region{X: 100, Y: 0, Width: 200, Height: 100}
+	c := &capture.Region{X: 0, Y: 100, Width: 300, Height: 50}
+	failed := errors.New("overlay failed")
+
+	tests := []struct {
+		name      string
+		count     int
+		results   []scriptedResult
+		wantNames []string
+		wantErr   error
+	}{
+		{
+			name:      "fixed count",
+			count:     2,
+			results:   []scriptedResult{{region: a}, {region: b}, {region: c}},
+			wantNames: []string{"r-1", "r-2"},
+		},
+		{
+			name:      "until canceled",
+			count:     0,
+			results:   []scriptedResult{{region: a}, {region: b}, {region: c}, {err: ErrCanceled}},
+			wantNames: []string{"r-1", "r-2", "r-3"},
+		},
+		{
+			name:      "canceled early",
+			count:     3,
+			results:   []scriptedResult{{region: a}, {err: fmt.Errorf("no region selected: %w", ErrCanceled)}},
+			wantNames: []string{"r-1"},
+		},
+		{
+			name:    "canceled first",
+			count:   0,
+			results: []scriptedResult{{err: ErrCanceled}},
+			wantErr: ErrCanceled,
+		},
+		{
+			name:      "failure keeps saved regions",
+			count:     3,
+			results:   []scriptedResult{{region: a}, {err: failed}},
+			wantNames: []string{"r-1"},
+			wantErr:   failed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cleanup := setupTestConfig(t)
+			defer cleanup()
+
+			sel := &scriptedSelector{results: tt.results}
+			names, err := SelectMultiple(sel, tt.count, func(n int, region *capture.Region) (string, error) {
+				return fmt.Sprintf("r-%d", n), nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SelectMultiple() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("SelectMultiple() = %q, want %q", names, tt.wantNames)
+			}
+
+			for i, name := range tt.wantNames {
+				loaded, err := LoadRegion(name)
+				if err != nil {
+					t.Fatalf("LoadRegion(%q) failed: %v", name, err)
+				}
+				if *loaded != *tt.results[i].region {
+					t.Errorf("region %q = %+v, want %+v", name, loaded, tt.results[i].region)
+				}
+			}
+		})
+	}
 }
@@ -1,10 +1,11 @@
-// +build !darwin
+//go:build !darwin && !linux && !windows
+// +build !darwin,!linux,!windows
 
 package selector
 
 import "fmt"
 
 // newPlatformSelector returns an error on unsupported platforms
-func newPlatformSelector() (Selector, error) {
-	return nil, fmt.Errorf("interactive region selection is not supported on this platform (only macOS is currently supported)")
+func newPlatformSelector(config Config) (Selector, error) {
+	return nil, fmt.Errorf("interactive region selection is not supported on this platform (only macOS, Linux, and Windows are currently supported)")
 }
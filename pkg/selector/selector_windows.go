@@ -0,0 +1,130 @@
+//go:build windows
+// +build windows
+
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/internal/windows"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// windowsSelector uses a native click-drag overlay for region selection
+type windowsSelector struct {
+	config       Config
+	selectRegion func() (image.Rectangle, error)
+	adjustRegion func(initial image.Rectangle) (image.Rectangle, error)
+}
+
+// newPlatformSelector creates a Windows selector
+func newPlatformSelector(config Config) (Selector, error) {
+	s := NewWindowsSelectorWithOverlay(windows.SelectRegion).(*windowsSelector)
+	s.config = config
+	s.adjustRegion = windows.AdjustRegion
+	return s, nil
+}
+
+// NewWindowsSelectorWithOverlay creates a Windows selector that calls
+// selectRegion in place of the native overlay
+// This is primarily used for testing without a display
+func NewWindowsSelectorWithOverlay(selectRegion func() (image.Rectangle, error)) Selector {
+	return &windowsSelector{
+		config:       DefaultConfig(),
+		selectRegion: selectRegion,
+	}
+}
+
+// Select launches an interactive region selector
+func (s *windowsSelector) Select() (*capture.Region, error) {
+	fmt.Println("📐 Select a screen region...")
+	fmt.Println("   - Click and drag to select the capture area")
+	fmt.Println("   - Arrow keys move it a pixel, Shift+arrows resize it, and Ctrl makes it 10")
+	fmt.Println("   - Press Enter to accept, or ESC or right-click to cancel")
+	fmt.Println()
+
+	return s.overlay(s.selectRegion)
+}
+
+// Adjust shows region selected on the overlay so its edges can be dragged
+// or nudged with the arrow keys, and returns it once Enter is pressed
+func (s *windowsSelector) Adjust(region *capture.Region) (*capture.Region, error) {
+	if s.adjustRegion == nil {
+		return nil, fmt.Errorf("adjusting a region: %w", capture.ErrUnsupportedPlatform)
+	}
+
+	fmt.Println("📐 Adjust the screen region...")
+	fmt.Println("   - Drag an edge or corner to move it, or drag inside to move the region")
+	fmt.Println("   - Arrow keys move it a pixel, Shift+arrows resize it, and Ctrl makes it 10")
+	fmt.Println("   - Press Enter to accept, or ESC to cancel")
+	fmt.Println()
+
+	initial := image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height)
+	return s.overlay(func() (image.Rectangle, error) {
+		return s.adjustRegion(initial)
+	})
+}
+
+// overlay runs show and converts its result to a region, giving up with
+// ErrTimeout once the configured timeout passes. The overlay closes when
+// the process exits.
+func (s *windowsSelector) overlay(show func() (image.Rectangle, error)) (*capture.Region, error) {
+	r, err := s.wait(show)
+	if errors.Is(err, windows.ErrCancelled) {
+		return nil, ErrCanceled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	region := &capture.Region{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()}
+
+	fmt.Printf("✓ Selected region: %dx%d at (%d,%d)\n",
+		region.Width, region.Height, region.X, region.Y)
+
+	return region, nil
+}
+
+// wait runs show, giving up with ErrTimeout once the configured timeout
+// passes
+func (s *windowsSelector) wait(show func() (image.Rectangle, error)) (image.Rectangle, error) {
+	if s.config.Timeout <= 0 {
+		return show()
+	}
+
+	type result struct {
+		r   image.Rectangle
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		r, err := show()
+		done <- result{r, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.r, res.err
+	case <-time.After(s.config.Timeout):
+		return image.Rectangle{}, fmt.Errorf("%w after %v", ErrTimeout, s.config.Timeout)
+	}
+}
+
+// SelectWithName selects a region and saves it with a name
+func (s *windowsSelector) SelectWithName(name string) (*capture.Region, error) {
+	region, err := s.Select()
+	if err != nil {
+		return nil, err
+	}
+
+	// Save the region with the name
+	if err := SaveRegion(name, region); err != nil {
+		return nil, fmt.Errorf("failed to save region: %w", err)
+	}
+
+	fmt.Printf("✓ Saved region '%s'\n", name)
+	return region, nil
+}
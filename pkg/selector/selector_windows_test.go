@@ -0,0 +1,113 @@
+//go:build windows
+// +build windows
+
+package selector
+
+import (
+	"errors"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/internal/windows"
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+func TestWindowsSelectorSelect(t *testing.T) {
+	selector := NewWindowsSelectorWithOverlay(func() (image.Rectangle, error) {
+		return image.Rect(-1920, 100, -1120, 700), nil
+	})
+
+	region, err := selector.Select()
+	if err != nil {
+		t.Fatalf("Select() failed: %v", err)
+	}
+	if region.X != -1920 || region.Y != 100 || region.Width != 800 || region.Height != 600 {
+		t.Errorf("Select() = %+v, want 800x600 at (-1920,100)", region)
+	}
+}
+
+func TestWindowsSelectorErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "cancelled", err: windows.ErrCancelled},
+		{name: "overlay failure", err: errors.New("failed to create selection window")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector := NewWindowsSelectorWithOverlay(func() (image.Rectangle, error) {
+				return image.Rectangle{}, tt.err
+			})
+			if _, err := selector.Select(); err == nil {
+				t.Error("Select() should fail")
+			}
+		})
+	}
+}
+
+func TestWindowsSelectorTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	selector := NewWindowsSelectorWithOverlay(func() (image.Rectangle, error) {
+		<-release
+		return image.Rectangle{}, windows.ErrCancelled
+	}).(*windowsSelector)
+	selector.config.Timeout = 20 * time.Millisecond
+
+	if _, err := selector.Select(); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Select() error = %v, want %v", err, ErrTimeout)
+	}
+}
+
+func TestWindowsSelectorSelectWithName(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	selector := NewWindowsSelectorWithOverlay(func() (image.Rectangle, error) {
+		return image.Rect(10, 20, 330, 260), nil
+	})
+
+	region, err := selector.SelectWithName("test-region")
+	if err != nil {
+		t.Fatalf("SelectWithName() failed: %v", err)
+	}
+
+	loaded, err := LoadRegion("test-region")
+	if err != nil {
+		t.Fatalf("Failed to load saved region: %v", err)
+	}
+	if *loaded != *region {
+		t.Errorf("Loaded region %+v doesn't match selected region %+v", loaded, region)
+	}
+}
+
+func TestWindowsSelectorAdjust(t *testing.T) {
+	var got image.Rectangle
+	selector := NewWindowsSelectorWithOverlay(nil).(*windowsSelector)
+	selector.adjustRegion = func(initial image.Rectangle) (image.Rectangle, error) {
+		got = initial
+		return initial.Inset(2), nil
+	}
+
+	region, err := selector.Adjust(&capture.Region{X: -1920, Y: 100, Width: 800, Height: 600})
+	if err != nil {
+		t.Fatalf("Adjust() failed: %v", err)
+	}
+	if want := image.Rect(-1920, 100, -1120, 700); got != want {
+		t.Errorf("overlay shown with %v, want %v", got, want)
+	}
+	if region.X != -1918 || region.Y != 102 || region.Width != 796 || region.Height != 596 {
+		t.Errorf("Adjust() = %+v, want 796x596 at (-1918,102)", region)
+	}
+
+	selector.adjustRegion = func(image.Rectangle) (image.Rectangle, error) {
+		return image.Rectangle{}, windows.ErrCancelled
+	}
+	if _, err := selector.Adjust(region); !errors.Is(err, ErrCanceled) {
+		t.Errorf("Adjust() error = %v, want %v", err, ErrCanceled)
+	}
+}
@@ -2,7 +2,12 @@ package selector
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os/exec"
+	"path"
+	"time"
 )
 
 // SystemCommand is an interface for executing system commands
@@ -16,7 +21,11 @@ type SystemCommand interface {
 }
 
 // RealSystemCommand implements SystemCommand using actual os/exec
-type RealSystemCommand struct{}
+type RealSystemCommand struct {
+	// Timeout, if positive, kills commands that run longer, which then
+	// fail with ErrTimeout
+	Timeout time.Duration
+}
 
 // NewRealSystemCommand creates a new real system command executor
 func NewRealSystemCommand() SystemCommand {
@@ -25,20 +34,47 @@ func NewRealSystemCommand() SystemCommand {
 
 // Run executes a command and returns the output
 func (r *RealSystemCommand) Run(name string, args ...string) ([]byte, error) {
-	cmd := exec.Command(name, args...)
+	ctx, cancel := r.context()
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	err := cmd.Run()
-	return out.Bytes(), err
+	return out.Bytes(), r.timedOut(ctx, name, err)
 }
 
 // RunInteractive executes a command without capturing output
 func (r *RealSystemCommand) RunInteractive(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	return cmd.Run()
+	ctx, cancel := r.context()
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	return r.timedOut(ctx, name, cmd.Run())
+}
+
+// context returns a context that expires after the timeout, if one is set
+func (r *RealSystemCommand) context() (context.Context, context.CancelFunc) {
+	if r.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), r.Timeout)
 }
 
-// MockSystemCommand is a mock implementation for testing
+// timedOut replaces the error of a command killed by the timeout with
+// ErrTimeout
+func (r *RealSystemCommand) timedOut(ctx context.Context, name string, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s: %w after %v", name, ErrTimeout, r.Timeout)
+	}
+	return err
+}
+
+// MockSystemCommand is a mock implementation for testing.
+//
+// Responses set with SetResponses take precedence, so a command can answer
+// differently depending on its arguments or on how often it has been
+// called. Otherwise the command's entry in Errors, then Output, is used.
 type MockSystemCommand struct {
 	// Output maps command names to their mock output
 	Output map[string][]byte
@@ -48,6 +84,68 @@ type MockSystemCommand struct {
 
 	// CallLog records all commands that were executed
 	CallLog []CommandCall
+
+	scripts []*mockScript
+}
+
+// Response is one canned result of a mocked command
+type Response struct {
+	// Output is returned by Run
+	Output []byte
+
+	// Err is returned by Run and RunInteractive
+	Err error
+
+	// Do, if set, runs when the response is used, for side effects such
+	// as creating the file the real command would write. An error from Do
+	// replaces Err.
+	Do func(name string, args ...string) error
+}
+
+// mockScript holds the responses for calls matching one pattern
+type mockScript struct {
+	name      string
+	pattern   []string
+	responses []Response
+	next      int
+}
+
+// matches reports whether a call matches the script's name and pattern
+func (s *mockScript) matches(name string, args []string) bool {
+	if name != s.name {
+		return false
+	}
+	if s.pattern == nil {
+		return true
+	}
+
+	for i, p := range s.pattern {
+		if p == "..." && i == len(s.pattern)-1 {
+			return true
+		}
+		if i >= len(args) {
+			return false
+		}
+		if ok, err := path.Match(p, args[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return len(args) == len(s.pattern)
+}
+
+// respond returns the next response, repeating the last once all have
+// been used
+func (s *mockScript) respond(name string, args []string) ([]byte, error) {
+	r := s.responses[min(s.next, len(s.responses)-1)]
+	s.next++
+
+	err := r.Err
+	if r.Do != nil {
+		if doErr := r.Do(name, args...); doErr != nil {
+			err = doErr
+		}
+	}
+	return r.Output, err
 }
 
 // CommandCall records a command execution
@@ -69,6 +167,10 @@ func NewMockSystemCommand() *MockSystemCommand {
 func (m *MockSystemCommand) Run(name string, args ...string) ([]byte, error) {
 	m.CallLog = append(m.CallLog, CommandCall{Name: name, Args: args})
 
+	if s := m.script(name, args); s != nil {
+		return s.respond(name, args)
+	}
+
 	if err, ok := m.Errors[name]; ok {
 		return nil, err
 	}
@@ -84,6 +186,11 @@ func (m *MockSystemCommand) Run(name string, args ...string) ([]byte, error) {
 func (m *MockSystemCommand) RunInteractive(name string, args ...string) error {
 	m.CallLog = append(m.CallLog, CommandCall{Name: name, Args: args})
 
+	if s := m.script(name, args); s != nil {
+		_, err := s.respond(name, args)
+		return err
+	}
+
 	if err, ok := m.Errors[name]; ok {
 		return err
 	}
@@ -101,6 +208,31 @@ func (m *MockSystemCommand) SetError(name string, err error) {
 	m.Errors[name] = err
 }
 
+// SetResponses configures the results of calls to name whose arguments
+// match pattern, one response per call in order; the last response repeats
+// once the others are used up. Each pattern element is matched against the
+// argument in the same position with path.Match, so "*" matches any single
+// argument, and a final "..." matches any remaining arguments. A nil
+// pattern matches any arguments.
+//
+// When several patterns match a call, the one set first is used.
+func (m *MockSystemCommand) SetResponses(name string, pattern []string, responses ...Response) {
+	if len(responses) == 0 {
+		responses = []Response{{}}
+	}
+	m.scripts = append(m.scripts, &mockScript{name: name, pattern: pattern, responses: responses})
+}
+
+// script returns the first script matching a call, or nil
+func (m *MockSystemCommand) script(name string, args []string) *mockScript {
+	for _, s := range m.scripts {
+		if s.matches(name, args) {
+			return s
+		}
+	}
+	return nil
+}
+
 // GetCallCount returns the number of times a command was called
 func (m *MockSystemCommand) GetCallCount(name string) int {
 	count := 0
@@ -135,9 +267,10 @@ func (m *MockSystemCommand) WasCalled(name string, args ...string) bool {
 	return false
 }
 
-// Reset clears the call log and configured outputs/errors
+// Reset clears the call log and configured outputs, errors, and responses
 func (m *MockSystemCommand) Reset() {
 	m.Output = make(map[string][]byte)
 	m.Errors = make(map[string]error)
 	m.CallLog = make([]CommandCall, 0)
+	m.scripts = nil
 }
@@ -0,0 +1,135 @@
+package selector
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestMockSystemCommandPatterns(t *testing.T) {
+	mockCmd := NewMockSystemCommand()
+	mockCmd.SetResponses("defaults", []string{"read", "com.apple.screencapture", "last-selection"},
+		Response{Output: []byte("selection")})
+	mockCmd.SetResponses("defaults", []string{"read", "*", "location"}, Response{Output: []byte("location")})
+	mockCmd.SetResponses("defaults", []string{"write", "..."}, Response{Err: errors.New("read-only")})
+	mockCmd.SetOutput("defaults", []byte("fallback"))
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{"exact", []string{"read", "com.apple.screencapture", "last-selection"}, "selection", false},
+		{"wildcard", []string{"read", "com.apple.other", "location"}, "location", false},
+		{"remaining arguments", []string{"write", "com.apple.screencapture", "type", "png"}, "", true},
+		{"nothing after prefix", []string{"write"}, "", true},
+		{"too many arguments", []string{"read", "com.apple.screencapture", "last-selection", "extra"}, "fallback", false},
+		{"too few arguments", []string{"read", "com.apple.screencapture"}, "fallback", false},
+		{"no match", []string{"delete"}, "fallback", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mockCmd.Run("defaults", tt.args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Run() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockSystemCommandSequence(t *testing.T) {
+	mockCmd := NewMockSystemCommand()
+	mockCmd.SetResponses("screencapture", nil,
+		Response{Err: errors.New("busy")},
+		Response{Output: []byte("ok")},
+	)
+
+	if _, err := mockCmd.Run("screencapture", "-i"); err == nil {
+		t.Error("first call succeeded, want error")
+	}
+	for i := 0; i < 2; i++ {
+		got, err := mockCmd.Run("screencapture", "-i")
+		if err != nil || string(got) != "ok" {
+			t.Errorf("call %d = %q, %v, want %q", i+2, got, err, "ok")
+		}
+	}
+
+	if got := mockCmd.GetCallCount("screencapture"); got != 3 {
+		t.Errorf("GetCallCount() = %d, want 3", got)
+	}
+}
+
+func TestMockSystemCommandDo(t *testing.T) {
+	var gotArgs []string
+	mockCmd := NewMockSystemCommand()
+	mockCmd.SetResponses("screencapture", nil,
+		Response{Do: func(name string, args ...string) error {
+			gotArgs = args
+			return nil
+		}},
+		Response{Err: errors.New("canceled"), Do: func(name string, args ...string) error {
+			return errors.New("disk full")
+		}},
+	)
+
+	if err := mockCmd.RunInteractive("screencapture", "-i", "/tmp/out.png"); err != nil {
+		t.Fatalf("RunInteractive() failed: %v", err)
+	}
+	if len(gotArgs) != 2 || gotArgs[1] != "/tmp/out.png" {
+		t.Errorf("Do() got args %q", gotArgs)
+	}
+
+	// An error from Do replaces the response's own error
+	if err := mockCmd.RunInteractive("screencapture", "-i"); err == nil || err.Error() != "disk full" {
+		t.Errorf("RunInteractive() error = %v, want disk full", err)
+	}
+}
+
+func TestMockSystemCommandReset(t *testing.T) {
+	mockCmd := NewMockSystemCommand()
+	mockCmd.SetResponses("defaults", nil, Response{Err: errors.New("failed")})
+	mockCmd.Run("defaults", "read")
+
+	mockCmd.Reset()
+	mockCmd.SetOutput("defaults", []byte("ok"))
+
+	got, err := mockCmd.Run("defaults", "read")
+	if err != nil || string(got) != "ok" {
+		t.Errorf("Run() after Reset() = %q, %v, want %q", got, err, "ok")
+	}
+	if len(mockCmd.CallLog) != 1 {
+		t.Errorf("CallLog has %d calls after Reset(), want 1", len(mockCmd.CallLog))
+	}
+}
+
+func TestRealSystemCommandTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is not available")
+	}
+
+	cmd := &RealSystemCommand{Timeout: 50 * time.Millisecond}
+
+	start := time.Now()
+	err := cmd.RunInteractive("sleep", "5")
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("RunInteractive() error = %v, want %v", err, ErrTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("RunInteractive() took %v, want the command killed at the timeout", elapsed)
+	}
+
+	if _, err := cmd.Run("sleep", "0"); err != nil {
+		t.Errorf("Run() of a quick command failed: %v", err)
+	}
+
+	// A failure before the timeout keeps its own error
+	if _, err := cmd.Run("sleep", "invalid"); err == nil || errors.Is(err, ErrTimeout) {
+		t.Errorf("Run() error = %v, want the command's own failure", err)
+	}
+}
@@ -0,0 +1,122 @@
+package source
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoding for sequences
+	_ "image/jpeg" // register JPEG decoding for sequences
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// sequenceExtensions are the image types picked up from a directory
+var sequenceExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+}
+
+// SequenceReader reads frames from a list of image files, one frame per
+// file. Every image must have the same size as the first.
+type SequenceReader struct {
+	paths []string
+	size  image.Point
+	clock clock
+}
+
+// NewSequenceReader creates a reader for the image files at paths, in order
+func NewSequenceReader(paths []string, fps capture.FPS) *SequenceReader {
+	return &SequenceReader{paths: paths, clock: newClock(fps)}
+}
+
+// ReadFrame decodes the next image in the sequence
+func (r *SequenceReader) ReadFrame() (*capture.Frame, error) {
+	if r.clock.n >= len(r.paths) {
+		return nil, io.EOF
+	}
+	path := r.paths[r.clock.n]
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open frame: %w", err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	size := img.Bounds().Size()
+	if r.clock.n == 0 {
+		r.size = size
+	} else if size != r.size {
+		return nil, fmt.Errorf("%s is %dx%d, but the sequence is %dx%d", path, size.X, size.Y, r.size.X, r.size.Y)
+	}
+
+	return &capture.Frame{Image: capture.ToRGBA(img), Timestamp: r.clock.next()}, nil
+}
+
+// ExpandSequence turns command-line arguments into an ordered list of image
+// files. Each argument may be a file, a directory (whose images are used),
+// or a glob pattern the shell did not expand. Files from a directory or
+// pattern are sorted so frame2.png comes before frame10.png.
+func ExpandSequence(args []string) ([]string, error) {
+	var paths []string
+
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil {
+			if !info.IsDir() {
+				paths = append(paths, arg)
+				continue
+			}
+			files, err := imagesInDir(arg)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, files...)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", arg)
+		}
+		capture.SortNatural(matches)
+		paths = append(paths, matches...)
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no image files found")
+	}
+	return paths, nil
+}
+
+// imagesInDir lists the image files in dir in natural order
+func imagesInDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && sequenceExtensions[strings.ToLower(filepath.Ext(e.Name()))] {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no image files in %s", dir)
+	}
+
+	capture.SortNatural(files)
+	return files, nil
+}
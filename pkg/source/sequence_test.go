@@ -0,0 +1,111 @@
+package source
+
+import (
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// Helper function to write a solid PNG into dir
+func writePNG(t *testing.T, dir, name string, w, h int, c color.RGBA) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", name, err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, solid(w, h, c)); err != nil {
+		t.Fatalf("failed to encode %s: %v", name, err)
+	}
+	return path
+}
+
+func TestSequenceReader(t *testing.T) {
+	dir := t.TempDir()
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	paths := []string{
+		writePNG(t, dir, "a.png", 4, 3, red),
+		writePNG(t, dir, "b.png", 4, 3, blue),
+	}
+
+	sink := &collectingSink{}
+	n, err := Copy(sink, NewSequenceReader(paths, capture.IntFPS(12)))
+	if err != nil {
+		t.Fatalf("Copy() failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("Copy() = %d frames, want 2", n)
+	}
+	if got := sink.frames[1].Image.RGBAAt(0, 0); got != blue {
+		t.Errorf("second frame color = %v, want %v", got, blue)
+	}
+	if d := sink.frames[1].Timestamp.Sub(sink.frames[0].Timestamp); d != time.Second/12 {
+		t.Errorf("frame interval = %v, want %v", d, time.Second/12)
+	}
+}
+
+func TestSequenceReaderSizeMismatch(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{
+		writePNG(t, dir, "a.png", 4, 3, color.RGBA{A: 255}),
+		writePNG(t, dir, "b.png", 5, 3, color.RGBA{A: 255}),
+	}
+
+	_, err := Copy(&collectingSink{}, NewSequenceReader(paths, capture.FPS15))
+	if err == nil {
+		t.Error("expected error for images of different sizes")
+	}
+}
+
+func TestExpandSequence(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"frame10.png", "frame2.png", "frame1.png"} {
+		writePNG(t, dir, name, 1, 1, color.RGBA{A: 255})
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("skip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		filepath.Join(dir, "frame1.png"),
+		filepath.Join(dir, "frame2.png"),
+		filepath.Join(dir, "frame10.png"),
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"directory", []string{dir}, want},
+		{"pattern", []string{filepath.Join(dir, "frame*.png")}, want},
+		{"explicit order kept", []string{want[2], want[0]}, []string{want[2], want[0]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandSequence(tt.args)
+			if err != nil {
+				t.Fatalf("ExpandSequence() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandSequence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ExpandSequence([]string{filepath.Join(dir, "*.jpg")}); err == nil {
+		t.Error("expected error for a pattern with no matches")
+	}
+	if _, err := ExpandSequence([]string{t.TempDir()}); err == nil {
+		t.Error("expected error for a directory with no images")
+	}
+}
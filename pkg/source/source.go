@@ -0,0 +1,172 @@
+package source
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/recorder"
+)
+
+// Reader produces frames from a source other than screen capture, so
+// external capture tools can use Witness purely as an encoder
+type Reader interface {
+	// ReadFrame returns the next frame, or io.EOF when the source is exhausted
+	ReadFrame() (*capture.Frame, error)
+}
+
+// clock assigns frame timestamps at a fixed frame rate
+type clock struct {
+	start time.Time
+	fps   capture.FPS
+	n     int
+}
+
+// newClock creates a clock whose first frame is at the current time
+func newClock(fps capture.FPS) clock {
+	return clock{start: time.Now(), fps: fps}
+}
+
+// next returns the timestamp of the next frame
+func (c *clock) next() time.Time {
+	t := c.start.Add(c.fps.FrameTime(c.n))
+	c.n++
+	return t
+}
+
+// RawReader reads headerless RGBA frames of a fixed size, 4 bytes per
+// pixel with no padding between rows or frames
+type RawReader struct {
+	r      io.Reader
+	width  int
+	height int
+	clock  clock
+}
+
+// NewRawReader creates a reader for raw RGBA frames
+func NewRawReader(r io.Reader, width, height int, fps capture.FPS) *RawReader {
+	return &RawReader{r: r, width: width, height: height, clock: newClock(fps)}
+}
+
+// ReadFrame reads the next raw frame
+func (r *RawReader) ReadFrame() (*capture.Frame, error) {
+	img := image.NewRGBA(image.Rect(0, 0, r.width, r.height))
+
+	n, err := io.ReadFull(r.r, img.Pix)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("truncated frame %d: got %d of %d bytes", r.clock.n, n, len(img.Pix))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frame %d: %w", r.clock.n, err)
+	}
+
+	return &capture.Frame{Image: img, Timestamp: r.clock.next()}, nil
+}
+
+// PNGReader reads a stream of concatenated PNG images
+type PNGReader struct {
+	r     *bufio.Reader
+	clock clock
+}
+
+// NewPNGReader creates a reader for a PNG stream
+func NewPNGReader(r io.Reader, fps capture.FPS) *PNGReader {
+	return &PNGReader{r: bufio.NewReader(r), clock: newClock(fps)}
+}
+
+// ReadFrame decodes the next PNG image in the stream
+func (r *PNGReader) ReadFrame() (*capture.Frame, error) {
+	if _, err := r.r.Peek(1); err == io.EOF {
+		return nil, io.EOF
+	}
+
+	img, err := png.Decode(r.r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PNG frame %d: %w", r.clock.n, err)
+	}
+
+	return &capture.Frame{Image: capture.ToRGBA(img), Timestamp: r.clock.next()}, nil
+}
+
+// Copy reads every frame from src and adds it to dst. It returns the number
+// of frames copied.
+func Copy(dst recorder.FrameSink, src Reader) (int, error) {
+	n := 0
+	for {
+		frame, err := src.ReadFrame()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := dst.AddFrame(frame); err != nil {
+			return n, fmt.Errorf("failed to add frame %d: %w", n, err)
+		}
+		n++
+	}
+}
+
+// rebased shifts frame timestamps by a fixed offset
+type rebased struct {
+	src   Reader
+	start time.Time
+	first *time.Time
+}
+
+// Rebase returns a reader whose first frame is at start, keeping the time
+// between frames. Use it with capture.Epoch to make timestamps independent
+// of when the frames were read or recorded.
+func Rebase(src Reader, start time.Time) Reader {
+	return &rebased{src: src, start: start}
+}
+
+// ReadFrame reads the next frame from the source and shifts its timestamp
+func (r *rebased) ReadFrame() (*capture.Frame, error) {
+	frame, err := r.src.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	if r.first == nil {
+		first := frame.Timestamp
+		r.first = &first
+	}
+
+	// Adding to start drops the monotonic clock reading, which would
+	// otherwise still differ between runs
+	shifted := *frame
+	shifted.Timestamp = r.start.Add(frame.Timestamp.Sub(*r.first))
+	return &shifted, nil
+}
+
+// ParseSize parses a frame size in the form WIDTHxHEIGHT
+func ParseSize(s string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid size %q: want WIDTHxHEIGHT", s)
+	}
+
+	width, err = strconv.Atoi(w)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid width in size %q", s)
+	}
+	height, err = strconv.Atoi(h)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid height in size %q", s)
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("width and height must be positive, got %s", s)
+	}
+
+	return width, height, nil
+}
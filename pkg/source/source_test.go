@@ -0,0 +1,163 @@
+package source
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// collectingSink records every frame it receives
+type collectingSink struct {
+	frames []*capture.Frame
+}
+
+func (s *collectingSink) AddFrame(frame *capture.Frame) error {
+	s.frames = append(s.frames, frame)
+	return nil
+}
+
+// Helper function to create a solid image
+func solid(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestRawReader(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+
+	var buf bytes.Buffer
+	buf.Write(solid(4, 3, red).Pix)
+	buf.Write(solid(4, 3, blue).Pix)
+
+	sink := &collectingSink{}
+	n, err := Copy(sink, NewRawReader(&buf, 4, 3, capture.IntFPS(10)))
+	if err != nil {
+		t.Fatalf("Copy() failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("Copy() = %d frames, want 2", n)
+	}
+
+	if got := sink.frames[1].Image.RGBAAt(3, 2); got != blue {
+		t.Errorf("second frame color = %v, want %v", got, blue)
+	}
+	if d := sink.frames[1].Timestamp.Sub(sink.frames[0].Timestamp); d != 100*time.Millisecond {
+		t.Errorf("frame interval = %v, want 100ms", d)
+	}
+}
+
+func TestRawReaderTruncated(t *testing.T) {
+	r := NewRawReader(bytes.NewReader(make([]byte, 4*3*4+5)), 4, 3, capture.IntFPS(10))
+
+	if _, err := r.ReadFrame(); err != nil {
+		t.Fatalf("first ReadFrame() failed: %v", err)
+	}
+	if _, err := r.ReadFrame(); err == nil || err == io.EOF {
+		t.Errorf("ReadFrame() error = %v, want a truncation error", err)
+	}
+}
+
+func TestPNGReader(t *testing.T) {
+	colors := []color.RGBA{{R: 255, A: 255}, {G: 255, A: 255}, {B: 255, A: 255}}
+
+	var buf bytes.Buffer
+	for _, c := range colors {
+		if err := png.Encode(&buf, solid(5, 5, c)); err != nil {
+			t.Fatalf("png.Encode() failed: %v", err)
+		}
+	}
+
+	sink := &collectingSink{}
+	n, err := Copy(sink, NewPNGReader(&buf, capture.IntFPS(15)))
+	if err != nil {
+		t.Fatalf("Copy() failed: %v", err)
+	}
+	if n != len(colors) {
+		t.Fatalf("Copy() = %d frames, want %d", n, len(colors))
+	}
+	for i, c := range colors {
+		if got := sink.frames[i].Image.RGBAAt(2, 2); got != c {
+			t.Errorf("frame %d color = %v, want %v", i, got, c)
+		}
+	}
+}
+
+func TestPNGReaderInvalid(t *testing.T) {
+	r := NewPNGReader(strings.NewReader("not a png"), capture.IntFPS(15))
+	if _, err := r.ReadFrame(); err == nil || err == io.EOF {
+		t.Errorf("ReadFrame() error = %v, want a decode error", err)
+	}
+}
+
+func TestPNGReaderEmpty(t *testing.T) {
+	r := NewPNGReader(strings.NewReader(""), capture.IntFPS(15))
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("ReadFrame() error = %v, want io.EOF", err)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	read := func() []*capture.Frame {
+		var buf bytes.Buffer
+		for i := 0; i < 3; i++ {
+			buf.Write(solid(2, 2, color.RGBA{A: 255}).Pix)
+		}
+		sink := &collectingSink{}
+		if _, err := Copy(sink, Rebase(NewRawReader(&buf, 2, 2, capture.IntFPS(10)), capture.Epoch)); err != nil {
+			t.Fatalf("Copy() failed: %v", err)
+		}
+		return sink.frames
+	}
+
+	// Reads made at different times get the same timestamps
+	first := read()
+	time.Sleep(5 * time.Millisecond)
+	second := read()
+
+	for i, frame := range first {
+		want := capture.Epoch.Add(time.Duration(i) * 100 * time.Millisecond)
+		if !frame.Timestamp.Equal(want) {
+			t.Errorf("frame %d timestamp = %v, want %v", i, frame.Timestamp, want)
+		}
+		if !second[i].Timestamp.Equal(frame.Timestamp) {
+			t.Errorf("frame %d timestamp differs between reads: %v and %v", i, frame.Timestamp, second[i].Timestamp)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		w, h    int
+		wantErr bool
+	}{
+		{in: "800x600", w: 800, h: 600},
+		{in: "1920X1080", w: 1920, h: 1080},
+		{in: " 64x48 ", w: 64, h: 48},
+		{in: "800", wantErr: true},
+		{in: "0x600", wantErr: true},
+		{in: "axb", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		w, h, err := ParseSize(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (w != tt.w || h != tt.h) {
+			t.Errorf("ParseSize(%q) = %dx%d, want %dx%d", tt.in, w, h, tt.w, tt.h)
+		}
+	}
+}
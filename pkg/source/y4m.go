@@ -0,0 +1,195 @@
+package source
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+)
+
+// y4mMagic starts every YUV4MPEG2 stream
+const y4mMagic = "YUV4MPEG2"
+
+// Y4MReader reads frames from a YUV4MPEG2 (Y4M) stream, as produced by
+// "ffmpeg -f yuv4mpegpipe". The frame size and rate come from the stream
+// header.
+type Y4MReader struct {
+	r      *bufio.Reader
+	width  int
+	height int
+	fps    capture.FPS
+
+	// chroma plane size and subsampling shifts
+	cw, ch         int
+	shiftX, shiftY uint
+	mono           bool
+	limited        bool
+
+	buf   []byte
+	clock clock
+}
+
+// NewY4MReader reads the stream header and returns a reader for its frames.
+// 4:2:0, 4:2:2, 4:4:4, and mono 8-bit streams are supported.
+func NewY4MReader(r io.Reader) (*Y4MReader, error) {
+	br := bufio.NewReader(r)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Y4M header: %w", err)
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != y4mMagic {
+		return nil, fmt.Errorf("not a Y4M stream")
+	}
+
+	y := &Y4MReader{r: br, fps: capture.FPS25, shiftX: 1, shiftY: 1, limited: true}
+	for _, f := range fields[1:] {
+		value := f[1:]
+		switch f[0] {
+		case 'W':
+			y.width, err = strconv.Atoi(value)
+		case 'H':
+			y.height, err = strconv.Atoi(value)
+		case 'F':
+			y.fps, err = parseY4MRate(value)
+		case 'C':
+			err = y.setColorspace(value)
+		case 'X':
+			switch value {
+			case "COLORRANGE=FULL":
+				y.limited = false
+			case "COLORRANGE=LIMITED":
+				y.limited = true
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Y4M header field %q: %w", f, err)
+		}
+	}
+
+	if y.width <= 0 || y.height <= 0 {
+		return nil, fmt.Errorf("Y4M header has no frame size")
+	}
+
+	if !y.mono {
+		y.cw = (y.width + (1 << y.shiftX) - 1) >> y.shiftX
+		y.ch = (y.height + (1 << y.shiftY) - 1) >> y.shiftY
+	}
+	y.buf = make([]byte, y.width*y.height+2*y.cw*y.ch)
+	y.clock = newClock(y.fps)
+
+	return y, nil
+}
+
+// setColorspace configures chroma subsampling from a C header value
+func (y *Y4MReader) setColorspace(c string) error {
+	switch {
+	case c == "420" || c == "420jpeg" || c == "420paldv" || c == "420mpeg2":
+		y.shiftX, y.shiftY = 1, 1
+	case c == "422":
+		y.shiftX, y.shiftY = 1, 0
+	case c == "444":
+		y.shiftX, y.shiftY = 0, 0
+	case c == "mono":
+		y.mono = true
+	default:
+		return fmt.Errorf("unsupported colorspace %s", c)
+	}
+	return nil
+}
+
+// parseY4MRate parses a num:den frame rate
+func parseY4MRate(s string) (capture.FPS, error) {
+	num, den, ok := strings.Cut(s, ":")
+	if !ok {
+		return capture.FPS{}, fmt.Errorf("frame rate must be num:den")
+	}
+	return capture.ParseFPS(num + "/" + den)
+}
+
+// Size returns the frame size from the stream header
+func (y *Y4MReader) Size() (width, height int) {
+	return y.width, y.height
+}
+
+// FPS returns the frame rate from the stream header
+func (y *Y4MReader) FPS() capture.FPS {
+	return y.fps
+}
+
+// ReadFrame reads and converts the next frame
+func (y *Y4MReader) ReadFrame() (*capture.Frame, error) {
+	line, err := y.r.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Y4M frame %d header: %w", y.clock.n, err)
+	}
+	if !strings.HasPrefix(line, "FRAME") {
+		return nil, fmt.Errorf("invalid Y4M frame %d header %q", y.clock.n, strings.TrimSpace(line))
+	}
+
+	if _, err := io.ReadFull(y.r, y.buf); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("truncated Y4M frame %d: %w", y.clock.n, err)
+	}
+
+	return &capture.Frame{Image: y.toRGBA(), Timestamp: y.clock.next()}, nil
+}
+
+// toRGBA converts the planes in buf to an RGBA image
+func (y *Y4MReader) toRGBA() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, y.width, y.height))
+	n := y.width * y.height
+	yPlane := y.buf[:n]
+	cbPlane := y.buf[n : n+y.cw*y.ch]
+	crPlane := y.buf[n+y.cw*y.ch:]
+
+	for py := 0; py < y.height; py++ {
+		for px := 0; px < y.width; px++ {
+			luma, cb, cr := yPlane[py*y.width+px], uint8(128), uint8(128)
+			if !y.mono {
+				ci := (py>>y.shiftY)*y.cw + px>>y.shiftX
+				cb, cr = cbPlane[ci], crPlane[ci]
+			}
+			if y.limited {
+				luma, cb, cr = expandLuma(luma), expandChroma(cb), expandChroma(cr)
+			}
+			r, g, b := color.YCbCrToRGB(luma, cb, cr)
+			img.SetRGBA(px, py, color.RGBA{R: r, G: g, B: b, A: 255})
+		}
+	}
+
+	return img
+}
+
+// expandLuma maps limited-range luma (16-235) to full range
+func expandLuma(v uint8) uint8 {
+	return clampByte((int(v) - 16) * 255 / 219)
+}
+
+// expandChroma maps limited-range chroma (16-240) to full range
+func expandChroma(v uint8) uint8 {
+	return clampByte((int(v)-128)*255/224 + 128)
+}
+
+// clampByte clamps v to 0-255
+func clampByte(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
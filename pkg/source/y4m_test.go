@@ -0,0 +1,114 @@
+package source
+
+import (
+	"bytes"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericmhalvorsen/witness/pkg/capture"
+	"github.com/ericmhalvorsen/witness/pkg/encoder"
+)
+
+func TestY4MRoundTrip(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	gray := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+
+	var buf bytes.Buffer
+	enc := encoder.NewY4MEncoder(&buf, capture.IntFPS(10))
+	for _, c := range []color.RGBA{red, gray} {
+		if err := enc.AddFrame(&capture.Frame{Image: solid(4, 2, c), Timestamp: time.Now()}); err != nil {
+			t.Fatalf("AddFrame() failed: %v", err)
+		}
+	}
+
+	r, err := NewY4MReader(&buf)
+	if err != nil {
+		t.Fatalf("NewY4MReader() failed: %v", err)
+	}
+	if w, h := r.Size(); w != 4 || h != 2 {
+		t.Errorf("Size() = %dx%d, want 4x2", w, h)
+	}
+	if r.FPS() != capture.IntFPS(10) {
+		t.Errorf("FPS() = %v, want 10", r.FPS())
+	}
+
+	sink := &collectingSink{}
+	n, err := Copy(sink, r)
+	if err != nil {
+		t.Fatalf("Copy() failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("Copy() = %d frames, want 2", n)
+	}
+
+	for i, want := range []color.RGBA{red, gray} {
+		got := sink.frames[i].Image.RGBAAt(3, 1)
+		if !near(got, want) {
+			t.Errorf("frame %d color = %v, want about %v", i, got, want)
+		}
+	}
+}
+
+func TestY4MReader420(t *testing.T) {
+	// 4x2 limited-range 4:2:0 frame: luma 235 is white, neutral chroma
+	var buf bytes.Buffer
+	buf.WriteString("YUV4MPEG2 W4 H2 F25:1 Ip C420jpeg\n")
+	buf.WriteString("FRAME\n")
+	buf.Write(bytes.Repeat([]byte{235}, 8))
+	buf.Write(bytes.Repeat([]byte{128}, 4))
+
+	r, err := NewY4MReader(&buf)
+	if err != nil {
+		t.Fatalf("NewY4MReader() failed: %v", err)
+	}
+	frame, err := r.ReadFrame()
+	if err != nil {
+		t.Fatalf("ReadFrame() failed: %v", err)
+	}
+	if got := frame.Image.RGBAAt(3, 1); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("pixel = %v, want white", got)
+	}
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("ReadFrame() at end = %v, want io.EOF", err)
+	}
+}
+
+func TestY4MReaderInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"not y4m", "hello\n"},
+		{"missing size", "YUV4MPEG2 F25:1\n"},
+		{"bad rate", "YUV4MPEG2 W4 H2 F25\n"},
+		{"unsupported colorspace", "YUV4MPEG2 W4 H2 C420p10\n"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewY4MReader(strings.NewReader(tt.input)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestY4MReaderTruncated(t *testing.T) {
+	r, err := NewY4MReader(strings.NewReader("YUV4MPEG2 W4 H2 C444\nFRAME\nshort"))
+	if err != nil {
+		t.Fatalf("NewY4MReader() failed: %v", err)
+	}
+	if _, err := r.ReadFrame(); err == nil || err == io.EOF {
+		t.Errorf("ReadFrame() = %v, want truncation error", err)
+	}
+}
+
+// Helper function to compare colors allowing for YCbCr rounding
+func near(a, b color.RGBA) bool {
+	d := func(x, y uint8) bool { return int(x)-int(y) <= 2 && int(y)-int(x) <= 2 }
+	return d(a.R, b.R) && d(a.G, b.G) && d(a.B, b.B) && a.A == b.A
+}